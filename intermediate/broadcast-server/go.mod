@@ -2,4 +2,4 @@ module github.com/jaygaha/roadmap-go-projects/intermediate/broadcast-server
 
 go 1.24.0
 
-require github.com/gorilla/websocket v1.5.3 // indirect
+require github.com/gorilla/websocket v1.5.3
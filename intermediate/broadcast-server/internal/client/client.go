@@ -2,6 +2,7 @@ package client
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
@@ -73,6 +74,12 @@ func (c *Client) readMessages() {
 	for {
 		_, messageBytes, err := c.conn.ReadMessage()
 		if err != nil {
+			// Show why the server closed the connection (shutdown, kicked by an admin, ...)
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) && closeErr.Text != "" {
+				fmt.Printf("\rDisconnected by server: %s\n", closeErr.Text)
+				return
+			}
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
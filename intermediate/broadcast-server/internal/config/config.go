@@ -1,6 +1,14 @@
 package config
 
+import "time"
+
 // Config struct is a configuration struct
 type Config struct {
 	ServerPort string
+
+	// AdminToken protects the /admin endpoints. The admin API is disabled when it is empty.
+	AdminToken string
+
+	// ShutdownTimeout is how long the server waits for clients to be closed on shutdown
+	ShutdownTimeout time.Duration
 }
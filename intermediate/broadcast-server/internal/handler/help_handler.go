@@ -5,7 +5,7 @@ import "fmt"
 // PrintUsage prints the command-line usage
 func PrintUsage() {
 	fmt.Println("Usage:")
-	fmt.Println("  broadcast-server start [-port PORT]")
+	fmt.Println("  broadcast-server start [-port PORT] [-admin-token TOKEN] [-shutdown-timeout DURATION]")
 	fmt.Println("  broadcast-server connect [-host HOST] [-port PORT] [-username USERNAME]")
 	fmt.Println("")
 	fmt.Println("Commands:")
@@ -15,6 +15,7 @@ func PrintUsage() {
 	fmt.Println("Examples:")
 	fmt.Println("  broadcast-server start")
 	fmt.Println("  broadcast-server start -port 6000")
+	fmt.Println("  broadcast-server start -admin-token secret")
 	fmt.Println("  broadcast-server connect")
 	fmt.Println("  broadcast-server connect -host localhost -port 6000 -username jay")
 }
@@ -4,22 +4,28 @@ import (
 	"flag"
 	"log"
 	"os"
+	"time"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/broadcast-server/internal/config"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/broadcast-server/internal/server"
 )
 
 func StartServerCommand() {
-	var port string
+	var port, adminToken string
+	var shutdownTimeout time.Duration
 
 	// Parse flags for server command
 	serverFlgs := flag.NewFlagSet("start", flag.ExitOnError)
 	serverFlgs.StringVar(&port, "port", "6000", "Port to run the server on")
+	serverFlgs.StringVar(&adminToken, "admin-token", os.Getenv("BROADCAST_ADMIN_TOKEN"), "Bearer token for the admin API (disabled when empty)")
+	serverFlgs.DurationVar(&shutdownTimeout, "shutdown-timeout", 10*time.Second, "Time to wait for clients to disconnect on shutdown")
 	serverFlgs.Parse(os.Args[2:])
 
 	// Map to config
 	config := &config.Config{
-		ServerPort: port,
+		ServerPort:      port,
+		AdminToken:      adminToken,
+		ShutdownTimeout: shutdownTimeout,
 	}
 
 	log.Printf("Server port: %s...\n", config.ServerPort)
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// announceRequest is the body of POST /admin/announce
+type announceRequest struct {
+	Content string `json:"content"`
+}
+
+// room describes a chat room and its members
+type room struct {
+	Name      string       `json:"name"`
+	UserCount int          `json:"user_count"`
+	Users     []ClientInfo `json:"users"`
+}
+
+// registerAdminRoutes adds the admin API to the mux. The API is only enabled when an admin token is configured.
+func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
+	if s.config.AdminToken == "" {
+		log.Println("Admin API disabled (no admin token configured)")
+		return
+	}
+
+	mux.Handle("GET /admin/stats", s.requireAdmin(s.handleAdminStats))
+	mux.Handle("GET /admin/users", s.requireAdmin(s.handleAdminUsers))
+	mux.Handle("DELETE /admin/users/{username}", s.requireAdmin(s.handleAdminKick))
+	mux.Handle("GET /admin/rooms", s.requireAdmin(s.handleAdminRooms))
+	mux.Handle("POST /admin/announce", s.requireAdmin(s.handleAdminAnnounce))
+
+	log.Printf("Admin API enabled: http://localhost:%s/admin", s.config.ServerPort)
+}
+
+// requireAdmin checks the bearer token before calling next
+func (s *Server) requireAdmin(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next(w, r)
+	})
+}
+
+// handleAdminStats returns a full snapshot of the hub
+func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.hub.Stats(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "hub unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleAdminUsers lists connected users
+func (s *Server) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.hub.Stats(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "hub unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats.Clients)
+}
+
+// handleAdminRooms lists rooms with their members
+func (s *Server) handleAdminRooms(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.hub.Stats(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "hub unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, []room{{
+		Name:      defaultRoom,
+		UserCount: stats.ConnectedClients,
+		Users:     stats.Clients,
+	}})
+}
+
+// handleAdminKick disconnects every connection of a user
+func (s *Server) handleAdminKick(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "kicked by administrator"
+	}
+
+	kicked, err := s.hub.Kick(r.Context(), username, reason)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "hub unavailable"})
+		return
+	}
+	if kicked == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "user not connected"})
+		return
+	}
+
+	log.Printf("Admin kicked %s (%d connection(s)): %s", username, kicked, reason)
+	writeJSON(w, http.StatusOK, map[string]any{"username": username, "kicked": kicked})
+}
+
+// handleAdminAnnounce broadcasts a system announcement to every client
+func (s *Server) handleAdminAnnounce(w http.ResponseWriter, r *http.Request) {
+	var req announceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "content is required"})
+		return
+	}
+
+	if err := s.hub.Announce(r.Context(), req.Content); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to send announcement"})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "sent"})
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("error encoding response: %v", err)
+	}
+}
@@ -34,22 +34,41 @@ var upgrader = websocket.Upgrader{
 
 // Client represents a connected client
 type Client struct {
-	hub      *Hub
-	conn     *websocket.Conn
-	send     chan []byte
-	username string
+	hub         *Hub
+	conn        *websocket.Conn
+	send        chan []byte
+	username    string
+	remoteAddr  string
+	connectedAt time.Time
+
+	// closeFrame is the payload of the close message sent once send is closed.
+	// It is written by the hub before closing send, so writePump can read it safely.
+	closeFrame []byte
+
+	// done is closed when writePump has finished writing to the connection
+	done chan struct{}
 }
 
 // NewClient creates a new client
 func NewClient(hub *Hub, conn *websocket.Conn, username string) *Client {
 	return &Client{
-		hub:      hub,
-		conn:     conn,
-		send:     make(chan []byte, 256),
-		username: username,
+		hub:         hub,
+		conn:        conn,
+		send:        make(chan []byte, 256),
+		username:    username,
+		remoteAddr:  conn.RemoteAddr().String(),
+		connectedAt: time.Now(),
+		done:        make(chan struct{}),
 	}
 }
 
+// closeWith closes the send channel so writePump sends a close frame with the given code and reason.
+// It must only be called once, from the hub goroutine.
+func (c *Client) closeWith(code int, reason string) {
+	c.closeFrame = websocket.FormatCloseMessage(code, reason)
+	close(c.send)
+}
+
 // readPump pumps messages from the websocket connection to the hub
 // The purpose of this method is to handle reading messages from a WebSocket connection.
 func (c *Client) readPump() {
@@ -103,6 +122,7 @@ func (c *Client) writePump() {
 		// Stop the ticker and close the connection when the function returns
 		ticker.Stop()
 		c.conn.Close()
+		close(c.done)
 	}()
 
 	// infinite loop that listens for messages from the c.send channel and sends them to the WebSocket connection.
@@ -111,7 +131,7 @@ func (c *Client) writePump() {
 		case message, ok := <-c.send: // listens for messages from the c.send channel.
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait)) // If the write operation does not complete before this deadline, it will fail.
 			if !ok {                                           // If the channel is closed, send a close message to the peer.
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.conn.WriteMessage(websocket.CloseMessage, c.closeFrame)
 				return
 			}
 
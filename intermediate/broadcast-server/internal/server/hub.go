@@ -1,11 +1,44 @@
 package server
 
 import (
+	"context"
 	"log"
+	"sort"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/broadcast-server/pkg/message"
 )
 
+// defaultRoom is the single room every client joins; the server does not support multiple rooms yet
+const defaultRoom = "global"
+
+// ClientInfo describes a connected client
+type ClientInfo struct {
+	Username    string    `json:"username"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Room        string    `json:"room"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// Stats is a point-in-time snapshot of the hub state
+type Stats struct {
+	StartedAt          time.Time    `json:"started_at"`
+	ConnectedClients   int          `json:"connected_clients"`
+	TotalConnections   int          `json:"total_connections"`
+	MessagesBroadcast  int          `json:"messages_broadcast"`
+	SlowClientsDropped int          `json:"slow_clients_dropped"`
+	ClientsKicked      int          `json:"clients_kicked"`
+	Clients            []ClientInfo `json:"clients"`
+}
+
+// kickRequest asks the hub to disconnect every client using the given username
+type kickRequest struct {
+	username string
+	reason   string
+	reply    chan int
+}
+
 // Hub maintains the set of active clients and broadcasts messages to them
 type Hub struct {
 	// Registered client
@@ -19,6 +52,23 @@ type Hub struct {
 
 	// Unregister requests from the client
 	unregister chan *Client
+
+	// Snapshot requests; the hub replies with a copy of its state
+	stats chan chan Stats
+
+	// Kick requests from the admin API
+	kick chan kickRequest
+
+	// Shutdown requests; the hub replies with the clients it closed
+	shutdown chan chan []*Client
+
+	// Counters, only touched by the Run goroutine
+	startedAt          time.Time
+	totalConnections   int
+	messagesBroadcast  int
+	slowClientsDropped int
+	clientsKicked      int
+	closing            bool
 }
 
 // NewHub creates a new hub
@@ -28,6 +78,10 @@ func NewHub() *Hub {
 		broadcast:  make(chan []byte),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		stats:      make(chan chan Stats),
+		kick:       make(chan kickRequest),
+		shutdown:   make(chan chan []*Client),
+		startedAt:  time.Now(),
 	}
 }
 
@@ -38,7 +92,14 @@ func (h *Hub) Run() {
 		select {
 		// When a client connects, it is received and assigned to the variable client.
 		case client := <-h.register:
+			// Refuse new clients once the server is shutting down
+			if h.closing {
+				client.closeWith(websocket.CloseGoingAway, "server shutting down")
+				continue
+			}
+
 			h.clients[client] = true // Add the client to the map
+			h.totalConnections++
 			log.Printf("Client connected: %s (Total: %d)", client.GetUsername(), len(h.clients))
 
 			// Send join message to all clients
@@ -53,27 +114,72 @@ func (h *Hub) Run() {
 			// When a client disconnects, it is received and assigned to the variable client.
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client) // Remove the client from the map
-				close(client.send)        // Close the send channel to signal the client to stop listening
-				log.Printf("Client disconnected: %s (Total: %d)", client.GetUsername(), len(h.clients))
-
-				// Send leave message to all clients
-				leaveMsg := message.NewMessage(message.TypeLeave, client.GetUsername(), "")
-				if msgBytes, err := leaveMsg.ToJson(); err == nil {
-					h.broadcastToAll(msgBytes)
-				}
-
-				// Send user count update
-				h.sendUserCount()
+				h.removeClient(client, websocket.CloseNormalClosure, "")
 			}
 
 			// When a message is received, it is broadcasted to all connected clients using the broadcastToAll method.
 		case message := <-h.broadcast:
+			h.messagesBroadcast++
 			h.broadcastToAll(message)
+
+			// When a snapshot is requested, a copy of the state is sent back so callers never touch the map.
+		case reply := <-h.stats:
+			reply <- h.snapshot()
+
+			// When an admin kicks a user, every connection with that username is closed.
+		case req := <-h.kick:
+			kicked := 0
+			for client := range h.clients {
+				if client.GetUsername() != req.username {
+					continue
+				}
+
+				// Tell the client why before the close frame is sent
+				notice := message.NewMessage(message.TypeSystem, "", "You have been removed by an administrator: "+req.reason)
+				if msgBytes, err := notice.ToJson(); err == nil {
+					select {
+					case client.send <- msgBytes:
+					default:
+					}
+				}
+
+				h.removeClient(client, websocket.ClosePolicyViolation, req.reason)
+				kicked++
+			}
+			h.clientsKicked += kicked
+			req.reply <- kicked
+
+			// When the server shuts down, every client receives a close frame.
+		case reply := <-h.shutdown:
+			h.closing = true
+			closed := make([]*Client, 0, len(h.clients))
+			for client := range h.clients {
+				delete(h.clients, client)
+				client.closeWith(websocket.CloseGoingAway, "server shutting down")
+				closed = append(closed, client)
+			}
+			log.Printf("Closed %d client connection(s) for shutdown", len(closed))
+			reply <- closed
 		}
 	}
 }
 
+// removeClient disconnects a client and notifies the remaining clients
+func (h *Hub) removeClient(client *Client, code int, reason string) {
+	delete(h.clients, client) // Remove the client from the map
+	client.closeWith(code, reason)
+	log.Printf("Client disconnected: %s (Total: %d)", client.GetUsername(), len(h.clients))
+
+	// Send leave message to all clients
+	leaveMsg := message.NewMessage(message.TypeLeave, client.GetUsername(), "")
+	if msgBytes, err := leaveMsg.ToJson(); err == nil {
+		h.broadcastToAll(msgBytes)
+	}
+
+	// Send user count update
+	h.sendUserCount()
+}
+
 // broadcastToAll sends a message to all connected clients
 func (h *Hub) broadcastToAll(message []byte) {
 	// Iterate over all clients and send the message to their send channel
@@ -82,8 +188,9 @@ func (h *Hub) broadcastToAll(message []byte) {
 		// Attempt to send the message to the client's send channel
 		case client.send <- message:
 		default:
-			close(client.send)
+			client.closeWith(websocket.CloseTryAgainLater, "client too slow")
 			delete(h.clients, client)
+			h.slowClientsDropped++
 		}
 	}
 }
@@ -101,7 +208,95 @@ func (h *Hub) sendUserCount() {
 	}
 }
 
+// snapshot copies the hub state; it must only be called from the Run goroutine
+func (h *Hub) snapshot() Stats {
+	clients := make([]ClientInfo, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, ClientInfo{
+			Username:    client.GetUsername(),
+			RemoteAddr:  client.remoteAddr,
+			Room:        defaultRoom,
+			ConnectedAt: client.connectedAt,
+		})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].Username == clients[j].Username {
+			return clients[i].ConnectedAt.Before(clients[j].ConnectedAt)
+		}
+		return clients[i].Username < clients[j].Username
+	})
+
+	return Stats{
+		StartedAt:          h.startedAt,
+		ConnectedClients:   len(h.clients),
+		TotalConnections:   h.totalConnections,
+		MessagesBroadcast:  h.messagesBroadcast,
+		SlowClientsDropped: h.slowClientsDropped,
+		ClientsKicked:      h.clientsKicked,
+		Clients:            clients,
+	}
+}
+
+// Stats returns a snapshot of the hub state. It is safe to call from any goroutine
+// and returns ctx.Err() if the hub does not answer before ctx is done.
+func (h *Hub) Stats(ctx context.Context) (Stats, error) {
+	reply := make(chan Stats, 1)
+	select {
+	case h.stats <- reply:
+	case <-ctx.Done():
+		return Stats{}, ctx.Err()
+	}
+	return <-reply, nil
+}
+
 // GetClientCount returns the number of connected clients
-func (h *Hub) GetClientCount() int {
-	return len(h.clients)
+func (h *Hub) GetClientCount(ctx context.Context) (int, error) {
+	stats, err := h.Stats(ctx)
+	return stats.ConnectedClients, err
+}
+
+// Kick disconnects every client with the given username and returns how many were removed
+func (h *Hub) Kick(ctx context.Context, username, reason string) (int, error) {
+	reply := make(chan int, 1)
+	select {
+	case h.kick <- kickRequest{username: username, reason: reason, reply: reply}:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	return <-reply, nil
+}
+
+// Announce broadcasts a system message to every connected client
+func (h *Hub) Announce(ctx context.Context, content string) error {
+	msg := message.NewMessage(message.TypeSystem, "", content)
+	msgBytes, err := msg.ToJson()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case h.broadcast <- msgBytes:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown sends a close frame to every client and waits until they have been written or ctx expires
+func (h *Hub) Shutdown(ctx context.Context) error {
+	reply := make(chan []*Client, 1)
+	select {
+	case h.shutdown <- reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for _, client := range <-reply {
+		select {
+		case <-client.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
 }
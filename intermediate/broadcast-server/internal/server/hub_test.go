@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/broadcast-server/internal/config"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/broadcast-server/pkg/message"
+)
+
+// startTestServer runs a hub behind the WebSocket handler
+func startTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	s := &Server{config: &config.Config{}, hub: NewHub()}
+	go s.hub.Run()
+
+	ts := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+	t.Cleanup(ts.Close)
+	return s, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func dial(t *testing.T, url, username string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?username="+username, nil)
+	if err != nil {
+		t.Fatalf("dialing as %s: %v", username, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitForStats polls the hub until ok accepts its stats
+func waitForStats(t *testing.T, h *Hub, ok func(Stats) bool) Stats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		stats, err := h.Stats(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok(stats) {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the hub, last stats %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// readUntilClose reads messages until the server closes the connection
+// and returns the close error with the messages read before it.
+func readUntilClose(t *testing.T, conn *websocket.Conn) (*websocket.CloseError, []*message.Message) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msgs []*message.Message
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				t.Fatalf("expected a close frame, got %v", err)
+			}
+			return closeErr, msgs
+		}
+		// Queued messages are sent newline-separated in one frame
+		for _, line := range strings.Split(string(data), "\n") {
+			msg, err := message.FromJson([]byte(line))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	s, url := startTestServer(t)
+	ctx := context.Background()
+
+	alice := dial(t, url, "alice")
+	bobs := []*websocket.Conn{dial(t, url, "bob"), dial(t, url, "bob")}
+
+	stats := waitForStats(t, s.hub, func(st Stats) bool { return st.ConnectedClients == 3 })
+	if stats.TotalConnections != 3 || len(stats.Clients) != 3 {
+		t.Fatalf("expected 3 connections, got %+v", stats)
+	}
+	for i, want := range []string{"alice", "bob", "bob"} {
+		if c := stats.Clients[i]; c.Username != want || c.Room != defaultRoom {
+			t.Errorf("client %d: expected %s in %s, got %+v", i, want, defaultRoom, c)
+		}
+	}
+
+	if err := alice.WriteJSON(message.NewMessage(message.TypeMessage, "", "hello")); err != nil {
+		t.Fatal(err)
+	}
+	waitForStats(t, s.hub, func(st Stats) bool { return st.MessagesBroadcast == 1 })
+
+	// Kicking closes every connection of the user, with the reason
+	if n, err := s.hub.Kick(ctx, "bob", "spam"); err != nil || n != 2 {
+		t.Fatalf("kick: expected 2 connections removed, got %d (%v)", n, err)
+	}
+	for _, bob := range bobs {
+		closeErr, msgs := readUntilClose(t, bob)
+		if closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != "spam" {
+			t.Errorf("expected a policy violation close for spam, got %v", closeErr)
+		}
+		if last := msgs[len(msgs)-1]; last.Type != message.TypeSystem || !strings.Contains(last.Content, "spam") {
+			t.Errorf("expected the kick notice last, got %+v", last)
+		}
+	}
+	if n, err := s.hub.Kick(ctx, "nobody", "spam"); err != nil || n != 0 {
+		t.Fatalf("kicking an unknown user: got %d (%v)", n, err)
+	}
+
+	stats = waitForStats(t, s.hub, func(st Stats) bool { return st.ConnectedClients == 1 })
+	if stats.ClientsKicked != 2 || stats.Clients[0].Username != "alice" {
+		t.Fatalf("expected alice left and 2 kicked, got %+v", stats)
+	}
+
+	// Shutdown returns once alice has been sent her close frame
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := s.hub.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	closeErr, msgs := readUntilClose(t, alice)
+	if closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("expected a going away close, got %v", closeErr)
+	}
+	var heard bool
+	for _, msg := range msgs {
+		heard = heard || (msg.Type == message.TypeMessage && msg.Username == "alice" && msg.Content == "hello")
+	}
+	if !heard {
+		t.Errorf("expected alice's broadcast echoed back, got %d messages", len(msgs))
+	}
+
+	// Clients connecting during shutdown are turned away
+	late := dial(t, url, "carol")
+	if closeErr, _ := readUntilClose(t, late); closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("expected the late client turned away, got %v", closeErr)
+	}
+	if n, err := s.hub.GetClientCount(ctx); err != nil || n != 0 {
+		t.Fatalf("expected no clients after shutdown, got %d (%v)", n, err)
+	}
+}
+
+// Without Run nothing answers, so calls give up when their context is done
+func TestHubNotRunning(t *testing.T) {
+	h := NewHub()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := h.GetClientCount(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetClientCount: expected DeadlineExceeded, got %v", err)
+	}
+	if _, err := h.Kick(ctx, "alice", "spam"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Kick: expected DeadlineExceeded, got %v", err)
+	}
+	if err := h.Announce(ctx, "hello"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Announce: expected DeadlineExceeded, got %v", err)
+	}
+	if err := h.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown: expected DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// handleMetrics exposes hub counters in the Prometheus text exposition format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.hub.Stats(r.Context())
+	if err != nil {
+		http.Error(w, "hub unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var b strings.Builder
+	writeMetric(&b, "broadcast_connected_clients", "gauge", "Number of currently connected clients.", float64(stats.ConnectedClients))
+	writeMetric(&b, "broadcast_connections_total", "counter", "Total number of accepted client connections.", float64(stats.TotalConnections))
+	writeMetric(&b, "broadcast_messages_total", "counter", "Total number of messages broadcast by the hub.", float64(stats.MessagesBroadcast))
+	writeMetric(&b, "broadcast_slow_clients_dropped_total", "counter", "Total number of clients dropped because their send buffer was full.", float64(stats.SlowClientsDropped))
+	writeMetric(&b, "broadcast_clients_kicked_total", "counter", "Total number of clients removed through the admin API.", float64(stats.ClientsKicked))
+	writeMetric(&b, "broadcast_uptime_seconds", "gauge", "Seconds since the hub was started.", time.Since(stats.StartedAt).Seconds())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// writeMetric writes a single metric with its HELP and TYPE lines
+func writeMetric(b *strings.Builder, name, metricType, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, metricType)
+	fmt.Fprintf(b, "%s %g\n", name, value)
+}
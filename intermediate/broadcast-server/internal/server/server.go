@@ -1,19 +1,26 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/broadcast-server/internal/config"
 )
 
+// defaultShutdownTimeout is used when the config does not set one
+const defaultShutdownTimeout = 10 * time.Second
+
 // Server represents the broadcast server
 type Server struct {
-	config *config.Config
-	hub    *Hub
+	config     *config.Config
+	hub        *Hub
+	httpServer *http.Server
 }
 
 // NewServer creates a new server instance
@@ -24,22 +31,38 @@ func NewServer(cfg *config.Config) *Server {
 	}
 }
 
-// Start starts the server
+// Start starts the server and blocks until it has been shut down
 func (s *Server) Start() error {
 	// Start the hub
 	go s.hub.Run()
 
 	// Setup HTTP routes
-	http.HandleFunc("/ws", s.handleWebSocket)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	s.registerAdminRoutes(mux)
+
+	addr := ":" + s.config.ServerPort
+	s.httpServer = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
 
 	// Setup graceful shutdown
-	go s.setupGracefulShutdown()
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- s.waitForShutdown()
+	}()
 
 	// Start the server
-	addr := ":" + s.config.ServerPort
 	log.Printf("WebSocket endpoint: ws://localhost%s/ws", addr)
+	log.Printf("Metrics endpoint: http://localhost%s/metrics", addr)
+
+	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 
-	return http.ListenAndServe(addr, nil)
+	return <-shutdownErr
 }
 
 // handleWebSocket handles WebSocket connections
@@ -67,18 +90,32 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	go client.readPump()
 }
 
-// setupGracefulShutdown handles graceful shutdown
-func (s *Server) setupGracefulShutdown() {
+// waitForShutdown blocks until SIGINT/SIGTERM, then stops accepting connections
+// and sends a close frame to every connected client before returning.
+func (s *Server) waitForShutdown() error {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	<-c
 	log.Println("\nReceived shutdown signal. Gracefully shutting down...")
 
-	// Here you could add cleanup logic like:
-	// - Closing database connections
-	// - Saving state
-	// - Notifying clients of shutdown
+	timeout := s.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stop accepting new connections. WebSocket connections are hijacked,
+	// so http.Server does not track them and the hub closes them below.
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	if err := s.hub.Shutdown(ctx); err != nil {
+		return err
+	}
 
-	os.Exit(0)
+	log.Println("All clients disconnected. Bye!")
+	return nil
 }
@@ -15,6 +15,7 @@ const (
 	TypeJoin      MessageType = "join"
 	TypeLeave     MessageType = "leave"
 	TypeUserCount MessageType = "user_count"
+	TypeSystem    MessageType = "system"
 )
 
 // Message holds a message sent between client and server
@@ -61,6 +62,8 @@ func (m *Message) String() string {
 		return fmt.Sprintf("[%s] %s: %s", m.CreatedAt.Format(time.RFC3339), m.Username, m.Content)
 	case TypeUserCount:
 		return fmt.Sprintf("Users online: %d", m.UserCount)
+	case TypeSystem:
+		return fmt.Sprintf("[%s] *** %s", m.CreatedAt.Format(time.RFC3339), m.Content)
 	default:
 		return fmt.Sprintf("[%s] %s: %s", m.CreatedAt.Format(time.RFC3339), m.Username, m.Content)
 	}
@@ -2,28 +2,61 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 // Config holds all application-wide settings. We load from env vars
 // so secrets never live in source code.
 type Config struct {
-	JWTSecret     string
-	StripeKey     string
-	DBPath        string
-	Port          string
-	AdminEmail    string
-	AdminPassword string
+	JWTSecret           string
+	AccessTokenTTL      time.Duration // lifetime of a JWT access token
+	RefreshTokenTTL     time.Duration // lifetime of a refresh token; each refresh issues a new one
+	PasswordResetTTL    time.Duration // how long a password reset link works
+	Mailer              string        // "log" or "file"
+	MailFrom            string
+	MailDir             string // where the file mailer writes .eml files
+	BaseURL             string // public URL of the site, used in emailed links
+	PaymentProvider     string
+	StripeKey           string
+	StripeWebhookSecret string
+	ShippingFee         int64         // flat shipping fee in cents
+	FreeShippingMin     int64         // basket value in cents that ships free; 0 disables
+	ReservationTTL      time.Duration // how long a cart line holds its stock
+	ReservationSweep    time.Duration // how often expired reservations are released
+	LowStockThreshold   int           // stock at or below this is reported as low
+	AlertEmail          string        // where alerts are mailed; empty only logs them
+	DBPath              string
+	Port                string
+	AdminEmail          string
+	AdminPassword       string
 }
 
 // Load reads environment variables and populates the Config struct.
 func Load() *Config {
+	port := getEnv("PORT", "8080")
 	return &Config{
-		JWTSecret:     getEnv("JWT_SECRET", "change-me-in-production"),
-		StripeKey:     getEnv("STRIPE_SECRET_KEY", ""),
-		DBPath:        getEnv("DB_PATH", "ecommerce.db"),
-		Port:          getEnv("PORT", "8080"),
-		AdminEmail:    getEnv("ADMIN_EMAIL", "admin@jaygaha.com.np"),
-		AdminPassword: getEnv("ADMIN_PASSWORD", "admin123"),
+		JWTSecret:           getEnv("JWT_SECRET", "change-me-in-production"),
+		AccessTokenTTL:      getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:     getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		PasswordResetTTL:    getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+		Mailer:              getEnv("MAILER", "log"),
+		MailFrom:            getEnv("MAIL_FROM", "Goods & Co. <no-reply@localhost>"),
+		MailDir:             getEnv("MAIL_DIR", "data/mail"),
+		BaseURL:             getEnv("BASE_URL", "http://localhost:"+port),
+		PaymentProvider:     getEnv("PAYMENT_PROVIDER", ""), // "stripe" (default) or "fake"
+		StripeKey:           getEnv("STRIPE_SECRET_KEY", ""),
+		StripeWebhookSecret: getEnv("STRIPE_WEBHOOK_SECRET", ""),
+		ShippingFee:         getEnvInt64("SHIPPING_FEE", 500),
+		FreeShippingMin:     getEnvInt64("FREE_SHIPPING_MIN", 5000),
+		ReservationTTL:      getEnvDuration("RESERVATION_TTL", 15*time.Minute),
+		ReservationSweep:    getEnvDuration("RESERVATION_SWEEP_INTERVAL", time.Minute),
+		LowStockThreshold:   int(getEnvInt64("LOW_STOCK_THRESHOLD", 5)),
+		AlertEmail:          getEnv("ALERT_EMAIL", ""),
+		DBPath:              getEnv("DB_PATH", "ecommerce.db"),
+		Port:                port,
+		AdminEmail:          getEnv("ADMIN_EMAIL", "admin@jaygaha.com.np"),
+		AdminPassword:       getEnv("ADMIN_PASSWORD", "admin123"),
 	}
 }
 
@@ -33,3 +66,17 @@ func getEnv(key string, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if v, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil {
+		return v
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return defaultValue
+}
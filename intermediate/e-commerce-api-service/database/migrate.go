@@ -0,0 +1,205 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Migration is one versioned schema change. Up and Down run in a single
+// transaction together with the schema_migrations bookkeeping, so a failed
+// step leaves the database at the previous version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil while pending
+}
+
+// execAll returns a migration step that runs the statements in order.
+func execAll(stmts ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for i, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("statement %d: %w", i+1, err)
+			}
+		}
+		return nil
+	}
+}
+
+// Migrator applies and rolls back migrations, recording the applied
+// versions in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a Migrator for the service's migrations
+func NewMigrator(db *sql.DB) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+            version    INTEGER PRIMARY KEY,
+            name       TEXT    NOT NULL,
+            applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// applied returns the applied versions and when they were applied.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			v  int
+			at time.Time
+		)
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, fmt.Errorf("reading schema_migrations: %w", err)
+		}
+		versions[v] = at
+	}
+
+	return versions, rows.Err()
+}
+
+// Version returns the highest applied version, or 0 for an empty database.
+func (m *Migrator) Version() (int, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	current := 0
+	for v := range versions {
+		current = max(current, v)
+	}
+	return current, nil
+}
+
+// Status lists every known migration in order with its applied time.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if at, ok := versions[mig.Version]; ok {
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Up applies pending migrations up to and including target; 0 means all.
+// It returns how many were applied.
+func (m *Migrator) Up(target int) (int, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if _, ok := versions[mig.Version]; ok {
+			continue
+		}
+		if err := m.run(mig, mig.Up, true); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down rolls back the given number of applied migrations, newest first.
+// It returns how many were rolled back.
+func (m *Migrator) Down(steps int) (int, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := versions[mig.Version]; !ok {
+			continue
+		}
+		if err := m.run(mig, mig.Down, false); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// run executes one direction of a migration and records the result.
+func (m *Migrator) run(mig Migration, step func(tx *sql.Tx) error, up bool) (err error) {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("migration %d %s: %w", mig.Version, direction, err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = step(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) %s: %w", mig.Version, mig.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, mig.Version, mig.Name)
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("recording migration %d: %w", mig.Version, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d %s: %w", mig.Version, direction, err)
+	}
+
+	log.Printf("[DB] Migration %d (%s) %s", mig.Version, mig.Name, direction)
+
+	return nil
+}
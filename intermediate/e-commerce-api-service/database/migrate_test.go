@@ -0,0 +1,183 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// openTestDB opens an empty SQLite database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=ON")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func mustExec(t *testing.T, db *sql.DB, stmts ...string) {
+	t.Helper()
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+// columnExists reports whether the table exists and has the column
+func columnExists(t *testing.T, db *sql.DB, table, column string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func checkVersion(t *testing.T, m *Migrator, want int) {
+	t.Helper()
+	if got, err := m.Version(); err != nil || got != want {
+		t.Fatalf("expected version %d, got %d (%v)", want, got, err)
+	}
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	db := openTestDB(t)
+	m := NewMigrator(db)
+	checkVersion(t, m, 0)
+
+	if n, err := m.Up(2); err != nil || n != 2 {
+		t.Fatalf("up to 2: applied %d (%v)", n, err)
+	}
+	checkVersion(t, m, 2)
+	if !columnExists(t, db, "stock_reservations", "quantity") || columnExists(t, db, "refresh_tokens", "id") {
+		t.Fatal("expected the inventory tables and no accounts tables at version 2")
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != len(migrations) {
+		t.Fatalf("expected %d migrations listed, got %d", len(migrations), len(status))
+	}
+	for _, s := range status {
+		if applied := s.AppliedAt != nil; applied != (s.Version <= 2) {
+			t.Errorf("migration %d (%s): applied %v at version 2", s.Version, s.Name, applied)
+		}
+	}
+
+	if n, err := m.Up(0); err != nil || n != 1 {
+		t.Fatalf("up: applied %d (%v)", n, err)
+	}
+	checkVersion(t, m, 3)
+	mustExec(t, db,
+		`INSERT INTO users (id, email, password) VALUES (1, 'a@example.com', 'hash')`,
+		`INSERT INTO orders (id, user_id, total, ship_name) VALUES (1, 1, 500, 'Ann')`,
+	)
+
+	if n, err := m.Down(2); err != nil || n != 2 {
+		t.Fatalf("down 2: rolled back %d (%v)", n, err)
+	}
+	checkVersion(t, m, 1)
+	if columnExists(t, db, "orders", "ship_name") || columnExists(t, db, "stock_reservations", "id") {
+		t.Fatal("expected the accounts and inventory changes rolled back")
+	}
+
+	// Going up again keeps the baseline data
+	if n, err := m.Up(0); err != nil || n != 2 {
+		t.Fatalf("up again: applied %d (%v)", n, err)
+	}
+	checkVersion(t, m, 3)
+	var total int
+	if err := db.QueryRow(`SELECT total FROM orders WHERE id = 1`).Scan(&total); err != nil || total != 500 {
+		t.Fatalf("expected the order kept, got %d (%v)", total, err)
+	}
+	if n, err := m.Up(0); err != nil || n != 0 {
+		t.Fatalf("up when current: applied %d (%v)", n, err)
+	}
+
+	// Steps beyond the applied migrations stop at an empty database
+	if n, err := m.Down(10); err != nil || n != 3 {
+		t.Fatalf("down all: rolled back %d (%v)", n, err)
+	}
+	checkVersion(t, m, 0)
+	if columnExists(t, db, "users", "id") {
+		t.Fatal("expected the baseline tables dropped")
+	}
+}
+
+// TestMigratorAdoptsLegacyDatabase upgrades a database created by the
+// unversioned runner, before variants, categories and order pricing existed.
+func TestMigratorAdoptsLegacyDatabase(t *testing.T) {
+	db := openTestDB(t)
+	mustExec(t, db,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT NOT NULL UNIQUE, password TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT 'customer', created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE products (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, description TEXT NOT NULL DEFAULT '',
+			price INTEGER NOT NULL, stock INTEGER NOT NULL DEFAULT 0, image_url TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE cart_items (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER NOT NULL REFERENCES users(id),
+			product_id INTEGER NOT NULL REFERENCES products(id), quantity INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP, UNIQUE(user_id, product_id))`,
+		`CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER NOT NULL REFERENCES users(id), total INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending', stripe_payment_id TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE order_items (id INTEGER PRIMARY KEY AUTOINCREMENT, order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			product_id INTEGER NOT NULL REFERENCES products(id), quantity INTEGER NOT NULL, price INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE discounts (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, product_id INTEGER NOT NULL REFERENCES products(id),
+			type TEXT NOT NULL, value INTEGER NOT NULL, starts_at DATETIME, ends_at DATETIME, active INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+
+		`INSERT INTO users (id, email, password) VALUES (1, 'a@example.com', 'hash')`,
+		`INSERT INTO products (id, name, price, stock) VALUES (1, 'Mug', 1000, 5)`,
+		`INSERT INTO cart_items (user_id, product_id, quantity) VALUES (1, 1, 2)`,
+		`INSERT INTO orders (id, user_id, total, status) VALUES (1, 1, 2000, 'paid')`,
+		`INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (1, 1, 2, 1000)`,
+		`INSERT INTO discounts (name, product_id, type, value) VALUES ('Mug sale', 1, 'percentage', 10)`,
+	)
+
+	m := NewMigrator(db)
+	if n, err := m.Up(0); err != nil || n != len(migrations) {
+		t.Fatalf("adopting: applied %d (%v)", n, err)
+	}
+	checkVersion(t, m, len(migrations))
+
+	// Rebuilt tables keep their rows, with the defaults of the new columns
+	var quantity, variant int
+	if err := db.QueryRow(`SELECT quantity, variant_id FROM cart_items WHERE user_id = 1`).Scan(&quantity, &variant); err != nil || quantity != 2 || variant != 0 {
+		t.Fatalf("cart item: got quantity %d, variant %d (%v)", quantity, variant, err)
+	}
+	var category sql.NullInt64
+	if err := db.QueryRow(`SELECT category_id FROM discounts WHERE name = 'Mug sale'`).Scan(&category); err != nil || category.Valid {
+		t.Fatalf("discount: got category %v (%v)", category, err)
+	}
+	// The rebuilt discounts table enforces its new constraint
+	if _, err := db.Exec(`INSERT INTO discounts (name, type, value) VALUES ('No scope', 'fixed', 100)`); err == nil {
+		t.Fatal("expected a discount without a product or category to be rejected")
+	}
+
+	// Added columns
+	for _, c := range columnMigrations {
+		if !columnExists(t, db, c.table, c.column) {
+			t.Errorf("expected %s.%s added", c.table, c.column)
+		}
+	}
+	var status string
+	var subtotal int
+	if err := db.QueryRow(`SELECT status, subtotal FROM orders WHERE id = 1`).Scan(&status, &subtotal); err != nil || status != "paid" || subtotal != 0 {
+		t.Fatalf("order: got %s, subtotal %d (%v)", status, subtotal, err)
+	}
+
+	// The status triggers cover the table created without the CHECK
+	if _, err := db.Exec(`UPDATE orders SET status = 'lost' WHERE id = 1`); err == nil {
+		t.Fatal("expected an invalid status to be rejected")
+	}
+
+	if n, err := m.Up(0); err != nil || n != 0 {
+		t.Fatalf("second run: applied %d (%v)", n, err)
+	}
+}
@@ -6,12 +6,39 @@ import (
 	"log"
 )
 
-// RunMigrations applies schema changes idempotently.
-// In production you'd use a migration tool (goose, golang-migrate),
-// but for an intermediate project this is clean and explicit.
+// RunMigrations brings the schema up to the latest version and prepares the
+// product search index. It runs on every start; the migrate subcommand
+// gives finer control (see Migrator).
 func RunMigrations(db *sql.DB) error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS users (
+	applied, err := NewMigrator(db).Up(0)
+	if err != nil {
+		return err
+	}
+	if err := setupProductSearch(db); err != nil {
+		return err
+	}
+
+	log.Printf("[DB] Successfully applied %d migrations", applied)
+
+	return nil
+}
+
+// migrations is the ordered list of schema versions. Never edit a released
+// migration; add a new one instead.
+var migrations = []Migration{
+	{Version: 1, Name: "baseline", Up: baselineUp, Down: baselineDown},
+	{Version: 2, Name: "inventory", Up: execAll(inventorySchema...), Down: execAll(
+		`DROP TABLE IF EXISTS inventory_movements;`,
+		`DROP TABLE IF EXISTS stock_reservations;`,
+	)},
+	{Version: 3, Name: "accounts", Up: execAll(accountsSchema...), Down: execAll(accountsDown...)},
+}
+
+// baselineSchema is the schema that existed before migrations were versioned.
+// Every statement is idempotent so databases created by the old unversioned
+// runner are adopted as version 1 without changes.
+var baselineSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
             id         INTEGER PRIMARY KEY AUTOINCREMENT,
             email      TEXT    NOT NULL UNIQUE,
             password   TEXT    NOT NULL,
@@ -20,7 +47,7 @@ func RunMigrations(db *sql.DB) error {
             updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
         );`,
 
-		`CREATE TABLE IF NOT EXISTS products (
+	`CREATE TABLE IF NOT EXISTS products (
             id          INTEGER PRIMARY KEY AUTOINCREMENT,
             name        TEXT    NOT NULL,
             description TEXT    NOT NULL DEFAULT '',
@@ -31,27 +58,20 @@ func RunMigrations(db *sql.DB) error {
             updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
         );`,
 
-		`CREATE TABLE IF NOT EXISTS cart_items (
-            id         INTEGER PRIMARY KEY AUTOINCREMENT,
-            user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-            product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
-            quantity   INTEGER NOT NULL DEFAULT 1,
-            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
-            UNIQUE(user_id, product_id)
-        );`,
+	cartItemsTable,
 
-		`CREATE TABLE IF NOT EXISTS orders (
+	`CREATE TABLE IF NOT EXISTS orders (
             id              INTEGER PRIMARY KEY AUTOINCREMENT,
             user_id         INTEGER NOT NULL REFERENCES users(id),
             total           INTEGER NOT NULL,       -- cents
-            status          TEXT    NOT NULL DEFAULT 'pending',
+            status          TEXT    NOT NULL DEFAULT 'pending'
+                            CHECK (status IN ('pending','paid','shipped','delivered','cancelled','refunded')),
             stripe_payment_id TEXT  NOT NULL DEFAULT '',
             created_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
         );`,
 
-		`CREATE TABLE IF NOT EXISTS order_items (
+	`CREATE TABLE IF NOT EXISTS order_items (
             id         INTEGER PRIMARY KEY AUTOINCREMENT,
             order_id   INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
             product_id INTEGER NOT NULL REFERENCES products(id),
@@ -60,16 +80,406 @@ func RunMigrations(db *sql.DB) error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
         );`,
+
+	// Databases created before the CHECK constraint existed still get
+	// the status values enforced through triggers.
+	`CREATE TRIGGER IF NOT EXISTS orders_status_insert_check
+         BEFORE INSERT ON orders
+         WHEN NEW.status NOT IN ('pending','paid','shipped','delivered','cancelled','refunded')
+         BEGIN
+            SELECT RAISE(ABORT, 'invalid order status');
+         END;`,
+
+	`CREATE TRIGGER IF NOT EXISTS orders_status_update_check
+         BEFORE UPDATE OF status ON orders
+         WHEN NEW.status NOT IN ('pending','paid','shipped','delivered','cancelled','refunded')
+         BEGIN
+            SELECT RAISE(ABORT, 'invalid order status');
+         END;`,
+
+	`CREATE INDEX IF NOT EXISTS idx_orders_stripe_payment_id ON orders(stripe_payment_id);`,
+
+	// Idempotency keys let clients retry POST /checkout without creating a second order.
+	`CREATE TABLE IF NOT EXISTS checkout_idempotency_keys (
+            user_id         INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            idempotency_key TEXT    NOT NULL,
+            order_id        INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+            response        TEXT    NOT NULL DEFAULT '', -- JSON CheckoutResponse, empty while in flight
+            created_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (user_id, idempotency_key)
+        );`,
+
+	`CREATE TABLE IF NOT EXISTS coupons (
+            id           INTEGER PRIMARY KEY AUTOINCREMENT,
+            code         TEXT    NOT NULL UNIQUE,
+            type         TEXT    NOT NULL CHECK (type IN ('percentage','fixed')),
+            value        INTEGER NOT NULL,             -- percent or cents
+            min_subtotal INTEGER NOT NULL DEFAULT 0,   -- cents
+            max_uses     INTEGER NOT NULL DEFAULT 0,   -- 0 = unlimited
+            times_used   INTEGER NOT NULL DEFAULT 0,
+            expires_at   DATETIME,
+            active       INTEGER NOT NULL DEFAULT 1,
+            created_at   DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at   DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+	discountsTable,
+
+	`CREATE TABLE IF NOT EXISTS tax_rates (
+            region     TEXT    PRIMARY KEY,            -- e.g. US-CA
+            name       TEXT    NOT NULL DEFAULT '',
+            rate_bps   INTEGER NOT NULL,               -- basis points: 725 = 7.25%
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+	// Catalog: category tree, variants (SKUs) and image gallery
+	`CREATE TABLE IF NOT EXISTS categories (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            parent_id  INTEGER REFERENCES categories(id),
+            name       TEXT    NOT NULL,
+            slug       TEXT    NOT NULL UNIQUE,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+	`CREATE TABLE IF NOT EXISTS product_variants (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+            sku        TEXT    NOT NULL UNIQUE,
+            size       TEXT    NOT NULL DEFAULT '',
+            color      TEXT    NOT NULL DEFAULT '',
+            price      INTEGER NOT NULL,               -- cents
+            stock      INTEGER NOT NULL DEFAULT 0,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+	`CREATE INDEX IF NOT EXISTS idx_product_variants_product ON product_variants(product_id);`,
+
+	// A product with variants shows its cheapest price and total stock,
+	// so listing, filtering and sorting keep working on the products table.
+	`CREATE TRIGGER IF NOT EXISTS product_variants_sync_insert
+         AFTER INSERT ON product_variants
+         BEGIN
+            UPDATE products
+            SET price = (SELECT MIN(price) FROM product_variants WHERE product_id = NEW.product_id),
+                stock = (SELECT SUM(stock) FROM product_variants WHERE product_id = NEW.product_id)
+            WHERE id = NEW.product_id;
+         END;`,
+
+	`CREATE TRIGGER IF NOT EXISTS product_variants_sync_update
+         AFTER UPDATE OF price, stock ON product_variants
+         BEGIN
+            UPDATE products
+            SET price = (SELECT MIN(price) FROM product_variants WHERE product_id = NEW.product_id),
+                stock = (SELECT SUM(stock) FROM product_variants WHERE product_id = NEW.product_id)
+            WHERE id = NEW.product_id;
+         END;`,
+
+	`CREATE TRIGGER IF NOT EXISTS product_variants_sync_delete
+         AFTER DELETE ON product_variants
+         WHEN EXISTS (SELECT 1 FROM product_variants WHERE product_id = OLD.product_id)
+         BEGIN
+            UPDATE products
+            SET price = (SELECT MIN(price) FROM product_variants WHERE product_id = OLD.product_id),
+                stock = (SELECT SUM(stock) FROM product_variants WHERE product_id = OLD.product_id)
+            WHERE id = OLD.product_id;
+         END;`,
+
+	`CREATE TABLE IF NOT EXISTS product_images (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+            url        TEXT    NOT NULL,
+            alt_text   TEXT    NOT NULL DEFAULT '',
+            position   INTEGER NOT NULL DEFAULT 0,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+	`CREATE INDEX IF NOT EXISTS idx_product_images_product ON product_images(product_id, position);`,
+}
+
+// baselineUp creates the baseline schema and upgrades tables created by
+// older releases of the unversioned runner.
+func baselineUp(tx *sql.Tx) error {
+	if err := execAll(baselineSchema...)(tx); err != nil {
+		return err
+	}
+
+	// Tables whose constraints changed are rebuilt with their new definition.
+	for _, t := range tableRebuilds {
+		if err := rebuildTableIfMissing(tx, t.table, t.column, t.definition, t.columns); err != nil {
+			return err
+		}
+	}
+
+	// Columns added after the tables were first created.
+	for _, c := range columnMigrations {
+		if err := addColumnIfMissing(tx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// Indexes on added columns
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_products_category ON products(category_id);`); err != nil {
+		return fmt.Errorf("creating products category index: %w", err)
+	}
+
+	return nil
+}
+
+// baselineDown drops every baseline table, children first. Their triggers
+// and indexes are dropped with them.
+func baselineDown(tx *sql.Tx) error {
+	tables := []string{
+		"checkout_idempotency_keys", "order_items", "orders", "cart_items",
+		"discounts", "coupons", "tax_rates", "product_images", "product_variants",
+		"products", "categories", "users",
+	}
+	for _, t := range tables {
+		if _, err := tx.Exec("DROP TABLE IF EXISTS " + t); err != nil {
+			return fmt.Errorf("dropping %s: %w", t, err)
+		}
+	}
+
+	return nil
+}
+
+// inventorySchema adds cart reservations and the inventory ledger.
+var inventorySchema = []string{
+	// A reservation holds stock for one cart line until it expires.
+	// variant_id is 0 for products without variants, as in cart_items.
+	`CREATE TABLE IF NOT EXISTS stock_reservations (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+            variant_id INTEGER NOT NULL DEFAULT 0,
+            quantity   INTEGER NOT NULL,
+            expires_at DATETIME NOT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE(user_id, product_id, variant_id)
+        );`,
+
+	`CREATE INDEX IF NOT EXISTS idx_stock_reservations_item ON stock_reservations(product_id, variant_id, expires_at);`,
+
+	// Every stock change is recorded with its signed quantity and the
+	// resulting stock, so the current level can be audited.
+	`CREATE TABLE IF NOT EXISTS inventory_movements (
+            id          INTEGER PRIMARY KEY AUTOINCREMENT,
+            product_id  INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+            variant_id  INTEGER NOT NULL DEFAULT 0,
+            type        TEXT    NOT NULL CHECK (type IN ('sale','restock','adjustment','return')),
+            quantity    INTEGER NOT NULL,                -- signed change in units
+            stock_after INTEGER NOT NULL,
+            order_id    INTEGER REFERENCES orders(id) ON DELETE SET NULL,
+            user_id     INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            note        TEXT    NOT NULL DEFAULT '',
+            created_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+	`CREATE INDEX IF NOT EXISTS idx_inventory_movements_product ON inventory_movements(product_id, variant_id, created_at);`,
+}
+
+// orderShippingColumns snapshot the shipping address on the order.
+var orderShippingColumns = []string{
+	"ship_name", "ship_line1", "ship_line2", "ship_city",
+	"ship_region", "ship_postal_code", "ship_country", "ship_phone",
+}
+
+// accountsSchema adds refresh tokens, password reset tokens, the address
+// book and the shipping address of orders.
+var accountsSchema = append([]string{
+	// Only a SHA-256 hash of each token is stored. Rotation revokes the old
+	// token; tokens descended from the same login share a family.
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            token_hash TEXT    NOT NULL UNIQUE,
+            family     TEXT    NOT NULL,
+            expires_at DATETIME NOT NULL,
+            revoked_at DATETIME,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+	`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family);`,
+	`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);`,
+
+	`CREATE TABLE IF NOT EXISTS password_reset_tokens (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            token_hash TEXT    NOT NULL UNIQUE,
+            expires_at DATETIME NOT NULL,
+            used_at    DATETIME,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+	`CREATE TABLE IF NOT EXISTS addresses (
+            id          INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            label       TEXT    NOT NULL DEFAULT '',
+            name        TEXT    NOT NULL,
+            line1       TEXT    NOT NULL,
+            line2       TEXT    NOT NULL DEFAULT '',
+            city        TEXT    NOT NULL,
+            region      TEXT    NOT NULL DEFAULT '',
+            postal_code TEXT    NOT NULL,
+            country     TEXT    NOT NULL,
+            phone       TEXT    NOT NULL DEFAULT '',
+            is_default  INTEGER NOT NULL DEFAULT 0,
+            created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+	`CREATE INDEX IF NOT EXISTS idx_addresses_user ON addresses(user_id);`,
+}, addColumns("orders", orderShippingColumns, "TEXT NOT NULL DEFAULT ''")...)
+
+var accountsDown = append(dropColumns("orders", orderShippingColumns),
+	`DROP TABLE IF EXISTS addresses;`,
+	`DROP TABLE IF EXISTS password_reset_tokens;`,
+	`DROP TABLE IF EXISTS refresh_tokens;`,
+)
+
+// addColumns returns ALTER TABLE statements adding each column with the same definition.
+func addColumns(table string, columns []string, definition string) []string {
+	stmts := make([]string, len(columns))
+	for i, c := range columns {
+		stmts[i] = fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, c, definition)
+	}
+	return stmts
+}
+
+// dropColumns returns ALTER TABLE statements dropping each column.
+func dropColumns(table string, columns []string) []string {
+	stmts := make([]string, len(columns))
+	for i, c := range columns {
+		stmts[i] = fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, c)
+	}
+	return stmts
+}
+
+// cartItemsTable holds one row per product variant in a user's cart.
+// variant_id is 0 for products without variants so the UNIQUE constraint
+// (which treats NULLs as distinct) still merges repeated adds.
+const cartItemsTable = `CREATE TABLE IF NOT EXISTS cart_items (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+            variant_id INTEGER NOT NULL DEFAULT 0,
+            quantity   INTEGER NOT NULL DEFAULT 1,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE(user_id, product_id, variant_id)
+        );`
+
+// discountsTable holds automatic discounts scoped to either one product
+// or a category (including its subcategories).
+const discountsTable = `CREATE TABLE IF NOT EXISTS discounts (
+            id          INTEGER PRIMARY KEY AUTOINCREMENT,
+            name        TEXT    NOT NULL,
+            product_id  INTEGER REFERENCES products(id) ON DELETE CASCADE,
+            category_id INTEGER REFERENCES categories(id) ON DELETE CASCADE,
+            type        TEXT    NOT NULL CHECK (type IN ('percentage','fixed')),
+            value       INTEGER NOT NULL,               -- percent or cents per unit
+            starts_at   DATETIME,
+            ends_at     DATETIME,
+            active      INTEGER NOT NULL DEFAULT 1,
+            created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+            CHECK ((product_id IS NULL) <> (category_id IS NULL))
+        );`
+
+// tableRebuilds lists tables created before a constraint change. When the
+// marker column is missing the table is recreated and the listed columns copied.
+var tableRebuilds = []struct {
+	table, column, definition, columns string
+}{
+	{"cart_items", "variant_id", cartItemsTable, "id, user_id, product_id, quantity, created_at, updated_at"},
+	{"discounts", "category_id", discountsTable, "id, name, product_id, type, value, starts_at, ends_at, active, created_at"},
+}
+
+// columnMigrations lists columns added to existing tables. SQLite has no
+// ADD COLUMN IF NOT EXISTS, so each one is checked before it is added.
+var columnMigrations = []struct {
+	table, column, definition string
+}{
+	// Price breakdown persisted on the order (all cents)
+	{"orders", "subtotal", "INTEGER NOT NULL DEFAULT 0"},
+	{"orders", "discount_total", "INTEGER NOT NULL DEFAULT 0"},
+	{"orders", "coupon_code", "TEXT NOT NULL DEFAULT ''"},
+	{"orders", "coupon_discount", "INTEGER NOT NULL DEFAULT 0"},
+	{"orders", "shipping_fee", "INTEGER NOT NULL DEFAULT 0"},
+	{"orders", "tax_region", "TEXT NOT NULL DEFAULT ''"},
+	{"orders", "tax_rate_bps", "INTEGER NOT NULL DEFAULT 0"},
+	{"orders", "tax_total", "INTEGER NOT NULL DEFAULT 0"},
+	{"order_items", "discount", "INTEGER NOT NULL DEFAULT 0"},
+
+	// Catalog
+	{"products", "category_id", "INTEGER REFERENCES categories(id) ON DELETE SET NULL"},
+	{"order_items", "variant_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"order_items", "sku", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// addColumnIfMissing adds a column unless PRAGMA table_info already lists it.
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	exists, err := hasColumn(tx, table, column)
+	if err != nil || exists {
+		return err
 	}
 
-	// Apply migrations.
-	for i, m := range migrations {
-		if _, err := db.Exec(m); err != nil {
-			return fmt.Errorf("migration %d failed: %w", i, err)
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("adding %s.%s: %w", table, column, err)
+	}
+
+	return nil
+}
+
+// rebuildTableIfMissing recreates a table from its current definition when the
+// marker column is missing, copying the given columns across.
+func rebuildTableIfMissing(tx *sql.Tx, table, column, definition, columns string) error {
+	exists, err := hasColumn(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	steps := []string{
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s_old", table, table),
+		definition,
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s_old", table, columns, columns, table),
+		fmt.Sprintf("DROP TABLE %s_old", table),
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("rebuilding %s: %w", table, err)
 		}
 	}
 
-	log.Printf("[DB] Successfully applied %d migrations", len(migrations))
+	log.Printf("[DB] Rebuilt table %s", table)
 
 	return nil
 }
+
+// hasColumn reports whether PRAGMA table_info lists the column.
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("inspecting %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, fmt.Errorf("inspecting %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("inspecting %s: %w", table, err)
+	}
+
+	return false, nil
+}
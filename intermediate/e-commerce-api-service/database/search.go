@@ -0,0 +1,83 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// searchTriggers keep the products_fts index in sync with the products table.
+// They are named so they can be dropped when FTS5 is not compiled in.
+var searchTriggers = map[string]string{
+	"products_fts_insert": `CREATE TRIGGER IF NOT EXISTS products_fts_insert
+         AFTER INSERT ON products
+         BEGIN
+            INSERT INTO products_fts(rowid, name, description) VALUES (NEW.id, NEW.name, NEW.description);
+         END;`,
+
+	"products_fts_delete": `CREATE TRIGGER IF NOT EXISTS products_fts_delete
+         AFTER DELETE ON products
+         BEGIN
+            INSERT INTO products_fts(products_fts, rowid, name, description)
+            VALUES ('delete', OLD.id, OLD.name, OLD.description);
+         END;`,
+
+	"products_fts_update": `CREATE TRIGGER IF NOT EXISTS products_fts_update
+         AFTER UPDATE OF name, description ON products
+         BEGIN
+            INSERT INTO products_fts(products_fts, rowid, name, description)
+            VALUES ('delete', OLD.id, OLD.name, OLD.description);
+            INSERT INTO products_fts(rowid, name, description) VALUES (NEW.id, NEW.name, NEW.description);
+         END;`,
+}
+
+// FTS5Enabled reports whether the SQLite driver was built with FTS5
+// (go build -tags sqlite_fts5).
+func FTS5Enabled(db *sql.DB) bool {
+	var used int
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&used); err != nil {
+		return false
+	}
+	return used == 1
+}
+
+// setupProductSearch creates the products_fts full-text index and rebuilds it
+// from the products table. Without FTS5 the sync triggers are dropped, so a
+// database created by an FTS5 build keeps working, and search uses LIKE.
+func setupProductSearch(db *sql.DB) error {
+	if !FTS5Enabled(db) {
+		for name := range searchTriggers {
+			if _, err := db.Exec("DROP TRIGGER IF EXISTS " + name); err != nil {
+				return fmt.Errorf("dropping %s: %w", name, err)
+			}
+		}
+		log.Printf("[DB] FTS5 not available (build with -tags sqlite_fts5); product search uses LIKE")
+		return nil
+	}
+
+	// External-content table: the index stores tokens only and reads the
+	// text from products. Porter stemming lets "running" match "run".
+	if _, err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS products_fts USING fts5(
+            name, description,
+            content='products', content_rowid='id',
+            tokenize='porter unicode61'
+        );`); err != nil {
+		return fmt.Errorf("creating products_fts: %w", err)
+	}
+
+	for name, stmt := range searchTriggers {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("creating %s: %w", name, err)
+		}
+	}
+
+	// Rebuilding is cheap for a small catalog and repairs an index that
+	// missed writes while the app ran without FTS5.
+	if _, err := db.Exec(`INSERT INTO products_fts(products_fts) VALUES ('rebuild')`); err != nil {
+		return fmt.Errorf("rebuilding products_fts: %w", err)
+	}
+
+	log.Printf("[DB] Product search index ready (FTS5)")
+
+	return nil
+}
@@ -7,7 +7,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func SeedAdmin(repo *repository.UserRepo, email, password string) {
+func SeedAdmin(repo repository.UserStore, email, password string) {
 	if _, err := repo.FindByEmail(email); err == nil {
 		return // admin already exists
 	}
@@ -11,7 +11,7 @@ import (
 // New opens (or creates) the SQLite database and applies pragmas
 // for better concurrent-read performance and data safety.
 func New(dbPath string) (*sql.DB, error) {
-	log.Printf("data/" + dbPath + "?_journal_mode=WAL&_foreign_keys=ON")
+	log.Printf("[DB] Opening data/%s", dbPath)
 	db, err := sql.Open("sqlite3", "data/"+dbPath+"?_journal_mode=WAL&_foreign_keys=ON")
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
+)
+
+type AddressHandler struct {
+	svc *services.AddressService
+}
+
+func NewAddressHandler(svc *services.AddressService) *AddressHandler {
+	return &AddressHandler{svc: svc}
+}
+
+// GET /addresses
+// @Summary      List addresses
+// @Description  List the user's saved shipping addresses, the default first
+// @Tags         Addresses
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Address
+// @Failure      401  {object}  map[string]string
+// @Router       /addresses [get]
+func (h *AddressHandler) List(w http.ResponseWriter, r *http.Request) {
+	addresses, err := h.svc.List(getUserId(r))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, addresses)
+}
+
+// POST /addresses
+// @Summary      Add address
+// @Description  Save a shipping address. The first address, or one with is_default, becomes the default used at checkout
+// @Tags         Addresses
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      models.AddressRequest  true  "Address"
+// @Success      201      {object}  models.Address
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Router       /addresses [post]
+func (h *AddressHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req models.AddressRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	address, err := h.svc.Create(getUserId(r), req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, address)
+}
+
+// PUT /addresses/{id}
+// @Summary      Update address
+// @Description  Replace a saved address; is_default makes it the default. Orders keep the address they were placed with
+// @Tags         Addresses
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int64                  true  "Address ID"
+// @Param        payload  body      models.AddressRequest  true  "Address"
+// @Success      200      {object}  models.Address
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Router       /addresses/{id} [put]
+func (h *AddressHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	var req models.AddressRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	address, err := h.svc.Update(getUserId(r), id, req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, address)
+}
+
+// DELETE /addresses/{id}
+// @Summary      Delete address
+// @Description  Delete a saved address. If it was the default, the oldest remaining address becomes the default
+// @Tags         Addresses
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int64  true  "Address ID"
+// @Success      204  "No Content"
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /addresses/{id} [delete]
+func (h *AddressHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	if err := h.svc.Delete(getUserId(r), id); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
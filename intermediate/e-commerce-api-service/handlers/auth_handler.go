@@ -19,7 +19,7 @@ func NewAuthHandler(svc *services.AuthService) *AuthHandler {
 
 // Register handles user registration requests.
 // @Summary      Register a new user
-// @Description  Create a user account and return a JWT
+// @Description  Create a user account and return an access token and refresh token
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
@@ -43,7 +43,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary      Login
-// @Description  Authenticate a user and return a JWT
+// @Description  Authenticate a user and return an access token and refresh token
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
@@ -66,3 +66,101 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
+
+// POST /auth/refresh
+// @Summary      Refresh tokens
+// @Description  Exchange a refresh token for a new access token and refresh token. The old refresh token stops working; presenting it again signs out that session
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      models.RefreshRequest  true  "Refresh token"
+// @Success      200      {object}  models.AuthResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Router       /auth/refresh [post]
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+
+	resp, err := h.svc.Refresh(req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /auth/logout
+// @Summary      Logout
+// @Description  Revoke the session of a refresh token, or every session of the user with "all". Access tokens stay valid until they expire
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body  models.LogoutRequest  true  "Refresh token"
+// @Success      204      "No Content"
+// @Failure      400      {object}  map[string]string
+// @Router       /auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.LogoutRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+
+	if err := h.svc.Logout(req); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /auth/password/forgot
+// @Summary      Forgot password
+// @Description  Email a one-time password reset link. Answers 202 whether or not the account exists
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body  models.ForgotPasswordRequest  true  "Account email"
+// @Success      202      "Accepted"
+// @Failure      400      {object}  map[string]string
+// @Router       /auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+
+	if err := h.svc.ForgotPassword(req); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// POST /auth/password/reset
+// @Summary      Reset password
+// @Description  Set a new password with the token from a reset email. Signs the user out of every session
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body  models.ResetPasswordRequest  true  "Reset token and new password"
+// @Success      204      "No Content"
+// @Failure      400      {object}  map[string]string
+// @Router       /auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+
+	if err := h.svc.ResetPassword(req); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
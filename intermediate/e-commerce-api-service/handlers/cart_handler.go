@@ -17,16 +17,19 @@ func NewCartHandler(svc *services.CartService) *CartHandler {
 
 // GET /cart
 // @Summary      Get cart
-// @Description  Retrieve the authenticated user's cart
+// @Description  Retrieve the authenticated user's cart with a price preview.
+// @Description  Coupons or regions that do not apply are listed in warnings.
 // @Tags         Cart
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Param        coupon  query     string  false  "Coupon code to preview"
+// @Param        region  query     string  false  "Tax region, e.g. US-CA"
 // @Success      200  {object}  models.CartResponse
 // @Failure      401  {object}  map[string]string
 // @Router       /cart [get]
 func (h *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
-	cart, err := h.svc.GetCart(getUserId(r))
+	cart, err := h.svc.GetCart(getUserId(r), pricingOptions(r))
 	if err != nil {
 		handleError(w, err)
 		return
@@ -67,8 +70,9 @@ func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        productId  path      int64                        true  "Product ID"
-// @Param        payload    body      models.UpdateCartItemRequest true  "Quantity (0 deletes)"
+// @Param        productId   path      int64                        true   "Product ID"
+// @Param        variant_id  query     int64                        false  "Variant ID, for products with variants"
+// @Param        payload     body      models.UpdateCartItemRequest true   "Quantity (0 deletes)"
 // @Success      200        {object}  map[string]string
 // @Failure      400        {object}  map[string]string
 // @Failure      401        {object}  map[string]string
@@ -85,7 +89,7 @@ func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 		handleError(w, models.ErrBadRequest)
 		return
 	}
-	if err := h.svc.UpdateItem(getUserId(r), productId, req); err != nil {
+	if err := h.svc.UpdateItem(getUserId(r), productId, queryInt64(r, "variant_id"), req); err != nil {
 		handleError(w, err)
 		return
 	}
@@ -99,7 +103,8 @@ func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        productId  path  int64  true  "Product ID"
+// @Param        productId   path   int64  true   "Product ID"
+// @Param        variant_id  query  int64  false  "Variant ID, for products with variants"
 // @Success      204        "No Content"
 // @Failure      401        {object}  map[string]string
 // @Failure      404        {object}  map[string]string
@@ -110,7 +115,7 @@ func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
 		handleError(w, models.ErrBadRequest)
 		return
 	}
-	if err := h.svc.RemoveItem(getUserId(r), productId); err != nil {
+	if err := h.svc.RemoveItem(getUserId(r), productId, queryInt64(r, "variant_id")); err != nil {
 		handleError(w, err)
 		return
 	}
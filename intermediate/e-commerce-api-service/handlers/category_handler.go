@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
+)
+
+type CategoryHandler struct {
+	svc *services.CategoryService
+}
+
+func NewCategoryHandler(svc *services.CategoryService) *CategoryHandler {
+	return &CategoryHandler{svc: svc}
+}
+
+// GET /categories
+// @Summary      List categories
+// @Description  The category tree: root categories with their subcategories in children
+// @Tags         Categories
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.Category
+// @Router       /categories [get]
+func (h *CategoryHandler) List(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.svc.Tree()
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if tree == nil {
+		tree = []models.Category{}
+	}
+	writeJSON(w, http.StatusOK, tree)
+}
+
+// POST /admin/categories
+// @Summary      Create category
+// @Description  Create a category, optionally below a parent (admin only)
+// @Tags         Categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      models.CategoryRequest  true  "Category"
+// @Success      201      {object}  models.Category
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Router       /admin/categories [post]
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req models.CategoryRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	category, err := h.svc.Create(req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, category)
+}
+
+// PUT /admin/categories/{id}
+// @Summary      Update category
+// @Description  Rename a category or move it to another parent (admin only)
+// @Tags         Categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int64                   true  "Category ID"
+// @Param        payload  body      models.CategoryRequest  true  "Category"
+// @Success      200      {object}  models.Category
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Router       /admin/categories/{id} [put]
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	var req models.CategoryRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	category, err := h.svc.Update(id, req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, category)
+}
+
+// DELETE /admin/categories/{id}
+// @Summary      Delete category
+// @Description  Delete a category without subcategories; its products become uncategorised (admin only)
+// @Tags         Categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int64  true  "Category ID"
+// @Success      204  "No Content"
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/categories/{id} [delete]
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	if err := h.svc.Delete(id); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
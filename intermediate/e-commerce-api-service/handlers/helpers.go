@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/middleware"
@@ -57,6 +59,8 @@ func handleError(w http.ResponseWriter, err error) {
 		status = http.StatusConflict
 	case errors.Is(err, models.ErrEmptyCart):
 		status = http.StatusBadRequest
+	case errors.Is(err, models.ErrInvalidTransition):
+		status = http.StatusConflict
 	}
 	writeJSON(w, status, map[string]string{"error": err.Error()})
 }
@@ -68,7 +72,40 @@ func urlParamInt64(r *http.Request, key string) (int64, error) {
 	return strconv.ParseInt(s, 10, 64)
 }
 
+// queryInt64 parses a query parameter as an int64, returning 0 when it is absent or invalid.
+func queryInt64(r *http.Request, key string) int64 {
+	n, _ := strconv.ParseInt(r.URL.Query().Get(key), 10, 64)
+	return n
+}
+
+// queryDate parses a YYYY-MM-DD query parameter, returning the zero time when it is absent.
+func queryDate(r *http.Request, key string) (time.Time, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be a date like 2006-01-02", models.ErrBadRequest, key)
+	}
+	return t, nil
+}
+
+// pricingOptions reads the coupon and tax region from the query string.
+func pricingOptions(r *http.Request) models.PricingOptions {
+	return models.PricingOptions{
+		CouponCode: r.URL.Query().Get("coupon"),
+		Region:     r.URL.Query().Get("region"),
+	}
+}
+
 // getUserId retrieves the user ID from the request context.
 func getUserId(r *http.Request) int64 {
 	return r.Context().Value(middleware.UserIdKey).(int64)
 }
+
+// isAdmin reports whether the request was made by an admin.
+func isAdmin(r *http.Request) bool {
+	role, _ := r.Context().Value(middleware.RoleKey).(string)
+	return role == "admin"
+}
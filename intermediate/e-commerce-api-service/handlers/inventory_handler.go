@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
+)
+
+type InventoryHandler struct {
+	svc *services.InventoryService
+}
+
+func NewInventoryHandler(svc *services.InventoryService) *InventoryHandler {
+	return &InventoryHandler{svc: svc}
+}
+
+// GET /admin/inventory/low-stock
+// @Summary      List low stock
+// @Description  List products and variants whose stock is at or below the threshold, with units held by carts (admin only)
+// @Tags         Inventory
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        threshold  query     int  false  "Threshold (defaults to LOW_STOCK_THRESHOLD)"
+// @Success      200        {array}   models.StockLevel
+// @Failure      401        {object}  map[string]string
+// @Failure      403        {object}  map[string]string
+// @Router       /admin/inventory/low-stock [get]
+func (h *InventoryHandler) LowStock(w http.ResponseWriter, r *http.Request) {
+	threshold := -1
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			handleError(w, models.ErrBadRequest)
+			return
+		}
+		threshold = n
+	}
+
+	levels, err := h.svc.LowStock(threshold)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, levels)
+}
+
+// GET /admin/inventory/movements
+// @Summary      List inventory movements
+// @Description  List the stock ledger, newest first (admin only)
+// @Tags         Inventory
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        product_id  query     int     false  "Product ID"
+// @Param        variant_id  query     int     false  "Variant ID"
+// @Param        type        query     string  false  "sale, restock, adjustment or return"
+// @Param        limit       query     int     false  "Max entries (default 50, max 500)"
+// @Success      200         {array}   models.InventoryMovement
+// @Failure      400         {object}  map[string]string
+// @Failure      401         {object}  map[string]string
+// @Failure      403         {object}  map[string]string
+// @Router       /admin/inventory/movements [get]
+func (h *InventoryHandler) ListMovements(w http.ResponseWriter, r *http.Request) {
+	movements, err := h.svc.ListMovements(models.MovementQuery{
+		ProductId: queryInt64(r, "product_id"),
+		VariantId: queryInt64(r, "variant_id"),
+		Type:      models.MovementType(r.URL.Query().Get("type")),
+		Limit:     int(queryInt64(r, "limit")),
+	})
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, movements)
+}
+
+// POST /admin/inventory/movements
+// @Summary      Record inventory movement
+// @Description  Restock, return or adjust the stock of a product or variant and record it in the ledger (admin only)
+// @Tags         Inventory
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      models.InventoryMovementRequest  true  "Movement"
+// @Success      201      {object}  models.InventoryMovement
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Router       /admin/inventory/movements [post]
+func (h *InventoryHandler) RecordMovement(w http.ResponseWriter, r *http.Request) {
+	var req models.InventoryMovementRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	movement, err := h.svc.RecordMovement(getUserId(r), req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, movement)
+}
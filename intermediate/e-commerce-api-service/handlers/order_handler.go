@@ -1,8 +1,17 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
 )
 
@@ -16,18 +25,30 @@ func NewOrderHandler(svc *services.OrderService) *OrderHandler {
 
 // POST /checkout
 // @Summary      Checkout
-// @Description  Create an order from the user's cart and a Stripe PaymentIntent
+// @Description  Create an order from the user's cart and a payment with the configured provider
 // @Tags         Orders
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Param        Idempotency-Key  header    string                  false  "Reuse the same key when retrying to avoid duplicate orders"
+// @Param        payload          body      models.CheckoutRequest  false  "Coupon code, tax region and shipping address (defaults to the saved default address)"
 // @Success      201  {object}  models.CheckoutResponse
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
 // @Failure      409  {object}  map[string]string
 // @Router       /checkout [post]
 func (h *OrderHandler) Checkout(w http.ResponseWriter, r *http.Request) {
-	resp, err := h.svc.Checkout(getUserId(r))
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > 255 {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	var req models.CheckoutRequest
+	if err := readJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	resp, err := h.svc.Checkout(getUserId(r), key, req)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -80,3 +101,304 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, order)
 }
+
+// POST /orders/{id}/cancel
+// @Summary      Cancel order
+// @Description  Cancel a pending order owned by the user and restore its stock
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int64  true  "Order ID"
+// @Success      200  {object}  models.Order
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /orders/{id}/cancel [post]
+func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	orderID, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	userId := getUserId(r)
+	if err := h.svc.CancelOrder(userId, orderID); err != nil {
+		handleError(w, err)
+		return
+	}
+	order, err := h.svc.GetOrder(userId, orderID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+// orderQuery reads the admin order filters from the query string.
+func orderQuery(r *http.Request) (models.OrderQuery, error) {
+	q := r.URL.Query()
+	from, err := queryDate(r, "from")
+	if err != nil {
+		return models.OrderQuery{}, err
+	}
+	to, err := queryDate(r, "to")
+	if err != nil {
+		return models.OrderQuery{}, err
+	}
+	return models.OrderQuery{
+		Status: models.OrderStatus(q.Get("status")),
+		UserId: queryInt64(r, "user_id"),
+		Email:  strings.TrimSpace(q.Get("email")),
+		From:   from,
+		To:     to,
+		Page:   int(queryInt64(r, "page")),
+		Limit:  int(queryInt64(r, "limit")),
+	}, nil
+}
+
+// GET /admin/orders
+// @Summary      List all orders
+// @Description  List orders across all customers, newest first, with filters and pagination (admin only)
+// @Tags         Admin Orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status   query     string  false  "pending, paid, shipped, delivered, cancelled or refunded"
+// @Param        user_id  query     int64   false  "Customer ID"
+// @Param        email    query     string  false  "Part of the customer's email"
+// @Param        from     query     string  false  "First day (YYYY-MM-DD, UTC)"
+// @Param        to       query     string  false  "Last day, inclusive (YYYY-MM-DD, UTC)"
+// @Param        page     query     int     false  "page"
+// @Param        limit    query     int     false  "limit (default 20, max 100)"
+// @Success      200      {object}  models.OrderListResult
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Router       /admin/orders [get]
+func (h *OrderHandler) AdminList(w http.ResponseWriter, r *http.Request) {
+	q, err := orderQuery(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	result, err := h.svc.ListAllOrders(q)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// GET /admin/orders/export.csv
+// @Summary      Export orders as CSV
+// @Description  Download every order matching the filters as CSV; amounts are in dollars.
+// @Description  Text cells starting with =, +, -, @, tab or CR are prefixed with ' so spreadsheets do not run them (admin only)
+// @Tags         Admin Orders
+// @Produce      text/csv
+// @Security     BearerAuth
+// @Param        status   query     string  false  "Order status"
+// @Param        user_id  query     int64   false  "Customer ID"
+// @Param        email    query     string  false  "Part of the customer's email"
+// @Param        from     query     string  false  "First day (YYYY-MM-DD, UTC)"
+// @Param        to       query     string  false  "Last day, inclusive (YYYY-MM-DD, UTC)"
+// @Success      200      {string}  string  "CSV file"
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Router       /admin/orders/export.csv [get]
+func (h *OrderHandler) AdminExport(w http.ResponseWriter, r *http.Request) {
+	q, err := orderQuery(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	orders, err := h.svc.ExportOrders(q)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="orders-%s.csv"`, time.Now().UTC().Format("20060102")))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"id", "created_at", "user_id", "email", "status", "payment_id",
+		"subtotal", "discount", "coupon_code", "coupon_discount", "shipping", "tax_region", "tax", "total",
+		"ship_name", "ship_city", "ship_postal_code", "ship_country",
+	})
+	for _, o := range orders {
+		var addr models.ShippingAddress
+		if o.ShippingAddress != nil {
+			addr = *o.ShippingAddress
+		}
+		cw.Write([]string{
+			strconv.FormatInt(o.ID, 10), o.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatInt(o.UserId, 10), csvText(o.UserEmail), string(o.Status), o.StripePaymentId,
+			dollars(o.Subtotal), dollars(o.Discount), csvText(o.CouponCode), dollars(o.CouponDiscount),
+			dollars(o.Shipping), csvText(o.TaxRegion), dollars(o.Tax), dollars(o.Total),
+			csvText(addr.Name), csvText(addr.City), csvText(addr.PostalCode), csvText(addr.Country),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("[ORDER] writing CSV export: %v", err)
+	}
+}
+
+// csvText neutralizes a customer-supplied cell that a spreadsheet would run
+// as a formula, such as =HYPERLINK(...), by prefixing it with a quote.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// dollars formats an amount in cents as a decimal string, e.g. 1999 as "19.99".
+func dollars(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// GET /admin/orders/{id}
+// @Summary      Get any order
+// @Description  Retrieve an order of any customer with its items (admin only)
+// @Tags         Admin Orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int64  true  "Order ID"
+// @Success      200  {object}  models.Order
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/orders/{id} [get]
+func (h *OrderHandler) AdminGet(w http.ResponseWriter, r *http.Request) {
+	orderID, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	order, err := h.svc.FindOrder(orderID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+// PUT /admin/orders/{id}/status
+// @Summary      Change order status
+// @Description  Move an order to another status, e.g. shipped or delivered, following the order state machine.
+// @Description  Cancelling a pending order restores its stock. Use the refund endpoint to refund (admin only).
+// @Tags         Admin Orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int64                      true  "Order ID"
+// @Param        payload  body      models.OrderStatusRequest  true  "New status"
+// @Success      200      {object}  models.Order
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Router       /admin/orders/{id}/status [put]
+func (h *OrderHandler) AdminSetStatus(w http.ResponseWriter, r *http.Request) {
+	orderID, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	var req models.OrderStatusRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	if err := h.svc.SetStatus(orderID, req.Status); err != nil {
+		handleError(w, err)
+		return
+	}
+	order, err := h.svc.FindOrder(orderID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+// POST /admin/orders/{id}/refund
+// @Summary      Refund order
+// @Description  Refund the full payment of a paid, shipped or delivered order through the payment provider
+// @Description  and mark it refunded. Stock is not restocked automatically (admin only).
+// @Tags         Admin Orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int64  true  "Order ID"
+// @Success      200  {object}  models.Order
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/orders/{id}/refund [post]
+func (h *OrderHandler) AdminRefund(w http.ResponseWriter, r *http.Request) {
+	orderID, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	if err := h.svc.RefundOrder(orderID); err != nil {
+		handleError(w, err)
+		return
+	}
+	order, err := h.svc.FindOrder(orderID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+// GET /admin/reports/sales
+// @Summary      Sales report
+// @Description  Revenue per day, order count, average order value and top products over a date range.
+// @Description  Paid, shipped and delivered orders count as sales. Amounts are in cents (admin only).
+// @Tags         Admin Orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        from  query     string  false  "First day (YYYY-MM-DD, UTC; default 30 days before to)"
+// @Param        to    query     string  false  "Last day, inclusive (YYYY-MM-DD, UTC; default today)"
+// @Param        top   query     int     false  "Number of top products (default 5, max 50)"
+// @Success      200   {object}  models.SalesReport
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Router       /admin/reports/sales [get]
+func (h *OrderHandler) SalesReport(w http.ResponseWriter, r *http.Request) {
+	from, err := queryDate(r, "from")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	to, err := queryDate(r, "to")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	report, err := h.svc.SalesReport(from, to, int(queryInt64(r, "top")))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
+)
+
+// newAdminOrderHandler seeds five orders of two customers over four days:
+//
+//	id  user  status     day         total  items
+//	1   1     paid       2026-03-01  2000   2 mugs
+//	2   2     delivered  2026-03-02  2500   1 tee
+//	3   1     pending    2026-03-02  1000   1 mug
+//	4   2     cancelled  2026-03-03  5000   2 tees
+//	5   1     shipped    2026-03-04  3000   3 mugs
+//
+// Order 1 carries spreadsheet formulas in its customer-supplied text.
+func newAdminOrderHandler(t *testing.T) *OrderHandler {
+	t.Helper()
+	db := newTestDB(t)
+	mustExec(t, db,
+		`INSERT INTO users (id, email, password) VALUES (1, 'alice@example.com', 'hash'), (2, '=bob@example.com', 'hash')`,
+		`INSERT INTO products (id, name, price, stock) VALUES (1, 'Mug', 1000, 10), (2, 'Tee', 2500, 10)`,
+		`INSERT INTO orders (id, user_id, total, status, created_at, coupon_code, ship_name, ship_line1, ship_city) VALUES
+			(1, 1, 2000, 'paid', '2026-03-01 10:00:00', '-5', '=HYPERLINK("http://evil.example")', '1 Main St', '+Town')`,
+		`INSERT INTO orders (id, user_id, total, status, created_at) VALUES
+			(2, 2, 2500, 'delivered', '2026-03-02 09:00:00'),
+			(3, 1, 1000, 'pending',   '2026-03-02 18:00:00'),
+			(4, 2, 5000, 'cancelled', '2026-03-03 12:00:00'),
+			(5, 1, 3000, 'shipped',   '2026-03-04 08:00:00')`,
+		`INSERT INTO order_items (order_id, product_id, quantity, price) VALUES
+			(1, 1, 2, 1000), (2, 2, 1, 2500), (3, 1, 1, 1000), (4, 2, 2, 2500), (5, 1, 3, 1000)`,
+	)
+	return NewOrderHandler(newTestOrderService(db, services.NewFakeProvider(""), services.LogAlerter{}))
+}
+
+func TestAdminListFilters(t *testing.T) {
+	h := newAdminOrderHandler(t)
+
+	tests := []struct {
+		query string
+		total int
+		ids   []int64
+	}{
+		{"", 5, []int64{5, 4, 3, 2, 1}},
+		{"status=paid", 1, []int64{1}},
+		{"user_id=2", 2, []int64{4, 2}},
+		{"email=alice", 3, []int64{5, 3, 1}},
+		{"from=2026-03-02&to=2026-03-03", 3, []int64{4, 3, 2}},
+		{"status=pending&email=alice&from=2026-03-02", 1, []int64{3}},
+		{"page=2&limit=2", 5, []int64{3, 2}},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.AdminList(rr, httptest.NewRequest(http.MethodGet, "/admin/orders?"+tt.query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", tt.query, rr.Code, rr.Body.String())
+		}
+		var result models.OrderListResult
+		if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, o := range result.Items {
+			ids = append(ids, o.ID)
+		}
+		if result.Total != tt.total || !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%q: got total %d, ids %v; want %d, %v", tt.query, result.Total, ids, tt.total, tt.ids)
+		}
+	}
+
+	for _, query := range []string{"status=lost", "from=03/01/2026", "from=2026-03-04&to=2026-03-01"} {
+		rr := httptest.NewRecorder()
+		h.AdminList(rr, httptest.NewRequest(http.MethodGet, "/admin/orders?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestAdminExport(t *testing.T) {
+	h := newAdminOrderHandler(t)
+
+	rr := httptest.NewRecorder()
+	h.AdminExport(rr, httptest.NewRequest(http.MethodGet, "/admin/orders/export.csv?status=paid", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("unexpected content type %q", got)
+	}
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected a header and one order, got %d rows", len(records))
+	}
+	row := map[string]string{}
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+
+	want := map[string]string{
+		"id":          "1",
+		"email":       "alice@example.com",
+		"status":      "paid",
+		"total":       "20.00",
+		"coupon_code": "'-5",
+		"ship_name":   `'=HYPERLINK("http://evil.example")`,
+		"ship_city":   "'+Town",
+	}
+	for column, value := range want {
+		if row[column] != value {
+			t.Errorf("%s: got %q, want %q", column, row[column], value)
+		}
+	}
+
+	// The other customer's email starts with =
+	rr = httptest.NewRecorder()
+	h.AdminExport(rr, httptest.NewRequest(http.MethodGet, "/admin/orders/export.csv?user_id=2", nil))
+	records, err = csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][3] != "'=bob@example.com" {
+		t.Fatalf("expected two orders with a neutralized email, got %v", records)
+	}
+}
+
+func TestSalesReportTotals(t *testing.T) {
+	h := newAdminOrderHandler(t)
+
+	rr := httptest.NewRecorder()
+	h.SalesReport(rr, httptest.NewRequest(http.MethodGet, "/admin/reports/sales?from=2026-03-01&to=2026-03-04", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report models.SalesReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pending and cancelled orders are not sales
+	if report.Orders != 3 || report.Revenue != 7500 || report.AverageOrderValue != 2500 {
+		t.Errorf("got %d orders, revenue %d, average %d; want 3, 7500, 2500",
+			report.Orders, report.Revenue, report.AverageOrderValue)
+	}
+	wantDaily := []models.DailySales{
+		{Date: "2026-03-01", Orders: 1, Revenue: 2000},
+		{Date: "2026-03-02", Orders: 1, Revenue: 2500},
+		{Date: "2026-03-03", Orders: 0, Revenue: 0},
+		{Date: "2026-03-04", Orders: 1, Revenue: 3000},
+	}
+	if !reflect.DeepEqual(report.Daily, wantDaily) {
+		t.Errorf("daily: got %+v, want %+v", report.Daily, wantDaily)
+	}
+	wantTop := []models.ProductSales{
+		{ProductId: 1, Name: "Mug", Quantity: 5, Revenue: 5000},
+		{ProductId: 2, Name: "Tee", Quantity: 1, Revenue: 2500},
+	}
+	if !reflect.DeepEqual(report.TopProducts, wantTop) {
+		t.Errorf("top products: got %+v, want %+v", report.TopProducts, wantTop)
+	}
+
+	// The range is inclusive and top limits the products
+	rr = httptest.NewRecorder()
+	h.SalesReport(rr, httptest.NewRequest(http.MethodGet, "/admin/reports/sales?from=2026-03-02&to=2026-03-03&top=1", nil))
+	report = models.SalesReport{}
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Orders != 1 || report.Revenue != 2500 || len(report.TopProducts) != 1 || report.TopProducts[0].Name != "Tee" {
+		t.Errorf("got %d orders, revenue %d, top %+v; want 1, 2500, [Tee]", report.Orders, report.Revenue, report.TopProducts)
+	}
+
+	rr = httptest.NewRecorder()
+	h.SalesReport(rr, httptest.NewRequest(http.MethodGet, "/admin/reports/sales?from=2026-03-04&to=2026-03-01", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("reversed range expected 400, got %d", rr.Code)
+	}
+}
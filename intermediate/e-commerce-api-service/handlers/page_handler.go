@@ -140,7 +140,7 @@ func (h *PageHandler) pageData(r *http.Request) PageData {
 		pd.User = &models.User{ID: uid, Role: role}
 
 		// Load cart count for the nav badge
-		if cart, err := h.cartSvc.GetCart(uid); err == nil && cart != nil {
+		if cart, err := h.cartSvc.GetCart(uid, models.PricingOptions{}); err == nil && cart != nil {
 			pd.CartCount = len(cart.Items)
 		}
 	}
@@ -232,6 +232,7 @@ func (h *PageHandler) CartAdd(w http.ResponseWriter, r *http.Request) {
 	}
 	r.ParseForm()
 	productId, _ := strconv.ParseInt(r.FormValue("product_id"), 10, 64)
+	variantId, _ := strconv.ParseInt(r.FormValue("variant_id"), 10, 64)
 	qty, _ := strconv.Atoi(r.FormValue("quantity"))
 	if qty <= 0 {
 		qty = 1
@@ -239,6 +240,7 @@ func (h *PageHandler) CartAdd(w http.ResponseWriter, r *http.Request) {
 
 	err := h.cartSvc.AddItem(pd.User.ID, models.AddToCartRequest{
 		ProductId: productId,
+		VariantId: variantId,
 		Quantity:  qty,
 	})
 	if err != nil {
@@ -257,9 +259,10 @@ func (h *PageHandler) CartUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 	r.ParseForm()
 	productID, _ := strconv.ParseInt(r.FormValue("product_id"), 10, 64)
+	variantID, _ := strconv.ParseInt(r.FormValue("variant_id"), 10, 64)
 	qty, _ := strconv.Atoi(r.FormValue("quantity"))
 
-	h.cartSvc.UpdateItem(pd.User.ID, productID, models.UpdateCartItemRequest{Quantity: qty})
+	h.cartSvc.UpdateItem(pd.User.ID, productID, variantID, models.UpdateCartItemRequest{Quantity: qty})
 	http.Redirect(w, r, "/cart", http.StatusSeeOther)
 }
 
@@ -272,7 +275,8 @@ func (h *PageHandler) CartRemove(w http.ResponseWriter, r *http.Request) {
 	}
 	r.ParseForm()
 	productId, _ := strconv.ParseInt(r.FormValue("product_id"), 10, 64)
-	h.cartSvc.RemoveItem(pd.User.ID, productId)
+	variantId, _ := strconv.ParseInt(r.FormValue("variant_id"), 10, 64)
+	h.cartSvc.RemoveItem(pd.User.ID, productId, variantId)
 	http.Redirect(w, r, "/cart?flash=Item+removed", http.StatusSeeOther)
 }
 
@@ -283,7 +287,15 @@ func (h *PageHandler) Checkout(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/auth", http.StatusSeeOther)
 		return
 	}
-	resp, err := h.orderSvc.Checkout(pd.User.ID)
+	r.ParseForm()
+	addressId, _ := strconv.ParseInt(r.FormValue("address_id"), 10, 64)
+	resp, err := h.orderSvc.Checkout(pd.User.ID, "", models.CheckoutRequest{
+		PricingOptions: models.PricingOptions{
+			CouponCode: r.FormValue("coupon_code"),
+			Region:     r.FormValue("region"),
+		},
+		AddressId: addressId,
+	})
 	if err != nil {
 		http.Redirect(w, r, "/cart?flash="+err.Error()+"&flash_type=error", http.StatusSeeOther)
 		return
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
+)
+
+type PricingHandler struct {
+	svc *services.PricingService
+}
+
+func NewPricingHandler(svc *services.PricingService) *PricingHandler {
+	return &PricingHandler{svc: svc}
+}
+
+// ── Coupons
+
+// GET /admin/coupons
+// @Summary      List coupons
+// @Description  List all coupons with their usage (admin only)
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Coupon
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /admin/coupons [get]
+func (h *PricingHandler) ListCoupons(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.svc.ListCoupons()
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, coupons)
+}
+
+// POST /admin/coupons
+// @Summary      Create coupon
+// @Description  Create a percentage or fixed-amount coupon (admin only)
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      models.CouponRequest  true  "Coupon"
+// @Success      201      {object}  models.Coupon
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Router       /admin/coupons [post]
+func (h *PricingHandler) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	var req models.CouponRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	coupon, err := h.svc.CreateCoupon(req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, coupon)
+}
+
+// PUT /admin/coupons/{id}
+// @Summary      Update coupon
+// @Description  Replace the settings of a coupon; its usage count is kept (admin only)
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int64                 true  "Coupon ID"
+// @Param        payload  body      models.CouponRequest  true  "Coupon"
+// @Success      200      {object}  models.Coupon
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Router       /admin/coupons/{id} [put]
+func (h *PricingHandler) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	var req models.CouponRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	coupon, err := h.svc.UpdateCoupon(id, req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, coupon)
+}
+
+// DELETE /admin/coupons/{id}
+// @Summary      Delete coupon
+// @Description  Delete a coupon (admin only)
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int64  true  "Coupon ID"
+// @Success      204  "No Content"
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/coupons/{id} [delete]
+func (h *PricingHandler) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	if err := h.svc.DeleteCoupon(id); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ── Discounts
+
+// GET /admin/discounts
+// @Summary      List discounts
+// @Description  List all automatic product discounts (admin only)
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Discount
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /admin/discounts [get]
+func (h *PricingHandler) ListDiscounts(w http.ResponseWriter, r *http.Request) {
+	discounts, err := h.svc.ListDiscounts()
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, discounts)
+}
+
+// POST /admin/discounts
+// @Summary      Create discount
+// @Description  Create an automatic discount for a product, optionally limited to a date range (admin only)
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      models.DiscountRequest  true  "Discount"
+// @Success      201      {object}  models.Discount
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Router       /admin/discounts [post]
+func (h *PricingHandler) CreateDiscount(w http.ResponseWriter, r *http.Request) {
+	var req models.DiscountRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	discount, err := h.svc.CreateDiscount(req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, discount)
+}
+
+// DELETE /admin/discounts/{id}
+// @Summary      Delete discount
+// @Description  Delete a product discount (admin only)
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int64  true  "Discount ID"
+// @Success      204  "No Content"
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/discounts/{id} [delete]
+func (h *PricingHandler) DeleteDiscount(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	if err := h.svc.DeleteDiscount(id); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ── Tax rates
+
+// GET /tax-rates
+// @Summary      List tax rates
+// @Description  List the tax regions customers can choose at checkout
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.TaxRate
+// @Router       /tax-rates [get]
+func (h *PricingHandler) ListTaxRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.svc.ListTaxRates()
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rates)
+}
+
+// PUT /admin/tax-rates/{region}
+// @Summary      Set tax rate
+// @Description  Create or replace the tax rate of a region (admin only)
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        region   path      string                 true  "Region code, e.g. US-CA"
+// @Param        payload  body      models.TaxRateRequest  true  "Tax rate"
+// @Success      200      {object}  models.TaxRate
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Router       /admin/tax-rates/{region} [put]
+func (h *PricingHandler) SetTaxRate(w http.ResponseWriter, r *http.Request) {
+	var req models.TaxRateRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	rate, err := h.svc.SetTaxRate(chi.URLParam(r, "region"), req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rate)
+}
+
+// DELETE /admin/tax-rates/{region}
+// @Summary      Delete tax rate
+// @Description  Remove a tax region (admin only)
+// @Tags         Pricing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        region  path  string  true  "Region code"
+// @Success      204     "No Content"
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Router       /admin/tax-rates/{region} [delete]
+func (h *PricingHandler) DeleteTaxRate(w http.ResponseWriter, r *http.Request) {
+	if err := h.svc.DeleteTaxRate(chi.URLParam(r, "region")); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
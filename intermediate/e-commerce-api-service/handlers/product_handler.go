@@ -44,17 +44,21 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 // GET /products
-// @Summary      List products
-// @Description  List products with optional search and pagination
+// @Summary      Search products
+// @Description  Full-text search with ranking, category and price filters, sorting and pagination.
+// @Description  Facets count matches per category (including subcategories) and price range.
 // @Tags         Products
 // @Accept       json
 // @Produce      json
-// @Param        q          query     string  false  "search"
+// @Param        q          query     string  false  "search terms (prefix match on name and description)"
+// @Param        category   query     string  false  "category ID or slug; includes subcategories"
 // @Param        min_price  query     int64   false  "min price (cents)"
 // @Param        max_price  query     int64   false  "max price (cents)"
+// @Param        sort       query     string  false  "relevance (default with q), newest (default), price_asc, price_desc, name"
 // @Param        page       query     int     false  "page"
-// @Param        limit      query     int     false  "limit"
-// @Success      200        {array}   models.Product
+// @Param        limit      query     int     false  "limit (max 100)"
+// @Success      200        {object}  models.ProductSearchResult
+// @Failure      400        {object}  map[string]string
 // @Router       /products [get]
 func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
@@ -63,23 +67,34 @@ func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 	minPrice, _ := strconv.ParseInt(q.Get("min_price"), 10, 64)
 	maxPrice, _ := strconv.ParseInt(q.Get("max_price"), 10, 64)
 
-	products, err := h.svc.List(models.ProductQuery{
+	query := models.ProductQuery{
 		Name:     q.Get("q"),
 		MinPrice: minPrice,
 		MaxPrice: maxPrice,
+		Sort:     q.Get("sort"),
 		Page:     page,
 		Limit:    limit,
-	})
+	}
+	if c := q.Get("category"); c != "" {
+		category, err := h.svc.ResolveCategory(c)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		query.CategoryId = category.ID
+	}
+
+	result, err := h.svc.Search(query)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, products)
+	writeJSON(w, http.StatusOK, result)
 }
 
 // GET /products/{id}
 // @Summary      Get product
-// @Description  Get a product by ID
+// @Description  Get a product by ID with its variants and image gallery
 // @Tags         Products
 // @Accept       json
 // @Produce      json
@@ -160,3 +175,177 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// POST /admin/products/{id}/variants
+// @Summary      Create variant
+// @Description  Add a SKU (e.g. size/color) with its own price and stock to a product (admin only)
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int64                  true  "Product ID"
+// @Param        payload  body      models.VariantRequest  true  "Variant"
+// @Success      201      {object}  models.ProductVariant
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Router       /admin/products/{id}/variants [post]
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	var req models.VariantRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	variant, err := h.svc.CreateVariant(id, req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, variant)
+}
+
+// PUT /admin/products/{id}/variants/{variantId}
+// @Summary      Update variant
+// @Description  Replace the SKU, options, price and stock of a variant (admin only)
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      int64                  true  "Product ID"
+// @Param        variantId  path      int64                  true  "Variant ID"
+// @Param        payload    body      models.VariantRequest  true  "Variant"
+// @Success      200        {object}  models.ProductVariant
+// @Failure      400        {object}  map[string]string
+// @Failure      401        {object}  map[string]string
+// @Failure      403        {object}  map[string]string
+// @Failure      404        {object}  map[string]string
+// @Failure      409        {object}  map[string]string
+// @Router       /admin/products/{id}/variants/{variantId} [put]
+func (h *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	variantId, err := urlParamInt64(r, "variantId")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	var req models.VariantRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	variant, err := h.svc.UpdateVariant(id, variantId, req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, variant)
+}
+
+// DELETE /admin/products/{id}/variants/{variantId}
+// @Summary      Delete variant
+// @Description  Delete a variant; it is also removed from carts (admin only)
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path  int64  true  "Product ID"
+// @Param        variantId  path  int64  true  "Variant ID"
+// @Success      204        "No Content"
+// @Failure      401        {object}  map[string]string
+// @Failure      403        {object}  map[string]string
+// @Failure      404        {object}  map[string]string
+// @Router       /admin/products/{id}/variants/{variantId} [delete]
+func (h *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	variantId, err := urlParamInt64(r, "variantId")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	if err := h.svc.DeleteVariant(id, variantId); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /admin/products/{id}/images
+// @Summary      Add product image
+// @Description  Add an image to a product's gallery; lower positions are shown first (admin only)
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int64                       true  "Product ID"
+// @Param        payload  body      models.ProductImageRequest  true  "Image"
+// @Success      201      {object}  models.ProductImage
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Router       /admin/products/{id}/images [post]
+func (h *ProductHandler) AddImage(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	var req models.ProductImageRequest
+	if err := readJSON(r, &req); err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	image, err := h.svc.AddImage(id, req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, image)
+}
+
+// DELETE /admin/products/{id}/images/{imageId}
+// @Summary      Delete product image
+// @Description  Remove an image from a product's gallery (admin only)
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int64  true  "Product ID"
+// @Param        imageId  path  int64  true  "Image ID"
+// @Success      204      "No Content"
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Router       /admin/products/{id}/images/{imageId} [delete]
+func (h *ProductHandler) DeleteImage(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt64(r, "id")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	imageId, err := urlParamInt64(r, "imageId")
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+	if err := h.svc.DeleteImage(id, imageId); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
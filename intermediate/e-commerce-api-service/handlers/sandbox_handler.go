@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
+)
+
+// SandboxHandler lets developers complete fake payments without a real provider.
+// It is only routed when PAYMENT_PROVIDER=fake.
+type SandboxHandler struct {
+	fake     *services.FakeProvider
+	orderSvc *services.OrderService
+}
+
+// NewSandboxHandler creates a new SandboxHandler.
+func NewSandboxHandler(fake *services.FakeProvider, orderSvc *services.OrderService) *SandboxHandler {
+	return &SandboxHandler{fake: fake, orderSvc: orderSvc}
+}
+
+// POST /sandbox/payments/{paymentId}/succeed
+// @Summary      Simulate a successful payment
+// @Description  Settle a fake payment as succeeded and mark its order paid (fake provider only)
+// @Tags         Sandbox
+// @Produce      json
+// @Security     BearerAuth
+// @Param        paymentId  path      string  true  "Payment ID returned by checkout"
+// @Success      200        {object}  map[string]string
+// @Failure      404        {object}  map[string]string
+// @Failure      409        {object}  map[string]string
+// @Router       /sandbox/payments/{paymentId}/succeed [post]
+func (h *SandboxHandler) Succeed(w http.ResponseWriter, r *http.Request) {
+	h.settle(w, r, true)
+}
+
+// POST /sandbox/payments/{paymentId}/fail
+// @Summary      Simulate a failed payment
+// @Description  Settle a fake payment as failed and cancel its order (fake provider only)
+// @Tags         Sandbox
+// @Produce      json
+// @Security     BearerAuth
+// @Param        paymentId  path      string  true  "Payment ID returned by checkout"
+// @Success      200        {object}  map[string]string
+// @Failure      404        {object}  map[string]string
+// @Failure      409        {object}  map[string]string
+// @Router       /sandbox/payments/{paymentId}/fail [post]
+func (h *SandboxHandler) Fail(w http.ResponseWriter, r *http.Request) {
+	h.settle(w, r, false)
+}
+
+// settle completes the fake payment and feeds the resulting event through the webhook flow.
+// Users may only settle the payments of their own orders; admins may settle any.
+func (h *SandboxHandler) settle(w http.ResponseWriter, r *http.Request, succeeded bool) {
+	paymentId := chi.URLParam(r, "paymentId")
+	if err := h.orderSvc.CheckPaymentAccess(getUserId(r), isAdmin(r), paymentId); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	event, err := h.fake.Settle(paymentId, succeeded)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if err := applyPaymentEvent(h.orderSvc, event); err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"event": string(event.Type)})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/database"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
+)
+
+// newTestDB opens a migrated SQLite database in a temporary directory
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=ON")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return db
+}
+
+// mustExec runs setup statements, failing the test on the first error
+func mustExec(t *testing.T, db *sql.DB, stmts ...string) {
+	t.Helper()
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+// newTestOrderService wires an OrderService to db as main does
+func newTestOrderService(db *sql.DB, payments services.PaymentProvider, alerts services.Alerter) *services.OrderService {
+	pricing := services.NewPricingService(repository.NewPricingRepo(db), repository.NewCategoryRepo(db), 0, 0)
+	inventory := services.NewInventoryService(repository.NewInventoryRepo(db), repository.NewProductRepo(db), services.LogAlerter{}, 15*time.Minute, 0)
+	return services.NewOrderService(repository.NewOrderRepo(db), repository.NewCartRepo(db),
+		inventory, pricing, services.NewAddressService(repository.NewAddressRepo(db)), payments, alerts)
+}
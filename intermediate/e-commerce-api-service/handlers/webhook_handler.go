@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
+)
+
+// maxWebhookBodyBytes caps the size of webhook payloads we are willing to read.
+const maxWebhookBodyBytes = 65536
+
+// WebhookHandler receives payment events from the payment provider.
+type WebhookHandler struct {
+	payments services.PaymentProvider
+	orderSvc *services.OrderService
+}
+
+// NewWebhookHandler creates a new WebhookHandler.
+func NewWebhookHandler(payments services.PaymentProvider, orderSvc *services.OrderService) *WebhookHandler {
+	return &WebhookHandler{payments: payments, orderSvc: orderSvc}
+}
+
+// POST /webhooks/stripe
+// @Summary      Stripe webhook
+// @Description  Receive Stripe events. The payload must be signed with the configured webhook secret (Stripe-Signature header).
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Stripe-Signature  header    string  true  "Stripe signature header"
+// @Success      200               {object}  map[string]bool
+// @Failure      400               {object}  map[string]string
+// @Failure      401               {object}  map[string]string
+// @Router       /webhooks/stripe [post]
+func (h *WebhookHandler) Stripe(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		handleError(w, models.ErrBadRequest)
+		return
+	}
+
+	event, err := h.payments.ParseWebhook(payload, r.Header.Get("Stripe-Signature"))
+	if err != nil {
+		log.Printf("[WEBHOOK] Rejected event: %v", err)
+		handleError(w, err)
+		return
+	}
+
+	err = applyPaymentEvent(h.orderSvc, event)
+
+	// Events that cannot apply to any order are acknowledged so Stripe stops
+	// retrying them; only unexpected failures ask Stripe to deliver again.
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrNotFound), errors.Is(err, models.ErrInvalidTransition):
+		log.Printf("[WEBHOOK] Event %s (%s) not applied: %v", event.ID, event.Type, err)
+	default:
+		log.Printf("[WEBHOOK] Event %s (%s) failed: %v", event.ID, event.Type, err)
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"received": true})
+}
+
+// applyPaymentEvent updates the order a payment event refers to.
+// Unknown event types are ignored.
+func applyPaymentEvent(orderSvc *services.OrderService, event *services.PaymentEvent) error {
+	switch event.Type {
+	case services.PaymentSucceeded:
+		return orderSvc.HandlePaymentSucceeded(event.PaymentIntentId)
+	case services.PaymentFailed:
+		return orderSvc.HandlePaymentFailed(event.PaymentIntentId)
+	default:
+		log.Printf("[WEBHOOK] Ignoring event %s (%s)", event.ID, event.Type)
+		return nil
+	}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
+	"github.com/stripe/stripe-go/v84/webhook"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// fixturePaymentId is the PaymentIntent the testdata/stripe fixtures refer to
+const fixturePaymentId = "pi_fixture_123"
+
+// webhookTest drives the webhook handler against a real SQLite database and
+// the fake payment provider.
+type webhookTest struct {
+	t        *testing.T
+	db       *sql.DB
+	handler  *WebhookHandler
+	orderSvc *services.OrderService
+	payments *services.FakeProvider
+	alerts   *recordedAlerts
+
+	// paymentId replaces the fixtures' PaymentIntent in posted events
+	paymentId string
+}
+
+// recordedAlerts keeps the subjects of the alerts sent
+type recordedAlerts struct {
+	subjects []string
+}
+
+func (a *recordedAlerts) Alert(subject, body string) {
+	a.subjects = append(a.subjects, subject)
+}
+
+func newWebhookTest(t *testing.T) *webhookTest {
+	t.Helper()
+	db := newTestDB(t)
+	payments := services.NewFakeProvider(testWebhookSecret)
+	alerts := &recordedAlerts{}
+	orderSvc := newTestOrderService(db, payments, alerts)
+
+	return &webhookTest{
+		t: t, db: db,
+		handler:   NewWebhookHandler(payments, orderSvc),
+		orderSvc:  orderSvc,
+		payments:  payments,
+		alerts:    alerts,
+		paymentId: fixturePaymentId,
+	}
+}
+
+// placeOrder records a pending order for two of five mugs, paid with
+// wt.paymentId, as checkout would.
+func (wt *webhookTest) placeOrder() int64 {
+	wt.t.Helper()
+	mustExec(wt.t, wt.db,
+		`INSERT INTO users (id, email, password) VALUES (1, 'buyer@example.com', 'hash')`,
+		`INSERT INTO products (id, name, price, stock) VALUES (1, 'Mug', 1999, 3)`,
+		`INSERT INTO orders (id, user_id, total, stripe_payment_id) VALUES (1, 1, 3998, '`+wt.paymentId+`')`,
+		`INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (1, 1, 2, 1999)`,
+	)
+	return 1
+}
+
+// post sends a fixture signed with secret at the given time.
+func (wt *webhookTest) post(fixture, secret string, signedAt time.Time) *httptest.ResponseRecorder {
+	wt.t.Helper()
+	payload, err := os.ReadFile(filepath.Join("..", "testdata", "stripe", fixture))
+	if err != nil {
+		wt.t.Fatal(err)
+	}
+	payload = bytes.ReplaceAll(payload, []byte(fixturePaymentId), []byte(wt.paymentId))
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: secret, Timestamp: signedAt})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/stripe", bytes.NewReader(signed.Payload))
+	req.Header.Set("Stripe-Signature", signed.Header)
+	rr := httptest.NewRecorder()
+	wt.handler.Stripe(rr, req)
+	return rr
+}
+
+func (wt *webhookTest) status(orderId int64) models.OrderStatus {
+	wt.t.Helper()
+	var status models.OrderStatus
+	if err := wt.db.QueryRow(`SELECT status FROM orders WHERE id = ?`, orderId).Scan(&status); err != nil {
+		wt.t.Fatal(err)
+	}
+	return status
+}
+
+func (wt *webhookTest) stock() int {
+	wt.t.Helper()
+	var stock int
+	if err := wt.db.QueryRow(`SELECT stock FROM products WHERE id = 1`).Scan(&stock); err != nil {
+		wt.t.Fatal(err)
+	}
+	return stock
+}
+
+func TestStripeWebhookPaymentSucceeded(t *testing.T) {
+	wt := newWebhookTest(t)
+	orderId := wt.placeOrder()
+
+	// A payload signed with another secret is rejected and changes nothing
+	if rr := wt.post("payment_intent_succeeded.json", "whsec_other", time.Now()); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("bad signature expected 401, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := wt.status(orderId); got != models.OrderPending {
+		t.Fatalf("expected the order still pending, got %s", got)
+	}
+
+	if rr := wt.post("payment_intent_succeeded.json", testWebhookSecret, time.Now()); rr.Code != http.StatusOK {
+		t.Fatalf("signed event expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := wt.status(orderId); got != models.OrderPaid {
+		t.Fatalf("expected the order paid, got %s", got)
+	}
+
+	// A captured request replayed later is rejected by its signature timestamp
+	if rr := wt.post("payment_intent_succeeded.json", testWebhookSecret, time.Now().Add(-time.Hour)); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed event expected 401, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Stripe redelivering the event is acknowledged without another change
+	if rr := wt.post("payment_intent_succeeded.json", testWebhookSecret, time.Now()); rr.Code != http.StatusOK {
+		t.Fatalf("redelivered event expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := wt.status(orderId); got != models.OrderPaid {
+		t.Fatalf("expected the order still paid, got %s", got)
+	}
+
+	// A late failure cannot cancel a paid order; it is acknowledged so Stripe stops retrying
+	if rr := wt.post("payment_intent_payment_failed.json", testWebhookSecret, time.Now()); rr.Code != http.StatusOK {
+		t.Fatalf("inapplicable event expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := wt.status(orderId); got != models.OrderPaid {
+		t.Fatalf("expected the order still paid, got %s", got)
+	}
+	if got := wt.stock(); got != 3 {
+		t.Fatalf("expected 3 mugs left, got %d", got)
+	}
+}
+
+func TestStripeWebhookPaymentFailed(t *testing.T) {
+	wt := newWebhookTest(t)
+	orderId := wt.placeOrder()
+
+	if rr := wt.post("payment_intent_payment_failed.json", testWebhookSecret, time.Now()); rr.Code != http.StatusOK {
+		t.Fatalf("signed event expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := wt.status(orderId); got != models.OrderCancelled {
+		t.Fatalf("expected the order cancelled, got %s", got)
+	}
+	if got := wt.stock(); got != 5 {
+		t.Fatalf("expected the stock restored to 5, got %d", got)
+	}
+
+	// Events for unknown payments are acknowledged and ignored
+	if _, err := wt.db.Exec(`UPDATE orders SET stripe_payment_id = '' WHERE id = ?`, orderId); err != nil {
+		t.Fatal(err)
+	}
+	if rr := wt.post("payment_intent_succeeded.json", testWebhookSecret, time.Now()); rr.Code != http.StatusOK {
+		t.Fatalf("unknown payment expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestStripeWebhookPaymentSucceededForCancelledOrder(t *testing.T) {
+	wt := newWebhookTest(t)
+	pi, err := wt.payments.CreatePaymentIntent(3998, "usd", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.paymentId = pi.ID
+	orderId := wt.placeOrder()
+
+	// The customer completes the payment while the order is being cancelled:
+	// the order is cancelled before the success event arrives.
+	if _, err := wt.payments.Settle(pi.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.orderSvc.UpdateStatus(orderId, models.OrderCancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr := wt.post("payment_intent_succeeded.json", testWebhookSecret, time.Now()); rr.Code != http.StatusOK {
+		t.Fatalf("signed event expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := wt.status(orderId); got != models.OrderCancelled {
+		t.Fatalf("expected the order still cancelled, got %s", got)
+	}
+	if got := wt.stock(); got != 5 {
+		t.Fatalf("expected the stock to stay restored at 5, got %d", got)
+	}
+	if len(wt.alerts.subjects) != 1 {
+		t.Fatalf("expected one alert, got %v", wt.alerts.subjects)
+	}
+
+	// The whole payment was refunded, so nothing is left to refund
+	if _, err := wt.payments.Refund(pi.ID, 1, ""); !errors.Is(err, models.ErrBadRequest) {
+		t.Fatalf("expected the payment fully refunded, got %v", err)
+	}
+}
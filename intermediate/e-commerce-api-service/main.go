@@ -12,16 +12,18 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/config"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/database"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/docs"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/handlers"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/router"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/services"
-	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/docs"
 )
 
 func main() {
@@ -35,6 +37,14 @@ func main() {
 	}
 	defer db.Close()
 
+	// `migrate ...` manages the schema and exits without starting the server
+	if isMigrateCommand() {
+		if err := runMigrateCommand(db, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	if err := database.RunMigrations(db); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
@@ -42,19 +52,48 @@ func main() {
 	// 3. Wire up layers: repos → services → handlers
 	userRepo := repository.NewUserRepo(db)
 	productRepo := repository.NewProductRepo(db)
+	categoryRepo := repository.NewCategoryRepo(db)
 	cartRepo := repository.NewCartRepo(db)
 	orderRepo := repository.NewOrderRepo(db)
+	pricingRepo := repository.NewPricingRepo(db)
+	inventoryRepo := repository.NewInventoryRepo(db)
+	tokenRepo := repository.NewTokenRepo(db)
+	addressRepo := repository.NewAddressRepo(db)
 
-	authSvc := services.NewAuthService(userRepo, cfg.JWTSecret)
-	productSvc := services.NewProductService(productRepo)
-	cartSvc := services.NewCartService(cartRepo, productRepo)
-	paymentSvc := services.NewPaymentService(cfg.StripeKey)
-	orderSvc := services.NewOrderService(orderRepo, cartRepo, productRepo, paymentSvc)
+	mailer, err := services.NewMailer(cfg.Mailer, cfg.MailFrom, cfg.MailDir)
+	if err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+	authSvc := services.NewAuthService(userRepo, tokenRepo, mailer, cfg.JWTSecret,
+		cfg.AccessTokenTTL, cfg.RefreshTokenTTL, cfg.PasswordResetTTL, cfg.BaseURL)
+	addressSvc := services.NewAddressService(addressRepo)
+	alerts := services.NewAlerter(mailer, cfg.AlertEmail)
+	inventorySvc := services.NewInventoryService(inventoryRepo, productRepo, alerts, cfg.ReservationTTL, cfg.LowStockThreshold)
+	productSvc := services.NewProductService(productRepo, categoryRepo, inventorySvc)
+	categorySvc := services.NewCategoryService(categoryRepo)
+	pricingSvc := services.NewPricingService(pricingRepo, categoryRepo, cfg.ShippingFee, cfg.FreeShippingMin)
+	cartSvc := services.NewCartService(cartRepo, productRepo, inventorySvc, pricingSvc)
+	payments, err := services.NewPaymentProvider(cfg.PaymentProvider, cfg.StripeKey, cfg.StripeWebhookSecret)
+	if err != nil {
+		log.Fatalf("Failed to configure payments: %v", err)
+	}
+	orderSvc := services.NewOrderService(orderRepo, cartRepo, inventorySvc, pricingSvc, addressSvc, payments, alerts)
 
 	authH := handlers.NewAuthHandler(authSvc)
+	addressH := handlers.NewAddressHandler(addressSvc)
 	productH := handlers.NewProductHandler(productSvc)
+	categoryH := handlers.NewCategoryHandler(categorySvc)
 	cartH := handlers.NewCartHandler(cartSvc)
 	orderH := handlers.NewOrderHandler(orderSvc)
+	pricingH := handlers.NewPricingHandler(pricingSvc)
+	inventoryH := handlers.NewInventoryHandler(inventorySvc)
+	webhookH := handlers.NewWebhookHandler(payments, orderSvc)
+
+	// The sandbox endpoints only exist when the fake provider is active
+	var sandboxH *handlers.SandboxHandler
+	if fake, ok := payments.(*services.FakeProvider); ok {
+		sandboxH = handlers.NewSandboxHandler(fake, orderSvc)
+	}
 
 	// Page Handler (HTML templates)
 	pageH := handlers.NewPageHandler("templates", productSvc, cartSvc, orderSvc, authSvc)
@@ -62,6 +101,11 @@ func main() {
 	// 4. Seed admin user if not exists
 	database.SeedAdmin(userRepo, cfg.AdminEmail, cfg.AdminPassword)
 
+	// Release cart reservations whose hold has expired
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go inventorySvc.RunReservationSweeper(ctx, cfg.ReservationSweep)
+
 	// 5. Build router and start server
 	// Configure swagger metadata
 	docs.SwaggerInfo.Title = "E-Commerce API Service"
@@ -69,7 +113,7 @@ func main() {
 	docs.SwaggerInfo.BasePath = "/api/v1"
 	docs.SwaggerInfo.Host = "localhost:" + cfg.Port
 
-	r := router.New(authSvc, authH, productH, cartH, orderH, pageH)
+	r := router.New(authSvc, authH, addressH, productH, categoryH, cartH, orderH, pricingH, inventoryH, webhookH, sandboxH, pageH)
 
 	log.Printf("[SERVER] Starting on :%s", cfg.Port)
 	log.Printf("[SERVER] Open http://localhost:%s in your browser", cfg.Port)
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/database"
+)
+
+const migrateUsage = `usage: e-commerce-api-service migrate <command>
+
+commands:
+  status           list migrations and whether they are applied
+  up [version]     apply pending migrations, up to version if given
+  down [steps]     roll back the newest migrations (default 1)`
+
+// runMigrateCommand handles `migrate status|up|down` and reports the result on stdout.
+func runMigrateCommand(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", migrateUsage)
+	}
+
+	m := database.NewMigrator(db)
+
+	// Optional numeric argument for up and down
+	n := 0
+	if len(args) > 1 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid number %q\n%s", args[1], migrateUsage)
+		}
+		n = v
+	}
+
+	switch args[0] {
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-20s %s\n", s.Version, s.Name, applied)
+		}
+
+	case "up":
+		applied, err := m.Up(n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		rolledBack, err := m.Down(n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", rolledBack)
+
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], migrateUsage)
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema version: %d\n", version)
+
+	return nil
+}
+
+// isMigrateCommand reports whether the binary was started as `migrate ...`.
+func isMigrateCommand() bool {
+	return len(os.Args) > 1 && os.Args[1] == "migrate"
+}
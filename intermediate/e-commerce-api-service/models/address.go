@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ShippingAddress is a postal address. Orders keep a copy of the address they
+// were shipped to, so editing or deleting a saved address does not change them.
+type ShippingAddress struct {
+	Name       string `json:"name"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	Region     string `json:"region,omitempty"` // state, province or county
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"` // ISO 3166-1 alpha-2, e.g. "US"
+	Phone      string `json:"phone,omitempty"`
+}
+
+// Normalize trims the fields and upper-cases the country code
+func (a *ShippingAddress) Normalize() {
+	for _, f := range []*string{&a.Name, &a.Line1, &a.Line2, &a.City, &a.Region, &a.PostalCode, &a.Country, &a.Phone} {
+		*f = strings.TrimSpace(*f)
+	}
+	a.Country = strings.ToUpper(a.Country)
+}
+
+// Validate checks that the address can be shipped to
+func (a *ShippingAddress) Validate() error {
+	switch {
+	case a.Name == "", a.Line1 == "", a.City == "", a.PostalCode == "":
+		return fmt.Errorf("%w: name, line1, city and postal_code are required", ErrBadRequest)
+	case len(a.Country) != 2:
+		return fmt.Errorf("%w: country must be a two-letter code", ErrBadRequest)
+	}
+	return nil
+}
+
+// Address is a shipping address saved in a user's address book
+type Address struct {
+	ID     int64  `json:"id"`
+	UserId int64  `json:"user_id"`
+	Label  string `json:"label,omitempty"` // e.g. "Home" or "Work"
+	ShippingAddress
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AddressRequest represents the payload for saving an address.
+// The first address a user saves becomes the default.
+type AddressRequest struct {
+	Label string `json:"label"`
+	ShippingAddress
+	IsDefault bool `json:"is_default"`
+}
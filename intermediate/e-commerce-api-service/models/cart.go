@@ -4,18 +4,25 @@ import "time"
 
 // CartItem represents an item in the shopping cart
 type CartItem struct {
-	ID        int64     `json:"id"`
-	UserId    int64     `json:"user_id"`
-	ProductId int64     `json:"product_id"`
-	Quantity  int       `json:"quantity"`
-	Product   *Product  `json:"product,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            int64           `json:"id"`
+	UserId        int64           `json:"user_id"`
+	ProductId     int64           `json:"product_id"`
+	VariantId     int64           `json:"variant_id,omitempty"` // 0 for products without variants
+	Quantity      int             `json:"quantity"`
+	UnitPrice     int64           `json:"unit_price"` // variant price, or product price without a variant
+	Discount      int64           `json:"discount"`   // automatic discount for the whole line, in cents
+	Product       *Product        `json:"product,omitempty"`
+	Variant       *ProductVariant `json:"variant,omitempty"`
+	ReservedUntil *time.Time      `json:"reserved_until,omitempty"` // stock is held until then; nil once expired
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
 }
 
-// AddToCartRequest represents the request payload for adding a product to the cart
+// AddToCartRequest represents the request payload for adding a product to the cart.
+// variant_id is required for products that have variants.
 type AddToCartRequest struct {
 	ProductId int64 `json:"product_id"`
+	VariantId int64 `json:"variant_id"`
 	Quantity  int   `json:"quantity"`
 }
 
@@ -24,8 +31,10 @@ type UpdateCartItemRequest struct {
 	Quantity int `json:"quantity"`
 }
 
-// CartResponse represents the response payload for cart retrieval
+// CartResponse represents the response payload for cart retrieval.
+// The embedded breakdown shows discounts, shipping and tax; Total is the amount due.
 type CartResponse struct {
 	Items []CartItem `json:"items"`
-	Total int64      `json:"total"` // total price in cents
+	PriceBreakdown
+	Warnings []string `json:"warnings,omitempty"` // e.g. a coupon that does not apply
 }
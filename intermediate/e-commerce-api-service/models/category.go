@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Category groups products. Categories form a tree through ParentId.
+type Category struct {
+	ID        int64      `json:"id"`
+	ParentId  *int64     `json:"parent_id,omitempty"`
+	Name      string     `json:"name"`
+	Slug      string     `json:"slug"`
+	Children  []Category `json:"children,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CategoryRequest represents the request payload for creating or updating a category.
+// An empty slug is derived from the name.
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentId *int64 `json:"parent_id"`
+}
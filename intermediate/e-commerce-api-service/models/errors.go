@@ -10,4 +10,5 @@ var (
 	ErrBadRequest        = errors.New("bad request")
 	ErrInsufficientStock = errors.New("insufficient stock")
 	ErrEmptyCart         = errors.New("cart is empty")
+	ErrInvalidTransition = errors.New("invalid order status transition")
 )
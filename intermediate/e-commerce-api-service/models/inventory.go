@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// MovementType is the reason stock changed
+type MovementType string
+
+const (
+	MovementSale       MovementType = "sale"       // units sold at checkout
+	MovementRestock    MovementType = "restock"    // new units received
+	MovementAdjustment MovementType = "adjustment" // manual correction, e.g. after a stock count
+	MovementReturn     MovementType = "return"     // units back from a customer or a cancelled order
+)
+
+// IsValid reports whether t is a known movement type
+func (t MovementType) IsValid() bool {
+	switch t {
+	case MovementSale, MovementRestock, MovementAdjustment, MovementReturn:
+		return true
+	}
+	return false
+}
+
+// InventoryMovement is one entry in the stock ledger
+type InventoryMovement struct {
+	ID         int64        `json:"id"`
+	ProductId  int64        `json:"product_id"`
+	VariantId  int64        `json:"variant_id,omitempty"`
+	Type       MovementType `json:"type"`
+	Quantity   int          `json:"quantity"`    // signed change in units
+	StockAfter int          `json:"stock_after"` // stock of the product or variant after the change
+	OrderId    *int64       `json:"order_id,omitempty"`
+	UserId     *int64       `json:"user_id,omitempty"` // admin who recorded it, if any
+	Note       string       `json:"note,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// InventoryMovementRequest represents a stock change recorded by an admin.
+// Restocks and returns take a positive quantity; adjustments are signed.
+// Sales are only recorded by checkout.
+type InventoryMovementRequest struct {
+	ProductId int64        `json:"product_id"`
+	VariantId int64        `json:"variant_id"`
+	Type      MovementType `json:"type"`
+	Quantity  int          `json:"quantity"`
+	Note      string       `json:"note"`
+}
+
+// MovementQuery filters the stock ledger. Zero values match everything.
+type MovementQuery struct {
+	ProductId int64
+	VariantId int64
+	Type      MovementType
+	Limit     int
+}
+
+// StockLevel is the stock of a product without variants, or of one variant
+type StockLevel struct {
+	ProductId int64  `json:"product_id"`
+	VariantId int64  `json:"variant_id,omitempty"`
+	Name      string `json:"name"`
+	SKU       string `json:"sku,omitempty"`
+	Stock     int    `json:"stock"`     // units on hand
+	Reserved  int    `json:"reserved"`  // units held by active cart reservations
+	Available int    `json:"available"` // stock minus reserved
+}
@@ -2,16 +2,58 @@ package models
 
 import "time"
 
+// OrderStatus is the lifecycle state of an order
+type OrderStatus string
+
+const (
+	OrderPending   OrderStatus = "pending"
+	OrderPaid      OrderStatus = "paid"
+	OrderShipped   OrderStatus = "shipped"
+	OrderDelivered OrderStatus = "delivered"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderRefunded  OrderStatus = "refunded"
+)
+
+// orderTransitions lists the states each status may move to.
+// Cancelled and refunded are terminal.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderShipped, OrderRefunded},
+	OrderShipped:   {OrderDelivered, OrderRefunded},
+	OrderDelivered: {OrderRefunded},
+}
+
+// IsValid reports whether s is a known order status
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderPending, OrderPaid, OrderShipped, OrderDelivered, OrderCancelled, OrderRefunded:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether an order in status s may move to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 // Order represents an order placed by a user
 type Order struct {
-	ID              int64       `json:"id"`
-	UserId          int64       `json:"user_id"`
-	Total           int64       `json:"total"` // total price in cents
-	StripePaymentId string      `json:"stripe_payment_id,omitempty"`
-	Items           []OrderItem `json:"items,omitempty"`
-	Status          string      `json:"status"`
-	CreatedAt       time.Time   `json:"created_at"`
-	UpdatedAt       time.Time   `json:"updated_at"`
+	ID              int64            `json:"id"`
+	UserId          int64            `json:"user_id"`
+	PriceBreakdown                   // Total is the amount charged, in cents
+	StripePaymentId string           `json:"stripe_payment_id,omitempty"`
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"` // nil for orders placed before addresses existed
+	Items           []OrderItem      `json:"items,omitempty"`
+	Status          OrderStatus      `json:"status"`
+	UserEmail       string           `json:"user_email,omitempty"` // only set in admin listings
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
 }
 
 // OrderItem represents an item in an order
@@ -19,14 +61,52 @@ type OrderItem struct {
 	ID        int64    `json:"id"`
 	OrderId   int64    `json:"order_id"`
 	ProductId int64    `json:"product_id"`
+	VariantId int64    `json:"variant_id,omitempty"`
+	SKU       string   `json:"sku,omitempty"`
 	Quantity  int      `json:"quantity"`
-	Price     int64    `json:"price"`
+	Price     int64    `json:"price"`    // unit price at purchase time
+	Discount  int64    `json:"discount"` // automatic discount for the whole line
 	Product   *Product `json:"product,omitempty"`
 }
 
+// CheckoutRequest represents the request payload for checkout.
+// The order ships to ShippingAddress if given, else to the saved address
+// AddressId, else to the user's default address.
+type CheckoutRequest struct {
+	PricingOptions
+	AddressId       int64            `json:"address_id"`
+	ShippingAddress *ShippingAddress `json:"shipping_address"`
+}
+
 // CheckoutResponse represents the response payload for checkout
 type CheckoutResponse struct {
 	OrderID         int64  `json:"order_id"`
 	ClientSecret    string `json:"client_secret"`
 	StripePaymentId string `json:"stripe_payment_id"`
+	PriceBreakdown
+}
+
+// OrderQuery filters the admin order list. Zero values match everything.
+// From and To are inclusive calendar days in UTC.
+type OrderQuery struct {
+	Status OrderStatus
+	UserId int64
+	Email  string // substring of the customer's email
+	From   time.Time
+	To     time.Time
+	Page   int
+	Limit  int // 0 returns every match (CSV export)
+}
+
+// OrderListResult is a page of orders across all customers
+type OrderListResult struct {
+	Items []Order `json:"items"`
+	Total int     `json:"total"`
+	Page  int     `json:"page"`
+	Limit int     `json:"limit"`
+}
+
+// OrderStatusRequest represents an admin status change
+type OrderStatusRequest struct {
+	Status OrderStatus `json:"status"`
 }
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// DiscountType says how a coupon or discount value is interpreted
+type DiscountType string
+
+const (
+	DiscountPercentage DiscountType = "percentage" // value is a whole percent (1-100)
+	DiscountFixed      DiscountType = "fixed"      // value is an amount in cents
+)
+
+// Coupon is an admin-managed code customers enter at checkout
+type Coupon struct {
+	ID          int64        `json:"id"`
+	Code        string       `json:"code"`
+	Type        DiscountType `json:"type"`
+	Value       int64        `json:"value"`
+	MinSubtotal int64        `json:"min_subtotal"` // cents; 0 = no minimum
+	MaxUses     int          `json:"max_uses"`     // 0 = unlimited
+	TimesUsed   int          `json:"times_used"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+	Active      bool         `json:"active"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// CouponRequest represents the request payload for creating or updating a coupon
+type CouponRequest struct {
+	Code        string       `json:"code"`
+	Type        DiscountType `json:"type"`
+	Value       int64        `json:"value"`
+	MinSubtotal int64        `json:"min_subtotal"`
+	MaxUses     int          `json:"max_uses"`
+	ExpiresAt   *time.Time   `json:"expires_at"`
+	Active      *bool        `json:"active"`
+}
+
+// Discount is an automatic price reduction applied to one product
+// or to every product in a category and its subcategories
+type Discount struct {
+	ID         int64        `json:"id"`
+	Name       string       `json:"name"`
+	ProductId  int64        `json:"product_id,omitempty"`
+	CategoryId int64        `json:"category_id,omitempty"`
+	Type       DiscountType `json:"type"`
+	Value      int64        `json:"value"`
+	StartsAt   *time.Time   `json:"starts_at,omitempty"`
+	EndsAt     *time.Time   `json:"ends_at,omitempty"`
+	Active     bool         `json:"active"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// DiscountRequest represents the request payload for creating a discount.
+// Exactly one of product_id and category_id must be set.
+type DiscountRequest struct {
+	Name       string       `json:"name"`
+	ProductId  int64        `json:"product_id"`
+	CategoryId int64        `json:"category_id"`
+	Type       DiscountType `json:"type"`
+	Value      int64        `json:"value"`
+	StartsAt   *time.Time   `json:"starts_at"`
+	EndsAt     *time.Time   `json:"ends_at"`
+}
+
+// TaxRate is the sales tax charged for a region
+type TaxRate struct {
+	Region  string `json:"region"`   // e.g. "US-CA"
+	Name    string `json:"name"`     // e.g. "California"
+	RateBps int    `json:"rate_bps"` // basis points: 725 = 7.25%
+}
+
+// TaxRateRequest represents the request payload for setting a tax rate
+type TaxRateRequest struct {
+	Name    string `json:"name"`
+	RateBps int    `json:"rate_bps"`
+}
+
+// PricingOptions are the customer choices that affect the price of a basket
+type PricingOptions struct {
+	CouponCode string `json:"coupon_code"`
+	Region     string `json:"region"`
+}
+
+// PriceBreakdown is the itemised price of a basket or order, all amounts in cents
+type PriceBreakdown struct {
+	Subtotal       int64  `json:"subtotal"` // unit price × quantity before discounts
+	Discount       int64  `json:"discount"` // automatic product discounts
+	CouponCode     string `json:"coupon_code,omitempty"`
+	CouponDiscount int64  `json:"coupon_discount"` // reduction from the coupon
+	Shipping       int64  `json:"shipping"`
+	TaxRegion      string `json:"tax_region,omitempty"`
+	TaxRateBps     int    `json:"tax_rate_bps"`
+	Tax            int64  `json:"tax"`
+	Total          int64  `json:"total"` // amount charged
+}
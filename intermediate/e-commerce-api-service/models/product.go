@@ -2,16 +2,55 @@ package models
 
 import "time"
 
-// Product represents a product in the system
+// Product represents a product in the system.
+// For a product with variants, Price is the cheapest variant and Stock the total.
 type Product struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       int64     `json:"price"` // cents
-	Stock       int       `json:"stock"`
-	ImageURL    string    `json:"image_url"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64            `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Price       int64            `json:"price"` // cents
+	Stock       int              `json:"stock"`
+	ImageURL    string           `json:"image_url"` // cover image
+	CategoryId  *int64           `json:"category_id,omitempty"`
+	Variants    []ProductVariant `json:"variants,omitempty"`
+	Images      []ProductImage   `json:"images,omitempty"` // gallery, in display order
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// ProductVariant is a purchasable SKU of a product, e.g. size M in blue
+type ProductVariant struct {
+	ID        int64     `json:"id"`
+	ProductId int64     `json:"product_id"`
+	SKU       string    `json:"sku"`
+	Size      string    `json:"size,omitempty"`
+	Color     string    `json:"color,omitempty"`
+	Price     int64     `json:"price"` // cents
+	Stock     int       `json:"stock"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Label describes the variant for display, e.g. "M / Blue"
+func (v *ProductVariant) Label() string {
+	switch {
+	case v.Size != "" && v.Color != "":
+		return v.Size + " / " + v.Color
+	case v.Size != "":
+		return v.Size
+	case v.Color != "":
+		return v.Color
+	}
+	return v.SKU
+}
+
+// ProductImage is one picture in a product's gallery
+type ProductImage struct {
+	ID        int64  `json:"id"`
+	ProductId int64  `json:"product_id"`
+	URL       string `json:"url"`
+	AltText   string `json:"alt_text"`
+	Position  int    `json:"position"`
 }
 
 // ProductCreateRequest represents the request payload for creating a product
@@ -21,22 +60,86 @@ type ProductCreateRequest struct {
 	Price       int64  `json:"price"` // cents
 	Stock       int    `json:"stock"`
 	ImageURL    string `json:"image_url"`
+	CategoryId  *int64 `json:"category_id"`
 }
 
-// ProductUpdateRequest represents the request payload for updating a product
+// ProductUpdateRequest represents the request payload for updating a product.
+// A category_id of 0 removes the product from its category.
 type ProductUpdateRequest struct {
 	Name        *string `json:"name"`
 	Description *string `json:"description"`
 	Price       *int64  `json:"price"` // cents
 	Stock       *int    `json:"stock"`
 	ImageURL    *string `json:"image_url"`
+	CategoryId  *int64  `json:"category_id"`
+}
+
+// VariantRequest represents the request payload for creating or replacing a variant
+type VariantRequest struct {
+	SKU   string `json:"sku"`
+	Size  string `json:"size"`
+	Color string `json:"color"`
+	Price int64  `json:"price"` // cents
+	Stock int    `json:"stock"`
+}
+
+// ProductImageRequest represents the request payload for adding a gallery image
+type ProductImageRequest struct {
+	URL      string `json:"url"`
+	AltText  string `json:"alt_text"`
+	Position int    `json:"position"`
 }
 
+// Product sort orders accepted by ProductQuery.Sort
+const (
+	SortRelevance = "relevance" // best search match first (default with a search term)
+	SortNewest    = "newest"    // default without a search term
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortName      = "name"
+)
+
 // ProductQuery represents the query parameters for product retrieval
 type ProductQuery struct {
-	Name     string
-	MinPrice int64
-	MaxPrice int64
-	Page     int
-	Limit    int
+	Name       string // full-text search terms
+	CategoryId int64  // includes subcategories
+	MinPrice   int64
+	MaxPrice   int64
+	Sort       string
+	Page       int
+	Limit      int
+}
+
+// ProductSearchResult is a page of products with facet counts for the whole result
+type ProductSearchResult struct {
+	Items  []Product     `json:"items"`
+	Total  int           `json:"total"`
+	Page   int           `json:"page"`
+	Limit  int           `json:"limit"`
+	Sort   string        `json:"sort"`
+	Facets ProductFacets `json:"facets"`
+}
+
+// ProductFacets count matching products per category and price range.
+// Each facet ignores its own filter so the other options stay visible.
+type ProductFacets struct {
+	Categories []CategoryFacet `json:"categories"`
+	Prices     []PriceFacet    `json:"prices"`
+}
+
+// CategoryFacet counts matches in a category, including its subcategories
+type CategoryFacet struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentId *int64 `json:"parent_id,omitempty"`
+	Count    int    `json:"count"`
+}
+
+// PriceFacet counts matches in a price range [Min, Max); Max 0 means no upper bound
+type PriceFacet struct {
+	Label string `json:"label"`
+	Min   int64  `json:"min"`
+	Max   int64  `json:"max"`
+	Count int    `json:"count"`
 }
@@ -0,0 +1,31 @@
+package models
+
+// SalesReport summarises revenue over a date range. Only paid, shipped and
+// delivered orders count as sales; pending, cancelled and refunded orders are left out.
+// Amounts are in cents.
+type SalesReport struct {
+	From              string         `json:"from"` // first day, YYYY-MM-DD (UTC)
+	To                string         `json:"to"`   // last day, inclusive
+	Orders            int            `json:"orders"`
+	Revenue           int64          `json:"revenue"`             // sum of order totals, including tax and shipping
+	AverageOrderValue int64          `json:"average_order_value"` // revenue / orders, rounded down
+	Daily             []DailySales   `json:"daily"`               // one entry per day, including days without sales
+	TopProducts       []ProductSales `json:"top_products"`
+}
+
+// DailySales is the number of orders and revenue for one day
+type DailySales struct {
+	Date    string `json:"date"`
+	Orders  int    `json:"orders"`
+	Revenue int64  `json:"revenue"`
+}
+
+// ProductSales is the units sold and line revenue of one product.
+// Line revenue is the unit price times quantity minus automatic discounts,
+// before coupons, shipping and tax.
+type ProductSales struct {
+	ProductId int64  `json:"product_id"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	Revenue   int64  `json:"revenue"`
+}
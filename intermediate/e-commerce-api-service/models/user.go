@@ -24,8 +24,44 @@ type UserLoginRequest struct {
 	Password string `json:"password"`
 }
 
-// AuthResponse represents the response payload for authentication
+// AuthResponse represents the response payload for authentication.
+// Token is a short-lived access token; RefreshToken obtains the next pair.
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token          string    `json:"token"`
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+	RefreshToken   string    `json:"refresh_token"`
+	User           User      `json:"user"`
+}
+
+// RefreshRequest exchanges a refresh token for a new token pair
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutRequest revokes a refresh token, or every session of its user when All is set
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
+// ForgotPasswordRequest asks for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest sets a new password with a token from the reset email
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// RefreshToken is a stored refresh token. Only the hash of the token is kept.
+// Tokens issued by rotating one another share a family, so reuse of a rotated
+// token can revoke the whole chain.
+type RefreshToken struct {
+	ID        int64
+	UserId    int64
+	Family    string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
 }
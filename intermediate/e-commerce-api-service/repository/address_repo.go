@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+)
+
+// AddressRepo is the repository for users' saved shipping addresses
+type AddressRepo struct {
+	db *sql.DB
+}
+
+// NewAddressRepo creates a new instance of AddressRepo
+func NewAddressRepo(db *sql.DB) *AddressRepo {
+	return &AddressRepo{db: db}
+}
+
+const addressColumns = `id, user_id, label, name, line1, line2, city, region, postal_code, country, phone,
+    is_default, created_at, updated_at`
+
+// addressFields returns the scan destinations matching addressColumns.
+func addressFields(a *models.Address) []any {
+	return []any{
+		&a.ID, &a.UserId, &a.Label, &a.Name, &a.Line1, &a.Line2, &a.City, &a.Region, &a.PostalCode,
+		&a.Country, &a.Phone, &a.IsDefault, &a.CreatedAt, &a.UpdatedAt,
+	}
+}
+
+// List returns a user's addresses, the default first
+func (r *AddressRepo) List(userId int64) ([]models.Address, error) {
+	rows, err := r.db.Query(
+		`SELECT `+addressColumns+` FROM addresses WHERE user_id = ? ORDER BY is_default DESC, id`, userId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing addresses: %w", err)
+	}
+	defer rows.Close()
+
+	var addresses []models.Address
+	for rows.Next() {
+		var a models.Address
+		if err := rows.Scan(addressFields(&a)...); err != nil {
+			return nil, fmt.Errorf("scanning address: %w", err)
+		}
+		addresses = append(addresses, a)
+	}
+
+	return addresses, rows.Err()
+}
+
+// Find returns one of a user's addresses. Addresses of other users are not found.
+func (r *AddressRepo) Find(userId, id int64) (*models.Address, error) {
+	return r.findOne(`SELECT `+addressColumns+` FROM addresses WHERE id = ? AND user_id = ?`, id, userId)
+}
+
+// FindDefault returns a user's default address
+func (r *AddressRepo) FindDefault(userId int64) (*models.Address, error) {
+	return r.findOne(`SELECT `+addressColumns+` FROM addresses WHERE user_id = ? AND is_default = 1`, userId)
+}
+
+func (r *AddressRepo) findOne(query string, args ...any) (*models.Address, error) {
+	a := &models.Address{}
+	err := r.db.QueryRow(query, args...).Scan(addressFields(a)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying address: %w", err)
+	}
+
+	return a, nil
+}
+
+// Create saves an address. It becomes the default when requested or when it
+// is the user's first address.
+func (r *AddressRepo) Create(userId int64, req *models.AddressRequest) (a *models.Address, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	isDefault := req.IsDefault
+	if !isDefault {
+		var count int
+		if err = tx.QueryRow(`SELECT COUNT(*) FROM addresses WHERE user_id = ?`, userId).Scan(&count); err != nil {
+			return nil, fmt.Errorf("counting addresses: %w", err)
+		}
+		isDefault = count == 0
+	}
+	if isDefault {
+		if err = clearDefaultAddress(tx, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := tx.Exec(
+		`INSERT INTO addresses (user_id, label, name, line1, line2, city, region, postal_code, country, phone, is_default)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userId, req.Label, req.Name, req.Line1, req.Line2, req.City, req.Region, req.PostalCode,
+		req.Country, req.Phone, isDefault,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating address: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	id, _ := res.LastInsertId()
+
+	return r.Find(userId, id)
+}
+
+// Update replaces an address. Setting is_default moves the default to it;
+// the default cannot be unset without choosing another one.
+func (r *AddressRepo) Update(userId, id int64, req *models.AddressRequest) (a *models.Address, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if req.IsDefault {
+		if err = clearDefaultAddress(tx, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := tx.Exec(
+		`UPDATE addresses SET label = ?, name = ?, line1 = ?, line2 = ?, city = ?, region = ?,
+                              postal_code = ?, country = ?, phone = ?, is_default = is_default OR ?,
+                              updated_at = CURRENT_TIMESTAMP
+         WHERE id = ? AND user_id = ?`,
+		req.Label, req.Name, req.Line1, req.Line2, req.City, req.Region,
+		req.PostalCode, req.Country, req.Phone, req.IsDefault, id, userId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("updating address: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = models.ErrNotFound
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return r.Find(userId, id)
+}
+
+// Delete removes an address. When it was the default, the oldest remaining
+// address becomes the default.
+func (r *AddressRepo) Delete(userId, id int64) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	res, err := tx.Exec(`DELETE FROM addresses WHERE id = ? AND user_id = ?`, id, userId)
+	if err != nil {
+		return fmt.Errorf("deleting address: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = models.ErrNotFound
+		return err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE addresses SET is_default = 1
+         WHERE id = (SELECT MIN(id) FROM addresses WHERE user_id = ?)
+           AND NOT EXISTS (SELECT 1 FROM addresses WHERE user_id = ? AND is_default = 1)`,
+		userId, userId,
+	)
+	if err != nil {
+		return fmt.Errorf("choosing default address: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func clearDefaultAddress(tx *sql.Tx, userId int64) error {
+	if _, err := tx.Exec(`UPDATE addresses SET is_default = 0 WHERE user_id = ?`, userId); err != nil {
+		return fmt.Errorf("clearing default address: %w", err)
+	}
+	return nil
+}
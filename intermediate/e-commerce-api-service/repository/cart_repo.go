@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
@@ -17,14 +18,15 @@ func NewCartRepo(db *sql.DB) *CartRepo {
 	return &CartRepo{db: db}
 }
 
-// Upsert inserts a new cart item or updates the quantity if it already exists
-func (r *CartRepo) Upsert(userId int64, productId int64, qty int) error {
+// Upsert inserts a new cart item or updates the quantity if it already exists.
+// variantId is 0 for products without variants.
+func (r *CartRepo) Upsert(userId, productId, variantId int64, qty int) error {
 	_, err := r.db.Exec(
-		`INSERT INTO cart_items (user_id, product_id, quantity)
-         VALUES (?, ?, ?)
-         ON CONFLICT(user_id, product_id)
-         DO UPDATE SET quantity = quantity + excluded.quantity`,
-		userId, productId, qty,
+		`INSERT INTO cart_items (user_id, product_id, variant_id, quantity)
+         VALUES (?, ?, ?, ?)
+         ON CONFLICT(user_id, product_id, variant_id)
+         DO UPDATE SET quantity = quantity + excluded.quantity, updated_at = CURRENT_TIMESTAMP`,
+		userId, productId, variantId, qty,
 	)
 	if err != nil {
 		return fmt.Errorf("upserting cart item: %w", err)
@@ -33,12 +35,12 @@ func (r *CartRepo) Upsert(userId int64, productId int64, qty int) error {
 }
 
 // UpdateQuantity updates the quantity of a cart item
-func (r *CartRepo) UpdateQuantity(userId, productId int64, qty int) error {
+func (r *CartRepo) UpdateQuantity(userId, productId, variantId int64, qty int) error {
 	res, err := r.db.Exec(
 		`UPDATE cart_items
-         SET quantity = ?
-         WHERE user_id = ? AND product_id = ?`,
-		qty, userId, productId,
+         SET quantity = ?, updated_at = CURRENT_TIMESTAMP
+         WHERE user_id = ? AND product_id = ? AND variant_id = ?`,
+		qty, userId, productId, variantId,
 	)
 	if err != nil {
 		return fmt.Errorf("updating cart quantity: %w", err)
@@ -52,11 +54,11 @@ func (r *CartRepo) UpdateQuantity(userId, productId int64, qty int) error {
 }
 
 // Remove removes a cart item
-func (r *CartRepo) Remove(userId, productId int64) error {
+func (r *CartRepo) Remove(userId, productId, variantId int64) error {
 	res, err := r.db.Exec(
 		`DELETE FROM cart_items
-         WHERE user_id = ? AND product_id = ?`,
-		userId, productId,
+         WHERE user_id = ? AND product_id = ? AND variant_id = ?`,
+		userId, productId, variantId,
 	)
 	if err != nil {
 		return fmt.Errorf("removing cart item: %w", err)
@@ -69,16 +71,79 @@ func (r *CartRepo) Remove(userId, productId int64) error {
 	return nil
 }
 
-// GetCart returns all cart items with their associated product data.
-func (r *CartRepo) GetCart(userId int64) ([]models.CartItem, error) {
-	rows, err := r.db.Query(
-		`SELECT ci.id, ci.user_id, ci.product_id, ci.quantity, ci.created_at,
-                p.id, p.name, p.description, p.price, p.stock, p.image_url
+// Quantity returns the quantity of a cart line, or 0 when it is not in the cart.
+func (r *CartRepo) Quantity(userId, productId, variantId int64) (int, error) {
+	var qty int
+	err := r.db.QueryRow(
+		`SELECT quantity FROM cart_items WHERE user_id = ? AND product_id = ? AND variant_id = ?`,
+		userId, productId, variantId,
+	).Scan(&qty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("querying cart quantity: %w", err)
+	}
+
+	return qty, nil
+}
+
+// cartQuery selects cart items with product, optional variant and active
+// reservation data, in scanCartItem order.
+const cartQuery = `SELECT ci.id, ci.user_id, ci.product_id, ci.variant_id, ci.quantity, ci.created_at,
+                p.id, p.name, p.description, p.price, p.stock, p.image_url, p.category_id,
+                v.id, v.sku, v.size, v.color, v.price, v.stock, sr.expires_at
          FROM cart_items ci
          JOIN products p ON p.id = ci.product_id
+         LEFT JOIN product_variants v ON v.id = ci.variant_id AND ci.variant_id > 0
+         LEFT JOIN stock_reservations sr
+                ON sr.user_id = ci.user_id AND sr.product_id = ci.product_id
+               AND sr.variant_id = ci.variant_id AND sr.expires_at > datetime('now')
          WHERE ci.user_id = ?
-         ORDER BY ci.created_at DESC`, userId,
+         ORDER BY ci.created_at DESC, ci.id DESC`
+
+// scanCartItem scans a row of cartQuery and sets the unit price from the variant when there is one.
+func scanCartItem(rows *sql.Rows) (*models.CartItem, error) {
+	var (
+		ci         models.CartItem
+		p          models.Product
+		categoryId sql.NullInt64
+		vId        sql.NullInt64
+		vSKU       sql.NullString
+		vSize      sql.NullString
+		vColor     sql.NullString
+		vPrice     sql.NullInt64
+		vStock     sql.NullInt64
+		reserved   sql.NullTime
 	)
+	if err := rows.Scan(
+		&ci.ID, &ci.UserId, &ci.ProductId, &ci.VariantId, &ci.Quantity, &ci.CreatedAt,
+		&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.ImageURL, &categoryId,
+		&vId, &vSKU, &vSize, &vColor, &vPrice, &vStock, &reserved,
+	); err != nil {
+		return nil, err
+	}
+	if categoryId.Valid {
+		p.CategoryId = &categoryId.Int64
+	}
+	if reserved.Valid {
+		ci.ReservedUntil = &reserved.Time
+	}
+	ci.Product = &p
+	ci.UnitPrice = p.Price
+	if vId.Valid {
+		ci.Variant = &models.ProductVariant{
+			ID: vId.Int64, ProductId: p.ID, SKU: vSKU.String, Size: vSize.String,
+			Color: vColor.String, Price: vPrice.Int64, Stock: int(vStock.Int64),
+		}
+		ci.UnitPrice = vPrice.Int64
+	}
+	return &ci, nil
+}
+
+// GetCart returns all cart items with their associated product and variant data.
+func (r *CartRepo) GetCart(userId int64) ([]models.CartItem, error) {
+	rows, err := r.db.Query(cartQuery, userId)
 	if err != nil {
 		return nil, fmt.Errorf("querying cart: %w", err)
 	}
@@ -86,24 +151,23 @@ func (r *CartRepo) GetCart(userId int64) ([]models.CartItem, error) {
 
 	var items []models.CartItem
 	for rows.Next() {
-		var ci models.CartItem
-		var p models.Product
-		if err := rows.Scan(
-			&ci.ID, &ci.UserId, &ci.ProductId, &ci.Quantity, &ci.CreatedAt,
-			&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.ImageURL,
-		); err != nil {
+		ci, err := scanCartItem(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scanning cart item: %w", err)
 		}
-		ci.Product = &p
-		items = append(items, ci)
+		items = append(items, *ci)
 	}
 
 	return items, rows.Err()
 }
 
 // ClearCart removes all items for a user. Used after checkout.
-func (r *CartRepo) ClearCart(tx *sql.Tx, userId int64) error {
-	_, err := tx.Exec("DELETE FROM cart_items WHERE user_id = ?", userId)
+func (r *CartRepo) ClearCart(tx Tx, userId int64) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	_, err = stx.Exec("DELETE FROM cart_items WHERE user_id = ?", userId)
 	if err != nil {
 		return fmt.Errorf("clearing cart: %w", err)
 	}
@@ -111,14 +175,13 @@ func (r *CartRepo) ClearCart(tx *sql.Tx, userId int64) error {
 	return nil
 }
 
-// GetCartTx returns all cart items with their associated product data from a transaction.
-func (r *CartRepo) GetCartTx(tx *sql.Tx, userId int64) ([]models.CartItem, error) {
-	rows, err := tx.Query(
-		`SELECT ci.product_id, ci.quantity, p.price, p.stock
-         FROM cart_items ci
-         JOIN products p ON p.id = ci.product_id
-         WHERE ci.user_id = ?`, userId,
-	)
+// GetCartTx returns all cart items with their associated product and variant data from a transaction.
+func (r *CartRepo) GetCartTx(tx Tx, userId int64) ([]models.CartItem, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stx.Query(cartQuery, userId)
 	if err != nil {
 		return nil, fmt.Errorf("querying cart: %w", err)
 	}
@@ -126,13 +189,11 @@ func (r *CartRepo) GetCartTx(tx *sql.Tx, userId int64) ([]models.CartItem, error
 
 	var items []models.CartItem
 	for rows.Next() {
-		var ci models.CartItem
-		var p models.Product
-		if err := rows.Scan(&ci.ProductId, &ci.Quantity, &p.Price, &p.Stock); err != nil {
+		ci, err := scanCartItem(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scanning cart item: %w", err)
 		}
-		ci.Product = &p
-		items = append(items, ci)
+		items = append(items, *ci)
 	}
 
 	return items, rows.Err()
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+)
+
+// CategoryRepo is the repository for product categories
+type CategoryRepo struct {
+	db *sql.DB
+}
+
+// NewCategoryRepo creates a new instance of CategoryRepo
+func NewCategoryRepo(db *sql.DB) *CategoryRepo {
+	return &CategoryRepo{db: db}
+}
+
+// Create creates a new category
+func (r *CategoryRepo) Create(req *models.CategoryRequest) (*models.Category, error) {
+	res, err := r.db.Exec(
+		`INSERT INTO categories (parent_id, name, slug) VALUES (?, ?, ?)`,
+		req.ParentId, req.Name, req.Slug,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("%w: slug %s is taken", models.ErrConflict, req.Slug)
+		}
+		return nil, fmt.Errorf("creating category: %w", err)
+	}
+
+	id, _ := res.LastInsertId()
+
+	return r.FindById(id)
+}
+
+// Update replaces the name, slug and parent of a category
+func (r *CategoryRepo) Update(id int64, req *models.CategoryRequest) (*models.Category, error) {
+	res, err := r.db.Exec(
+		`UPDATE categories SET parent_id = ?, name = ?, slug = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		req.ParentId, req.Name, req.Slug, id,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("%w: slug %s is taken", models.ErrConflict, req.Slug)
+		}
+		return nil, fmt.Errorf("updating category: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, models.ErrNotFound
+	}
+
+	return r.FindById(id)
+}
+
+// Delete deletes a category. Its products become uncategorised.
+func (r *CategoryRepo) Delete(id int64) error {
+	res, err := r.db.Exec("DELETE FROM categories WHERE id = ?", id)
+	if err != nil {
+		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+			return fmt.Errorf("%w: category has subcategories", models.ErrConflict)
+		}
+		return fmt.Errorf("deleting category: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// FindById finds a category by its ID
+func (r *CategoryRepo) FindById(id int64) (*models.Category, error) {
+	return r.findOne(`SELECT id, parent_id, name, slug, created_at FROM categories WHERE id = ?`, id)
+}
+
+// FindBySlug finds a category by its slug
+func (r *CategoryRepo) FindBySlug(slug string) (*models.Category, error) {
+	return r.findOne(`SELECT id, parent_id, name, slug, created_at FROM categories WHERE slug = ?`, slug)
+}
+
+func (r *CategoryRepo) findOne(query string, arg any) (*models.Category, error) {
+	c := &models.Category{}
+	var parentId sql.NullInt64
+	err := r.db.QueryRow(query, arg).Scan(&c.ID, &parentId, &c.Name, &c.Slug, &c.CreatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying category: %w", err)
+	}
+	if parentId.Valid {
+		c.ParentId = &parentId.Int64
+	}
+
+	return c, nil
+}
+
+// List returns all categories as a flat list ordered by name
+func (r *CategoryRepo) List() ([]models.Category, error) {
+	rows, err := r.db.Query(`SELECT id, parent_id, name, slug, created_at FROM categories ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("listing categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var c models.Category
+		var parentId sql.NullInt64
+		if err := rows.Scan(&c.ID, &parentId, &c.Name, &c.Slug, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning category: %w", err)
+		}
+		if parentId.Valid {
+			c.ParentId = &parentId.Int64
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+)
+
+// InventoryRepo is the repository for stock reservations and the inventory ledger
+type InventoryRepo struct {
+	db *sql.DB
+}
+
+// NewInventoryRepo creates a new instance of InventoryRepo
+func NewInventoryRepo(db *sql.DB) *InventoryRepo {
+	return &InventoryRepo{db: db}
+}
+
+// BeginTx exposes the DB's transaction capability to the service layer.
+func (r *InventoryRepo) BeginTx() (Tx, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err // avoid returning a non-nil Tx holding a nil *sql.Tx
+	}
+	return tx, nil
+}
+
+// ── Reservations
+
+// activeReservation matches reservations that still hold stock. Expiry times
+// are stored in SQLite's own format so they compare as text.
+const activeReservation = `expires_at > datetime('now')`
+
+// Available returns the stock of a product (or of a variant when variantId > 0)
+// minus the units held by other users' active reservations.
+func (r *InventoryRepo) Available(tx Tx, productId, variantId, userId int64) (int, error) {
+	stockQuery := `SELECT stock FROM products WHERE id = ?`
+	stockArg := productId
+	if variantId > 0 {
+		stockQuery = `SELECT stock FROM product_variants WHERE id = ?`
+		stockArg = variantId
+	}
+
+	var available int
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return 0, err
+	}
+	err = stx.QueryRow(
+		`SELECT (`+stockQuery+`) - COALESCE((
+             SELECT SUM(quantity) FROM stock_reservations
+             WHERE product_id = ? AND variant_id = ? AND user_id <> ? AND `+activeReservation+`
+         ), 0)`,
+		stockArg, productId, variantId, userId,
+	).Scan(&available)
+	if err != nil {
+		return 0, fmt.Errorf("querying available stock: %w", err)
+	}
+
+	return available, nil
+}
+
+// Reserve sets the units a user holds for a cart line and restarts its expiry.
+func (r *InventoryRepo) Reserve(tx Tx, userId, productId, variantId int64, qty int, ttl time.Duration) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	_, err = stx.Exec(
+		`INSERT INTO stock_reservations (user_id, product_id, variant_id, quantity, expires_at)
+         VALUES (?, ?, ?, ?, datetime('now', ?))
+         ON CONFLICT(user_id, product_id, variant_id)
+         DO UPDATE SET quantity = excluded.quantity, expires_at = excluded.expires_at`,
+		userId, productId, variantId, qty, fmt.Sprintf("+%d seconds", int(ttl.Seconds())),
+	)
+	if err != nil {
+		return fmt.Errorf("reserving stock: %w", err)
+	}
+
+	return nil
+}
+
+// Release drops the reservation of one cart line, if any.
+func (r *InventoryRepo) Release(userId, productId, variantId int64) error {
+	_, err := r.db.Exec(
+		`DELETE FROM stock_reservations WHERE user_id = ? AND product_id = ? AND variant_id = ?`,
+		userId, productId, variantId,
+	)
+	if err != nil {
+		return fmt.Errorf("releasing reservation: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseUser drops every reservation of a user. Used when checkout turns them into a sale.
+func (r *InventoryRepo) ReleaseUser(tx Tx, userId int64) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	if _, err = stx.Exec(`DELETE FROM stock_reservations WHERE user_id = ?`, userId); err != nil {
+		return fmt.Errorf("releasing reservations: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes reservations whose hold has ended and returns how many were removed.
+func (r *InventoryRepo) DeleteExpired() (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM stock_reservations WHERE NOT (` + activeReservation + `)`)
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired reservations: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+// ── Ledger
+
+// RecordMovement appends a movement to the ledger. The stock after the change
+// is read from the product or variant, so call it after updating the stock.
+func (r *InventoryRepo) RecordMovement(tx Tx, m *models.InventoryMovement) error {
+	stockQuery := `SELECT stock FROM products WHERE id = ?`
+	stockArg := m.ProductId
+	if m.VariantId > 0 {
+		stockQuery = `SELECT stock FROM product_variants WHERE id = ?`
+		stockArg = m.VariantId
+	}
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	if err = stx.QueryRow(stockQuery, stockArg).Scan(&m.StockAfter); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNotFound
+		}
+		return fmt.Errorf("reading stock: %w", err)
+	}
+
+	res, err := stx.Exec(
+		`INSERT INTO inventory_movements (product_id, variant_id, type, quantity, stock_after, order_id, user_id, note)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.ProductId, m.VariantId, m.Type, m.Quantity, m.StockAfter, m.OrderId, m.UserId, m.Note,
+	)
+	if err != nil {
+		return fmt.Errorf("recording inventory movement: %w", err)
+	}
+	m.ID, _ = res.LastInsertId()
+	m.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	return nil
+}
+
+// ListMovements returns ledger entries matching q, newest first
+func (r *InventoryRepo) ListMovements(q models.MovementQuery) ([]models.InventoryMovement, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	if q.ProductId > 0 {
+		conds = append(conds, "product_id = ?")
+		args = append(args, q.ProductId)
+	}
+	if q.VariantId > 0 {
+		conds = append(conds, "variant_id = ?")
+		args = append(args, q.VariantId)
+	}
+	if q.Type != "" {
+		conds = append(conds, "type = ?")
+		args = append(args, q.Type)
+	}
+
+	query := `SELECT id, product_id, variant_id, type, quantity, stock_after, order_id, user_id, note, created_at
+              FROM inventory_movements`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, q.Limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing inventory movements: %w", err)
+	}
+	defer rows.Close()
+
+	movements := []models.InventoryMovement{}
+	for rows.Next() {
+		var (
+			m       models.InventoryMovement
+			orderId sql.NullInt64
+			userId  sql.NullInt64
+		)
+		if err := rows.Scan(&m.ID, &m.ProductId, &m.VariantId, &m.Type, &m.Quantity, &m.StockAfter,
+			&orderId, &userId, &m.Note, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning inventory movement: %w", err)
+		}
+		if orderId.Valid {
+			m.OrderId = &orderId.Int64
+		}
+		if userId.Valid {
+			m.UserId = &userId.Int64
+		}
+		movements = append(movements, m)
+	}
+
+	return movements, rows.Err()
+}
+
+// ── Stock levels
+
+// LowStock returns products without variants, and variants, whose stock on
+// hand is at or below threshold, lowest first.
+func (r *InventoryRepo) LowStock(threshold int) ([]models.StockLevel, error) {
+	rows, err := r.db.Query(
+		`SELECT product_id, variant_id, name, sku, stock, reserved FROM (
+             SELECT p.id AS product_id, 0 AS variant_id, p.name, '' AS sku, p.stock,
+                    COALESCE((SELECT SUM(quantity) FROM stock_reservations
+                              WHERE product_id = p.id AND variant_id = 0 AND `+activeReservation+`), 0) AS reserved
+             FROM products p
+             WHERE NOT EXISTS (SELECT 1 FROM product_variants WHERE product_id = p.id)
+             UNION ALL
+             SELECT v.product_id, v.id, p.name, v.sku, v.stock,
+                    COALESCE((SELECT SUM(quantity) FROM stock_reservations
+                              WHERE variant_id = v.id AND `+activeReservation+`), 0)
+             FROM product_variants v
+             JOIN products p ON p.id = v.product_id
+         )
+         WHERE stock <= ?
+         ORDER BY stock, name, sku`,
+		threshold,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing low stock: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []models.StockLevel{}
+	for rows.Next() {
+		var l models.StockLevel
+		if err := rows.Scan(&l.ProductId, &l.VariantId, &l.Name, &l.SKU, &l.Stock, &l.Reserved); err != nil {
+			return nil, fmt.Errorf("scanning stock level: %w", err)
+		}
+		l.Available = max(l.Stock-l.Reserved, 0)
+		levels = append(levels, l)
+	}
+
+	return levels, rows.Err()
+}
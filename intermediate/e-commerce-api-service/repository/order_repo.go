@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
 )
@@ -18,11 +20,51 @@ func NewOrderRepo(db *sql.DB) *OrderRepo {
 	return &OrderRepo{db: db}
 }
 
-// CreateOrder creates a new order in the database
-func (r *OrderRepo) CreateOrder(tx *sql.Tx, userId int64, total int64, stripeID string) (int64, error) {
-	res, err := tx.Exec(
-		`INSERT INTO orders (user_id, total, status, stripe_payment_id) VALUES (?, ?, 'pending', ?)`,
-		userId, total, stripeID,
+// orderColumns lists the order columns read by orderFields, in scan order.
+const orderColumns = `id, user_id, status, stripe_payment_id, created_at,
+    subtotal, discount_total, coupon_code, coupon_discount, shipping_fee,
+    tax_region, tax_rate_bps, tax_total, total,
+    ship_name, ship_line1, ship_line2, ship_city, ship_region, ship_postal_code, ship_country, ship_phone`
+
+// orderFields returns the scan destinations matching orderColumns.
+// Call clearEmptyAddress after scanning.
+func orderFields(o *models.Order) []any {
+	o.ShippingAddress = &models.ShippingAddress{}
+	a := o.ShippingAddress
+	return []any{
+		&o.ID, &o.UserId, &o.Status, &o.StripePaymentId, &o.CreatedAt,
+		&o.Subtotal, &o.Discount, &o.CouponCode, &o.CouponDiscount, &o.Shipping,
+		&o.TaxRegion, &o.TaxRateBps, &o.Tax, &o.Total,
+		&a.Name, &a.Line1, &a.Line2, &a.City, &a.Region, &a.PostalCode, &a.Country, &a.Phone,
+	}
+}
+
+// clearEmptyAddress drops the shipping address of orders placed before
+// addresses were captured.
+func clearEmptyAddress(o *models.Order) {
+	if o.ShippingAddress != nil && o.ShippingAddress.Line1 == "" {
+		o.ShippingAddress = nil
+	}
+}
+
+// CreateOrder creates a new pending order with its price breakdown and a copy
+// of the shipping address in the database.
+// The payment ID is attached later with SetPaymentId.
+func (r *OrderRepo) CreateOrder(tx Tx, userId int64, b *models.PriceBreakdown, addr *models.ShippingAddress) (int64, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return 0, err
+	}
+	res, err := stx.Exec(
+		`INSERT INTO orders (user_id, total, status, subtotal, discount_total, coupon_code,
+                             coupon_discount, shipping_fee, tax_region, tax_rate_bps, tax_total,
+                             ship_name, ship_line1, ship_line2, ship_city, ship_region,
+                             ship_postal_code, ship_country, ship_phone)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userId, b.Total, models.OrderPending, b.Subtotal, b.Discount, b.CouponCode,
+		b.CouponDiscount, b.Shipping, b.TaxRegion, b.TaxRateBps, b.Tax,
+		addr.Name, addr.Line1, addr.Line2, addr.City, addr.Region,
+		addr.PostalCode, addr.Country, addr.Phone,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("creating order: %w", err)
@@ -31,30 +73,125 @@ func (r *OrderRepo) CreateOrder(tx *sql.Tx, userId int64, total int64, stripeID
 	return res.LastInsertId()
 }
 
-// CreateOrderItem creates a new order item in the database
-func (r *OrderRepo) CreateOrderItem(tx *sql.Tx, orderID, productID int64, qty int, price int64) error {
-	_, err := tx.Exec(
-		`INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (?, ?, ?, ?)`,
-		orderID, productID, qty, price,
+// CreateOrderItem creates a new order item in the database from a cart line.
+// The unit price, line discount and variant SKU are snapshotted.
+func (r *OrderRepo) CreateOrderItem(tx Tx, orderID int64, item *models.CartItem) error {
+	var sku string
+	if item.Variant != nil {
+		sku = item.Variant.SKU
+	}
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	_, err = stx.Exec(
+		`INSERT INTO order_items (order_id, product_id, variant_id, sku, quantity, price, discount)
+         VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		orderID, item.ProductId, item.VariantId, sku, item.Quantity, item.UnitPrice, item.Discount,
 	)
 
 	return err
 }
 
-// UpdateStatus updates the status of an order
-func (r *OrderRepo) UpdateStatus(orderId int64, status string) error {
-	_, err := r.db.Exec("UPDATE orders SET status = ? WHERE id = ?", status, orderId)
+// SetPaymentId links an order to the payment created by the payment provider.
+func (r *OrderRepo) SetPaymentId(tx Tx, orderId int64, paymentId string) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	_, err = stx.Exec(
+		`UPDATE orders SET stripe_payment_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		paymentId, orderId,
+	)
+	if err != nil {
+		return fmt.Errorf("setting payment id: %w", err)
+	}
 
-	return err
+	return nil
+}
+
+// UpdateStatusTx moves an order from one status to another inside a transaction.
+// The WHERE clause on the current status guards against concurrent updates.
+func (r *OrderRepo) UpdateStatusTx(tx Tx, orderId int64, from, to models.OrderStatus) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	res, err := stx.Exec(
+		`UPDATE orders SET status = ?, updated_at = CURRENT_TIMESTAMP
+         WHERE id = ? AND status = ?`,
+		to, orderId, from,
+	)
+	if err != nil {
+		return fmt.Errorf("updating order status: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: order %d is no longer %s", models.ErrConflict, orderId, from)
+	}
+
+	return nil
+}
+
+// FindByIdTx retrieves an order and its items (without product details) inside a transaction.
+func (r *OrderRepo) FindByIdTx(tx Tx, orderId int64) (*models.Order, error) {
+	o := &models.Order{}
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	err = stx.QueryRow(
+		`SELECT `+orderColumns+` FROM orders WHERE id = ?`, orderId,
+	).Scan(orderFields(o)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	clearEmptyAddress(o)
+
+	rows, err := stx.Query(
+		`SELECT id, order_id, product_id, variant_id, quantity, price, discount
+         FROM order_items WHERE order_id = ?`, orderId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderId, &item.ProductId, &item.VariantId,
+			&item.Quantity, &item.Price, &item.Discount); err != nil {
+			return nil, err
+		}
+		o.Items = append(o.Items, item)
+	}
+
+	return o, rows.Err()
+}
+
+// FindIdByPaymentId returns the ID of the order linked to a Stripe PaymentIntent.
+func (r *OrderRepo) FindIdByPaymentId(paymentId string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(
+		`SELECT id FROM orders WHERE stripe_payment_id = ?`, paymentId,
+	).Scan(&id)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, models.ErrNotFound
+	}
+
+	return id, err
 }
 
 // FindById retrieves an order by its ID
 func (r *OrderRepo) FindById(orderId int64) (*models.Order, error) {
 	o := &models.Order{}
 	err := r.db.QueryRow(
-		`SELECT id, user_id, total, status, stripe_payment_id, created_at
-         FROM orders WHERE id = ?`, orderId,
-	).Scan(&o.ID, &o.UserId, &o.Total, &o.Status, &o.StripePaymentId, &o.CreatedAt)
+		`SELECT `+orderColumns+` FROM orders WHERE id = ?`, orderId,
+	).Scan(orderFields(o)...)
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, models.ErrNotFound
@@ -62,10 +199,11 @@ func (r *OrderRepo) FindById(orderId int64) (*models.Order, error) {
 	if err != nil {
 		return nil, err
 	}
+	clearEmptyAddress(o)
 
 	// Load order items
 	rows, err := r.db.Query(
-		`SELECT oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price,
+		`SELECT oi.id, oi.order_id, oi.product_id, oi.variant_id, oi.sku, oi.quantity, oi.price, oi.discount,
                 p.name, p.description, p.image_url
          FROM order_items oi
          JOIN products p ON p.id = oi.product_id
@@ -79,8 +217,8 @@ func (r *OrderRepo) FindById(orderId int64) (*models.Order, error) {
 	for rows.Next() {
 		var item models.OrderItem
 		var p models.Product
-		if err := rows.Scan(&item.ID, &item.OrderId, &item.ProductId,
-			&item.Quantity, &item.Price, &p.Name, &p.Description, &p.ImageURL); err != nil {
+		if err := rows.Scan(&item.ID, &item.OrderId, &item.ProductId, &item.VariantId, &item.SKU,
+			&item.Quantity, &item.Price, &item.Discount, &p.Name, &p.Description, &p.ImageURL); err != nil {
 			return nil, err
 		}
 		p.ID = item.ProductId
@@ -95,8 +233,7 @@ func (r *OrderRepo) FindById(orderId int64) (*models.Order, error) {
 // ListByUser retrieves all orders for a given user ID
 func (r *OrderRepo) ListByUser(userId int64) ([]models.Order, error) {
 	rows, err := r.db.Query(
-		`SELECT id, user_id, total, status, stripe_payment_id, created_at
-         FROM orders WHERE user_id = ? ORDER BY created_at DESC`, userId,
+		`SELECT `+orderColumns+` FROM orders WHERE user_id = ? ORDER BY created_at DESC`, userId,
 	)
 	if err != nil {
 		return nil, err
@@ -106,17 +243,210 @@ func (r *OrderRepo) ListByUser(userId int64) ([]models.Order, error) {
 	var orders []models.Order
 	for rows.Next() {
 		var o models.Order
-		if err := rows.Scan(&o.ID, &o.UserId, &o.Total, &o.Status,
-			&o.StripePaymentId, &o.CreatedAt); err != nil {
+		if err := rows.Scan(orderFields(&o)...); err != nil {
 			return nil, err
 		}
+		clearEmptyAddress(&o)
 		orders = append(orders, o)
 	}
 
 	return orders, nil
 }
 
+// salesStatuses are the order statuses counted as sales in reports.
+const salesStatuses = `('paid', 'shipped', 'delivered')`
+
+// ListAll retrieves a page of orders across all users, newest first, with the
+// customer's email and the total number of matches.
+// A zero limit returns every match.
+func (r *OrderRepo) ListAll(q models.OrderQuery) ([]models.Order, int, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	if q.Status != "" {
+		conds = append(conds, "status = ?")
+		args = append(args, q.Status)
+	}
+	if q.UserId > 0 {
+		conds = append(conds, "user_id = ?")
+		args = append(args, q.UserId)
+	}
+	if q.Email != "" {
+		conds = append(conds, "user_id IN (SELECT id FROM users WHERE email LIKE ?)")
+		args = append(args, "%"+q.Email+"%")
+	}
+	if !q.From.IsZero() {
+		conds = append(conds, "date(created_at) >= ?")
+		args = append(args, q.From.Format(time.DateOnly))
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, "date(created_at) <= ?")
+		args = append(args, q.To.Format(time.DateOnly))
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM orders`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting orders: %w", err)
+	}
+
+	query := `SELECT ` + orderColumns + `, COALESCE((SELECT email FROM users WHERE users.id = orders.user_id), '')
+              FROM orders` + where + ` ORDER BY created_at DESC, id DESC`
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, (q.Page-1)*q.Limit)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("listing orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		var o models.Order
+		if err := rows.Scan(append(orderFields(&o), &o.UserEmail)...); err != nil {
+			return nil, 0, fmt.Errorf("scanning order: %w", err)
+		}
+		clearEmptyAddress(&o)
+		orders = append(orders, o)
+	}
+
+	return orders, total, rows.Err()
+}
+
+// SalesByDay returns the number of sales and their revenue for each day
+// between from and to (inclusive) that had at least one sale.
+func (r *OrderRepo) SalesByDay(from, to time.Time) ([]models.DailySales, error) {
+	rows, err := r.db.Query(
+		`SELECT date(created_at) AS day, COUNT(*), COALESCE(SUM(total), 0)
+         FROM orders
+         WHERE status IN `+salesStatuses+` AND date(created_at) BETWEEN ? AND ?
+         GROUP BY day ORDER BY day`,
+		from.Format(time.DateOnly), to.Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying daily sales: %w", err)
+	}
+	defer rows.Close()
+
+	var days []models.DailySales
+	for rows.Next() {
+		var d models.DailySales
+		if err := rows.Scan(&d.Date, &d.Orders, &d.Revenue); err != nil {
+			return nil, fmt.Errorf("scanning daily sales: %w", err)
+		}
+		days = append(days, d)
+	}
+
+	return days, rows.Err()
+}
+
+// TopProducts returns the best-selling products by line revenue between
+// from and to (inclusive).
+func (r *OrderRepo) TopProducts(from, to time.Time, limit int) ([]models.ProductSales, error) {
+	rows, err := r.db.Query(
+		`SELECT oi.product_id, COALESCE(p.name, ''), SUM(oi.quantity),
+                SUM(oi.price * oi.quantity - oi.discount) AS revenue
+         FROM order_items oi
+         JOIN orders o ON o.id = oi.order_id
+         LEFT JOIN products p ON p.id = oi.product_id
+         WHERE o.status IN `+salesStatuses+` AND date(o.created_at) BETWEEN ? AND ?
+         GROUP BY oi.product_id
+         ORDER BY revenue DESC, oi.product_id
+         LIMIT ?`,
+		from.Format(time.DateOnly), to.Format(time.DateOnly), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying top products: %w", err)
+	}
+	defer rows.Close()
+
+	products := []models.ProductSales{}
+	for rows.Next() {
+		var p models.ProductSales
+		if err := rows.Scan(&p.ProductId, &p.Name, &p.Quantity, &p.Revenue); err != nil {
+			return nil, fmt.Errorf("scanning top product: %w", err)
+		}
+		products = append(products, p)
+	}
+
+	return products, rows.Err()
+}
+
+// CreateCheckoutKey reserves an idempotency key for a checkout inside its transaction.
+func (r *OrderRepo) CreateCheckoutKey(tx Tx, userId int64, key string, orderId int64) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	_, err = stx.Exec(
+		`INSERT INTO checkout_idempotency_keys (user_id, idempotency_key, order_id) VALUES (?, ?, ?)`,
+		userId, key, orderId,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return models.ErrConflict
+		}
+		return fmt.Errorf("saving idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// FindCheckoutKey returns the stored checkout response for an idempotency key.
+// The response is empty while the original request is still in flight.
+func (r *OrderRepo) FindCheckoutKey(userId int64, key string) (string, error) {
+	var response string
+	err := r.db.QueryRow(
+		`SELECT response FROM checkout_idempotency_keys WHERE user_id = ? AND idempotency_key = ?`,
+		userId, key,
+	).Scan(&response)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", models.ErrNotFound
+	}
+
+	return response, err
+}
+
+// SaveCheckoutResponse stores the response to replay for an idempotency key.
+func (r *OrderRepo) SaveCheckoutResponse(tx Tx, userId int64, key, response string) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	_, err = stx.Exec(
+		`UPDATE checkout_idempotency_keys SET response = ? WHERE user_id = ? AND idempotency_key = ?`,
+		response, userId, key,
+	)
+	if err != nil {
+		return fmt.Errorf("saving checkout response: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteCheckoutKey releases an idempotency key after a failed checkout so it can be retried.
+func (r *OrderRepo) DeleteCheckoutKey(userId int64, key string) error {
+	_, err := r.db.Exec(
+		`DELETE FROM checkout_idempotency_keys WHERE user_id = ? AND idempotency_key = ?`,
+		userId, key,
+	)
+
+	return err
+}
+
 // BeginTx exposes the DB's transaction capability to the service layer.
-func (r *OrderRepo) BeginTx() (*sql.Tx, error) {
-	return r.db.Begin()
+func (r *OrderRepo) BeginTx() (Tx, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err // avoid returning a non-nil Tx holding a nil *sql.Tx
+	}
+	return tx, nil
 }
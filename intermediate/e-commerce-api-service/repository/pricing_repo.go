@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+)
+
+// PricingRepo is the repository for coupons, discounts and tax rates
+type PricingRepo struct {
+	db *sql.DB
+}
+
+// NewPricingRepo creates a new instance of PricingRepo
+func NewPricingRepo(db *sql.DB) *PricingRepo {
+	return &PricingRepo{db: db}
+}
+
+// ── Coupons
+
+const couponColumns = `id, code, type, value, min_subtotal, max_uses, times_used, expires_at, active, created_at`
+
+// scanCoupon scans a row selected with couponColumns
+func scanCoupon(row interface{ Scan(...any) error }) (*models.Coupon, error) {
+	c := &models.Coupon{}
+	var expiresAt sql.NullTime
+	if err := row.Scan(&c.ID, &c.Code, &c.Type, &c.Value, &c.MinSubtotal,
+		&c.MaxUses, &c.TimesUsed, &expiresAt, &c.Active, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		c.ExpiresAt = &expiresAt.Time
+	}
+	return c, nil
+}
+
+// CreateCoupon creates a new coupon
+func (r *PricingRepo) CreateCoupon(req *models.CouponRequest) (*models.Coupon, error) {
+	active := req.Active == nil || *req.Active
+	res, err := r.db.Exec(
+		`INSERT INTO coupons (code, type, value, min_subtotal, max_uses, expires_at, active)
+         VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		req.Code, req.Type, req.Value, req.MinSubtotal, req.MaxUses, req.ExpiresAt, active,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, models.ErrConflict
+		}
+		return nil, fmt.Errorf("creating coupon: %w", err)
+	}
+
+	id, _ := res.LastInsertId()
+
+	return r.FindCouponById(id)
+}
+
+// UpdateCoupon replaces the settings of a coupon; its usage count is kept
+func (r *PricingRepo) UpdateCoupon(id int64, req *models.CouponRequest) (*models.Coupon, error) {
+	active := req.Active == nil || *req.Active
+	res, err := r.db.Exec(
+		`UPDATE coupons
+         SET code = ?, type = ?, value = ?, min_subtotal = ?, max_uses = ?, expires_at = ?,
+             active = ?, updated_at = CURRENT_TIMESTAMP
+         WHERE id = ?`,
+		req.Code, req.Type, req.Value, req.MinSubtotal, req.MaxUses, req.ExpiresAt, active, id,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, models.ErrConflict
+		}
+		return nil, fmt.Errorf("updating coupon: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, models.ErrNotFound
+	}
+
+	return r.FindCouponById(id)
+}
+
+// DeleteCoupon deletes a coupon by its ID
+func (r *PricingRepo) DeleteCoupon(id int64) error {
+	res, err := r.db.Exec("DELETE FROM coupons WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("deleting coupon: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// FindCouponById finds a coupon by its ID
+func (r *PricingRepo) FindCouponById(id int64) (*models.Coupon, error) {
+	c, err := scanCoupon(r.db.QueryRow(
+		`SELECT `+couponColumns+` FROM coupons WHERE id = ?`, id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying coupon: %w", err)
+	}
+
+	return c, nil
+}
+
+// FindCouponByCode finds a coupon by its (upper-case) code
+func (r *PricingRepo) FindCouponByCode(code string) (*models.Coupon, error) {
+	c, err := scanCoupon(r.db.QueryRow(
+		`SELECT `+couponColumns+` FROM coupons WHERE code = ?`, code,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying coupon: %w", err)
+	}
+
+	return c, nil
+}
+
+// ListCoupons returns all coupons, newest first
+func (r *PricingRepo) ListCoupons() ([]models.Coupon, error) {
+	rows, err := r.db.Query(`SELECT ` + couponColumns + ` FROM coupons ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("listing coupons: %w", err)
+	}
+	defer rows.Close()
+
+	var coupons []models.Coupon
+	for rows.Next() {
+		c, err := scanCoupon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning coupon: %w", err)
+		}
+		coupons = append(coupons, *c)
+	}
+
+	return coupons, rows.Err()
+}
+
+// RedeemCoupon atomically counts one use of a coupon. Used during checkout.
+func (r *PricingRepo) RedeemCoupon(tx Tx, code string) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	res, err := stx.Exec(
+		`UPDATE coupons SET times_used = times_used + 1
+         WHERE code = ? AND active = 1 AND (max_uses = 0 OR times_used < max_uses)`,
+		code,
+	)
+	if err != nil {
+		return fmt.Errorf("redeeming coupon: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: coupon %s is no longer available", models.ErrBadRequest, code)
+	}
+
+	return nil
+}
+
+// ReleaseCoupon gives back a use of a coupon, e.g. when its order is cancelled.
+func (r *PricingRepo) ReleaseCoupon(tx Tx, code string) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	_, err = stx.Exec(
+		`UPDATE coupons SET times_used = times_used - 1 WHERE code = ? AND times_used > 0`,
+		code,
+	)
+	if err != nil {
+		return fmt.Errorf("releasing coupon: %w", err)
+	}
+
+	return nil
+}
+
+// ── Discounts
+
+const discountColumns = `id, name, product_id, category_id, type, value, starts_at, ends_at, active, created_at`
+
+// scanDiscount scans a row selected with discountColumns
+func scanDiscount(row interface{ Scan(...any) error }) (*models.Discount, error) {
+	d := &models.Discount{}
+	var productId, categoryId sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.Name, &productId, &categoryId, &d.Type, &d.Value,
+		&startsAt, &endsAt, &d.Active, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	d.ProductId = productId.Int64
+	d.CategoryId = categoryId.Int64
+	if startsAt.Valid {
+		d.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		d.EndsAt = &endsAt.Time
+	}
+	return d, nil
+}
+
+// CreateDiscount creates a new product or category discount
+func (r *PricingRepo) CreateDiscount(req *models.DiscountRequest) (*models.Discount, error) {
+	var productId, categoryId sql.NullInt64
+	if req.ProductId > 0 {
+		productId = sql.NullInt64{Int64: req.ProductId, Valid: true}
+	}
+	if req.CategoryId > 0 {
+		categoryId = sql.NullInt64{Int64: req.CategoryId, Valid: true}
+	}
+	res, err := r.db.Exec(
+		`INSERT INTO discounts (name, product_id, category_id, type, value, starts_at, ends_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		req.Name, productId, categoryId, req.Type, req.Value, req.StartsAt, req.EndsAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+			return nil, fmt.Errorf("%w: product or category not found", models.ErrBadRequest)
+		}
+		return nil, fmt.Errorf("creating discount: %w", err)
+	}
+
+	id, _ := res.LastInsertId()
+
+	d, err := scanDiscount(r.db.QueryRow(`SELECT `+discountColumns+` FROM discounts WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("querying discount: %w", err)
+	}
+
+	return d, nil
+}
+
+// DeleteDiscount deletes a discount by its ID
+func (r *PricingRepo) DeleteDiscount(id int64) error {
+	res, err := r.db.Exec("DELETE FROM discounts WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("deleting discount: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// ListDiscounts returns all discounts, newest first
+func (r *PricingRepo) ListDiscounts() ([]models.Discount, error) {
+	return r.queryDiscounts(`SELECT ` + discountColumns + ` FROM discounts ORDER BY created_at DESC, id DESC`)
+}
+
+// ActiveDiscounts returns the enabled discounts of the given products and all
+// enabled category discounts. Start/end dates and categories are checked by the caller.
+func (r *PricingRepo) ActiveDiscounts(productIds []int64) ([]models.Discount, error) {
+	if len(productIds) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(productIds)), ",")
+	args := make([]any, len(productIds))
+	for i, id := range productIds {
+		args[i] = id
+	}
+
+	return r.queryDiscounts(
+		`SELECT `+discountColumns+` FROM discounts
+         WHERE active = 1 AND (product_id IN (`+placeholders+`) OR category_id IS NOT NULL)`,
+		args...,
+	)
+}
+
+// queryDiscounts runs a query selecting discountColumns
+func (r *PricingRepo) queryDiscounts(query string, args ...any) ([]models.Discount, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing discounts: %w", err)
+	}
+	defer rows.Close()
+
+	var discounts []models.Discount
+	for rows.Next() {
+		d, err := scanDiscount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning discount: %w", err)
+		}
+		discounts = append(discounts, *d)
+	}
+
+	return discounts, rows.Err()
+}
+
+// ── Tax rates
+
+// ListTaxRates returns all configured tax regions
+func (r *PricingRepo) ListTaxRates() ([]models.TaxRate, error) {
+	rows, err := r.db.Query(`SELECT region, name, rate_bps FROM tax_rates ORDER BY region`)
+	if err != nil {
+		return nil, fmt.Errorf("listing tax rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []models.TaxRate
+	for rows.Next() {
+		var t models.TaxRate
+		if err := rows.Scan(&t.Region, &t.Name, &t.RateBps); err != nil {
+			return nil, fmt.Errorf("scanning tax rate: %w", err)
+		}
+		rates = append(rates, t)
+	}
+
+	return rates, rows.Err()
+}
+
+// FindTaxRate finds the tax rate of a region
+func (r *PricingRepo) FindTaxRate(region string) (*models.TaxRate, error) {
+	t := &models.TaxRate{}
+	err := r.db.QueryRow(
+		`SELECT region, name, rate_bps FROM tax_rates WHERE region = ?`, region,
+	).Scan(&t.Region, &t.Name, &t.RateBps)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying tax rate: %w", err)
+	}
+
+	return t, nil
+}
+
+// UpsertTaxRate creates or replaces the tax rate of a region
+func (r *PricingRepo) UpsertTaxRate(region string, req *models.TaxRateRequest) (*models.TaxRate, error) {
+	_, err := r.db.Exec(
+		`INSERT INTO tax_rates (region, name, rate_bps) VALUES (?, ?, ?)
+         ON CONFLICT(region)
+         DO UPDATE SET name = excluded.name, rate_bps = excluded.rate_bps, updated_at = CURRENT_TIMESTAMP`,
+		region, req.Name, req.RateBps,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("saving tax rate: %w", err)
+	}
+
+	return r.FindTaxRate(region)
+}
+
+// DeleteTaxRate removes a tax region
+func (r *PricingRepo) DeleteTaxRate(region string) error {
+	res, err := r.db.Exec("DELETE FROM tax_rates WHERE region = ?", region)
+	if err != nil {
+		return fmt.Errorf("deleting tax rate: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
@@ -5,27 +5,51 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
 )
 
-// ProductRepo is the repository for products
+// ProductRepo is the repository for products, their variants and images
 type ProductRepo struct {
-	db *sql.DB
+	db  *sql.DB
+	fts bool // products_fts is usable (driver built with -tags sqlite_fts5)
 }
 
-// NewProductRepo creates a new instance of ProductRepo
+// NewProductRepo creates a new instance of ProductRepo.
+// Call it after the migrations so the search index can be detected.
 func NewProductRepo(db *sql.DB) *ProductRepo {
-	return &ProductRepo{db: db}
+	_, err := db.Exec(`SELECT rowid FROM products_fts LIMIT 0`)
+	return &ProductRepo{db: db, fts: err == nil}
+}
+
+// productColumns lists the product columns read by scanProduct, in scan order.
+const productColumns = `p.id, p.name, p.description, p.price, p.stock, p.image_url, p.category_id, p.created_at, p.updated_at`
+
+// scanProduct scans a row selected with productColumns
+func scanProduct(row interface{ Scan(...any) error }) (*models.Product, error) {
+	p := &models.Product{}
+	var categoryId sql.NullInt64
+	if err := row.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock,
+		&p.ImageURL, &categoryId, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if categoryId.Valid {
+		p.CategoryId = &categoryId.Int64
+	}
+	return p, nil
 }
 
 // Create creates a new product
 func (r *ProductRepo) Create(p *models.ProductCreateRequest) (*models.Product, error) {
 	res, err := r.db.Exec(
-		`INSERT INTO products (name, description, price, stock, image_url) VALUES (?, ?, ?, ?, ?)`,
-		p.Name, p.Description, p.Price, p.Stock, p.ImageURL,
+		`INSERT INTO products (name, description, price, stock, image_url, category_id) VALUES (?, ?, ?, ?, ?, ?)`,
+		p.Name, p.Description, p.Price, p.Stock, p.ImageURL, p.CategoryId,
 	)
 	if err != nil {
+		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+			return nil, fmt.Errorf("%w: category not found", models.ErrBadRequest)
+		}
 		return nil, fmt.Errorf("creating product: %w", err)
 	}
 
@@ -34,76 +58,218 @@ func (r *ProductRepo) Create(p *models.ProductCreateRequest) (*models.Product, e
 	return r.FindById(id)
 }
 
-// FindById finds a product by its ID
+// FindById finds a product by its ID, with its variants and image gallery
 func (r *ProductRepo) FindById(id int64) (*models.Product, error) {
-	p := &models.Product{}
-	err := r.db.QueryRow(
-		`SELECT id, name, description, price, stock, image_url, created_at, updated_at FROM products WHERE id = ?`,
-		id,
-	).Scan(
-		&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.ImageURL, &p.CreatedAt, &p.UpdatedAt,
-	)
+	p, err := scanProduct(r.db.QueryRow(
+		`SELECT `+productColumns+` FROM products p WHERE p.id = ?`, id,
+	))
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, models.ErrNotFound
 	}
+	if err != nil {
+		return nil, fmt.Errorf("querying product: %w", err)
+	}
+
+	if p.Variants, err = r.ListVariants(id); err != nil {
+		return nil, err
+	}
+	if p.Images, err = r.ListImages(id); err != nil {
+		return nil, err
+	}
 
 	return p, nil
 }
 
-// List supports filtering, search, and pagination.
+// priceBuckets are the ranges reported in the price facet, in cents
+var priceBuckets = []models.PriceFacet{
+	{Label: "Under $25", Min: 0, Max: 2500},
+	{Label: "$25 to $50", Min: 2500, Max: 5000},
+	{Label: "$50 to $100", Min: 5000, Max: 10000},
+	{Label: "$100 to $200", Min: 10000, Max: 20000},
+	{Label: "$200 & above", Min: 20000, Max: 0},
+}
+
+// searchFilter is one WHERE condition of a product search
+type searchFilter struct {
+	kind string // "match", "category" or "price"
+	cond string
+	args []any
+}
+
+// Search finds products with full-text search (FTS5 when available, LIKE otherwise),
+// category and price filters, sorting and pagination. It also returns the number of
+// matches per category (direct members only) and fills the price facet.
 // All query parameters are safely parameterized — no SQL injection risk.
-func (r *ProductRepo) List(q models.ProductQuery) ([]models.Product, error) {
+func (r *ProductRepo) Search(q models.ProductQuery) (*models.ProductSearchResult, map[int64]int, error) {
 	var (
-		where []string
-		args  []any
+		filters  []searchFilter
+		from     = "products p"
+		rankExpr string
+		rankArgs []any
 	)
 
 	if q.Name != "" {
-		where = append(where, "(name LIKE ? OR description LIKE ?)")
-		s := "%" + q.Name + "%"
-		args = append(args, s, s)
+		if terms := ftsQuery(q.Name); r.fts && terms != "" {
+			from += " JOIN products_fts ON products_fts.rowid = p.id"
+			filters = append(filters, searchFilter{"match", "products_fts MATCH ?", []any{terms}})
+			// Name matches weigh ten times more than description matches
+			rankExpr = "bm25(products_fts, 10.0, 1.0)"
+		} else if !r.fts {
+			s := likeContains(q.Name)
+			filters = append(filters, searchFilter{"match", `(p.name LIKE ? ESCAPE '\' OR p.description LIKE ? ESCAPE '\')`, []any{s, s}})
+			rankExpr = `CASE WHEN p.name LIKE ? ESCAPE '\' THEN 0 ELSE 1 END`
+			rankArgs = []any{s}
+		}
+	}
+	if q.CategoryId > 0 {
+		filters = append(filters, searchFilter{"category",
+			`p.category_id IN (
+                WITH RECURSIVE sub(id) AS (
+                    SELECT ? UNION ALL SELECT c.id FROM categories c JOIN sub ON c.parent_id = sub.id
+                ) SELECT id FROM sub)`,
+			[]any{q.CategoryId}})
 	}
 	if q.MinPrice > 0 {
-		where = append(where, "price >= ?")
-		args = append(args, q.MinPrice)
+		filters = append(filters, searchFilter{"price", "p.price >= ?", []any{q.MinPrice}})
 	}
 	if q.MaxPrice > 0 {
-		where = append(where, "price <= ?")
-		args = append(args, q.MaxPrice)
+		filters = append(filters, searchFilter{"price", "p.price <= ?", []any{q.MaxPrice}})
 	}
 
-	query := "SELECT id, name, description, price, stock, image_url, created_at, updated_at FROM products"
-	if len(where) > 0 {
-		query += " WHERE " + strings.Join(where, " AND ")
+	// where joins every filter except the given kind
+	where := func(except string) (string, []any) {
+		var conds []string
+		var args []any
+		for _, f := range filters {
+			if f.kind == except {
+				continue
+			}
+			conds = append(conds, f.cond)
+			args = append(args, f.args...)
+		}
+		if len(conds) == 0 {
+			return "", nil
+		}
+		return " WHERE " + strings.Join(conds, " AND "), args
 	}
-	query += " ORDER BY created_at DESC"
 
-	if q.Limit <= 0 {
-		q.Limit = 20
+	res := &models.ProductSearchResult{Page: q.Page, Limit: q.Limit, Sort: q.Sort, Items: []models.Product{}}
+
+	// Total
+	cond, args := where("")
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM "+from+cond, args...).Scan(&res.Total); err != nil {
+		return nil, nil, fmt.Errorf("counting products: %w", err)
 	}
-	if q.Page <= 0 {
-		q.Page = 1
+
+	// Page of items
+	var order string
+	switch q.Sort {
+	case models.SortRelevance:
+		if rankExpr != "" {
+			order = rankExpr + ", p.created_at DESC, p.id DESC"
+			args = append(args, rankArgs...)
+		} else {
+			order = "p.created_at DESC, p.id DESC"
+		}
+	case models.SortPriceAsc:
+		order = "p.price ASC, p.id"
+	case models.SortPriceDesc:
+		order = "p.price DESC, p.id"
+	case models.SortName:
+		order = "p.name COLLATE NOCASE, p.id"
+	default:
+		order = "p.created_at DESC, p.id DESC"
 	}
-	offset := (q.Page - 1) * q.Limit
-	query += fmt.Sprintf(" LIMIT %d OFFSET %d", q.Limit, offset)
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT %d OFFSET %d",
+		productColumns, from, cond, order, q.Limit, (q.Page-1)*q.Limit)
 
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("listing products: %w", err)
+		return nil, nil, fmt.Errorf("listing products: %w", err)
 	}
 	defer rows.Close()
 
-	var products []models.Product
 	for rows.Next() {
-		var p models.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price,
-			&p.Stock, &p.ImageURL, &p.CreatedAt, &p.UpdatedAt); err != nil {
-			return nil, fmt.Errorf("scanning product: %w", err)
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scanning product: %w", err)
 		}
-		products = append(products, p)
+		res.Items = append(res.Items, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
 	}
-	return products, rows.Err()
+
+	// Category facet (ignores the category filter)
+	cond, args = where("category")
+	if cond == "" {
+		cond = " WHERE p.category_id IS NOT NULL"
+	} else {
+		cond += " AND p.category_id IS NOT NULL"
+	}
+	catRows, err := r.db.Query("SELECT p.category_id, COUNT(*) FROM "+from+cond+" GROUP BY p.category_id", args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("counting categories: %w", err)
+	}
+	defer catRows.Close()
+
+	categoryCounts := make(map[int64]int)
+	for catRows.Next() {
+		var id int64
+		var n int
+		if err := catRows.Scan(&id, &n); err != nil {
+			return nil, nil, fmt.Errorf("scanning category count: %w", err)
+		}
+		categoryCounts[id] = n
+	}
+	if err := catRows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Price facet (ignores the price filter)
+	sums := make([]string, len(priceBuckets))
+	for i, b := range priceBuckets {
+		c := fmt.Sprintf("p.price >= %d", b.Min)
+		if b.Max > 0 {
+			c += fmt.Sprintf(" AND p.price < %d", b.Max)
+		}
+		sums[i] = fmt.Sprintf("COALESCE(SUM(CASE WHEN %s THEN 1 ELSE 0 END), 0)", c)
+	}
+	cond, args = where("price")
+	counts := make([]int, len(priceBuckets))
+	dest := make([]any, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if err := r.db.QueryRow("SELECT "+strings.Join(sums, ", ")+" FROM "+from+cond, args...).Scan(dest...); err != nil {
+		return nil, nil, fmt.Errorf("counting price ranges: %w", err)
+	}
+	for i, b := range priceBuckets {
+		b.Count = counts[i]
+		res.Facets.Prices = append(res.Facets.Prices, b)
+	}
+
+	return res, categoryCounts, nil
+}
+
+// ftsQuery turns free text into an FTS5 query: every word must match,
+// as a prefix, so "blu shi" finds "Blue Shirt". Punctuation is dropped,
+// which also keeps FTS5 operators out of user input.
+func ftsQuery(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = `"` + w + `"*`
+	}
+	return strings.Join(words, " ")
+}
+
+// likeContains returns a LIKE pattern matching s anywhere, with the LIKE
+// wildcards in s escaped so "100%" matches literally.
+func likeContains(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
 }
 
 // Update updates a product
@@ -131,6 +297,14 @@ func (r *ProductRepo) Update(id int64, req *models.ProductUpdateRequest) (*model
 		sets = append(sets, "image_url = ?")
 		args = append(args, *req.ImageURL)
 	}
+	if req.CategoryId != nil {
+		sets = append(sets, "category_id = ?")
+		if *req.CategoryId > 0 {
+			args = append(args, *req.CategoryId)
+		} else {
+			args = append(args, nil)
+		}
+	}
 
 	if len(sets) == 0 {
 		return r.FindById(id)
@@ -142,6 +316,9 @@ func (r *ProductRepo) Update(id int64, req *models.ProductUpdateRequest) (*model
 	query := fmt.Sprintf("UPDATE products SET %s WHERE id = ?", strings.Join(sets, ", "))
 	res, err := r.db.Exec(query, args...)
 	if err != nil {
+		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+			return nil, fmt.Errorf("%w: category not found", models.ErrBadRequest)
+		}
 		return nil, fmt.Errorf("updating product: %w", err)
 	}
 	if n, _ := res.RowsAffected(); n == 0 {
@@ -165,8 +342,12 @@ func (r *ProductRepo) Delete(id int64) error {
 }
 
 // DecrementStock atomically reduces stock. Used during checkout.
-func (r *ProductRepo) DecrementStock(tx *sql.Tx, productID int64, qty int) error {
-	res, err := tx.Exec(
+func (r *ProductRepo) DecrementStock(tx Tx, productID int64, qty int) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	res, err := stx.Exec(
 		"UPDATE products SET stock = stock - ? WHERE id = ? AND stock >= ?",
 		qty, productID, qty,
 	)
@@ -179,3 +360,227 @@ func (r *ProductRepo) DecrementStock(tx *sql.Tx, productID int64, qty int) error
 
 	return nil
 }
+
+// IncrementStock puts units back into stock, e.g. when a pending order is cancelled.
+func (r *ProductRepo) IncrementStock(tx Tx, productID int64, qty int) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	_, err = stx.Exec(
+		"UPDATE products SET stock = stock + ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		qty, productID,
+	)
+	if err != nil {
+		return fmt.Errorf("incrementing stock: %w", err)
+	}
+
+	return nil
+}
+
+// ── Variants
+
+const variantColumns = `id, product_id, sku, size, color, price, stock, created_at, updated_at`
+
+func scanVariant(row interface{ Scan(...any) error }) (*models.ProductVariant, error) {
+	v := &models.ProductVariant{}
+	err := row.Scan(&v.ID, &v.ProductId, &v.SKU, &v.Size, &v.Color, &v.Price, &v.Stock, &v.CreatedAt, &v.UpdatedAt)
+	return v, err
+}
+
+// ListVariants returns the variants of a product ordered by ID
+func (r *ProductRepo) ListVariants(productId int64) ([]models.ProductVariant, error) {
+	rows, err := r.db.Query(`SELECT `+variantColumns+` FROM product_variants WHERE product_id = ? ORDER BY id`, productId)
+	if err != nil {
+		return nil, fmt.Errorf("listing variants: %w", err)
+	}
+	defer rows.Close()
+
+	var variants []models.ProductVariant
+	for rows.Next() {
+		v, err := scanVariant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning variant: %w", err)
+		}
+		variants = append(variants, *v)
+	}
+
+	return variants, rows.Err()
+}
+
+// FindVariant finds a variant by its ID
+func (r *ProductRepo) FindVariant(id int64) (*models.ProductVariant, error) {
+	v, err := scanVariant(r.db.QueryRow(`SELECT `+variantColumns+` FROM product_variants WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying variant: %w", err)
+	}
+
+	return v, nil
+}
+
+// CreateVariant adds a variant to a product
+func (r *ProductRepo) CreateVariant(productId int64, req *models.VariantRequest) (*models.ProductVariant, error) {
+	res, err := r.db.Exec(
+		`INSERT INTO product_variants (product_id, sku, size, color, price, stock) VALUES (?, ?, ?, ?, ?, ?)`,
+		productId, req.SKU, req.Size, req.Color, req.Price, req.Stock,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("%w: sku %s already exists", models.ErrConflict, req.SKU)
+		}
+		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+			return nil, models.ErrNotFound
+		}
+		return nil, fmt.Errorf("creating variant: %w", err)
+	}
+
+	id, _ := res.LastInsertId()
+
+	return r.FindVariant(id)
+}
+
+// UpdateVariant replaces the fields of a product's variant
+func (r *ProductRepo) UpdateVariant(productId, variantId int64, req *models.VariantRequest) (*models.ProductVariant, error) {
+	res, err := r.db.Exec(
+		`UPDATE product_variants
+         SET sku = ?, size = ?, color = ?, price = ?, stock = ?, updated_at = CURRENT_TIMESTAMP
+         WHERE id = ? AND product_id = ?`,
+		req.SKU, req.Size, req.Color, req.Price, req.Stock, variantId, productId,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("%w: sku %s already exists", models.ErrConflict, req.SKU)
+		}
+		return nil, fmt.Errorf("updating variant: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, models.ErrNotFound
+	}
+
+	return r.FindVariant(variantId)
+}
+
+// DeleteVariant removes a product's variant and any cart lines that hold it
+func (r *ProductRepo) DeleteVariant(productId, variantId int64) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM cart_items WHERE variant_id = ?`, variantId); err != nil {
+		return fmt.Errorf("removing variant from carts: %w", err)
+	}
+	res, err := tx.Exec(`DELETE FROM product_variants WHERE id = ? AND product_id = ?`, variantId, productId)
+	if err != nil {
+		return fmt.Errorf("deleting variant: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = models.ErrNotFound
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DecrementVariantStock atomically reduces the stock of a variant. Used during checkout.
+func (r *ProductRepo) DecrementVariantStock(tx Tx, variantId int64, qty int) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	res, err := stx.Exec(
+		"UPDATE product_variants SET stock = stock - ? WHERE id = ? AND stock >= ?",
+		qty, variantId, qty,
+	)
+	if err != nil {
+		return fmt.Errorf("decrementing variant stock: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrInsufficientStock
+	}
+
+	return nil
+}
+
+// IncrementVariantStock puts units of a variant back into stock.
+func (r *ProductRepo) IncrementVariantStock(tx Tx, variantId int64, qty int) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+	_, err = stx.Exec(
+		"UPDATE product_variants SET stock = stock + ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		qty, variantId,
+	)
+	if err != nil {
+		return fmt.Errorf("incrementing variant stock: %w", err)
+	}
+
+	return nil
+}
+
+// ── Images
+
+// ListImages returns the gallery of a product in display order
+func (r *ProductRepo) ListImages(productId int64) ([]models.ProductImage, error) {
+	rows, err := r.db.Query(
+		`SELECT id, product_id, url, alt_text, position FROM product_images
+         WHERE product_id = ? ORDER BY position, id`, productId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing images: %w", err)
+	}
+	defer rows.Close()
+
+	var images []models.ProductImage
+	for rows.Next() {
+		var img models.ProductImage
+		if err := rows.Scan(&img.ID, &img.ProductId, &img.URL, &img.AltText, &img.Position); err != nil {
+			return nil, fmt.Errorf("scanning image: %w", err)
+		}
+		images = append(images, img)
+	}
+
+	return images, rows.Err()
+}
+
+// AddImage appends an image to a product's gallery
+func (r *ProductRepo) AddImage(productId int64, req *models.ProductImageRequest) (*models.ProductImage, error) {
+	res, err := r.db.Exec(
+		`INSERT INTO product_images (product_id, url, alt_text, position) VALUES (?, ?, ?, ?)`,
+		productId, req.URL, req.AltText, req.Position,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+			return nil, models.ErrNotFound
+		}
+		return nil, fmt.Errorf("adding image: %w", err)
+	}
+
+	id, _ := res.LastInsertId()
+
+	return &models.ProductImage{
+		ID: id, ProductId: productId, URL: req.URL, AltText: req.AltText, Position: req.Position,
+	}, nil
+}
+
+// DeleteImage removes an image from a product's gallery
+func (r *ProductRepo) DeleteImage(productId, imageId int64) error {
+	res, err := r.db.Exec(`DELETE FROM product_images WHERE id = ? AND product_id = ?`, imageId, productId)
+	if err != nil {
+		return fmt.Errorf("deleting image: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
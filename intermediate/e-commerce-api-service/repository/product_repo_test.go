@@ -0,0 +1,42 @@
+package repository
+
+import "testing"
+
+func TestFtsQuery(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"blu shi", `"blu"* "shi"*`},
+		{"  Blue   Shirt ", `"Blue"* "Shirt"*`},
+		{`"organic cotton"`, `"organic"* "cotton"*`},
+		{`say "hi`, `"say"* "hi"*`},
+		{"shirt NOT blue OR red", `"shirt"* "NOT"* "blue"* "OR"* "red"*`},
+		{"name:mug -handle ^tea* (pot)", `"name"* "mug"* "handle"* "tea"* "pot"*`},
+		{"t-shirt", `"t"* "shirt"*`},
+		{"café größe 100%", `"café"* "größe"* "100"*`},
+		{`"*^-:()`, ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ftsQuery(tt.in); got != tt.want {
+			t.Errorf("ftsQuery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLikeContains(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"mug", "%mug%"},
+		{"100%", `%100\%%`},
+		{"tea_pot", `%tea\_pot%`},
+		{`a\b`, `%a\\b%`},
+		{"", "%%"},
+	}
+	for _, tt := range tests {
+		if got := likeContains(tt.in); got != tt.want {
+			t.Errorf("likeContains(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
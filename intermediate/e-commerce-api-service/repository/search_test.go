@@ -0,0 +1,185 @@
+package repository_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/database"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+)
+
+// newSearchCatalog migrates a temporary database holding five products in
+// Home > Kitchen and Clothing. It runs with LIKE search in a default build
+// and with FTS5 under -tags sqlite_fts5.
+func newSearchCatalog(t *testing.T) (*repository.ProductRepo, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=ON")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	stmts := []string{
+		`INSERT INTO categories (id, parent_id, name, slug) VALUES (1, NULL, 'Home', 'home'), (2, 1, 'Kitchen', 'kitchen'), (3, NULL, 'Clothing', 'clothing')`,
+		`INSERT INTO products (id, name, description, price, stock, category_id) VALUES
+			(1, 'Blue Shirt', 'Cotton tee', 1999, 5, 3),
+			(2, 'Blue Mug', 'Ceramic', 1200, 5, 2),
+			(3, 'Striped Shirt', 'Navy and blue stripes', 3500, 5, 3),
+			(4, 'Kettle', 'Boils water 100% faster', 6000, 5, 2),
+			(5, 'Plate', 'Ceramic', 800, 5, 1)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return repository.NewProductRepo(db), db
+}
+
+func TestSearch(t *testing.T) {
+	repo, db := newSearchCatalog(t)
+
+	tests := []struct {
+		name  string
+		query models.ProductQuery
+		want  []int64
+		total int
+	}{
+		{
+			name:  "name matches rank above description matches",
+			query: models.ProductQuery{Name: "blue", Sort: models.SortRelevance},
+			want:  []int64{2, 1, 3},
+		},
+		{
+			name:  "search within a category",
+			query: models.ProductQuery{Name: "blue", CategoryId: 3, Sort: models.SortRelevance},
+			want:  []int64{1, 3},
+		},
+		{
+			name:  "search sorted by price",
+			query: models.ProductQuery{Name: "BLUE", Sort: models.SortPriceAsc},
+			want:  []int64{2, 1, 3},
+		},
+		{
+			name:  "percent sign is not a wildcard",
+			query: models.ProductQuery{Name: "100%"},
+			want:  []int64{4},
+		},
+		{
+			name:  "category includes subcategories",
+			query: models.ProductQuery{CategoryId: 1, Sort: models.SortName},
+			want:  []int64{2, 4, 5},
+		},
+		{
+			name:  "price range",
+			query: models.ProductQuery{MinPrice: 1000, MaxPrice: 2500, Sort: models.SortPriceDesc},
+			want:  []int64{1, 2},
+		},
+		{
+			name:  "newest first, second page",
+			query: models.ProductQuery{Page: 2, Limit: 2},
+			want:  []int64{3, 2},
+			total: 5,
+		},
+	}
+	if database.FTS5Enabled(db) {
+		tests = append(tests, struct {
+			name  string
+			query models.ProductQuery
+			want  []int64
+			total int
+		}{
+			name:  "every word matches as a prefix, in any column",
+			query: models.ProductQuery{Name: "blu shi", Sort: models.SortRelevance},
+			want:  []int64{1, 3},
+		})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			if q.Page == 0 {
+				q.Page, q.Limit = 1, 20
+			}
+			res, _, err := repo.Search(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []int64{}
+			for _, p := range res.Items {
+				got = append(got, p.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got products %v, want %v", got, tt.want)
+			}
+			total := tt.total
+			if total == 0 {
+				total = len(tt.want)
+			}
+			if res.Total != total {
+				t.Errorf("got total %d, want %d", res.Total, total)
+			}
+		})
+	}
+}
+
+// Each facet counts the matches under every filter except its own
+func TestSearchFacets(t *testing.T) {
+	repo, _ := newSearchCatalog(t)
+
+	tests := []struct {
+		name       string
+		query      models.ProductQuery
+		want       []int64
+		categories map[int64]int
+		prices     []int
+	}{
+		{
+			name:       "shirts in Clothing up to 25.00",
+			query:      models.ProductQuery{Name: "shirt", CategoryId: 3, MaxPrice: 2500},
+			want:       []int64{1},
+			categories: map[int64]int{3: 1},  // shirts up to 25.00, in any category
+			prices:     []int{1, 1, 0, 0, 0}, // shirts in Clothing, at any price
+		},
+		{
+			name:       "Kitchen from 50.00",
+			query:      models.ProductQuery{CategoryId: 2, MinPrice: 5000},
+			want:       []int64{4},
+			categories: map[int64]int{2: 1},
+			prices:     []int{1, 0, 1, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			q.Page, q.Limit = 1, 20
+			res, categories, err := repo.Search(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []int64{}
+			for _, p := range res.Items {
+				got = append(got, p.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got products %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(categories, tt.categories) {
+				t.Errorf("got category counts %v, want %v", categories, tt.categories)
+			}
+			prices := make([]int, len(res.Facets.Prices))
+			for i, b := range res.Facets.Prices {
+				prices[i] = b.Count
+			}
+			if !reflect.DeepEqual(prices, tt.prices) {
+				t.Errorf("got price counts %v, want %v", prices, tt.prices)
+			}
+		})
+	}
+}
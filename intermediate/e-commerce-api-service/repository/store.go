@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+)
+
+// The services depend on these interfaces rather than on the SQLite repos,
+// so they can be unit-tested against in-memory fakes and the store can be
+// swapped for another database later.
+
+// Tx is a transaction opened by BeginTx. Methods that take a Tx run inside it,
+// so a checkout can span orders, carts, stock and coupons atomically.
+// The SQLite repos share one database, so a Tx opened by any of them is a
+// *sql.Tx that every other SQLite repo accepts. Stores of other kinds must
+// agree on their own Tx type the same way.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// sqlTx unwraps a transaction opened by one of the SQLite repos. It returns
+// an error for any other Tx instead of panicking.
+func sqlTx(tx Tx) (*sql.Tx, error) {
+	stx, ok := tx.(*sql.Tx)
+	if !ok {
+		return nil, fmt.Errorf("unsupported transaction type %T", tx)
+	}
+	return stx, nil
+}
+
+// UserStore persists user accounts
+type UserStore interface {
+	Create(email, hashedPassword, role string) (*models.User, error)
+	FindById(id int64) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+	UpdatePassword(userId int64, hashedPassword string) error
+}
+
+// TokenStore persists refresh tokens and password reset tokens by hash
+type TokenStore interface {
+	CreateRefreshToken(userId int64, tokenHash, family string, ttl time.Duration) error
+	FindRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(id int64) error
+	RevokeRefreshFamily(family string) error
+	RevokeUserRefreshTokens(userId int64) error
+
+	CreateResetToken(userId int64, tokenHash string, ttl time.Duration) error
+	ConsumeResetToken(tokenHash string) (int64, error)
+	InvalidateResetTokens(userId int64) error
+}
+
+// AddressStore persists users' saved shipping addresses
+type AddressStore interface {
+	List(userId int64) ([]models.Address, error)
+	Find(userId, id int64) (*models.Address, error)
+	FindDefault(userId int64) (*models.Address, error)
+	Create(userId int64, req *models.AddressRequest) (*models.Address, error)
+	Update(userId, id int64, req *models.AddressRequest) (*models.Address, error)
+	Delete(userId, id int64) error
+}
+
+// CategoryStore persists the category tree
+type CategoryStore interface {
+	Create(req *models.CategoryRequest) (*models.Category, error)
+	Update(id int64, req *models.CategoryRequest) (*models.Category, error)
+	Delete(id int64) error
+	FindById(id int64) (*models.Category, error)
+	FindBySlug(slug string) (*models.Category, error)
+	List() ([]models.Category, error)
+}
+
+// ProductStore persists products with their variants and images
+type ProductStore interface {
+	Create(p *models.ProductCreateRequest) (*models.Product, error)
+	FindById(id int64) (*models.Product, error)
+	Search(q models.ProductQuery) (*models.ProductSearchResult, map[int64]int, error)
+	Update(id int64, req *models.ProductUpdateRequest) (*models.Product, error)
+	Delete(id int64) error
+	DecrementStock(tx Tx, productID int64, qty int) error
+	IncrementStock(tx Tx, productID int64, qty int) error
+
+	ListVariants(productId int64) ([]models.ProductVariant, error)
+	FindVariant(id int64) (*models.ProductVariant, error)
+	CreateVariant(productId int64, req *models.VariantRequest) (*models.ProductVariant, error)
+	UpdateVariant(productId, variantId int64, req *models.VariantRequest) (*models.ProductVariant, error)
+	DeleteVariant(productId, variantId int64) error
+	DecrementVariantStock(tx Tx, variantId int64, qty int) error
+	IncrementVariantStock(tx Tx, variantId int64, qty int) error
+
+	ListImages(productId int64) ([]models.ProductImage, error)
+	AddImage(productId int64, req *models.ProductImageRequest) (*models.ProductImage, error)
+	DeleteImage(productId, imageId int64) error
+}
+
+// CartStore persists shopping cart lines
+type CartStore interface {
+	Upsert(userId, productId, variantId int64, qty int) error
+	UpdateQuantity(userId, productId, variantId int64, qty int) error
+	Remove(userId, productId, variantId int64) error
+	Quantity(userId, productId, variantId int64) (int, error)
+	GetCart(userId int64) ([]models.CartItem, error)
+	ClearCart(tx Tx, userId int64) error
+	GetCartTx(tx Tx, userId int64) ([]models.CartItem, error)
+}
+
+// OrderStore persists orders and checkout idempotency keys
+type OrderStore interface {
+	BeginTx() (Tx, error)
+	CreateOrder(tx Tx, userId int64, b *models.PriceBreakdown, addr *models.ShippingAddress) (int64, error)
+	CreateOrderItem(tx Tx, orderID int64, item *models.CartItem) error
+	SetPaymentId(tx Tx, orderId int64, paymentId string) error
+	UpdateStatusTx(tx Tx, orderId int64, from, to models.OrderStatus) error
+	FindByIdTx(tx Tx, orderId int64) (*models.Order, error)
+	FindIdByPaymentId(paymentId string) (int64, error)
+	FindById(orderId int64) (*models.Order, error)
+	ListByUser(userId int64) ([]models.Order, error)
+	ListAll(q models.OrderQuery) ([]models.Order, int, error)
+	SalesByDay(from, to time.Time) ([]models.DailySales, error)
+	TopProducts(from, to time.Time, limit int) ([]models.ProductSales, error)
+
+	CreateCheckoutKey(tx Tx, userId int64, key string, orderId int64) error
+	FindCheckoutKey(userId int64, key string) (string, error)
+	SaveCheckoutResponse(tx Tx, userId int64, key, response string) error
+	DeleteCheckoutKey(userId int64, key string) error
+}
+
+// PricingStore persists coupons, automatic discounts and tax rates
+type PricingStore interface {
+	CreateCoupon(req *models.CouponRequest) (*models.Coupon, error)
+	UpdateCoupon(id int64, req *models.CouponRequest) (*models.Coupon, error)
+	DeleteCoupon(id int64) error
+	FindCouponById(id int64) (*models.Coupon, error)
+	FindCouponByCode(code string) (*models.Coupon, error)
+	ListCoupons() ([]models.Coupon, error)
+	RedeemCoupon(tx Tx, code string) error
+	ReleaseCoupon(tx Tx, code string) error
+
+	CreateDiscount(req *models.DiscountRequest) (*models.Discount, error)
+	DeleteDiscount(id int64) error
+	ListDiscounts() ([]models.Discount, error)
+	ActiveDiscounts(productIds []int64) ([]models.Discount, error)
+
+	ListTaxRates() ([]models.TaxRate, error)
+	FindTaxRate(region string) (*models.TaxRate, error)
+	UpsertTaxRate(region string, req *models.TaxRateRequest) (*models.TaxRate, error)
+	DeleteTaxRate(region string) error
+}
+
+// InventoryStore persists stock reservations and the inventory ledger
+type InventoryStore interface {
+	BeginTx() (Tx, error)
+	Available(tx Tx, productId, variantId, userId int64) (int, error)
+	Reserve(tx Tx, userId, productId, variantId int64, qty int, ttl time.Duration) error
+	Release(userId, productId, variantId int64) error
+	ReleaseUser(tx Tx, userId int64) error
+	DeleteExpired() (int64, error)
+
+	RecordMovement(tx Tx, m *models.InventoryMovement) error
+	ListMovements(q models.MovementQuery) ([]models.InventoryMovement, error)
+	LowStock(threshold int) ([]models.StockLevel, error)
+}
+
+// The SQLite repos implement the stores
+var (
+	_ UserStore      = (*UserRepo)(nil)
+	_ TokenStore     = (*TokenRepo)(nil)
+	_ AddressStore   = (*AddressRepo)(nil)
+	_ CategoryStore  = (*CategoryRepo)(nil)
+	_ ProductStore   = (*ProductRepo)(nil)
+	_ CartStore      = (*CartRepo)(nil)
+	_ OrderStore     = (*OrderRepo)(nil)
+	_ PricingStore   = (*PricingRepo)(nil)
+	_ InventoryStore = (*InventoryRepo)(nil)
+)
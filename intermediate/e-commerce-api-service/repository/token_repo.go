@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+)
+
+// TokenRepo is the repository for refresh tokens and password reset tokens.
+// Tokens are looked up by the hash of their value; the value itself is never stored.
+type TokenRepo struct {
+	db *sql.DB
+}
+
+// NewTokenRepo creates a new instance of TokenRepo
+func NewTokenRepo(db *sql.DB) *TokenRepo {
+	return &TokenRepo{db: db}
+}
+
+// ttlModifier turns a duration into a SQLite datetime modifier such as "+900 seconds".
+func ttlModifier(ttl time.Duration) string {
+	return fmt.Sprintf("+%d seconds", int(ttl.Seconds()))
+}
+
+// ── Refresh tokens
+
+// CreateRefreshToken stores a refresh token that expires after ttl
+func (r *TokenRepo) CreateRefreshToken(userId int64, tokenHash, family string, ttl time.Duration) error {
+	_, err := r.db.Exec(
+		`INSERT INTO refresh_tokens (user_id, token_hash, family, expires_at)
+         VALUES (?, ?, ?, datetime('now', ?))`,
+		userId, tokenHash, family, ttlModifier(ttl),
+	)
+	if err != nil {
+		return fmt.Errorf("storing refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// FindRefreshToken returns the refresh token with the given hash, revoked or not
+func (r *TokenRepo) FindRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	var (
+		t         models.RefreshToken
+		revokedAt sql.NullTime
+	)
+	err := r.db.QueryRow(
+		`SELECT id, user_id, family, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = ?`,
+		tokenHash,
+	).Scan(&t.ID, &t.UserId, &t.Family, &t.ExpiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying refresh token: %w", err)
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+
+	return &t, nil
+}
+
+// RevokeRefreshToken revokes one refresh token. It returns ErrConflict when
+// the token was already revoked, e.g. by a concurrent refresh.
+func (r *TokenRepo) RevokeRefreshToken(id int64) error {
+	res, err := r.db.Exec(
+		`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL`, id,
+	)
+	if err != nil {
+		return fmt.Errorf("revoking refresh token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrConflict
+	}
+
+	return nil
+}
+
+// RevokeRefreshFamily revokes every token descended from the same login
+func (r *TokenRepo) RevokeRefreshFamily(family string) error {
+	_, err := r.db.Exec(
+		`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family = ? AND revoked_at IS NULL`, family,
+	)
+	if err != nil {
+		return fmt.Errorf("revoking refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of a user, ending all their sessions
+func (r *TokenRepo) RevokeUserRefreshTokens(userId int64) error {
+	_, err := r.db.Exec(
+		`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL`, userId,
+	)
+	if err != nil {
+		return fmt.Errorf("revoking refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// ── Password reset tokens
+
+// CreateResetToken stores a password reset token that expires after ttl
+func (r *TokenRepo) CreateResetToken(userId int64, tokenHash string, ttl time.Duration) error {
+	_, err := r.db.Exec(
+		`INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+         VALUES (?, ?, datetime('now', ?))`,
+		userId, tokenHash, ttlModifier(ttl),
+	)
+	if err != nil {
+		return fmt.Errorf("storing reset token: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeResetToken marks an unused, unexpired reset token as used and returns
+// its user. A single UPDATE makes sure the token works only once.
+// It returns ErrNotFound for unknown, used or expired tokens.
+func (r *TokenRepo) ConsumeResetToken(tokenHash string) (int64, error) {
+	var userId int64
+	err := r.db.QueryRow(
+		`UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP
+         WHERE token_hash = ? AND used_at IS NULL AND expires_at > datetime('now')
+         RETURNING user_id`,
+		tokenHash,
+	).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, models.ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("using reset token: %w", err)
+	}
+
+	return userId, nil
+}
+
+// InvalidateResetTokens marks every outstanding reset token of a user as used
+func (r *TokenRepo) InvalidateResetTokens(userId int64) error {
+	_, err := r.db.Exec(
+		`UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND used_at IS NULL`, userId,
+	)
+	if err != nil {
+		return fmt.Errorf("invalidating reset tokens: %w", err)
+	}
+
+	return nil
+}
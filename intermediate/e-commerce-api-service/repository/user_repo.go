@@ -77,3 +77,19 @@ func (r *UserRepo) FindByEmail(email string) (*models.User, error) {
 	}
 	return u, nil
 }
+
+// UpdatePassword replaces the password hash of a user
+func (r *UserRepo) UpdatePassword(userId int64, hashedPassword string) error {
+	res, err := r.db.Exec(
+		`UPDATE users SET password = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		hashedPassword, userId,
+	)
+	if err != nil {
+		return fmt.Errorf("updating password: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
@@ -14,9 +14,15 @@ import (
 func New(
 	authSvc *services.AuthService,
 	authH *handlers.AuthHandler,
+	addressH *handlers.AddressHandler,
 	productH *handlers.ProductHandler,
+	categoryH *handlers.CategoryHandler,
 	cartH *handlers.CartHandler,
 	orderH *handlers.OrderHandler,
+	pricingH *handlers.PricingHandler,
+	inventoryH *handlers.InventoryHandler,
+	webhookH *handlers.WebhookHandler,
+	sandboxH *handlers.SandboxHandler,
 	pageH *handlers.PageHandler,
 ) *chi.Mux {
 	r := chi.NewRouter()
@@ -44,9 +50,18 @@ func New(
 		// ── Public routes
 		r.Post("/auth/register", authH.Register)
 		r.Post("/auth/login", authH.Login)
+		r.Post("/auth/refresh", authH.Refresh)
+		r.Post("/auth/logout", authH.Logout)
+		r.Post("/auth/password/forgot", authH.ForgotPassword)
+		r.Post("/auth/password/reset", authH.ResetPassword)
 
 		r.Get("/products", productH.List)
 		r.Get("/products/{id}", productH.GetById)
+		r.Get("/categories", categoryH.List)
+		r.Get("/tax-rates", pricingH.ListTaxRates)
+
+		// Webhooks (authenticated by signature, not JWT)
+		r.Post("/webhooks/stripe", webhookH.Stripe)
 
 		// ── Authenticated routes
 		r.Group(func(r chi.Router) {
@@ -58,10 +73,23 @@ func New(
 			r.Put("/cart/items/{productId}", cartH.UpdateItem)
 			r.Delete("/cart/items/{productId}", cartH.RemoveItem)
 
+			// Address book
+			r.Get("/addresses", addressH.List)
+			r.Post("/addresses", addressH.Create)
+			r.Put("/addresses/{id}", addressH.Update)
+			r.Delete("/addresses/{id}", addressH.Delete)
+
 			// Checkout & Orders
 			r.Post("/checkout", orderH.Checkout)
 			r.Get("/orders", orderH.ListOrders)
 			r.Get("/orders/{id}", orderH.GetOrder)
+			r.Post("/orders/{id}/cancel", orderH.CancelOrder)
+
+			// Payment sandbox (fake provider only)
+			if sandboxH != nil {
+				r.Post("/sandbox/payments/{paymentId}/succeed", sandboxH.Succeed)
+				r.Post("/sandbox/payments/{paymentId}/fail", sandboxH.Fail)
+			}
 
 		})
 
@@ -73,6 +101,40 @@ func New(
 			r.Post("/admin/products", productH.Create)
 			r.Put("/admin/products/{id}", productH.Update)
 			r.Delete("/admin/products/{id}", productH.Delete)
+			r.Post("/admin/products/{id}/variants", productH.CreateVariant)
+			r.Put("/admin/products/{id}/variants/{variantId}", productH.UpdateVariant)
+			r.Delete("/admin/products/{id}/variants/{variantId}", productH.DeleteVariant)
+			r.Post("/admin/products/{id}/images", productH.AddImage)
+			r.Delete("/admin/products/{id}/images/{imageId}", productH.DeleteImage)
+
+			// Categories
+			r.Post("/admin/categories", categoryH.Create)
+			r.Put("/admin/categories/{id}", categoryH.Update)
+			r.Delete("/admin/categories/{id}", categoryH.Delete)
+
+			// Pricing
+			r.Get("/admin/coupons", pricingH.ListCoupons)
+			r.Post("/admin/coupons", pricingH.CreateCoupon)
+			r.Put("/admin/coupons/{id}", pricingH.UpdateCoupon)
+			r.Delete("/admin/coupons/{id}", pricingH.DeleteCoupon)
+			r.Get("/admin/discounts", pricingH.ListDiscounts)
+			r.Post("/admin/discounts", pricingH.CreateDiscount)
+			r.Delete("/admin/discounts/{id}", pricingH.DeleteDiscount)
+			r.Put("/admin/tax-rates/{region}", pricingH.SetTaxRate)
+			r.Delete("/admin/tax-rates/{region}", pricingH.DeleteTaxRate)
+
+			// Inventory
+			r.Get("/admin/inventory/low-stock", inventoryH.LowStock)
+			r.Get("/admin/inventory/movements", inventoryH.ListMovements)
+			r.Post("/admin/inventory/movements", inventoryH.RecordMovement)
+
+			// Orders & reports
+			r.Get("/admin/orders", orderH.AdminList)
+			r.Get("/admin/orders/export.csv", orderH.AdminExport)
+			r.Get("/admin/orders/{id}", orderH.AdminGet)
+			r.Put("/admin/orders/{id}/status", orderH.AdminSetStatus)
+			r.Post("/admin/orders/{id}/refund", orderH.AdminRefund)
+			r.Get("/admin/reports/sales", orderH.SalesReport)
 		})
 	})
 
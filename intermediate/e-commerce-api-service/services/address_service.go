@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+)
+
+// AddressService manages users' address books and picks the shipping address at checkout.
+type AddressService struct {
+	repo repository.AddressStore
+}
+
+// NewAddressService creates a new instance of AddressService
+func NewAddressService(repo repository.AddressStore) *AddressService {
+	return &AddressService{repo: repo}
+}
+
+// List returns the user's saved addresses, the default first.
+func (s *AddressService) List(userId int64) ([]models.Address, error) {
+	return s.repo.List(userId)
+}
+
+// Create validates and saves an address for the user.
+func (s *AddressService) Create(userId int64, req models.AddressRequest) (*models.Address, error) {
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return s.repo.Create(userId, &req)
+}
+
+// Update validates and replaces one of the user's addresses.
+func (s *AddressService) Update(userId, id int64, req models.AddressRequest) (*models.Address, error) {
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return s.repo.Update(userId, id, &req)
+}
+
+// Delete removes one of the user's addresses. Orders keep their own copy.
+func (s *AddressService) Delete(userId, id int64) error {
+	return s.repo.Delete(userId, id)
+}
+
+// forCheckout returns the address an order ships to: the address given in
+// the request, else the saved address addressId, else the user's default.
+func (s *AddressService) forCheckout(userId int64, req models.CheckoutRequest) (*models.ShippingAddress, error) {
+	if req.ShippingAddress != nil {
+		addr := *req.ShippingAddress
+		addr.Normalize()
+		if err := addr.Validate(); err != nil {
+			return nil, err
+		}
+		return &addr, nil
+	}
+
+	var (
+		saved *models.Address
+		err   error
+	)
+	if req.AddressId > 0 {
+		saved, err = s.repo.Find(userId, req.AddressId)
+	} else {
+		saved, err = s.repo.FindDefault(userId)
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, fmt.Errorf("%w: a shipping address is required", models.ErrBadRequest)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &saved.ShippingAddress, nil
+}
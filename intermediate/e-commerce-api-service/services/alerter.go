@@ -0,0 +1,41 @@
+package services
+
+import "log"
+
+// Alerter tells the shop's operators about something that needs their
+// attention, such as stock running low.
+type Alerter interface {
+	Alert(subject, body string)
+}
+
+// NewAlerter mails alerts to the given address. Without an address, alerts
+// are only written to the log.
+func NewAlerter(mailer Mailer, to string) Alerter {
+	if to == "" {
+		return LogAlerter{}
+	}
+	return &MailAlerter{mailer: mailer, to: to}
+}
+
+// LogAlerter writes alerts to the application log.
+type LogAlerter struct{}
+
+// Alert logs the alert.
+func (LogAlerter) Alert(subject, body string) {
+	log.Printf("[ALERT] %s: %s", subject, body)
+}
+
+// MailAlerter emails alerts to one address, e.g. the operations inbox.
+// The alert is logged as well, so it is not lost if sending fails.
+type MailAlerter struct {
+	mailer Mailer
+	to     string
+}
+
+// Alert logs the alert and mails it.
+func (a *MailAlerter) Alert(subject, body string) {
+	LogAlerter{}.Alert(subject, body)
+	if err := a.mailer.Send(a.to, subject, body); err != nil {
+		log.Printf("[ALERT] mailing %q to %s failed: %v", subject, a.to, err)
+	}
+}
@@ -1,8 +1,14 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -13,13 +19,26 @@ import (
 
 // AuthService is the service for authentication
 type AuthService struct {
-	userRepo  *repository.UserRepo
-	jwtSecret []byte
+	userRepo   repository.UserStore
+	tokenRepo  repository.TokenStore
+	mailer     Mailer
+	jwtSecret  []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	resetTTL   time.Duration
+	baseURL    string // public URL of the site, used in emailed links
 }
 
-// NewAuthService creates a new instance of AuthService
-func NewAuthService(repo *repository.UserRepo, secret string) *AuthService {
-	return &AuthService{userRepo: repo, jwtSecret: []byte(secret)}
+// NewAuthService creates a new instance of AuthService. Access tokens (JWTs)
+// last accessTTL; refresh tokens last refreshTTL and are rotated on every use;
+// password reset links last resetTTL.
+func NewAuthService(repo repository.UserStore, tokens repository.TokenStore, mailer Mailer, secret string,
+	accessTTL, refreshTTL, resetTTL time.Duration, baseURL string) *AuthService {
+	return &AuthService{
+		userRepo: repo, tokenRepo: tokens, mailer: mailer, jwtSecret: []byte(secret),
+		accessTTL: accessTTL, refreshTTL: refreshTTL, resetTTL: resetTTL,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
 }
 
 // Register registers a new user
@@ -41,12 +60,7 @@ func (s *AuthService) Register(req models.UserRegisterRequest) (*models.AuthResp
 		return nil, err
 	}
 
-	token, err := s.generateToken(user)
-	if err != nil {
-		return nil, err
-	}
-
-	return &models.AuthResponse{Token: token, User: *user}, nil
+	return s.issueTokens(user, "")
 }
 
 // Login logs in a user
@@ -61,21 +75,199 @@ func (s *AuthService) Login(req models.UserLoginRequest) (*models.AuthResponse,
 		return nil, fmt.Errorf("%w: invalid credentials", models.ErrUnauthorized)
 	}
 
-	token, err := s.generateToken(user)
+	return s.issueTokens(user, "")
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+// The presented token is revoked. Presenting a token that was already
+// rotated means it was copied, so every token of that login is revoked.
+func (s *AuthService) Refresh(req models.RefreshRequest) (*models.AuthResponse, error) {
+	invalid := fmt.Errorf("%w: invalid refresh token", models.ErrUnauthorized)
+	if req.RefreshToken == "" {
+		return nil, invalid
+	}
+
+	rt, err := s.tokenRepo.FindRefreshToken(hashToken(req.RefreshToken))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, invalid
+	}
 	if err != nil {
 		return nil, err
 	}
+	if rt.RevokedAt != nil {
+		s.revokeReused(rt)
+		return nil, invalid
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		return nil, fmt.Errorf("%w: refresh token expired", models.ErrUnauthorized)
+	}
+
+	// Revoking only succeeds once, so two concurrent refreshes with the same token cannot both win
+	if err := s.tokenRepo.RevokeRefreshToken(rt.ID); err != nil {
+		if errors.Is(err, models.ErrConflict) {
+			s.revokeReused(rt)
+			return nil, invalid
+		}
+		return nil, err
+	}
 
-	return &models.AuthResponse{Token: token, User: *user}, nil
+	user, err := s.userRepo.FindById(rt.UserId)
+	if err != nil {
+		return nil, invalid
+	}
+
+	return s.issueTokens(user, rt.Family)
+}
+
+// revokeReused ends the session a reused refresh token belongs to.
+func (s *AuthService) revokeReused(rt *models.RefreshToken) {
+	log.Printf("[AUTH] Refresh token reuse for user %d; revoking session %s", rt.UserId, rt.Family)
+	if err := s.tokenRepo.RevokeRefreshFamily(rt.Family); err != nil {
+		log.Printf("[AUTH] revoking session failed: %v", err)
+	}
+}
+
+// Logout revokes the session of a refresh token, or all of its user's
+// sessions when req.All is set. Unknown tokens are ignored.
+// Access tokens already issued stay valid until they expire.
+func (s *AuthService) Logout(req models.LogoutRequest) error {
+	if req.RefreshToken == "" {
+		return fmt.Errorf("%w: refresh_token is required", models.ErrBadRequest)
+	}
+
+	rt, err := s.tokenRepo.FindRefreshToken(hashToken(req.RefreshToken))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if req.All {
+		return s.tokenRepo.RevokeUserRefreshTokens(rt.UserId)
+	}
+	return s.tokenRepo.RevokeRefreshFamily(rt.Family)
+}
+
+// ForgotPassword emails a one-time password reset link. It behaves the same
+// whether or not the email belongs to an account, so it cannot be used to
+// discover accounts; failures are only logged.
+func (s *AuthService) ForgotPassword(req models.ForgotPasswordRequest) error {
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		return fmt.Errorf("%w: email is required", models.ErrBadRequest)
+	}
+
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		if !errors.Is(err, models.ErrNotFound) {
+			log.Printf("[AUTH] password reset lookup failed: %v", err)
+		}
+		return nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		log.Printf("[AUTH] generating reset token failed: %v", err)
+		return nil
+	}
+	if err := s.tokenRepo.CreateResetToken(user.ID, hashToken(token), s.resetTTL); err != nil {
+		log.Printf("[AUTH] storing reset token failed: %v", err)
+		return nil
+	}
+
+	body := fmt.Sprintf("Someone asked to reset the password of your Goods & Co. account.\n\n"+
+		"Open this link within %d minutes to choose a new password:\n%s/auth?reset=%s\n\n"+
+		"If it was not you, ignore this email; your password stays the same.",
+		int(s.resetTTL.Minutes()), s.baseURL, token)
+	if err := s.mailer.Send(user.Email, "Reset your password", body); err != nil {
+		log.Printf("[AUTH] sending reset email failed: %v", err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a token from a reset email. The
+// token works once; all of the user's sessions are signed out.
+func (s *AuthService) ResetPassword(req models.ResetPasswordRequest) error {
+	if len(req.Password) < 8 {
+		return fmt.Errorf("%w: password must be at least 8 characters", models.ErrBadRequest)
+	}
+
+	userId, err := s.tokenRepo.ConsumeResetToken(hashToken(req.Token))
+	if errors.Is(err, models.ErrNotFound) {
+		return fmt.Errorf("%w: invalid or expired reset token", models.ErrBadRequest)
+	}
+	if err != nil {
+		return err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hashing password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(userId, string(hashed)); err != nil {
+		return err
+	}
+
+	// Other reset links and existing sessions may be in the wrong hands
+	if err := s.tokenRepo.InvalidateResetTokens(userId); err != nil {
+		log.Printf("[AUTH] invalidating reset tokens failed: %v", err)
+	}
+	if err := s.tokenRepo.RevokeUserRefreshTokens(userId); err != nil {
+		return err
+	}
+
+	if user, err := s.userRepo.FindById(userId); err == nil {
+		body := "The password of your Goods & Co. account was just changed and you were signed out everywhere.\n\n" +
+			"If it was not you, reset your password again right away."
+		if err := s.mailer.Send(user.Email, "Your password was changed", body); err != nil {
+			log.Printf("[AUTH] sending password change email failed: %v", err)
+		}
+	}
+
+	log.Printf("[AUTH] Password reset for user %d", userId)
+
+	return nil
+}
+
+// issueTokens creates an access token and a refresh token for the user.
+// An empty family starts a new session; rotation passes the old token's family.
+func (s *AuthService) issueTokens(user *models.User, family string) (*models.AuthResponse, error) {
+	expiresAt := time.Now().Add(s.accessTTL)
+	token, err := s.generateToken(user, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if family == "" {
+		if family, err = newToken(); err != nil {
+			return nil, err
+		}
+	}
+	refresh, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokenRepo.CreateRefreshToken(user.ID, hashToken(refresh), family, s.refreshTTL); err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token:          token,
+		TokenExpiresAt: expiresAt.UTC().Truncate(time.Second),
+		RefreshToken:   refresh,
+		User:           *user,
+	}, nil
 }
 
 // generateToken generates a JWT token for the user
-func (s *AuthService) generateToken(user *models.User) (string, error) {
+func (s *AuthService) generateToken(user *models.User, expiresAt time.Time) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
 		"email":   user.Email,
 		"role":    user.Role,
-		"exp":     time.Now().Add(72 * time.Hour).Unix(),
+		"exp":     expiresAt.Unix(),
 		"iat":     time.Now().Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -83,6 +275,22 @@ func (s *AuthService) generateToken(user *models.User) (string, error) {
 	return token.SignedString(s.jwtSecret)
 }
 
+// newToken returns a random URL-safe token with 256 bits of entropy.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hex digest stored in place of a token.
+// The tokens are random, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // ValidateToken parses and validates a JWT, returning claims.
 func (s *AuthService) ValidateToken(tokenStr string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+)
+
+func newTestAuth(t *testing.T) (*AuthService, *sql.DB, *recordedMail) {
+	t.Helper()
+	db := newTestDB(t)
+	mail := &recordedMail{}
+	svc := NewAuthService(repository.NewUserRepo(db), repository.NewTokenRepo(db), mail, "test-secret",
+		15*time.Minute, 24*time.Hour, time.Hour, "http://shop.test/")
+	return svc, db, mail
+}
+
+// register creates an account and returns its first session
+func register(t *testing.T, svc *AuthService, email string) *models.AuthResponse {
+	t.Helper()
+	resp, err := svc.Register(models.UserRegisterRequest{Email: email, Password: "password1"})
+	if err != nil {
+		t.Fatalf("registering %s: %v", email, err)
+	}
+	return resp
+}
+
+// expire moves the expiry of a refresh or reset token into the past
+func expire(t *testing.T, db *sql.DB, table, token string) {
+	t.Helper()
+	res, err := db.Exec(`UPDATE `+table+` SET expires_at = datetime('now', '-1 minute') WHERE token_hash = ?`, hashToken(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("no %s row for the token", table)
+	}
+}
+
+func TestRefreshRotates(t *testing.T) {
+	svc, db, _ := newTestAuth(t)
+	first := register(t, svc, "alice@example.com")
+
+	second, err := svc.Refresh(models.RefreshRequest{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.Token == "" || second.User.Email != "alice@example.com" {
+		t.Fatalf("expected a new token pair for alice, got %+v", second)
+	}
+	third, err := svc.Refresh(models.RefreshRequest{RefreshToken: second.RefreshToken})
+	if err != nil {
+		t.Fatalf("refreshing the rotated token: %v", err)
+	}
+
+	expire(t, db, "refresh_tokens", third.RefreshToken)
+	if _, err := svc.Refresh(models.RefreshRequest{RefreshToken: third.RefreshToken}); !errors.Is(err, models.ErrUnauthorized) {
+		t.Fatalf("expired token: expected ErrUnauthorized, got %v", err)
+	}
+
+	for _, token := range []string{"", "not-a-token"} {
+		if _, err := svc.Refresh(models.RefreshRequest{RefreshToken: token}); !errors.Is(err, models.ErrUnauthorized) {
+			t.Errorf("%q: expected ErrUnauthorized, got %v", token, err)
+		}
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	svc, _, _ := newTestAuth(t)
+	stolen := register(t, svc, "alice@example.com")
+	other, err := svc.Login(models.UserLoginRequest{Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := svc.Refresh(models.RefreshRequest{RefreshToken: stolen.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old token comes back: whoever holds it copied it
+	if _, err := svc.Refresh(models.RefreshRequest{RefreshToken: stolen.RefreshToken}); !errors.Is(err, models.ErrUnauthorized) {
+		t.Fatalf("reused token: expected ErrUnauthorized, got %v", err)
+	}
+	if _, err := svc.Refresh(models.RefreshRequest{RefreshToken: rotated.RefreshToken}); !errors.Is(err, models.ErrUnauthorized) {
+		t.Fatalf("the session of a reused token should be revoked, got %v", err)
+	}
+
+	// Other logins of the same user are separate sessions
+	if _, err := svc.Refresh(models.RefreshRequest{RefreshToken: other.RefreshToken}); err != nil {
+		t.Fatalf("other session: %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	svc, db, mail := newTestAuth(t)
+	session := register(t, svc, "alice@example.com")
+
+	// Unknown emails look the same to the caller, but nothing is sent
+	if err := svc.ForgotPassword(models.ForgotPasswordRequest{Email: "nobody@example.com"}); err != nil || len(mail.sent) != 0 {
+		t.Fatalf("unknown email: got %v, %d emails", err, len(mail.sent))
+	}
+
+	link := regexp.MustCompile(`http://shop\.test/auth\?reset=(\S+)`)
+	requestReset := func() string {
+		t.Helper()
+		if err := svc.ForgotPassword(models.ForgotPasswordRequest{Email: "alice@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		m := link.FindStringSubmatch(mail.sent[len(mail.sent)-1].body)
+		if m == nil {
+			t.Fatalf("no reset link in %q", mail.sent[len(mail.sent)-1].body)
+		}
+		return m[1]
+	}
+	first, second := requestReset(), requestReset()
+
+	if err := svc.ResetPassword(models.ResetPasswordRequest{Token: first, Password: "short"}); !errors.Is(err, models.ErrBadRequest) {
+		t.Fatalf("short password: expected ErrBadRequest, got %v", err)
+	}
+	if err := svc.ResetPassword(models.ResetPasswordRequest{Token: first, Password: "password2"}); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if last := mail.sent[len(mail.sent)-1]; last.to != "alice@example.com" || last.subject != "Your password was changed" {
+		t.Errorf("expected a password change notice, got %+v", last)
+	}
+
+	if _, err := svc.Login(models.UserLoginRequest{Email: "alice@example.com", Password: "password1"}); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("old password: expected ErrUnauthorized, got %v", err)
+	}
+	if _, err := svc.Login(models.UserLoginRequest{Email: "alice@example.com", Password: "password2"}); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+	if _, err := svc.Refresh(models.RefreshRequest{RefreshToken: session.RefreshToken}); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("sessions from before the reset should be signed out, got %v", err)
+	}
+
+	// Each link works once, and a reset voids the other links
+	for name, token := range map[string]string{"used": first, "other": second} {
+		if err := svc.ResetPassword(models.ResetPasswordRequest{Token: token, Password: "password3"}); !errors.Is(err, models.ErrBadRequest) {
+			t.Errorf("%s link: expected ErrBadRequest, got %v", name, err)
+		}
+	}
+
+	expired := requestReset()
+	expire(t, db, "password_reset_tokens", expired)
+	if err := svc.ResetPassword(models.ResetPasswordRequest{Token: expired, Password: "password3"}); !errors.Is(err, models.ErrBadRequest) {
+		t.Fatalf("expired link: expected ErrBadRequest, got %v", err)
+	}
+}
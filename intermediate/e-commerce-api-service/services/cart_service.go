@@ -7,57 +7,98 @@ import (
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
 )
 
-// CartService handles cart operations
+// CartService handles cart operations. Every cart line holds a stock
+// reservation that is renewed whenever the line changes.
 type CartService struct {
-	cartRepo    *repository.CartRepo
-	productRepo *repository.ProductRepo
+	cartRepo    repository.CartStore
+	productRepo repository.ProductStore
+	inventory   *InventoryService
+	pricing     *PricingService
 }
 
-func NewCartService(cr *repository.CartRepo, pr *repository.ProductRepo) *CartService {
-	return &CartService{cartRepo: cr, productRepo: pr}
+func NewCartService(cr repository.CartStore, pr repository.ProductStore, inv *InventoryService, ps *PricingService) *CartService {
+	return &CartService{cartRepo: cr, productRepo: pr, inventory: inv, pricing: ps}
 }
 
+// AddItem adds units to a cart line and reserves the line's new quantity.
 func (s *CartService) AddItem(userID int64, req models.AddToCartRequest) error {
 	if req.Quantity <= 0 {
 		return fmt.Errorf("%w: quantity must be positive", models.ErrBadRequest)
 	}
 
-	// Verify product exists and has stock
+	// Verify product exists
 	product, err := s.productRepo.FindById(req.ProductId)
 	if err != nil {
 		return err
 	}
-	if product.Stock < req.Quantity {
-		return models.ErrInsufficientStock
+
+	// Products with variants are bought per variant, which has its own stock
+	switch {
+	case req.VariantId > 0:
+		variant, err := s.productRepo.FindVariant(req.VariantId)
+		if err != nil {
+			return err
+		}
+		if variant.ProductId != product.ID {
+			return fmt.Errorf("%w: variant does not belong to product", models.ErrBadRequest)
+		}
+	case len(product.Variants) > 0:
+		return fmt.Errorf("%w: choose a variant of %s", models.ErrBadRequest, product.Name)
+	}
+
+	inCart, err := s.cartRepo.Quantity(userID, req.ProductId, req.VariantId)
+	if err != nil {
+		return err
+	}
+	if err := s.inventory.Reserve(userID, req.ProductId, req.VariantId, inCart+req.Quantity); err != nil {
+		return err
 	}
 
-	return s.cartRepo.Upsert(userID, req.ProductId, req.Quantity)
+	return s.cartRepo.Upsert(userID, req.ProductId, req.VariantId, req.Quantity)
 }
 
-func (s *CartService) UpdateItem(userID, productId int64, req models.UpdateCartItemRequest) error {
+// UpdateItem sets the quantity of a cart line and its reservation; 0 or less removes it.
+// variantId is 0 for products without variants.
+func (s *CartService) UpdateItem(userID, productId, variantId int64, req models.UpdateCartItemRequest) error {
 	if req.Quantity <= 0 {
-		return s.cartRepo.Remove(userID, productId)
+		return s.RemoveItem(userID, productId, variantId)
 	}
-	return s.cartRepo.UpdateQuantity(userID, productId, req.Quantity)
+	if inCart, err := s.cartRepo.Quantity(userID, productId, variantId); err != nil {
+		return err
+	} else if inCart == 0 {
+		return models.ErrNotFound
+	}
+	if err := s.inventory.Reserve(userID, productId, variantId, req.Quantity); err != nil {
+		return err
+	}
+	return s.cartRepo.UpdateQuantity(userID, productId, variantId, req.Quantity)
 }
 
-func (s *CartService) RemoveItem(userId, productId int64) error {
-	return s.cartRepo.Remove(userId, productId)
+// RemoveItem removes a cart line and releases its reservation.
+func (s *CartService) RemoveItem(userId, productId, variantId int64) error {
+	if err := s.cartRepo.Remove(userId, productId, variantId); err != nil {
+		return err
+	}
+	return s.inventory.Release(userId, productId, variantId)
 }
 
-// GetCart retrieves the user's cart with product details
-func (s *CartService) GetCart(userId int64) (*models.CartResponse, error) {
+// GetCart retrieves the user's cart with product details and a price preview.
+// A coupon or region that cannot be applied is reported as a warning.
+func (s *CartService) GetCart(userId int64, opts models.PricingOptions) (*models.CartResponse, error) {
 	items, err := s.cartRepo.GetCart(userId)
 	if err != nil {
 		return nil, err
 	}
 
-	var total int64
+	breakdown, warnings, err := s.pricing.Quote(items, opts, false)
+	if err != nil {
+		return nil, err
+	}
 	for _, item := range items {
-		if item.Product != nil {
-			total += item.Product.Price * int64(item.Quantity)
+		if item.ReservedUntil == nil {
+			warnings = append(warnings, fmt.Sprintf("%s is no longer reserved for you; update the quantity to hold it again", item.Product.Name))
 		}
 	}
 
-	return &models.CartResponse{Items: items, Total: total}, nil
+	return &models.CartResponse{Items: items, PriceBreakdown: *breakdown, Warnings: warnings}, nil
 }
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+)
+
+// CategoryService manages the category tree.
+type CategoryService struct {
+	repo repository.CategoryStore
+}
+
+// NewCategoryService creates a new instance of CategoryService
+func NewCategoryService(repo repository.CategoryStore) *CategoryService {
+	return &CategoryService{repo: repo}
+}
+
+// List returns all categories as a flat list.
+func (s *CategoryService) List() ([]models.Category, error) {
+	return s.repo.List()
+}
+
+// Tree returns the root categories with their subcategories nested in Children.
+func (s *CategoryService) Tree() ([]models.Category, error) {
+	categories, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int64][]models.Category)
+	var roots []models.Category
+	for _, c := range categories {
+		if c.ParentId == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentId] = append(children[*c.ParentId], c)
+		}
+	}
+
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+
+	return attach(roots), nil
+}
+
+// Create validates and stores a new category.
+func (s *CategoryService) Create(req models.CategoryRequest) (*models.Category, error) {
+	if err := s.validate(0, &req); err != nil {
+		return nil, err
+	}
+	return s.repo.Create(&req)
+}
+
+// Update renames or moves a category. A category cannot be moved below itself.
+func (s *CategoryService) Update(id int64, req models.CategoryRequest) (*models.Category, error) {
+	if _, err := s.repo.FindById(id); err != nil {
+		return nil, err
+	}
+	if err := s.validate(id, &req); err != nil {
+		return nil, err
+	}
+	return s.repo.Update(id, &req)
+}
+
+// Delete removes a category that has no subcategories. Its products become uncategorised.
+func (s *CategoryService) Delete(id int64) error {
+	return s.repo.Delete(id)
+}
+
+// validate normalises the request and checks the parent exists and does not create a cycle.
+func (s *CategoryService) validate(id int64, req *models.CategoryRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("%w: name is required", models.ErrBadRequest)
+	}
+	req.Slug = slugify(req.Slug)
+	if req.Slug == "" {
+		req.Slug = slugify(req.Name)
+	}
+	if req.Slug == "" {
+		return fmt.Errorf("%w: slug must contain letters or digits", models.ErrBadRequest)
+	}
+
+	if req.ParentId == nil || *req.ParentId == 0 {
+		req.ParentId = nil
+		return nil
+	}
+
+	// Walk up from the new parent; meeting the category itself means a cycle
+	for parentId := req.ParentId; parentId != nil; {
+		if *parentId == id {
+			return fmt.Errorf("%w: a category cannot be its own ancestor", models.ErrBadRequest)
+		}
+		parent, err := s.repo.FindById(*parentId)
+		if err != nil {
+			return fmt.Errorf("%w: parent category not found", models.ErrBadRequest)
+		}
+		parentId = parent.ParentId
+	}
+
+	return nil
+}
+
+// slugify lower-cases s and joins its letters and digits with hyphens, e.g. "Men's Shoes" → "men-s-shoes".
+func slugify(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+)
+
+// Statuses used by the fake provider, mirroring Stripe's names.
+const (
+	fakeStatusRequiresPayment = "requires_payment_method"
+	fakeStatusSucceeded       = "succeeded"
+	fakeStatusRefunded        = "refunded"
+	fakeStatusCanceled        = "canceled"
+)
+
+// FakeProvider is an in-process payment sandbox. Payments stay pending until
+// they are settled through Settle, which lets checkout run offline and in tests.
+type FakeProvider struct {
+	mu            sync.Mutex
+	intents       map[string]*PaymentIntent
+	refunded      map[string]int64
+	byKey         map[string]any // idempotency key → *PaymentIntent or *Refund
+	webhookSecret string
+}
+
+// NewFakeProvider creates an empty sandbox. The webhook secret is optional.
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	return &FakeProvider{
+		intents:       make(map[string]*PaymentIntent),
+		refunded:      make(map[string]int64),
+		byKey:         make(map[string]any),
+		webhookSecret: webhookSecret,
+	}
+}
+
+// Name returns "fake".
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// CreatePaymentIntent records a new pending payment.
+func (p *FakeProvider) CreatePaymentIntent(amountCents int64, currency, idempotencyKey string) (*PaymentIntent, error) {
+	if amountCents <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", models.ErrBadRequest)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if prev, ok := p.byKey[idempotencyKey].(*PaymentIntent); ok && idempotencyKey != "" {
+		copied := *prev
+		return &copied, nil
+	}
+
+	id := "pi_fake_" + randomHex(12)
+	pi := &PaymentIntent{
+		ID:           id,
+		ClientSecret: id + "_secret_" + randomHex(12),
+		Amount:       amountCents,
+		Currency:     currency,
+		Status:       fakeStatusRequiresPayment,
+	}
+	p.intents[id] = pi
+	if idempotencyKey != "" {
+		p.byKey[idempotencyKey] = pi
+	}
+
+	copied := *pi
+	return &copied, nil
+}
+
+// CancelPaymentIntent voids a pending payment, after which Settle refuses it.
+func (p *FakeProvider) CancelPaymentIntent(paymentId string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pi, ok := p.intents[paymentId]
+	if !ok {
+		return fmt.Errorf("%w: unknown payment %s", models.ErrNotFound, paymentId)
+	}
+	switch pi.Status {
+	case fakeStatusCanceled:
+		return nil
+	case fakeStatusRequiresPayment:
+		pi.Status = fakeStatusCanceled
+		return nil
+	default:
+		return fmt.Errorf("%w: payment %s is already %s", models.ErrConflict, paymentId, pi.Status)
+	}
+}
+
+// Refund refunds part or all of a settled payment.
+func (p *FakeProvider) Refund(paymentId string, amountCents int64, idempotencyKey string) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if prev, ok := p.byKey[idempotencyKey].(*Refund); ok && idempotencyKey != "" {
+		copied := *prev
+		return &copied, nil
+	}
+
+	pi, ok := p.intents[paymentId]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown payment %s", models.ErrNotFound, paymentId)
+	}
+	if pi.Status != fakeStatusSucceeded && pi.Status != fakeStatusRefunded {
+		return nil, fmt.Errorf("%w: payment %s has not succeeded", models.ErrBadRequest, paymentId)
+	}
+	if amountCents <= 0 || p.refunded[paymentId]+amountCents > pi.Amount {
+		return nil, fmt.Errorf("%w: refund exceeds the captured amount", models.ErrBadRequest)
+	}
+
+	p.refunded[paymentId] += amountCents
+	if p.refunded[paymentId] == pi.Amount {
+		pi.Status = fakeStatusRefunded
+	}
+
+	re := &Refund{
+		ID:        "re_fake_" + randomHex(12),
+		PaymentId: paymentId,
+		Amount:    amountCents,
+		Status:    fakeStatusSucceeded,
+	}
+	if idempotencyKey != "" {
+		p.byKey[idempotencyKey] = re
+	}
+
+	copied := *re
+	return &copied, nil
+}
+
+// ParseWebhook accepts Stripe-format signed events, so the fixtures work in sandbox mode too.
+func (p *FakeProvider) ParseWebhook(payload []byte, signature string) (*PaymentEvent, error) {
+	return parseSignedEvent(payload, signature, p.webhookSecret)
+}
+
+// Settle completes a pending payment as if the customer had confirmed it,
+// returning the event a real provider would have delivered.
+func (p *FakeProvider) Settle(paymentId string, succeeded bool) (*PaymentEvent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pi, ok := p.intents[paymentId]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown payment %s", models.ErrNotFound, paymentId)
+	}
+	if pi.Status != fakeStatusRequiresPayment {
+		return nil, fmt.Errorf("%w: payment %s is already %s", models.ErrConflict, paymentId, pi.Status)
+	}
+
+	event := &PaymentEvent{ID: "evt_fake_" + randomHex(12), PaymentIntentId: paymentId}
+	if succeeded {
+		pi.Status = fakeStatusSucceeded
+		event.Type = PaymentSucceeded
+	} else {
+		pi.Status = fakeStatusCanceled
+		event.Type = PaymentFailed
+	}
+
+	return event, nil
+}
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
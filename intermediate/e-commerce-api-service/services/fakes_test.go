@@ -0,0 +1,359 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+)
+
+// In-memory stores for unit-testing services without SQLite. Each fake embeds
+// its store interface and implements only the methods the tested services
+// call; any other call panics on the nil interface.
+
+// memDB is the state shared by the fake stores, like the SQLite database
+// shared by the repos. Writes made inside a memTx are undone on rollback.
+type memDB struct {
+	nextId       int64
+	products     map[int64]*models.Product
+	carts        map[int64][]models.CartItem // user ID → cart lines
+	reservations map[int64]map[int64]int     // user ID → product ID → units held
+	orders       map[int64]*models.Order
+	keys         map[string]*memCheckoutKey // "<user ID>/<key>"
+	movements    []models.InventoryMovement
+
+	// fail makes the named store method return the error
+	fail map[string]error
+}
+
+type memCheckoutKey struct {
+	orderId  int64
+	response string
+}
+
+func newMemDB() *memDB {
+	return &memDB{
+		products:     make(map[int64]*models.Product),
+		carts:        make(map[int64][]models.CartItem),
+		reservations: make(map[int64]map[int64]int),
+		orders:       make(map[int64]*models.Order),
+		keys:         make(map[string]*memCheckoutKey),
+		fail:         make(map[string]error),
+	}
+}
+
+// memTx is the fake stores' transaction
+type memTx struct {
+	undo []func()
+	done bool
+}
+
+func (t *memTx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	t.undo = nil
+	return nil
+}
+
+func (t *memTx) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		t.undo[i]()
+	}
+	return nil
+}
+
+// onRollback registers f to undo a write made inside tx
+func onRollback(tx repository.Tx, f func()) {
+	t := tx.(*memTx)
+	t.undo = append(t.undo, f)
+}
+
+func checkoutKeyOf(userId int64, key string) string {
+	return fmt.Sprintf("%d/%s", userId, key)
+}
+
+// ── Orders
+
+type memOrders struct {
+	repository.OrderStore
+	db *memDB
+}
+
+func (s *memOrders) BeginTx() (repository.Tx, error) {
+	return &memTx{}, nil
+}
+
+func (s *memOrders) CreateOrder(tx repository.Tx, userId int64, b *models.PriceBreakdown, addr *models.ShippingAddress) (int64, error) {
+	s.db.nextId++
+	id := s.db.nextId
+	s.db.orders[id] = &models.Order{ID: id, UserId: userId, PriceBreakdown: *b, ShippingAddress: addr, Status: models.OrderPending}
+	onRollback(tx, func() { delete(s.db.orders, id) })
+	return id, nil
+}
+
+func (s *memOrders) CreateOrderItem(tx repository.Tx, orderID int64, item *models.CartItem) error {
+	o := s.db.orders[orderID]
+	o.Items = append(o.Items, models.OrderItem{
+		OrderId: orderID, ProductId: item.ProductId, VariantId: item.VariantId,
+		Quantity: item.Quantity, Price: item.UnitPrice, Discount: item.Discount,
+	})
+	onRollback(tx, func() { o.Items = o.Items[:len(o.Items)-1] })
+	return nil
+}
+
+func (s *memOrders) SetPaymentId(tx repository.Tx, orderId int64, paymentId string) error {
+	if err := s.db.fail["SetPaymentId"]; err != nil {
+		return err
+	}
+	o, ok := s.db.orders[orderId]
+	if !ok {
+		return models.ErrNotFound
+	}
+	prev := o.StripePaymentId
+	o.StripePaymentId = paymentId
+	onRollback(tx, func() { o.StripePaymentId = prev })
+	return nil
+}
+
+func (s *memOrders) UpdateStatusTx(tx repository.Tx, orderId int64, from, to models.OrderStatus) error {
+	o, ok := s.db.orders[orderId]
+	if !ok || o.Status != from {
+		return models.ErrConflict
+	}
+	o.Status = to
+	onRollback(tx, func() { o.Status = from })
+	return nil
+}
+
+func (s *memOrders) FindByIdTx(tx repository.Tx, orderId int64) (*models.Order, error) {
+	return s.FindById(orderId)
+}
+
+func (s *memOrders) FindById(orderId int64) (*models.Order, error) {
+	o, ok := s.db.orders[orderId]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	copied := *o
+	copied.Items = append([]models.OrderItem(nil), o.Items...)
+	return &copied, nil
+}
+
+func (s *memOrders) FindIdByPaymentId(paymentId string) (int64, error) {
+	for id, o := range s.db.orders {
+		if o.StripePaymentId == paymentId {
+			return id, nil
+		}
+	}
+	return 0, models.ErrNotFound
+}
+
+func (s *memOrders) CreateCheckoutKey(tx repository.Tx, userId int64, key string, orderId int64) error {
+	k := checkoutKeyOf(userId, key)
+	if _, ok := s.db.keys[k]; ok {
+		return models.ErrConflict
+	}
+	s.db.keys[k] = &memCheckoutKey{orderId: orderId}
+	onRollback(tx, func() { delete(s.db.keys, k) })
+	return nil
+}
+
+func (s *memOrders) FindCheckoutKey(userId int64, key string) (string, error) {
+	ck, ok := s.db.keys[checkoutKeyOf(userId, key)]
+	if !ok {
+		return "", models.ErrNotFound
+	}
+	return ck.response, nil
+}
+
+func (s *memOrders) SaveCheckoutResponse(tx repository.Tx, userId int64, key, response string) error {
+	if err := s.db.fail["SaveCheckoutResponse"]; err != nil {
+		return err
+	}
+	ck, ok := s.db.keys[checkoutKeyOf(userId, key)]
+	if !ok {
+		return models.ErrNotFound
+	}
+	prev := ck.response
+	ck.response = response
+	onRollback(tx, func() { ck.response = prev })
+	return nil
+}
+
+func (s *memOrders) DeleteCheckoutKey(userId int64, key string) error {
+	delete(s.db.keys, checkoutKeyOf(userId, key))
+	return nil
+}
+
+// ── Carts
+
+type memCarts struct {
+	repository.CartStore
+	db *memDB
+}
+
+func (s *memCarts) Upsert(userId, productId, variantId int64, qty int) error {
+	p, ok := s.db.products[productId]
+	if !ok {
+		return models.ErrNotFound
+	}
+	lines := s.db.carts[userId]
+	for i := range lines {
+		if lines[i].ProductId == productId && lines[i].VariantId == variantId {
+			lines[i].Quantity = qty
+			return nil
+		}
+	}
+	s.db.carts[userId] = append(lines, models.CartItem{
+		UserId: userId, ProductId: productId, VariantId: variantId,
+		Quantity: qty, UnitPrice: p.Price, Product: p,
+	})
+	return nil
+}
+
+func (s *memCarts) GetCartTx(tx repository.Tx, userId int64) ([]models.CartItem, error) {
+	return append([]models.CartItem(nil), s.db.carts[userId]...), nil
+}
+
+func (s *memCarts) ClearCart(tx repository.Tx, userId int64) error {
+	prev := s.db.carts[userId]
+	delete(s.db.carts, userId)
+	onRollback(tx, func() { s.db.carts[userId] = prev })
+	return nil
+}
+
+// ── Products and stock
+
+type memProducts struct {
+	repository.ProductStore
+	db *memDB
+}
+
+func (s *memProducts) DecrementStock(tx repository.Tx, productID int64, qty int) error {
+	p, ok := s.db.products[productID]
+	if !ok {
+		return models.ErrNotFound
+	}
+	if p.Stock < qty {
+		return models.ErrInsufficientStock
+	}
+	p.Stock -= qty
+	onRollback(tx, func() { p.Stock += qty })
+	return nil
+}
+
+func (s *memProducts) IncrementStock(tx repository.Tx, productID int64, qty int) error {
+	p, ok := s.db.products[productID]
+	if !ok {
+		return models.ErrNotFound
+	}
+	p.Stock += qty
+	onRollback(tx, func() { p.Stock -= qty })
+	return nil
+}
+
+type memInventory struct {
+	repository.InventoryStore
+	db *memDB
+}
+
+func (s *memInventory) BeginTx() (repository.Tx, error) {
+	return &memTx{}, nil
+}
+
+func (s *memInventory) Available(tx repository.Tx, productId, variantId, userId int64) (int, error) {
+	p, ok := s.db.products[productId]
+	if !ok {
+		return 0, models.ErrNotFound
+	}
+	available := p.Stock
+	for holder, held := range s.db.reservations {
+		if holder != userId {
+			available -= held[productId]
+		}
+	}
+	return available, nil
+}
+
+func (s *memInventory) Reserve(tx repository.Tx, userId, productId, variantId int64, qty int, ttl time.Duration) error {
+	held := s.db.reservations[userId]
+	if held == nil {
+		held = make(map[int64]int)
+		s.db.reservations[userId] = held
+	}
+	prev, had := held[productId]
+	held[productId] = qty
+	onRollback(tx, func() {
+		if had {
+			held[productId] = prev
+		} else {
+			delete(held, productId)
+		}
+	})
+	return nil
+}
+
+func (s *memInventory) ReleaseUser(tx repository.Tx, userId int64) error {
+	prev, had := s.db.reservations[userId]
+	delete(s.db.reservations, userId)
+	onRollback(tx, func() {
+		if had {
+			s.db.reservations[userId] = prev
+		}
+	})
+	return nil
+}
+
+func (s *memInventory) RecordMovement(tx repository.Tx, m *models.InventoryMovement) error {
+	m.StockAfter = s.db.products[m.ProductId].Stock
+	s.db.movements = append(s.db.movements, *m)
+	onRollback(tx, func() { s.db.movements = s.db.movements[:len(s.db.movements)-1] })
+	return nil
+}
+
+// ── Pricing
+
+// memPricing has no discounts, coupons or tax rates
+type memPricing struct {
+	repository.PricingStore
+}
+
+func (s *memPricing) ActiveDiscounts(productIds []int64) ([]models.Discount, error) {
+	return nil, nil
+}
+
+// ── Alerts
+
+// recordedAlerts keeps the subjects of alerts instead of sending them
+type recordedAlerts struct {
+	subjects []string
+}
+
+func (a *recordedAlerts) Alert(subject, body string) {
+	a.subjects = append(a.subjects, subject)
+}
+
+// ── Mail
+
+// sentMail is an email captured by recordedMail
+type sentMail struct {
+	to, subject, body string
+}
+
+// recordedMail keeps emails instead of sending them
+type recordedMail struct {
+	sent []sentMail
+}
+
+func (m *recordedMail) Send(to, subject, body string) error {
+	m.sent = append(m.sent, sentMail{to, subject, body})
+	return nil
+}
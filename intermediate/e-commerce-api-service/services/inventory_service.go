@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+)
+
+// InventoryService holds stock for carts, keeps the inventory ledger and
+// reports low stock.
+type InventoryService struct {
+	repo              repository.InventoryStore
+	productRepo       repository.ProductStore
+	alerts            Alerter
+	reservationTTL    time.Duration
+	lowStockThreshold int
+}
+
+// NewInventoryService creates a new InventoryService. Cart reservations last
+// reservationTTL; stock falling to lowStockThreshold or below is sent to alerts.
+func NewInventoryService(ir repository.InventoryStore, pr repository.ProductStore, alerts Alerter, reservationTTL time.Duration, lowStockThreshold int) *InventoryService {
+	return &InventoryService{
+		repo: ir, productRepo: pr, alerts: alerts,
+		reservationTTL: reservationTTL, lowStockThreshold: lowStockThreshold,
+	}
+}
+
+// ── Reservations
+
+// Reserve holds qty units of a cart line for the user, replacing any earlier
+// hold on the same line and restarting its expiry. Units held by other users
+// are not available.
+func (s *InventoryService) Reserve(userId, productId, variantId int64, qty int) (err error) {
+	tx, err := s.repo.BeginTx()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("[INVENTORY] rollback failed: %v", rbErr)
+			}
+		}
+	}()
+
+	available, err := s.repo.Available(tx, productId, variantId, userId)
+	if err != nil {
+		return err
+	}
+	if available < qty {
+		err = fmt.Errorf("%w: only %d available", models.ErrInsufficientStock, max(available, 0))
+		return err
+	}
+	if err = s.repo.Reserve(tx, userId, productId, variantId, qty, s.reservationTTL); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Release drops the hold of a cart line.
+func (s *InventoryService) Release(userId, productId, variantId int64) error {
+	return s.repo.Release(userId, productId, variantId)
+}
+
+// ReleaseExpired deletes reservations whose hold has ended.
+func (s *InventoryService) ReleaseExpired() (int64, error) {
+	return s.repo.DeleteExpired()
+}
+
+// RunReservationSweeper releases expired reservations every interval until ctx is done.
+// Expired holds are already ignored when stock is checked; the sweep keeps the table small.
+func (s *InventoryService) RunReservationSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.ReleaseExpired()
+			if err != nil {
+				log.Printf("[INVENTORY] releasing expired reservations failed: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("[INVENTORY] Released %d expired reservation(s)", n)
+			}
+		}
+	}
+}
+
+// ── Stock changes inside an order transaction
+
+// sell takes a cart line out of stock for an order and records the sale.
+// The buyer's own reservation counts towards what they may buy.
+func (s *InventoryService) sell(tx repository.Tx, userId, orderId int64, item *models.CartItem) error {
+	available, err := s.repo.Available(tx, item.ProductId, item.VariantId, userId)
+	if err != nil {
+		return err
+	}
+	if available < item.Quantity {
+		return fmt.Errorf("%w: only %d of %s available", models.ErrInsufficientStock, max(available, 0), item.Product.Name)
+	}
+
+	if item.VariantId > 0 {
+		err = s.productRepo.DecrementVariantStock(tx, item.VariantId, item.Quantity)
+	} else {
+		err = s.productRepo.DecrementStock(tx, item.ProductId, item.Quantity)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.record(tx, &models.InventoryMovement{
+		ProductId: item.ProductId, VariantId: item.VariantId,
+		Type: models.MovementSale, Quantity: -item.Quantity, OrderId: &orderId,
+	})
+}
+
+// returnToStock puts the items of a cancelled order back into stock.
+func (s *InventoryService) returnToStock(tx repository.Tx, order *models.Order, note string) error {
+	for _, item := range order.Items {
+		var err error
+		if item.VariantId > 0 {
+			err = s.productRepo.IncrementVariantStock(tx, item.VariantId, item.Quantity)
+		} else {
+			err = s.productRepo.IncrementStock(tx, item.ProductId, item.Quantity)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.record(tx, &models.InventoryMovement{
+			ProductId: item.ProductId, VariantId: item.VariantId,
+			Type: models.MovementReturn, Quantity: item.Quantity, OrderId: &order.ID, Note: note,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseUser drops all of a user's reservations once checkout has sold them.
+func (s *InventoryService) releaseUser(tx repository.Tx, userId int64) error {
+	return s.repo.ReleaseUser(tx, userId)
+}
+
+// record appends m to the ledger and sends an alert when it takes the stock
+// down to the low-stock threshold.
+func (s *InventoryService) record(tx repository.Tx, m *models.InventoryMovement) error {
+	if err := s.repo.RecordMovement(tx, m); err != nil {
+		return err
+	}
+	if m.Quantity < 0 && m.StockAfter <= s.lowStockThreshold && m.StockAfter-m.Quantity > s.lowStockThreshold {
+		s.alerts.Alert(
+			fmt.Sprintf("Low stock: product %d", m.ProductId),
+			fmt.Sprintf("Product %d variant %d has %d left after a %s of %d.", m.ProductId, m.VariantId, m.StockAfter, m.Type, -m.Quantity),
+		)
+	}
+	return nil
+}
+
+// recordChange logs a stock change made outside an order, such as a product
+// created with stock or an edited stock level.
+func (s *InventoryService) recordChange(m *models.InventoryMovement) (err error) {
+	tx, err := s.repo.BeginTx()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("[INVENTORY] rollback failed: %v", rbErr)
+			}
+		}
+	}()
+
+	if err = s.record(tx, m); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ── Admin
+
+// RecordMovement applies a restock, return or adjustment entered by an admin
+// and records it in the ledger with the admin's user ID.
+func (s *InventoryService) RecordMovement(adminId int64, req models.InventoryMovementRequest) (m *models.InventoryMovement, err error) {
+	switch req.Type {
+	case models.MovementRestock, models.MovementReturn:
+		if req.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive for a %s", models.ErrBadRequest, req.Type)
+		}
+	case models.MovementAdjustment:
+		if req.Quantity == 0 {
+			return nil, fmt.Errorf("%w: quantity must not be zero", models.ErrBadRequest)
+		}
+	case models.MovementSale:
+		return nil, fmt.Errorf("%w: sales are recorded by checkout", models.ErrBadRequest)
+	default:
+		return nil, fmt.Errorf("%w: type must be restock, return or adjustment", models.ErrBadRequest)
+	}
+
+	// Products with variants keep their stock per variant
+	product, err := s.productRepo.FindById(req.ProductId)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case req.VariantId > 0:
+		variant, err := s.productRepo.FindVariant(req.VariantId)
+		if err != nil {
+			return nil, err
+		}
+		if variant.ProductId != product.ID {
+			return nil, fmt.Errorf("%w: variant does not belong to product", models.ErrBadRequest)
+		}
+	case len(product.Variants) > 0:
+		return nil, fmt.Errorf("%w: %s has variants; choose one", models.ErrBadRequest, product.Name)
+	}
+
+	tx, err := s.repo.BeginTx()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("[INVENTORY] rollback failed: %v", rbErr)
+			}
+		}
+	}()
+
+	switch {
+	case req.VariantId > 0 && req.Quantity > 0:
+		err = s.productRepo.IncrementVariantStock(tx, req.VariantId, req.Quantity)
+	case req.VariantId > 0:
+		err = s.productRepo.DecrementVariantStock(tx, req.VariantId, -req.Quantity)
+	case req.Quantity > 0:
+		err = s.productRepo.IncrementStock(tx, req.ProductId, req.Quantity)
+	default:
+		err = s.productRepo.DecrementStock(tx, req.ProductId, -req.Quantity)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m = &models.InventoryMovement{
+		ProductId: req.ProductId, VariantId: req.VariantId, Type: req.Type,
+		Quantity: req.Quantity, UserId: &adminId, Note: req.Note,
+	}
+	if err = s.record(tx, m); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return m, nil
+}
+
+// ListMovements returns ledger entries, newest first. The limit defaults to 50 and is capped at 500.
+func (s *InventoryService) ListMovements(q models.MovementQuery) ([]models.InventoryMovement, error) {
+	if q.Type != "" && !q.Type.IsValid() {
+		return nil, fmt.Errorf("%w: unknown movement type %q", models.ErrBadRequest, q.Type)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	q.Limit = min(q.Limit, 500)
+	return s.repo.ListMovements(q)
+}
+
+// LowStock lists stock at or below threshold; a negative threshold uses the configured one.
+func (s *InventoryService) LowStock(threshold int) ([]models.StockLevel, error) {
+	if threshold < 0 {
+		threshold = s.lowStockThreshold
+	}
+	return s.repo.LowStock(threshold)
+}
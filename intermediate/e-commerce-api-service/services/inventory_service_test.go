@@ -0,0 +1,120 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+)
+
+// newTestInventory returns an InventoryService on SQLite with users 1 and 2
+// and a mug with 5 in stock. Stock at 3 or below is low.
+func newTestInventory(t *testing.T) (*InventoryService, *sql.DB, *recordedAlerts) {
+	t.Helper()
+	db := newTestDB(t)
+	mustExec(t, db,
+		`INSERT INTO users (id, email, password) VALUES (1, 'ann@example.com', 'hash'), (2, 'bob@example.com', 'hash')`,
+		`INSERT INTO products (id, name, price, stock) VALUES (1, 'Mug', 1500, 5)`,
+	)
+	alerts := &recordedAlerts{}
+	svc := NewInventoryService(repository.NewInventoryRepo(db), repository.NewProductRepo(db), alerts, 15*time.Minute, 3)
+	return svc, db, alerts
+}
+
+func TestReserveExcludesOtherUsersHolds(t *testing.T) {
+	svc, _, _ := newTestInventory(t)
+
+	if err := svc.Reserve(1, 1, 0, 3); err != nil {
+		t.Fatalf("first hold: %v", err)
+	}
+	if err := svc.Reserve(2, 1, 0, 3); !errors.Is(err, models.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock with 3 of 5 held, got %v", err)
+	}
+	if err := svc.Reserve(2, 1, 0, 2); err != nil {
+		t.Fatalf("holding the remaining 2: %v", err)
+	}
+
+	// A user's own hold does not count against them when they change it
+	if err := svc.Reserve(1, 1, 0, 3); err != nil {
+		t.Fatalf("renewing own hold: %v", err)
+	}
+	if err := svc.Reserve(1, 1, 0, 4); !errors.Is(err, models.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock growing past the free stock, got %v", err)
+	}
+
+	if err := svc.Release(2, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Reserve(1, 1, 0, 5); err != nil {
+		t.Fatalf("holding everything after release: %v", err)
+	}
+}
+
+func TestReleaseExpired(t *testing.T) {
+	svc, db, _ := newTestInventory(t)
+
+	if err := svc.Reserve(1, 1, 0, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Reserve(2, 1, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, db, `UPDATE stock_reservations SET expires_at = datetime('now', '-1 seconds') WHERE user_id = 1`)
+
+	// An expired hold stops counting before it is swept
+	if err := svc.Reserve(2, 1, 0, 5); err != nil {
+		t.Fatalf("expired hold still counted: %v", err)
+	}
+
+	n, err := svc.ReleaseExpired()
+	if err != nil || n != 1 {
+		t.Fatalf("ReleaseExpired = %d, %v; want 1", n, err)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM stock_reservations`).Scan(&left); err != nil || left != 1 {
+		t.Fatalf("expected only the live hold left, got %d (%v)", left, err)
+	}
+	if n, err := svc.ReleaseExpired(); err != nil || n != 0 {
+		t.Fatalf("second sweep = %d, %v; want 0", n, err)
+	}
+}
+
+func TestLedgerStockAfter(t *testing.T) {
+	svc, _, alerts := newTestInventory(t)
+
+	for _, req := range []models.InventoryMovementRequest{
+		{ProductId: 1, Type: models.MovementRestock, Quantity: 4},
+		{ProductId: 1, Type: models.MovementAdjustment, Quantity: -5},
+		{ProductId: 1, Type: models.MovementAdjustment, Quantity: -2},
+		{ProductId: 1, Type: models.MovementAdjustment, Quantity: -1},
+	} {
+		if _, err := svc.RecordMovement(1, req); err != nil {
+			t.Fatalf("RecordMovement(%+v): %v", req, err)
+		}
+	}
+	if _, err := svc.RecordMovement(1, models.InventoryMovementRequest{ProductId: 1, Type: models.MovementAdjustment, Quantity: -2}); !errors.Is(err, models.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock taking stock negative, got %v", err)
+	}
+
+	movements, err := svc.ListMovements(models.MovementQuery{ProductId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ quantity, stockAfter int }{{-1, 1}, {-2, 2}, {-5, 4}, {4, 9}}
+	if len(movements) != len(want) {
+		t.Fatalf("expected %d movements, got %+v", len(want), movements)
+	}
+	for i, m := range movements {
+		if m.Quantity != want[i].quantity || m.StockAfter != want[i].stockAfter || m.UserId == nil || *m.UserId != 1 {
+			t.Errorf("movement %d = %+v; want quantity %d, stock after %d, by user 1", i, m, want[i].quantity, want[i].stockAfter)
+		}
+	}
+
+	// Only the change that crossed the threshold alerts
+	if len(alerts.subjects) != 1 {
+		t.Fatalf("expected one low-stock alert, got %q", alerts.subjects)
+	}
+}
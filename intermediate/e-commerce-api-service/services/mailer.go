@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Supported values for the MAILER setting.
+const (
+	MailerLog  = "log"
+	MailerFile = "file"
+)
+
+// Mailer sends plain-text emails. Only local sinks are built in; an SMTP or
+// API-based mailer can be added by implementing this interface.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer builds the mailer selected by configuration. An empty name picks the log mailer.
+func NewMailer(name, from, dir string) (Mailer, error) {
+	switch name {
+	case "", MailerLog:
+		return &LogMailer{from: from}, nil
+	case MailerFile:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("creating mail directory: %w", err)
+		}
+		return &FileMailer{from: from, dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q (want %q or %q)", name, MailerLog, MailerFile)
+	}
+}
+
+// LogMailer writes emails to the application log instead of sending them.
+type LogMailer struct {
+	from string
+}
+
+// Send logs the email.
+func (m *LogMailer) Send(to, subject, body string) error {
+	log.Printf("[MAIL] From: %s To: %s Subject: %s\n%s", m.from, to, subject, body)
+	return nil
+}
+
+// FileMailer writes each email to its own .eml file in a directory, where it
+// can be opened with a mail client.
+type FileMailer struct {
+	from string
+	dir  string
+	seq  atomic.Int64
+}
+
+// Send writes the email to a file named after the time and recipient.
+func (m *FileMailer) Send(to, subject, body string) error {
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s-%03d-%s.eml", now.Format("20060102T150405"), m.seq.Add(1)%1000, sanitizeFileName(to))
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		m.from, to, subject, now.Format(time.RFC1123Z), body)
+
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, []byte(msg), 0o600); err != nil {
+		return fmt.Errorf("writing mail: %w", err)
+	}
+	log.Printf("[MAIL] %q to %s written to %s", subject, to, path)
+
+	return nil
+}
+
+// sanitizeFileName keeps letters, digits, dots and dashes.
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -1,8 +1,11 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
@@ -10,32 +13,121 @@ import (
 
 // OrderService handles order creation and payment processing.
 type OrderService struct {
-	orderRepo   *repository.OrderRepo
-	cartRepo    *repository.CartRepo
-	productRepo *repository.ProductRepo
-	paymentSvc  *PaymentService
+	orderRepo repository.OrderStore
+	cartRepo  repository.CartStore
+	inventory *InventoryService
+	pricing   *PricingService
+	addresses *AddressService
+	payments  PaymentProvider
+	alerts    Alerter
 }
 
-// NewOrderService creates a new OrderService with the given repositories and payment service.
+// NewOrderService creates a new OrderService with the given repositories,
+// inventory, pricing engine, address book, payment provider and alerter.
 func NewOrderService(
-	or *repository.OrderRepo,
-	cr *repository.CartRepo,
-	pr *repository.ProductRepo,
-	ps *PaymentService,
+	or repository.OrderStore,
+	cr repository.CartStore,
+	inv *InventoryService,
+	ps *PricingService,
+	as *AddressService,
+	pp PaymentProvider,
+	alerts Alerter,
 ) *OrderService {
 	return &OrderService{
 		orderRepo: or, cartRepo: cr,
-		productRepo: pr, paymentSvc: ps,
+		inventory: inv, pricing: ps, addresses: as, payments: pp,
+		alerts: alerts,
 	}
 }
 
-// Checkout is the core business operation. It runs inside a single
-// transaction to guarantee atomicity: either everything succeeds
-// (stock decremented, order created, cart cleared) or nothing changes.
-func (s *OrderService) Checkout(userId int64) (*models.CheckoutResponse, error) {
+// Checkout is the core business operation. The order itself is created
+// inside a single transaction to guarantee atomicity: either everything
+// succeeds (stock decremented, order created, cart cleared) or nothing changes.
+// Units reserved by other carts cannot be bought; the buyer's own
+// reservations are turned into the sale and released.
+// The payment is created after the commit so no network call holds the
+// database lock; if it fails, the order is cancelled and the cart restored.
+//
+// The coupon and tax region in req are validated strictly; the resulting
+// price breakdown is stored on the order and the coupon use is counted.
+// A copy of the shipping address is stored on the order.
+//
+// A non-empty idempotencyKey makes retries of the same request return the
+// original response instead of creating a second order.
+func (s *OrderService) Checkout(userId int64, idempotencyKey string, req models.CheckoutRequest) (*models.CheckoutResponse, error) {
+	if idempotencyKey != "" {
+		resp, err := s.replayCheckout(userId, idempotencyKey)
+		if err == nil || !errors.Is(err, models.ErrNotFound) {
+			return resp, err
+		}
+	}
+
+	addr, err := s.addresses.forCheckout(userId, req)
+	if err != nil {
+		return nil, err
+	}
+
+	orderID, breakdown, items, err := s.createOrder(userId, idempotencyKey, req.PricingOptions, addr)
+	if err != nil {
+		return nil, err
+	}
+	total := breakdown.Total
+
+	// Create the payment with the provider. Keying it on the order ID means
+	// a retried call for the same order never creates a second payment.
+	pi, err := s.payments.CreatePaymentIntent(total, "usd", fmt.Sprintf("order-%d", orderID))
+	if err != nil {
+		s.abortCheckout(userId, orderID, idempotencyKey, items)
+		return nil, fmt.Errorf("creating payment intent: %w", err)
+	}
+
+	resp := &models.CheckoutResponse{
+		OrderID:         orderID,
+		ClientSecret:    pi.ClientSecret,
+		StripePaymentId: pi.ID,
+		PriceBreakdown:  *breakdown,
+	}
+
+	if err := s.completeCheckout(userId, idempotencyKey, resp); err != nil {
+		// The client never sees this payment, so nobody may complete it
+		if cerr := s.payments.CancelPaymentIntent(pi.ID); cerr != nil {
+			log.Printf("[ORDER] cancelling payment %s failed: %v", pi.ID, cerr)
+		}
+		s.abortCheckout(userId, orderID, idempotencyKey, items)
+		return nil, err
+	}
+
+	log.Printf("[ORDER] Created order %d for user %d, total: %d cents (%s payment %s)",
+		orderID, userId, total, s.payments.Name(), pi.ID)
+
+	return resp, nil
+}
+
+// replayCheckout returns the stored response for an idempotency key.
+// It returns ErrNotFound when the key has not been used yet.
+func (s *OrderService) replayCheckout(userId int64, key string) (*models.CheckoutResponse, error) {
+	stored, err := s.orderRepo.FindCheckoutKey(userId, key)
+	if err != nil {
+		return nil, err
+	}
+	if stored == "" {
+		return nil, fmt.Errorf("%w: a checkout with this idempotency key is still in progress", models.ErrConflict)
+	}
+
+	var resp models.CheckoutResponse
+	if err := json.Unmarshal([]byte(stored), &resp); err != nil {
+		return nil, fmt.Errorf("decoding stored checkout response: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// createOrder turns the cart into a pending order in one transaction and
+// returns the order ID, its price breakdown and the items that were purchased.
+func (s *OrderService) createOrder(userId int64, idempotencyKey string, opts models.PricingOptions, addr *models.ShippingAddress) (orderID int64, breakdown *models.PriceBreakdown, items []models.CartItem, err error) {
 	tx, err := s.orderRepo.BeginTx()
 	if err != nil {
-		return nil, fmt.Errorf("beginning transaction: %w", err)
+		return 0, nil, nil, fmt.Errorf("beginning transaction: %w", err)
 	}
 	defer func() {
 		if err != nil {
@@ -46,64 +138,123 @@ func (s *OrderService) Checkout(userId int64) (*models.CheckoutResponse, error)
 	}()
 
 	// 1. Read cart within the transaction
-	items, err := s.cartRepo.GetCartTx(tx, userId)
+	items, err = s.cartRepo.GetCartTx(tx, userId)
 	if err != nil {
-		return nil, fmt.Errorf("reading cart: %w", err)
+		return 0, nil, nil, fmt.Errorf("reading cart: %w", err)
 	}
 	if len(items) == 0 {
 		err = models.ErrEmptyCart
-		return nil, err
-	}
-
-	// 2. Calculate total & validate stock
-	var total int64
-	for _, item := range items {
-		total += item.Product.Price * int64(item.Quantity)
+		return 0, nil, nil, err
 	}
 
-	// 3. Create Stripe PaymentIntent
-	pi, piErr := s.paymentSvc.CreatePaymentIntent(total, "usd")
-	if piErr != nil {
-		err = fmt.Errorf("creating payment intent: %w", piErr)
-		return nil, err
+	// 2. Price the basket: discounts, coupon, shipping and tax
+	breakdown, _, err = s.pricing.Quote(items, opts, true)
+	if err != nil {
+		return 0, nil, nil, err
 	}
 
-	// 4. Create order record
-	orderID, err := s.orderRepo.CreateOrder(tx, userId, total, pi.ID)
+	// 3. Create order record and count the coupon use
+	orderID, err = s.orderRepo.CreateOrder(tx, userId, breakdown, addr)
 	if err != nil {
-		return nil, err
+		return 0, nil, nil, err
+	}
+	if breakdown.CouponCode != "" {
+		if err = s.pricing.RedeemCoupon(tx, breakdown.CouponCode); err != nil {
+			return 0, nil, nil, err
+		}
 	}
 
-	// 5. Decrement stock & create order items
-	for _, item := range items {
-		if stockErr := s.productRepo.DecrementStock(tx, item.ProductId, item.Quantity); stockErr != nil {
-			err = stockErr
-			return nil, err
+	// 4. Decrement stock, record the sales & create order items
+	for i := range items {
+		item := &items[i]
+		if err = s.inventory.sell(tx, userId, orderID, item); err != nil {
+			return 0, nil, nil, err
 		}
-		if itemErr := s.orderRepo.CreateOrderItem(tx, orderID, item.ProductId,
-			item.Quantity, item.Product.Price); itemErr != nil {
-			err = itemErr
-			return nil, err
+		if err = s.orderRepo.CreateOrderItem(tx, orderID, item); err != nil {
+			return 0, nil, nil, err
 		}
 	}
 
-	// 6. Clear the cart
+	// 5. Clear the cart and its reservations
 	if err = s.cartRepo.ClearCart(tx, userId); err != nil {
-		return nil, fmt.Errorf("clearing cart: %w", err)
+		return 0, nil, nil, fmt.Errorf("clearing cart: %w", err)
+	}
+	if err = s.inventory.releaseUser(tx, userId); err != nil {
+		return 0, nil, nil, err
+	}
+
+	// 6. Reserve the idempotency key together with the order
+	if idempotencyKey != "" {
+		if err = s.orderRepo.CreateCheckoutKey(tx, userId, idempotencyKey, orderID); err != nil {
+			return 0, nil, nil, err
+		}
 	}
 
 	// 7. Commit
 	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("committing transaction: %w", err)
+		return 0, nil, nil, fmt.Errorf("committing transaction: %w", err)
 	}
 
-	log.Printf("[ORDER] Created order %d for user %d, total: %d cents", orderID, userId, total)
+	return orderID, breakdown, items, nil
+}
 
-	return &models.CheckoutResponse{
-		OrderID:         orderID,
-		ClientSecret:    pi.ClientSecret,
-		StripePaymentId: pi.ID,
-	}, nil
+// completeCheckout links the order to its payment and stores the response to
+// replay for the idempotency key. Both are saved in one transaction, so the
+// key never stays in progress for an order that has a payment.
+func (s *OrderService) completeCheckout(userId int64, idempotencyKey string, resp *models.CheckoutResponse) (err error) {
+	tx, err := s.orderRepo.BeginTx()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("[ORDER] rollback failed: %v", rbErr)
+			}
+		}
+	}()
+
+	if err = s.orderRepo.SetPaymentId(tx, resp.OrderID, resp.StripePaymentId); err != nil {
+		return err
+	}
+
+	if idempotencyKey != "" {
+		var body []byte
+		if body, err = json.Marshal(resp); err != nil {
+			return fmt.Errorf("encoding checkout response: %w", err)
+		}
+		if err = s.orderRepo.SaveCheckoutResponse(tx, userId, idempotencyKey, string(body)); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// abortCheckout undoes an order whose payment could not be created or saved:
+// the order is cancelled (restoring stock), the cart is refilled and the
+// idempotency key is released so the client can retry.
+func (s *OrderService) abortCheckout(userId, orderID int64, idempotencyKey string, items []models.CartItem) {
+	if err := s.UpdateStatus(orderID, models.OrderCancelled); err != nil {
+		log.Printf("[ORDER] cancelling order %d failed: %v", orderID, err)
+	}
+	for _, item := range items {
+		if err := s.cartRepo.Upsert(userId, item.ProductId, item.VariantId, item.Quantity); err != nil {
+			log.Printf("[ORDER] restoring cart item %d failed: %v", item.ProductId, err)
+			continue
+		}
+		if err := s.inventory.Reserve(userId, item.ProductId, item.VariantId, item.Quantity); err != nil {
+			log.Printf("[ORDER] reserving restored cart item %d failed: %v", item.ProductId, err)
+		}
+	}
+	if idempotencyKey != "" {
+		if err := s.orderRepo.DeleteCheckoutKey(userId, idempotencyKey); err != nil {
+			log.Printf("[ORDER] releasing idempotency key failed: %v", err)
+		}
+	}
 }
 
 // GetOrder returns a single order by ID, ensuring the user has permission to view it.
@@ -124,7 +275,324 @@ func (s *OrderService) ListOrders(userId int64) ([]models.Order, error) {
 	return s.orderRepo.ListByUser(userId)
 }
 
-// ConfirmPayment is called via Stripe webhook or manual confirmation.
+// ListAllOrders returns a page of orders across all customers, newest first.
+// The limit defaults to 20 and is capped at 100.
+func (s *OrderService) ListAllOrders(q models.OrderQuery) (*models.OrderListResult, error) {
+	if err := validateOrderQuery(q); err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	q.Limit = min(q.Limit, 100)
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+
+	orders, total, err := s.orderRepo.ListAll(q)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OrderListResult{Items: orders, Total: total, Page: q.Page, Limit: q.Limit}, nil
+}
+
+// ExportOrders returns every order matching the filters, newest first, for the CSV export.
+func (s *OrderService) ExportOrders(q models.OrderQuery) ([]models.Order, error) {
+	if err := validateOrderQuery(q); err != nil {
+		return nil, err
+	}
+	q.Page, q.Limit = 1, 0
+	orders, _, err := s.orderRepo.ListAll(q)
+	return orders, err
+}
+
+// validateOrderQuery rejects unknown statuses and reversed date ranges.
+func validateOrderQuery(q models.OrderQuery) error {
+	if q.Status != "" && !q.Status.IsValid() {
+		return fmt.Errorf("%w: unknown order status %q", models.ErrBadRequest, q.Status)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return fmt.Errorf("%w: to is before from", models.ErrBadRequest)
+	}
+	return nil
+}
+
+// FindOrder returns any order by ID with its items, for admins.
+func (s *OrderService) FindOrder(orderId int64) (*models.Order, error) {
+	return s.orderRepo.FindById(orderId)
+}
+
+// SetStatus lets an admin move an order through the state machine, e.g. to
+// mark it shipped or delivered. Refunds must go through RefundOrder so the
+// payment is actually returned.
+func (s *OrderService) SetStatus(orderId int64, next models.OrderStatus) error {
+	switch next {
+	case models.OrderRefunded:
+		return fmt.Errorf("%w: use the refund endpoint to refund an order", models.ErrBadRequest)
+	case models.OrderCancelled:
+		return s.cancelOrder(orderId)
+	}
+	return s.UpdateStatus(orderId, next)
+}
+
+// Bounds of the sales report
+const (
+	defaultReportDays  = 30
+	maxReportDays      = 366
+	defaultTopProducts = 5
+	maxTopProducts     = 50
+)
+
+// SalesReport summarises sales between from and to (inclusive, UTC days):
+// revenue per day, order count, average order value and the top products by revenue.
+// A zero from defaults to 30 days before to, and a zero to defaults to today.
+func (s *OrderService) SalesReport(from, to time.Time, top int) (*models.SalesReport, error) {
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	to = to.UTC().Truncate(24 * time.Hour)
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -(defaultReportDays - 1))
+	}
+	from = from.UTC().Truncate(24 * time.Hour)
+
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: to is before from", models.ErrBadRequest)
+	}
+	if to.Sub(from) >= maxReportDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: the report covers at most %d days", models.ErrBadRequest, maxReportDays)
+	}
+	if top <= 0 {
+		top = defaultTopProducts
+	}
+	top = min(top, maxTopProducts)
+
+	days, err := s.orderRepo.SalesByDay(from, to)
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.orderRepo.TopProducts(from, to, top)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.SalesReport{
+		From:        from.Format(time.DateOnly),
+		To:          to.Format(time.DateOnly),
+		TopProducts: products,
+	}
+
+	// Fill in the days without sales so the series can be charted directly
+	sales := make(map[string]models.DailySales, len(days))
+	for _, d := range days {
+		sales[d.Date] = d
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		d, ok := sales[day.Format(time.DateOnly)]
+		if !ok {
+			d = models.DailySales{Date: day.Format(time.DateOnly)}
+		}
+		report.Daily = append(report.Daily, d)
+		report.Orders += d.Orders
+		report.Revenue += d.Revenue
+	}
+	if report.Orders > 0 {
+		report.AverageOrderValue = report.Revenue / int64(report.Orders)
+	}
+
+	return report, nil
+}
+
+// ConfirmPayment marks a pending order as paid.
 func (s *OrderService) ConfirmPayment(orderId int64) error {
-	return s.orderRepo.UpdateStatus(orderId, "paid")
+	return s.UpdateStatus(orderId, models.OrderPaid)
+}
+
+// CancelOrder lets a customer cancel one of their own orders.
+// Only pending orders can be cancelled; their stock is restored.
+func (s *OrderService) CancelOrder(userId, orderId int64) error {
+	if _, err := s.GetOrder(userId, orderId); err != nil {
+		return err
+	}
+	return s.cancelOrder(orderId)
+}
+
+// cancelOrder voids the payment of a pending order, then cancels the order.
+// A payment the customer already completed cannot be voided; the order then
+// stays pending and is marked paid by the webhook.
+func (s *OrderService) cancelOrder(orderId int64) error {
+	order, err := s.orderRepo.FindById(orderId)
+	if err != nil {
+		return err
+	}
+
+	if order.Status == models.OrderPending && order.StripePaymentId != "" {
+		err := s.payments.CancelPaymentIntent(order.StripePaymentId)
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			// The provider never took this payment, so nothing can complete it
+			log.Printf("[ORDER] Order %d: payment %s is unknown to %s", orderId, order.StripePaymentId, s.payments.Name())
+		case errors.Is(err, models.ErrConflict):
+			return fmt.Errorf("%w: the order has already been paid", models.ErrInvalidTransition)
+		case err != nil:
+			return fmt.Errorf("cancelling payment: %w", err)
+		}
+	}
+
+	return s.UpdateStatus(orderId, models.OrderCancelled)
+}
+
+// CheckPaymentAccess returns ErrNotFound unless the payment belongs to one of
+// the user's orders. Admins may access any payment.
+func (s *OrderService) CheckPaymentAccess(userId int64, admin bool, paymentId string) error {
+	orderId, err := s.orderRepo.FindIdByPaymentId(paymentId)
+	if err != nil {
+		return err
+	}
+	if admin {
+		return nil
+	}
+
+	order, err := s.orderRepo.FindById(orderId)
+	if err != nil {
+		return err
+	}
+	// Other users' payments look like unknown ones
+	if order.UserId != userId {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// HandlePaymentSucceeded marks the order linked to a PaymentIntent as paid.
+// A payment that completes after its order was cancelled, when the stock is
+// already back on sale, is refunded in full and reported through the alerter.
+func (s *OrderService) HandlePaymentSucceeded(paymentId string) error {
+	orderId, err := s.orderRepo.FindIdByPaymentId(paymentId)
+	if err != nil {
+		return err
+	}
+	err = s.UpdateStatus(orderId, models.OrderPaid)
+	if !errors.Is(err, models.ErrInvalidTransition) {
+		return err
+	}
+
+	order, findErr := s.orderRepo.FindById(orderId)
+	if findErr != nil {
+		return findErr
+	}
+	if order.Status != models.OrderCancelled {
+		return err
+	}
+	return s.refundCancelled(order)
+}
+
+// refundCancelled returns a payment captured for a cancelled order. A failed
+// refund is returned so the webhook is delivered again and the refund retried.
+func (s *OrderService) refundCancelled(order *models.Order) error {
+	refund, err := s.payments.Refund(order.StripePaymentId, order.Total, fmt.Sprintf("refund-cancelled-order-%d", order.ID))
+	if err != nil {
+		s.alerts.Alert(fmt.Sprintf("Refund failed: order %d", order.ID),
+			fmt.Sprintf("Payment %s of %d cents succeeded after order %d was cancelled and could not be refunded: %v.",
+				order.StripePaymentId, order.Total, order.ID, err))
+		return fmt.Errorf("refunding payment of cancelled order: %w", err)
+	}
+
+	s.alerts.Alert(fmt.Sprintf("Payment refunded: order %d", order.ID),
+		fmt.Sprintf("Payment %s succeeded after order %d was cancelled; %d cents were refunded (%s).",
+			order.StripePaymentId, order.ID, refund.Amount, refund.ID))
+	return nil
+}
+
+// HandlePaymentFailed cancels the order linked to a PaymentIntent and restores
+// its stock. The payment is voided too, so the customer cannot retry it for
+// an order that no longer holds stock.
+func (s *OrderService) HandlePaymentFailed(paymentId string) error {
+	orderId, err := s.orderRepo.FindIdByPaymentId(paymentId)
+	if err != nil {
+		return err
+	}
+	return s.cancelOrder(orderId)
+}
+
+// RefundOrder refunds the full payment of an order through the payment provider
+// and marks the order as refunded.
+func (s *OrderService) RefundOrder(orderId int64) error {
+	order, err := s.orderRepo.FindById(orderId)
+	if err != nil {
+		return err
+	}
+	if !order.Status.CanTransitionTo(models.OrderRefunded) {
+		return fmt.Errorf("%w: cannot refund a %s order", models.ErrInvalidTransition, order.Status)
+	}
+	if order.StripePaymentId == "" {
+		return fmt.Errorf("%w: order has no payment to refund", models.ErrBadRequest)
+	}
+
+	refund, err := s.payments.Refund(order.StripePaymentId, order.Total, fmt.Sprintf("refund-order-%d", orderId))
+	if err != nil {
+		return fmt.Errorf("refunding payment: %w", err)
+	}
+	log.Printf("[ORDER] Refunded %d cents for order %d (%s)", refund.Amount, orderId, refund.ID)
+
+	return s.UpdateStatus(orderId, models.OrderRefunded)
+}
+
+// UpdateStatus moves an order to the next status, enforcing the order state machine.
+// Repeating the current status is a no-op so replayed webhooks are harmless.
+// Cancelling a pending order puts its items back into stock (recorded as returns
+// in the inventory ledger) and gives back its coupon use in the same transaction.
+func (s *OrderService) UpdateStatus(orderId int64, next models.OrderStatus) (err error) {
+	if !next.IsValid() {
+		return fmt.Errorf("%w: unknown order status %q", models.ErrBadRequest, next)
+	}
+
+	tx, err := s.orderRepo.BeginTx()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("[ORDER] rollback failed: %v", rbErr)
+			}
+		}
+	}()
+
+	order, err := s.orderRepo.FindByIdTx(tx, orderId)
+	if err != nil {
+		return err
+	}
+
+	if order.Status == next {
+		return tx.Commit()
+	}
+	if !order.Status.CanTransitionTo(next) {
+		err = fmt.Errorf("%w: cannot move order from %s to %s", models.ErrInvalidTransition, order.Status, next)
+		return err
+	}
+
+	// Release reserved stock when an unpaid order is cancelled
+	if order.Status == models.OrderPending && next == models.OrderCancelled {
+		if err = s.inventory.returnToStock(tx, order, "order cancelled"); err != nil {
+			return err
+		}
+		if order.CouponCode != "" {
+			if err = s.pricing.ReleaseCoupon(tx, order.CouponCode); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = s.orderRepo.UpdateStatusTx(tx, orderId, order.Status, next); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	log.Printf("[ORDER] Order %d: %s → %s", orderId, order.Status, next)
+
+	return nil
 }
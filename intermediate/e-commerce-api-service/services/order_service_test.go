@@ -0,0 +1,179 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+)
+
+const testUser = 7
+
+// newTestOrderService wires an OrderService to in-memory stores holding one
+// product with 5 in stock, 2 of which are in the test user's cart.
+func newTestOrderService(t *testing.T) (*OrderService, *memDB) {
+	t.Helper()
+	db := newMemDB()
+	db.products[1] = &models.Product{ID: 1, Name: "Mug", Price: 1500, Stock: 5}
+
+	carts := &memCarts{db: db}
+	inventory := NewInventoryService(&memInventory{db: db}, &memProducts{db: db}, LogAlerter{}, 15*time.Minute, 0)
+	pricing := NewPricingService(&memPricing{}, nil, 500, 5000)
+	svc := NewOrderService(&memOrders{db: db}, carts, inventory, pricing, NewAddressService(nil), NewFakeProvider(""), LogAlerter{})
+
+	if err := carts.Upsert(testUser, 1, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := inventory.Reserve(testUser, 1, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	return svc, db
+}
+
+func testCheckoutRequest() models.CheckoutRequest {
+	return models.CheckoutRequest{ShippingAddress: &models.ShippingAddress{
+		Name: "Ada", Line1: "1 Main St", City: "Springfield", PostalCode: "12345", Country: "us",
+	}}
+}
+
+func TestCheckout(t *testing.T) {
+	svc, db := newTestOrderService(t)
+
+	resp, err := svc.Checkout(testUser, "key-1", testCheckoutRequest())
+	if err != nil {
+		t.Fatalf("Checkout returned error: %v", err)
+	}
+	if resp.Total != 3500 || resp.StripePaymentId == "" || resp.ClientSecret == "" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	order := db.orders[resp.OrderID]
+	if order.Status != models.OrderPending || order.StripePaymentId != resp.StripePaymentId || len(order.Items) != 1 {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+	if order.ShippingAddress == nil || order.ShippingAddress.Country != "US" {
+		t.Fatalf("expected the normalized address on the order, got %+v", order.ShippingAddress)
+	}
+	if db.products[1].Stock != 3 || len(db.carts[testUser]) != 0 || len(db.reservations[testUser]) != 0 {
+		t.Fatalf("expected stock sold and cart cleared, got stock %d, cart %+v, holds %+v",
+			db.products[1].Stock, db.carts[testUser], db.reservations[testUser])
+	}
+	if len(db.movements) != 1 || db.movements[0].Quantity != -2 || db.movements[0].StockAfter != 3 {
+		t.Fatalf("expected the sale in the ledger, got %+v", db.movements)
+	}
+
+	// A retry with the same key replays the response without a second order
+	replay, err := svc.Checkout(testUser, "key-1", testCheckoutRequest())
+	if err != nil || replay.OrderID != resp.OrderID || replay.StripePaymentId != resp.StripePaymentId {
+		t.Fatalf("expected the original response, got %+v (%v)", replay, err)
+	}
+	if len(db.orders) != 1 {
+		t.Fatalf("expected one order, got %d", len(db.orders))
+	}
+
+	if _, err := svc.Checkout(testUser, "key-2", testCheckoutRequest()); !errors.Is(err, models.ErrEmptyCart) {
+		t.Fatalf("expected ErrEmptyCart, got %v", err)
+	}
+}
+
+func TestCheckoutReleasesKeyWhenSaveFails(t *testing.T) {
+	for _, method := range []string{"SetPaymentId", "SaveCheckoutResponse"} {
+		t.Run(method, func(t *testing.T) {
+			svc, db := newTestOrderService(t)
+			db.fail[method] = errors.New("disk full")
+
+			if _, err := svc.Checkout(testUser, "key-1", testCheckoutRequest()); err == nil {
+				t.Fatalf("expected an error")
+			}
+
+			// The order is cancelled and everything else undone
+			order := db.orders[1]
+			if order.Status != models.OrderCancelled || order.StripePaymentId != "" {
+				t.Fatalf("expected a cancelled order without payment, got %+v", order)
+			}
+			if db.products[1].Stock != 5 {
+				t.Fatalf("expected stock restored, got %d", db.products[1].Stock)
+			}
+			if cart := db.carts[testUser]; len(cart) != 1 || cart[0].Quantity != 2 || db.reservations[testUser][1] != 2 {
+				t.Fatalf("expected cart refilled and held, got %+v, holds %+v", cart, db.reservations[testUser])
+			}
+			if len(db.keys) != 0 {
+				t.Fatalf("expected the idempotency key released, got %+v", db.keys)
+			}
+			for id, pi := range svc.payments.(*FakeProvider).intents {
+				if pi.Status != fakeStatusCanceled {
+					t.Fatalf("expected the unsaved payment %s cancelled, got %s", id, pi.Status)
+				}
+			}
+
+			// So a retry with the same key goes through instead of a 409
+			delete(db.fail, method)
+			resp, err := svc.Checkout(testUser, "key-1", testCheckoutRequest())
+			if err != nil {
+				t.Fatalf("retry returned error: %v", err)
+			}
+			if resp.OrderID == order.ID || db.orders[resp.OrderID].StripePaymentId != resp.StripePaymentId {
+				t.Fatalf("expected a new paid-for order, got %+v", resp)
+			}
+		})
+	}
+}
+
+func TestCheckPaymentAccess(t *testing.T) {
+	svc, _ := newTestOrderService(t)
+	resp, err := svc.Checkout(testUser, "", testCheckoutRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.CheckPaymentAccess(testUser, false, resp.StripePaymentId); err != nil {
+		t.Fatalf("owner denied: %v", err)
+	}
+	if err := svc.CheckPaymentAccess(testUser+1, true, resp.StripePaymentId); err != nil {
+		t.Fatalf("admin denied: %v", err)
+	}
+	if err := svc.CheckPaymentAccess(testUser+1, false, resp.StripePaymentId); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("other user: expected ErrNotFound, got %v", err)
+	}
+	if err := svc.CheckPaymentAccess(testUser, false, "pi_unknown"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("unknown payment: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCancelOrderVoidsPayment(t *testing.T) {
+	svc, db := newTestOrderService(t)
+	fake := svc.payments.(*FakeProvider)
+	resp, err := svc.Checkout(testUser, "", testCheckoutRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.CancelOrder(testUser, resp.OrderID); err != nil {
+		t.Fatalf("CancelOrder returned error: %v", err)
+	}
+	if got := db.orders[resp.OrderID].Status; got != models.OrderCancelled {
+		t.Fatalf("expected the order cancelled, got %s", got)
+	}
+	if _, err := fake.Settle(resp.StripePaymentId, true); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("expected the cancelled payment to be refused, got %v", err)
+	}
+
+	// An order the customer already paid cannot be cancelled before the webhook arrives
+	if err := (&memCarts{db: db}).Upsert(testUser, 1, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = svc.Checkout(testUser, "", testCheckoutRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.Settle(resp.StripePaymentId, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.CancelOrder(testUser, resp.OrderID); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+	if got := db.orders[resp.OrderID].Status; got != models.OrderPending {
+		t.Fatalf("expected the paid order still pending, got %s", got)
+	}
+}
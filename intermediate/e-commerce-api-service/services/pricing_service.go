@@ -0,0 +1,360 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+)
+
+// PricingService manages coupons, discounts and tax rates and prices baskets.
+type PricingService struct {
+	pricingRepo     repository.PricingStore
+	categoryRepo    repository.CategoryStore
+	shippingFee     int64 // flat fee in cents
+	freeShippingMin int64 // subtotal after discounts that ships free; 0 = never
+}
+
+// NewPricingService creates a new PricingService with the given shipping settings.
+func NewPricingService(pr repository.PricingStore, cr repository.CategoryStore, shippingFee, freeShippingMin int64) *PricingService {
+	return &PricingService{
+		pricingRepo: pr, categoryRepo: cr,
+		shippingFee: shippingFee, freeShippingMin: freeShippingMin,
+	}
+}
+
+// Quote prices a basket. The steps are applied in this order:
+//
+//  1. subtotal of unit price × quantity
+//  2. the best active product or category discount per line (stored on each item)
+//  3. the coupon, on the discounted subtotal
+//  4. shipping, free above the configured threshold
+//  5. tax for the region, on the goods after discounts (not on shipping)
+//
+// In strict mode (checkout) an unusable coupon or unknown region is an error.
+// Otherwise (cart preview) it is skipped and reported in the returned warnings.
+func (s *PricingService) Quote(items []models.CartItem, opts models.PricingOptions, strict bool) (*models.PriceBreakdown, []string, error) {
+	b := &models.PriceBreakdown{}
+	var warnings []string
+
+	now := time.Now()
+
+	// 1-2. Subtotal and product discounts
+	discounts, err := s.bestDiscounts(items, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range items {
+		item := &items[i]
+		line := item.UnitPrice * int64(item.Quantity)
+		item.Discount = 0
+		if d, ok := discounts[i]; ok {
+			item.Discount = discountAmount(d.Type, d.Value*unitMultiplier(d.Type, item.Quantity), line)
+		}
+		b.Subtotal += line
+		b.Discount += item.Discount
+	}
+	goods := b.Subtotal - b.Discount
+
+	// 3. Coupon
+	if code := normalizeCode(opts.CouponCode); code != "" {
+		coupon, err := s.usableCoupon(code, goods, now)
+		switch {
+		case err == nil:
+			b.CouponCode = coupon.Code
+			b.CouponDiscount = min(discountAmount(coupon.Type, coupon.Value, goods), goods)
+			goods -= b.CouponDiscount
+		case strict:
+			return nil, nil, err
+		default:
+			warnings = append(warnings, err.Error())
+		}
+	}
+
+	// 4. Shipping
+	if len(items) > 0 && (s.freeShippingMin == 0 || goods < s.freeShippingMin) {
+		b.Shipping = s.shippingFee
+	}
+
+	// 5. Tax
+	if region := strings.ToUpper(strings.TrimSpace(opts.Region)); region != "" {
+		rate, err := s.pricingRepo.FindTaxRate(region)
+		switch {
+		case err == nil:
+			b.TaxRegion = rate.Region
+			b.TaxRateBps = rate.RateBps
+			b.Tax = (goods*int64(rate.RateBps) + 5000) / 10000
+		case !errors.Is(err, models.ErrNotFound):
+			return nil, nil, err
+		case strict:
+			return nil, nil, fmt.Errorf("%w: unknown tax region %s", models.ErrBadRequest, region)
+		default:
+			warnings = append(warnings, fmt.Sprintf("unknown tax region %s", region))
+		}
+	}
+
+	b.Total = goods + b.Shipping + b.Tax
+
+	return b, warnings, nil
+}
+
+// bestDiscounts returns, per item index, the active discount that saves the most on one unit.
+// A category discount applies to products in that category or any of its subcategories.
+func (s *PricingService) bestDiscounts(items []models.CartItem, now time.Time) (map[int]models.Discount, error) {
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductId)
+	}
+
+	discounts, err := s.pricingRepo.ActiveDiscounts(ids)
+	if err != nil || len(discounts) == 0 {
+		return nil, err
+	}
+
+	var parents map[int64]*int64
+	best := make(map[int]models.Discount)
+	for i, item := range items {
+		for _, d := range discounts {
+			if d.StartsAt != nil && now.Before(*d.StartsAt) {
+				continue
+			}
+			if d.EndsAt != nil && !now.Before(*d.EndsAt) {
+				continue
+			}
+			if d.CategoryId > 0 {
+				if item.Product == nil || item.Product.CategoryId == nil {
+					continue
+				}
+				if parents == nil {
+					if parents, err = s.categoryParents(); err != nil {
+						return nil, err
+					}
+				}
+				if !inCategory(parents, *item.Product.CategoryId, d.CategoryId) {
+					continue
+				}
+			} else if d.ProductId != item.ProductId {
+				continue
+			}
+			if cur, ok := best[i]; !ok ||
+				discountAmount(d.Type, d.Value, item.UnitPrice) > discountAmount(cur.Type, cur.Value, item.UnitPrice) {
+				best[i] = d
+			}
+		}
+	}
+
+	return best, nil
+}
+
+// categoryParents maps every category ID to its parent ID.
+func (s *PricingService) categoryParents() (map[int64]*int64, error) {
+	categories, err := s.categoryRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[int64]*int64, len(categories))
+	for _, c := range categories {
+		parents[c.ID] = c.ParentId
+	}
+	return parents, nil
+}
+
+// inCategory reports whether category id is target or one of its descendants.
+func inCategory(parents map[int64]*int64, id, target int64) bool {
+	for seen := 0; seen <= len(parents); seen++ {
+		if id == target {
+			return true
+		}
+		parent := parents[id]
+		if parent == nil {
+			return false
+		}
+		id = *parent
+	}
+	return false // cycle guard
+}
+
+// usableCoupon looks up a coupon and checks it can be applied to a basket worth amount cents.
+func (s *PricingService) usableCoupon(code string, amount int64, now time.Time) (*models.Coupon, error) {
+	coupon, err := s.pricingRepo.FindCouponByCode(code)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("%w: coupon %s does not exist", models.ErrBadRequest, code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !coupon.Active:
+		return nil, fmt.Errorf("%w: coupon %s is not active", models.ErrBadRequest, code)
+	case coupon.ExpiresAt != nil && !now.Before(*coupon.ExpiresAt):
+		return nil, fmt.Errorf("%w: coupon %s has expired", models.ErrBadRequest, code)
+	case coupon.MaxUses > 0 && coupon.TimesUsed >= coupon.MaxUses:
+		return nil, fmt.Errorf("%w: coupon %s has been fully redeemed", models.ErrBadRequest, code)
+	case amount < coupon.MinSubtotal:
+		return nil, fmt.Errorf("%w: coupon %s needs a basket of at least %s",
+			models.ErrBadRequest, code, formatCents(coupon.MinSubtotal))
+	}
+
+	return coupon, nil
+}
+
+// RedeemCoupon counts a use of a coupon inside the checkout transaction.
+func (s *PricingService) RedeemCoupon(tx repository.Tx, code string) error {
+	return s.pricingRepo.RedeemCoupon(tx, code)
+}
+
+// ReleaseCoupon gives back the use of a coupon when its order is cancelled.
+func (s *PricingService) ReleaseCoupon(tx repository.Tx, code string) error {
+	return s.pricingRepo.ReleaseCoupon(tx, code)
+}
+
+// ── Admin: coupons
+
+// ListCoupons returns all coupons.
+func (s *PricingService) ListCoupons() ([]models.Coupon, error) {
+	return s.pricingRepo.ListCoupons()
+}
+
+// CreateCoupon validates and stores a new coupon.
+func (s *PricingService) CreateCoupon(req models.CouponRequest) (*models.Coupon, error) {
+	if err := validateCoupon(&req); err != nil {
+		return nil, err
+	}
+	return s.pricingRepo.CreateCoupon(&req)
+}
+
+// UpdateCoupon validates and replaces the settings of a coupon.
+func (s *PricingService) UpdateCoupon(id int64, req models.CouponRequest) (*models.Coupon, error) {
+	if err := validateCoupon(&req); err != nil {
+		return nil, err
+	}
+	return s.pricingRepo.UpdateCoupon(id, &req)
+}
+
+// DeleteCoupon removes a coupon. Orders keep the code they were placed with.
+func (s *PricingService) DeleteCoupon(id int64) error {
+	return s.pricingRepo.DeleteCoupon(id)
+}
+
+// ── Admin: discounts
+
+// ListDiscounts returns all product and category discounts.
+func (s *PricingService) ListDiscounts() ([]models.Discount, error) {
+	return s.pricingRepo.ListDiscounts()
+}
+
+// CreateDiscount validates and stores a new product or category discount.
+func (s *PricingService) CreateDiscount(req models.DiscountRequest) (*models.Discount, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", models.ErrBadRequest)
+	}
+	if (req.ProductId > 0) == (req.CategoryId > 0) {
+		return nil, fmt.Errorf("%w: set either product_id or category_id", models.ErrBadRequest)
+	}
+	if err := validateAmount(req.Type, req.Value); err != nil {
+		return nil, err
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", models.ErrBadRequest)
+	}
+	return s.pricingRepo.CreateDiscount(&req)
+}
+
+// DeleteDiscount removes a product discount.
+func (s *PricingService) DeleteDiscount(id int64) error {
+	return s.pricingRepo.DeleteDiscount(id)
+}
+
+// ── Tax rates
+
+// ListTaxRates returns all tax regions.
+func (s *PricingService) ListTaxRates() ([]models.TaxRate, error) {
+	return s.pricingRepo.ListTaxRates()
+}
+
+// SetTaxRate creates or replaces the tax rate of a region.
+func (s *PricingService) SetTaxRate(region string, req models.TaxRateRequest) (*models.TaxRate, error) {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if region == "" {
+		return nil, fmt.Errorf("%w: region is required", models.ErrBadRequest)
+	}
+	if req.RateBps < 0 || req.RateBps > 10000 {
+		return nil, fmt.Errorf("%w: rate_bps must be between 0 and 10000", models.ErrBadRequest)
+	}
+	return s.pricingRepo.UpsertTaxRate(region, &req)
+}
+
+// DeleteTaxRate removes a tax region.
+func (s *PricingService) DeleteTaxRate(region string) error {
+	return s.pricingRepo.DeleteTaxRate(strings.ToUpper(strings.TrimSpace(region)))
+}
+
+// ── Helpers
+
+// validateCoupon normalises the code and checks the coupon settings.
+func validateCoupon(req *models.CouponRequest) error {
+	req.Code = normalizeCode(req.Code)
+	if req.Code == "" {
+		return fmt.Errorf("%w: code is required", models.ErrBadRequest)
+	}
+	if err := validateAmount(req.Type, req.Value); err != nil {
+		return err
+	}
+	if req.MinSubtotal < 0 || req.MaxUses < 0 {
+		return fmt.Errorf("%w: min_subtotal and max_uses cannot be negative", models.ErrBadRequest)
+	}
+	return nil
+}
+
+// validateAmount checks a discount value against its type.
+func validateAmount(t models.DiscountType, value int64) error {
+	switch t {
+	case models.DiscountPercentage:
+		if value < 1 || value > 100 {
+			return fmt.Errorf("%w: a percentage must be between 1 and 100", models.ErrBadRequest)
+		}
+	case models.DiscountFixed:
+		if value <= 0 {
+			return fmt.Errorf("%w: a fixed amount must be positive", models.ErrBadRequest)
+		}
+	default:
+		return fmt.Errorf("%w: type must be percentage or fixed", models.ErrBadRequest)
+	}
+	return nil
+}
+
+// discountAmount returns the reduction of a discount on amount cents,
+// rounding percentages half up. It never exceeds amount.
+func discountAmount(t models.DiscountType, value, amount int64) int64 {
+	var off int64
+	if t == models.DiscountPercentage {
+		off = (amount*value + 50) / 100
+	} else {
+		off = value
+	}
+	return min(off, amount)
+}
+
+// unitMultiplier scales a fixed per-unit discount to a whole line.
+// Percentages already scale with the line amount.
+func unitMultiplier(t models.DiscountType, qty int) int64 {
+	if t == models.DiscountFixed {
+		return int64(qty)
+	}
+	return 1
+}
+
+// normalizeCode makes coupon codes case-insensitive.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// formatCents renders an amount in cents as dollars, e.g. 1999 → "$19.99".
+func formatCents(cents int64) string {
+	return fmt.Sprintf("$%d.%02d", cents/100, cents%100)
+}
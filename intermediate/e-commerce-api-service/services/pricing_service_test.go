@@ -0,0 +1,257 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
+)
+
+// Catalog of the pricing tests: unit price and category of each product
+var pricingProducts = map[int64]struct {
+	price    int64
+	category int64
+}{
+	1: {1000, 2}, // Mug in Kitchen, with its own discounts
+	2: {2500, 0}, // Tee
+	3: {333, 0},  // Pen, 15% off
+	4: {300, 0},  // Cap, 5.00 off per unit
+	5: {2000, 2}, // Bowl in Kitchen
+	6: {2499, 0}, // Sock
+	7: {200, 0},  // Clip
+}
+
+// newTestPricing prices baskets with a 4.99 shipping fee, free from 50.00,
+// against a database holding the discounts, coupons and tax rate used below.
+func newTestPricing(t *testing.T) (*PricingService, *repository.OrderRepo) {
+	t.Helper()
+	db := newTestDB(t)
+	mustExec(t, db,
+		`INSERT INTO categories (id, parent_id, name, slug) VALUES (1, NULL, 'Home', 'home'), (2, 1, 'Kitchen', 'kitchen')`,
+		`INSERT INTO products (id, name, price, stock, category_id) VALUES
+			(1, 'Mug', 1000, 9, 2), (2, 'Tee', 2500, 9, NULL), (3, 'Pen', 333, 9, NULL), (4, 'Cap', 300, 9, NULL),
+			(5, 'Bowl', 2000, 9, 2), (6, 'Sock', 2499, 9, NULL), (7, 'Clip', 200, 9, NULL)`,
+	)
+	svc := NewPricingService(repository.NewPricingRepo(db), repository.NewCategoryRepo(db), 499, 5000)
+
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time { at := now.Add(-d); return &at }
+	inactive := false
+	discounts := []models.DiscountRequest{
+		{Name: "Home 10%", CategoryId: 1, Type: models.DiscountPercentage, Value: 10},
+		{Name: "Mug 1.50 off", ProductId: 1, Type: models.DiscountFixed, Value: 150},
+		{Name: "Ended mug sale", ProductId: 1, Type: models.DiscountPercentage, Value: 50, StartsAt: ago(48 * time.Hour), EndsAt: ago(24 * time.Hour)},
+		{Name: "Future mug sale", ProductId: 1, Type: models.DiscountPercentage, Value: 60, StartsAt: ago(-24 * time.Hour)},
+		{Name: "Pen 15%", ProductId: 3, Type: models.DiscountPercentage, Value: 15},
+		{Name: "Cap 5.00 off", ProductId: 4, Type: models.DiscountFixed, Value: 500},
+	}
+	for _, d := range discounts {
+		if _, err := svc.CreateDiscount(d); err != nil {
+			t.Fatalf("creating discount %s: %v", d.Name, err)
+		}
+	}
+	coupons := []models.CouponRequest{
+		{Code: "save10", Type: models.DiscountPercentage, Value: 10, MinSubtotal: 2500},
+		{Code: "FIVER", Type: models.DiscountFixed, Value: 500},
+		{Code: "HUGE", Type: models.DiscountFixed, Value: 100000},
+		{Code: "OLD", Type: models.DiscountPercentage, Value: 20, ExpiresAt: ago(time.Hour)},
+		{Code: "OFF", Type: models.DiscountPercentage, Value: 20, Active: &inactive},
+		{Code: "ONCE", Type: models.DiscountFixed, Value: 100, MaxUses: 1},
+	}
+	for _, c := range coupons {
+		if _, err := svc.CreateCoupon(c); err != nil {
+			t.Fatalf("creating coupon %s: %v", c.Code, err)
+		}
+	}
+	if _, err := svc.SetTaxRate("us-ca", models.TaxRateRequest{Name: "California", RateBps: 725}); err != nil {
+		t.Fatal(err)
+	}
+
+	return svc, repository.NewOrderRepo(db)
+}
+
+// basket builds cart items from product IDs and quantities
+func basket(lines ...[2]int64) []models.CartItem {
+	items := make([]models.CartItem, 0, len(lines))
+	for _, l := range lines {
+		p := pricingProducts[l[0]]
+		product := &models.Product{ID: l[0], Price: p.price}
+		if p.category > 0 {
+			product.CategoryId = &p.category
+		}
+		items = append(items, models.CartItem{ProductId: l[0], Quantity: int(l[1]), UnitPrice: p.price, Product: product})
+	}
+	return items
+}
+
+func TestQuote(t *testing.T) {
+	svc, _ := newTestPricing(t)
+
+	tests := []struct {
+		name    string
+		items   []models.CartItem
+		opts    models.PricingOptions
+		want    models.PriceBreakdown
+		lines   []int64 // discount per line, when checked
+		wantErr bool
+	}{
+		{
+			name:  "percentage rounds half up per line",
+			items: basket([2]int64{3, 3}), // 15% of 9.99 is 1.4985
+			want:  models.PriceBreakdown{Subtotal: 999, Discount: 150, Shipping: 499, Total: 1348},
+		},
+		{
+			name:  "fixed discount larger than the line",
+			items: basket([2]int64{4, 2}),
+			want:  models.PriceBreakdown{Subtotal: 600, Discount: 600, Shipping: 499, Total: 499},
+		},
+		{
+			name:  "best discount per line, ignoring ended and future ones",
+			items: basket([2]int64{1, 2}, [2]int64{5, 1}, [2]int64{2, 1}),
+			want:  models.PriceBreakdown{Subtotal: 6500, Discount: 500, Total: 6000},
+			lines: []int64{300, 200, 0}, // 1.50 beats 10% on the mug; the bowl inherits Home 10%
+		},
+		{
+			name:  "coupon applies to the discounted subtotal",
+			items: basket([2]int64{1, 2}, [2]int64{2, 1}),
+			opts:  models.PricingOptions{CouponCode: " Save10 "},
+			want:  models.PriceBreakdown{Subtotal: 4500, Discount: 300, CouponCode: "SAVE10", CouponDiscount: 420, Shipping: 499, Total: 4279},
+		},
+		{
+			name:  "coupon minimum reached exactly",
+			items: basket([2]int64{2, 1}),
+			opts:  models.PricingOptions{CouponCode: "SAVE10"},
+			want:  models.PriceBreakdown{Subtotal: 2500, CouponCode: "SAVE10", CouponDiscount: 250, Shipping: 499, Total: 2749},
+		},
+		{
+			name:    "coupon minimum missed by a cent",
+			items:   basket([2]int64{6, 1}),
+			opts:    models.PricingOptions{CouponCode: "SAVE10"},
+			wantErr: true,
+		},
+		{
+			name:    "coupon minimum counts line discounts",
+			items:   basket([2]int64{1, 2}, [2]int64{3, 2}), // 26.66 before, 22.66 after discounts
+			opts:    models.PricingOptions{CouponCode: "SAVE10"},
+			wantErr: true,
+		},
+		{
+			name:  "fixed coupon larger than the basket",
+			items: basket([2]int64{2, 1}),
+			opts:  models.PricingOptions{CouponCode: "HUGE"},
+			want:  models.PriceBreakdown{Subtotal: 2500, CouponCode: "HUGE", CouponDiscount: 2500, Shipping: 499, Total: 499},
+		},
+		{name: "expired coupon", items: basket([2]int64{2, 1}), opts: models.PricingOptions{CouponCode: "OLD"}, wantErr: true},
+		{name: "inactive coupon", items: basket([2]int64{2, 1}), opts: models.PricingOptions{CouponCode: "OFF"}, wantErr: true},
+		{name: "unknown coupon", items: basket([2]int64{2, 1}), opts: models.PricingOptions{CouponCode: "NOPE"}, wantErr: true},
+		{
+			name:  "free shipping at the threshold",
+			items: basket([2]int64{2, 2}),
+			want:  models.PriceBreakdown{Subtotal: 5000, Total: 5000},
+		},
+		{
+			name:  "shipping charged a cent below the threshold",
+			items: basket([2]int64{6, 2}),
+			want:  models.PriceBreakdown{Subtotal: 4998, Shipping: 499, Total: 5497},
+		},
+		{
+			name:  "threshold applies after the coupon",
+			items: basket([2]int64{2, 2}),
+			opts:  models.PricingOptions{CouponCode: "FIVER"},
+			want:  models.PriceBreakdown{Subtotal: 5000, CouponCode: "FIVER", CouponDiscount: 500, Shipping: 499, Total: 4999},
+		},
+		{
+			name:  "tax rounds half up and excludes shipping",
+			items: basket([2]int64{7, 1}), // 7.25% of 2.00 is 0.145
+			opts:  models.PricingOptions{Region: "us-ca"},
+			want:  models.PriceBreakdown{Subtotal: 200, Shipping: 499, TaxRegion: "US-CA", TaxRateBps: 725, Tax: 15, Total: 714},
+		},
+		{
+			name:  "tax rounds down below half",
+			items: basket([2]int64{2, 1}), // 7.25% of 25.00 is 1.8125
+			opts:  models.PricingOptions{Region: "US-CA"},
+			want:  models.PriceBreakdown{Subtotal: 2500, Shipping: 499, TaxRegion: "US-CA", TaxRateBps: 725, Tax: 181, Total: 3180},
+		},
+		{
+			name:  "tax on the goods after discounts and coupon",
+			items: basket([2]int64{1, 2}, [2]int64{2, 1}),
+			opts:  models.PricingOptions{CouponCode: "SAVE10", Region: "US-CA"}, // 7.25% of 37.80 is 2.7405
+			want: models.PriceBreakdown{Subtotal: 4500, Discount: 300, CouponCode: "SAVE10", CouponDiscount: 420,
+				Shipping: 499, TaxRegion: "US-CA", TaxRateBps: 725, Tax: 274, Total: 4553},
+		},
+		{name: "unknown region", items: basket([2]int64{2, 1}), opts: models.PricingOptions{Region: "XX"}, wantErr: true},
+		{name: "empty basket ships nothing", want: models.PriceBreakdown{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings, err := svc.Quote(tt.items, tt.opts, true)
+			if tt.wantErr {
+				if !errors.Is(err, models.ErrBadRequest) {
+					t.Fatalf("expected ErrBadRequest, got %v", err)
+				}
+				// The cart preview skips the problem instead
+				if _, warnings, err := svc.Quote(tt.items, tt.opts, false); err != nil || len(warnings) != 1 {
+					t.Fatalf("preview: expected one warning, got %v (%v)", warnings, err)
+				}
+				return
+			}
+			if err != nil || len(warnings) != 0 {
+				t.Fatalf("Quote returned warnings %v, error %v", warnings, err)
+			}
+			if *got != tt.want {
+				t.Errorf("got %+v\nwant %+v", *got, tt.want)
+			}
+			for i, want := range tt.lines {
+				if tt.items[i].Discount != want {
+					t.Errorf("line %d: got discount %d, want %d", i, tt.items[i].Discount, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedeemCouponMaxUses(t *testing.T) {
+	svc, orders := newTestPricing(t)
+	items := basket([2]int64{2, 1})
+	opts := models.PricingOptions{CouponCode: "ONCE"}
+
+	redeem := func() error {
+		t.Helper()
+		tx, err := orders.BeginTx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.RedeemCoupon(tx, "ONCE"); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if err := redeem(); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := redeem(); !errors.Is(err, models.ErrBadRequest) {
+		t.Fatalf("second use: expected ErrBadRequest, got %v", err)
+	}
+	if _, _, err := svc.Quote(items, opts, true); !errors.Is(err, models.ErrBadRequest) {
+		t.Fatalf("quote with a used-up coupon: expected ErrBadRequest, got %v", err)
+	}
+
+	// A cancelled order gives its use back
+	tx, err := orders.BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ReleaseCoupon(tx, "ONCE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _, err := svc.Quote(items, opts, true); err != nil || b.CouponDiscount != 100 {
+		t.Fatalf("quote after release: got %+v, %v", b, err)
+	}
+}
@@ -2,6 +2,9 @@ package services
 
 import (
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/repository"
@@ -9,12 +12,15 @@ import (
 
 // ProductService handles product-related business logic
 type ProductService struct {
-	repo *repository.ProductRepo
+	repo         repository.ProductStore
+	categoryRepo repository.CategoryStore
+	inventory    *InventoryService
 }
 
-// NewProductService creates a new instance of ProductService
-func NewProductService(repo *repository.ProductRepo) *ProductService {
-	return &ProductService{repo: repo}
+// NewProductService creates a new instance of ProductService. Stock set
+// through product and variant edits is recorded in the inventory ledger.
+func NewProductService(repo repository.ProductStore, cr repository.CategoryStore, inv *InventoryService) *ProductService {
+	return &ProductService{repo: repo, categoryRepo: cr, inventory: inv}
 }
 
 // Create creates a new product
@@ -25,7 +31,12 @@ func (s *ProductService) Create(req models.ProductCreateRequest) (*models.Produc
 	if req.Price <= 0 {
 		return nil, fmt.Errorf("%w: price must be positive", models.ErrBadRequest)
 	}
-	return s.repo.Create(&req)
+	product, err := s.repo.Create(&req)
+	if err != nil {
+		return nil, err
+	}
+	s.recordStockChange(product.ID, 0, models.MovementRestock, product.Stock, "initial stock")
+	return product, nil
 }
 
 // GetById retrieves a product by its ID
@@ -35,15 +46,185 @@ func (s *ProductService) GetById(id int64) (*models.Product, error) {
 
 // List retrieves a list of products based on the query parameters
 func (s *ProductService) List(q models.ProductQuery) ([]models.Product, error) {
-	return s.repo.List(q)
+	res, err := s.Search(q)
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+// Search returns a page of matching products with category and price facets.
+// Without an explicit sort, results are ranked by relevance when there is a
+// search term and listed newest first otherwise.
+func (s *ProductService) Search(q models.ProductQuery) (*models.ProductSearchResult, error) {
+	q.Name = strings.TrimSpace(q.Name)
+	switch q.Sort {
+	case "":
+		q.Sort = models.SortNewest
+		if q.Name != "" {
+			q.Sort = models.SortRelevance
+		}
+	case models.SortRelevance, models.SortNewest, models.SortPriceAsc, models.SortPriceDesc, models.SortName:
+	default:
+		return nil, fmt.Errorf("%w: sort must be one of relevance, newest, price_asc, price_desc, name", models.ErrBadRequest)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	if q.Limit > 100 {
+		q.Limit = 100
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+
+	res, counts, err := s.repo.Search(q)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := s.categoryRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	res.Facets.Categories = categoryFacets(categories, counts)
+
+	return res, nil
+}
+
+// ResolveCategory finds a category by numeric ID or slug.
+func (s *ProductService) ResolveCategory(idOrSlug string) (*models.Category, error) {
+	if id, err := strconv.ParseInt(idOrSlug, 10, 64); err == nil {
+		return s.categoryRepo.FindById(id)
+	}
+	return s.categoryRepo.FindBySlug(idOrSlug)
+}
+
+// categoryFacets rolls the direct product counts up the category tree, so a
+// parent counts the products of all its subcategories, and drops empty categories.
+func categoryFacets(categories []models.Category, direct map[int64]int) []models.CategoryFacet {
+	parents := make(map[int64]*int64, len(categories))
+	for _, c := range categories {
+		parents[c.ID] = c.ParentId
+	}
+
+	totals := make(map[int64]int, len(categories))
+	for id, n := range direct {
+		// Walk up at most len(categories) levels to stay safe on a corrupt tree
+		for cur, steps := &id, 0; cur != nil && steps <= len(categories); cur, steps = parents[*cur], steps+1 {
+			totals[*cur] += n
+		}
+	}
+
+	facets := []models.CategoryFacet{}
+	for _, c := range categories {
+		if n := totals[c.ID]; n > 0 {
+			facets = append(facets, models.CategoryFacet{
+				ID: c.ID, Name: c.Name, Slug: c.Slug, ParentId: c.ParentId, Count: n,
+			})
+		}
+	}
+	return facets
 }
 
 // Update updates a product by its ID
 func (s *ProductService) Update(id int64, req models.ProductUpdateRequest) (*models.Product, error) {
-	return s.repo.Update(id, &req)
+	if req.Stock == nil {
+		return s.repo.Update(id, &req)
+	}
+
+	before, err := s.repo.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+	product, err := s.repo.Update(id, &req)
+	if err != nil {
+		return nil, err
+	}
+	s.recordStockChange(id, 0, models.MovementAdjustment, product.Stock-before.Stock, "stock edited")
+	return product, nil
+}
+
+// recordStockChange adds a stock edit to the inventory ledger. The edit has
+// already been saved, so a failure is only logged.
+func (s *ProductService) recordStockChange(productId, variantId int64, t models.MovementType, delta int, note string) {
+	if delta == 0 {
+		return
+	}
+	err := s.inventory.recordChange(&models.InventoryMovement{
+		ProductId: productId, VariantId: variantId, Type: t, Quantity: delta, Note: note,
+	})
+	if err != nil {
+		log.Printf("[INVENTORY] recording stock change of product %d failed: %v", productId, err)
+	}
 }
 
 // Delete deletes a product by its ID
 func (s *ProductService) Delete(id int64) error {
 	return s.repo.Delete(id)
 }
+
+// CreateVariant adds a SKU to a product. The product's listed price and
+// stock follow its variants from then on.
+func (s *ProductService) CreateVariant(productId int64, req models.VariantRequest) (*models.ProductVariant, error) {
+	if err := validateVariant(&req); err != nil {
+		return nil, err
+	}
+	variant, err := s.repo.CreateVariant(productId, &req)
+	if err != nil {
+		return nil, err
+	}
+	s.recordStockChange(productId, variant.ID, models.MovementRestock, variant.Stock, "initial stock")
+	return variant, nil
+}
+
+// UpdateVariant replaces the fields of a product's variant.
+func (s *ProductService) UpdateVariant(productId, variantId int64, req models.VariantRequest) (*models.ProductVariant, error) {
+	if err := validateVariant(&req); err != nil {
+		return nil, err
+	}
+	before, err := s.repo.FindVariant(variantId)
+	if err != nil {
+		return nil, err
+	}
+	variant, err := s.repo.UpdateVariant(productId, variantId, &req)
+	if err != nil {
+		return nil, err
+	}
+	s.recordStockChange(productId, variantId, models.MovementAdjustment, variant.Stock-before.Stock, "stock edited")
+	return variant, nil
+}
+
+// DeleteVariant removes a product's variant.
+func (s *ProductService) DeleteVariant(productId, variantId int64) error {
+	return s.repo.DeleteVariant(productId, variantId)
+}
+
+// AddImage appends an image to a product's gallery.
+func (s *ProductService) AddImage(productId int64, req models.ProductImageRequest) (*models.ProductImage, error) {
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" {
+		return nil, fmt.Errorf("%w: url is required", models.ErrBadRequest)
+	}
+	return s.repo.AddImage(productId, &req)
+}
+
+// DeleteImage removes an image from a product's gallery.
+func (s *ProductService) DeleteImage(productId, imageId int64) error {
+	return s.repo.DeleteImage(productId, imageId)
+}
+
+// validateVariant trims the SKU and checks price and stock.
+func validateVariant(req *models.VariantRequest) error {
+	req.SKU = strings.TrimSpace(req.SKU)
+	if req.SKU == "" {
+		return fmt.Errorf("%w: sku is required", models.ErrBadRequest)
+	}
+	if req.Price <= 0 {
+		return fmt.Errorf("%w: price must be positive", models.ErrBadRequest)
+	}
+	if req.Stock < 0 {
+		return fmt.Errorf("%w: stock cannot be negative", models.ErrBadRequest)
+	}
+	return nil
+}
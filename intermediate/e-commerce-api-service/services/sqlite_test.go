@@ -0,0 +1,34 @@
+package services
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/database"
+)
+
+// newTestDB opens a migrated SQLite database in a temporary directory, for
+// behaviour that lives in SQL, such as reservation expiry or the ledger.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=ON")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return db
+}
+
+// mustExec runs setup statements, failing the test on the first error
+func mustExec(t *testing.T, db *sql.DB, stmts ...string) {
+	t.Helper()
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/e-commerce-api-service/models"
+	"github.com/stripe/stripe-go/v84"
+)
+
+// StripeProvider creates payments through the Stripe API.
+// It owns its own client instead of setting the global stripe.Key.
+type StripeProvider struct {
+	client        *stripe.Client
+	webhookSecret string
+}
+
+// NewStripeProvider creates a StripeProvider with the given secret key and webhook signing secret.
+func NewStripeProvider(apiKey, webhookSecret string) *StripeProvider {
+	return &StripeProvider{
+		client:        stripe.NewClient(apiKey),
+		webhookSecret: webhookSecret,
+	}
+}
+
+// Name returns "stripe".
+func (p *StripeProvider) Name() string {
+	return ProviderStripe
+}
+
+// CreatePaymentIntent creates a Stripe PaymentIntent for the given amount.
+// The client_secret is returned to the frontend for Stripe.js confirmation.
+func (p *StripeProvider) CreatePaymentIntent(amountCents int64, currency, idempotencyKey string) (*PaymentIntent, error) {
+	params := &stripe.PaymentIntentCreateParams{
+		Amount:   stripe.Int64(amountCents),
+		Currency: stripe.String(currency),
+		AutomaticPaymentMethods: &stripe.PaymentIntentCreateAutomaticPaymentMethodsParams{
+			Enabled: stripe.Bool(true),
+		},
+	}
+	if idempotencyKey != "" {
+		params.SetIdempotencyKey(idempotencyKey)
+	}
+
+	pi, err := p.client.V1PaymentIntents.Create(context.Background(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaymentIntent{
+		ID:           pi.ID,
+		ClientSecret: pi.ClientSecret,
+		Amount:       pi.Amount,
+		Currency:     string(pi.Currency),
+		Status:       string(pi.Status),
+	}, nil
+}
+
+// CancelPaymentIntent cancels a PaymentIntent unless the customer already paid it.
+func (p *StripeProvider) CancelPaymentIntent(paymentId string) error {
+	pi, err := p.client.V1PaymentIntents.Retrieve(context.Background(), paymentId, nil)
+	if err != nil {
+		return stripeError(err)
+	}
+	switch pi.Status {
+	case stripe.PaymentIntentStatusCanceled:
+		return nil
+	case stripe.PaymentIntentStatusSucceeded, stripe.PaymentIntentStatusProcessing:
+		return fmt.Errorf("%w: payment %s is already %s", models.ErrConflict, paymentId, pi.Status)
+	}
+
+	_, err = p.client.V1PaymentIntents.Cancel(context.Background(), paymentId, nil)
+	return stripeError(err)
+}
+
+// Refund refunds a PaymentIntent, fully or partially.
+func (p *StripeProvider) Refund(paymentId string, amountCents int64, idempotencyKey string) (*Refund, error) {
+	params := &stripe.RefundCreateParams{
+		PaymentIntent: stripe.String(paymentId),
+		Amount:        stripe.Int64(amountCents),
+	}
+	if idempotencyKey != "" {
+		params.SetIdempotencyKey(idempotencyKey)
+	}
+
+	re, err := p.client.V1Refunds.Create(context.Background(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Refund{
+		ID:        re.ID,
+		PaymentId: paymentId,
+		Amount:    re.Amount,
+		Status:    string(re.Status),
+	}, nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header against the raw payload.
+func (p *StripeProvider) ParseWebhook(payload []byte, signature string) (*PaymentEvent, error) {
+	return parseSignedEvent(payload, signature, p.webhookSecret)
+}
+
+// stripeError maps the Stripe errors the order flow acts on to model errors.
+func stripeError(err error) error {
+	var se *stripe.Error
+	if !errors.As(err, &se) {
+		return err
+	}
+	switch se.Code {
+	case stripe.ErrorCodeResourceMissing:
+		return fmt.Errorf("%w: %s", models.ErrNotFound, se.Msg)
+	case stripe.ErrorCodePaymentIntentUnexpectedState:
+		return fmt.Errorf("%w: %s", models.ErrConflict, se.Msg)
+	default:
+		return err
+	}
+}
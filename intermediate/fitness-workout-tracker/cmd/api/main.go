@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/database"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/handlers"
@@ -39,6 +40,16 @@ func main() {
 	// Call our new router setup function, passing the database connection
 	mux := handlers.SetupRoutes(db)
 
+	// ADMIN_EMAIL bootstraps the first admin by promoting an existing user.
+	// It is read after SetupRoutes, which loads .env.
+	if email := os.Getenv("ADMIN_EMAIL"); email != "" {
+		if err := database.PromoteAdmin(db, email); err != nil {
+			log.Printf("Could not promote admin: %v", err)
+		} else {
+			log.Printf("Promoted %s to admin", email)
+		}
+	}
+
 	log.Println("Server starting on :8800...")
 	if err := http.ListenAndServe(":8800", mux); err != nil {
 		log.Fatalf("Server failed: %v", err)
@@ -2,7 +2,9 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/models"
 	_ "github.com/mattn/go-sqlite3" // Import the SQLite driver
@@ -39,7 +41,7 @@ func runMigrations(db *sql.DB) error {
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		username TEXT UNIQUE NOT NULL,
-		email TEXT UNIQUE NOT NULL,
+		email TEXT UNIQUE NOT NULL COLLATE NOCASE,
 		password_hash TEXT NOT NULL,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
@@ -47,14 +49,29 @@ func runMigrations(db *sql.DB) error {
 
 	CREATE TABLE IF NOT EXISTS exercises (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT UNIQUE NOT NULL, -- Name of the exercise
+		user_id INTEGER,           -- owner of a custom exercise, NULL for the shared catalog
+		name TEXT NOT NULL,        -- Name of the exercise, unique per owner
 		category TEXT NOT NULL,    -- Category (e.g., Strength)
 		muscle_group TEXT NOT NULL, -- Muscle group (e.g., Legs)
 		description TEXT,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 	);
 
+	-- Other names of an exercise, shared (user_id NULL) or private to a user
+	CREATE TABLE IF NOT EXISTS exercise_aliases (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		exercise_id INTEGER NOT NULL,
+		user_id INTEGER,
+		alias TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_exercise_aliases_owner_alias ON exercise_aliases(COALESCE(user_id, 0), alias COLLATE NOCASE);
+
 	CREATE TABLE IF NOT EXISTS workouts (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id INTEGER NOT NULL,
@@ -79,6 +96,100 @@ func runMigrations(db *sql.DB) error {
 		FOREIGN KEY (workout_id) REFERENCES workouts(id) ON DELETE CASCADE,
 		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
 	);
+
+	-- What was actually performed: one session per workout, with the sets logged during it
+	CREATE TABLE IF NOT EXISTS workout_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		workout_id INTEGER UNIQUE NOT NULL,
+		user_id INTEGER NOT NULL,
+		status TEXT NOT NULL DEFAULT 'in_progress', -- in_progress, completed or skipped
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME,
+		notes TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (workout_id) REFERENCES workouts(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS session_sets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL,
+		exercise_id INTEGER NOT NULL,
+		set_number INTEGER NOT NULL, -- 1-based, per exercise
+		reps INTEGER NOT NULL,
+		weight REAL NOT NULL,
+		rpe REAL,                    -- rate of perceived exertion, 1-10
+		rest_seconds INTEGER,        -- rest taken before the set
+		notes TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (session_id) REFERENCES workout_sessions(id) ON DELETE CASCADE,
+		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_session_sets_session ON session_sets(session_id);
+
+	-- Reusable workouts: a template holds planned exercises without a date
+	CREATE TABLE IF NOT EXISTS workout_templates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS template_exercises (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		template_id INTEGER NOT NULL,
+		exercise_id INTEGER NOT NULL,
+		sets INTEGER NOT NULL,
+		reps INTEGER NOT NULL,
+		weight REAL NOT NULL,
+		notes TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (template_id) REFERENCES workout_templates(id) ON DELETE CASCADE,
+		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
+	);
+
+	-- Multi-week programs schedule templates with a recurrence rule and progressive overload
+	CREATE TABLE IF NOT EXISTS programs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		weeks INTEGER NOT NULL,
+		weight_increment REAL NOT NULL DEFAULT 0, -- added to planned weights every week
+		reps_increment INTEGER NOT NULL DEFAULT 0,
+		deload_every INTEGER NOT NULL DEFAULT 0,  -- every Nth week is a deload, 0 = never
+		deload_percent REAL NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS program_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		program_id INTEGER NOT NULL,
+		template_id INTEGER NOT NULL,
+		recurrence TEXT NOT NULL, -- e.g. FREQ=WEEKLY;BYDAY=MO,TH
+		time_of_day TEXT NOT NULL DEFAULT '07:00',
+		FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE,
+		FOREIGN KEY (template_id) REFERENCES workout_templates(id)
+	);
+
+	-- A program applied from a start date; its generated workouts point back to it
+	CREATE TABLE IF NOT EXISTS program_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		program_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		start_date TEXT NOT NULL, -- YYYY-MM-DD
+		timezone TEXT NOT NULL DEFAULT 'UTC',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
 	`
 
 	_, err := db.Exec(query)
@@ -87,10 +198,124 @@ func runMigrations(db *sql.DB) error {
 		return err
 	}
 
+	// Columns added after the first release; CREATE TABLE IF NOT EXISTS
+	// does not add them to existing databases.
+	if err := addColumnIfMissing(db, "workouts", "completed_at", "DATETIME"); err != nil {
+		log.Printf("Error running migrations: %v\n", err)
+		return err
+	}
+	if err := addColumnIfMissing(db, "workouts", "program_run_id", "INTEGER"); err != nil {
+		log.Printf("Error running migrations: %v\n", err)
+		return err
+	}
+	if err := addColumnIfMissing(db, "users", "is_admin", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		log.Printf("Error running migrations: %v\n", err)
+		return err
+	}
+	// Secret token of the user's calendar feed, NULL until one is created
+	if err := addColumnIfMissing(db, "users", "calendar_token", "TEXT"); err != nil {
+		log.Printf("Error running migrations: %v\n", err)
+		return err
+	}
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_calendar_token ON users(calendar_token)`); err != nil {
+		log.Printf("Error running migrations: %v\n", err)
+		return err
+	}
+	// Emails are unique regardless of case; databases created before the
+	// email column was COLLATE NOCASE get the rule from this index
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_nocase ON users(email COLLATE NOCASE)`); err != nil {
+		log.Printf("Error running migrations: users with emails differing only in case must be merged first: %v\n", err)
+		return err
+	}
+	if err := migrateCustomExercises(db); err != nil {
+		log.Printf("Error running migrations: %v\n", err)
+		return err
+	}
+
 	log.Println("Database migrated successfully.")
 	return nil
 }
 
+// hasColumn reports whether a table has a column
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already there
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	exists, err := hasColumn(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// migrateCustomExercises rebuilds an exercises table from before custom
+// exercises: SQLite cannot drop the UNIQUE constraint on name, which now only
+// holds per owner. Existing rows keep their IDs and become catalog exercises.
+func migrateCustomExercises(db *sql.DB) error {
+	migrated, err := hasColumn(db, "exercises", "user_id")
+	if err != nil {
+		return err
+	}
+	if !migrated {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(`
+		CREATE TABLE exercises_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER,
+			name TEXT NOT NULL,
+			category TEXT NOT NULL,
+			muscle_group TEXT NOT NULL,
+			description TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+		INSERT INTO exercises_new (id, name, category, muscle_group, description, updated_at, created_at)
+			SELECT id, name, category, muscle_group, description, updated_at, created_at FROM exercises;
+		DROP TABLE exercises;
+		ALTER TABLE exercises_new RENAME TO exercises;`)
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(`
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_owner_name ON exercises(COALESCE(user_id, 0), name COLLATE NOCASE);
+	CREATE INDEX IF NOT EXISTS idx_exercises_category ON exercises(category);`)
+	return err
+}
+
 // runSeedData inserts initial seed data into the database
 func runSeedData(db *sql.DB) error {
 	// Insert seed exercises
@@ -112,9 +337,12 @@ func runSeedData(db *sql.DB) error {
 		return err
 	}
 
-	// Example using PostgreSQL/SQLite syntax
-	query := `INSERT INTO exercises (name, category, muscle_group, description) 
-              VALUES ($1, $2, $3, $4) ON CONFLICT (name) DO NOTHING`
+	// Skip exercises already in the catalog, also when they were merged into
+	// another exercise and live on as an alias
+	query := `INSERT INTO exercises (name, category, muscle_group, description)
+              SELECT ?, ?, ?, ?
+              WHERE NOT EXISTS (SELECT 1 FROM exercises WHERE user_id IS NULL AND name = ? COLLATE NOCASE)
+                AND NOT EXISTS (SELECT 1 FROM exercise_aliases WHERE user_id IS NULL AND alias = ? COLLATE NOCASE)`
 
 	stmt, err := tx.Prepare(query)
 	if err != nil {
@@ -124,7 +352,7 @@ func runSeedData(db *sql.DB) error {
 	defer stmt.Close()
 
 	for _, ex := range exercises {
-		if _, err := stmt.Exec(ex.Name, ex.Category, ex.MuscleGroup, ex.Description); err != nil {
+		if _, err := stmt.Exec(ex.Name, ex.Category, ex.MuscleGroup, ex.Description, ex.Name, ex.Name); err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -132,3 +360,20 @@ func runSeedData(db *sql.DB) error {
 
 	return tx.Commit()
 }
+
+// PromoteAdmin flags the user registered with email as an admin. This is how
+// the first admin is created; the API has no way to grant admin rights.
+func PromoteAdmin(db *sql.DB, email string) error {
+	res, err := db.Exec(`UPDATE users SET is_admin = 1 WHERE email = ?`, strings.TrimSpace(email))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no user registered with %s", email)
+	}
+	return nil
+}
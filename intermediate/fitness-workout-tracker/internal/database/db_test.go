@@ -23,3 +23,85 @@ func TestInitDBAndSeeds(t *testing.T) {
 		t.Fatalf("expected seed exercises inserted")
 	}
 }
+
+func TestInitDBTwice(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tracker_test.db")
+	for i := 0; i < 2; i++ {
+		db, err := InitDB(dbPath)
+		if err != nil {
+			t.Fatalf("InitDB run %d error: %v", i+1, err)
+		}
+		db.Close()
+	}
+}
+
+func TestMigrateLegacyExercises(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tracker_test.db")
+
+	// The exercises table as created before custom exercises
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = legacy.Exec(`
+		CREATE TABLE exercises (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			category TEXT NOT NULL,
+			muscle_group TEXT NOT NULL,
+			description TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO exercises (id, name, category, muscle_group) VALUES (42, 'Squat', 'Strength', 'Legs');`)
+	legacy.Close()
+	if err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+
+	db, err := InitDB(dbPath)
+	if err != nil {
+		t.Fatalf("InitDB error: %v", err)
+	}
+	defer db.Close()
+
+	// IDs survive and the seed does not duplicate the existing exercise
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM exercises WHERE name = 'Squat' AND id = 42 AND user_id IS NULL").Scan(&count); err != nil || count != 1 {
+		t.Fatalf("expected the legacy Squat to be kept once, got %d (%v)", count, err)
+	}
+
+	// Names are now unique per owner only
+	if _, err := db.Exec("INSERT INTO exercises (user_id, name, category, muscle_group) VALUES (1, 'Squat', 'Strength', 'Legs')"); err != nil {
+		t.Fatalf("custom exercise with a catalog name: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO exercises (name, category, muscle_group) VALUES ('squat', 'Strength', 'Legs')"); err == nil {
+		t.Fatalf("expected a duplicate catalog name to be rejected")
+	}
+}
+
+func TestPromoteAdmin(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "tracker_test.db"))
+	if err != nil {
+		t.Fatalf("InitDB error: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO users (username, email, password_hash) VALUES ('alice', 'alice@example.com', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := PromoteAdmin(db, "nobody@example.com"); err == nil {
+		t.Fatal("expected an error for an unknown email")
+	}
+
+	// Emails match regardless of case, and promoting twice is harmless
+	for i := 0; i < 2; i++ {
+		if err := PromoteAdmin(db, " Alice@Example.com "); err != nil {
+			t.Fatalf("PromoteAdmin run %d error: %v", i+1, err)
+		}
+	}
+	var isAdmin bool
+	if err := db.QueryRow(`SELECT is_admin FROM users WHERE username = 'alice'`).Scan(&isAdmin); err != nil || !isAdmin {
+		t.Fatalf("expected alice to be an admin, got %v (%v)", isAdmin, err)
+	}
+}
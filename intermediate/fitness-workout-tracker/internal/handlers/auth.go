@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -37,7 +38,9 @@ func RegisterUser(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// 2. Validate the request payload
+		// 2. Validate the request payload. Emails are stored lowercase, so
+		// addresses differing only in case belong to the same account.
+		req.Email = normalizeEmail(req.Email)
 		if req.Username == "" || req.Email == "" || req.Password == "" {
 			http.Error(w, `{"error": "Username, email, and password are required"}`, http.StatusUnprocessableEntity)
 			return
@@ -89,6 +92,7 @@ func LoginUser(db *sql.DB, jwtSecret string) http.HandlerFunc {
 		}
 
 		// Validate the request payload
+		req.Email = normalizeEmail(req.Email)
 		if req.Email == "" || req.Password == "" {
 			http.Error(w, `{"error": "Email and password are required"}`, http.StatusUnprocessableEntity)
 			return
@@ -97,7 +101,7 @@ func LoginUser(db *sql.DB, jwtSecret string) http.HandlerFunc {
 		// Fetch the user from the database
 		var id int
 		var username, passwordHash string
-		query := `SELECT id, username, password_hash FROM users WHERE email = ?`
+		query := `SELECT id, username, password_hash FROM users WHERE email = ? COLLATE NOCASE`
 		err := db.QueryRow(query, req.Email).Scan(&id, &username, &passwordHash)
 		if err != nil {
 			http.Error(w, `{"error": "Invalid credentials"}`, http.StatusUnauthorized)
@@ -137,3 +141,8 @@ func LoginUser(db *sql.DB, jwtSecret string) http.HandlerFunc {
 		json.NewEncoder(w).Encode(response)
 	}
 }
+
+// normalizeEmail trims and lowercases an email address
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/models"
+)
+
+// newCalendarToken returns a random, URL-safe feed token
+func newCalendarToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// calendarFeedURL is the public address of a feed, based on the request's host
+func calendarFeedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + "/api/v1/calendar/feed/" + token + ".ics"
+}
+
+// writeCalendarToken stores a new feed token for the user and responds with it
+func writeCalendarToken(w http.ResponseWriter, r *http.Request, db *sql.DB, userID int) {
+	token, err := newCalendarToken()
+	if err != nil {
+		http.Error(w, `{"error": "Failed to create calendar token"}`, http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.Exec(`UPDATE users SET calendar_token = ?, updated_at = ? WHERE id = ?`, token, time.Now().UTC(), userID); err != nil {
+		http.Error(w, `{"error": "Failed to create calendar token"}`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(models.CalendarTokenResponse{Token: token, FeedURL: calendarFeedURL(r, token)})
+}
+
+// GetCalendarToken godoc
+//
+//	@ID				getCalendarToken
+//	@Summary		Get the calendar feed address
+//	@Description	Return the secret iCalendar feed URL of the user, creating it on first use.
+//	@Description	Anyone with the URL can read the feed; regenerate the token to revoke it.
+//	@Tags			calendar
+//	@Produce		json
+//	@Success		200	{object}	models.CalendarTokenResponse	"Feed address"
+//	@Failure		401	{object}	map[string]string				"Unauthorized"
+//	@Failure		500	{object}	map[string]string				"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/calendar/token [get]
+func GetCalendarToken(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		var token sql.NullString
+		if err := db.QueryRow(`SELECT calendar_token FROM users WHERE id = ?`, userID).Scan(&token); err != nil {
+			http.Error(w, `{"error": "Failed to load calendar token"}`, http.StatusInternalServerError)
+			return
+		}
+		if !token.Valid {
+			writeCalendarToken(w, r, db, userID)
+			return
+		}
+		json.NewEncoder(w).Encode(models.CalendarTokenResponse{Token: token.String, FeedURL: calendarFeedURL(r, token.String)})
+	}
+}
+
+// RegenerateCalendarToken godoc
+//
+//	@ID				regenerateCalendarToken
+//	@Summary		Regenerate the calendar feed token
+//	@Description	Replace the feed token; the old feed URL stops working immediately.
+//	@Tags			calendar
+//	@Produce		json
+//	@Success		200	{object}	models.CalendarTokenResponse	"New feed address"
+//	@Failure		401	{object}	map[string]string				"Unauthorized"
+//	@Failure		500	{object}	map[string]string				"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/calendar/token [post]
+func RegenerateCalendarToken(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeCalendarToken(w, r, db, r.Context().Value(userIDKey).(int))
+	}
+}
+
+// DeleteCalendarToken godoc
+//
+//	@ID				deleteCalendarToken
+//	@Summary		Disable the calendar feed
+//	@Description	Remove the feed token; the feed URL stops working until a new one is requested.
+//	@Tags			calendar
+//	@Success		204	"Feed disabled"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/calendar/token [delete]
+func DeleteCalendarToken(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+		if _, err := db.Exec(`UPDATE users SET calendar_token = NULL, updated_at = ? WHERE id = ?`, time.Now().UTC(), userID); err != nil {
+			http.Error(w, `{"error": "Failed to disable calendar feed"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CalendarFeed godoc
+//
+//	@ID				calendarFeed
+//	@Summary		iCalendar feed of upcoming workouts
+//	@Description	Public feed for calendar apps, authenticated by the secret token in the URL.
+//	@Description	Lists workouts that are not completed or skipped, from a day ago on, with the planned exercises in the description.
+//	@Tags			calendar
+//	@Produce		text/calendar
+//	@Param			file	path		string	true	"Feed token followed by .ics"
+//	@Success		200		{string}	string	"iCalendar document"
+//	@Failure		404		{object}	map[string]string	"Unknown feed"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/calendar/feed/{file} [get]
+func CalendarFeed(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+		if !ok || token == "" {
+			http.Error(w, `{"error": "Calendar feed not found"}`, http.StatusNotFound)
+			return
+		}
+
+		var userID int
+		var username string
+		err := db.QueryRow(`SELECT id, username FROM users WHERE calendar_token = ?`, token).Scan(&userID, &username)
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"error": "Calendar feed not found"}`, http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, `{"error": "Failed to load calendar feed"}`, http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now().UTC()
+		rows, err := db.Query(`
+			SELECT w.id, w.name, COALESCE(w.description, ''), w.scheduled_for, w.updated_at,
+			       we.sets, we.reps, we.weight, we.notes, e.name
+			FROM workouts w
+			LEFT JOIN workout_exercises we ON w.id = we.workout_id
+			LEFT JOIN exercises e ON we.exercise_id = e.id
+			LEFT JOIN workout_sessions s ON s.workout_id = w.id
+			WHERE w.user_id = ? AND w.completed_at IS NULL AND COALESCE(s.status, '') != ?
+			  AND w.scheduled_for >= ?
+			ORDER BY w.scheduled_for ASC, w.id ASC, we.id ASC`,
+			userID, models.SessionSkipped, now.AddDate(0, 0, -models.CalendarFeedDays))
+		if err != nil {
+			log.Printf("Error querying calendar feed: %v", err)
+			http.Error(w, `{"error": "Failed to load calendar feed"}`, http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var workouts []*models.WorkoutResponse
+		updated := make(map[int]time.Time)
+		byID := make(map[int]*models.WorkoutResponse)
+		for rows.Next() {
+			var workout models.WorkoutResponse
+			var updatedAt sql.NullTime
+			var sets, reps *int
+			var weight *float64
+			var notes, exercise *string
+			if err := rows.Scan(&workout.ID, &workout.Name, &workout.Description, &workout.ScheduledFor, &updatedAt,
+				&sets, &reps, &weight, &notes, &exercise); err != nil {
+				http.Error(w, `{"error": "Failed to load calendar feed"}`, http.StatusInternalServerError)
+				return
+			}
+			current, exists := byID[workout.ID]
+			if !exists {
+				current = &workout
+				byID[workout.ID] = current
+				workouts = append(workouts, current)
+				updated[workout.ID] = now
+				if updatedAt.Valid {
+					updated[workout.ID] = updatedAt.Time
+				}
+			}
+			if exercise != nil && sets != nil && reps != nil && weight != nil {
+				ex := models.WorkoutExerciseResponse{Sets: *sets, Reps: *reps, Weight: *weight, Exercise: models.Exercise{Name: *exercise}}
+				if notes != nil {
+					ex.Notes = *notes
+				}
+				current.Exercises = append(current.Exercises, ex)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, `{"error": "Failed to load calendar feed"}`, http.StatusInternalServerError)
+			return
+		}
+
+		events := make([]models.CalendarEvent, 0, len(workouts))
+		for _, wo := range workouts {
+			events = append(events, models.CalendarEvent{
+				UID:         "workout-" + strconv.Itoa(wo.ID) + "@fitness-workout-tracker",
+				Start:       wo.ScheduledFor,
+				Duration:    models.CalendarEventDuration,
+				Summary:     wo.Name,
+				Description: models.WorkoutEventDescription(wo.Description, wo.Exercises),
+				Updated:     updated[wo.ID],
+			})
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="workouts.ics"`)
+		w.Header().Set("Cache-Control", "private, max-age=300")
+		if err := models.WriteICalendar(w, username+"'s workouts", events); err != nil {
+			log.Printf("Error writing calendar feed: %v", err)
+		}
+	}
+}
@@ -3,44 +3,872 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/models"
 )
 
+// Exercises a user can see: the shared catalog and their own custom ones
+const visibleExercise = `(e.user_id IS NULL OR e.user_id = ?)`
+
+const exerciseColumns = `e.id, e.name, e.category, e.muscle_group, COALESCE(e.description, ''), e.user_id IS NOT NULL, e.updated_at, e.created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExercise(row rowScanner) (models.Exercise, error) {
+	var ex models.Exercise
+	err := row.Scan(&ex.ID, &ex.Name, &ex.Category, &ex.MuscleGroup, &ex.Description, &ex.Custom, &ex.UpdatedAt, &ex.CreatedAt)
+	return ex, err
+}
+
+// loadAliases fills in the aliases of exercises that the user can see
+func loadAliases(db *sql.DB, userID int, exercises []models.Exercise) error {
+	if len(exercises) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(exercises))
+	placeholders := make([]string, 0, len(exercises))
+	args := []any{userID}
+	for i, ex := range exercises {
+		index[ex.ID] = i
+		placeholders = append(placeholders, "?")
+		args = append(args, ex.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT id, exercise_id, alias, user_id IS NOT NULL
+		FROM exercise_aliases
+		WHERE (user_id IS NULL OR user_id = ?) AND exercise_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY alias COLLATE NOCASE`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alias models.ExerciseAlias
+		var exerciseID int
+		if err := rows.Scan(&alias.ID, &exerciseID, &alias.Alias, &alias.Custom); err != nil {
+			return err
+		}
+		i := index[exerciseID]
+		exercises[i].Aliases = append(exercises[i].Aliases, alias)
+	}
+	return rows.Err()
+}
+
+// loadExercise fetches an exercise the user can see, with its aliases.
+// It returns sql.ErrNoRows when the exercise does not exist or is someone else's.
+func loadExercise(db *sql.DB, exerciseID, userID int) (*models.Exercise, error) {
+	ex, err := scanExercise(db.QueryRow(`SELECT `+exerciseColumns+` FROM exercises e WHERE e.id = ? AND `+visibleExercise,
+		exerciseID, userID))
+	if err != nil {
+		return nil, err
+	}
+	exercises := []models.Exercise{ex}
+	if err := loadAliases(db, userID, exercises); err != nil {
+		return nil, err
+	}
+	return &exercises[0], nil
+}
+
+// exercisesVisible reports whether the user can see every planned exercise
+func exercisesVisible(db *sql.DB, userID int, exercises []models.WorkoutExerciseRequest) (bool, error) {
+	ids := make(map[int]bool)
+	for _, ex := range exercises {
+		ids[ex.ExerciseID] = true
+	}
+	for id := range ids {
+		var exists bool
+		err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM exercises e WHERE e.id = ? AND `+visibleExercise+`)`, id, userID).Scan(&exists)
+		if err != nil || !exists {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ownerArg is the user_id stored for a catalog (NULL) or custom exercise or alias
+func ownerArg(catalog bool, userID int) any {
+	if catalog {
+		return nil
+	}
+	return userID
+}
+
+// nameTaken reports whether a name is already used by another exercise or
+// alias in the catalog or, for custom exercises, among the user's own
+func nameTaken(db *sql.DB, name string, catalog bool, userID, exceptExerciseID int) (bool, error) {
+	scope, args := "user_id IS NULL", []any{}
+	if !catalog {
+		scope, args = "(user_id IS NULL OR user_id = ?)", []any{userID}
+	}
+	query := `SELECT EXISTS(SELECT 1 FROM exercises WHERE name = ? COLLATE NOCASE AND id != ? AND ` + scope + `)
+		OR EXISTS(SELECT 1 FROM exercise_aliases WHERE alias = ? COLLATE NOCASE AND exercise_id != ? AND ` + scope + `)`
+	allArgs := append([]any{name, exceptExerciseID}, args...)
+	allArgs = append(append(allArgs, name, exceptExerciseID), args...)
+
+	var taken bool
+	err := db.QueryRow(query, allArgs...).Scan(&taken)
+	return taken, err
+}
+
+// manageableExercise resolves the {id} path value to an exercise the caller may
+// change: a catalog exercise on admin routes, the user's own custom exercise
+// otherwise. It writes the error response and returns false on failure.
+func manageableExercise(w http.ResponseWriter, r *http.Request, db *sql.DB, catalog bool) (int, string, bool) {
+	exerciseID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Invalid exercise ID"}`, http.StatusBadRequest)
+		return 0, "", false
+	}
+	userID := r.Context().Value(userIDKey).(int)
+
+	var owner sql.NullInt64
+	var name string
+	err = db.QueryRow(`SELECT user_id, name FROM exercises WHERE id = ?`, exerciseID).Scan(&owner, &name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows), catalog && owner.Valid, !catalog && owner.Valid && int(owner.Int64) != userID:
+		http.Error(w, `{"error": "Exercise not found"}`, http.StatusNotFound)
+		return 0, "", false
+	case err != nil:
+		http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+		return 0, "", false
+	case !catalog && !owner.Valid:
+		http.Error(w, `{"error": "Catalog exercises can only be changed by admins"}`, http.StatusForbidden)
+		return 0, "", false
+	}
+	return exerciseID, name, true
+}
+
 // GetExercises godoc
 //
 //	@ID				getExercises
 //	@Summary		Get all exercises
-//	@Description	Retrieve a list of all available exercises
+//	@Description	Retrieve the exercise catalog and the user's custom exercises, filtered and paginated.
+//	@Description	q searches names and aliases; muscle_group also matches combined groups such as Back/Legs.
+//	@Description	The total number of matches is returned in the X-Total-Count header.
 //	@Security		BearerToken
 //	@Tags			exercises
 //	@Accept			json
 //	@Produce		json
+//	@Param			q				query		string	false	"Search in names and aliases"
+//	@Param			category		query		string	false	"Category, e.g. Strength"
+//	@Param			muscle_group	query		string	false	"Muscle group, e.g. Legs"
+//	@Param			scope			query		string	false	"all (default), catalog or custom"
+//	@Param			page			query		int		false	"Page, default 1"
+//	@Param			page_size		query		int		false	"Page size, default 50, max 200"
 //	@Success		200	{array}		models.Exercise		"List of exercises"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
 //	@Failure		401	{object}	map[string]string	"Unauthorized"
 //	@Failure		500	{object}	map[string]string	"Internal server error"
 //	@Security		BearerAuth
 //	@Router			/exercises [get]
 func GetExercises(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query("SELECT id, name, category, muscle_group, description FROM exercises")
+		userID := r.Context().Value(userIDKey).(int)
+		q := r.URL.Query()
+
+		where := []string{visibleExercise}
+		args := []any{userID}
+		switch q.Get("scope") {
+		case "", "all":
+		case "catalog":
+			where = append(where, "e.user_id IS NULL")
+		case "custom":
+			where = append(where, "e.user_id IS NOT NULL")
+		default:
+			http.Error(w, `{"error": "scope must be all, catalog or custom"}`, http.StatusBadRequest)
+			return
+		}
+		if category := strings.TrimSpace(q.Get("category")); category != "" {
+			where = append(where, "e.category = ? COLLATE NOCASE")
+			args = append(args, category)
+		}
+		if group := strings.TrimSpace(q.Get("muscle_group")); group != "" {
+			where = append(where, "('/' || e.muscle_group || '/') LIKE ?")
+			args = append(args, "%/"+group+"/%")
+		}
+		if search := strings.TrimSpace(q.Get("q")); search != "" {
+			where = append(where, `(e.name LIKE ? OR EXISTS(
+				SELECT 1 FROM exercise_aliases a
+				WHERE a.exercise_id = e.id AND a.alias LIKE ? AND (a.user_id IS NULL OR a.user_id = ?)))`)
+			pattern := "%" + search + "%"
+			args = append(args, pattern, pattern, userID)
+		}
+
+		page, pageSize := 1, models.DefaultExercisePageSize
+		var err error
+		if v := q.Get("page"); v != "" {
+			if page, err = strconv.Atoi(v); err != nil || page < 1 {
+				http.Error(w, `{"error": "invalid page"}`, http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("page_size"); v != "" {
+			if pageSize, err = strconv.Atoi(v); err != nil || pageSize < 1 || pageSize > models.MaxExercisePageSize {
+				http.Error(w, fmt.Sprintf(`{"error": "page_size must be between 1 and %d"}`, models.MaxExercisePageSize), http.StatusBadRequest)
+				return
+			}
+		}
+
+		filter := strings.Join(where, " AND ")
+		var total int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM exercises e WHERE `+filter, args...).Scan(&total); err != nil {
+			http.Error(w, `{"error": "Failed to fetch exercises"}`, http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := db.Query(`SELECT `+exerciseColumns+` FROM exercises e WHERE `+filter+`
+			ORDER BY e.name COLLATE NOCASE, e.id LIMIT ? OFFSET ?`,
+			append(args, pageSize, (page-1)*pageSize)...)
 		if err != nil {
 			http.Error(w, `{"error": "Failed to fetch exercises"}`, http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
 
-		var exercises []models.Exercise
+		exercises := []models.Exercise{}
 		for rows.Next() {
-			var ex models.Exercise
-			if err := rows.Scan(&ex.ID, &ex.Name, &ex.Category, &ex.MuscleGroup, &ex.Description); err != nil {
+			ex, err := scanExercise(rows)
+			if err != nil {
 				http.Error(w, `{"error": "Error scanning exercises"}`, http.StatusInternalServerError)
 				return
 			}
 			exercises = append(exercises, ex)
 		}
+		rows.Close()
+		if err := loadAliases(db, userID, exercises); err != nil {
+			http.Error(w, `{"error": "Failed to fetch aliases"}`, http.StatusInternalServerError)
+			return
+		}
 
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
 		json.NewEncoder(w).Encode(exercises)
 	}
 }
+
+// GetExercise godoc
+//
+//	@ID				getExercise
+//	@Summary		Get an exercise
+//	@Description	Retrieve a catalog exercise or one of the user's custom exercises, with its aliases
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int					true	"Exercise ID"
+//	@Success		200	{object}	models.Exercise		"Exercise"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		404	{object}	map[string]string	"Exercise not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/exercises/{id} [get]
+func GetExercise(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		exerciseID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid exercise ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		exercise, err := loadExercise(db, exerciseID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Exercise not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(exercise)
+	}
+}
+
+func createExercise(db *sql.DB, catalog bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		var req models.ExerciseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusUnprocessableEntity)
+			return
+		}
+		taken, err := nameTaken(db, req.Name, catalog, userID, 0)
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if taken {
+			http.Error(w, `{"error": "An exercise with this name already exists"}`, http.StatusConflict)
+			return
+		}
+
+		res, err := db.Exec(`INSERT INTO exercises (user_id, name, category, muscle_group, description) VALUES (?, ?, ?, ?, ?)`,
+			ownerArg(catalog, userID), req.Name, req.Category, req.MuscleGroup, req.Description)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to create exercise"}`, http.StatusInternalServerError)
+			return
+		}
+		exerciseID, _ := res.LastInsertId()
+
+		writeExercise(w, db, int(exerciseID), userID, http.StatusCreated)
+	}
+}
+
+// writeExercise responds with the current state of an exercise
+func writeExercise(w http.ResponseWriter, db *sql.DB, exerciseID, userID, status int) {
+	exercise, err := loadExercise(db, exerciseID, userID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to load exercise"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(exercise)
+}
+
+func updateExercise(db *sql.DB, catalog bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		exerciseID, oldName, ok := manageableExercise(w, r, db, catalog)
+		if !ok {
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		var req models.ExerciseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusUnprocessableEntity)
+			return
+		}
+		taken, err := nameTaken(db, req.Name, catalog, userID, exerciseID)
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if taken {
+			http.Error(w, `{"error": "An exercise with this name already exists"}`, http.StatusConflict)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(`UPDATE exercises SET name = ?, category = ?, muscle_group = ?, description = ?, updated_at = ? WHERE id = ?`,
+			req.Name, req.Category, req.MuscleGroup, req.Description, time.Now(), exerciseID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to update exercise"}`, http.StatusInternalServerError)
+			return
+		}
+		// A renamed exercise stays findable by its old name
+		if !strings.EqualFold(oldName, req.Name) {
+			_, err = tx.Exec(`INSERT OR IGNORE INTO exercise_aliases (exercise_id, user_id, alias) VALUES (?, ?, ?)`,
+				exerciseID, ownerArg(catalog, userID), oldName)
+			if err != nil {
+				http.Error(w, `{"error": "Failed to keep the old name"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to update exercise"}`, http.StatusInternalServerError)
+			return
+		}
+
+		writeExercise(w, db, exerciseID, userID, http.StatusOK)
+	}
+}
+
+func deleteExercise(db *sql.DB, catalog bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		exerciseID, _, ok := manageableExercise(w, r, db, catalog)
+		if !ok {
+			return
+		}
+
+		var used bool
+		err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM workout_exercises WHERE exercise_id = ?)
+			OR EXISTS(SELECT 1 FROM session_sets WHERE exercise_id = ?)
+			OR EXISTS(SELECT 1 FROM template_exercises WHERE exercise_id = ?)`,
+			exerciseID, exerciseID, exerciseID).Scan(&used)
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if used {
+			http.Error(w, `{"error": "Exercise has workout history; merge it into another exercise instead"}`, http.StatusConflict)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`DELETE FROM exercise_aliases WHERE exercise_id = ?`, exerciseID); err != nil {
+			http.Error(w, `{"error": "Failed to delete child records"}`, http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(`DELETE FROM exercises WHERE id = ?`, exerciseID); err != nil {
+			http.Error(w, `{"error": "Failed to delete exercise"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to delete"}`, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func mergeExercise(db *sql.DB, catalog bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sourceID, sourceName, ok := manageableExercise(w, r, db, catalog)
+		if !ok {
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		var req models.MergeExerciseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		if req.IntoID == sourceID {
+			http.Error(w, `{"error": "Cannot merge an exercise into itself"}`, http.StatusUnprocessableEntity)
+			return
+		}
+
+		// The catalog only merges into the catalog; users merge into anything they can see
+		var intoOwner sql.NullInt64
+		err := db.QueryRow(`SELECT user_id FROM exercises e WHERE e.id = ? AND `+visibleExercise, req.IntoID, userID).Scan(&intoOwner)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && catalog && intoOwner.Valid) {
+			http.Error(w, `{"error": "Target exercise not found"}`, http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		// Move the history and aliases over, keep the old name as an alias, drop the duplicate
+		for _, query := range []string{
+			`UPDATE workout_exercises SET exercise_id = ? WHERE exercise_id = ?`,
+			`UPDATE session_sets SET exercise_id = ? WHERE exercise_id = ?`,
+			`UPDATE template_exercises SET exercise_id = ? WHERE exercise_id = ?`,
+			`UPDATE OR IGNORE exercise_aliases SET exercise_id = ? WHERE exercise_id = ?`,
+		} {
+			if _, err := tx.Exec(query, req.IntoID, sourceID); err != nil {
+				http.Error(w, `{"error": "Failed to merge exercise"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		_, err = tx.Exec(`INSERT OR IGNORE INTO exercise_aliases (exercise_id, user_id, alias) VALUES (?, ?, ?)`,
+			req.IntoID, ownerArg(catalog, userID), sourceName)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to merge exercise"}`, http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(`DELETE FROM exercise_aliases WHERE exercise_id = ?`, sourceID); err != nil {
+			http.Error(w, `{"error": "Failed to merge exercise"}`, http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(`DELETE FROM exercises WHERE id = ?`, sourceID); err != nil {
+			http.Error(w, `{"error": "Failed to merge exercise"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to merge exercise"}`, http.StatusInternalServerError)
+			return
+		}
+
+		writeExercise(w, db, req.IntoID, userID, http.StatusOK)
+	}
+}
+
+func addAlias(db *sql.DB, catalog bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		exerciseID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid exercise ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		var req models.AliasRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		req.Alias = strings.TrimSpace(req.Alias)
+		if req.Alias == "" || len(req.Alias) > 100 {
+			http.Error(w, `{"error": "alias is required"}`, http.StatusUnprocessableEntity)
+			return
+		}
+
+		// Shared aliases belong to catalog exercises; users alias anything they can see
+		var owner sql.NullInt64
+		err = db.QueryRow(`SELECT user_id FROM exercises e WHERE e.id = ? AND `+visibleExercise, exerciseID, userID).Scan(&owner)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && catalog && owner.Valid) {
+			http.Error(w, `{"error": "Exercise not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		taken, err := nameTaken(db, req.Alias, catalog, userID, 0)
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if taken {
+			http.Error(w, `{"error": "An exercise with this name already exists"}`, http.StatusConflict)
+			return
+		}
+
+		_, err = db.Exec(`INSERT INTO exercise_aliases (exercise_id, user_id, alias) VALUES (?, ?, ?)`,
+			exerciseID, ownerArg(catalog, userID), req.Alias)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to add alias"}`, http.StatusInternalServerError)
+			return
+		}
+
+		writeExercise(w, db, exerciseID, userID, http.StatusCreated)
+	}
+}
+
+func deleteAlias(db *sql.DB, catalog bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		exerciseID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid exercise ID"}`, http.StatusBadRequest)
+			return
+		}
+		aliasID, err := strconv.Atoi(r.PathValue("aliasId"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid alias ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		query, args := `DELETE FROM exercise_aliases WHERE id = ? AND exercise_id = ? AND user_id IS NULL`, []any{aliasID, exerciseID}
+		if !catalog {
+			query, args = `DELETE FROM exercise_aliases WHERE id = ? AND exercise_id = ? AND user_id = ?`, []any{aliasID, exerciseID, userID}
+		}
+		res, err := db.Exec(query, args...)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to delete alias"}`, http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, `{"error": "Alias not found"}`, http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CreateExercise godoc
+//
+//	@ID				createExercise
+//	@Summary		Create a custom exercise
+//	@Description	Create a private exercise that only the authenticated user can see and use
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			exerciseRequest	body		models.ExerciseRequest	true	"Exercise"
+//	@Success		201	{object}	models.Exercise		"Exercise created"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		409	{object}	map[string]string	"Name already in use"
+//	@Failure		422	{object}	map[string]string	"Validation errors"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/exercises [post]
+func CreateExercise(db *sql.DB) http.HandlerFunc {
+	return createExercise(db, false)
+}
+
+// UpdateExercise godoc
+//
+//	@ID				updateExercise
+//	@Summary		Update a custom exercise
+//	@Description	Update one of the user's custom exercises. A renamed exercise keeps its old name as an alias.
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Exercise ID"
+//	@Param			exerciseRequest	body		models.ExerciseRequest	true	"Exercise"
+//	@Success		200	{object}	models.Exercise		"Updated exercise"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{object}	map[string]string	"Catalog exercise"
+//	@Failure		404	{object}	map[string]string	"Exercise not found"
+//	@Failure		409	{object}	map[string]string	"Name already in use"
+//	@Failure		422	{object}	map[string]string	"Validation errors"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/exercises/{id} [put]
+func UpdateExercise(db *sql.DB) http.HandlerFunc {
+	return updateExercise(db, false)
+}
+
+// DeleteExercise godoc
+//
+//	@ID				deleteExercise
+//	@Summary		Delete a custom exercise
+//	@Description	Delete one of the user's custom exercises that has no workout history
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int					true	"Exercise ID"
+//	@Success		204	{string}	string				"Exercise deleted"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{object}	map[string]string	"Catalog exercise"
+//	@Failure		404	{object}	map[string]string	"Exercise not found"
+//	@Failure		409	{object}	map[string]string	"Exercise has workout history"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/exercises/{id} [delete]
+func DeleteExercise(db *sql.DB) http.HandlerFunc {
+	return deleteExercise(db, false)
+}
+
+// MergeExercise godoc
+//
+//	@ID				mergeExercise
+//	@Summary		Merge a custom exercise into another
+//	@Description	Move the workouts, logged sets, templates and aliases of a custom exercise to another
+//	@Description	exercise the user can see, keep its name as a private alias and delete it
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			id						path		int							true	"Exercise ID"
+//	@Param			mergeExerciseRequest	body		models.MergeExerciseRequest	true	"Target exercise"
+//	@Success		200	{object}	models.Exercise		"Target exercise"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{object}	map[string]string	"Catalog exercise"
+//	@Failure		404	{object}	map[string]string	"Exercise not found"
+//	@Failure		422	{object}	map[string]string	"Invalid target"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/exercises/{id}/merge [post]
+func MergeExercise(db *sql.DB) http.HandlerFunc {
+	return mergeExercise(db, false)
+}
+
+// AddExerciseAlias godoc
+//
+//	@ID				addExerciseAlias
+//	@Summary		Add a private alias
+//	@Description	Add a name that only the authenticated user finds an exercise by
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int					true	"Exercise ID"
+//	@Param			aliasRequest	body		models.AliasRequest	true	"Alias"
+//	@Success		201	{object}	models.Exercise		"Exercise with the new alias"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		404	{object}	map[string]string	"Exercise not found"
+//	@Failure		409	{object}	map[string]string	"Name already in use"
+//	@Failure		422	{object}	map[string]string	"Validation errors"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/exercises/{id}/aliases [post]
+func AddExerciseAlias(db *sql.DB) http.HandlerFunc {
+	return addAlias(db, false)
+}
+
+// DeleteExerciseAlias godoc
+//
+//	@ID				deleteExerciseAlias
+//	@Summary		Delete a private alias
+//	@Description	Remove one of the user's own aliases
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Exercise ID"
+//	@Param			aliasId	path		int					true	"Alias ID"
+//	@Success		204		{string}	string				"Alias deleted"
+//	@Failure		400		{object}	map[string]string	"Invalid request"
+//	@Failure		401		{object}	map[string]string	"Unauthorized"
+//	@Failure		404		{object}	map[string]string	"Alias not found"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/exercises/{id}/aliases/{aliasId} [delete]
+func DeleteExerciseAlias(db *sql.DB) http.HandlerFunc {
+	return deleteAlias(db, false)
+}
+
+// AdminCreateExercise godoc
+//
+//	@ID				adminCreateExercise
+//	@Summary		Add a catalog exercise
+//	@Description	Add an exercise to the shared catalog (admin only)
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			exerciseRequest	body		models.ExerciseRequest	true	"Exercise"
+//	@Success		201	{object}	models.Exercise		"Exercise created"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{object}	map[string]string	"Admin access required"
+//	@Failure		409	{object}	map[string]string	"Name already in use"
+//	@Failure		422	{object}	map[string]string	"Validation errors"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/admin/exercises [post]
+func AdminCreateExercise(db *sql.DB) http.HandlerFunc {
+	return createExercise(db, true)
+}
+
+// AdminUpdateExercise godoc
+//
+//	@ID				adminUpdateExercise
+//	@Summary		Update a catalog exercise
+//	@Description	Update a shared catalog exercise (admin only). A renamed exercise keeps its old name as an alias.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Exercise ID"
+//	@Param			exerciseRequest	body		models.ExerciseRequest	true	"Exercise"
+//	@Success		200	{object}	models.Exercise		"Updated exercise"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{object}	map[string]string	"Admin access required"
+//	@Failure		404	{object}	map[string]string	"Exercise not found"
+//	@Failure		409	{object}	map[string]string	"Name already in use"
+//	@Failure		422	{object}	map[string]string	"Validation errors"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/admin/exercises/{id} [put]
+func AdminUpdateExercise(db *sql.DB) http.HandlerFunc {
+	return updateExercise(db, true)
+}
+
+// AdminDeleteExercise godoc
+//
+//	@ID				adminDeleteExercise
+//	@Summary		Delete a catalog exercise
+//	@Description	Delete a shared catalog exercise that nobody has used (admin only)
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int					true	"Exercise ID"
+//	@Success		204	{string}	string				"Exercise deleted"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{object}	map[string]string	"Admin access required"
+//	@Failure		404	{object}	map[string]string	"Exercise not found"
+//	@Failure		409	{object}	map[string]string	"Exercise has workout history"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/admin/exercises/{id} [delete]
+func AdminDeleteExercise(db *sql.DB) http.HandlerFunc {
+	return deleteExercise(db, true)
+}
+
+// AdminMergeExercise godoc
+//
+//	@ID				adminMergeExercise
+//	@Summary		Merge duplicate catalog exercises
+//	@Description	Move every user's workouts, logged sets, templates and the aliases of a catalog exercise
+//	@Description	to another catalog exercise, keep its name as a shared alias and delete it (admin only)
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id						path		int							true	"Exercise ID"
+//	@Param			mergeExerciseRequest	body		models.MergeExerciseRequest	true	"Target exercise"
+//	@Success		200	{object}	models.Exercise		"Target exercise"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{object}	map[string]string	"Admin access required"
+//	@Failure		404	{object}	map[string]string	"Exercise not found"
+//	@Failure		422	{object}	map[string]string	"Invalid target"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/admin/exercises/{id}/merge [post]
+func AdminMergeExercise(db *sql.DB) http.HandlerFunc {
+	return mergeExercise(db, true)
+}
+
+// AdminAddExerciseAlias godoc
+//
+//	@ID				adminAddExerciseAlias
+//	@Summary		Add a shared alias
+//	@Description	Add a name that everyone finds a catalog exercise by (admin only)
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int					true	"Exercise ID"
+//	@Param			aliasRequest	body		models.AliasRequest	true	"Alias"
+//	@Success		201	{object}	models.Exercise		"Exercise with the new alias"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{object}	map[string]string	"Admin access required"
+//	@Failure		404	{object}	map[string]string	"Exercise not found"
+//	@Failure		409	{object}	map[string]string	"Name already in use"
+//	@Failure		422	{object}	map[string]string	"Validation errors"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/admin/exercises/{id}/aliases [post]
+func AdminAddExerciseAlias(db *sql.DB) http.HandlerFunc {
+	return addAlias(db, true)
+}
+
+// AdminDeleteExerciseAlias godoc
+//
+//	@ID				adminDeleteExerciseAlias
+//	@Summary		Delete a shared alias
+//	@Description	Remove a shared alias of a catalog exercise (admin only)
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Exercise ID"
+//	@Param			aliasId	path		int					true	"Alias ID"
+//	@Success		204		{string}	string				"Alias deleted"
+//	@Failure		400		{object}	map[string]string	"Invalid request"
+//	@Failure		401		{object}	map[string]string	"Unauthorized"
+//	@Failure		403		{object}	map[string]string	"Admin access required"
+//	@Failure		404		{object}	map[string]string	"Alias not found"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/admin/exercises/{id}/aliases/{aliasId} [delete]
+func AdminDeleteExerciseAlias(db *sql.DB) http.HandlerFunc {
+	return deleteAlias(db, true)
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,13 +43,60 @@ func setupTestServer(t *testing.T) (*sql.DB, http.Handler, string) {
 	v1.HandleFunc("POST /auth/register", RegisterUser(db))
 	v1.HandleFunc("POST /auth/login", LoginUser(db, jwtSecret))
 	auth := AuthMiddleware(jwtSecret)
+	adminOnly := AdminMiddleware(db)
 	v1.Handle("GET /exercises", auth(GetExercises(db)))
+	v1.Handle("POST /exercises", auth(CreateExercise(db)))
+	v1.Handle("GET /exercises/{id}", auth(GetExercise(db)))
+	v1.Handle("PUT /exercises/{id}", auth(UpdateExercise(db)))
+	v1.Handle("DELETE /exercises/{id}", auth(DeleteExercise(db)))
+	v1.Handle("POST /exercises/{id}/merge", auth(MergeExercise(db)))
+	v1.Handle("POST /exercises/{id}/aliases", auth(AddExerciseAlias(db)))
+	v1.Handle("DELETE /exercises/{id}/aliases/{aliasId}", auth(DeleteExerciseAlias(db)))
+	v1.Handle("POST /admin/exercises", auth(adminOnly(AdminCreateExercise(db))))
+	v1.Handle("PUT /admin/exercises/{id}", auth(adminOnly(AdminUpdateExercise(db))))
+	v1.Handle("DELETE /admin/exercises/{id}", auth(adminOnly(AdminDeleteExercise(db))))
+	v1.Handle("POST /admin/exercises/{id}/merge", auth(adminOnly(AdminMergeExercise(db))))
+	v1.Handle("POST /admin/exercises/{id}/aliases", auth(adminOnly(AdminAddExerciseAlias(db))))
+	v1.Handle("DELETE /admin/exercises/{id}/aliases/{aliasId}", auth(adminOnly(AdminDeleteExerciseAlias(db))))
 	v1.Handle("POST /workouts", auth(CreateWorkout(db)))
 	v1.Handle("GET /workouts", auth(ListWorkouts(db)))
 	v1.Handle("PUT /workouts/{id}", auth(UpdateWorkout(db)))
 	v1.Handle("GET /workouts/{id}", auth(GetWorkout(db)))
 	v1.Handle("DELETE /workouts/{id}", auth(DeleteWorkout(db)))
+	v1.Handle("POST /workouts/{id}/complete", auth(CompleteWorkout(db)))
+	v1.Handle("POST /workouts/{id}/sessions", auth(StartSession(db)))
+	v1.Handle("POST /workouts/{id}/skip", auth(SkipWorkout(db)))
+	v1.Handle("GET /workouts/{id}/comparison", auth(GetWorkoutComparison(db)))
+	v1.Handle("GET /sessions/{id}", auth(GetSession(db)))
+	v1.Handle("POST /sessions/{id}/sets", auth(LogSet(db)))
+	v1.Handle("PUT /sessions/{id}/sets/{setId}", auth(UpdateSet(db)))
+	v1.Handle("DELETE /sessions/{id}/sets/{setId}", auth(DeleteSet(db)))
+	v1.Handle("POST /sessions/{id}/complete", auth(CompleteSession(db)))
+	v1.Handle("POST /templates", auth(CreateTemplate(db)))
+	v1.Handle("GET /templates", auth(ListTemplates(db)))
+	v1.Handle("GET /templates/{id}", auth(GetTemplate(db)))
+	v1.Handle("PUT /templates/{id}", auth(UpdateTemplate(db)))
+	v1.Handle("DELETE /templates/{id}", auth(DeleteTemplate(db)))
+	v1.Handle("POST /templates/{id}/clone", auth(CloneTemplate(db)))
+	v1.Handle("POST /templates/{id}/workouts", auth(ScheduleTemplate(db)))
+	v1.Handle("POST /programs", auth(CreateProgram(db)))
+	v1.Handle("GET /programs", auth(ListPrograms(db)))
+	v1.Handle("GET /programs/{id}", auth(GetProgram(db)))
+	v1.Handle("DELETE /programs/{id}", auth(DeleteProgram(db)))
+	v1.Handle("POST /programs/{id}/apply", auth(ApplyProgram(db)))
+	v1.Handle("POST /programs/{id}/runs/{runId}/reschedule", auth(RescheduleProgramRun(db)))
 	v1.Handle("GET /workouts/reports", auth(GetWorkoutReport(db)))
+	v1.Handle("GET /stats/records", auth(GetRecords(db)))
+	v1.Handle("GET /stats/volume", auth(GetWeeklyVolume(db)))
+	v1.Handle("GET /stats/streaks", auth(GetStreaks(db)))
+	v1.Handle("GET /stats/adherence", auth(GetAdherence(db)))
+	v1.Handle("GET /stats/progression", auth(GetProgression(db)))
+	v1.Handle("GET /export", auth(ExportWorkouts(db)))
+	v1.Handle("POST /import", auth(ImportWorkouts(db)))
+	v1.Handle("GET /calendar/token", auth(GetCalendarToken(db)))
+	v1.Handle("POST /calendar/token", auth(RegenerateCalendarToken(db)))
+	v1.Handle("DELETE /calendar/token", auth(DeleteCalendarToken(db)))
+	v1.HandleFunc("GET /calendar/feed/{file}", CalendarFeed(db))
 	root.Handle("/api/v1/", JSONMiddleware(http.StripPrefix("/api/v1", v1)))
 
 	return db, root, jwtSecret
@@ -74,10 +123,15 @@ func doRequest(t *testing.T, handler http.Handler, method, path string, body any
 }
 
 func registerAndLogin(t *testing.T, h http.Handler) string {
+	t.Helper()
+	return registerAndLoginAs(t, h, "alice", "alice@example.com")
+}
+
+func registerAndLoginAs(t *testing.T, h http.Handler, username, email string) string {
 	t.Helper()
 	reg := map[string]string{
-		"username": "alice",
-		"email":    "alice@example.com",
+		"username": username,
+		"email":    email,
 		"password": "Secret123!",
 	}
 	rr := doRequest(t, h, http.MethodPost, "/api/v1/auth/register", reg, nil)
@@ -85,7 +139,7 @@ func registerAndLogin(t *testing.T, h http.Handler) string {
 		t.Fatalf("register status = %d, body=%s", rr.Code, rr.Body.String())
 	}
 	login := map[string]string{
-		"email":    "alice@example.com",
+		"email":    email,
 		"password": "Secret123!",
 	}
 	rr = doRequest(t, h, http.MethodPost, "/api/v1/auth/login", login, nil)
@@ -195,6 +249,715 @@ func TestWorkoutCRUDAndReport(t *testing.T) {
 	}
 }
 
+func TestStats(t *testing.T) {
+	db, h, _ := setupTestServer(t)
+	token := registerAndLogin(t, h)
+	headers := map[string]string{"Authorization": "Bearer " + token}
+
+	// Two bench sessions on consecutive days, the second one heavier, and a
+	// missed squat session
+	yesterday := time.Now().UTC().Add(-24 * time.Hour)
+	sessions := []struct {
+		day      time.Time
+		weight   float64
+		complete bool
+	}{
+		{yesterday.Add(-24 * time.Hour), 80, true},
+		{yesterday, 90, true},
+		{yesterday.Add(-48 * time.Hour), 0, false},
+	}
+	for _, s := range sessions {
+		exercise := 1 // Barbell Bench Press
+		if !s.complete {
+			exercise = 6 // Squat
+		}
+		create := models.CreateWorkoutRequest{
+			Name:         "Session",
+			ScheduledFor: s.day,
+			Exercises:    []models.WorkoutExerciseRequest{{ExerciseID: exercise, Sets: 3, Reps: 5, Weight: s.weight}},
+		}
+		if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts", create, headers); rr.Code != http.StatusCreated {
+			t.Fatalf("create workout expected 201, got %d", rr.Code)
+		}
+		if !s.complete {
+			continue
+		}
+		var wid int
+		if err := db.QueryRow("SELECT id FROM workouts ORDER BY id DESC LIMIT 1").Scan(&wid); err != nil {
+			t.Fatalf("get workout id: %v", err)
+		}
+		body := models.CompleteWorkoutRequest{CompletedAt: &s.day}
+		if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts/"+intToPath(wid)+"/complete", body, headers); rr.Code != http.StatusOK {
+			t.Fatalf("complete workout expected 200, got %d, body=%s", rr.Code, rr.Body.String())
+		}
+	}
+
+	// Records: the heavier session is a PR for all three record types
+	rr := doRequest(t, h, http.MethodGet, "/api/v1/stats/records", nil, headers)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("records expected 200, got %d", rr.Code)
+	}
+	var records models.RecordsResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &records)
+	if len(records.Records) != 1 || records.Records[0].MaxWeight != 90 || records.Records[0].EstimatedMax != 105 {
+		t.Fatalf("unexpected records: %+v", records.Records)
+	}
+	if len(records.RecentPRs) != 3 || records.RecentPRs[0].Previous == 0 {
+		t.Fatalf("expected 3 personal records, got %+v", records.RecentPRs)
+	}
+
+	// Volume counts completed workouts only
+	rr = doRequest(t, h, http.MethodGet, "/api/v1/stats/volume?weeks=2", nil, headers)
+	var weeks []models.WeeklyVolume
+	_ = json.Unmarshal(rr.Body.Bytes(), &weeks)
+	var total float64
+	for _, w := range weeks {
+		total += w.Total
+	}
+	if len(weeks) != 2 || total != 15*80+15*90 {
+		t.Fatalf("unexpected weekly volume: %+v", weeks)
+	}
+	if rr := doRequest(t, h, http.MethodGet, "/api/v1/stats/volume?weeks=0", nil, headers); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for weeks=0, got %d", rr.Code)
+	}
+
+	// Streak of two days ending yesterday
+	rr = doRequest(t, h, http.MethodGet, "/api/v1/stats/streaks", nil, headers)
+	var streaks models.StreaksResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &streaks)
+	if streaks.CurrentDays != 2 || streaks.LongestDays != 2 {
+		t.Fatalf("unexpected streaks: %+v", streaks)
+	}
+
+	// Two of three due workouts completed
+	rr = doRequest(t, h, http.MethodGet, "/api/v1/stats/adherence", nil, headers)
+	var adherence models.AdherenceResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &adherence)
+	if adherence.Due != 3 || adherence.Completed != 2 || adherence.Missed != 1 || adherence.Adherence != 66.7 {
+		t.Fatalf("unexpected adherence: %+v", adherence)
+	}
+
+	// Progression has one point per completed session, oldest first
+	rr = doRequest(t, h, http.MethodGet, "/api/v1/stats/progression?exercise_id=1", nil, headers)
+	var progression models.ProgressionResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &progression)
+	if len(progression.Points) != 2 || progression.Points[0].MaxWeight != 80 || progression.Points[1].MaxWeight != 90 {
+		t.Fatalf("unexpected progression: %+v", progression)
+	}
+	if rr := doRequest(t, h, http.MethodGet, "/api/v1/stats/progression?exercise_id=999", nil, headers); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown exercise, got %d", rr.Code)
+	}
+}
+
+func TestSessionLogging(t *testing.T) {
+	db, h, _ := setupTestServer(t)
+	token := registerAndLogin(t, h)
+	headers := map[string]string{"Authorization": "Bearer " + token}
+
+	// Plan: 3 x 5 bench at 100
+	create := models.CreateWorkoutRequest{
+		Name:         "Push",
+		ScheduledFor: time.Now().Add(-time.Hour).UTC(),
+		Exercises:    []models.WorkoutExerciseRequest{{ExerciseID: 1, Sets: 3, Reps: 5, Weight: 100}},
+	}
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts", create, headers); rr.Code != http.StatusCreated {
+		t.Fatalf("create workout expected 201, got %d", rr.Code)
+	}
+	var wid int
+	if err := db.QueryRow("SELECT id FROM workouts ORDER BY id DESC LIMIT 1").Scan(&wid); err != nil {
+		t.Fatalf("get workout id: %v", err)
+	}
+	workoutPath := "/api/v1/workouts/" + intToPath(wid)
+
+	// Start the session; a second start conflicts
+	rr := doRequest(t, h, http.MethodPost, workoutPath+"/sessions", nil, headers)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("start session expected 201, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var session models.WorkoutSession
+	_ = json.Unmarshal(rr.Body.Bytes(), &session)
+	if session.Status != models.SessionInProgress {
+		t.Fatalf("expected in_progress session, got %q", session.Status)
+	}
+	if rr := doRequest(t, h, http.MethodPost, workoutPath+"/sessions", nil, headers); rr.Code != http.StatusConflict {
+		t.Fatalf("second start expected 409, got %d", rr.Code)
+	}
+
+	// Log two sets at 100 and an invalid one
+	sessionPath := "/api/v1/sessions/" + intToPath(session.ID)
+	rpe := 8.0
+	for i := 0; i < 2; i++ {
+		set := models.LogSetRequest{ExerciseID: 1, Reps: 5, Weight: 100, RPE: &rpe}
+		rr = doRequest(t, h, http.MethodPost, sessionPath+"/sets", set, headers)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("log set expected 201, got %d, body=%s", rr.Code, rr.Body.String())
+		}
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &session)
+	if len(session.Sets) != 2 || session.Sets[1].SetNumber != 2 {
+		t.Fatalf("expected two numbered sets, got %+v", session.Sets)
+	}
+	bad := models.LogSetRequest{ExerciseID: 1, Reps: 0, Weight: 100}
+	if rr := doRequest(t, h, http.MethodPost, sessionPath+"/sets", bad, headers); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid set expected 422, got %d", rr.Code)
+	}
+
+	// Planned vs actual: 2 of 3 sets done
+	rr = doRequest(t, h, http.MethodGet, workoutPath+"/comparison", nil, headers)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("comparison expected 200, got %d", rr.Code)
+	}
+	var comparison models.WorkoutComparison
+	_ = json.Unmarshal(rr.Body.Bytes(), &comparison)
+	if len(comparison.Exercises) != 1 {
+		t.Fatalf("expected one exercise, got %+v", comparison.Exercises)
+	}
+	ex := comparison.Exercises[0]
+	if ex.SetsDiff != -1 || ex.CompletedPct != 66.7 || ex.Actual.AverageRPE == nil || *ex.Actual.AverageRPE != 8 {
+		t.Fatalf("unexpected comparison: %+v", ex)
+	}
+
+	// Completing the session completes the workout; skipping it is then refused
+	rr = doRequest(t, h, http.MethodPost, sessionPath+"/complete", nil, headers)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("complete session expected 200, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := doRequest(t, h, http.MethodPost, sessionPath+"/complete", nil, headers); rr.Code != http.StatusConflict {
+		t.Fatalf("second complete expected 409, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodPost, workoutPath+"/skip", nil, headers); rr.Code != http.StatusConflict {
+		t.Fatalf("skip after complete expected 409, got %d", rr.Code)
+	}
+
+	// Stats use the logged sets, not the plan
+	rr = doRequest(t, h, http.MethodGet, "/api/v1/stats/progression?exercise_id=1", nil, headers)
+	var progression models.ProgressionResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &progression)
+	if len(progression.Points) != 1 || progression.Points[0].Sets != 2 || progression.Points[0].Volume != 1000 {
+		t.Fatalf("expected progression from logged sets, got %+v", progression.Points)
+	}
+
+	// Skipping another workout without a session counts as missed
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts", create, headers); rr.Code != http.StatusCreated {
+		t.Fatalf("create workout expected 201, got %d", rr.Code)
+	}
+	if err := db.QueryRow("SELECT id FROM workouts ORDER BY id DESC LIMIT 1").Scan(&wid); err != nil {
+		t.Fatalf("get workout id: %v", err)
+	}
+	rr = doRequest(t, h, http.MethodPost, "/api/v1/workouts/"+intToPath(wid)+"/skip", map[string]string{"notes": "sick"}, headers)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("skip expected 200, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	rr = doRequest(t, h, http.MethodGet, "/api/v1/stats/adherence", nil, headers)
+	var adherence models.AdherenceResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &adherence)
+	if adherence.Completed != 1 || adherence.Skipped != 1 || adherence.Missed != 1 {
+		t.Fatalf("unexpected adherence: %+v", adherence)
+	}
+
+	// Deleting the workout removes its session
+	if rr := doRequest(t, h, http.MethodDelete, workoutPath, nil, headers); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete workout expected 204, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodGet, sessionPath, nil, headers); rr.Code != http.StatusNotFound {
+		t.Fatalf("session of deleted workout expected 404, got %d", rr.Code)
+	}
+}
+
+func TestTemplatesAndPrograms(t *testing.T) {
+	db, h, _ := setupTestServer(t)
+	token := registerAndLogin(t, h)
+	headers := map[string]string{"Authorization": "Bearer " + token}
+
+	// Template: 3 x 5 bench at 100
+	create := models.CreateTemplateRequest{
+		Name:      "Push",
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: 1, Sets: 3, Reps: 5, Weight: 100}},
+	}
+	rr := doRequest(t, h, http.MethodPost, "/api/v1/templates", create, headers)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create template expected 201, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var template models.TemplateResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &template)
+	templatePath := "/api/v1/templates/" + intToPath(template.ID)
+
+	// Clone it and schedule the clone once
+	rr = doRequest(t, h, http.MethodPost, templatePath+"/clone", nil, headers)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("clone expected 201, got %d", rr.Code)
+	}
+	var clone models.TemplateResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &clone)
+	if clone.ID == template.ID || clone.Name != "Copy of Push" || len(clone.Exercises) != 1 {
+		t.Fatalf("unexpected clone: %+v", clone)
+	}
+	schedule := models.ScheduleTemplateRequest{ScheduledFor: time.Now().Add(24 * time.Hour).UTC()}
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/templates/"+intToPath(clone.ID)+"/workouts", schedule, headers); rr.Code != http.StatusCreated {
+		t.Fatalf("schedule template expected 201, got %d", rr.Code)
+	}
+
+	// Four weekly sessions, +2.5kg per week, the fourth week is a deload
+	program := models.CreateProgramRequest{
+		Name:        "Bench block",
+		Weeks:       4,
+		Progression: models.ProgressionRule{WeightIncrement: 2.5, DeloadEvery: 4},
+		Schedule:    []models.ProgramSessionRequest{{TemplateID: template.ID, Recurrence: "FREQ=WEEKLY"}},
+	}
+	bad := program
+	bad.Schedule = []models.ProgramSessionRequest{{TemplateID: template.ID, Recurrence: "FREQ=MONTHLY"}}
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/programs", bad, headers); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid recurrence expected 422, got %d", rr.Code)
+	}
+	rr = doRequest(t, h, http.MethodPost, "/api/v1/programs", program, headers)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create program expected 201, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var created models.ProgramResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	programPath := "/api/v1/programs/" + intToPath(created.ID)
+
+	// A template used by a program cannot be deleted
+	if rr := doRequest(t, h, http.MethodDelete, templatePath, nil, headers); rr.Code != http.StatusConflict {
+		t.Fatalf("delete used template expected 409, got %d", rr.Code)
+	}
+
+	// Started two weeks ago: weeks 1 and 2 are already past
+	start := time.Now().UTC().AddDate(0, 0, -14).Format("2006-01-02")
+	rr = doRequest(t, h, http.MethodPost, programPath+"/apply", models.ApplyProgramRequest{StartDate: start}, headers)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("apply expected 201, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var run models.ProgramRun
+	_ = json.Unmarshal(rr.Body.Bytes(), &run)
+	if run.Workouts != 4 {
+		t.Fatalf("expected 4 generated workouts, got %+v", run)
+	}
+
+	rows, err := db.Query(`
+		SELECT w.id, w.name, we.weight FROM workouts w JOIN workout_exercises we ON we.workout_id = w.id
+		WHERE w.program_run_id = ? ORDER BY w.scheduled_for`, run.ID)
+	if err != nil {
+		t.Fatalf("query generated workouts: %v", err)
+	}
+	var ids []int
+	var names []string
+	var weights []float64
+	for rows.Next() {
+		var id int
+		var name string
+		var weight float64
+		if err := rows.Scan(&id, &name, &weight); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		ids, names, weights = append(ids, id), append(names, name), append(weights, weight)
+	}
+	rows.Close()
+	if names[0] != "Push - Week 1" || weights[0] != 100 || weights[1] != 102.5 || weights[2] != 105 || weights[3] != 96.75 {
+		t.Fatalf("unexpected progression: %v %v", names, weights)
+	}
+
+	// Week 1 was done; week 2 was missed and moves to today with the rest
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts/"+intToPath(ids[0])+"/complete", nil, headers); rr.Code != http.StatusOK {
+		t.Fatalf("complete expected 200, got %d", rr.Code)
+	}
+	reschedulePath := programPath + "/runs/" + intToPath(run.ID) + "/reschedule"
+	rr = doRequest(t, h, http.MethodPost, reschedulePath, nil, headers)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("reschedule expected 200, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var moved models.RescheduleResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &moved)
+	if moved.Shifted != 3 || moved.Days != 7 {
+		t.Fatalf("unexpected reschedule: %+v", moved)
+	}
+	var scheduled time.Time
+	if err := db.QueryRow("SELECT scheduled_for FROM workouts WHERE id = ?", ids[1]).Scan(&scheduled); err != nil {
+		t.Fatalf("get rescheduled workout: %v", err)
+	}
+	if scheduled.UTC().Format("2006-01-02") != time.Now().UTC().Format("2006-01-02") {
+		t.Fatalf("missed workout should move to today, got %v", scheduled)
+	}
+
+	// Nothing is missed any more
+	rr = doRequest(t, h, http.MethodPost, reschedulePath, nil, headers)
+	_ = json.Unmarshal(rr.Body.Bytes(), &moved)
+	if moved.Shifted != 0 {
+		t.Fatalf("second reschedule should move nothing, got %+v", moved)
+	}
+
+	// The run shows up on the program; deleting it keeps the workouts
+	rr = doRequest(t, h, http.MethodGet, programPath, nil, headers)
+	var got models.ProgramResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &got)
+	if len(got.Runs) != 1 || got.Runs[0].Workouts != 4 || got.Runs[0].Completed != 1 {
+		t.Fatalf("unexpected runs: %+v", got.Runs)
+	}
+	if rr := doRequest(t, h, http.MethodDelete, programPath, nil, headers); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete program expected 204, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodGet, "/api/v1/workouts/"+intToPath(ids[3]), nil, headers); rr.Code != http.StatusOK {
+		t.Fatalf("generated workout should survive its program, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodDelete, templatePath, nil, headers); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete template expected 204, got %d", rr.Code)
+	}
+}
+
+func TestExerciseCatalog(t *testing.T) {
+	db, h, _ := setupTestServer(t)
+	alice := map[string]string{"Authorization": "Bearer " + registerAndLogin(t, h)}
+	bob := map[string]string{"Authorization": "Bearer " + registerAndLoginAs(t, h, "bob", "bob@example.com")}
+	admin := map[string]string{"Authorization": "Bearer " + registerAndLoginAs(t, h, "admin", "admin@example.com")}
+	if _, err := db.Exec(`UPDATE users SET is_admin = 1 WHERE email = 'admin@example.com'`); err != nil {
+		t.Fatalf("flag admin: %v", err)
+	}
+
+	list := func(headers map[string]string, query string) ([]models.Exercise, string) {
+		t.Helper()
+		rr := doRequest(t, h, http.MethodGet, "/api/v1/exercises"+query, nil, headers)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("list %q expected 200, got %d, body=%s", query, rr.Code, rr.Body.String())
+		}
+		var exercises []models.Exercise
+		_ = json.Unmarshal(rr.Body.Bytes(), &exercises)
+		return exercises, rr.Header().Get("X-Total-Count")
+	}
+
+	// Filters match combined muscle groups; pages report the total
+	if got, total := list(alice, "?category=strength&muscle_group=legs"); len(got) != 2 || total != "2" {
+		t.Fatalf("expected Deadlift and Squat, got %+v (total %s)", got, total)
+	}
+	if got, total := list(alice, "?page=2&page_size=3"); len(got) != 3 || total != "10" {
+		t.Fatalf("expected page 2 of the seeded catalog, got %d (total %s)", len(got), total)
+	}
+	if rr := doRequest(t, h, http.MethodGet, "/api/v1/exercises?page_size=1000", nil, alice); rr.Code != http.StatusBadRequest {
+		t.Fatalf("oversized page expected 400, got %d", rr.Code)
+	}
+
+	// Custom exercises are private and cannot shadow catalog names
+	custom := models.ExerciseRequest{Name: "Bulgarian Split Squat", Category: "Strength", MuscleGroup: "Legs"}
+	rr := doRequest(t, h, http.MethodPost, "/api/v1/exercises", custom, alice)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create custom expected 201, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var split models.Exercise
+	_ = json.Unmarshal(rr.Body.Bytes(), &split)
+	if !split.Custom {
+		t.Fatalf("expected a custom exercise, got %+v", split)
+	}
+	dup := models.ExerciseRequest{Name: "squat", Category: "Strength", MuscleGroup: "Legs"}
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/exercises", dup, alice); rr.Code != http.StatusConflict {
+		t.Fatalf("duplicate name expected 409, got %d", rr.Code)
+	}
+	if got, _ := list(alice, "?scope=custom"); len(got) != 1 {
+		t.Fatalf("expected one custom exercise, got %+v", got)
+	}
+	splitPath := "/api/v1/exercises/" + intToPath(split.ID)
+	if rr := doRequest(t, h, http.MethodGet, splitPath, nil, bob); rr.Code != http.StatusNotFound {
+		t.Fatalf("other user's exercise expected 404, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodGet, "/api/v1/stats/progression?exercise_id="+intToPath(split.ID), nil, bob); rr.Code != http.StatusNotFound {
+		t.Fatalf("progression of other user's exercise expected 404, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodGet, "/api/v1/stats/progression?exercise_id="+intToPath(split.ID), nil, alice); rr.Code != http.StatusOK {
+		t.Fatalf("progression of own exercise expected 200, got %d", rr.Code)
+	}
+	plan := models.CreateWorkoutRequest{
+		Name:         "Legs",
+		ScheduledFor: time.Now().UTC(),
+		Exercises:    []models.WorkoutExerciseRequest{{ExerciseID: split.ID, Sets: 3, Reps: 8, Weight: 20}},
+	}
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts", plan, bob); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("workout with other user's exercise expected 422, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts", plan, alice); rr.Code != http.StatusCreated {
+		t.Fatalf("workout with own exercise expected 201, got %d", rr.Code)
+	}
+
+	// The catalog is read-only for users
+	if rr := doRequest(t, h, http.MethodPut, "/api/v1/exercises/6", dup, alice); rr.Code != http.StatusForbidden {
+		t.Fatalf("user editing catalog expected 403, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/admin/exercises", custom, alice); rr.Code != http.StatusForbidden {
+		t.Fatalf("user on admin route expected 403, got %d", rr.Code)
+	}
+
+	// Emails are case-insensitive, so nobody can register the admin's address
+	// in other case; the admin logs in with any case
+	impostor := map[string]string{"username": "boss", "email": " ADMIN@example.com", "password": "Secret123!"}
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/auth/register", impostor, nil); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("email differing in case expected 422, got %d", rr.Code)
+	}
+	login := map[string]string{"email": "Admin@Example.com", "password": "Secret123!"}
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/auth/login", login, nil); rr.Code != http.StatusOK {
+		t.Fatalf("login with email in other case expected 200, got %d", rr.Code)
+	}
+
+	// Admin adds a duplicate that gets used, then merges it into Squat
+	rr = doRequest(t, h, http.MethodPost, "/api/v1/admin/exercises", models.ExerciseRequest{Name: "Back Squat", Category: "Strength", MuscleGroup: "Legs"}, admin)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("admin create expected 201, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var backSquat models.Exercise
+	_ = json.Unmarshal(rr.Body.Bytes(), &backSquat)
+	plan.Exercises[0].ExerciseID = backSquat.ID
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts", plan, bob); rr.Code != http.StatusCreated {
+		t.Fatalf("workout with catalog exercise expected 201, got %d", rr.Code)
+	}
+	backSquatPath := "/api/v1/admin/exercises/" + intToPath(backSquat.ID)
+	if rr := doRequest(t, h, http.MethodDelete, backSquatPath, nil, admin); rr.Code != http.StatusConflict {
+		t.Fatalf("deleting a used exercise expected 409, got %d", rr.Code)
+	}
+	rr = doRequest(t, h, http.MethodPost, backSquatPath+"/merge", models.MergeExerciseRequest{IntoID: 6}, admin)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("admin merge expected 200, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var moved int
+	if err := db.QueryRow("SELECT COUNT(*) FROM workout_exercises WHERE exercise_id = 6").Scan(&moved); err != nil || moved != 1 {
+		t.Fatalf("expected bob's history on Squat, got %d (%v)", moved, err)
+	}
+	if got, _ := list(bob, "?q=back%20squat"); len(got) != 1 || got[0].ID != 6 {
+		t.Fatalf("old name should find Squat through its alias, got %+v", got)
+	}
+
+	// Alice folds her custom exercise into Squat too; the alias stays hers
+	if rr := doRequest(t, h, http.MethodPost, splitPath+"/merge", models.MergeExerciseRequest{IntoID: 6}, alice); rr.Code != http.StatusOK {
+		t.Fatalf("merge custom expected 200, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	if got, _ := list(alice, "?q=bulgarian"); len(got) != 1 || got[0].ID != 6 {
+		t.Fatalf("alice should find Squat by her alias, got %+v", got)
+	}
+	if got, _ := list(bob, "?q=bulgarian"); len(got) != 0 {
+		t.Fatalf("bob should not see alice's alias, got %+v", got)
+	}
+	if rr := doRequest(t, h, http.MethodGet, splitPath, nil, alice); rr.Code != http.StatusNotFound {
+		t.Fatalf("merged exercise expected 404, got %d", rr.Code)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	db, h, _ := setupTestServer(t)
+	alice := map[string]string{"Authorization": "Bearer " + registerAndLogin(t, h)}
+	bob := map[string]string{"Authorization": "Bearer " + registerAndLoginAs(t, h, "bob", "bob@example.com")}
+
+	upload := func(headers map[string]string, query, contentType, body string) models.ImportResult {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/import"+query, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("import expected 200, got %d, body=%s", rr.Code, rr.Body.String())
+		}
+		var result models.ImportResult
+		_ = json.Unmarshal(rr.Body.Bytes(), &result)
+		return result
+	}
+
+	strong := "Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE\n" +
+		"2024-03-04 07:30:00,Leg Day,45m,Squat (Barbell),1,100,5,0,0,,Felt strong,8\n" +
+		"2024-03-04 07:30:00,Leg Day,45m,Squat (Barbell),2,110,3,0,0,,Felt strong,\n" +
+		"2024-03-04 07:30:00,Leg Day,45m,Bench Press (Barbell),1,60,8,0,0,,Felt strong,\n" +
+		"2024-03-04 07:30:00,Leg Day,45m,Zercher Carry,1,40,10,0,0,,Felt strong,\n" +
+		"2024-03-04 07:30:00,Leg Day,45m,Running,1,0,0,5,1500,,Felt strong,\n"
+	result := upload(alice, "?timezone=Asia/Tokyo", "text/csv", strong)
+	if result.Format != models.FormatStrong || result.Workouts != 1 || result.Sets != 3 || result.Planned != 2 {
+		t.Fatalf("unexpected strong import: %+v", result)
+	}
+	if len(result.Unmatched) != 1 || result.Unmatched[0].Row != 5 || len(result.UnmatchedExercises) != 1 || result.UnmatchedExercises[0] != "Zercher Carry" {
+		t.Fatalf("expected the carry row unmatched, got %+v", result)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Row != 6 {
+		t.Fatalf("expected the running row skipped, got %+v", result.Skipped)
+	}
+	if result := upload(alice, "?timezone=Asia/Tokyo", "text/csv", strong); result.Workouts != 0 || result.Duplicates != 1 {
+		t.Fatalf("re-import should be a duplicate, got %+v", result)
+	}
+
+	hevy := `"title","start_time","end_time","description","exercise_title","superset_id","exercise_notes","set_index","set_type","weight_kg","reps","distance_km","duration_seconds","rpe"` + "\n" +
+		`"Push","5 Mar 2024, 18:00","5 Mar 2024, 19:00","","Bench Press (Barbell)",,"",0,"warmup",40,10,,,` + "\n" +
+		`"Push","5 Mar 2024, 18:00","5 Mar 2024, 19:00","","Bench Press (Barbell)",,"",1,"normal",70,6,,,9` + "\n"
+	if result := upload(alice, "?dry_run=true", "text/csv", hevy); result.Format != models.FormatHevy || !result.DryRun || result.Workouts != 1 || result.Sets != 2 {
+		t.Fatalf("unexpected hevy dry run: %+v", result)
+	}
+	var workouts int
+	if err := db.QueryRow("SELECT COUNT(*) FROM workouts").Scan(&workouts); err != nil || workouts != 1 {
+		t.Fatalf("dry run should not save, got %d workouts (%v)", workouts, err)
+	}
+
+	// The JSON export carries the plan, the session and the logged sets
+	rr := doRequest(t, h, http.MethodGet, "/api/v1/export", nil, alice)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Header().Get("Content-Disposition"), "workouts.json") {
+		t.Fatalf("export expected 200 with a file name, got %d %v", rr.Code, rr.Header())
+	}
+	exportJSON := rr.Body.String()
+	var data models.ExportData
+	_ = json.Unmarshal(rr.Body.Bytes(), &data)
+	if len(data.Workouts) != 1 {
+		t.Fatalf("expected 1 exported workout, got %+v", data)
+	}
+	wo := data.Workouts[0]
+	if wo.Name != "Leg Day" || wo.Description != "Felt strong" || wo.Status != models.SessionCompleted || wo.CompletedAt == nil ||
+		!wo.ScheduledFor.Equal(time.Date(2024, 3, 3, 22, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected exported workout: %+v", wo)
+	}
+	if len(wo.Exercises) != 2 || wo.Exercises[0].Exercise != "Squat" || wo.Exercises[0].Sets != 2 || wo.Exercises[0].Weight != 110 {
+		t.Fatalf("plan should mirror the heaviest logged set, got %+v", wo.Exercises)
+	}
+	if len(wo.Sets) != 3 || wo.Sets[1].SetNumber != 2 || wo.Sets[0].RPE == nil || *wo.Sets[0].RPE != 8 {
+		t.Fatalf("unexpected exported sets: %+v", wo.Sets)
+	}
+
+	rr = doRequest(t, h, http.MethodGet, "/api/v1/export?format=csv", nil, alice)
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv export expected text/csv, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := doRequest(t, h, http.MethodGet, "/api/v1/export?format=xml", nil, alice); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown export format expected 400, got %d", rr.Code)
+	}
+
+	// Bob imports alice's CSV export, then the JSON export of the same workout
+	result = upload(bob, "", "text/csv", rr.Body.String())
+	if result.Format != models.FormatTracker || result.Workouts != 1 || result.Planned != 2 || result.Sets != 3 || len(result.Skipped) != 0 {
+		t.Fatalf("unexpected tracker csv import: %+v", result)
+	}
+	if result := upload(bob, "", "application/json", exportJSON); result.Duplicates != 1 {
+		t.Fatalf("json import of the same workout should be a duplicate, got %+v", result)
+	}
+	var sets int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM session_sets ss JOIN workout_sessions s ON s.id = ss.session_id
+		WHERE s.user_id = (SELECT id FROM users WHERE username = 'bob') AND s.status = 'completed'`).Scan(&sets)
+	if err != nil || sets != 3 {
+		t.Fatalf("expected bob's 3 logged sets, got %d (%v)", sets, err)
+	}
+
+	// A completed session completes its workout even without completed_at
+	completed := `{"version": 1, "workouts": [{"name": "Pull", "scheduled_for": "2024-03-06T07:00:00Z", "status": "completed",
+		"exercises": [{"exercise": "Deadlift", "sets": 1, "reps": 5, "weight": 120}], "sets": []}]}`
+	if result := upload(bob, "", "application/json", completed); result.Workouts != 1 {
+		t.Fatalf("unexpected completed workout import: %+v", result)
+	}
+	var completedAt sql.NullTime
+	err = db.QueryRow(`SELECT completed_at FROM workouts WHERE name = 'Pull'`).Scan(&completedAt)
+	if err != nil || !completedAt.Valid || !completedAt.Time.Equal(time.Date(2024, 3, 6, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the workout completed at its session's finish, got %+v (%v)", completedAt, err)
+	}
+
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/import?timezone=Mars/Olympus", nil, bob); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unknown timezone expected 422, got %d", rr.Code)
+	}
+
+	// Parser errors quote the input and are still valid JSON
+	for _, tc := range []struct{ query, body string }{
+		{"?format=" + url.QueryEscape(`my"format`), "a,b\n1,2\n"},
+		{"", "workout,name\n1,Push \"heavy\n"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/import"+tc.query, strings.NewReader(tc.body))
+		req.Header.Set("Authorization", bob["Authorization"])
+		req.Header.Set("Content-Type", "text/csv")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		var body struct{ Error string }
+		if rr.Code != http.StatusBadRequest || json.Unmarshal(rr.Body.Bytes(), &body) != nil || body.Error == "" {
+			t.Fatalf("%q: expected 400 with a JSON error, got %d: %s", tc.query, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestCalendarFeed(t *testing.T) {
+	db, h, _ := setupTestServer(t)
+	alice := map[string]string{"Authorization": "Bearer " + registerAndLogin(t, h)}
+
+	create := func(name string, at time.Time) int {
+		t.Helper()
+		req := models.CreateWorkoutRequest{
+			Name: name, Description: "Heavy; then rest", ScheduledFor: at,
+			Exercises: []models.WorkoutExerciseRequest{{ExerciseID: 1, Sets: 5, Reps: 5, Weight: 100, Notes: "pause reps"}},
+		}
+		if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts", req, alice); rr.Code != http.StatusCreated {
+			t.Fatalf("create workout expected 201, got %d, body=%s", rr.Code, rr.Body.String())
+		}
+		var id int
+		if err := db.QueryRow("SELECT id FROM workouts ORDER BY id DESC LIMIT 1").Scan(&id); err != nil {
+			t.Fatalf("get workout id: %v", err)
+		}
+		return id
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	upcoming := create("Leg Day", now.Add(48*time.Hour))
+	create("Old Day", now.AddDate(0, 0, -3))
+	skipped := create("Skipped Day", now.Add(72*time.Hour))
+	if rr := doRequest(t, h, http.MethodPost, "/api/v1/workouts/"+intToPath(skipped)+"/skip", nil, alice); rr.Code != http.StatusOK {
+		t.Fatalf("skip expected 200, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+
+	rr := doRequest(t, h, http.MethodGet, "/api/v1/calendar/token", nil, alice)
+	var feed models.CalendarTokenResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &feed)
+	if rr.Code != http.StatusOK || len(feed.Token) != 64 || !strings.HasSuffix(feed.FeedURL, "/api/v1/calendar/feed/"+feed.Token+".ics") {
+		t.Fatalf("unexpected token response %d: %+v", rr.Code, feed)
+	}
+	rr = doRequest(t, h, http.MethodGet, "/api/v1/calendar/token", nil, alice)
+	var again models.CalendarTokenResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &again)
+	if again.Token != feed.Token {
+		t.Fatalf("token should be stable, got %q then %q", feed.Token, again.Token)
+	}
+
+	path := "/api/v1/calendar/feed/" + feed.Token + ".ics"
+	rr = doRequest(t, h, http.MethodGet, path, nil, nil)
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/calendar") {
+		t.Fatalf("feed expected 200 text/calendar, got %d %v", rr.Code, rr.Header())
+	}
+	// Unfold long lines before looking for content
+	ics := strings.ReplaceAll(rr.Body.String(), "\r\n ", "")
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:workout-" + intToPath(upcoming) + "@fitness-workout-tracker\r\n",
+		"DTSTART:" + now.Add(48*time.Hour).Format("20060102T150405Z") + "\r\n",
+		"SUMMARY:Leg Day\r\n",
+		`DESCRIPTION:Heavy\; then rest\n\nBarbell Bench Press: 5 x 5 @ 100 kg (pause reps)`,
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("feed is missing %q:\n%s", want, ics)
+		}
+	}
+	if strings.Contains(ics, "Old Day") || strings.Contains(ics, "Skipped Day") {
+		t.Errorf("feed should only list open upcoming workouts:\n%s", ics)
+	}
+
+	// Regenerating revokes the old address
+	rr = doRequest(t, h, http.MethodPost, "/api/v1/calendar/token", nil, alice)
+	var fresh models.CalendarTokenResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &fresh)
+	if rr.Code != http.StatusOK || fresh.Token == feed.Token {
+		t.Fatalf("regenerate expected a new token, got %d %+v", rr.Code, fresh)
+	}
+	if rr := doRequest(t, h, http.MethodGet, path, nil, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("old feed expected 404, got %d", rr.Code)
+	}
+	freshPath := "/api/v1/calendar/feed/" + fresh.Token + ".ics"
+	if rr := doRequest(t, h, http.MethodGet, freshPath, nil, nil); rr.Code != http.StatusOK {
+		t.Fatalf("new feed expected 200, got %d", rr.Code)
+	}
+
+	if rr := doRequest(t, h, http.MethodDelete, "/api/v1/calendar/token", nil, alice); rr.Code != http.StatusNoContent {
+		t.Fatalf("disable expected 204, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodGet, freshPath, nil, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("disabled feed expected 404, got %d", rr.Code)
+	}
+	if rr := doRequest(t, h, http.MethodGet, "/api/v1/calendar/feed/.ics", nil, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("empty token expected 404, got %d", rr.Code)
+	}
+}
+
 func intToPath(id int) string {
 	// simple helper to avoid fmt import
 	digits := []byte{}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"net/http"
 	"strings"
 
@@ -55,3 +56,26 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// AdminMiddleware only lets admins through and must run after AuthMiddleware.
+// A user is an admin when users.is_admin is set.
+func AdminMiddleware(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(userIDKey).(int)
+			if !ok {
+				http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+
+			var isAdmin bool
+			err := db.QueryRow(`SELECT is_admin FROM users WHERE id = ?`, userID).Scan(&isAdmin)
+			if err != nil || !isAdmin {
+				http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
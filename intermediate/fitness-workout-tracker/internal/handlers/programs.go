@@ -0,0 +1,631 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/models"
+)
+
+// defaultSessionTime is used for program sessions without a time of day
+const defaultSessionTime = "07:00"
+
+// loadProgram fetches a program of the user with its schedule and runs.
+// It returns sql.ErrNoRows when the program does not exist or belongs to someone else.
+func loadProgram(db *sql.DB, programID, userID int) (*models.ProgramResponse, error) {
+	var p models.ProgramResponse
+	err := db.QueryRow(`
+		SELECT id, name, description, weeks, weight_increment, reps_increment, deload_every, deload_percent
+		FROM programs WHERE id = ? AND user_id = ?`,
+		programID, userID,
+	).Scan(&p.ID, &p.Name, &p.Description, &p.Weeks,
+		&p.Progression.WeightIncrement, &p.Progression.RepsIncrement, &p.Progression.DeloadEvery, &p.Progression.DeloadPercent)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT ps.id, ps.template_id, t.name, ps.recurrence, ps.time_of_day
+		FROM program_sessions ps
+		JOIN workout_templates t ON ps.template_id = t.id
+		WHERE ps.program_id = ?
+		ORDER BY ps.id ASC`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p.Schedule = []models.ProgramSession{}
+	for rows.Next() {
+		var s models.ProgramSession
+		if err := rows.Scan(&s.ID, &s.TemplateID, &s.TemplateName, &s.Recurrence, &s.Time); err != nil {
+			return nil, err
+		}
+		p.Schedule = append(p.Schedule, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	runRows, err := db.Query(`
+		SELECT r.id, r.program_id, r.start_date, r.timezone, r.created_at,
+		       COUNT(w.id), COUNT(w.completed_at)
+		FROM program_runs r
+		LEFT JOIN workouts w ON w.program_run_id = r.id
+		WHERE r.program_id = ?
+		GROUP BY r.id
+		ORDER BY r.id ASC`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer runRows.Close()
+
+	p.Runs = []models.ProgramRun{}
+	for runRows.Next() {
+		var run models.ProgramRun
+		if err := runRows.Scan(&run.ID, &run.ProgramID, &run.StartDate, &run.Timezone, &run.CreatedAt, &run.Workouts, &run.Completed); err != nil {
+			return nil, err
+		}
+		p.Runs = append(p.Runs, run)
+	}
+	return &p, runRows.Err()
+}
+
+// programWorkout is a workout generated by applying a program
+type programWorkout struct {
+	name         string
+	description  string
+	scheduledFor time.Time
+	exercises    []models.WorkoutExerciseRequest
+}
+
+// generateProgramWorkouts expands the schedule of a program from start, a
+// midnight in the run's location, applying the progression week by week.
+// Workouts come out in chronological order.
+func generateProgramWorkouts(p *models.ProgramResponse, templates map[int]*models.TemplateResponse, start time.Time) ([]programWorkout, error) {
+	type session struct {
+		rule     models.RecurrenceRule
+		template *models.TemplateResponse
+		hour     int
+		minute   int
+	}
+	sessions := make([]session, 0, len(p.Schedule))
+	for _, s := range p.Schedule {
+		rule, err := models.ParseRecurrenceRule(s.Recurrence)
+		if err != nil {
+			return nil, err
+		}
+		at, err := time.Parse("15:04", s.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q", s.Time)
+		}
+		sessions = append(sessions, session{rule: rule, template: templates[s.TemplateID], hour: at.Hour(), minute: at.Minute()})
+	}
+
+	var workouts []programWorkout
+	for day := 0; day < p.Weeks*7; day++ {
+		date := start.AddDate(0, 0, day)
+		week := day / 7
+		var today []programWorkout
+		for _, s := range sessions {
+			if !s.rule.Matches(start, date) {
+				continue
+			}
+			exercises := make([]models.WorkoutExerciseRequest, 0, len(s.template.Exercises))
+			for _, ex := range templateExercises(s.template) {
+				exercises = append(exercises, p.Progression.Apply(week, ex))
+			}
+			today = append(today, programWorkout{
+				name:         fmt.Sprintf("%s - Week %d", s.template.Name, week+1),
+				description:  s.template.Description,
+				scheduledFor: time.Date(date.Year(), date.Month(), date.Day(), s.hour, s.minute, 0, 0, start.Location()).UTC(),
+				exercises:    exercises,
+			})
+		}
+		// Sessions on the same day in time order
+		for i := 1; i < len(today); i++ {
+			for j := i; j > 0 && today[j].scheduledFor.Before(today[j-1].scheduledFor); j-- {
+				today[j], today[j-1] = today[j-1], today[j]
+			}
+		}
+		workouts = append(workouts, today...)
+	}
+	return workouts, nil
+}
+
+// CreateProgram godoc
+//
+//	@ID				createProgram
+//	@Summary		Create a training program
+//	@Description	Create a multi-week program that schedules templates with recurrence rules
+//	@Description	(FREQ=DAILY or FREQ=WEEKLY with optional INTERVAL and BYDAY) and applies
+//	@Description	progressive overload week by week, e.g. +2.5kg per week with a deload every 4th week.
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			createProgramRequest	body		models.CreateProgramRequest	true	"Program"
+//	@Success		201	{object}	models.ProgramResponse	"Program created"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		422	{object}	map[string]string		"Validation errors"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/programs [post]
+func CreateProgram(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		var req models.CreateProgramRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusUnprocessableEntity)
+			return
+		}
+
+		// Only the user's own templates can be scheduled
+		for _, s := range req.Schedule {
+			var exists bool
+			if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM workout_templates WHERE id = ? AND user_id = ?)`, s.TemplateID, userID).Scan(&exists); err != nil {
+				http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+				return
+			}
+			if !exists {
+				http.Error(w, fmt.Sprintf(`{"error": "Template %d not found"}`, s.TemplateID), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		p := req.Progression
+		res, err := tx.Exec(`
+			INSERT INTO programs (user_id, name, description, weeks, weight_increment, reps_increment, deload_every, deload_percent)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			userID, req.Name, req.Description, req.Weeks, p.WeightIncrement, p.RepsIncrement, p.DeloadEvery, p.DeloadPercent)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to create program"}`, http.StatusInternalServerError)
+			return
+		}
+		programID, _ := res.LastInsertId()
+
+		for _, s := range req.Schedule {
+			at := s.Time
+			if at == "" {
+				at = defaultSessionTime
+			}
+			_, err := tx.Exec(`INSERT INTO program_sessions (program_id, template_id, recurrence, time_of_day) VALUES (?, ?, ?, ?)`,
+				programID, s.TemplateID, s.Recurrence, at)
+			if err != nil {
+				http.Error(w, `{"error": "Failed to add schedule"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to save program"}`, http.StatusInternalServerError)
+			return
+		}
+
+		program, err := loadProgram(db, int(programID), userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to load program"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(program)
+	}
+}
+
+// ListPrograms godoc
+//
+//	@ID				listPrograms
+//	@Summary		List training programs
+//	@Description	Retrieve the programs of the authenticated user with their schedules and runs
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		models.ProgramResponse	"List of programs"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/programs [get]
+func ListPrograms(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		rows, err := db.Query(`SELECT id FROM programs WHERE user_id = ? ORDER BY id ASC`, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to fetch programs"}`, http.StatusInternalServerError)
+			return
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				http.Error(w, `{"error": "Error scanning programs"}`, http.StatusInternalServerError)
+				return
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		programs := []*models.ProgramResponse{}
+		for _, id := range ids {
+			p, err := loadProgram(db, id, userID)
+			if err != nil {
+				http.Error(w, `{"error": "Failed to fetch programs"}`, http.StatusInternalServerError)
+				return
+			}
+			programs = append(programs, p)
+		}
+
+		json.NewEncoder(w).Encode(programs)
+	}
+}
+
+// GetProgram godoc
+//
+//	@ID				getProgram
+//	@Summary		Get a training program
+//	@Description	Retrieve a program of the authenticated user with its schedule and runs
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int						true	"Program ID"
+//	@Success		200	{object}	models.ProgramResponse	"Program"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Program not found"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/programs/{id} [get]
+func GetProgram(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		programID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid program ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		program, err := loadProgram(db, programID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Program not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(program)
+	}
+}
+
+// DeleteProgram godoc
+//
+//	@ID				deleteProgram
+//	@Summary		Delete a training program
+//	@Description	Delete a program and its runs. Workouts it generated are kept as regular workouts.
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int					true	"Program ID"
+//	@Success		204	{string}	string				"Program deleted"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		404	{object}	map[string]string	"Program not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/programs/{id} [delete]
+func DeleteProgram(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		programID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid program ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`DELETE FROM programs WHERE id = ? AND user_id = ?`, programID, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to delete program"}`, http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, `{"error": "Program not found"}`, http.StatusNotFound)
+			return
+		}
+
+		// Detach the generated workouts, then remove the children
+		_, err = tx.Exec(`UPDATE workouts SET program_run_id = NULL WHERE program_run_id IN (SELECT id FROM program_runs WHERE program_id = ?)`, programID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to delete child records"}`, http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(`DELETE FROM program_runs WHERE program_id = ?`, programID); err != nil {
+			http.Error(w, `{"error": "Failed to delete child records"}`, http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(`DELETE FROM program_sessions WHERE program_id = ?`, programID); err != nil {
+			http.Error(w, `{"error": "Failed to delete child records"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to delete"}`, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ApplyProgram godoc
+//
+//	@ID				applyProgram
+//	@Summary		Apply a program to a start date
+//	@Description	Generate the scheduled workouts of a program from a start date. Weeks are counted
+//	@Description	from the start date; session times are in the given timezone (default UTC).
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id						path		int							true	"Program ID"
+//	@Param			applyProgramRequest		body		models.ApplyProgramRequest	true	"Start date"
+//	@Success		201	{object}	models.ProgramRun	"Program run with its generated workouts counted"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		404	{object}	map[string]string	"Program not found"
+//	@Failure		422	{object}	map[string]string	"Validation errors"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/programs/{id}/apply [post]
+func ApplyProgram(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		programID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid program ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		var req models.ApplyProgramRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		if req.Timezone == "" {
+			req.Timezone = "UTC"
+		}
+		loc, err := time.LoadLocation(req.Timezone)
+		if err != nil {
+			http.Error(w, `{"error": "unknown timezone"}`, http.StatusUnprocessableEntity)
+			return
+		}
+		start, err := time.ParseInLocation("2006-01-02", req.StartDate, loc)
+		if err != nil {
+			http.Error(w, `{"error": "start_date is required (YYYY-MM-DD)"}`, http.StatusUnprocessableEntity)
+			return
+		}
+
+		program, err := loadProgram(db, programID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Program not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		templates := make(map[int]*models.TemplateResponse)
+		for _, s := range program.Schedule {
+			if _, ok := templates[s.TemplateID]; ok {
+				continue
+			}
+			t, err := loadTemplate(db, s.TemplateID, userID)
+			if err != nil {
+				http.Error(w, `{"error": "Failed to load template"}`, http.StatusInternalServerError)
+				return
+			}
+			templates[s.TemplateID] = t
+		}
+
+		workouts, err := generateProgramWorkouts(program, templates, start)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusUnprocessableEntity)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`INSERT INTO program_runs (program_id, user_id, start_date, timezone) VALUES (?, ?, ?, ?)`,
+			programID, userID, req.StartDate, req.Timezone)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to apply program"}`, http.StatusInternalServerError)
+			return
+		}
+		runID, _ := res.LastInsertId()
+
+		for _, wo := range workouts {
+			if _, err := insertWorkout(tx, userID, wo.name, wo.description, wo.scheduledFor, &runID, wo.exercises); err != nil {
+				log.Printf("Error applying program: %v", err)
+				http.Error(w, `{"error": "Failed to create workouts"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to apply program"}`, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.ProgramRun{
+			ID:        int(runID),
+			ProgramID: programID,
+			StartDate: req.StartDate,
+			Timezone:  req.Timezone,
+			Workouts:  len(workouts),
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+}
+
+// RescheduleProgramRun godoc
+//
+//	@ID				rescheduleProgramRun
+//	@Summary		Reschedule missed sessions of a program run
+//	@Description	Push back the rest of a program run so that its earliest missed workout (in the past,
+//	@Description	not completed and not skipped) falls on the given date, default today. Every later
+//	@Description	open workout of the run moves by the same number of days, keeping the spacing.
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id					path		int							true	"Program ID"
+//	@Param			runId				path		int							true	"Run ID"
+//	@Param			rescheduleRequest	body		models.RescheduleRequest	false	"New date of the first missed workout"
+//	@Success		200	{object}	models.RescheduleResponse	"Workouts moved"
+//	@Failure		400	{object}	map[string]string			"Invalid request"
+//	@Failure		401	{object}	map[string]string			"Unauthorized"
+//	@Failure		404	{object}	map[string]string			"Program run not found"
+//	@Failure		422	{object}	map[string]string			"Validation errors"
+//	@Failure		500	{object}	map[string]string			"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/programs/{id}/runs/{runId}/reschedule [post]
+func RescheduleProgramRun(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		programID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid program ID"}`, http.StatusBadRequest)
+			return
+		}
+		runID, err := strconv.Atoi(r.PathValue("runId"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid run ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		// The body is optional
+		var req models.RescheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+
+		var timezone string
+		err = db.QueryRow(`SELECT timezone FROM program_runs WHERE id = ? AND program_id = ? AND user_id = ?`,
+			runID, programID, userID).Scan(&timezone)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Program run not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+
+		now := time.Now().In(loc)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		from := today
+		if req.From != "" {
+			if from, err = time.ParseInLocation("2006-01-02", req.From, loc); err != nil {
+				http.Error(w, `{"error": "invalid from date (YYYY-MM-DD)"}`, http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		// Open workouts of the run: neither completed nor skipped
+		rows, err := db.Query(`
+			SELECT w.id, w.scheduled_for
+			FROM workouts w
+			LEFT JOIN workout_sessions s ON s.workout_id = w.id
+			WHERE w.program_run_id = ? AND w.user_id = ? AND w.completed_at IS NULL
+			  AND (s.status IS NULL OR s.status = ?)
+			ORDER BY w.scheduled_for ASC`,
+			runID, userID, models.SessionInProgress)
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		type openWorkout struct {
+			id           int
+			scheduledFor time.Time
+		}
+		var open []openWorkout
+		for rows.Next() {
+			var o openWorkout
+			if err := rows.Scan(&o.id, &o.scheduledFor); err != nil {
+				rows.Close()
+				http.Error(w, `{"error": "Scan error"}`, http.StatusInternalServerError)
+				return
+			}
+			open = append(open, o)
+		}
+		rows.Close()
+
+		// The earliest workout scheduled before today was missed
+		if len(open) == 0 || !open[0].scheduledFor.In(loc).Before(today) {
+			json.NewEncoder(w).Encode(models.RescheduleResponse{})
+			return
+		}
+		missed := open[0].scheduledFor.In(loc)
+		missedDay := time.Date(missed.Year(), missed.Month(), missed.Day(), 0, 0, 0, 0, loc)
+		days := int(from.Sub(missedDay).Hours()/24 + 0.5)
+		if days <= 0 {
+			http.Error(w, `{"error": "from must be after the first missed workout"}`, http.StatusUnprocessableEntity)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		for _, o := range open {
+			moved := o.scheduledFor.In(loc).AddDate(0, 0, days).UTC()
+			if _, err := tx.Exec(`UPDATE workouts SET scheduled_for = ?, updated_at = ? WHERE id = ?`, moved, time.Now(), o.id); err != nil {
+				http.Error(w, `{"error": "Failed to reschedule workouts"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to reschedule workouts"}`, http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(models.RescheduleResponse{Shifted: len(open), Days: days})
+	}
+}
@@ -50,8 +50,25 @@ func SetupRoutes(db *sql.DB) http.Handler {
 
 	// attach auth middleware to all v1 routes
 	auth := AuthMiddleware(jwtSecret)
+	adminOnly := AdminMiddleware(db)
+
 	// exercise routes
 	v1.Handle("GET /exercises", auth(GetExercises(db)))
+	v1.Handle("POST /exercises", auth(CreateExercise(db)))
+	v1.Handle("GET /exercises/{id}", auth(GetExercise(db)))
+	v1.Handle("PUT /exercises/{id}", auth(UpdateExercise(db)))
+	v1.Handle("DELETE /exercises/{id}", auth(DeleteExercise(db)))
+	v1.Handle("POST /exercises/{id}/merge", auth(MergeExercise(db)))
+	v1.Handle("POST /exercises/{id}/aliases", auth(AddExerciseAlias(db)))
+	v1.Handle("DELETE /exercises/{id}/aliases/{aliasId}", auth(DeleteExerciseAlias(db)))
+
+	// admin catalog routes
+	v1.Handle("POST /admin/exercises", auth(adminOnly(AdminCreateExercise(db))))
+	v1.Handle("PUT /admin/exercises/{id}", auth(adminOnly(AdminUpdateExercise(db))))
+	v1.Handle("DELETE /admin/exercises/{id}", auth(adminOnly(AdminDeleteExercise(db))))
+	v1.Handle("POST /admin/exercises/{id}/merge", auth(adminOnly(AdminMergeExercise(db))))
+	v1.Handle("POST /admin/exercises/{id}/aliases", auth(adminOnly(AdminAddExerciseAlias(db))))
+	v1.Handle("DELETE /admin/exercises/{id}/aliases/{aliasId}", auth(adminOnly(AdminDeleteExerciseAlias(db))))
 
 	// workout routes
 	v1.Handle("POST /workouts", auth(CreateWorkout(db)))
@@ -59,9 +76,55 @@ func SetupRoutes(db *sql.DB) http.Handler {
 	v1.Handle("PUT /workouts/{id}", auth(UpdateWorkout(db)))
 	v1.Handle("GET /workouts/{id}", auth(GetWorkout(db)))
 	v1.Handle("DELETE /workouts/{id}", auth(DeleteWorkout(db)))
+	v1.Handle("POST /workouts/{id}/complete", auth(CompleteWorkout(db)))
+
+	// session routes
+	v1.Handle("POST /workouts/{id}/sessions", auth(StartSession(db)))
+	v1.Handle("POST /workouts/{id}/skip", auth(SkipWorkout(db)))
+	v1.Handle("GET /workouts/{id}/comparison", auth(GetWorkoutComparison(db)))
+	v1.Handle("GET /sessions/{id}", auth(GetSession(db)))
+	v1.Handle("POST /sessions/{id}/sets", auth(LogSet(db)))
+	v1.Handle("PUT /sessions/{id}/sets/{setId}", auth(UpdateSet(db)))
+	v1.Handle("DELETE /sessions/{id}/sets/{setId}", auth(DeleteSet(db)))
+	v1.Handle("POST /sessions/{id}/complete", auth(CompleteSession(db)))
+
+	// template routes
+	v1.Handle("POST /templates", auth(CreateTemplate(db)))
+	v1.Handle("GET /templates", auth(ListTemplates(db)))
+	v1.Handle("GET /templates/{id}", auth(GetTemplate(db)))
+	v1.Handle("PUT /templates/{id}", auth(UpdateTemplate(db)))
+	v1.Handle("DELETE /templates/{id}", auth(DeleteTemplate(db)))
+	v1.Handle("POST /templates/{id}/clone", auth(CloneTemplate(db)))
+	v1.Handle("POST /templates/{id}/workouts", auth(ScheduleTemplate(db)))
+
+	// program routes
+	v1.Handle("POST /programs", auth(CreateProgram(db)))
+	v1.Handle("GET /programs", auth(ListPrograms(db)))
+	v1.Handle("GET /programs/{id}", auth(GetProgram(db)))
+	v1.Handle("DELETE /programs/{id}", auth(DeleteProgram(db)))
+	v1.Handle("POST /programs/{id}/apply", auth(ApplyProgram(db)))
+	v1.Handle("POST /programs/{id}/runs/{runId}/reschedule", auth(RescheduleProgramRun(db)))
+
 	// report routes
 	v1.Handle("GET /workouts/reports", auth(GetWorkoutReport(db)))
 
+	// stats routes
+	v1.Handle("GET /stats/records", auth(GetRecords(db)))
+	v1.Handle("GET /stats/volume", auth(GetWeeklyVolume(db)))
+	v1.Handle("GET /stats/streaks", auth(GetStreaks(db)))
+	v1.Handle("GET /stats/adherence", auth(GetAdherence(db)))
+	v1.Handle("GET /stats/progression", auth(GetProgression(db)))
+
+	// export and import routes
+	v1.Handle("GET /export", auth(ExportWorkouts(db)))
+	v1.Handle("POST /import", auth(ImportWorkouts(db)))
+
+	// calendar routes; the feed is public and authenticated by its token
+	v1.Handle("GET /calendar/token", auth(GetCalendarToken(db)))
+	v1.Handle("POST /calendar/token", auth(RegenerateCalendarToken(db)))
+	v1.Handle("DELETE /calendar/token", auth(DeleteCalendarToken(db)))
+	v1.HandleFunc("GET /calendar/feed/{file}", CalendarFeed(db))
+
 	// Prefix all v1 routes with /v1
 	mux.Handle("/api/v1/", http.StripPrefix("/api/v1", v1))
 
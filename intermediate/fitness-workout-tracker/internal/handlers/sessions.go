@@ -0,0 +1,630 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/models"
+)
+
+// loadSession fetches a session of the user with its sets.
+// It returns sql.ErrNoRows when the session does not exist or belongs to someone else.
+func loadSession(db *sql.DB, sessionID, userID int) (*models.WorkoutSession, error) {
+	var s models.WorkoutSession
+	var finishedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT id, workout_id, status, started_at, finished_at, notes
+		FROM workout_sessions WHERE id = ? AND user_id = ?`,
+		sessionID, userID,
+	).Scan(&s.ID, &s.WorkoutID, &s.Status, &s.StartedAt, &finishedAt, &s.Notes)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		s.FinishedAt = &finishedAt.Time
+	}
+
+	rows, err := db.Query(`
+		SELECT ss.id, ss.exercise_id, e.name, ss.set_number, ss.reps, ss.weight, ss.rpe, ss.rest_seconds, ss.notes
+		FROM session_sets ss
+		JOIN exercises e ON ss.exercise_id = e.id
+		WHERE ss.session_id = ?
+		ORDER BY ss.id ASC`, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s.Sets = []models.SessionSet{}
+	for rows.Next() {
+		var set models.SessionSet
+		var rpe sql.NullFloat64
+		var rest sql.NullInt64
+		if err := rows.Scan(&set.ID, &set.ExerciseID, &set.ExerciseName, &set.SetNumber, &set.Reps, &set.Weight, &rpe, &rest, &set.Notes); err != nil {
+			return nil, err
+		}
+		if rpe.Valid {
+			set.RPE = &rpe.Float64
+		}
+		if rest.Valid {
+			n := int(rest.Int64)
+			set.RestSeconds = &n
+		}
+		s.Sets = append(s.Sets, set)
+	}
+	return &s, rows.Err()
+}
+
+// writeSession responds with the current state of a session
+func writeSession(w http.ResponseWriter, db *sql.DB, sessionID, userID, status int) {
+	session, err := loadSession(db, sessionID, userID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to load session"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(session)
+}
+
+// StartSession godoc
+//
+//	@ID				startSession
+//	@Summary		Start a workout session
+//	@Description	Start logging what is actually performed for a planned workout. A workout has at most one session.
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int						true	"Workout ID"
+//	@Success		201	{object}	models.WorkoutSession	"Session started"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Workout not found"
+//	@Failure		409	{object}	map[string]string		"Workout already has a session"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/workouts/{id}/sessions [post]
+func StartSession(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		workoutID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid workout ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM workouts WHERE id = ? AND user_id = ?)`, workoutID, userID).Scan(&exists); err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, `{"error": "Workout not found or unauthorized"}`, http.StatusNotFound)
+			return
+		}
+
+		res, err := db.Exec(`INSERT INTO workout_sessions (workout_id, user_id, status, started_at) VALUES (?, ?, ?, ?)`,
+			workoutID, userID, models.SessionInProgress, time.Now().UTC())
+		if err != nil {
+			// workout_id is unique
+			http.Error(w, `{"error": "Workout already has a session"}`, http.StatusConflict)
+			return
+		}
+		sessionID, _ := res.LastInsertId()
+
+		writeSession(w, db, int(sessionID), userID, http.StatusCreated)
+	}
+}
+
+// GetSession godoc
+//
+//	@ID				getSession
+//	@Summary		Get a workout session
+//	@Description	Retrieve a session of the authenticated user with its logged sets
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int						true	"Session ID"
+//	@Success		200	{object}	models.WorkoutSession	"Session"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Session not found"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/sessions/{id} [get]
+func GetSession(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid session ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		session, err := loadSession(db, sessionID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Session not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(session)
+	}
+}
+
+// editableSession loads a session that sets may be logged to: in progress,
+// or completed for corrections. It writes the error response and returns nil otherwise.
+func editableSession(w http.ResponseWriter, r *http.Request, db *sql.DB) *models.WorkoutSession {
+	sessionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Invalid session ID"}`, http.StatusBadRequest)
+		return nil
+	}
+	userID := r.Context().Value(userIDKey).(int)
+
+	session, err := loadSession(db, sessionID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, `{"error": "Session not found"}`, http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+		return nil
+	}
+	if session.Status == models.SessionSkipped {
+		http.Error(w, `{"error": "Session was skipped"}`, http.StatusConflict)
+		return nil
+	}
+	return session
+}
+
+// decodeSetRequest decodes and validates a LogSetRequest, checking that the exercise exists.
+// It writes the error response and returns false on failure.
+func decodeSetRequest(w http.ResponseWriter, r *http.Request, db *sql.DB) (models.LogSetRequest, bool) {
+	var req models.LogSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+		return req, false
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusUnprocessableEntity)
+		return req, false
+	}
+	userID := r.Context().Value(userIDKey).(int)
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM exercises e WHERE e.id = ? AND `+visibleExercise+`)`, req.ExerciseID, userID).Scan(&exists); err != nil {
+		http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+		return req, false
+	}
+	if !exists {
+		http.Error(w, `{"error": "Exercise not found"}`, http.StatusUnprocessableEntity)
+		return req, false
+	}
+	return req, true
+}
+
+// LogSet godoc
+//
+//	@ID				logSet
+//	@Summary		Log a set
+//	@Description	Record a set actually performed in a session. Sets are numbered per exercise.
+//	@Description	Completed sessions accept sets as corrections; skipped sessions do not.
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Session ID"
+//	@Param			logSetRequest	body		models.LogSetRequest	true	"Performed set"
+//	@Success		201	{object}	models.WorkoutSession	"Session with the new set"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Session not found"
+//	@Failure		409	{object}	map[string]string		"Session was skipped"
+//	@Failure		422	{object}	map[string]string		"Validation errors"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/sessions/{id}/sets [post]
+func LogSet(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := editableSession(w, r, db)
+		if session == nil {
+			return
+		}
+		req, ok := decodeSetRequest(w, r, db)
+		if !ok {
+			return
+		}
+
+		_, err := db.Exec(`
+			INSERT INTO session_sets (session_id, exercise_id, set_number, reps, weight, rpe, rest_seconds, notes)
+			VALUES (?, ?, (SELECT COALESCE(MAX(set_number), 0) + 1 FROM session_sets WHERE session_id = ? AND exercise_id = ?),
+			        ?, ?, ?, ?, ?)`,
+			session.ID, req.ExerciseID, session.ID, req.ExerciseID, req.Reps, req.Weight, req.RPE, req.RestSeconds, req.Notes)
+		if err != nil {
+			log.Printf("Error logging set: %v", err)
+			http.Error(w, `{"error": "Failed to log set"}`, http.StatusInternalServerError)
+			return
+		}
+
+		writeSession(w, db, session.ID, r.Context().Value(userIDKey).(int), http.StatusCreated)
+	}
+}
+
+// UpdateSet godoc
+//
+//	@ID				updateSet
+//	@Summary		Update a logged set
+//	@Description	Correct the exercise, reps, weight, RPE, rest time or notes of a logged set
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Session ID"
+//	@Param			setId			path		int						true	"Set ID"
+//	@Param			logSetRequest	body		models.LogSetRequest	true	"Performed set"
+//	@Success		200	{object}	models.WorkoutSession	"Updated session"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Session or set not found"
+//	@Failure		409	{object}	map[string]string		"Session was skipped"
+//	@Failure		422	{object}	map[string]string		"Validation errors"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/sessions/{id}/sets/{setId} [put]
+func UpdateSet(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setID, err := strconv.Atoi(r.PathValue("setId"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid set ID"}`, http.StatusBadRequest)
+			return
+		}
+		session := editableSession(w, r, db)
+		if session == nil {
+			return
+		}
+		req, ok := decodeSetRequest(w, r, db)
+		if !ok {
+			return
+		}
+
+		res, err := db.Exec(`
+			UPDATE session_sets
+			SET exercise_id = ?, reps = ?, weight = ?, rpe = ?, rest_seconds = ?, notes = ?, updated_at = ?
+			WHERE id = ? AND session_id = ?`,
+			req.ExerciseID, req.Reps, req.Weight, req.RPE, req.RestSeconds, req.Notes, time.Now(), setID, session.ID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to update set"}`, http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, `{"error": "Set not found"}`, http.StatusNotFound)
+			return
+		}
+
+		writeSession(w, db, session.ID, r.Context().Value(userIDKey).(int), http.StatusOK)
+	}
+}
+
+// DeleteSet godoc
+//
+//	@ID				deleteSet
+//	@Summary		Delete a logged set
+//	@Description	Remove a set from a session
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Session ID"
+//	@Param			setId	path		int					true	"Set ID"
+//	@Success		204		{string}	string				"Set deleted"
+//	@Failure		400		{object}	map[string]string	"Invalid request"
+//	@Failure		401		{object}	map[string]string	"Unauthorized"
+//	@Failure		404		{object}	map[string]string	"Session or set not found"
+//	@Failure		409		{object}	map[string]string	"Session was skipped"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/sessions/{id}/sets/{setId} [delete]
+func DeleteSet(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setID, err := strconv.Atoi(r.PathValue("setId"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid set ID"}`, http.StatusBadRequest)
+			return
+		}
+		session := editableSession(w, r, db)
+		if session == nil {
+			return
+		}
+
+		res, err := db.Exec(`DELETE FROM session_sets WHERE id = ? AND session_id = ?`, setID, session.ID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to delete set"}`, http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, `{"error": "Set not found"}`, http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CompleteSession godoc
+//
+//	@ID				completeSession
+//	@Summary		Complete a session
+//	@Description	Finish an in-progress session and mark its workout as completed.
+//	@Description	The stats endpoints then use the logged sets instead of the plan.
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id						path		int							true	"Session ID"
+//	@Param			finishSessionRequest	body		models.FinishSessionRequest	false	"Notes"
+//	@Success		200	{object}	models.WorkoutSession	"Completed session"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Session not found"
+//	@Failure		409	{object}	map[string]string		"Session is not in progress"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/sessions/{id}/complete [post]
+func CompleteSession(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid session ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		// The body is optional
+		var req models.FinishSessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+
+		session, err := loadSession(db, sessionID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Session not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if session.Status != models.SessionInProgress {
+			http.Error(w, `{"error": "Session is not in progress"}`, http.StatusConflict)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		now := time.Now().UTC()
+		if _, err := tx.Exec(`UPDATE workout_sessions SET status = ?, finished_at = ?, notes = ?, updated_at = ? WHERE id = ?`,
+			models.SessionCompleted, now, req.Notes, now, session.ID); err != nil {
+			http.Error(w, `{"error": "Failed to complete session"}`, http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(`UPDATE workouts SET completed_at = ?, updated_at = ? WHERE id = ?`, now, now, session.WorkoutID); err != nil {
+			http.Error(w, `{"error": "Failed to update workout"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to complete session"}`, http.StatusInternalServerError)
+			return
+		}
+
+		writeSession(w, db, session.ID, userID, http.StatusOK)
+	}
+}
+
+// SkipWorkout godoc
+//
+//	@ID				skipWorkout
+//	@Summary		Skip a workout
+//	@Description	Record that a planned workout was skipped, stopping its session if one is in progress.
+//	@Description	Skipped workouts count as missed in the adherence stats.
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id						path		int							true	"Workout ID"
+//	@Param			finishSessionRequest	body		models.FinishSessionRequest	false	"Reason"
+//	@Success		200	{object}	models.WorkoutSession	"Skipped session"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Workout not found"
+//	@Failure		409	{object}	map[string]string		"Workout already completed"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/workouts/{id}/skip [post]
+func SkipWorkout(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		workoutID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid workout ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		// The body is optional
+		var req models.FinishSessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+
+		var completedAt sql.NullTime
+		err = db.QueryRow(`SELECT completed_at FROM workouts WHERE id = ? AND user_id = ?`, workoutID, userID).Scan(&completedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Workout not found or unauthorized"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if completedAt.Valid {
+			http.Error(w, `{"error": "Workout already completed"}`, http.StatusConflict)
+			return
+		}
+
+		// Create the session, or stop the one in progress
+		now := time.Now().UTC()
+		_, err = db.Exec(`
+			INSERT INTO workout_sessions (workout_id, user_id, status, started_at, finished_at, notes)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (workout_id) DO UPDATE
+			SET status = excluded.status, finished_at = excluded.finished_at, notes = excluded.notes, updated_at = excluded.finished_at`,
+			workoutID, userID, models.SessionSkipped, now, now, req.Notes)
+		if err != nil {
+			log.Printf("Error skipping workout: %v", err)
+			http.Error(w, `{"error": "Failed to skip workout"}`, http.StatusInternalServerError)
+			return
+		}
+
+		var sessionID int
+		if err := db.QueryRow(`SELECT id FROM workout_sessions WHERE workout_id = ?`, workoutID).Scan(&sessionID); err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		writeSession(w, db, sessionID, userID, http.StatusOK)
+	}
+}
+
+// GetWorkoutComparison godoc
+//
+//	@ID				getWorkoutComparison
+//	@Summary		Compare planned and actual
+//	@Description	Compare the planned sets, reps and weight of each exercise with the sets logged in the workout's session
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int							true	"Workout ID"
+//	@Success		200	{object}	models.WorkoutComparison	"Planned vs actual"
+//	@Failure		400	{object}	map[string]string			"Invalid request"
+//	@Failure		401	{object}	map[string]string			"Unauthorized"
+//	@Failure		404	{object}	map[string]string			"Workout not found"
+//	@Failure		500	{object}	map[string]string			"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/workouts/{id}/comparison [get]
+func GetWorkoutComparison(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		workoutID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid workout ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		comparison := models.WorkoutComparison{WorkoutID: workoutID, Status: "planned", Exercises: []models.ExerciseComparison{}}
+		err = db.QueryRow(`SELECT name, scheduled_for FROM workouts WHERE id = ? AND user_id = ?`, workoutID, userID).
+			Scan(&comparison.Name, &comparison.ScheduledFor)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Workout not found or unauthorized"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		// Keep the plan's order, then any unplanned exercises in the order they were logged
+		byExercise := make(map[int]*models.ExerciseComparison)
+		var order []int
+		entry := func(exerciseID int, name string) *models.ExerciseComparison {
+			c, ok := byExercise[exerciseID]
+			if !ok {
+				c = &models.ExerciseComparison{ExerciseID: exerciseID, Name: name}
+				byExercise[exerciseID] = c
+				order = append(order, exerciseID)
+			}
+			return c
+		}
+
+		rows, err := db.Query(`
+			SELECT we.exercise_id, e.name, we.sets, we.reps, we.weight
+			FROM workout_exercises we
+			JOIN exercises e ON we.exercise_id = e.id
+			WHERE we.workout_id = ?
+			ORDER BY we.id ASC`, workoutID)
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var exerciseID, sets, reps int
+			var name string
+			var weight float64
+			if err := rows.Scan(&exerciseID, &name, &sets, &reps, &weight); err != nil {
+				http.Error(w, `{"error": "Scan error"}`, http.StatusInternalServerError)
+				return
+			}
+			// The same exercise may be planned twice, e.g. heavy and back-off sets
+			c := entry(exerciseID, name)
+			c.Planned.Sets += sets
+			c.Planned.Reps = reps
+			c.Planned.Weight = math.Max(c.Planned.Weight, weight)
+			c.Planned.Volume += float64(sets*reps) * weight
+		}
+
+		var sessionID int
+		err = db.QueryRow(`SELECT id, status FROM workout_sessions WHERE workout_id = ?`, workoutID).Scan(&sessionID, &comparison.Status)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			session, err := loadSession(db, sessionID, userID)
+			if err != nil {
+				http.Error(w, `{"error": "Failed to load session"}`, http.StatusInternalServerError)
+				return
+			}
+			rpeTotals := make(map[int]float64)
+			rpeCounts := make(map[int]int)
+			for _, set := range session.Sets {
+				c := entry(set.ExerciseID, set.ExerciseName)
+				c.Actual.Sets++
+				c.Actual.Reps += set.Reps
+				c.Actual.MaxWeight = math.Max(c.Actual.MaxWeight, set.Weight)
+				c.Actual.Volume += float64(set.Reps) * set.Weight
+				if set.RPE != nil {
+					rpeTotals[set.ExerciseID] += *set.RPE
+					rpeCounts[set.ExerciseID]++
+				}
+			}
+			for id, n := range rpeCounts {
+				avg := math.Round(rpeTotals[id]/float64(n)*10) / 10
+				byExercise[id].Actual.AverageRPE = &avg
+			}
+		}
+
+		for _, id := range order {
+			c := byExercise[id]
+			c.SetsDiff = c.Actual.Sets - c.Planned.Sets
+			c.VolumeDiff = c.Actual.Volume - c.Planned.Volume
+			if c.Planned.Volume > 0 {
+				c.CompletedPct = math.Round(c.Actual.Volume/c.Planned.Volume*1000) / 10
+			}
+			comparison.PlannedVolume += c.Planned.Volume
+			comparison.ActualVolume += c.Actual.Volume
+			comparison.Exercises = append(comparison.Exercises, *c)
+		}
+
+		json.NewEncoder(w).Encode(comparison)
+	}
+}
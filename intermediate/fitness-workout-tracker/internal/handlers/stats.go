@@ -0,0 +1,497 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/models"
+)
+
+// recentPRLimit caps the personal records returned by GET /stats/records
+const recentPRLimit = 20
+
+// performedSet is one exercise line of a completed workout: a set logged in
+// its session, or a planned line when nothing was logged
+type performedSet struct {
+	WorkoutID   int
+	Date        time.Time
+	ExerciseID  int
+	Name        string
+	MuscleGroup string
+	Sets        int
+	Reps        int
+	Weight      float64
+}
+
+// loadPerformedSets returns what the user did in completed workouts, oldest
+// first. Workouts with logged sets use them; workouts marked completed without
+// logging any sets fall back to their plan. A zero since loads the whole history.
+func loadPerformedSets(db *sql.DB, userID int, since time.Time) ([]performedSet, error) {
+	rows, err := db.Query(`
+		SELECT w.id, w.completed_at, ss.exercise_id, e.name, e.muscle_group, 1, ss.reps, ss.weight, ss.id
+		FROM workouts w
+		JOIN workout_sessions s ON s.workout_id = w.id
+		JOIN session_sets ss ON ss.session_id = s.id
+		JOIN exercises e ON ss.exercise_id = e.id
+		WHERE w.user_id = ? AND w.completed_at IS NOT NULL AND w.completed_at >= ?
+		UNION ALL
+		SELECT w.id, w.completed_at, we.exercise_id, e.name, e.muscle_group, we.sets, we.reps, we.weight, we.id
+		FROM workouts w
+		JOIN workout_exercises we ON w.id = we.workout_id
+		JOIN exercises e ON we.exercise_id = e.id
+		WHERE w.user_id = ? AND w.completed_at IS NOT NULL AND w.completed_at >= ?
+		  AND NOT EXISTS (
+		      SELECT 1 FROM workout_sessions s JOIN session_sets ss ON ss.session_id = s.id
+		      WHERE s.workout_id = w.id)
+		ORDER BY 2 ASC, 1 ASC, 9 ASC`,
+		userID, since, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sets []performedSet
+	for rows.Next() {
+		var s performedSet
+		var lineID int
+		if err := rows.Scan(&s.WorkoutID, &s.Date, &s.ExerciseID, &s.Name, &s.MuscleGroup, &s.Sets, &s.Reps, &s.Weight, &lineID); err != nil {
+			return nil, err
+		}
+		sets = append(sets, s)
+	}
+	return sets, rows.Err()
+}
+
+// exerciseSession is the best performance of one exercise within one workout
+type exerciseSession struct {
+	performedSet
+	EstimatedMax  float64
+	BestSetWeight float64
+	BestSetReps   int
+	MaxWeight     float64
+	TotalSets     int
+	TotalReps     int
+	Volume        float64
+}
+
+// groupSessions folds performed sets into one entry per workout and exercise,
+// keeping the order of the first set.
+func groupSessions(sets []performedSet) []*exerciseSession {
+	type key struct{ workout, exercise int }
+	index := make(map[key]*exerciseSession)
+	var sessions []*exerciseSession
+
+	for _, s := range sets {
+		k := key{s.WorkoutID, s.ExerciseID}
+		sess, ok := index[k]
+		if !ok {
+			sess = &exerciseSession{performedSet: s}
+			index[k] = sess
+			sessions = append(sessions, sess)
+		}
+		if e := models.EstimateOneRepMax(s.Weight, s.Reps); e > sess.EstimatedMax {
+			sess.EstimatedMax, sess.BestSetWeight, sess.BestSetReps = e, s.Weight, s.Reps
+		}
+		sess.MaxWeight = math.Max(sess.MaxWeight, s.Weight)
+		sess.TotalSets += s.Sets
+		sess.TotalReps += s.Sets * s.Reps
+		sess.Volume += float64(s.Sets*s.Reps) * s.Weight
+	}
+	return sessions
+}
+
+// parseDateRange reads start_date and end_date (YYYY-MM-DD). Missing dates
+// default to the defaultDays days ending today. The returned end is exclusive.
+func parseDateRange(r *http.Request, defaultDays int) (start, end time.Time, err error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	start, end = today.AddDate(0, 0, -(defaultDays-1)), today
+
+	if s := r.URL.Query().Get("start_date"); s != "" {
+		if start, err = time.Parse("2006-01-02", s); err != nil {
+			return start, end, errors.New("invalid start date")
+		}
+	}
+	if s := r.URL.Query().Get("end_date"); s != "" {
+		if end, err = time.Parse("2006-01-02", s); err != nil {
+			return start, end, errors.New("invalid end date")
+		}
+	}
+	if end.Before(start) {
+		return start, end, errors.New("end date is before start date")
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// GetRecords godoc
+//
+//	@ID				getRecords
+//	@Summary		Get personal records
+//	@Description	Best estimated one-rep max (Epley), heaviest weight and highest single-workout volume per exercise,
+//	@Description	from completed workouts, plus the most recent personal records that beat a previous best
+//	@Tags			stats
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.RecordsResponse	"Personal records"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/stats/records [get]
+func GetRecords(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		sets, err := loadPerformedSets(db, userID, time.Time{})
+		if err != nil {
+			log.Printf("Error loading performed sets: %v", err)
+			http.Error(w, `{"error": "Failed to load workouts"}`, http.StatusInternalServerError)
+			return
+		}
+
+		records := make(map[int]*models.ExerciseRecord)
+		var prs []models.PersonalRecord
+		// pr records a new best when it beats a previous one; the first workout only sets the baseline
+		pr := func(s *exerciseSession, kind string, value, previous float64) {
+			if previous > 0 && value > previous {
+				prs = append(prs, models.PersonalRecord{
+					ExerciseID: s.ExerciseID, Name: s.Name, WorkoutID: s.WorkoutID,
+					Date: s.Date, Type: kind, Value: value, Previous: previous,
+				})
+			}
+		}
+
+		for _, s := range groupSessions(sets) {
+			rec, ok := records[s.ExerciseID]
+			if !ok {
+				rec = &models.ExerciseRecord{ExerciseID: s.ExerciseID, Name: s.Name, MuscleGroup: s.MuscleGroup}
+				records[s.ExerciseID] = rec
+			}
+			rec.Workouts++
+
+			if s.EstimatedMax > rec.EstimatedMax {
+				pr(s, models.RecordEstimatedMax, s.EstimatedMax, rec.EstimatedMax)
+				rec.EstimatedMax, rec.EstimatedDate = s.EstimatedMax, s.Date
+				rec.BestSetWeight, rec.BestSetReps = s.BestSetWeight, s.BestSetReps
+			}
+			if s.MaxWeight > rec.MaxWeight {
+				pr(s, models.RecordMaxWeight, s.MaxWeight, rec.MaxWeight)
+				rec.MaxWeight, rec.MaxWeightDate = s.MaxWeight, s.Date
+			}
+			if s.Volume > rec.MaxVolume {
+				pr(s, models.RecordMaxVolume, s.Volume, rec.MaxVolume)
+				rec.MaxVolume, rec.MaxVolumeDate = s.Volume, s.Date
+			}
+		}
+
+		response := models.RecordsResponse{
+			Records:   []models.ExerciseRecord{},
+			RecentPRs: []models.PersonalRecord{},
+		}
+		for _, rec := range records {
+			response.Records = append(response.Records, *rec)
+		}
+		sort.Slice(response.Records, func(i, j int) bool { return response.Records[i].Name < response.Records[j].Name })
+
+		for i := len(prs) - 1; i >= 0 && len(response.RecentPRs) < recentPRLimit; i-- {
+			response.RecentPRs = append(response.RecentPRs, prs[i])
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetWeeklyVolume godoc
+//
+//	@ID				getWeeklyVolume
+//	@Summary		Get weekly volume
+//	@Description	Training volume (sets × reps × weight) of completed workouts per week (Monday to Sunday, UTC)
+//	@Description	and muscle group. An exercise that works several muscle groups counts towards each of them.
+//	@Tags			stats
+//	@Accept			json
+//	@Produce		json
+//	@Param			weeks	query		int						false	"Number of weeks up to the current one (default 12, max 104)"
+//	@Success		200		{array}		models.WeeklyVolume		"Volume per week, oldest first"
+//	@Failure		400		{object}	map[string]string		"Invalid request"
+//	@Failure		401		{object}	map[string]string		"Unauthorized"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/stats/volume [get]
+func GetWeeklyVolume(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		weeks := 12
+		if s := r.URL.Query().Get("weeks"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > 104 {
+				http.Error(w, `{"error": "weeks must be between 1 and 104"}`, http.StatusBadRequest)
+				return
+			}
+			weeks = n
+		}
+
+		first := models.WeekStart(time.Now()).AddDate(0, 0, -7*(weeks-1))
+		sets, err := loadPerformedSets(db, userID, first)
+		if err != nil {
+			log.Printf("Error loading performed sets: %v", err)
+			http.Error(w, `{"error": "Failed to load workouts"}`, http.StatusInternalServerError)
+			return
+		}
+
+		result := make([]models.WeeklyVolume, weeks)
+		for i := range result {
+			result[i] = models.WeeklyVolume{
+				WeekStart:    first.AddDate(0, 0, 7*i),
+				MuscleGroups: map[string]float64{},
+			}
+		}
+
+		counted := make(map[int]bool)
+		for _, s := range sets {
+			i := int(models.WeekStart(s.Date).Sub(first).Hours() / (24 * 7))
+			if i < 0 || i >= weeks {
+				continue
+			}
+			week := &result[i]
+			if !counted[s.WorkoutID] {
+				counted[s.WorkoutID] = true
+				week.Workouts++
+			}
+			volume := float64(s.Sets*s.Reps) * s.Weight
+			week.Total += volume
+			for _, group := range models.MuscleGroups(s.MuscleGroup) {
+				week.MuscleGroups[group] += volume
+			}
+		}
+
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// GetStreaks godoc
+//
+//	@ID				getStreaks
+//	@Summary		Get streaks
+//	@Description	Current and longest runs of consecutive days and weeks (UTC) with at least one completed workout
+//	@Tags			stats
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.StreaksResponse	"Streaks"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/stats/streaks [get]
+func GetStreaks(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		rows, err := db.Query(`SELECT completed_at FROM workouts WHERE user_id = ? AND completed_at IS NOT NULL ORDER BY completed_at ASC`, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var days, weeks []time.Time
+		for rows.Next() {
+			var completed time.Time
+			if err := rows.Scan(&completed); err != nil {
+				http.Error(w, `{"error": "Scan error"}`, http.StatusInternalServerError)
+				return
+			}
+			day := completed.UTC().Truncate(24 * time.Hour)
+			if len(days) == 0 || !days[len(days)-1].Equal(day) {
+				days = append(days, day)
+			}
+			week := models.WeekStart(completed)
+			if len(weeks) == 0 || !weeks[len(weeks)-1].Equal(week) {
+				weeks = append(weeks, week)
+			}
+		}
+
+		var response models.StreaksResponse
+		if len(days) > 0 {
+			last := days[len(days)-1]
+			response.LastWorkout = &last
+		}
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		response.CurrentDays, response.LongestDays = streaks(days, today, 1)
+		response.CurrentWeeks, response.LongestWeeks = streaks(weeks, models.WeekStart(today), 7)
+
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// streaks returns the current and longest runs in sorted, distinct periods
+// that are stepDays apart. The current run must reach the period starting at
+// now or the one before it.
+func streaks(periods []time.Time, now time.Time, stepDays int) (current, longest int) {
+	run := 0
+	for i, p := range periods {
+		if i > 0 && periods[i-1].AddDate(0, 0, stepDays).Equal(p) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+	}
+	if n := len(periods); n > 0 {
+		last := periods[n-1]
+		if last.Equal(now) || last.AddDate(0, 0, stepDays).Equal(now) {
+			current = run
+		}
+	}
+	return current, longest
+}
+
+// GetAdherence godoc
+//
+//	@ID				getAdherence
+//	@Summary		Get adherence
+//	@Description	Compare workouts scheduled in a date range with those completed. Workouts still ahead count as upcoming.
+//	@Tags			stats
+//	@Accept			json
+//	@Produce		json
+//	@Param			start_date	query		string						false	"Start Date (YYYY-MM-DD, default 30 days ago)"
+//	@Param			end_date	query		string						false	"End Date (YYYY-MM-DD, default today)"
+//	@Success		200			{object}	models.AdherenceResponse	"Adherence"
+//	@Failure		400			{object}	map[string]string			"Invalid request"
+//	@Failure		401			{object}	map[string]string			"Unauthorized"
+//	@Failure		500			{object}	map[string]string			"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/stats/adherence [get]
+func GetAdherence(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		start, end, err := parseDateRange(r, 30)
+		if err != nil {
+			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT w.scheduled_for, w.completed_at IS NOT NULL, COALESCE(s.status = 'skipped', 0)
+			FROM workouts w
+			LEFT JOIN workout_sessions s ON s.workout_id = w.id
+			WHERE w.user_id = ? AND w.scheduled_for >= ? AND w.scheduled_for < ?`,
+			userID, start, end)
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		response := models.AdherenceResponse{
+			StartDate: start.Format("2006-01-02"),
+			EndDate:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		}
+		now := time.Now()
+		for rows.Next() {
+			var scheduled time.Time
+			var completed, skipped bool
+			if err := rows.Scan(&scheduled, &completed, &skipped); err != nil {
+				http.Error(w, `{"error": "Scan error"}`, http.StatusInternalServerError)
+				return
+			}
+			switch {
+			case completed:
+				response.Due++
+				response.Completed++
+			case skipped:
+				response.Due++
+				response.Missed++
+				response.Skipped++
+			case scheduled.After(now):
+				response.Upcoming++
+			default:
+				response.Due++
+				response.Missed++
+			}
+		}
+		if response.Due > 0 {
+			response.Adherence = math.Round(float64(response.Completed)/float64(response.Due)*1000) / 10
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetProgression godoc
+//
+//	@ID				getProgression
+//	@Summary		Get exercise progression
+//	@Description	Chart data for one exercise: estimated one-rep max, heaviest weight and volume of each completed workout
+//	@Tags			stats
+//	@Accept			json
+//	@Produce		json
+//	@Param			exercise_id	query		int							true	"Exercise ID"
+//	@Param			start_date	query		string						false	"Start Date (YYYY-MM-DD, default one year ago)"
+//	@Param			end_date	query		string						false	"End Date (YYYY-MM-DD, default today)"
+//	@Success		200			{object}	models.ProgressionResponse	"Progression, oldest first"
+//	@Failure		400			{object}	map[string]string			"Invalid request"
+//	@Failure		401			{object}	map[string]string			"Unauthorized"
+//	@Failure		404			{object}	map[string]string			"Exercise not found"
+//	@Failure		500			{object}	map[string]string			"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/stats/progression [get]
+func GetProgression(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		exerciseID, err := strconv.Atoi(r.URL.Query().Get("exercise_id"))
+		if err != nil {
+			http.Error(w, `{"error": "exercise_id is required"}`, http.StatusBadRequest)
+			return
+		}
+		start, end, err := parseDateRange(r, 365)
+		if err != nil {
+			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusBadRequest)
+			return
+		}
+
+		response := models.ProgressionResponse{ExerciseID: exerciseID, Points: []models.ProgressionPoint{}}
+		err = db.QueryRow(`SELECT e.name FROM exercises e WHERE e.id = ? AND `+visibleExercise, exerciseID, userID).Scan(&response.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Exercise not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		sets, err := loadPerformedSets(db, userID, start)
+		if err != nil {
+			log.Printf("Error loading performed sets: %v", err)
+			http.Error(w, `{"error": "Failed to load workouts"}`, http.StatusInternalServerError)
+			return
+		}
+		var matching []performedSet
+		for _, s := range sets {
+			if s.ExerciseID == exerciseID && s.Date.Before(end) {
+				matching = append(matching, s)
+			}
+		}
+
+		for _, s := range groupSessions(matching) {
+			response.Points = append(response.Points, models.ProgressionPoint{
+				WorkoutID:    s.WorkoutID,
+				Date:         s.Date,
+				EstimatedMax: s.EstimatedMax,
+				MaxWeight:    s.MaxWeight,
+				Sets:         s.TotalSets,
+				Reps:         s.TotalReps,
+				Volume:       s.Volume,
+			})
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}
+}
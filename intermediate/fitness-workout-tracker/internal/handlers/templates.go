@@ -0,0 +1,554 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/models"
+)
+
+// loadTemplate fetches a template of the user with its exercises.
+// It returns sql.ErrNoRows when the template does not exist or belongs to someone else.
+func loadTemplate(db *sql.DB, templateID, userID int) (*models.TemplateResponse, error) {
+	var t models.TemplateResponse
+	err := db.QueryRow(`SELECT id, name, description FROM workout_templates WHERE id = ? AND user_id = ?`,
+		templateID, userID,
+	).Scan(&t.ID, &t.Name, &t.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT te.exercise_id, te.sets, te.reps, te.weight, te.notes,
+		       e.name, e.category, e.muscle_group, e.description
+		FROM template_exercises te
+		JOIN exercises e ON te.exercise_id = e.id
+		WHERE te.template_id = ?
+		ORDER BY te.id ASC`, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	t.Exercises = []models.WorkoutExerciseResponse{}
+	for rows.Next() {
+		var ex models.WorkoutExerciseResponse
+		var eDesc sql.NullString
+		if err := rows.Scan(&ex.ExerciseID, &ex.Sets, &ex.Reps, &ex.Weight, &ex.Notes,
+			&ex.Exercise.Name, &ex.Exercise.Category, &ex.Exercise.MuscleGroup, &eDesc); err != nil {
+			return nil, err
+		}
+		ex.Exercise.ID = ex.ExerciseID
+		ex.Exercise.Description = eDesc.String
+		t.Exercises = append(t.Exercises, ex)
+	}
+	return &t, rows.Err()
+}
+
+// templateExercises converts the exercises of a loaded template back into planned exercises
+func templateExercises(t *models.TemplateResponse) []models.WorkoutExerciseRequest {
+	exercises := make([]models.WorkoutExerciseRequest, 0, len(t.Exercises))
+	for _, ex := range t.Exercises {
+		exercises = append(exercises, models.WorkoutExerciseRequest{
+			ExerciseID: ex.ExerciseID,
+			Sets:       ex.Sets,
+			Reps:       ex.Reps,
+			Weight:     ex.Weight,
+			Notes:      ex.Notes,
+		})
+	}
+	return exercises
+}
+
+// insertTemplateExercises adds the planned exercises of a template
+func insertTemplateExercises(tx *sql.Tx, templateID int64, exercises []models.WorkoutExerciseRequest) error {
+	for _, ex := range exercises {
+		_, err := tx.Exec(`INSERT INTO template_exercises (template_id, exercise_id, sets, reps, weight, notes) VALUES (?, ?, ?, ?, ?, ?)`,
+			templateID, ex.ExerciseID, ex.Sets, ex.Reps, ex.Weight, ex.Notes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertWorkout creates a scheduled workout with its exercises. runID links
+// workouts generated by a program run and is nil otherwise.
+func insertWorkout(tx *sql.Tx, userID int, name, description string, scheduledFor time.Time, runID *int64, exercises []models.WorkoutExerciseRequest) (int64, error) {
+	res, err := tx.Exec(`INSERT INTO workouts (user_id, name, scheduled_for, description, program_run_id) VALUES (?, ?, ?, ?, ?)`,
+		userID, name, scheduledFor, description, runID)
+	if err != nil {
+		return 0, err
+	}
+	workoutID, _ := res.LastInsertId()
+
+	for _, ex := range exercises {
+		_, err := tx.Exec(`INSERT INTO workout_exercises (workout_id, exercise_id, sets, reps, weight, notes) VALUES (?, ?, ?, ?, ?, ?)`,
+			workoutID, ex.ExerciseID, ex.Sets, ex.Reps, ex.Weight, ex.Notes)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return workoutID, nil
+}
+
+// writeTemplate responds with the current state of a template
+func writeTemplate(w http.ResponseWriter, db *sql.DB, templateID, userID, status int) {
+	template, err := loadTemplate(db, templateID, userID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to load template"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(template)
+}
+
+// CreateTemplate godoc
+//
+//	@ID				createTemplate
+//	@Summary		Create a workout template
+//	@Description	Save a reusable set of planned exercises that can be scheduled or used in programs
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			createTemplateRequest	body		models.CreateTemplateRequest	true	"Template"
+//	@Success		201	{object}	models.TemplateResponse	"Template created"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		422	{object}	map[string]string		"Validation errors"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/templates [post]
+func CreateTemplate(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		var req models.CreateTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusUnprocessableEntity)
+			return
+		}
+		if ok, err := exercisesVisible(db, userID, req.Exercises); err != nil || !ok {
+			http.Error(w, `{"error": "Exercise not found"}`, http.StatusUnprocessableEntity)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`INSERT INTO workout_templates (user_id, name, description) VALUES (?, ?, ?)`,
+			userID, req.Name, req.Description)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to create template"}`, http.StatusInternalServerError)
+			return
+		}
+		templateID, _ := res.LastInsertId()
+
+		if err := insertTemplateExercises(tx, templateID, req.Exercises); err != nil {
+			http.Error(w, `{"error": "Failed to add exercises"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to save template"}`, http.StatusInternalServerError)
+			return
+		}
+
+		writeTemplate(w, db, int(templateID), userID, http.StatusCreated)
+	}
+}
+
+// ListTemplates godoc
+//
+//	@ID				listTemplates
+//	@Summary		List workout templates
+//	@Description	Retrieve the workout templates of the authenticated user
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		models.TemplateResponse	"List of templates"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/templates [get]
+func ListTemplates(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		rows, err := db.Query(`SELECT id FROM workout_templates WHERE user_id = ? ORDER BY name ASC, id ASC`, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to fetch templates"}`, http.StatusInternalServerError)
+			return
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				http.Error(w, `{"error": "Error scanning templates"}`, http.StatusInternalServerError)
+				return
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		templates := []*models.TemplateResponse{}
+		for _, id := range ids {
+			t, err := loadTemplate(db, id, userID)
+			if err != nil {
+				http.Error(w, `{"error": "Failed to fetch templates"}`, http.StatusInternalServerError)
+				return
+			}
+			templates = append(templates, t)
+		}
+
+		json.NewEncoder(w).Encode(templates)
+	}
+}
+
+// GetTemplate godoc
+//
+//	@ID				getTemplate
+//	@Summary		Get a workout template
+//	@Description	Retrieve a workout template of the authenticated user
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int						true	"Template ID"
+//	@Success		200	{object}	models.TemplateResponse	"Template"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Template not found"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/templates/{id} [get]
+func GetTemplate(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templateID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid template ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		template, err := loadTemplate(db, templateID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Template not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(template)
+	}
+}
+
+// UpdateTemplate godoc
+//
+//	@ID				updateTemplate
+//	@Summary		Update a workout template
+//	@Description	Replace the name, description and exercises of a template.
+//	@Description	Workouts already generated from it are not changed.
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			id						path		int								true	"Template ID"
+//	@Param			createTemplateRequest	body		models.CreateTemplateRequest	true	"Template"
+//	@Success		200	{object}	models.TemplateResponse	"Updated template"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Template not found"
+//	@Failure		422	{object}	map[string]string		"Validation errors"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/templates/{id} [put]
+func UpdateTemplate(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templateID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid template ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		var req models.CreateTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusUnprocessableEntity)
+			return
+		}
+		if ok, err := exercisesVisible(db, userID, req.Exercises); err != nil || !ok {
+			http.Error(w, `{"error": "Exercise not found"}`, http.StatusUnprocessableEntity)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`UPDATE workout_templates SET name = ?, description = ?, updated_at = ? WHERE id = ? AND user_id = ?`,
+			req.Name, req.Description, time.Now(), templateID, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to update template"}`, http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, `{"error": "Template not found"}`, http.StatusNotFound)
+			return
+		}
+
+		if _, err := tx.Exec(`DELETE FROM template_exercises WHERE template_id = ?`, templateID); err != nil {
+			http.Error(w, `{"error": "Failed to clear existing exercises"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := insertTemplateExercises(tx, int64(templateID), req.Exercises); err != nil {
+			http.Error(w, `{"error": "Failed to add exercises"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to update template"}`, http.StatusInternalServerError)
+			return
+		}
+
+		writeTemplate(w, db, templateID, userID, http.StatusOK)
+	}
+}
+
+// DeleteTemplate godoc
+//
+//	@ID				deleteTemplate
+//	@Summary		Delete a workout template
+//	@Description	Delete a template that no program uses. Workouts generated from it are kept.
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int					true	"Template ID"
+//	@Success		204	{string}	string				"Template deleted"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		404	{object}	map[string]string	"Template not found"
+//	@Failure		409	{object}	map[string]string	"Template is used by a program"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/templates/{id} [delete]
+func DeleteTemplate(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templateID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid template ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		var used bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM program_sessions WHERE template_id = ?)`, templateID).Scan(&used); err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if used {
+			http.Error(w, `{"error": "Template is used by a program"}`, http.StatusConflict)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`DELETE FROM workout_templates WHERE id = ? AND user_id = ?`, templateID, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to delete template"}`, http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, `{"error": "Template not found"}`, http.StatusNotFound)
+			return
+		}
+		if _, err := tx.Exec(`DELETE FROM template_exercises WHERE template_id = ?`, templateID); err != nil {
+			http.Error(w, `{"error": "Failed to delete child records"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to delete"}`, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CloneTemplate godoc
+//
+//	@ID				cloneTemplate
+//	@Summary		Clone a workout template
+//	@Description	Copy a template with its exercises, e.g. to make a variation of it.
+//	@Description	The copy is named "Copy of <name>" unless a name is given.
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			id						path		int							true	"Template ID"
+//	@Param			cloneTemplateRequest	body		models.CloneTemplateRequest	false	"Name of the copy"
+//	@Success		201	{object}	models.TemplateResponse	"Cloned template"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Template not found"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/templates/{id}/clone [post]
+func CloneTemplate(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templateID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid template ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		// The body is optional
+		var req models.CloneTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+
+		source, err := loadTemplate(db, templateID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Template not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		name := req.Name
+		if name == "" {
+			name = "Copy of " + source.Name
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`INSERT INTO workout_templates (user_id, name, description) VALUES (?, ?, ?)`,
+			userID, name, source.Description)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to clone template"}`, http.StatusInternalServerError)
+			return
+		}
+		cloneID, _ := res.LastInsertId()
+
+		if err := insertTemplateExercises(tx, cloneID, templateExercises(source)); err != nil {
+			http.Error(w, `{"error": "Failed to add exercises"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to save template"}`, http.StatusInternalServerError)
+			return
+		}
+
+		writeTemplate(w, db, int(cloneID), userID, http.StatusCreated)
+	}
+}
+
+// ScheduleTemplate godoc
+//
+//	@ID				scheduleTemplate
+//	@Summary		Schedule a workout from a template
+//	@Description	Create a single dated workout with the exercises of a template
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			id							path		int								true	"Template ID"
+//	@Param			scheduleTemplateRequest		body		models.ScheduleTemplateRequest	true	"Date of the workout"
+//	@Success		201	{object}	models.WorkoutResponse	"Workout created"
+//	@Failure		400	{object}	map[string]string		"Invalid request"
+//	@Failure		401	{object}	map[string]string		"Unauthorized"
+//	@Failure		404	{object}	map[string]string		"Template not found"
+//	@Failure		422	{object}	map[string]string		"Validation errors"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/templates/{id}/workouts [post]
+func ScheduleTemplate(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templateID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid template ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		var req models.ScheduleTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		if req.ScheduledFor.IsZero() {
+			http.Error(w, `{"error": "scheduled_for is required"}`, http.StatusUnprocessableEntity)
+			return
+		}
+
+		template, err := loadTemplate(db, templateID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error": "Template not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		workoutID, err := insertWorkout(tx, userID, template.Name, template.Description, req.ScheduledFor, nil, templateExercises(template))
+		if err != nil {
+			log.Printf("Error scheduling template: %v", err)
+			http.Error(w, `{"error": "Failed to create workout"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to save workout"}`, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.WorkoutResponse{
+			ID:           int(workoutID),
+			Name:         template.Name,
+			Description:  template.Description,
+			ScheduledFor: req.ScheduledFor,
+			Exercises:    template.Exercises,
+		})
+	}
+}
@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/fitness-workout-tracker/internal/models"
+)
+
+// loadExport collects every workout of a user with its plan and logged sets
+func loadExport(db *sql.DB, userID int) (*models.ExportData, error) {
+	data := &models.ExportData{Version: models.ExportVersion, ExportedAt: time.Now().UTC(), Workouts: []models.ExportWorkout{}}
+
+	rows, err := db.Query(`
+		SELECT w.id, w.name, COALESCE(w.description, ''), w.scheduled_for, w.completed_at, COALESCE(s.status, '')
+		FROM workouts w
+		LEFT JOIN workout_sessions s ON s.workout_id = w.id
+		WHERE w.user_id = ?
+		ORDER BY w.scheduled_for ASC, w.id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	index := make(map[int]int)
+	for rows.Next() {
+		var id int
+		var wo models.ExportWorkout
+		var completedAt sql.NullTime
+		if err := rows.Scan(&id, &wo.Name, &wo.Description, &wo.ScheduledFor, &completedAt, &wo.Status); err != nil {
+			return nil, err
+		}
+		if completedAt.Valid {
+			wo.CompletedAt = &completedAt.Time
+		}
+		wo.Exercises = []models.ExportExercise{}
+		wo.Sets = []models.ExportSet{}
+		index[id] = len(data.Workouts)
+		data.Workouts = append(data.Workouts, wo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	planned, err := db.Query(`
+		SELECT we.workout_id, e.name, we.sets, we.reps, we.weight, COALESCE(we.notes, '')
+		FROM workout_exercises we
+		JOIN workouts w ON w.id = we.workout_id
+		JOIN exercises e ON e.id = we.exercise_id
+		WHERE w.user_id = ?
+		ORDER BY we.id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer planned.Close()
+	for planned.Next() {
+		var workoutID int
+		var ex models.ExportExercise
+		if err := planned.Scan(&workoutID, &ex.Exercise, &ex.Sets, &ex.Reps, &ex.Weight, &ex.Notes); err != nil {
+			return nil, err
+		}
+		if i, ok := index[workoutID]; ok {
+			data.Workouts[i].Exercises = append(data.Workouts[i].Exercises, ex)
+		}
+	}
+	if err := planned.Err(); err != nil {
+		return nil, err
+	}
+
+	sets, err := db.Query(`
+		SELECT s.workout_id, e.name, ss.set_number, ss.reps, ss.weight, ss.rpe, ss.rest_seconds, ss.notes
+		FROM session_sets ss
+		JOIN workout_sessions s ON s.id = ss.session_id
+		JOIN exercises e ON e.id = ss.exercise_id
+		WHERE s.user_id = ?
+		ORDER BY ss.id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer sets.Close()
+	for sets.Next() {
+		var workoutID int
+		var set models.ExportSet
+		if err := sets.Scan(&workoutID, &set.Exercise, &set.SetNumber, &set.Reps, &set.Weight, &set.RPE, &set.RestSeconds, &set.Notes); err != nil {
+			return nil, err
+		}
+		if i, ok := index[workoutID]; ok {
+			data.Workouts[i].Sets = append(data.Workouts[i].Sets, set)
+		}
+	}
+	return data, sets.Err()
+}
+
+// ExportWorkouts godoc
+//
+//	@ID				exportWorkouts
+//	@Summary		Export all workouts
+//	@Description	Download every workout with its planned exercises and logged sets, as JSON (default) or CSV.
+//	@Description	Exercises are referred to by name; the file can be imported again with POST /import.
+//	@Tags			transfer
+//	@Produce		json
+//	@Produce		text/csv
+//	@Param			format	query		string	false	"json or csv"	Enums(json, csv)
+//	@Success		200		{object}	models.ExportData	"Export"
+//	@Failure		400		{object}	map[string]string	"Invalid format"
+//	@Failure		401		{object}	map[string]string	"Unauthorized"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/export [get]
+func ExportWorkouts(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			http.Error(w, `{"error": "format must be json or csv"}`, http.StatusBadRequest)
+			return
+		}
+
+		data, err := loadExport(db, userID)
+		if err != nil {
+			log.Printf("Error exporting workouts: %v", err)
+			http.Error(w, `{"error": "Failed to export workouts"}`, http.StatusInternalServerError)
+			return
+		}
+
+		if format == "csv" {
+			var buf bytes.Buffer
+			if err := models.WriteTrackerCSV(&buf, data); err != nil {
+				http.Error(w, `{"error": "Failed to export workouts"}`, http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="workouts.csv"`)
+			w.Write(buf.Bytes())
+			return
+		}
+
+		w.Header().Set("Content-Disposition", `attachment; filename="workouts.json"`)
+		json.NewEncoder(w).Encode(data)
+	}
+}
+
+// exerciseMatcher resolves imported exercise names against the exercises and
+// aliases visible to a user, ignoring case
+type exerciseMatcher struct {
+	names   map[string]int
+	aliases map[string]int
+}
+
+func newExerciseMatcher(db *sql.DB, userID int) (*exerciseMatcher, error) {
+	m := &exerciseMatcher{names: make(map[string]int), aliases: make(map[string]int)}
+
+	// Custom exercises come last so they win over catalog entries of the same name
+	rows, err := db.Query(`SELECT e.id, e.name FROM exercises e WHERE `+visibleExercise+` ORDER BY e.user_id IS NOT NULL, e.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		m.names[strings.ToLower(name)] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	aliases, err := db.Query(`
+		SELECT a.exercise_id, a.alias
+		FROM exercise_aliases a
+		JOIN exercises e ON e.id = a.exercise_id
+		WHERE (a.user_id IS NULL OR a.user_id = ?) AND `+visibleExercise+`
+		ORDER BY a.user_id IS NOT NULL, a.id`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer aliases.Close()
+	for aliases.Next() {
+		var id int
+		var alias string
+		if err := aliases.Scan(&id, &alias); err != nil {
+			return nil, err
+		}
+		m.aliases[strings.ToLower(alias)] = id
+	}
+	return m, aliases.Err()
+}
+
+// resolve returns the exercise ID for an imported name, trying the names
+// before the aliases for each candidate spelling
+func (m *exerciseMatcher) resolve(name string) (int, bool) {
+	for _, candidate := range models.CandidateExerciseNames(name) {
+		key := strings.ToLower(candidate)
+		if id, ok := m.names[key]; ok {
+			return id, true
+		}
+		if id, ok := m.aliases[key]; ok {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// importKey identifies a workout for duplicate detection
+func importKey(name string, scheduledFor time.Time) string {
+	return strings.ToLower(name) + "|" + scheduledFor.UTC().Format(time.RFC3339)
+}
+
+// ImportWorkouts godoc
+//
+//	@ID				importWorkouts
+//	@Summary		Import workouts
+//	@Description	Import workouts from this tracker's JSON or CSV export, or from a CSV export of Strong or Hevy.
+//	@Description	The format is detected from the body unless given. Exercise names are matched against the
+//	@Description	catalog, custom exercises and aliases ("Bench Press (Barbell)" also matches "Barbell Bench Press"
+//	@Description	and "Bench Press"); rows that do not match are reported and left out. Workouts with the same name
+//	@Description	and time as an existing one are skipped. Third-party times without a zone are read in the given timezone.
+//	@Tags			transfer
+//	@Accept			json
+//	@Accept			text/csv
+//	@Produce		json
+//	@Param			format		query		string	false	"Input format, detected when omitted"	Enums(tracker, strong, hevy)
+//	@Param			timezone	query		string	false	"IANA timezone of third-party times (default UTC)"
+//	@Param			dry_run		query		bool	false	"Report what would be imported without saving"
+//	@Param			file		body		string	true	"Export file contents"
+//	@Success		200			{object}	models.ImportResult	"Import report"
+//	@Failure		400			{object}	map[string]string	"Unreadable file"
+//	@Failure		401			{object}	map[string]string	"Unauthorized"
+//	@Failure		413			{object}	map[string]string	"File too large"
+//	@Failure		422			{object}	map[string]string	"Unknown timezone"
+//	@Failure		500			{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/import [post]
+func ImportWorkouts(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(userIDKey).(int)
+		q := r.URL.Query()
+
+		format := q.Get("format")
+		if format != "" && format != models.FormatTracker && format != models.FormatStrong && format != models.FormatHevy {
+			http.Error(w, `{"error": "format must be tracker, strong or hevy"}`, http.StatusBadRequest)
+			return
+		}
+		loc := time.UTC
+		if tz := q.Get("timezone"); tz != "" {
+			var err error
+			if loc, err = time.LoadLocation(tz); err != nil {
+				http.Error(w, `{"error": "unknown timezone"}`, http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, models.MaxImportBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, `{"error": "Import file is too large"}`, http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, `{"error": "Failed to read import file"}`, http.StatusBadRequest)
+			return
+		}
+
+		result := models.ImportResult{
+			DryRun:             q.Get("dry_run") == "true",
+			Unmatched:          []models.ImportIssue{},
+			UnmatchedExercises: []string{},
+			Skipped:            []models.ImportIssue{},
+		}
+
+		// JSON is always the tracker's own export
+		var workouts []models.ExportWorkout
+		trimmed := bytes.TrimSpace(body)
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") || bytes.HasPrefix(trimmed, []byte("{")) {
+			var data models.ExportData
+			if err := json.Unmarshal(trimmed, &data); err != nil {
+				http.Error(w, `{"error": "Invalid JSON export"}`, http.StatusBadRequest)
+				return
+			}
+			result.Format = models.FormatTracker
+			workouts = data.Workouts
+		} else {
+			var issues []models.ImportIssue
+			result.Format, workouts, issues, err = models.ParseImportCSV(body, format, loc)
+			if err != nil {
+				// Parser errors quote the input, so the message must be escaped
+				msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+				http.Error(w, string(msg), http.StatusBadRequest)
+				return
+			}
+			result.Skipped = append(result.Skipped, issues...)
+		}
+
+		matcher, err := newExerciseMatcher(db, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to load exercises"}`, http.StatusInternalServerError)
+			return
+		}
+
+		existing := make(map[string]bool)
+		rows, err := db.Query(`SELECT name, scheduled_for FROM workouts WHERE user_id = ?`, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to load workouts"}`, http.StatusInternalServerError)
+			return
+		}
+		for rows.Next() {
+			var name string
+			var scheduledFor time.Time
+			if err := rows.Scan(&name, &scheduledFor); err == nil {
+				existing[importKey(name, scheduledFor)] = true
+			}
+		}
+		rows.Close()
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Failed to start transaction"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		unmatchedNames := make(map[string]bool)
+		unmatched := func(wo models.ExportWorkout, row int, exercise string) {
+			result.Unmatched = append(result.Unmatched, models.ImportIssue{Row: row, Workout: wo.Name, Exercise: exercise, Reason: "exercise not found"})
+			if !unmatchedNames[strings.ToLower(exercise)] {
+				unmatchedNames[strings.ToLower(exercise)] = true
+				result.UnmatchedExercises = append(result.UnmatchedExercises, exercise)
+			}
+		}
+
+		for _, wo := range workouts {
+			if strings.TrimSpace(wo.Name) == "" || wo.ScheduledFor.IsZero() {
+				result.Skipped = append(result.Skipped, models.ImportIssue{Workout: wo.Name, Reason: "workout name and scheduled_for are required"})
+				continue
+			}
+			key := importKey(wo.Name, wo.ScheduledFor)
+			if existing[key] {
+				result.Duplicates++
+				continue
+			}
+
+			// Third-party exports only list performed sets; the plan mirrors them
+			plan := wo.Exercises
+			if len(plan) == 0 {
+				plan = models.PlanFromSets(wo.Sets)
+			}
+
+			var exercises []models.WorkoutExerciseRequest
+			for _, ex := range plan {
+				if ex.Sets <= 0 || ex.Reps <= 0 || ex.Weight < 0 {
+					result.Skipped = append(result.Skipped, models.ImportIssue{Row: ex.Row, Workout: wo.Name, Exercise: ex.Exercise, Reason: "invalid planned exercise"})
+					continue
+				}
+				id, ok := matcher.resolve(ex.Exercise)
+				if !ok {
+					// Unmatched sets are reported below; only report the plan when it came from the file
+					if len(wo.Exercises) > 0 {
+						unmatched(wo, ex.Row, ex.Exercise)
+					}
+					continue
+				}
+				exercises = append(exercises, models.WorkoutExerciseRequest{ExerciseID: id, Sets: ex.Sets, Reps: ex.Reps, Weight: ex.Weight, Notes: ex.Notes})
+			}
+
+			type loggedSet struct {
+				models.ExportSet
+				ExerciseID int
+				Number     int
+			}
+			var logged []loggedSet
+			numbers := make(map[int]int)
+			for _, set := range wo.Sets {
+				if set.Reps <= 0 || set.Weight < 0 {
+					result.Skipped = append(result.Skipped, models.ImportIssue{Row: set.Row, Workout: wo.Name, Exercise: set.Exercise, Reason: "invalid set"})
+					continue
+				}
+				id, ok := matcher.resolve(set.Exercise)
+				if !ok {
+					unmatched(wo, set.Row, set.Exercise)
+					continue
+				}
+				numbers[id]++
+				logged = append(logged, loggedSet{ExportSet: set, ExerciseID: id, Number: numbers[id]})
+			}
+
+			if len(exercises) == 0 && len(logged) == 0 && (len(wo.Exercises) > 0 || len(wo.Sets) > 0) {
+				result.Skipped = append(result.Skipped, models.ImportIssue{Workout: wo.Name, Reason: "no exercises matched"})
+				continue
+			}
+			existing[key] = true
+			result.Workouts++
+			result.Planned += len(exercises)
+			result.Sets += len(logged)
+			if result.DryRun {
+				continue
+			}
+
+			workoutID, err := insertWorkout(tx, userID, wo.Name, wo.Description, wo.ScheduledFor.UTC(), nil, exercises)
+			if err != nil {
+				log.Printf("Error importing workout: %v", err)
+				http.Error(w, `{"error": "Failed to import workouts"}`, http.StatusInternalServerError)
+				return
+			}
+			// Without a session status, logged sets make one; none means the
+			// workout was only planned
+			status := wo.Status
+			if status != models.SessionInProgress && status != models.SessionCompleted && status != models.SessionSkipped {
+				status = ""
+				if len(logged) > 0 {
+					status = models.SessionInProgress
+					if wo.CompletedAt != nil {
+						status = models.SessionCompleted
+					}
+				}
+			}
+			startedAt := wo.ScheduledFor.UTC()
+			var finishedAt *time.Time
+			if status == models.SessionCompleted || status == models.SessionSkipped {
+				finished := startedAt
+				if wo.CompletedAt != nil {
+					finished = wo.CompletedAt.UTC()
+				}
+				finishedAt = &finished
+			}
+
+			// A completed session completes its workout, even when the file
+			// has no completed_at
+			completedAt := wo.CompletedAt
+			if status == models.SessionCompleted && completedAt == nil {
+				completedAt = finishedAt
+			}
+			if completedAt != nil {
+				if _, err := tx.Exec(`UPDATE workouts SET completed_at = ? WHERE id = ?`, completedAt.UTC(), workoutID); err != nil {
+					http.Error(w, `{"error": "Failed to import workouts"}`, http.StatusInternalServerError)
+					return
+				}
+			}
+			if status == "" {
+				continue
+			}
+
+			res, err := tx.Exec(`INSERT INTO workout_sessions (workout_id, user_id, status, started_at, finished_at) VALUES (?, ?, ?, ?, ?)`,
+				workoutID, userID, status, startedAt, finishedAt)
+			if err != nil {
+				http.Error(w, `{"error": "Failed to import workouts"}`, http.StatusInternalServerError)
+				return
+			}
+			sessionID, _ := res.LastInsertId()
+			for _, set := range logged {
+				_, err := tx.Exec(`
+					INSERT INTO session_sets (session_id, exercise_id, set_number, reps, weight, rpe, rest_seconds, notes)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+					sessionID, set.ExerciseID, set.Number, set.Reps, set.Weight, set.RPE, set.RestSeconds, set.Notes)
+				if err != nil {
+					http.Error(w, `{"error": "Failed to import workouts"}`, http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		if !result.DryRun {
+			if err := tx.Commit(); err != nil {
+				http.Error(w, `{"error": "Failed to import workouts"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
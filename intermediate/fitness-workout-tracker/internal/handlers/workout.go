@@ -3,7 +3,9 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -46,6 +48,10 @@ func CreateWorkout(db *sql.DB) http.HandlerFunc {
 			http.Error(w, `{"error": "Invalid request"}`, http.StatusUnprocessableEntity)
 			return
 		}
+		if ok, err := exercisesVisible(db, userID, req.Exercises); err != nil || !ok {
+			http.Error(w, `{"error": "Exercise not found"}`, http.StatusUnprocessableEntity)
+			return
+		}
 
 		// 4. Start Transaction
 		tx, err := db.Begin()
@@ -105,7 +111,7 @@ func ListWorkouts(db *sql.DB) http.HandlerFunc {
 		userID := r.Context().Value(userIDKey).(int)
 
 		query := `
-			SELECT w.id, w.name, w.scheduled_for, w.description,
+			SELECT w.id, w.name, w.scheduled_for, w.description, w.completed_at,
 			       we.exercise_id, we.sets, we.reps, we.weight, we.notes,
 			       e.name, e.category, e.muscle_group, e.description
 			FROM workouts w
@@ -133,12 +139,13 @@ func ListWorkouts(db *sql.DB) http.HandlerFunc {
 			var name string
 			var scheduledFor time.Time
 			var description *string
+			var completedAt sql.NullTime
 			// Using pointers to handle the NULLs we discussed!
 			var exID, sets, reps *int
 			var weight *float64
 			var notes *string
 
-			err := rows.Scan(&workoutID, &name, &scheduledFor, &description, &exID, &sets, &reps, &weight, &notes, &eName, &eCat, &eMuscle, &eDesc)
+			err := rows.Scan(&workoutID, &name, &scheduledFor, &description, &completedAt, &exID, &sets, &reps, &weight, &notes, &eName, &eCat, &eMuscle, &eDesc)
 			if err != nil {
 				continue
 			}
@@ -157,6 +164,9 @@ func ListWorkouts(db *sql.DB) http.HandlerFunc {
 					Description:  desc, // Initialize with empty string
 					Exercises:    []models.WorkoutExerciseResponse{},
 				}
+				if completedAt.Valid {
+					newWorkout.CompletedAt = &completedAt.Time
+				}
 
 				// Store the pointer in the map
 				workoutMap[workoutID] = newWorkout
@@ -233,6 +243,10 @@ func UpdateWorkout(db *sql.DB) http.HandlerFunc {
 			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
 			return
 		}
+		if ok, err := exercisesVisible(db, userID, req.Exercises); err != nil || !ok {
+			http.Error(w, `{"error": "Exercise not found"}`, http.StatusUnprocessableEntity)
+			return
+		}
 
 		// 3. Start Transaction
 		tx, err := db.Begin()
@@ -323,7 +337,7 @@ func GetWorkout(db *sql.DB) http.HandlerFunc {
 		}
 
 		rows, err := db.Query(`
-			SELECT w.id, w.name, w.scheduled_for, w.description, 
+			SELECT w.id, w.name, w.scheduled_for, w.description, w.completed_at,
 			       e.id, e.name, e.category, e.muscle_group, e.description,
 			       we.sets, we.reps, we.weight, we.notes
 			FROM workouts w
@@ -345,9 +359,10 @@ func GetWorkout(db *sql.DB) http.HandlerFunc {
 			var eID, sets, reps sql.NullInt64
 			var eName, eCat, eMuscle, eDesc, weNotes sql.NullString
 			var weight sql.NullFloat64
+			var completedAt sql.NullTime
 
 			err := rows.Scan(
-				&workout.ID, &workout.Name, &workout.ScheduledFor, &workout.Description,
+				&workout.ID, &workout.Name, &workout.ScheduledFor, &workout.Description, &completedAt,
 				&eID, &eName, &eCat, &eMuscle, &eDesc,
 				&sets, &reps, &weight, &weNotes,
 			)
@@ -356,6 +371,9 @@ func GetWorkout(db *sql.DB) http.HandlerFunc {
 				return
 			}
 			initialized = true
+			if completedAt.Valid {
+				workout.CompletedAt = &completedAt.Time
+			}
 
 			// Only append an exercise if the joined row actually exists (ID is not NULL)
 			if eID.Valid {
@@ -390,6 +408,79 @@ func GetWorkout(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// CompleteWorkout godoc
+//
+//	@ID				completeWorkout
+//	@Summary		Complete a workout
+//	@Description	Mark a workout of the authenticated user as completed, now or at the given time,
+//	@Description	finishing its session if one is in progress. Completed workouts feed the stats endpoints.
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id						path		int								true	"Workout ID"
+//	@Param			completeWorkoutRequest	body		models.CompleteWorkoutRequest	false	"Completion time"
+//	@Success		200	{object}	map[string]string	"Workout marked as completed"
+//	@Failure		400	{object}	map[string]string	"Invalid request"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		404	{object}	map[string]string	"Workout not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/workouts/{id}/complete [post]
+func CompleteWorkout(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		workoutID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, `{"error": "Invalid workout ID"}`, http.StatusBadRequest)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(int)
+
+		// The body is optional
+		var req models.CompleteWorkoutRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		completedAt := time.Now().UTC()
+		if req.CompletedAt != nil {
+			completedAt = req.CompletedAt.UTC()
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`UPDATE workouts SET completed_at = ?, updated_at = ? WHERE id = ? AND user_id = ?`,
+			completedAt, time.Now(), workoutID, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to update workout"}`, http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, `{"error": "Workout not found or unauthorized"}`, http.StatusNotFound)
+			return
+		}
+
+		// Finish a session left in progress
+		_, err = tx.Exec(`UPDATE workout_sessions SET status = ?, finished_at = ?, updated_at = ? WHERE workout_id = ? AND status = ?`,
+			models.SessionCompleted, completedAt, time.Now(), workoutID, models.SessionInProgress)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to update session"}`, http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error": "Failed to update workout"}`, http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"message": "Workout marked as completed"})
+	}
+}
+
 // DeleteWorkout godoc
 //
 //	@ID				deleteWorkout
@@ -425,12 +516,22 @@ func DeleteWorkout(db *sql.DB) http.HandlerFunc {
 		}
 		defer tx.Rollback()
 
-		// 3. Delete Exercises First (The Children)
+		// 3. Delete Exercises and Sessions First (The Children)
 		_, err = tx.Exec(`DELETE FROM workout_exercises WHERE workout_id = ?`, workoutID)
 		if err != nil {
 			http.Error(w, `{"error": "Failed to delete child records"}`, http.StatusInternalServerError)
 			return
 		}
+		_, err = tx.Exec(`DELETE FROM session_sets WHERE session_id IN (SELECT id FROM workout_sessions WHERE workout_id = ? AND user_id = ?)`, workoutID, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to delete child records"}`, http.StatusInternalServerError)
+			return
+		}
+		_, err = tx.Exec(`DELETE FROM workout_sessions WHERE workout_id = ? AND user_id = ?`, workoutID, userID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to delete child records"}`, http.StatusInternalServerError)
+			return
+		}
 
 		// 4. Delete Workout (The Parent - with Ownership Check)
 		res, err := tx.Exec(`DELETE FROM workouts WHERE id = ? AND user_id = ?`, workoutID, userID)
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CalendarFeedDays is how far back the calendar feed lists open workouts, so
+// today's workouts stay visible after their start time
+const CalendarFeedDays = 1
+
+// CalendarEventDuration is the length of a workout in calendar apps
+const CalendarEventDuration = time.Hour
+
+// CalendarTokenResponse is the secret feed address of a user's calendar
+type CalendarTokenResponse struct {
+	Token   string `json:"token"`
+	FeedURL string `json:"feed_url"`
+}
+
+// CalendarEvent is one workout in the calendar feed
+type CalendarEvent struct {
+	UID         string
+	Start       time.Time
+	Duration    time.Duration
+	Summary     string
+	Description string
+	Updated     time.Time
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// WriteICalendar writes events as an iCalendar (RFC 5545) document
+func WriteICalendar(w io.Writer, name string, events []CalendarEvent) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//roadmap-go-projects//Fitness Workout Tracker//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + EscapeICalText(name),
+	}
+	for _, e := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+e.UID,
+			"DTSTAMP:"+e.Updated.UTC().Format(icalTimeFormat),
+			"DTSTART:"+e.Start.UTC().Format(icalTimeFormat),
+			"DTEND:"+e.Start.Add(e.Duration).UTC().Format(icalTimeFormat),
+			"SUMMARY:"+EscapeICalText(e.Summary),
+		)
+		if e.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+EscapeICalText(e.Description))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, FoldICalLine(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EscapeICalText escapes a TEXT value: backslashes, semicolons, commas and newlines
+func EscapeICalText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return r.Replace(s)
+}
+
+// FoldICalLine splits a content line into lines of at most 75 octets, each
+// continuation starting with a space. Multi-byte characters are not split.
+func FoldICalLine(line string) string {
+	const limit = 75
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+// WorkoutEventDescription lists the planned exercises of a workout for its calendar event
+func WorkoutEventDescription(description string, exercises []WorkoutExerciseResponse) string {
+	var lines []string
+	if description != "" {
+		lines = append(lines, description, "")
+	}
+	for _, ex := range exercises {
+		line := fmt.Sprintf("%s: %d x %d", ex.Exercise.Name, ex.Sets, ex.Reps)
+		if ex.Weight > 0 {
+			line += fmt.Sprintf(" @ %g kg", ex.Weight)
+		}
+		if ex.Notes != "" {
+			line += " (" + ex.Notes + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
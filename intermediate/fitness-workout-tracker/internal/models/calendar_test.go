@@ -0,0 +1,63 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapeICalText(t *testing.T) {
+	got := EscapeICalText("a\\b;c,d\r\ne\nf")
+	if want := `a\\b\;c\,d\ne\nf`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFoldICalLine(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 70)
+	folded := FoldICalLine(line)
+	for _, part := range strings.Split(folded, "\r\n") {
+		if len(part) > 75 {
+			t.Errorf("line of %d octets: %q", len(part), part)
+		}
+	}
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != line {
+		t.Errorf("unfolding changed the line: %q", unfolded)
+	}
+	if short := "SUMMARY:Leg Day"; FoldICalLine(short) != short {
+		t.Errorf("short lines should not be folded")
+	}
+}
+
+func TestWriteICalendar(t *testing.T) {
+	start := time.Date(2024, 5, 6, 7, 0, 0, 0, time.UTC)
+	var b strings.Builder
+	err := WriteICalendar(&b, "Workouts", []CalendarEvent{
+		{UID: "workout-1@test", Start: start, Duration: time.Hour, Summary: "Push, heavy", Updated: start},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ics := b.String()
+	for _, want := range []string{"BEGIN:VEVENT\r\n", "DTSTART:20240506T070000Z\r\n", "DTEND:20240506T080000Z\r\n", "SUMMARY:Push\\, heavy\r\n"} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("missing %q in:\n%s", want, ics)
+		}
+	}
+	if strings.Contains(ics, "DESCRIPTION") {
+		t.Errorf("empty description should be left out")
+	}
+}
+
+func TestWorkoutEventDescription(t *testing.T) {
+	got := WorkoutEventDescription("Heavy day", []WorkoutExerciseResponse{
+		{Sets: 5, Reps: 5, Weight: 102.5, Exercise: Exercise{Name: "Squat"}},
+		{Sets: 3, Reps: 10, Notes: "slow", Exercise: Exercise{Name: "Push-up"}},
+	})
+	if want := "Heavy day\n\nSquat: 5 x 5 @ 102.5 kg\nPush-up: 3 x 10 (slow)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := WorkoutEventDescription("", nil); got != "" {
+		t.Errorf("expected an empty description, got %q", got)
+	}
+}
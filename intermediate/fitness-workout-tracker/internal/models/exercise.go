@@ -1,13 +1,66 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Exercise struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Category    string    `json:"category"`    // e.g., Strength, Cardio, Flexibility
-	MuscleGroup string    `json:"muscleGroup"` // e.g., Chest, Legs, Back
-	Description string    `json:"description"`
-	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
-	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Category    string          `json:"category"`    // e.g., Strength, Cardio, Flexibility
+	MuscleGroup string          `json:"muscleGroup"` // e.g., Chest, Legs, Back
+	Description string          `json:"description"`
+	Custom      bool            `json:"custom"` // a private exercise of the user rather than part of the shared catalog
+	Aliases     []ExerciseAlias `json:"aliases,omitempty"`
+	UpdatedAt   time.Time       `json:"updated_at" bson:"updated_at"`
+	CreatedAt   time.Time       `json:"created_at" bson:"created_at"`
 }
+
+// ExerciseAlias is another name an exercise can be found by, e.g. "Bench" for
+// "Barbell Bench Press". Shared aliases are managed by admins; custom ones are
+// private to the user who added them.
+type ExerciseAlias struct {
+	ID     int    `json:"id"`
+	Alias  string `json:"alias"`
+	Custom bool   `json:"custom"`
+}
+
+// ExerciseRequest represents the incoming JSON for a catalog or custom exercise
+type ExerciseRequest struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	MuscleGroup string `json:"muscleGroup"`
+	Description string `json:"description"`
+}
+
+// Validate checks if the ExerciseRequest is valid
+func (r *ExerciseRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Category = strings.TrimSpace(r.Category)
+	r.MuscleGroup = strings.TrimSpace(r.MuscleGroup)
+	if r.Name == "" || r.Category == "" || r.MuscleGroup == "" {
+		return fmt.Errorf("name, category and muscleGroup are required")
+	}
+	if len(r.Name) > 100 {
+		return fmt.Errorf("name is too long")
+	}
+	return nil
+}
+
+// AliasRequest represents the incoming JSON for a new alias
+type AliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+// MergeExerciseRequest names the exercise a duplicate is merged into
+type MergeExerciseRequest struct {
+	IntoID int `json:"into_id"`
+}
+
+// Exercise list pagination
+const (
+	DefaultExercisePageSize = 50
+	MaxExercisePageSize     = 200
+)
@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestExerciseRequestValidate(t *testing.T) {
+	req := ExerciseRequest{Name: "  Cable Fly ", Category: "Strength", MuscleGroup: "Chest"}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Name != "Cable Fly" {
+		t.Errorf("expected the name to be trimmed, got %q", req.Name)
+	}
+
+	for _, bad := range []ExerciseRequest{
+		{Name: " ", Category: "Strength", MuscleGroup: "Chest"},
+		{Name: "Cable Fly", MuscleGroup: "Chest"},
+		{Name: "Cable Fly", Category: "Strength"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateTemplateRequest represents the incoming JSON for a reusable workout template
+type CreateTemplateRequest struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	Exercises   []WorkoutExerciseRequest `json:"exercises"`
+}
+
+// Validate checks if the CreateTemplateRequest is valid
+func (r CreateTemplateRequest) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("template name is required")
+	}
+	return CreateWorkoutRequest{Name: r.Name, Exercises: r.Exercises}.Validate()
+}
+
+// TemplateResponse represents the outgoing JSON for a workout template
+type TemplateResponse struct {
+	ID          int                       `json:"id"`
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Exercises   []WorkoutExerciseResponse `json:"exercises"`
+}
+
+// CloneTemplateRequest names the copy of a template. The name defaults to "Copy of <name>".
+type CloneTemplateRequest struct {
+	Name string `json:"name"`
+}
+
+// ScheduleTemplateRequest creates a single workout from a template
+type ScheduleTemplateRequest struct {
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+// RecurrenceRule is a subset of the iCalendar RRULE: FREQ=DAILY or FREQ=WEEKLY,
+// an optional INTERVAL and, for weekly rules, BYDAY. Weeks are counted from the
+// program's start date; a weekly rule without BYDAY repeats on the start's weekday.
+// Example: "FREQ=WEEKLY;BYDAY=MO,WE,FR".
+type RecurrenceRule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRecurrenceRule parses a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH"
+func ParseRecurrenceRule(s string) (RecurrenceRule, error) {
+	rule := RecurrenceRule{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return rule, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+			if rule.Freq != "DAILY" && rule.Freq != "WEEKLY" {
+				return rule, fmt.Errorf("unsupported frequency %q (want DAILY or WEEKLY)", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("invalid interval %q", value)
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := weekdayCodes[strings.ToUpper(strings.TrimSpace(code))]
+				if !ok {
+					return rule, fmt.Errorf("invalid weekday %q", code)
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		default:
+			return rule, fmt.Errorf("unsupported recurrence rule part %q", key)
+		}
+	}
+	if rule.Freq == "" {
+		return rule, fmt.Errorf("recurrence rule needs FREQ")
+	}
+	if rule.Freq == "DAILY" && len(rule.ByDay) > 0 {
+		return rule, fmt.Errorf("BYDAY only applies to weekly rules")
+	}
+	return rule, nil
+}
+
+// Matches reports whether the rule falls on day, for a schedule starting on start.
+// Both are calendar days at midnight in the same location.
+func (r RecurrenceRule) Matches(start, day time.Time) bool {
+	if day.Before(start) {
+		return false
+	}
+	days := int(math.Round(day.Sub(start).Hours() / 24))
+	if r.Freq == "DAILY" {
+		return days%r.Interval == 0
+	}
+	if (days/7)%r.Interval != 0 {
+		return false
+	}
+	if len(r.ByDay) == 0 {
+		return day.Weekday() == start.Weekday()
+	}
+	for _, d := range r.ByDay {
+		if d == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// ProgressionRule describes progressive overload for a program. Each week
+// after the first adds WeightIncrement to every planned weight and
+// RepsIncrement to every rep target. Every DeloadEvery-th week (0 = never)
+// lowers that week's weights by DeloadPercent.
+type ProgressionRule struct {
+	WeightIncrement float64 `json:"weight_increment"` // e.g. 2.5 for +2.5kg per week
+	RepsIncrement   int     `json:"reps_increment"`
+	DeloadEvery     int     `json:"deload_every"`
+	DeloadPercent   float64 `json:"deload_percent"` // defaults to 10 when DeloadEvery is set
+}
+
+// Validate checks if the ProgressionRule is valid
+func (p ProgressionRule) Validate() error {
+	if p.WeightIncrement < 0 || p.RepsIncrement < 0 {
+		return fmt.Errorf("progression increments cannot be negative")
+	}
+	if p.DeloadEvery < 0 || p.DeloadPercent < 0 || p.DeloadPercent >= 100 {
+		return fmt.Errorf("invalid deload settings")
+	}
+	return nil
+}
+
+// Apply returns the target of an exercise in the given week (0 for the first week)
+func (p ProgressionRule) Apply(week int, ex WorkoutExerciseRequest) WorkoutExerciseRequest {
+	if ex.Weight > 0 {
+		ex.Weight += p.WeightIncrement * float64(week)
+	}
+	ex.Reps += p.RepsIncrement * week
+	if p.DeloadEvery > 0 && (week+1)%p.DeloadEvery == 0 {
+		percent := p.DeloadPercent
+		if percent == 0 {
+			percent = 10
+		}
+		ex.Weight *= 1 - percent/100
+	}
+	ex.Weight = math.Round(ex.Weight*100) / 100
+	return ex
+}
+
+// ProgramSessionRequest schedules a template inside a program
+type ProgramSessionRequest struct {
+	TemplateID int    `json:"template_id"`
+	Recurrence string `json:"recurrence"` // e.g. FREQ=WEEKLY;BYDAY=MO,TH
+	Time       string `json:"time"`       // time of day, HH:MM (default 07:00)
+}
+
+// CreateProgramRequest represents the incoming JSON for a multi-week program
+type CreateProgramRequest struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Weeks       int                     `json:"weeks"`
+	Progression ProgressionRule         `json:"progression"`
+	Schedule    []ProgramSessionRequest `json:"schedule"`
+}
+
+// MaxProgramWeeks caps the length of a program
+const MaxProgramWeeks = 52
+
+// Validate checks if the CreateProgramRequest is valid
+func (r CreateProgramRequest) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("program name is required")
+	}
+	if r.Weeks < 1 || r.Weeks > MaxProgramWeeks {
+		return fmt.Errorf("weeks must be between 1 and %d", MaxProgramWeeks)
+	}
+	if err := r.Progression.Validate(); err != nil {
+		return err
+	}
+	if len(r.Schedule) == 0 {
+		return fmt.Errorf("at least one scheduled template is required")
+	}
+	for _, s := range r.Schedule {
+		if s.TemplateID <= 0 {
+			return fmt.Errorf("template_id is required")
+		}
+		if _, err := ParseRecurrenceRule(s.Recurrence); err != nil {
+			return err
+		}
+		if s.Time != "" {
+			if _, err := time.Parse("15:04", s.Time); err != nil {
+				return fmt.Errorf("invalid time %q (want HH:MM)", s.Time)
+			}
+		}
+	}
+	return nil
+}
+
+// ProgramSession is a template scheduled inside a program
+type ProgramSession struct {
+	ID           int    `json:"id"`
+	TemplateID   int    `json:"template_id"`
+	TemplateName string `json:"template_name"`
+	Recurrence   string `json:"recurrence"`
+	Time         string `json:"time"`
+}
+
+// ProgramRun is a program applied from a start date
+type ProgramRun struct {
+	ID        int       `json:"id"`
+	ProgramID int       `json:"program_id"`
+	StartDate string    `json:"start_date"`
+	Timezone  string    `json:"timezone"`
+	Workouts  int       `json:"workouts"`
+	Completed int       `json:"completed"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProgramResponse represents the outgoing JSON for a program
+type ProgramResponse struct {
+	ID          int              `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Weeks       int              `json:"weeks"`
+	Progression ProgressionRule  `json:"progression"`
+	Schedule    []ProgramSession `json:"schedule"`
+	Runs        []ProgramRun     `json:"runs"`
+}
+
+// ApplyProgramRequest starts a program on a date
+type ApplyProgramRequest struct {
+	StartDate string `json:"start_date"` // YYYY-MM-DD
+	Timezone  string `json:"timezone"`   // IANA name for the session times, default UTC
+}
+
+// RescheduleRequest moves the missed sessions of a program run
+type RescheduleRequest struct {
+	From string `json:"from"` // YYYY-MM-DD the first missed session moves to, default today
+}
+
+// RescheduleResponse reports how a program run was shifted
+type RescheduleResponse struct {
+	Shifted int `json:"shifted"` // workouts moved
+	Days    int `json:"days"`    // how far they moved
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRecurrenceRule(t *testing.T) {
+	rule, err := ParseRecurrenceRule("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,th")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.Freq != "WEEKLY" || rule.Interval != 2 || len(rule.ByDay) != 2 || rule.ByDay[1] != time.Thursday {
+		t.Fatalf("unexpected rule: %+v", rule)
+	}
+
+	for _, bad := range []string{"", "BYDAY=MO", "FREQ=MONTHLY", "FREQ=WEEKLY;INTERVAL=0", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;BYDAY=MO", "FREQ"} {
+		if _, err := ParseRecurrenceRule(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRecurrenceRuleMatches(t *testing.T) {
+	start := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC) // a Wednesday
+	day := func(offset int) time.Time { return start.AddDate(0, 0, offset) }
+
+	everyOtherMonday, _ := ParseRecurrenceRule("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO")
+	var matched []int
+	for i := -7; i < 28; i++ {
+		if everyOtherMonday.Matches(start, day(i)) {
+			matched = append(matched, i)
+		}
+	}
+	// Mondays in weeks 1 and 3 counted from the start
+	if len(matched) != 2 || matched[0] != 5 || matched[1] != 19 {
+		t.Fatalf("unexpected matches: %v", matched)
+	}
+
+	weekly, _ := ParseRecurrenceRule("FREQ=WEEKLY")
+	if !weekly.Matches(start, day(7)) || weekly.Matches(start, day(8)) {
+		t.Error("weekly rule without BYDAY should repeat on the start's weekday")
+	}
+
+	everyThirdDay, _ := ParseRecurrenceRule("FREQ=DAILY;INTERVAL=3")
+	if !everyThirdDay.Matches(start, day(6)) || everyThirdDay.Matches(start, day(4)) {
+		t.Error("unexpected daily matches")
+	}
+}
+
+func TestProgressionRuleApply(t *testing.T) {
+	rule := ProgressionRule{WeightIncrement: 2.5, RepsIncrement: 1, DeloadEvery: 4, DeloadPercent: 20}
+	base := WorkoutExerciseRequest{ExerciseID: 1, Sets: 3, Reps: 5, Weight: 100}
+
+	if got := rule.Apply(0, base); got.Weight != 100 || got.Reps != 5 {
+		t.Errorf("week 1 should keep the template, got %+v", got)
+	}
+	if got := rule.Apply(2, base); got.Weight != 105 || got.Reps != 7 || got.Sets != 3 {
+		t.Errorf("week 3: got %+v", got)
+	}
+	if got := rule.Apply(3, base); got.Weight != 86 {
+		t.Errorf("deload week: got %+v", got)
+	}
+
+	// Bodyweight exercises gain reps but no weight
+	if got := rule.Apply(2, WorkoutExerciseRequest{Sets: 3, Reps: 10}); got.Weight != 0 || got.Reps != 12 {
+		t.Errorf("bodyweight: got %+v", got)
+	}
+}
+
+func TestCreateProgramRequestValidate(t *testing.T) {
+	valid := CreateProgramRequest{
+		Name:     "Block",
+		Weeks:    12,
+		Schedule: []ProgramSessionRequest{{TemplateID: 1, Recurrence: "FREQ=WEEKLY;BYDAY=MO,TH", Time: "18:30"}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tooLong := valid
+	tooLong.Weeks = MaxProgramWeeks + 1
+	badTime := valid
+	badTime.Schedule = []ProgramSessionRequest{{TemplateID: 1, Recurrence: "FREQ=DAILY", Time: "25:00"}}
+	noSchedule := valid
+	noSchedule.Schedule = nil
+	negative := valid
+	negative.Progression.WeightIncrement = -1
+	for name, req := range map[string]CreateProgramRequest{"weeks": tooLong, "time": badTime, "schedule": noSchedule, "progression": negative} {
+		if err := req.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Session statuses
+const (
+	SessionInProgress = "in_progress"
+	SessionCompleted  = "completed"
+	SessionSkipped    = "skipped"
+)
+
+// WorkoutSession records what was actually performed for a planned workout
+type WorkoutSession struct {
+	ID         int          `json:"id"`
+	WorkoutID  int          `json:"workout_id"`
+	Status     string       `json:"status"` // in_progress, completed or skipped
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	Notes      string       `json:"notes"`
+	Sets       []SessionSet `json:"sets"`
+}
+
+// SessionSet is one set actually performed during a session
+type SessionSet struct {
+	ID           int      `json:"id"`
+	ExerciseID   int      `json:"exercise_id"`
+	ExerciseName string   `json:"exercise_name"`
+	SetNumber    int      `json:"set_number"` // 1-based, per exercise
+	Reps         int      `json:"reps"`
+	Weight       float64  `json:"weight"`
+	RPE          *float64 `json:"rpe,omitempty"`
+	RestSeconds  *int     `json:"rest_seconds,omitempty"`
+	Notes        string   `json:"notes"`
+}
+
+// LogSetRequest represents the incoming JSON for a performed set
+type LogSetRequest struct {
+	ExerciseID  int      `json:"exercise_id"`
+	Reps        int      `json:"reps"`
+	Weight      float64  `json:"weight"`
+	RPE         *float64 `json:"rpe"`
+	RestSeconds *int     `json:"rest_seconds"`
+	Notes       string   `json:"notes"`
+}
+
+// Validate checks if the LogSetRequest is valid
+func (r LogSetRequest) Validate() error {
+	if r.ExerciseID <= 0 {
+		return fmt.Errorf("exercise_id is required")
+	}
+	if r.Reps <= 0 || r.Weight < 0 {
+		return fmt.Errorf("invalid reps or weight values")
+	}
+	if r.RPE != nil && (*r.RPE < 1 || *r.RPE > 10) {
+		return fmt.Errorf("rpe must be between 1 and 10")
+	}
+	if r.RestSeconds != nil && *r.RestSeconds < 0 {
+		return fmt.Errorf("rest_seconds cannot be negative")
+	}
+	return nil
+}
+
+// FinishSessionRequest represents the optional JSON when completing or skipping a session
+type FinishSessionRequest struct {
+	Notes string `json:"notes"`
+}
+
+// PlannedSummary is the target of one exercise in a planned workout
+type PlannedSummary struct {
+	Sets   int     `json:"sets"`
+	Reps   int     `json:"reps"` // per set
+	Weight float64 `json:"weight"`
+	Volume float64 `json:"volume"`
+}
+
+// ActualSummary sums up the sets logged for one exercise
+type ActualSummary struct {
+	Sets       int      `json:"sets"`
+	Reps       int      `json:"reps"` // total over all sets
+	MaxWeight  float64  `json:"max_weight"`
+	Volume     float64  `json:"volume"`
+	AverageRPE *float64 `json:"average_rpe,omitempty"`
+}
+
+// ExerciseComparison compares the plan and the logged sets for one exercise.
+// Exercises logged without being planned have an empty plan.
+type ExerciseComparison struct {
+	ExerciseID   int            `json:"exercise_id"`
+	Name         string         `json:"name"`
+	Planned      PlannedSummary `json:"planned"`
+	Actual       ActualSummary  `json:"actual"`
+	SetsDiff     int            `json:"sets_diff"`     // actual minus planned
+	VolumeDiff   float64        `json:"volume_diff"`   // actual minus planned
+	CompletedPct float64        `json:"completed_pct"` // actual volume / planned volume, 0 without a planned volume
+}
+
+// WorkoutComparison is the planned-vs-actual view of a workout
+type WorkoutComparison struct {
+	WorkoutID     int                  `json:"workout_id"`
+	Name          string               `json:"name"`
+	ScheduledFor  time.Time            `json:"scheduled_for"`
+	Status        string               `json:"status"` // the session status, or "planned" without a session
+	Exercises     []ExerciseComparison `json:"exercises"`
+	PlannedVolume float64              `json:"planned_volume"`
+	ActualVolume  float64              `json:"actual_volume"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestLogSetRequest_Validate(t *testing.T) {
+	rpe, rest := 8.5, 90
+	valid := LogSetRequest{ExerciseID: 1, Reps: 5, Weight: 100, RPE: &rpe, RestSeconds: &rest}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid request, got error: %v", err)
+	}
+
+	bodyweight := LogSetRequest{ExerciseID: 1, Reps: 12}
+	if err := bodyweight.Validate(); err != nil {
+		t.Fatalf("expected bodyweight set to be valid, got error: %v", err)
+	}
+
+	noExercise := valid
+	noExercise.ExerciseID = 0
+	if err := noExercise.Validate(); err == nil {
+		t.Fatalf("expected error for missing exercise")
+	}
+
+	noReps := valid
+	noReps.Reps = 0
+	if err := noReps.Validate(); err == nil {
+		t.Fatalf("expected error for zero reps")
+	}
+
+	badRPE := valid
+	tooHard := 11.0
+	badRPE.RPE = &tooHard
+	if err := badRPE.Validate(); err == nil {
+		t.Fatalf("expected error for rpe above 10")
+	}
+
+	badRest := valid
+	negative := -1
+	badRest.RestSeconds = &negative
+	if err := badRest.Validate(); err == nil {
+		t.Fatalf("expected error for negative rest")
+	}
+}
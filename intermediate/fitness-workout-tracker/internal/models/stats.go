@@ -0,0 +1,130 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// EstimateOneRepMax estimates the one-rep max of a set with the Epley formula,
+// weight × (1 + reps/30). A single rep is its own max. Sets without weight or
+// reps have no estimate.
+func EstimateOneRepMax(weight float64, reps int) float64 {
+	if weight <= 0 || reps <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return weight
+	}
+	return math.Round(weight*(1+float64(reps)/30)*10) / 10
+}
+
+// WeekStart returns midnight UTC on the Monday of t's week
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// MuscleGroups splits a combined muscle group such as "Back/Legs" into its parts
+func MuscleGroups(group string) []string {
+	var groups []string
+	for _, g := range strings.Split(group, "/") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// ExerciseRecord holds a user's best performances for one exercise
+type ExerciseRecord struct {
+	ExerciseID    int       `json:"exercise_id"`
+	Name          string    `json:"name"`
+	MuscleGroup   string    `json:"muscle_group"`
+	EstimatedMax  float64   `json:"estimated_1rm"`
+	EstimatedDate time.Time `json:"estimated_1rm_date"`
+	BestSetWeight float64   `json:"best_set_weight"` // the set behind the estimated 1RM
+	BestSetReps   int       `json:"best_set_reps"`
+	MaxWeight     float64   `json:"max_weight"`
+	MaxWeightDate time.Time `json:"max_weight_date"`
+	MaxVolume     float64   `json:"max_volume"` // most sets × reps × weight in one workout
+	MaxVolumeDate time.Time `json:"max_volume_date"`
+	Workouts      int       `json:"workouts"`
+}
+
+// PersonalRecord is a workout in which an exercise beat its previous best
+type PersonalRecord struct {
+	ExerciseID int       `json:"exercise_id"`
+	Name       string    `json:"name"`
+	WorkoutID  int       `json:"workout_id"`
+	Date       time.Time `json:"date"`
+	Type       string    `json:"type"` // estimated_1rm, max_weight or max_volume
+	Value      float64   `json:"value"`
+	Previous   float64   `json:"previous"`
+}
+
+// Personal record types
+const (
+	RecordEstimatedMax = "estimated_1rm"
+	RecordMaxWeight    = "max_weight"
+	RecordMaxVolume    = "max_volume"
+)
+
+// RecordsResponse is the response of GET /stats/records
+type RecordsResponse struct {
+	Records   []ExerciseRecord `json:"records"`
+	RecentPRs []PersonalRecord `json:"recent_prs"` // newest first
+}
+
+// WeeklyVolume is the training volume of one week, in total and per muscle group.
+// An exercise that works several groups counts fully towards each of them.
+type WeeklyVolume struct {
+	WeekStart    time.Time          `json:"week_start"`
+	Workouts     int                `json:"workouts"`
+	Total        float64            `json:"total"`
+	MuscleGroups map[string]float64 `json:"muscle_groups"`
+}
+
+// StreaksResponse is the response of GET /stats/streaks. A streak is a run of
+// consecutive days or weeks with at least one completed workout; the current
+// one may end yesterday or last week, since today's workout may still be ahead.
+type StreaksResponse struct {
+	CurrentDays  int        `json:"current_days"`
+	LongestDays  int        `json:"longest_days"`
+	CurrentWeeks int        `json:"current_weeks"`
+	LongestWeeks int        `json:"longest_weeks"`
+	LastWorkout  *time.Time `json:"last_workout,omitempty"`
+}
+
+// AdherenceResponse compares scheduled and completed workouts in a date range.
+// Only workouts whose scheduled time has passed count as due, unless they
+// were completed or skipped early. Skipped workouts count as missed.
+type AdherenceResponse struct {
+	StartDate string  `json:"start_date"`
+	EndDate   string  `json:"end_date"`
+	Due       int     `json:"due"`
+	Completed int     `json:"completed"`
+	Missed    int     `json:"missed"`
+	Skipped   int     `json:"skipped"`
+	Upcoming  int     `json:"upcoming"`
+	Adherence float64 `json:"adherence_pct"` // completed / due, 0 when nothing was due
+}
+
+// ProgressionPoint is one workout in an exercise's progression chart
+type ProgressionPoint struct {
+	WorkoutID    int       `json:"workout_id"`
+	Date         time.Time `json:"date"`
+	EstimatedMax float64   `json:"estimated_1rm"`
+	MaxWeight    float64   `json:"max_weight"`
+	Sets         int       `json:"sets"`
+	Reps         int       `json:"reps"`
+	Volume       float64   `json:"volume"`
+}
+
+// ProgressionResponse is the response of GET /stats/progression
+type ProgressionResponse struct {
+	ExerciseID int                `json:"exercise_id"`
+	Name       string             `json:"name"`
+	Points     []ProgressionPoint `json:"points"` // oldest first
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEstimateOneRepMax(t *testing.T) {
+	cases := []struct {
+		weight float64
+		reps   int
+		want   float64
+	}{
+		{100, 1, 100},
+		{100, 5, 116.7},
+		{60, 10, 80},
+		{0, 10, 0},
+		{100, 0, 0},
+	}
+	for _, c := range cases {
+		if got := EstimateOneRepMax(c.weight, c.reps); got != c.want {
+			t.Errorf("EstimateOneRepMax(%v, %d) = %v, want %v", c.weight, c.reps, got, c.want)
+		}
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	for _, day := range []time.Time{
+		monday,
+		time.Date(2026, 10, 21, 18, 30, 0, 0, time.UTC),
+		time.Date(2026, 10, 25, 23, 59, 0, 0, time.UTC), // Sunday
+	} {
+		if got := WeekStart(day); !got.Equal(monday) {
+			t.Errorf("WeekStart(%v) = %v, want %v", day, got, monday)
+		}
+	}
+	if got := WeekStart(time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC)); got.Equal(monday) {
+		t.Fatalf("next Monday should start a new week")
+	}
+}
+
+func TestMuscleGroups(t *testing.T) {
+	if got := MuscleGroups("Back/Legs"); !reflect.DeepEqual(got, []string{"Back", "Legs"}) {
+		t.Fatalf("MuscleGroups(Back/Legs) = %v", got)
+	}
+	if got := MuscleGroups("Chest"); !reflect.DeepEqual(got, []string{"Chest"}) {
+		t.Fatalf("MuscleGroups(Chest) = %v", got)
+	}
+}
@@ -0,0 +1,481 @@
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Import formats
+const (
+	FormatTracker = "tracker" // this tracker's own CSV or JSON export
+	FormatStrong  = "strong"  // CSV export of the Strong app
+	FormatHevy    = "hevy"    // CSV export of the Hevy app
+)
+
+// ExportVersion is the version of the export format
+const ExportVersion = 1
+
+// ExportData is a full export of a user's workouts. It is also the JSON import format.
+type ExportData struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Workouts   []ExportWorkout `json:"workouts"`
+}
+
+// ExportWorkout is a workout with its plan and the sets logged for it.
+// Exercises are referred to by name so exports can move between installations.
+type ExportWorkout struct {
+	Name         string           `json:"name"`
+	Description  string           `json:"description"`
+	ScheduledFor time.Time        `json:"scheduled_for"`
+	CompletedAt  *time.Time       `json:"completed_at,omitempty"`
+	Status       string           `json:"status,omitempty"` // session status, empty without a session
+	Exercises    []ExportExercise `json:"exercises"`
+	Sets         []ExportSet      `json:"sets"`
+}
+
+// ExportExercise is a planned exercise of a workout
+type ExportExercise struct {
+	Row      int     `json:"-"` // source line of an import, for error reports
+	Exercise string  `json:"exercise"`
+	Sets     int     `json:"sets"`
+	Reps     int     `json:"reps"`
+	Weight   float64 `json:"weight"`
+	Notes    string  `json:"notes"`
+}
+
+// ExportSet is a logged set of a workout
+type ExportSet struct {
+	Row         int      `json:"-"`
+	Exercise    string   `json:"exercise"`
+	SetNumber   int      `json:"set_number"`
+	Reps        int      `json:"reps"`
+	Weight      float64  `json:"weight"`
+	RPE         *float64 `json:"rpe,omitempty"`
+	RestSeconds *int     `json:"rest_seconds,omitempty"`
+	Notes       string   `json:"notes"`
+}
+
+// ImportIssue is a row that was not imported
+type ImportIssue struct {
+	Row      int    `json:"row"` // line in the CSV file, 0 for JSON
+	Workout  string `json:"workout"`
+	Exercise string `json:"exercise,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// ImportResult reports what an import created and what it left out
+type ImportResult struct {
+	Format             string        `json:"format"`
+	DryRun             bool          `json:"dry_run"`
+	Workouts           int           `json:"workouts"`   // workouts created
+	Planned            int           `json:"planned"`    // planned exercises created
+	Sets               int           `json:"sets"`       // logged sets created
+	Duplicates         int           `json:"duplicates"` // workouts already present, not imported again
+	Unmatched          []ImportIssue `json:"unmatched"`  // rows whose exercise is not in the catalog
+	UnmatchedExercises []string      `json:"unmatched_exercises"`
+	Skipped            []ImportIssue `json:"skipped"` // rows that could not be read
+}
+
+// MaxImportBytes limits the size of an uploaded import
+const MaxImportBytes = 10 << 20
+
+// trackerCSVHeader is the header of the tracker's CSV export. Each row is
+// either a planned exercise ("planned") or a logged set ("set"); rows of the
+// same workout share the workout number.
+var trackerCSVHeader = []string{
+	"workout", "name", "description", "scheduled_for", "completed_at", "status",
+	"type", "exercise", "set_number", "sets", "reps", "weight", "rpe", "rest_seconds", "notes",
+}
+
+// WriteTrackerCSV writes an export as CSV
+func WriteTrackerCSV(w io.Writer, data *ExportData) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(trackerCSVHeader); err != nil {
+		return err
+	}
+	for i, wo := range data.Workouts {
+		completedAt := ""
+		if wo.CompletedAt != nil {
+			completedAt = wo.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		prefix := []string{strconv.Itoa(i + 1), csvText(wo.Name), csvText(wo.Description), wo.ScheduledFor.UTC().Format(time.RFC3339), completedAt, wo.Status}
+		for _, ex := range wo.Exercises {
+			row := append(append([]string{}, prefix...), "planned", csvText(ex.Exercise), "",
+				strconv.Itoa(ex.Sets), strconv.Itoa(ex.Reps), formatFloat(ex.Weight), "", "", csvText(ex.Notes))
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		for _, set := range wo.Sets {
+			rpe, rest := "", ""
+			if set.RPE != nil {
+				rpe = formatFloat(*set.RPE)
+			}
+			if set.RestSeconds != nil {
+				rest = strconv.Itoa(*set.RestSeconds)
+			}
+			row := append(append([]string{}, prefix...), "set", csvText(set.Exercise), strconv.Itoa(set.SetNumber),
+				"", strconv.Itoa(set.Reps), formatFloat(set.Weight), rpe, rest, csvText(set.Notes))
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		// Keep workouts without exercises or sets
+		if len(wo.Exercises) == 0 && len(wo.Sets) == 0 {
+			row := append(append([]string{}, prefix...), "", "", "", "", "", "", "", "", "")
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formulaPrefixes are the first characters that make a spreadsheet read a
+// cell as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// csvText keeps user text such as "=HYPERLINK(...)" from running as a
+// formula when the export is opened in a spreadsheet, by prefixing it with a
+// quote. Importing the tracker's CSV removes the quote again.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvUntext undoes csvText
+func csvUntext(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ParseImportCSV reads a CSV export of this tracker, Strong or Hevy. format
+// may be empty to detect it from the header. Times without a zone are read in
+// loc. Rows that cannot be read are returned as issues.
+func ParseImportCSV(data []byte, format string, loc *time.Location) (string, []ExportWorkout, []ImportIssue, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	// Older Strong exports are separated by semicolons
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	r := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return "", nil, nil, fmt.Errorf("empty CSV")
+	}
+
+	cols := newCSVColumns(records[0])
+	if format == "" {
+		format = detectCSVFormat(cols)
+		if format == "" {
+			return "", nil, nil, fmt.Errorf("unrecognized CSV header")
+		}
+	}
+
+	var workouts []ExportWorkout
+	var issues []ImportIssue
+	switch format {
+	case FormatTracker:
+		workouts, issues = parseTrackerCSV(records, cols)
+	case FormatStrong:
+		workouts, issues = parseStrongCSV(records, cols, loc)
+	case FormatHevy:
+		workouts, issues = parseHevyCSV(records, cols, loc)
+	default:
+		return "", nil, nil, fmt.Errorf("unknown format %q", format)
+	}
+	return format, workouts, issues, nil
+}
+
+// csvColumns maps lower-cased header names to their column
+type csvColumns map[string]int
+
+func newCSVColumns(header []string) csvColumns {
+	cols := make(csvColumns, len(header))
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	return cols
+}
+
+func (c csvColumns) has(names ...string) bool {
+	for _, n := range names {
+		if _, ok := c[n]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the trimmed value of a column, or "" when the row or header lacks it
+func (c csvColumns) get(record []string, name string) string {
+	i, ok := c[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func detectCSVFormat(cols csvColumns) string {
+	switch {
+	case cols.has("workout", "type", "exercise", "scheduled_for"):
+		return FormatTracker
+	case cols.has("exercise_title", "start_time", "set_index"):
+		return FormatHevy
+	case cols.has("date", "workout name", "exercise name", "reps"):
+		return FormatStrong
+	}
+	return ""
+}
+
+// parseNumber reads an optional number; empty means zero
+func parseNumber(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+}
+
+// parseTime tries each layout in turn
+func parseTime(s string, loc *time.Location, layouts ...string) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+const lbsToKg = 0.45359237
+
+func roundWeight(kg float64) float64 {
+	return math.Round(kg*100) / 100
+}
+
+func parseTrackerCSV(records [][]string, cols csvColumns) ([]ExportWorkout, []ImportIssue) {
+	var workouts []ExportWorkout
+	var issues []ImportIssue
+	index := make(map[string]int)
+
+	for i, rec := range records[1:] {
+		line := i + 2
+		key := cols.get(rec, "workout")
+		name := csvUntext(cols.get(rec, "name"))
+		w, ok := index[key]
+		if !ok {
+			scheduled, err := parseTime(cols.get(rec, "scheduled_for"), time.UTC, time.RFC3339)
+			if err != nil || name == "" {
+				issues = append(issues, ImportIssue{Row: line, Workout: name, Reason: "invalid workout name or scheduled_for"})
+				continue
+			}
+			wo := ExportWorkout{Name: name, Description: csvUntext(cols.get(rec, "description")), ScheduledFor: scheduled, Status: cols.get(rec, "status")}
+			if completed := cols.get(rec, "completed_at"); completed != "" {
+				if t, err := parseTime(completed, time.UTC, time.RFC3339); err == nil {
+					wo.CompletedAt = &t
+				}
+			}
+			workouts = append(workouts, wo)
+			w = len(workouts) - 1
+			index[key] = w
+		}
+
+		exercise := csvUntext(cols.get(rec, "exercise"))
+		notes := csvUntext(cols.get(rec, "notes"))
+		sets, err1 := parseNumber(cols.get(rec, "sets"))
+		reps, err2 := parseNumber(cols.get(rec, "reps"))
+		weight, err3 := parseNumber(cols.get(rec, "weight"))
+		rpe, err4 := parseNumber(cols.get(rec, "rpe"))
+		rest, err5 := parseNumber(cols.get(rec, "rest_seconds"))
+		invalid := err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil || weight < 0
+
+		switch rowType := cols.get(rec, "type"); {
+		case rowType == "" && exercise == "":
+			// A workout without exercises
+		case invalid || exercise == "" || reps <= 0:
+			issues = append(issues, ImportIssue{Row: line, Workout: name, Exercise: exercise, Reason: "invalid exercise row"})
+		case rowType == "planned":
+			if sets <= 0 {
+				issues = append(issues, ImportIssue{Row: line, Workout: name, Exercise: exercise, Reason: "invalid exercise row"})
+				continue
+			}
+			workouts[w].Exercises = append(workouts[w].Exercises, ExportExercise{
+				Row: line, Exercise: exercise, Sets: int(sets), Reps: int(reps), Weight: weight, Notes: notes,
+			})
+		case rowType == "set":
+			set := ExportSet{Row: line, Exercise: exercise, Reps: int(reps), Weight: weight, Notes: notes}
+			if cols.get(rec, "rpe") != "" {
+				set.RPE = &rpe
+			}
+			if cols.get(rec, "rest_seconds") != "" {
+				n := int(rest)
+				set.RestSeconds = &n
+			}
+			workouts[w].Sets = append(workouts[w].Sets, set)
+		default:
+			issues = append(issues, ImportIssue{Row: line, Workout: name, Exercise: exercise, Reason: "unknown row type"})
+		}
+	}
+	return workouts, issues
+}
+
+// appendLoggedSet adds a set to the workout of a third-party export, which
+// only records performed sets. Rows without reps (timed or distance
+// exercises) cannot be stored as sets.
+func appendLoggedSet(workouts []ExportWorkout, index map[string]int, key string, wo ExportWorkout, set ExportSet, issues []ImportIssue) ([]ExportWorkout, []ImportIssue) {
+	if set.Reps <= 0 {
+		return workouts, append(issues, ImportIssue{Row: set.Row, Workout: wo.Name, Exercise: set.Exercise, Reason: "no reps (timed or distance sets are not supported)"})
+	}
+	w, ok := index[key]
+	if !ok {
+		workouts = append(workouts, wo)
+		w = len(workouts) - 1
+		index[key] = w
+	}
+	workouts[w].Sets = append(workouts[w].Sets, set)
+	return workouts, issues
+}
+
+func parseStrongCSV(records [][]string, cols csvColumns, loc *time.Location) ([]ExportWorkout, []ImportIssue) {
+	var workouts []ExportWorkout
+	var issues []ImportIssue
+	index := make(map[string]int)
+
+	for i, rec := range records[1:] {
+		line := i + 2
+		name := cols.get(rec, "workout name")
+		exercise := cols.get(rec, "exercise name")
+		date, err := parseTime(cols.get(rec, "date"), loc, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02")
+		if err != nil || name == "" || exercise == "" {
+			issues = append(issues, ImportIssue{Row: line, Workout: name, Exercise: exercise, Reason: "invalid date, workout or exercise"})
+			continue
+		}
+		weight, err1 := parseNumber(cols.get(rec, "weight"))
+		reps, err2 := parseNumber(cols.get(rec, "reps"))
+		rpe, err3 := parseNumber(cols.get(rec, "rpe"))
+		if err1 != nil || err2 != nil || err3 != nil || weight < 0 {
+			issues = append(issues, ImportIssue{Row: line, Workout: name, Exercise: exercise, Reason: "invalid weight, reps or RPE"})
+			continue
+		}
+		if strings.EqualFold(cols.get(rec, "weight unit"), "lbs") {
+			weight = roundWeight(weight * lbsToKg)
+		}
+
+		set := ExportSet{Row: line, Exercise: exercise, Reps: int(reps), Weight: weight, Notes: cols.get(rec, "notes")}
+		if rpe > 0 {
+			set.RPE = &rpe
+		}
+		completed := date
+		wo := ExportWorkout{Name: name, Description: cols.get(rec, "workout notes"), ScheduledFor: date, CompletedAt: &completed, Status: SessionCompleted}
+		workouts, issues = appendLoggedSet(workouts, index, cols.get(rec, "date")+"|"+name, wo, set, issues)
+	}
+	return workouts, issues
+}
+
+func parseHevyCSV(records [][]string, cols csvColumns, loc *time.Location) ([]ExportWorkout, []ImportIssue) {
+	layouts := []string{"2 Jan 2006, 15:04", "02 Jan 2006, 15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339}
+	var workouts []ExportWorkout
+	var issues []ImportIssue
+	index := make(map[string]int)
+
+	for i, rec := range records[1:] {
+		line := i + 2
+		name := cols.get(rec, "title")
+		exercise := cols.get(rec, "exercise_title")
+		start, err := parseTime(cols.get(rec, "start_time"), loc, layouts...)
+		if err != nil || name == "" || exercise == "" {
+			issues = append(issues, ImportIssue{Row: line, Workout: name, Exercise: exercise, Reason: "invalid start_time, title or exercise"})
+			continue
+		}
+		completed := start
+		if end, err := parseTime(cols.get(rec, "end_time"), loc, layouts...); err == nil {
+			completed = end
+		}
+
+		weight, err1 := parseNumber(cols.get(rec, "weight_kg"))
+		if cols.has("weight_lbs") {
+			lbs, err := parseNumber(cols.get(rec, "weight_lbs"))
+			weight, err1 = roundWeight(lbs*lbsToKg), err
+		}
+		reps, err2 := parseNumber(cols.get(rec, "reps"))
+		rpe, err3 := parseNumber(cols.get(rec, "rpe"))
+		if err1 != nil || err2 != nil || err3 != nil || weight < 0 {
+			issues = append(issues, ImportIssue{Row: line, Workout: name, Exercise: exercise, Reason: "invalid weight, reps or RPE"})
+			continue
+		}
+
+		notes := cols.get(rec, "exercise_notes")
+		if setType := cols.get(rec, "set_type"); setType != "" && setType != "normal" {
+			notes = strings.TrimSpace(setType + " " + notes)
+		}
+		set := ExportSet{Row: line, Exercise: exercise, Reps: int(reps), Weight: weight, Notes: notes}
+		if rpe > 0 {
+			set.RPE = &rpe
+		}
+		wo := ExportWorkout{Name: name, Description: cols.get(rec, "description"), ScheduledFor: start, CompletedAt: &completed, Status: SessionCompleted}
+		workouts, issues = appendLoggedSet(workouts, index, cols.get(rec, "start_time")+"|"+name, wo, set, issues)
+	}
+	return workouts, issues
+}
+
+// PlanFromSets derives the plan of a workout that only has logged sets: one
+// entry per exercise in order of appearance, with the number of sets and the
+// reps and weight of its heaviest set.
+func PlanFromSets(sets []ExportSet) []ExportExercise {
+	var plan []ExportExercise
+	index := make(map[string]int)
+	for _, set := range sets {
+		i, ok := index[set.Exercise]
+		if !ok {
+			plan = append(plan, ExportExercise{Row: set.Row, Exercise: set.Exercise})
+			i = len(plan) - 1
+			index[set.Exercise] = i
+		}
+		p := &plan[i]
+		p.Sets++
+		if p.Sets == 1 || set.Weight > p.Weight || (set.Weight == p.Weight && set.Reps > p.Reps) {
+			p.Weight, p.Reps = set.Weight, set.Reps
+		}
+	}
+	return plan
+}
+
+// CandidateExerciseNames lists the names an imported exercise may have in the
+// catalog, best match first. Apps such as Strong and Hevy name the equipment
+// in parentheses: "Bench Press (Barbell)" is tried as itself, as
+// "Barbell Bench Press" and as "Bench Press".
+func CandidateExerciseNames(name string) []string {
+	name = strings.Join(strings.Fields(name), " ")
+	names := []string{name}
+	open := strings.LastIndex(name, "(")
+	if open > 0 && strings.HasSuffix(name, ")") {
+		base := strings.TrimSpace(name[:open])
+		equipment := strings.TrimSpace(name[open+1 : len(name)-1])
+		if equipment != "" {
+			names = append(names, equipment+" "+base)
+		}
+		names = append(names, base)
+	}
+	return names
+}
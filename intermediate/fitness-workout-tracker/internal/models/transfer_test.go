@@ -0,0 +1,102 @@
+package models
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCandidateExerciseNames(t *testing.T) {
+	got := CandidateExerciseNames("Bench  Press (Barbell)")
+	want := []string{"Bench Press (Barbell)", "Barbell Bench Press", "Bench Press"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := CandidateExerciseNames("Squat"); !reflect.DeepEqual(got, []string{"Squat"}) {
+		t.Errorf("got %q for a plain name", got)
+	}
+}
+
+func TestPlanFromSets(t *testing.T) {
+	plan := PlanFromSets([]ExportSet{
+		{Exercise: "Squat", Reps: 5, Weight: 100},
+		{Exercise: "Bench", Reps: 8, Weight: 60},
+		{Exercise: "Squat", Reps: 3, Weight: 110},
+		{Exercise: "Squat", Reps: 4, Weight: 110},
+	})
+	if len(plan) != 2 || plan[0].Exercise != "Squat" || plan[1].Exercise != "Bench" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if plan[0].Sets != 3 || plan[0].Weight != 110 || plan[0].Reps != 4 {
+		t.Errorf("expected 3 sets of the heaviest set, got %+v", plan[0])
+	}
+}
+
+func TestParseImportCSVStrongSemicolons(t *testing.T) {
+	data := "\xef\xbb\xbfDate;Workout Name;Exercise Name;Set Order;Weight;Weight Unit;Reps;RPE\n" +
+		"2024-03-04 07:30:00;Legs;Squat (Barbell);1;225;lbs;5;\n" +
+		"2024-03-04 07:30:00;Legs;Squat (Barbell);2;bad;lbs;5;\n" +
+		"2024-03-05 07:30:00;Legs;Squat (Barbell);1;100;kg;5;7,5\n"
+	format, workouts, issues, err := ParseImportCSV([]byte(data), "", time.UTC)
+	if err != nil || format != FormatStrong {
+		t.Fatalf("got format %q, err %v", format, err)
+	}
+	if len(workouts) != 2 || len(issues) != 1 || issues[0].Row != 3 {
+		t.Fatalf("unexpected result: %+v %+v", workouts, issues)
+	}
+	if w := workouts[0].Sets[0].Weight; w != 102.06 {
+		t.Errorf("expected pounds converted to kg, got %v", w)
+	}
+	if rpe := workouts[1].Sets[0].RPE; rpe == nil || *rpe != 7.5 {
+		t.Errorf("expected RPE with a decimal comma, got %v", rpe)
+	}
+}
+
+func TestTrackerCSVRoundTrip(t *testing.T) {
+	completed := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+	rpe, rest := 8.5, 90
+	data := &ExportData{Workouts: []ExportWorkout{
+		{
+			Name: "Push, heavy", Description: "Line \"one\"", ScheduledFor: completed.Add(-time.Hour), CompletedAt: &completed, Status: SessionCompleted,
+			Exercises: []ExportExercise{{Exercise: "Barbell Bench Press", Sets: 3, Reps: 5, Weight: 80}},
+			Sets:      []ExportSet{{Exercise: "Barbell Bench Press", SetNumber: 1, Reps: 5, Weight: 82.5, RPE: &rpe, RestSeconds: &rest}},
+		},
+		{Name: "Rest day", ScheduledFor: completed.Add(24 * time.Hour)},
+		{
+			Name: `=HYPERLINK("http://evil.example")`, Description: "+1 day", ScheduledFor: completed.Add(48 * time.Hour),
+			Sets: []ExportSet{{Exercise: "@Squat", SetNumber: 1, Reps: 5, Notes: "-2 reps in reserve"}},
+		},
+	}}
+	var buf bytes.Buffer
+	if err := WriteTrackerCSV(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Spreadsheets must not see formulas in user text
+	for _, cell := range []string{`'=HYPERLINK(`, `,'+1 day,`, `,'@Squat,`, `,'-2 reps in reserve`} {
+		if !strings.Contains(buf.String(), cell) {
+			t.Errorf("expected %q in the CSV:\n%s", cell, buf.String())
+		}
+	}
+
+	format, workouts, issues, err := ParseImportCSV(buf.Bytes(), "", time.UTC)
+	if err != nil || format != FormatTracker || len(issues) != 0 {
+		t.Fatalf("got format %q, issues %+v, err %v", format, issues, err)
+	}
+	if len(workouts) != 3 || workouts[1].Name != "Rest day" || len(workouts[1].Exercises) != 0 {
+		t.Fatalf("unexpected workouts: %+v", workouts)
+	}
+	if f := workouts[2]; f.Name != data.Workouts[2].Name || f.Description != "+1 day" ||
+		len(f.Sets) != 1 || f.Sets[0].Exercise != "@Squat" || f.Sets[0].Notes != "-2 reps in reserve" {
+		t.Errorf("neutralized text not restored on import: %+v", f)
+	}
+	w := workouts[0]
+	if w.Name != data.Workouts[0].Name || w.Description != data.Workouts[0].Description || w.CompletedAt == nil || !w.CompletedAt.Equal(completed) {
+		t.Errorf("workout fields not preserved: %+v", w)
+	}
+	if len(w.Exercises) != 1 || w.Exercises[0].Sets != 3 || len(w.Sets) != 1 || *w.Sets[0].RPE != 8.5 || *w.Sets[0].RestSeconds != 90 {
+		t.Errorf("exercises or sets not preserved: %+v %+v", w.Exercises, w.Sets)
+	}
+}
@@ -37,9 +37,15 @@ type WorkoutResponse struct {
 	Name         string                    `json:"name"`
 	Description  string                    `json:"description"`
 	ScheduledFor time.Time                 `json:"scheduled_for"`
+	CompletedAt  *time.Time                `json:"completed_at,omitempty"`
 	Exercises    []WorkoutExerciseResponse `json:"exercises"`
 }
 
+// CompleteWorkoutRequest marks a workout as done. CompletedAt defaults to now.
+type CompleteWorkoutRequest struct {
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
 // Validate checks if the CreateWorkoutRequest is valid
 func (r CreateWorkoutRequest) Validate() error {
 	if r.Name == "" {
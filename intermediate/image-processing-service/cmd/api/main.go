@@ -33,12 +33,28 @@ func main() {
 
 	defer db.Disconnect(context.Background())
 
-	s3Service := services.NewS3Service(cfg)
+	storage, err := services.NewStorage(cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize storage", logger.Error(err))
+	}
+
 	userService := services.NewUserService(db, log)
-	fileService := services.NewFileService(s3Service, log)
-	imageService := services.NewImageService(db, s3Service, log)
+	fileService := services.NewFileService(storage, log, cfg.MaxImageDimension, cfg.MaxImageMegapixels)
+	imageService := services.NewImageService(db, storage, log, cfg.MaxImageMegapixels)
+	jobService := services.NewJobService(db, imageService, log, cfg.JobWorkers, cfg.JobMaxAttempts, cfg.WebhookSigningKey, cfg.WebhookAllowPrivate)
+	albumService := services.NewAlbumService(db, log)
+	shareService := services.NewShareService(db, log)
+	urlSigner := services.NewURLSigner(cfg.URLSigningKey)
+
+	if err := createIndexes(imageService, albumService, shareService); err != nil {
+		log.Fatal("Failed to create indexes", logger.Error(err))
+	}
+
+	if err := jobService.Start(); err != nil {
+		log.Fatal("Failed to start job workers", logger.Error(err))
+	}
 
-	h := handlers.New(userService, fileService, imageService, log, cfg)
+	h := handlers.New(userService, fileService, imageService, jobService, albumService, shareService, urlSigner, log, cfg)
 
 	router := routes.SetupRouter(h, log, cfg)
 
@@ -70,5 +86,23 @@ func main() {
 		log.Fatal("Server forced to shutdown", logger.Error(err))
 	}
 
+	// Running jobs finish; queued ones stay in MongoDB for the next start
+	jobService.Stop()
+
 	log.Info("Server exited")
 }
+
+// createIndexes creates the indexes behind image search, album listing and
+// share link lookups
+func createIndexes(imageService *services.ImageService, albumService *services.AlbumService, shareService *services.ShareService) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := imageService.CreateIndexes(ctx); err != nil {
+		return err
+	}
+	if err := albumService.CreateIndexes(ctx); err != nil {
+		return err
+	}
+	return shareService.CreateIndexes(ctx)
+}
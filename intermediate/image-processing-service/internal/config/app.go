@@ -15,10 +15,45 @@ type Config struct {
 	AWSSecretKey        string
 	AWSRegion           string
 	S3Bucket            string
+	StorageBackend      string
+	LocalStorageDir     string
 	Port                string
 	JWTSecret           string
 	JWTIssuer           string
 	JWTExpirationMinute int
+	URLSigningKey       string
+	JobWorkers          int
+	JobMaxAttempts      int
+	WebhookSigningKey   string
+	WebhookAllowPrivate bool
+	DuplicateDistance   int
+	MaxImageDimension   int
+	MaxImageMegapixels  int
+	Plans               map[string]Plan
+}
+
+// Plans limit what a user may store and how fast they may process images.
+// Zero means unlimited.
+type Plan struct {
+	MaxBytes         int64 `json:"max_bytes"`
+	MaxImages        int64 `json:"max_images"`
+	ProcessPerMinute int   `json:"process_per_minute"`
+}
+
+// Plan names; users without a plan are on DefaultPlan
+const (
+	PlanFree    = "free"
+	PlanPro     = "pro"
+	DefaultPlan = PlanFree
+)
+
+// PlanLimits returns the limits of a plan, or of the default plan for an
+// unknown or empty name
+func (c *Config) PlanLimits(name string) Plan {
+	if plan, ok := c.Plans[name]; ok {
+		return plan
+	}
+	return c.Plans[DefaultPlan]
 }
 
 // LoadConfig loads the application configuration from environment variables
@@ -34,10 +69,32 @@ func LoadConfig() *Config {
 		AWSSecretKey:        getEnv("AWS_SECRET_ACCESS_KEY", "localstack"),
 		AWSRegion:           getEnv("AWS_REGION", "us-east-1"),
 		S3Bucket:            getEnv("AWS_BUCKET", "img-bucket"),
+		StorageBackend:      getEnv("STORAGE_BACKEND", "s3"),
+		LocalStorageDir:     getEnv("LOCAL_STORAGE_DIR", "./data/storage"),
 		Port:                getEnv("APP_PORT", "8800"),
 		JWTSecret:           getEnv("JWT_SECRET", "supersecretjwtkey"),
 		JWTIssuer:           getEnv("JWT_ISSUER", "image-processing-service"),
 		JWTExpirationMinute: jwtExpirationMinute,
+		URLSigningKey:       getEnv("URL_SIGNING_KEY", "supersecreturlsigningkey"),
+		JobWorkers:          getEnvInt("JOB_WORKERS", 2),
+		JobMaxAttempts:      getEnvInt("JOB_MAX_ATTEMPTS", 3),
+		WebhookSigningKey:   getEnv("WEBHOOK_SIGNING_KEY", "supersecretwebhookkey"),
+		WebhookAllowPrivate: getEnv("WEBHOOK_ALLOW_PRIVATE", "false") == "true",
+		DuplicateDistance:   getEnvInt("DUPLICATE_HASH_DISTANCE", 4),
+		MaxImageDimension:   getEnvInt("MAX_IMAGE_DIMENSION", 10000),
+		MaxImageMegapixels:  getEnvInt("MAX_IMAGE_MEGAPIXELS", 50),
+		Plans: map[string]Plan{
+			PlanFree: {
+				MaxBytes:         getEnvInt64("PLAN_FREE_MAX_BYTES", 100*1024*1024),
+				MaxImages:        getEnvInt64("PLAN_FREE_MAX_IMAGES", 100),
+				ProcessPerMinute: getEnvInt("PLAN_FREE_PROCESS_PER_MINUTE", 10),
+			},
+			PlanPro: {
+				MaxBytes:         getEnvInt64("PLAN_PRO_MAX_BYTES", 10*1024*1024*1024),
+				MaxImages:        getEnvInt64("PLAN_PRO_MAX_IMAGES", 10000),
+				ProcessPerMinute: getEnvInt("PLAN_PRO_PROCESS_PER_MINUTE", 60),
+			},
+		},
 	}
 }
 
@@ -60,3 +117,14 @@ func getEnvInt(key string, defaultValue int) int {
 
 	return defaultValue
 }
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			return n
+		}
+	}
+
+	return defaultValue
+}
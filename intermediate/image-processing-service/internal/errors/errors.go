@@ -68,6 +68,14 @@ func NewUnauthorizedError(message string) *AppError {
 	}
 }
 
+// NewForbiddenError creates a forbidden error
+func NewForbiddenError(message string) *AppError {
+	return &AppError{
+		Code:    http.StatusForbidden,
+		Message: message,
+	}
+}
+
 // NewInternalError creates an internal server error
 func NewInternalError(message string, err error) *AppError {
 	return &AppError{
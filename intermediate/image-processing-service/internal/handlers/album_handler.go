@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/errors"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/services"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Album field limits
+const (
+	maxAlbumNameLength        = 100
+	maxAlbumDescriptionLength = 1000
+)
+
+// CreateAlbumHandler creates an album
+func (h *Handler) CreateAlbumHandler(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	var req models.CreateAlbumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	album := &models.Album{
+		UserID:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+	}
+	if msg := validateAlbum(album); msg != "" {
+		c.Error(errors.NewValidationError(msg))
+		return
+	}
+
+	if err := h.albumService.CreateAlbum(c.Request.Context(), album); err != nil {
+		c.Error(errors.NewDatabaseError("album creation", err))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Album created successfully", album.ToResponse(0))
+}
+
+// GetAlbumsHandler lists the user's albums
+func (h *Handler) GetAlbumsHandler(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	albums, err := h.albumService.GetUserAlbums(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(errors.NewDatabaseError("albums retrieval", err))
+		return
+	}
+
+	counts, err := h.albumService.CountImages(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(errors.NewDatabaseError("albums retrieval", err))
+		return
+	}
+
+	responses := make([]*models.AlbumResponse, len(albums))
+	for i, album := range albums {
+		responses[i] = album.ToResponse(counts[album.ID])
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Albums retrieved successfully", gin.H{"albums": responses})
+}
+
+// GetAlbumHandler retrieves an album; its images are listed by
+// GET /api/v1/images/search?album=:id
+func (h *Handler) GetAlbumHandler(c *gin.Context) {
+	album := h.userAlbum(c)
+	if album == nil {
+		return
+	}
+
+	counts, err := h.albumService.CountImages(c.Request.Context(), album.UserID)
+	if err != nil {
+		c.Error(errors.NewDatabaseError("album retrieval", err))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Album retrieved successfully", album.ToResponse(counts[album.ID]))
+}
+
+// UpdateAlbumHandler renames an album or sets its cover image
+func (h *Handler) UpdateAlbumHandler(c *gin.Context) {
+	album := h.userAlbum(c)
+	if album == nil {
+		return
+	}
+
+	var req models.UpdateAlbumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	if req.Name != nil {
+		album.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		album.Description = strings.TrimSpace(*req.Description)
+	}
+	if msg := validateAlbum(album); msg != "" {
+		c.Error(errors.NewValidationError(msg))
+		return
+	}
+
+	if req.CoverImageID != nil {
+		if *req.CoverImageID == "" {
+			album.CoverImageID = nil
+		} else {
+			// The cover must be one of the album's images
+			coverID, err := primitive.ObjectIDFromHex(*req.CoverImageID)
+			if err != nil {
+				c.Error(errors.NewValidationError("Invalid cover image ID"))
+				return
+			}
+			inAlbum, err := h.albumService.ContainsImage(c.Request.Context(), album, coverID)
+			if err != nil {
+				c.Error(errors.NewDatabaseError("album update", err))
+				return
+			}
+			if !inAlbum {
+				c.Error(errors.NewValidationError("The cover image must be in the album"))
+				return
+			}
+			album.CoverImageID = &coverID
+		}
+	}
+
+	if err := h.albumService.UpdateAlbum(c.Request.Context(), album); err != nil {
+		c.Error(errors.NewDatabaseError("album update", err))
+		return
+	}
+
+	counts, err := h.albumService.CountImages(c.Request.Context(), album.UserID)
+	if err != nil {
+		c.Error(errors.NewDatabaseError("album retrieval", err))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Album updated successfully", album.ToResponse(counts[album.ID]))
+}
+
+// DeleteAlbumHandler deletes an album; its images are kept
+func (h *Handler) DeleteAlbumHandler(c *gin.Context) {
+	album := h.userAlbum(c)
+	if album == nil {
+		return
+	}
+
+	if err := h.albumService.DeleteAlbum(c.Request.Context(), album); err != nil {
+		c.Error(errors.NewDatabaseError("album deletion", err))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Album deleted successfully", nil)
+}
+
+// AddAlbumImagesHandler adds the user's images to an album
+func (h *Handler) AddAlbumImagesHandler(c *gin.Context) {
+	album := h.userAlbum(c)
+	if album == nil {
+		return
+	}
+
+	var req models.AlbumImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+	if len(req.ImageIDs) == 0 || len(req.ImageIDs) > services.MaxAlbumImagesPerRequest {
+		c.Error(errors.NewValidationError("image_ids must list between 1 and 100 images"))
+		return
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	var imageIDs []primitive.ObjectID
+	for _, id := range req.ImageIDs {
+		imageID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.Error(errors.NewValidationError("Invalid image ID: " + id))
+			return
+		}
+		if !seen[imageID] {
+			seen[imageID] = true
+			imageIDs = append(imageIDs, imageID)
+		}
+	}
+
+	err := h.albumService.AddImages(c.Request.Context(), album, imageIDs)
+	if stderrors.Is(err, services.ErrImageNotFound) {
+		c.Error(errors.NewNotFoundError("Image"))
+		return
+	}
+	if err != nil {
+		c.Error(errors.NewDatabaseError("album update", err))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Images added to album successfully", gin.H{"added": len(imageIDs)})
+}
+
+// RemoveAlbumImageHandler removes an image from an album; the image is kept
+func (h *Handler) RemoveAlbumImageHandler(c *gin.Context) {
+	album := h.userAlbum(c)
+	if album == nil {
+		return
+	}
+
+	imageID, err := primitive.ObjectIDFromHex(c.Param("image_id"))
+	if err != nil {
+		c.Error(errors.NewValidationError("Invalid image ID"))
+		return
+	}
+
+	removed, err := h.albumService.RemoveImage(c.Request.Context(), album, imageID)
+	if err != nil {
+		c.Error(errors.NewDatabaseError("album update", err))
+		return
+	}
+	if !removed {
+		c.Error(errors.NewNotFoundError("Image in album"))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Image removed from album successfully", nil)
+}
+
+// userAlbum loads the album in the URL and checks that the user owns it. On
+// failure it records the error and returns nil.
+func (h *Handler) userAlbum(c *gin.Context) *models.Album {
+	userIDStr := c.GetString("user_id")
+	if userIDStr == "" {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return nil
+	}
+
+	album, err := h.albumService.GetAlbum(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.logger.Error("Failed to get album", logger.Error(err))
+		c.Error(errors.NewDatabaseError("album retrieval", err))
+		return nil
+	}
+
+	if album == nil {
+		c.Error(errors.NewNotFoundError("Album"))
+		return nil
+	}
+
+	if album.UserID.Hex() != userIDStr {
+		c.Error(errors.NewUnauthorizedError("Not authorized to access this album"))
+		return nil
+	}
+
+	return album
+}
+
+// validateAlbum returns a validation message, or "" if the album is valid
+func validateAlbum(album *models.Album) string {
+	if album.Name == "" {
+		return "Album name is required"
+	}
+	if utf8.RuneCountInString(album.Name) > maxAlbumNameLength {
+		return "Album name must be at most 100 characters"
+	}
+	if utf8.RuneCountInString(album.Description) > maxAlbumDescriptionLength {
+		return "Album description must be at most 1000 characters"
+	}
+	return ""
+}
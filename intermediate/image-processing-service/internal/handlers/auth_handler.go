@@ -8,6 +8,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/errors"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/middleware"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/utils"
 	"golang.org/x/crypto/bcrypt"
@@ -132,11 +133,14 @@ func (h *Handler) LoginHandler(c *gin.Context) {
 func (h *Handler) generateJWT(user *models.User) (string, error) {
 	expiresAt := time.Now().Add(time.Duration(h.cfg.JWTExpirationMinute) * time.Minute)
 
-	claims := jwt.RegisteredClaims{
-		Subject:   user.ID.Hex(),
-		Issuer:    h.cfg.JWTIssuer,
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	claims := middleware.Claims{
+		Plan: user.Plan,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.Hex(),
+			Issuer:    h.cfg.JWTIssuer,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -15,6 +15,10 @@ type Handler struct {
 	userService  *services.UserService
 	fileService  *services.FileService
 	imageService *services.ImageService
+	jobService   *services.JobService
+	albumService *services.AlbumService
+	shareService *services.ShareService
+	urlSigner    *services.URLSigner
 	logger       *zap.Logger
 	cfg          *config.Config
 }
@@ -23,6 +27,10 @@ func New(
 	userService *services.UserService,
 	fileService *services.FileService,
 	imageService *services.ImageService,
+	jobService *services.JobService,
+	albumService *services.AlbumService,
+	shareService *services.ShareService,
+	urlSigner *services.URLSigner,
 	logger *zap.Logger,
 	cfg *config.Config,
 ) *Handler {
@@ -30,6 +38,10 @@ func New(
 		userService:  userService,
 		fileService:  fileService,
 		imageService: imageService,
+		jobService:   jobService,
+		albumService: albumService,
+		shareService: shareService,
+		urlSigner:    urlSigner,
 		logger:       logger,
 		cfg:          cfg,
 	}
@@ -1,17 +1,38 @@
 package handlers
 
 import (
+	stderrors "errors"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/errors"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/services"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Download URLs last an hour by default and at most a week, the limit of S3
+// presigned URLs
+const (
+	defaultDownloadURLExpiry = time.Hour
+	maxDownloadURLExpiry     = 7 * 24 * time.Hour
+)
+
+// maxDuplicateWarnings limits the earlier uploads listed in an upload response
+const maxDuplicateWarnings = 5
+
+// Defaults of similar image search, out of a 64-bit hash
+const (
+	defaultSimilarDistance = 10
+	defaultSimilarLimit    = 20
+)
+
 // UploadImageHandler handles image upload requests
 func (h *Handler) UploadImageHandler(c *gin.Context) {
 	// Get user ID from context (would be set by auth middleware)
@@ -47,8 +68,23 @@ func (h *Handler) UploadImageHandler(c *gin.Context) {
 		return
 	}
 
+	// Check the plan before storing anything
+	plan := h.cfg.PlanLimits(c.GetString("plan"))
+	if err := h.imageService.CheckQuota(c.Request.Context(), userID, plan, file.Size, 1); err != nil {
+		h.quotaError(c, err)
+		return
+	}
+
 	// Upload file to storage
-	key, err := h.fileService.UploadFile(c.Request.Context(), file)
+	uploaded, err := h.fileService.UploadFile(c.Request.Context(), file)
+	if stderrors.Is(err, services.ErrInvalidImage) {
+		c.Error(errors.NewValidationError("File is not a valid " + contentType + " image"))
+		return
+	}
+	if stderrors.Is(err, services.ErrImageTooLarge) {
+		c.Error(errors.NewAppError(http.StatusRequestEntityTooLarge, "Image dimensions too large: "+err.Error(), nil))
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to upload file", logger.Error(err))
 		c.Error(errors.NewFileError("upload", err))
@@ -58,10 +94,13 @@ func (h *Handler) UploadImageHandler(c *gin.Context) {
 	// Create image record
 	image := &models.Image{
 		UserID:      userID,
-		OriginalKey: key,
+		OriginalKey: uploaded.Key,
 		Filename:    file.Filename,
 		ContentType: contentType,
-		Size:        file.Size,
+		Size:        uploaded.Size,
+		Width:       uploaded.Width,
+		Height:      uploaded.Height,
+		Hashes:      uploaded.Hashes,
 		Status:      models.ImageStatusUploaded,
 	}
 
@@ -71,7 +110,26 @@ func (h *Handler) UploadImageHandler(c *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusCreated, "Image uploaded successfully", image.ToResponse())
+	if _, err := h.imageService.RecomputeUsage(c.Request.Context(), userID); err != nil {
+		h.logger.Warn("Failed to recompute usage", logger.Error(err))
+	}
+
+	// Warn about earlier uploads that look the same; the upload itself is kept
+	response := &models.UploadImageResponse{ImageResponse: image.ToResponse()}
+	if image.Hashes != nil {
+		duplicates, err := h.imageService.FindSimilarImages(c.Request.Context(), userID, image.Hashes, services.HashPerceptual, h.cfg.DuplicateDistance, image.ID, maxDuplicateWarnings)
+		if err != nil {
+			h.logger.Warn("Failed to look for duplicate images", logger.Error(err))
+		}
+		response.Duplicates = duplicates
+	}
+
+	message := "Image uploaded successfully"
+	if len(response.Duplicates) > 0 {
+		message = "Image uploaded successfully; it looks like an image you already uploaded"
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, message, response)
 }
 
 // GetImageHandler retrieves an image by ID
@@ -141,7 +199,73 @@ func (h *Handler) GetUserImagesHandler(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Images retrieved successfully", result)
 }
 
-// ProcessImageHandler handles image processing requests
+// SimilarImagesHandler lists the user's images that look like an image,
+// by the Hamming distance between their perceptual hashes
+func (h *Handler) SimilarImagesHandler(c *gin.Context) {
+	imageID := c.Param("id")
+
+	userIDStr := c.GetString("user_id")
+	if userIDStr == "" {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	algorithm := c.DefaultQuery("algorithm", services.HashPerceptual)
+	if !services.IsValidHashAlgorithm(algorithm) {
+		c.Error(errors.NewValidationError("algorithm must be one of ahash, dhash or phash"))
+		return
+	}
+
+	threshold, err := strconv.Atoi(c.DefaultQuery("threshold", strconv.Itoa(defaultSimilarDistance)))
+	if err != nil || threshold < 0 || threshold > services.MaxHashDistance {
+		c.Error(errors.NewValidationError("threshold must be between 0 and 64"))
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSimilarLimit)))
+	if limit < 1 || limit > 100 {
+		limit = defaultSimilarLimit
+	}
+
+	image, err := h.imageService.GetImage(imageID)
+	if err != nil {
+		h.logger.Error("Failed to get image", logger.Error(err))
+		c.Error(errors.NewDatabaseError("image retrieval", err))
+		return
+	}
+
+	if image == nil {
+		c.Error(errors.NewNotFoundError("Image"))
+		return
+	}
+
+	if image.UserID.Hex() != userIDStr {
+		c.Error(errors.NewUnauthorizedError("Not authorized to access this image"))
+		return
+	}
+
+	// Images uploaded before hashing are hashed on first use
+	if err := h.imageService.EnsureHashes(c.Request.Context(), image); err != nil {
+		h.logger.Error("Failed to hash image", logger.Error(err))
+		c.Error(errors.NewInternalError("Failed to hash image", err))
+		return
+	}
+
+	similar, err := h.imageService.FindSimilarImages(c.Request.Context(), image.UserID, image.Hashes, algorithm, threshold, image.ID, limit)
+	if err != nil {
+		h.logger.Error("Failed to find similar images", logger.Error(err))
+		c.Error(errors.NewDatabaseError("similar images retrieval", err))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Similar images retrieved successfully", gin.H{
+		"images":    similar,
+		"algorithm": algorithm,
+		"threshold": threshold,
+	})
+}
+
+// ProcessImageHandler queues a processing job for an image
 func (h *Handler) ProcessImageHandler(c *gin.Context) {
 	imageID := c.Param("id")
 	if imageID == "" {
@@ -149,17 +273,34 @@ func (h *Handler) ProcessImageHandler(c *gin.Context) {
 		return
 	}
 
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
 	var req models.ProcessImageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ValidationErrorResponse(c, err)
 		return
 	}
 
-	// Validate operation
-	if !models.IsValidOperation(req.Operation) {
-		c.Error(errors.NewValidationError("Invalid operation"))
+	// Validate the whole pipeline before queueing it
+	transformations := req.Pipeline()
+	if len(transformations) == 0 {
+		c.Error(errors.NewValidationError("Operation or transformations is required"))
 		return
 	}
+	if err := services.ValidateTransformations(transformations); err != nil {
+		c.Error(errors.NewValidationError("Invalid transformation: " + err.Error()))
+		return
+	}
+	if req.WebhookURL != "" {
+		if err := h.jobService.ValidateWebhookURL(req.WebhookURL); err != nil {
+			c.Error(errors.NewValidationError(err.Error()))
+			return
+		}
+	}
 
 	image, err := h.imageService.GetImage(imageID)
 	if err != nil {
@@ -173,13 +314,53 @@ func (h *Handler) ProcessImageHandler(c *gin.Context) {
 		return
 	}
 
-	if err := h.imageService.EnqueueProcessing(imageID, req.Operation, req.Parameters); err != nil {
+	if image.UserID != userID {
+		c.Error(errors.NewUnauthorizedError("Not authorized to process this image"))
+		return
+	}
+
+	// Watermarks may only use the user's own images
+	for _, t := range transformations {
+		if t.Operation != string(models.OperationWatermark) {
+			continue
+		}
+		overlay, err := h.imageService.GetImage(t.Parameters["image_id"])
+		if err != nil {
+			h.logger.Error("Failed to get watermark image", logger.Error(err))
+			c.Error(errors.NewDatabaseError("image retrieval", err))
+			return
+		}
+		if overlay == nil || overlay.UserID != userID {
+			c.Error(errors.NewValidationError("Invalid transformation: " + services.ErrOverlayNotFound.Error()))
+			return
+		}
+	}
+
+	// Processed versions count towards the quota, so a full quota stops them
+	plan := h.cfg.PlanLimits(c.GetString("plan"))
+	if err := h.imageService.CheckQuota(c.Request.Context(), userID, plan, 0, 0); err != nil {
+		h.quotaError(c, err)
+		return
+	}
+
+	// format=auto is resolved now, while the request's Accept header is known
+	if services.HasAutoFormat(transformations) {
+		format := services.NegotiateFormat(c.GetHeader("Accept"), image.ContentType)
+		transformations = services.ResolveAutoFormat(transformations, format)
+	}
+
+	job, err := h.jobService.Enqueue(userID, image.ID, transformations, req.WebhookURL)
+	if err != nil {
 		h.logger.Error("Failed to enqueue image processing", logger.Error(err))
-		c.Error(errors.NewInternalError("Image processing enqueue failed", err))
+		c.Error(errors.NewDatabaseError("job creation", err))
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusAccepted, "Image processing started", image.ToResponse())
+	c.Header("Location", "/api/v1/jobs/"+job.ID.Hex())
+	utils.SuccessResponse(c, http.StatusAccepted, "Image processing queued", gin.H{
+		"job":   job.ToResponse(),
+		"image": image.ToResponse(),
+	})
 }
 
 // DownloadImageHandler handles image download requests
@@ -206,31 +387,10 @@ func (h *Handler) DownloadImageHandler(c *gin.Context) {
 	}
 
 	// Determine which version to download
-	var key string
-	var contentType string
-	var filename string
-
-	if processedKey != "" {
-		// Find processed version
-		found := false
-		for _, processed := range image.Processed {
-			if processed.Key == processedKey {
-				key = processed.Key
-				contentType = processed.ContentType
-				filename = "processed_" + image.Filename
-				found = true
-				break
-			}
-		}
-		if !found {
-			c.Error(errors.NewNotFoundError("Processed image"))
-			return
-		}
-	} else {
-		// Download original
-		key = image.OriginalKey
-		contentType = image.ContentType
-		filename = image.Filename
+	key, contentType, filename, found := downloadTarget(image, processedKey)
+	if !found {
+		c.Error(errors.NewNotFoundError("Processed image"))
+		return
 	}
 
 	// Get file data
@@ -249,6 +409,126 @@ func (h *Handler) DownloadImageHandler(c *gin.Context) {
 	c.Data(http.StatusOK, contentType, data)
 }
 
+// DownloadURLHandler issues a time-limited URL that downloads the original
+// or a processed version straight from storage
+func (h *Handler) DownloadURLHandler(c *gin.Context) {
+	imageID := c.Param("id")
+	processedKey := c.Query("processed")
+
+	userIDStr := c.GetString("user_id")
+	if userIDStr == "" {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	expiresIn := defaultDownloadURLExpiry
+	if v := c.Query("expires_in"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxDownloadURLExpiry {
+			c.Error(errors.NewValidationError("expires_in must be between 1 and 604800 seconds"))
+			return
+		}
+		expiresIn = time.Duration(seconds) * time.Second
+	}
+
+	image, err := h.imageService.GetImage(imageID)
+	if err != nil {
+		h.logger.Error("Failed to get image", logger.Error(err))
+		c.Error(errors.NewDatabaseError("image retrieval", err))
+		return
+	}
+
+	if image == nil {
+		c.Error(errors.NewNotFoundError("Image"))
+		return
+	}
+
+	if image.UserID.Hex() != userIDStr {
+		c.Error(errors.NewUnauthorizedError("Not authorized to access this image"))
+		return
+	}
+
+	key, _, filename, found := downloadTarget(image, processedKey)
+	if !found {
+		c.Error(errors.NewNotFoundError("Processed image"))
+		return
+	}
+
+	url, err := h.fileService.DownloadURL(c.Request.Context(), key, filename, expiresIn)
+	if err != nil {
+		c.Error(errors.NewInternalError("Failed to create download URL", err))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Download URL created successfully", gin.H{
+		"url":        absoluteURL(c, url),
+		"expires_at": time.Now().Add(expiresIn).UTC(),
+	})
+}
+
+// SignedFileHandler serves a file from local storage for a signed URL issued
+// by DownloadURLHandler
+func (h *Handler) SignedFileHandler(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	filename := c.Query("n")
+	signature := c.Query("s")
+
+	expires, err := strconv.ParseInt(c.Query("e"), 10, 64)
+	if err != nil || signature == "" {
+		c.Error(errors.NewForbiddenError("Invalid download URL"))
+		return
+	}
+
+	if err := h.fileService.VerifyDownload(key, filename, expires, signature); err != nil {
+		c.Error(errors.NewForbiddenError("Invalid download URL: " + err.Error()))
+		return
+	}
+
+	data, err := h.fileService.GetFile(c.Request.Context(), key)
+	if services.IsNotFound(err) {
+		c.Error(errors.NewNotFoundError("File"))
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to get file", logger.Error(err))
+		c.Error(errors.NewFileError("download", err))
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Content-Length", strconv.Itoa(len(data)))
+
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// quotaError reports a failed quota check
+func (h *Handler) quotaError(c *gin.Context, err error) {
+	if stderrors.Is(err, services.ErrQuotaExceeded) {
+		c.Error(errors.NewAppError(http.StatusForbidden, "Storage quota exceeded; see /api/v1/me/usage", err))
+		return
+	}
+
+	h.logger.Error("Failed to check quota", logger.Error(err))
+	c.Error(errors.NewDatabaseError("quota check", err))
+}
+
+// downloadTarget returns the key, content type and download filename of the
+// original, or of the processed version with the given key
+func downloadTarget(image *models.Image, processedKey string) (key, contentType, filename string, found bool) {
+	if processedKey == "" {
+		return image.OriginalKey, image.ContentType, image.Filename, true
+	}
+
+	for _, processed := range image.Processed {
+		if processed.Key == processedKey {
+			return processed.Key, processed.ContentType, "processed_" + image.Filename, true
+		}
+	}
+
+	return "", "", "", false
+}
+
 // DeleteImageHandler handles image deletion requests
 func (h *Handler) DeleteImageHandler(c *gin.Context) {
 	imageID := c.Param("id")
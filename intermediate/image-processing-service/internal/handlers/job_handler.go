@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/errors"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/utils"
+)
+
+// GetJobHandler returns the status of a processing job
+func (h *Handler) GetJobHandler(c *gin.Context) {
+	jobID := c.Param("id")
+
+	userIDStr := c.GetString("user_id")
+	if userIDStr == "" {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	job, err := h.jobService.GetJob(jobID)
+	if err != nil {
+		h.logger.Error("Failed to get job", logger.Error(err))
+		c.Error(errors.NewDatabaseError("job retrieval", err))
+		return
+	}
+
+	if job == nil {
+		c.Error(errors.NewNotFoundError("Job"))
+		return
+	}
+
+	if job.UserID.Hex() != userIDStr {
+		c.Error(errors.NewUnauthorizedError("Not authorized to access this job"))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Job retrieved successfully", job.ToResponse())
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/errors"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/services"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SetImageTagsHandler replaces the tags of an image
+func (h *Handler) SetImageTagsHandler(c *gin.Context) {
+	imageID := c.Param("id")
+
+	userIDStr := c.GetString("user_id")
+	if userIDStr == "" {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	var req models.ImageTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	tags, err := services.NormalizeTags(req.Tags)
+	if err != nil {
+		c.Error(errors.NewValidationError("Invalid tags: " + err.Error()))
+		return
+	}
+
+	image, err := h.imageService.GetImage(imageID)
+	if err != nil {
+		h.logger.Error("Failed to get image", logger.Error(err))
+		c.Error(errors.NewDatabaseError("image retrieval", err))
+		return
+	}
+
+	if image == nil {
+		c.Error(errors.NewNotFoundError("Image"))
+		return
+	}
+
+	if image.UserID.Hex() != userIDStr {
+		c.Error(errors.NewUnauthorizedError("Not authorized to tag this image"))
+		return
+	}
+
+	if err := h.imageService.SetTags(c.Request.Context(), image.ID, tags); err != nil {
+		c.Error(errors.NewDatabaseError("image update", err))
+		return
+	}
+
+	image.Tags = tags
+	utils.SuccessResponse(c, http.StatusOK, "Image tags updated successfully", image.ToResponse())
+}
+
+// SearchImagesHandler searches the user's images by tag, album, content type,
+// dimensions and upload date
+func (h *Handler) SearchImagesHandler(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	search, msg := parseImageSearch(c)
+	if msg != "" {
+		c.Error(errors.NewValidationError(msg))
+		return
+	}
+
+	images, total, err := h.imageService.SearchImages(c.Request.Context(), userID, search)
+	if err != nil {
+		h.logger.Error("Failed to search images", logger.Error(err))
+		c.Error(errors.NewDatabaseError("image search", err))
+		return
+	}
+
+	responses := make([]*models.ImageResponse, len(images))
+	for i, img := range images {
+		responses[i] = img.ToResponse()
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Images retrieved successfully", gin.H{
+		"images": responses,
+		"pagination": gin.H{
+			"page":  search.Page,
+			"limit": search.Limit,
+			"total": total,
+		},
+	})
+}
+
+// parseImageSearch reads the search query parameters. It returns a
+// validation message for invalid ones.
+func parseImageSearch(c *gin.Context) (*services.ImageSearch, string) {
+	search := &services.ImageSearch{
+		ContentType: c.Query("content_type"),
+		Sort:        c.DefaultQuery("sort", services.DefaultImageSort),
+	}
+
+	// tag may be repeated or comma separated; images must have all tags
+	var tags []string
+	for _, t := range c.QueryArray("tag") {
+		tags = append(tags, strings.Split(t, ",")...)
+	}
+	tags, err := services.NormalizeTags(tags)
+	if err != nil {
+		return nil, "Invalid tag: " + err.Error()
+	}
+	search.Tags = tags
+
+	if v := c.Query("album"); v != "" {
+		albumID, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return nil, "Invalid album ID"
+		}
+		search.AlbumID = &albumID
+	}
+
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{"min_width", &search.MinWidth},
+		{"max_width", &search.MaxWidth},
+		{"min_height", &search.MinHeight},
+		{"max_height", &search.MaxHeight},
+	} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, p.name + " must be a non-negative number"
+		}
+		*p.value = n
+	}
+
+	if search.From, err = parseSearchTime(c.Query("from"), false); err != nil {
+		return nil, "from must be a date (2006-01-02) or an RFC 3339 time"
+	}
+	if search.To, err = parseSearchTime(c.Query("to"), true); err != nil {
+		return nil, "to must be a date (2006-01-02) or an RFC 3339 time"
+	}
+
+	if !services.IsValidImageSort(search.Sort) {
+		return nil, "sort must be one of created_at, size, width, height or filename, optionally prefixed with -"
+	}
+
+	search.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	search.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if search.Page < 1 {
+		search.Page = 1
+	}
+	if search.Limit < 1 || search.Limit > 100 {
+		search.Limit = 10
+	}
+
+	return search, ""
+}
+
+// parseSearchTime parses an RFC 3339 time or a UTC date. A date used as the
+// end of a range covers the whole day.
+func parseSearchTime(v string, endOfDay bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
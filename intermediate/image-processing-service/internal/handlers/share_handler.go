@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/errors"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/services"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Share link limits. bcrypt only uses the first 72 bytes of a password.
+const (
+	maxShareExpiry       = 365 * 24 * time.Hour
+	maxShareDownloads    = 1000000
+	minSharePasswordSize = 4
+	maxSharePasswordSize = 72
+)
+
+// sharePasswordHeader carries the password of a protected share link
+const sharePasswordHeader = "X-Share-Password"
+
+// CreateShareLinkHandler creates a public link to an image's original or one
+// of its processed versions
+func (h *Handler) CreateShareLinkHandler(c *gin.Context) {
+	imageID := c.Param("id")
+
+	userIDStr := c.GetString("user_id")
+	if userIDStr == "" {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	var req models.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	if req.ExpiresIn < 0 || time.Duration(req.ExpiresIn)*time.Second > maxShareExpiry {
+		c.Error(errors.NewValidationError("expires_in must be between 0 and 31536000 seconds"))
+		return
+	}
+	if req.MaxDownloads < 0 || req.MaxDownloads > maxShareDownloads {
+		c.Error(errors.NewValidationError("max_downloads must be between 0 and 1000000"))
+		return
+	}
+	if req.Password != "" && (len(req.Password) < minSharePasswordSize || len(req.Password) > maxSharePasswordSize) {
+		c.Error(errors.NewValidationError("password must be between 4 and 72 characters"))
+		return
+	}
+
+	image, err := h.imageService.GetImage(imageID)
+	if err != nil {
+		h.logger.Error("Failed to get image", logger.Error(err))
+		c.Error(errors.NewDatabaseError("image retrieval", err))
+		return
+	}
+
+	if image == nil {
+		c.Error(errors.NewNotFoundError("Image"))
+		return
+	}
+
+	if image.UserID.Hex() != userIDStr {
+		c.Error(errors.NewUnauthorizedError("Not authorized to share this image"))
+		return
+	}
+
+	if _, _, _, found := downloadTarget(image, req.ProcessedKey); !found {
+		c.Error(errors.NewNotFoundError("Processed image"))
+		return
+	}
+
+	link := &models.ShareLink{
+		UserID:       image.UserID,
+		ImageID:      image.ID,
+		ProcessedKey: req.ProcessedKey,
+		MaxDownloads: req.MaxDownloads,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second).UTC()
+		link.ExpiresAt = &expiresAt
+	}
+
+	if err := h.shareService.CreateLink(c.Request.Context(), link, req.Password); err != nil {
+		h.logger.Error("Failed to create share link", logger.Error(err))
+		c.Error(errors.NewDatabaseError("share link creation", err))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Share link created successfully", link.ToResponse(h.shareURL(c, link)))
+}
+
+// GetShareLinksHandler lists the user's active share links, optionally of
+// one image
+func (h *Handler) GetShareLinksHandler(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	var imageID *primitive.ObjectID
+	if v := c.Query("image_id"); v != "" {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			c.Error(errors.NewValidationError("Invalid image ID"))
+			return
+		}
+		imageID = &id
+	}
+
+	links, err := h.shareService.GetActiveLinks(c.Request.Context(), userID, imageID)
+	if err != nil {
+		c.Error(errors.NewDatabaseError("share links retrieval", err))
+		return
+	}
+
+	responses := make([]*models.ShareLinkResponse, len(links))
+	for i, link := range links {
+		responses[i] = link.ToResponse(h.shareURL(c, link))
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Share links retrieved successfully", gin.H{"links": responses})
+}
+
+// RevokeShareLinkHandler revokes a share link
+func (h *Handler) RevokeShareLinkHandler(c *gin.Context) {
+	userIDStr := c.GetString("user_id")
+	if userIDStr == "" {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	link, err := h.shareService.GetLink(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.logger.Error("Failed to get share link", logger.Error(err))
+		c.Error(errors.NewDatabaseError("share link retrieval", err))
+		return
+	}
+
+	if link == nil {
+		c.Error(errors.NewNotFoundError("Share link"))
+		return
+	}
+
+	if link.UserID.Hex() != userIDStr {
+		c.Error(errors.NewUnauthorizedError("Not authorized to revoke this share link"))
+		return
+	}
+
+	if err := h.shareService.RevokeLink(c.Request.Context(), link); err != nil {
+		c.Error(errors.NewDatabaseError("share link revocation", err))
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Share link revoked successfully", nil)
+}
+
+// SharedFileHandler serves the file of a share link. It needs no account;
+// protected links take the password in the X-Share-Password header or a
+// password form field.
+func (h *Handler) SharedFileHandler(c *gin.Context) {
+	link, err := h.shareService.GetLinkByToken(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		h.logger.Error("Failed to get share link", logger.Error(err))
+		c.Error(errors.NewDatabaseError("share link retrieval", err))
+		return
+	}
+
+	if link == nil {
+		c.Error(errors.NewNotFoundError("Share link"))
+		return
+	}
+
+	if !link.Active(time.Now()) {
+		c.Error(errors.NewAppError(http.StatusGone, services.ErrShareUnavailable.Error(), nil))
+		return
+	}
+
+	password := c.GetHeader(sharePasswordHeader)
+	if password == "" {
+		password = c.PostForm("password")
+	}
+	if err := h.shareService.CheckPassword(link, password); err != nil {
+		c.Error(errors.NewUnauthorizedError("This share link needs a valid password"))
+		return
+	}
+
+	image, err := h.imageService.GetImage(link.ImageID.Hex())
+	if err != nil {
+		h.logger.Error("Failed to get image", logger.Error(err))
+		c.Error(errors.NewDatabaseError("image retrieval", err))
+		return
+	}
+
+	if image == nil {
+		c.Error(errors.NewNotFoundError("Image"))
+		return
+	}
+
+	key, contentType, filename, found := downloadTarget(image, link.ProcessedKey)
+	if !found {
+		c.Error(errors.NewNotFoundError("Processed image"))
+		return
+	}
+
+	data, err := h.fileService.GetFile(c.Request.Context(), key)
+	if err != nil {
+		h.logger.Error("Failed to get file", logger.Error(err))
+		c.Error(errors.NewFileError("download", err))
+		return
+	}
+
+	// Count the download last, so failed requests do not use up the limit
+	err = h.shareService.RecordDownload(c.Request.Context(), link)
+	if stderrors.Is(err, services.ErrShareUnavailable) {
+		c.Error(errors.NewAppError(http.StatusGone, err.Error(), nil))
+		return
+	}
+	if err != nil {
+		c.Error(errors.NewDatabaseError("share download", err))
+		return
+	}
+
+	disposition := "inline"
+	if c.Query("download") != "" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+	c.Header("Content-Length", strconv.Itoa(len(data)))
+	c.Header("Cache-Control", "private, no-store")
+
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// shareURL is the public URL of a share link
+func (h *Handler) shareURL(c *gin.Context, link *models.ShareLink) string {
+	return absoluteURL(c, "/s/"+link.Token)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/errors"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/services"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/utils"
+)
+
+// maxDerivativeAge is how long clients and CDNs may cache a rendering. The
+// original never changes, so a URL always renders the same bytes.
+const maxDerivativeAge = 365 * 24 * time.Hour
+
+// SignImageURLHandler issues a signed on-the-fly URL for an image
+func (h *Handler) SignImageURLHandler(c *gin.Context) {
+	imageID := c.Param("id")
+	spec := c.Query("t")
+
+	userIDStr := c.GetString("user_id")
+	if userIDStr == "" {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	transformations, _, err := services.ParseTransformSpec(spec)
+	if err != nil {
+		c.Error(errors.NewValidationError("Invalid transformation: " + err.Error()))
+		return
+	}
+
+	var expires int64
+	if v := c.Query("expires_in"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			c.Error(errors.NewValidationError("expires_in must be a positive number of seconds"))
+			return
+		}
+		expires = time.Now().Add(time.Duration(seconds) * time.Second).Unix()
+	}
+
+	image, err := h.imageService.GetImage(imageID)
+	if err != nil {
+		h.logger.Error("Failed to get image", logger.Error(err))
+		c.Error(errors.NewDatabaseError("image retrieval", err))
+		return
+	}
+
+	if image == nil {
+		c.Error(errors.NewNotFoundError("Image"))
+		return
+	}
+
+	if image.UserID.Hex() != userIDStr {
+		c.Error(errors.NewUnauthorizedError("Not authorized to access this image"))
+		return
+	}
+
+	path := h.urlSigner.Path(imageID, spec, expires)
+	result := gin.H{
+		"url":             absoluteURL(c, path),
+		"path":            path,
+		"transformations": transformations,
+	}
+	if expires > 0 {
+		result["expires_at"] = time.Unix(expires, 0).UTC()
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Image URL signed successfully", result)
+}
+
+// absoluteURL turns a path on this service into a full URL; absolute URLs,
+// such as presigned S3 URLs, are returned unchanged
+func absoluteURL(c *gin.Context, path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + c.Request.Host + path
+}
+
+// OnTheFlyImageHandler renders an image from a signed URL such as
+// /i/:id/w_300,h_200,fit_cover,f_png?s=<signature>
+func (h *Handler) OnTheFlyImageHandler(c *gin.Context) {
+	imageID := c.Param("id")
+	spec := c.Param("transform")
+
+	var expires int64
+	if v := c.Query("e"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.Error(errors.NewForbiddenError("Invalid image URL signature"))
+			return
+		}
+		expires = parsed
+	}
+
+	if err := h.urlSigner.Verify(imageID, spec, c.Query("s"), expires, time.Now()); err != nil {
+		c.Error(errors.NewForbiddenError("Invalid image URL: " + err.Error()))
+		return
+	}
+
+	transformations, canonical, err := services.ParseTransformSpec(spec)
+	if err != nil {
+		c.Error(errors.NewValidationError("Invalid transformation: " + err.Error()))
+		return
+	}
+
+	image, err := h.imageService.GetImage(imageID)
+	if err != nil {
+		h.logger.Error("Failed to get image", logger.Error(err))
+		c.Error(errors.NewDatabaseError("image retrieval", err))
+		return
+	}
+
+	if image == nil {
+		c.Error(errors.NewNotFoundError("Image"))
+		return
+	}
+
+	// f_auto renders per Accept header; caches must keep the variants apart
+	if services.HasAutoFormat(transformations) {
+		format := services.NegotiateFormat(c.GetHeader("Accept"), image.ContentType)
+		transformations = services.ResolveAutoFormat(transformations, format)
+		canonical = services.ResolveAutoSpec(canonical, format)
+		c.Header("Vary", "Accept")
+	}
+
+	// Cached renderings count towards the owner's quota. Once it is full new
+	// renderings are still served, but no longer cached.
+	stored := false
+	store := func() bool {
+		stored = h.derivativeQuotaLeft(c, image)
+		return stored
+	}
+	data, contentType, cached, err := h.imageService.RenderDerivative(c.Request.Context(), image, transformations, canonical, store)
+	if stderrors.Is(err, services.ErrImageTooLarge) {
+		c.Error(errors.NewAppError(http.StatusRequestEntityTooLarge, "Image dimensions too large: "+err.Error(), nil))
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to render image", logger.Error(err))
+		c.Error(errors.NewInternalError("Image rendering failed", err))
+		return
+	}
+	if stored {
+		h.imageService.RecordDerivative(c.Request.Context(), image, canonical, int64(len(data)))
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	maxAge := maxDerivativeAge
+	cacheControl := "public, max-age=" + strconv.Itoa(int(maxAge.Seconds())) + ", immutable"
+	if expires > 0 {
+		// Do not let caches serve the image past the URL's expiry
+		maxAge = min(maxAge, time.Until(time.Unix(expires, 0)))
+		cacheControl = "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", cacheControl)
+	if cached {
+		c.Header("X-Cache", "HIT")
+	} else {
+		c.Header("X-Cache", "MISS")
+	}
+
+	if match := c.GetHeader("If-None-Match"); match == "*" || strings.Contains(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Header("Content-Length", strconv.Itoa(len(data)))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// derivativeQuotaLeft reports whether the image owner's plan has room to
+// cache another rendering
+func (h *Handler) derivativeQuotaLeft(c *gin.Context, image *models.Image) bool {
+	owner, err := h.userService.GetUser(image.UserID.Hex())
+	if err != nil || owner == nil {
+		h.logger.Warn("Failed to get image owner", logger.String("image_id", image.ID.Hex()), logger.Error(err))
+		return false
+	}
+
+	plan := h.cfg.PlanLimits(owner.Plan)
+	if err := h.imageService.CheckQuota(c.Request.Context(), image.UserID, plan, 0, 0); err != nil {
+		if !stderrors.Is(err, services.ErrQuotaExceeded) {
+			h.logger.Warn("Failed to check quota", logger.String("user_id", image.UserID.Hex()), logger.Error(err))
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/errors"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetUsageHandler returns the user's storage usage and plan limits
+func (h *Handler) GetUsageHandler(c *gin.Context) {
+	userIDStr := c.GetString("user_id")
+	if userIDStr == "" {
+		c.Error(errors.NewUnauthorizedError("User not authenticated"))
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.Error(errors.NewValidationError("Invalid user ID"))
+		return
+	}
+
+	usage, err := h.imageService.GetUsage(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to get usage", logger.Error(err))
+		c.Error(errors.NewDatabaseError("usage retrieval", err))
+		return
+	}
+
+	plan := c.GetString("plan")
+	utils.SuccessResponse(c, http.StatusOK, "Usage retrieved successfully", gin.H{
+		"plan":   plan,
+		"usage":  usage,
+		"limits": h.cfg.PlanLimits(plan),
+		"uploads": gin.H{
+			"max_dimension":  h.cfg.MaxImageDimension,
+			"max_megapixels": h.cfg.MaxImageMegapixels,
+		},
+	})
+}
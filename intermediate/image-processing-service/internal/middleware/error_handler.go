@@ -100,11 +100,33 @@ type rateLimitEntry struct {
 	count       int
 }
 
+// RateLimitMiddleware allows limit requests per user, or per client IP for
+// anonymous requests, in each window
 func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
+	return rateLimit(window, func(c *gin.Context) int { return limit })
+}
+
+// PlanRateLimitMiddleware allows ProcessPerMinute requests per minute to each
+// user, by the plan in their token. Must run after AuthMiddleware.
+func PlanRateLimitMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return rateLimit(time.Minute, func(c *gin.Context) int {
+		return cfg.PlanLimits(c.GetString("plan")).ProcessPerMinute
+	})
+}
+
+// rateLimit counts requests in fixed windows; a limit of zero or less is
+// unlimited
+func rateLimit(window time.Duration, limitFor func(c *gin.Context) int) gin.HandlerFunc {
 	var mu sync.Mutex
 	entries := make(map[string]rateLimitEntry)
 
 	return func(c *gin.Context) {
+		limit := limitFor(c)
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
 		userID := c.GetString("user_id")
 		if userID == "" {
 			userID = c.ClientIP()
@@ -136,6 +158,13 @@ func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
 	}
 }
 
+// Claims are the claims of the tokens issued at sign up and login. Plan is
+// the user's plan when the token was issued.
+type Claims struct {
+	Plan string `json:"plan,omitempty"`
+	jwt.RegisteredClaims
+}
+
 func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -147,7 +176,7 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 
 		tokenString := strings.TrimSpace(authHeader[len("Bearer "):])
 
-		token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, errors.NewUnauthorizedError("Unexpected signing method")
 			}
@@ -159,7 +188,7 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		claims, ok := token.Claims.(*jwt.RegisteredClaims)
+		claims, ok := token.Claims.(*Claims)
 		if !ok || !token.Valid {
 			c.Error(errors.NewUnauthorizedError("Invalid token"))
 			c.Abort()
@@ -178,7 +207,13 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		plan := claims.Plan
+		if plan == "" {
+			plan = config.DefaultPlan
+		}
+
 		c.Set("user_id", claims.Subject)
+		c.Set("plan", plan)
 		c.Next()
 	}
 }
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Album groups a user's images. Membership is stored on the images, in
+// Image.AlbumIDs, so an image can be in several albums.
+type Album struct {
+	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	UserID       primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	Name         string              `json:"name" bson:"name"`
+	Description  string              `json:"description" bson:"description"`
+	CoverImageID *primitive.ObjectID `json:"cover_image_id,omitempty" bson:"cover_image_id,omitempty"`
+	CreatedAt    time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at" bson:"updated_at"`
+}
+
+// AlbumResponse represents the response for album operations
+type AlbumResponse struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	CoverImageID string    `json:"cover_image_id,omitempty"`
+	ImageCount   int64     `json:"image_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ToResponse converts Album model to AlbumResponse
+func (a *Album) ToResponse(imageCount int64) *AlbumResponse {
+	response := &AlbumResponse{
+		ID:          a.ID.Hex(),
+		Name:        a.Name,
+		Description: a.Description,
+		ImageCount:  imageCount,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+	if a.CoverImageID != nil {
+		response.CoverImageID = a.CoverImageID.Hex()
+	}
+	return response
+}
+
+// CreateAlbumRequest represents the request body for creating an album
+type CreateAlbumRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// UpdateAlbumRequest represents the request body for updating an album.
+// Omitted fields are left unchanged; an empty cover_image_id removes the cover.
+type UpdateAlbumRequest struct {
+	Name         *string `json:"name"`
+	Description  *string `json:"description"`
+	CoverImageID *string `json:"cover_image_id"`
+}
+
+// AlbumImagesRequest represents the request body for adding images to an album
+type AlbumImagesRequest struct {
+	ImageIDs []string `json:"image_ids" binding:"required"`
+}
+
+// ImageTagsRequest represents the request body for setting an image's tags
+type ImageTagsRequest struct {
+	Tags []string `json:"tags"`
+}
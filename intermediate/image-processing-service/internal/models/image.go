@@ -8,30 +8,55 @@ import (
 
 // Image represents an image record in the database
 type Image struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	UserID      primitive.ObjectID `json:"user_id" bson:"user_id"`
-	OriginalKey string             `json:"original_key" bson:"original_key"`
-	Filename    string             `json:"filename" bson:"filename"`
-	ContentType string             `json:"content_type" bson:"content_type"`
-	Size        int64              `json:"size" bson:"size"`
-	Width       int                `json:"width" bson:"width"`
-	Height      int                `json:"height" bson:"height"`
-	Status      ImageStatus        `json:"status" bson:"status"`
-	Processed   []ProcessedImage   `json:"processed" bson:"processed"`
-	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
+	ID          primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	UserID      primitive.ObjectID   `json:"user_id" bson:"user_id"`
+	OriginalKey string               `json:"original_key" bson:"original_key"`
+	Filename    string               `json:"filename" bson:"filename"`
+	ContentType string               `json:"content_type" bson:"content_type"`
+	Size        int64                `json:"size" bson:"size"`
+	Width       int                  `json:"width" bson:"width"`
+	Height      int                  `json:"height" bson:"height"`
+	Hashes      *ImageHashes         `json:"hashes,omitempty" bson:"hashes,omitempty"`
+	Tags        []string             `json:"tags,omitempty" bson:"tags,omitempty"`
+	AlbumIDs    []primitive.ObjectID `json:"album_ids,omitempty" bson:"album_ids,omitempty"`
+	Status      ImageStatus          `json:"status" bson:"status"`
+	Processed   []ProcessedImage     `json:"processed" bson:"processed"`
+	Derivatives []Derivative         `json:"-" bson:"derivatives,omitempty"`
+	CreatedAt   time.Time            `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+// ImageHashes are perceptual hashes of the original, as 16 hex digits each
+type ImageHashes struct {
+	AHash string `json:"ahash" bson:"ahash"`
+	DHash string `json:"dhash" bson:"dhash"`
+	PHash string `json:"phash" bson:"phash"`
 }
 
 // ProcessedImage represents a processed version of an image
 type ProcessedImage struct {
-	Key         string            `json:"key" bson:"key"`
-	Operation   string            `json:"operation" bson:"operation"`
-	Parameters  map[string]string `json:"parameters" bson:"parameters"`
-	ContentType string            `json:"content_type" bson:"content_type"`
-	Size        int64             `json:"size" bson:"size"`
-	Width       int               `json:"width" bson:"width"`
-	Height      int               `json:"height" bson:"height"`
-	CreatedAt   time.Time         `json:"created_at" bson:"created_at"`
+	Key             string            `json:"key" bson:"key"`
+	Operation       string            `json:"operation" bson:"operation"`
+	Parameters      map[string]string `json:"parameters" bson:"parameters"`
+	Transformations []Transformation  `json:"transformations,omitempty" bson:"transformations,omitempty"`
+	ContentType     string            `json:"content_type" bson:"content_type"`
+	Size            int64             `json:"size" bson:"size"`
+	Width           int               `json:"width" bson:"width"`
+	Height          int               `json:"height" bson:"height"`
+	CreatedAt       time.Time         `json:"created_at" bson:"created_at"`
+}
+
+// Derivative is a cached on-the-fly rendering, kept so it counts towards
+// the owner's usage
+type Derivative struct {
+	Key  string `bson:"key"`
+	Size int64  `bson:"size"`
+}
+
+// Transformation is one step of a processing pipeline
+type Transformation struct {
+	Operation  string            `json:"operation" bson:"operation"`
+	Parameters map[string]string `json:"parameters,omitempty" bson:"parameters,omitempty"`
 }
 
 // ImageStatus represents the status of an image
@@ -49,28 +74,52 @@ type UploadImageRequest struct {
 	Filename string `json:"filename" binding:"required"`
 }
 
-// ProcessImageRequest represents the request for processing an image
+// ProcessImageRequest represents the request for processing an image.
+// Either a single operation or a list of transformations applied in order.
 type ProcessImageRequest struct {
-	Operation  string            `json:"operation" binding:"required"`
-	Parameters map[string]string `json:"parameters"`
+	Operation       string            `json:"operation"`
+	Parameters      map[string]string `json:"parameters"`
+	Transformations []Transformation  `json:"transformations"`
+	// WebhookURL, if set, receives a POST when the job completes or fails
+	WebhookURL string `json:"webhook_url"`
+}
+
+// Pipeline returns the requested transformations; a single operation is a
+// pipeline of one step
+func (r *ProcessImageRequest) Pipeline() []Transformation {
+	if len(r.Transformations) > 0 {
+		return r.Transformations
+	}
+	if r.Operation == "" {
+		return nil
+	}
+	return []Transformation{{Operation: r.Operation, Parameters: r.Parameters}}
 }
 
 // ImageResponse represents the response for image operations
 type ImageResponse struct {
-	ID          string             `json:"id"`
-	Filename    string             `json:"filename"`
-	ContentType string             `json:"content_type"`
-	Size        int64              `json:"size"`
-	Width       int                `json:"width"`
-	Height      int                `json:"height"`
-	Status      ImageStatus        `json:"status"`
-	Processed   []ProcessedImage   `json:"processed"`
-	CreatedAt   time.Time          `json:"created_at"`
-	UpdatedAt   time.Time          `json:"updated_at"`
+	ID          string           `json:"id"`
+	Filename    string           `json:"filename"`
+	ContentType string           `json:"content_type"`
+	Size        int64            `json:"size"`
+	Width       int              `json:"width"`
+	Height      int              `json:"height"`
+	Hashes      *ImageHashes     `json:"hashes,omitempty"`
+	Tags        []string         `json:"tags,omitempty"`
+	AlbumIDs    []string         `json:"album_ids,omitempty"`
+	Status      ImageStatus      `json:"status"`
+	Processed   []ProcessedImage `json:"processed"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
 }
 
 // ToResponse converts Image model to ImageResponse
 func (i *Image) ToResponse() *ImageResponse {
+	var albumIDs []string
+	for _, id := range i.AlbumIDs {
+		albumIDs = append(albumIDs, id.Hex())
+	}
+
 	return &ImageResponse{
 		ID:          i.ID.Hex(),
 		Filename:    i.Filename,
@@ -78,6 +127,9 @@ func (i *Image) ToResponse() *ImageResponse {
 		Size:        i.Size,
 		Width:       i.Width,
 		Height:      i.Height,
+		Hashes:      i.Hashes,
+		Tags:        i.Tags,
+		AlbumIDs:    albumIDs,
 		Status:      i.Status,
 		Processed:   i.Processed,
 		CreatedAt:   i.CreatedAt,
@@ -85,6 +137,19 @@ func (i *Image) ToResponse() *ImageResponse {
 	}
 }
 
+// UploadImageResponse is an uploaded image with the user's existing images
+// that look the same
+type UploadImageResponse struct {
+	*ImageResponse
+	Duplicates []*SimilarImageResponse `json:"duplicates,omitempty"`
+}
+
+// SimilarImageResponse is an image with its hash distance to another image
+type SimilarImageResponse struct {
+	Image    *ImageResponse `json:"image"`
+	Distance int            `json:"distance"`
+}
+
 // ImageOperation represents supported image operations
 type ImageOperation string
 
@@ -99,8 +164,19 @@ const (
 	OperationBrightness ImageOperation = "brightness"
 	OperationContrast   ImageOperation = "contrast"
 	OperationCompress   ImageOperation = "compress"
+	OperationConvert    ImageOperation = "convert"
+	OperationWatermark  ImageOperation = "watermark"
+	OperationText       ImageOperation = "text"
+	OperationBorder     ImageOperation = "border"
+	OperationPad        ImageOperation = "pad"
+	OperationThumbnail  ImageOperation = "thumbnail"
+	// OperationPipeline names a processed image made by several transformations
+	OperationPipeline ImageOperation = "pipeline"
 )
 
+// MaxTransformations limits the length of a processing pipeline
+const MaxTransformations = 10
+
 // IsValidOperation checks if the operation is supported
 func IsValidOperation(operation string) bool {
 	validOps := map[string]bool{
@@ -114,6 +190,12 @@ func IsValidOperation(operation string) bool {
 		string(OperationBrightness): true,
 		string(OperationContrast):   true,
 		string(OperationCompress):   true,
+		string(OperationConvert):    true,
+		string(OperationWatermark):  true,
+		string(OperationText):       true,
+		string(OperationBorder):     true,
+		string(OperationPad):        true,
+		string(OperationThumbnail):  true,
 	}
 	return validOps[operation]
-}
\ No newline at end of file
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobStatus represents the state of a processing job
+type JobStatus string
+
+const (
+	JobStatusQueued     JobStatus = "queued"
+	JobStatusProcessing JobStatus = "processing"
+	JobStatusCompleted  JobStatus = "completed"
+	JobStatusFailed     JobStatus = "failed"
+)
+
+// Job is a persisted image processing request. Workers claim queued jobs by
+// taking a lease; a job whose lease runs out (the worker died) is claimed again.
+type Job struct {
+	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID          primitive.ObjectID `json:"user_id" bson:"user_id"`
+	ImageID         primitive.ObjectID `json:"image_id" bson:"image_id"`
+	Transformations []Transformation   `json:"transformations" bson:"transformations"`
+	Status          JobStatus          `json:"status" bson:"status"`
+	Progress        int                `json:"progress" bson:"progress"`
+	Attempts        int                `json:"attempts" bson:"attempts"`
+	MaxAttempts     int                `json:"max_attempts" bson:"max_attempts"`
+	Error           string             `json:"error,omitempty" bson:"error,omitempty"`
+	Result          *ProcessedImage    `json:"result,omitempty" bson:"result,omitempty"`
+	WebhookURL      string             `json:"webhook_url,omitempty" bson:"webhook_url,omitempty"`
+	WebhookError    string             `json:"webhook_error,omitempty" bson:"webhook_error,omitempty"`
+	WebhookSentAt   *time.Time         `json:"webhook_sent_at,omitempty" bson:"webhook_sent_at,omitempty"`
+	RunAt           time.Time          `json:"run_at" bson:"run_at"`
+	LeaseUntil      *time.Time         `json:"-" bson:"lease_until,omitempty"`
+	CreatedAt       time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at" bson:"updated_at"`
+	CompletedAt     *time.Time         `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+}
+
+// JobResponse represents the response for job operations
+type JobResponse struct {
+	ID              string           `json:"id"`
+	ImageID         string           `json:"image_id"`
+	Transformations []Transformation `json:"transformations"`
+	Status          JobStatus        `json:"status"`
+	Progress        int              `json:"progress"`
+	Attempts        int              `json:"attempts"`
+	MaxAttempts     int              `json:"max_attempts"`
+	Error           string           `json:"error,omitempty"`
+	Result          *ProcessedImage  `json:"result,omitempty"`
+	WebhookURL      string           `json:"webhook_url,omitempty"`
+	WebhookError    string           `json:"webhook_error,omitempty"`
+	WebhookSentAt   *time.Time       `json:"webhook_sent_at,omitempty"`
+	NextAttemptAt   *time.Time       `json:"next_attempt_at,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	CompletedAt     *time.Time       `json:"completed_at,omitempty"`
+}
+
+// ToResponse converts Job model to JobResponse
+func (j *Job) ToResponse() *JobResponse {
+	resp := &JobResponse{
+		ID:              j.ID.Hex(),
+		ImageID:         j.ImageID.Hex(),
+		Transformations: j.Transformations,
+		Status:          j.Status,
+		Progress:        j.Progress,
+		Attempts:        j.Attempts,
+		MaxAttempts:     j.MaxAttempts,
+		Error:           j.Error,
+		Result:          j.Result,
+		WebhookURL:      j.WebhookURL,
+		WebhookError:    j.WebhookError,
+		WebhookSentAt:   j.WebhookSentAt,
+		CreatedAt:       j.CreatedAt,
+		UpdatedAt:       j.UpdatedAt,
+		CompletedAt:     j.CompletedAt,
+	}
+	// A retry waiting for its backoff
+	if j.Status == JobStatusQueued && j.Attempts > 0 {
+		runAt := j.RunAt
+		resp.NextAttemptAt = &runAt
+	}
+	return resp
+}
+
+// JobWebhookPayload is posted to a job's webhook URL when it completes or fails
+type JobWebhookPayload struct {
+	Event string       `json:"event"`
+	Job   *JobResponse `json:"job"`
+}
+
+// Webhook events
+const (
+	JobEventCompleted = "job.completed"
+	JobEventFailed    = "job.failed"
+)
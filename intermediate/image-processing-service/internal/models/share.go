@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShareLink gives anyone with its token access to an original or a processed
+// version of an image, without an account
+type ShareLink struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Token  string             `json:"token" bson:"token"`
+	UserID primitive.ObjectID `json:"user_id" bson:"user_id"`
+	// ImageID and ProcessedKey name the shared file; an empty ProcessedKey
+	// shares the original
+	ImageID      primitive.ObjectID `json:"image_id" bson:"image_id"`
+	ProcessedKey string             `json:"processed_key,omitempty" bson:"processed_key,omitempty"`
+	PasswordHash string             `json:"-" bson:"password_hash,omitempty"`
+	ExpiresAt    *time.Time         `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	// MaxDownloads of 0 allows any number of downloads
+	MaxDownloads   int        `json:"max_downloads" bson:"max_downloads"`
+	Downloads      int        `json:"downloads" bson:"downloads"`
+	LastDownloadAt *time.Time `json:"last_download_at,omitempty" bson:"last_download_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// Active reports whether the link can still be used at the given time
+func (l *ShareLink) Active(now time.Time) bool {
+	if l.RevokedAt != nil {
+		return false
+	}
+	if l.ExpiresAt != nil && !now.Before(*l.ExpiresAt) {
+		return false
+	}
+	return l.MaxDownloads == 0 || l.Downloads < l.MaxDownloads
+}
+
+// ShareLinkResponse represents the response for share link operations
+type ShareLinkResponse struct {
+	ID             string     `json:"id"`
+	URL            string     `json:"url"`
+	ImageID        string     `json:"image_id"`
+	ProcessedKey   string     `json:"processed_key,omitempty"`
+	HasPassword    bool       `json:"has_password"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	MaxDownloads   int        `json:"max_downloads"`
+	Downloads      int        `json:"downloads"`
+	LastDownloadAt *time.Time `json:"last_download_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ToResponse converts ShareLink model to ShareLinkResponse; url is the
+// public URL of the link
+func (l *ShareLink) ToResponse(url string) *ShareLinkResponse {
+	return &ShareLinkResponse{
+		ID:             l.ID.Hex(),
+		URL:            url,
+		ImageID:        l.ImageID.Hex(),
+		ProcessedKey:   l.ProcessedKey,
+		HasPassword:    l.PasswordHash != "",
+		ExpiresAt:      l.ExpiresAt,
+		MaxDownloads:   l.MaxDownloads,
+		Downloads:      l.Downloads,
+		LastDownloadAt: l.LastDownloadAt,
+		CreatedAt:      l.CreatedAt,
+	}
+}
+
+// CreateShareLinkRequest represents the request body for creating a share
+// link. Zero values mean no expiry, no download limit and no password.
+type CreateShareLinkRequest struct {
+	ProcessedKey string `json:"processed_key"`
+	ExpiresIn    int    `json:"expires_in"`
+	MaxDownloads int    `json:"max_downloads"`
+	Password     string `json:"password"`
+}
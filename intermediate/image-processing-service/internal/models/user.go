@@ -11,10 +11,20 @@ type User struct {
 	Name      string             `json:"name" bson:"name" binding:"required"`
 	Email     string             `json:"email" bson:"email" binding:"required"`
 	Password  string             `json:"password" bson:"password" binding:"required"`
+	Plan      string             `json:"plan" bson:"plan"`
+	Usage     *Usage             `json:"usage,omitempty" bson:"usage,omitempty"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
+// Usage is the storage a user consumes: originals and processed versions.
+// Cached on-the-fly renderings are not counted.
+type Usage struct {
+	Bytes     int64     `json:"bytes" bson:"bytes"`
+	Images    int64     `json:"images" bson:"images"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
 // CreateUserRequest represents the request body for creating a user
 type CreateUserRequest struct {
 	Name     string `json:"name" binding:"required"`
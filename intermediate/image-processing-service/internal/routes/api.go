@@ -24,6 +24,18 @@ func SetupRouter(h *handlers.Handler, logger *zap.Logger, cfg *config.Config) *g
 	r.GET("/", h.HealthCheck)
 	r.GET("/health", h.HealthCheck)
 
+	// On-the-fly renderings; public, authorized by the URL signature
+	r.GET("/i/:id/:transform", middleware.RateLimitMiddleware(120, time.Minute), h.OnTheFlyImageHandler)
+
+	// Downloads from local storage; public, authorized by the URL signature
+	r.GET("/files/*key", middleware.RateLimitMiddleware(120, time.Minute), h.SignedFileHandler)
+
+	// Share links; public, authorized by the token and an optional password.
+	// The lower limit slows down password guessing.
+	shared := middleware.RateLimitMiddleware(30, time.Minute)
+	r.GET("/s/:token", shared, h.SharedFileHandler)
+	r.POST("/s/:token", shared, h.SharedFileHandler)
+
 	// API routes
 	api := r.Group("/api/v1")
 	{
@@ -39,11 +51,52 @@ func SetupRouter(h *handlers.Handler, logger *zap.Logger, cfg *config.Config) *g
 
 			images.POST("/upload", h.UploadImageHandler)
 			images.GET("/", h.GetUserImagesHandler)
+			images.GET("/search", h.SearchImagesHandler)
 			images.GET("/:id", h.GetImageHandler)
-			images.POST("/:id/process", middleware.RateLimitMiddleware(10, time.Minute), h.ProcessImageHandler)
+			images.GET("/:id/similar", h.SimilarImagesHandler)
+			images.PUT("/:id/tags", h.SetImageTagsHandler)
+			images.POST("/:id/shares", h.CreateShareLinkHandler)
+			images.POST("/:id/process", middleware.PlanRateLimitMiddleware(cfg), h.ProcessImageHandler)
 			images.GET("/:id/download", h.DownloadImageHandler)
+			images.GET("/:id/url", h.SignImageURLHandler)
+			images.GET("/:id/download-url", h.DownloadURLHandler)
 			images.DELETE("/:id", h.DeleteImageHandler)
 		}
+
+		albums := api.Group("/albums")
+		{
+			albums.Use(middleware.AuthMiddleware(cfg))
+
+			albums.POST("/", h.CreateAlbumHandler)
+			albums.GET("/", h.GetAlbumsHandler)
+			albums.GET("/:id", h.GetAlbumHandler)
+			albums.PATCH("/:id", h.UpdateAlbumHandler)
+			albums.DELETE("/:id", h.DeleteAlbumHandler)
+			albums.POST("/:id/images", h.AddAlbumImagesHandler)
+			albums.DELETE("/:id/images/:image_id", h.RemoveAlbumImageHandler)
+		}
+
+		shares := api.Group("/shares")
+		{
+			shares.Use(middleware.AuthMiddleware(cfg))
+
+			shares.GET("/", h.GetShareLinksHandler)
+			shares.DELETE("/:id", h.RevokeShareLinkHandler)
+		}
+
+		jobs := api.Group("/jobs")
+		{
+			jobs.Use(middleware.AuthMiddleware(cfg))
+
+			jobs.GET("/:id", h.GetJobHandler)
+		}
+
+		me := api.Group("/me")
+		{
+			me.Use(middleware.AuthMiddleware(cfg))
+
+			me.GET("/usage", h.GetUsageHandler)
+		}
 	}
 
 	return r
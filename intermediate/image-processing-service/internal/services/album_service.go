@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/database"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+)
+
+// ErrImageNotFound is returned when an album operation names an image the
+// user does not own
+var ErrImageNotFound = errors.New("image not found")
+
+// MaxAlbumImagesPerRequest limits the images added to an album at once
+const MaxAlbumImagesPerRequest = 100
+
+type AlbumService struct {
+	db     *database.MongoDB
+	logger *zap.Logger
+}
+
+func NewAlbumService(db *database.MongoDB, logger *zap.Logger) *AlbumService {
+	return &AlbumService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateIndexes creates the index listing a user's albums
+func (s *AlbumService) CreateIndexes(ctx context.Context) error {
+	_, err := s.db.Collection("albums").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		s.logger.Error("Failed to create album index", logger.Error(err))
+		return fmt.Errorf("failed to create album index: %w", err)
+	}
+	return nil
+}
+
+// CreateAlbum creates a new album record in the database
+func (s *AlbumService) CreateAlbum(ctx context.Context, album *models.Album) error {
+	album.ID = primitive.NewObjectID()
+	album.CreatedAt = time.Now()
+	album.UpdatedAt = album.CreatedAt
+
+	if _, err := s.db.Collection("albums").InsertOne(ctx, album); err != nil {
+		s.logger.Error("Failed to insert album", logger.Error(err))
+		return fmt.Errorf("failed to insert album: %w", err)
+	}
+
+	s.logger.Info("Album created successfully", logger.String("id", album.ID.Hex()))
+	return nil
+}
+
+// GetAlbum retrieves an album by ID
+func (s *AlbumService) GetAlbum(ctx context.Context, id string) (*models.Album, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid album ID: %w", err)
+	}
+
+	var album models.Album
+	err = s.db.Collection("albums").FindOne(ctx, bson.M{"_id": objectID}).Decode(&album)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		s.logger.Error("Failed to get album", logger.Error(err))
+		return nil, err
+	}
+
+	return &album, nil
+}
+
+// GetUserAlbums retrieves all albums of a user, newest first
+func (s *AlbumService) GetUserAlbums(ctx context.Context, userID primitive.ObjectID) ([]*models.Album, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := s.db.Collection("albums").Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		s.logger.Error("Failed to find albums", logger.Error(err))
+		return nil, fmt.Errorf("failed to find albums: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	albums := []*models.Album{}
+	if err := cursor.All(ctx, &albums); err != nil {
+		s.logger.Error("Failed to decode albums", logger.Error(err))
+		return nil, fmt.Errorf("failed to decode albums: %w", err)
+	}
+
+	return albums, nil
+}
+
+// CountImages returns the number of images in each of a user's albums
+func (s *AlbumService) CountImages(ctx context.Context, userID primitive.ObjectID) (map[primitive.ObjectID]int64, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"user_id": userID, "album_ids.0": bson.M{"$exists": true}}},
+		bson.M{"$unwind": "$album_ids"},
+		bson.M{"$group": bson.M{"_id": "$album_ids", "count": bson.M{"$sum": 1}}},
+	}
+
+	cursor, err := s.db.Collection("images").Aggregate(ctx, pipeline)
+	if err != nil {
+		s.logger.Error("Failed to count album images", logger.Error(err))
+		return nil, fmt.Errorf("failed to count album images: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Count int64              `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode album counts: %w", err)
+	}
+
+	counts := make(map[primitive.ObjectID]int64, len(results))
+	for _, r := range results {
+		counts[r.ID] = r.Count
+	}
+	return counts, nil
+}
+
+// UpdateAlbum saves the name, description and cover of an album
+func (s *AlbumService) UpdateAlbum(ctx context.Context, album *models.Album) error {
+	album.UpdatedAt = time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"name":        album.Name,
+			"description": album.Description,
+			"updated_at":  album.UpdatedAt,
+		},
+	}
+	if album.CoverImageID != nil {
+		update["$set"].(bson.M)["cover_image_id"] = *album.CoverImageID
+	} else {
+		update["$unset"] = bson.M{"cover_image_id": ""}
+	}
+
+	if _, err := s.db.Collection("albums").UpdateOne(ctx, bson.M{"_id": album.ID}, update); err != nil {
+		s.logger.Error("Failed to update album", logger.Error(err))
+		return fmt.Errorf("failed to update album: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAlbum deletes an album; its images are kept
+func (s *AlbumService) DeleteAlbum(ctx context.Context, album *models.Album) error {
+	_, err := s.db.Collection("images").UpdateMany(ctx,
+		bson.M{"user_id": album.UserID, "album_ids": album.ID},
+		bson.M{"$pull": bson.M{"album_ids": album.ID}},
+	)
+	if err != nil {
+		s.logger.Error("Failed to remove images from album", logger.Error(err))
+		return fmt.Errorf("failed to remove images from album: %w", err)
+	}
+
+	if _, err := s.db.Collection("albums").DeleteOne(ctx, bson.M{"_id": album.ID}); err != nil {
+		s.logger.Error("Failed to delete album", logger.Error(err))
+		return fmt.Errorf("failed to delete album: %w", err)
+	}
+
+	s.logger.Info("Album deleted successfully", logger.String("album_id", album.ID.Hex()))
+	return nil
+}
+
+// AddImages adds images to an album. All images must belong to the album's
+// owner, otherwise nothing is added and ErrImageNotFound is returned.
+func (s *AlbumService) AddImages(ctx context.Context, album *models.Album, imageIDs []primitive.ObjectID) error {
+	collection := s.db.Collection("images")
+	filter := bson.M{"_id": bson.M{"$in": imageIDs}, "user_id": album.UserID}
+
+	owned, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		s.logger.Error("Failed to count images", logger.Error(err))
+		return fmt.Errorf("failed to count images: %w", err)
+	}
+	if owned != int64(len(imageIDs)) {
+		return ErrImageNotFound
+	}
+
+	if _, err := collection.UpdateMany(ctx, filter, bson.M{"$addToSet": bson.M{"album_ids": album.ID}}); err != nil {
+		s.logger.Error("Failed to add images to album", logger.Error(err))
+		return fmt.Errorf("failed to add images to album: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveImage removes an image from an album, and clears the cover if it
+// was the cover. It returns false if the image was not in the album.
+func (s *AlbumService) RemoveImage(ctx context.Context, album *models.Album, imageID primitive.ObjectID) (bool, error) {
+	result, err := s.db.Collection("images").UpdateOne(ctx,
+		bson.M{"_id": imageID, "user_id": album.UserID, "album_ids": album.ID},
+		bson.M{"$pull": bson.M{"album_ids": album.ID}},
+	)
+	if err != nil {
+		s.logger.Error("Failed to remove image from album", logger.Error(err))
+		return false, fmt.Errorf("failed to remove image from album: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return false, nil
+	}
+
+	if album.CoverImageID != nil && *album.CoverImageID == imageID {
+		album.CoverImageID = nil
+		if err := s.UpdateAlbum(ctx, album); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// ContainsImage reports whether an image is in an album
+func (s *AlbumService) ContainsImage(ctx context.Context, album *models.Album, imageID primitive.ObjectID) (bool, error) {
+	count, err := s.db.Collection("images").CountDocuments(ctx, bson.M{"_id": imageID, "album_ids": album.ID})
+	if err != nil {
+		s.logger.Error("Failed to count images", logger.Error(err))
+		return false, fmt.Errorf("failed to count images: %w", err)
+	}
+	return count > 0, nil
+}
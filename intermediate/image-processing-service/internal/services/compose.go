@@ -0,0 +1,479 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/disintegration/imaging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Limits of the compositing operations
+const (
+	MaxTextLength = 200
+	maxTextSize   = 512
+	maxMargin     = 1000
+	maxBorderSize = 1000
+)
+
+// positions maps the "position" parameter to horizontal and vertical
+// alignment: 0 start, 1 center, 2 end
+var positions = map[string][2]int{
+	"top-left":     {0, 0},
+	"top":          {1, 0},
+	"top-right":    {2, 0},
+	"left":         {0, 1},
+	"center":       {1, 1},
+	"right":        {2, 1},
+	"bottom-left":  {0, 2},
+	"bottom":       {1, 2},
+	"bottom-right": {2, 2},
+}
+
+// fonts are the bundled Go fonts, selected with the "font" parameter
+var fonts = map[string][]byte{
+	"regular": goregular.TTF,
+	"bold":    gobold.TTF,
+	"mono":    gomono.TTF,
+}
+
+var (
+	parsedFonts   = map[string]*opentype.Font{}
+	parsedFontsMu sync.Mutex
+)
+
+func loadFont(name string) (*opentype.Font, error) {
+	parsedFontsMu.Lock()
+	defer parsedFontsMu.Unlock()
+
+	if f, ok := parsedFonts[name]; ok {
+		return f, nil
+	}
+	data, ok := fonts[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported font: %s", name)
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	parsedFonts[name] = f
+	return f, nil
+}
+
+// placement returns the top-left corner of a box of the given size placed at
+// a position inside the canvas, margin pixels from the edges it is aligned to
+func placement(position string, canvas image.Rectangle, size image.Point, margin int) image.Point {
+	align, ok := positions[strings.ToLower(position)]
+	if !ok {
+		align = positions["center"]
+	}
+	offset := func(a, space, length int) int {
+		switch a {
+		case 0:
+			return margin
+		case 2:
+			return space - length - margin
+		default:
+			return (space - length) / 2
+		}
+	}
+	return image.Pt(
+		canvas.Min.X+offset(align[0], canvas.Dx(), size.X),
+		canvas.Min.Y+offset(align[1], canvas.Dy(), size.Y),
+	)
+}
+
+// parseHexColor parses #rgb, #rrggbb or #rrggbbaa, with or without the #
+func parseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// colorParam returns a color parameter, or the default when it is not set
+func colorParam(parameters map[string]string, key string, def color.NRGBA) (color.NRGBA, error) {
+	if v := parameters[key]; v != "" {
+		return parseHexColor(v)
+	}
+	return def, nil
+}
+
+// intParam returns an integer parameter within [min, max], or the default
+// when it is not set
+func intParam(parameters map[string]string, key string, def, minValue, maxValue int) (int, error) {
+	v := parameters[key]
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < minValue || n > maxValue {
+		return 0, fmt.Errorf("%s must be between %d and %d", key, minValue, maxValue)
+	}
+	return n, nil
+}
+
+// floatParam returns a number parameter within [min, max], or the default
+// when it is not set
+func floatParam(parameters map[string]string, key string, def, minValue, maxValue float64) (float64, error) {
+	v := parameters[key]
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < minValue || f > maxValue {
+		return 0, fmt.Errorf("%s must be between %g and %g", key, minValue, maxValue)
+	}
+	return f, nil
+}
+
+// positionParam returns the "position" parameter, or the default when it is not set
+func positionParam(parameters map[string]string, def string) string {
+	if v := parameters["position"]; v != "" {
+		return v
+	}
+	return def
+}
+
+func validatePosition(parameters map[string]string) error {
+	if v := parameters["position"]; v != "" {
+		if _, ok := positions[strings.ToLower(v)]; !ok {
+			return fmt.Errorf("unsupported position: %s", v)
+		}
+	}
+	return nil
+}
+
+// validateWatermark checks the parameters of a watermark step:
+// image_id (required), position, opacity (0-1), scale (0-1) and margin
+func validateWatermark(parameters map[string]string) error {
+	if _, err := primitive.ObjectIDFromHex(parameters["image_id"]); err != nil {
+		return fmt.Errorf("image_id must be the ID of one of your images")
+	}
+	if err := validatePosition(parameters); err != nil {
+		return err
+	}
+	if _, err := floatParam(parameters, "opacity", 0.5, 0, 1); err != nil {
+		return err
+	}
+	if scale, err := floatParam(parameters, "scale", 0.2, 0, 1); err != nil || scale == 0 {
+		return fmt.Errorf("scale must be greater than 0 and at most 1")
+	}
+	_, err := intParam(parameters, "margin", 10, 0, maxMargin)
+	return err
+}
+
+// applyWatermark draws the overlay on the image. The overlay is scaled to a
+// fraction of the image's width, keeping its aspect ratio.
+func applyWatermark(src, overlay image.Image, parameters map[string]string) (image.Image, error) {
+	if overlay == nil {
+		return nil, fmt.Errorf("watermark image not loaded")
+	}
+	opacity, err := floatParam(parameters, "opacity", 0.5, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	scale, err := floatParam(parameters, "scale", 0.2, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	margin, err := intParam(parameters, "margin", 10, 0, maxMargin)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width := max(1, int(math.Round(float64(bounds.Dx())*scale)))
+	mark := imaging.Resize(overlay, width, 0, imaging.Lanczos)
+
+	pos := placement(positionParam(parameters, "bottom-right"), bounds, mark.Bounds().Size(), margin)
+	return imaging.Overlay(src, mark, pos, opacity), nil
+}
+
+// validateText checks the parameters of a text step: text (required), font,
+// size, color, position and margin
+func validateText(parameters map[string]string) error {
+	text := parameters["text"]
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("text is required")
+	}
+	if utf8.RuneCountInString(text) > MaxTextLength {
+		return fmt.Errorf("text must be at most %d characters", MaxTextLength)
+	}
+	if v := parameters["font"]; v != "" {
+		if _, ok := fonts[strings.ToLower(v)]; !ok {
+			return fmt.Errorf("unsupported font: %s", v)
+		}
+	}
+	if _, err := intParam(parameters, "size", 32, 4, maxTextSize); err != nil {
+		return err
+	}
+	if _, err := colorParam(parameters, "color", color.NRGBA{}); err != nil {
+		return err
+	}
+	if err := validatePosition(parameters); err != nil {
+		return err
+	}
+	_, err := intParam(parameters, "margin", 10, 0, maxMargin)
+	return err
+}
+
+// applyText renders a line of text with one of the bundled fonts
+func applyText(src image.Image, parameters map[string]string) (image.Image, error) {
+	name := strings.ToLower(parameters["font"])
+	if name == "" {
+		name = "regular"
+	}
+	f, err := loadFont(name)
+	if err != nil {
+		return nil, err
+	}
+	size, err := intParam(parameters, "size", 32, 4, maxTextSize)
+	if err != nil {
+		return nil, err
+	}
+	textColor, err := colorParam(parameters, "color", color.NRGBA{255, 255, 255, 255})
+	if err != nil {
+		return nil, err
+	}
+	margin, err := intParam(parameters, "margin", 10, 0, maxMargin)
+	if err != nil {
+		return nil, err
+	}
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: float64(size), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	// Newlines would render as missing glyphs
+	text := strings.Join(strings.Fields(parameters["text"]), " ")
+	metrics := face.Metrics()
+	textSize := image.Pt(font.MeasureString(face, text).Ceil(), (metrics.Ascent + metrics.Descent).Ceil())
+
+	dst := imaging.Clone(src)
+	pos := placement(positionParam(parameters, "bottom-right"), dst.Bounds(), textSize, margin)
+	drawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+		Dot:  fixed.Point26_6{X: fixed.I(pos.X), Y: fixed.I(pos.Y) + metrics.Ascent},
+	}
+	drawer.DrawString(text)
+	return dst, nil
+}
+
+// validateBorder checks the parameters of a border step: size and color
+func validateBorder(parameters map[string]string) error {
+	if _, err := intParam(parameters, "size", 10, 1, maxBorderSize); err != nil {
+		return err
+	}
+	_, err := colorParam(parameters, "color", color.NRGBA{})
+	return err
+}
+
+// applyBorder surrounds the image with a solid border, growing it by twice
+// the border size
+func applyBorder(src image.Image, parameters map[string]string) (image.Image, error) {
+	size, err := intParam(parameters, "size", 10, 1, maxBorderSize)
+	if err != nil {
+		return nil, err
+	}
+	borderColor, err := colorParam(parameters, "color", color.NRGBA{0, 0, 0, 255})
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	if bounds.Dx()+2*size > MaxOutputDimension || bounds.Dy()+2*size > MaxOutputDimension {
+		return nil, fmt.Errorf("bordered image would exceed %d pixels", MaxOutputDimension)
+	}
+	dst := imaging.New(bounds.Dx()+2*size, bounds.Dy()+2*size, borderColor)
+	return imaging.Paste(dst, src, image.Pt(size, size)), nil
+}
+
+// validatePad checks the parameters of a pad step: width and height of the
+// canvas (both required), color and position
+func validatePad(parameters map[string]string) error {
+	width, height, err := parseDimensions(parameters)
+	if err != nil {
+		return err
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("width and height must be greater than zero for pad")
+	}
+	if width > MaxOutputDimension || height > MaxOutputDimension {
+		return fmt.Errorf("width and height must be at most %d", MaxOutputDimension)
+	}
+	if _, err := colorParam(parameters, "color", color.NRGBA{}); err != nil {
+		return err
+	}
+	return validatePosition(parameters)
+}
+
+// applyPad places the image on a canvas of exactly width x height, shrinking
+// it first if it does not fit. The default background is white; use a
+// transparent color such as #00000000 with PNG or WebP output.
+func applyPad(src image.Image, parameters map[string]string) (image.Image, error) {
+	width, height, err := parseDimensions(parameters)
+	if err != nil {
+		return nil, err
+	}
+	background, err := colorParam(parameters, "color", color.NRGBA{255, 255, 255, 255})
+	if err != nil {
+		return nil, err
+	}
+
+	fitted := src
+	if b := src.Bounds(); b.Dx() > width || b.Dy() > height {
+		fitted = imaging.Fit(src, width, height, imaging.Lanczos)
+	}
+	canvas := imaging.New(width, height, background)
+	pos := placement(positionParam(parameters, "center"), canvas.Bounds(), fitted.Bounds().Size(), 0)
+	return imaging.Overlay(canvas, fitted, pos, 1), nil
+}
+
+// validateThumbnail checks the parameters of a thumbnail step: width and
+// height (both required), focus, focus_x and focus_y
+func validateThumbnail(parameters map[string]string) error {
+	width, height, err := parseDimensions(parameters)
+	if err != nil {
+		return err
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("width and height must be greater than zero for thumbnail")
+	}
+	if width > MaxOutputDimension || height > MaxOutputDimension {
+		return fmt.Errorf("width and height must be at most %d", MaxOutputDimension)
+	}
+	if v := strings.ToLower(parameters["focus"]); v != "" && v != "auto" {
+		if _, ok := positions[v]; !ok {
+			return fmt.Errorf("unsupported focus: %s", parameters["focus"])
+		}
+	}
+	for _, key := range []string{"focus_x", "focus_y"} {
+		if _, err := floatParam(parameters, key, 0.5, 0, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyThumbnail crops the image to the aspect ratio of width x height
+// around a focal point and scales the crop to exactly width x height. The
+// focal point is given as fractions (focus_x, focus_y), as a position name,
+// or found with focus=auto, which keeps the most detailed part of the image.
+// Cropping first means only the thumbnail itself is ever scaled.
+func applyThumbnail(src image.Image, parameters map[string]string) (image.Image, error) {
+	width, height, err := parseDimensions(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	window := coverWindow(b, width, height, 0.5, 0.5)
+	focus := strings.ToLower(parameters["focus"])
+	if focus == "auto" {
+		x0, y0 := detailedWindow(imaging.Clone(src), window.Dx(), window.Dy())
+		window = image.Rect(x0, y0, x0+window.Dx(), y0+window.Dy()).Add(b.Min)
+	} else {
+		fx, fy := 0.5, 0.5
+		if align, ok := positions[focus]; ok {
+			fx, fy = float64(align[0])/2, float64(align[1])/2
+		}
+		if fx, err = floatParam(parameters, "focus_x", fx, 0, 1); err != nil {
+			return nil, err
+		}
+		if fy, err = floatParam(parameters, "focus_y", fy, 0, 1); err != nil {
+			return nil, err
+		}
+		window = coverWindow(b, width, height, fx, fy)
+	}
+
+	return imaging.Resize(imaging.Crop(src, window), width, height, imaging.Lanczos), nil
+}
+
+// detailedWindow finds the crop of width x height with the most edge energy.
+// The crop spans the image in one direction, so only the other direction
+// has to be searched.
+func detailedWindow(img *image.NRGBA, width, height int) (int, int) {
+	rw, rh := img.Bounds().Dx(), img.Bounds().Dy()
+	if rw == width && rh == height {
+		return 0, 0
+	}
+
+	gray := imaging.Grayscale(img)
+	luma := func(x, y int) int { return int(gray.Pix[y*gray.Stride+x*4]) }
+
+	// Energy per column (or row) along the axis that has overflow
+	horizontal := rw > width
+	length, across, window := rh, rw, height
+	if horizontal {
+		length, across, window = rw, rh, width
+	}
+	energy := make([]int, length+1) // prefix sums
+	for i := 0; i < length; i++ {
+		sum := 0
+		for j := 0; j < across; j++ {
+			x, y := j, i
+			if horizontal {
+				x, y = i, j
+			}
+			if x+1 < rw {
+				sum += abs(luma(x+1, y) - luma(x, y))
+			}
+			if y+1 < rh {
+				sum += abs(luma(x, y+1) - luma(x, y))
+			}
+		}
+		energy[i+1] = energy[i] + sum
+	}
+
+	// Ties, such as a flat image, keep the center
+	best := (length - window) / 2
+	bestEnergy := energy[best+window] - energy[best]
+	for start := 0; start+window <= length; start++ {
+		if e := energy[start+window] - energy[start]; e > bestEnergy {
+			best, bestEnergy = start, e
+		}
+	}
+	if horizontal {
+		return best, 0
+	}
+	return 0, best
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+)
+
+func TestPlacement(t *testing.T) {
+	canvas := image.Rect(0, 0, 100, 50)
+	size := image.Pt(20, 10)
+	tests := map[string]image.Point{
+		"top-left":     {5, 5},
+		"center":       {40, 20},
+		"bottom-right": {75, 35},
+		"top":          {40, 5},
+		"left":         {5, 20},
+	}
+	for position, want := range tests {
+		if got := placement(position, canvas, size, 5); got != want {
+			t.Errorf("placement(%s) = %v, want %v", position, got, want)
+		}
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	tests := map[string]color.NRGBA{
+		"#fff":      {255, 255, 255, 255},
+		"ff0000":    {255, 0, 0, 255},
+		"#00ff0080": {0, 255, 0, 128},
+	}
+	for in, want := range tests {
+		got, err := parseHexColor(in)
+		if err != nil || got != want {
+			t.Errorf("parseHexColor(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "#12", "#gggggg", "#1234567"} {
+		if _, err := parseHexColor(in); err == nil {
+			t.Errorf("parseHexColor(%q): expected an error", in)
+		}
+	}
+}
+
+func TestApplyWatermark(t *testing.T) {
+	src := newSolidImage(200, 100, color.NRGBA{0, 0, 0, 255})
+	mark := newSolidImage(50, 25, color.NRGBA{255, 255, 255, 255})
+
+	dst, err := applyWatermark(src, mark, map[string]string{"position": "top-left", "opacity": "1", "scale": "0.25", "margin": "0"})
+	if err != nil {
+		t.Fatalf("applyWatermark returned error: %v", err)
+	}
+	if dst.Bounds() != src.Bounds() {
+		t.Fatalf("watermark changed the size: %v", dst.Bounds())
+	}
+	// Scaled to a quarter of the width: 50x25 at the top left
+	if r, _, _, _ := dst.At(10, 10).RGBA(); r>>8 != 255 {
+		t.Errorf("expected the watermark at the top left")
+	}
+	if r, _, _, _ := dst.At(60, 10).RGBA(); r>>8 != 0 {
+		t.Errorf("watermark is larger than expected")
+	}
+
+	if _, err := applyWatermark(src, nil, nil); err == nil {
+		t.Error("expected an error without an overlay")
+	}
+}
+
+func TestApplyPipelineWatermark(t *testing.T) {
+	svc := newTestImageService(t)
+	src := newSolidImage(100, 100, color.NRGBA{0, 0, 0, 255})
+	id := "64b7f0f0f0f0f0f0f0f0f0f0"
+	overlays := map[string]image.Image{id: newSolidImage(10, 10, color.NRGBA{255, 0, 0, 255})}
+
+	dst, _, _, err := svc.applyPipeline(src, "image/png", []models.Transformation{
+		{Operation: string(models.OperationWatermark), Parameters: map[string]string{"image_id": id, "opacity": "1", "scale": "0.5", "position": "center"}},
+	}, overlays)
+	if err != nil {
+		t.Fatalf("applyPipeline returned error: %v", err)
+	}
+	if r, _, _, _ := dst.At(50, 50).RGBA(); r>>8 != 255 {
+		t.Errorf("expected the watermark in the center")
+	}
+}
+
+func TestApplyText(t *testing.T) {
+	svc := newTestImageService(t)
+	src := newSolidImage(200, 60, color.NRGBA{0, 0, 0, 255})
+
+	dst, _, _, err := svc.applyOperation(src, "image/png", string(models.OperationText), map[string]string{
+		"text": "Hello", "size": "40", "color": "#ffffff", "position": "center", "font": "bold",
+	})
+	if err != nil {
+		t.Fatalf("applyOperation text returned error: %v", err)
+	}
+	lit := 0
+	b := dst.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if r, _, _, _ := dst.At(x, y).RGBA(); r>>8 > 128 {
+				lit++
+			}
+		}
+	}
+	if lit == 0 {
+		t.Error("no text was drawn")
+	}
+	if r, _, _, _ := src.At(100, 30).RGBA(); r != 0 {
+		t.Error("the source image was modified")
+	}
+}
+
+func TestApplyBorderAndPad(t *testing.T) {
+	svc := newTestImageService(t)
+	src := newSolidImage(40, 20, color.NRGBA{0, 0, 255, 255})
+
+	dst, _, _, err := svc.applyOperation(src, "image/png", string(models.OperationBorder), map[string]string{"size": "5", "color": "#ff0000"})
+	if err != nil {
+		t.Fatalf("applyOperation border returned error: %v", err)
+	}
+	if b := dst.Bounds(); b.Dx() != 50 || b.Dy() != 30 {
+		t.Fatalf("unexpected bordered dimensions: %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, bl, _ := dst.At(0, 0).RGBA(); r>>8 != 255 || bl != 0 {
+		t.Errorf("expected a red border")
+	}
+
+	dst, _, _, err = svc.applyOperation(src, "image/png", string(models.OperationPad), map[string]string{"width": "20", "height": "20"})
+	if err != nil {
+		t.Fatalf("applyOperation pad returned error: %v", err)
+	}
+	if b := dst.Bounds(); b.Dx() != 20 || b.Dy() != 20 {
+		t.Fatalf("unexpected padded dimensions: %dx%d", b.Dx(), b.Dy())
+	}
+	// The image is shrunk to 20x10 and centered on white
+	if r, _, _, _ := dst.At(10, 1).RGBA(); r>>8 != 255 {
+		t.Errorf("expected white padding above the image")
+	}
+	if r, _, bl, _ := dst.At(10, 10).RGBA(); r>>8 != 0 || bl>>8 != 255 {
+		t.Errorf("expected the image in the middle")
+	}
+}
+
+func TestApplyThumbnailFocus(t *testing.T) {
+	// Left half plain, right half striped: the detail is on the right
+	src := imaging.New(200, 100, color.White)
+	for x := 100; x < 200; x++ {
+		for y := 0; y < 100; y++ {
+			if (x/4)%2 == 0 {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+	svc := newTestImageService(t)
+
+	for _, tt := range []struct {
+		params map[string]string
+		right  bool
+	}{
+		{map[string]string{"focus": "left"}, false},
+		{map[string]string{"focus": "right"}, true},
+		{map[string]string{"focus_x": "0.9"}, true},
+		{map[string]string{"focus": "auto"}, true},
+	} {
+		params := map[string]string{"width": "50", "height": "50"}
+		for k, v := range tt.params {
+			params[k] = v
+		}
+		dst, _, _, err := svc.applyOperation(src, "image/png", string(models.OperationThumbnail), params)
+		if err != nil {
+			t.Fatalf("%v: applyOperation thumbnail returned error: %v", tt.params, err)
+		}
+		if b := dst.Bounds(); b.Dx() != 50 || b.Dy() != 50 {
+			t.Fatalf("%v: unexpected dimensions %dx%d", tt.params, b.Dx(), b.Dy())
+		}
+		dark := 0
+		for x := 0; x < 50; x++ {
+			if r, _, _, _ := dst.At(x, 25).RGBA(); r>>8 < 128 {
+				dark++
+			}
+		}
+		if got := dark > 10; got != tt.right {
+			t.Errorf("%v: striped side kept = %v, want %v", tt.params, got, tt.right)
+		}
+	}
+}
+
+func TestApplyThumbnailOfSliver(t *testing.T) {
+	// Scaling the whole 2x2000 sliver to cover 4096x64 would be 4096x4096000
+	svc := newTestImageService(t)
+	src := newSolidImage(2, 2000, color.White)
+
+	for _, focus := range []string{"center", "auto"} {
+		dst, _, _, err := svc.applyOperation(src, "image/png", string(models.OperationThumbnail), map[string]string{
+			"width": "4096", "height": "64", "focus": focus,
+		})
+		if err != nil {
+			t.Fatalf("%s: applyOperation thumbnail returned error: %v", focus, err)
+		}
+		if b := dst.Bounds(); b.Dx() != 4096 || b.Dy() != 64 {
+			t.Fatalf("%s: unexpected dimensions %dx%d", focus, b.Dx(), b.Dy())
+		}
+	}
+}
+
+func TestValidateCompositingOperations(t *testing.T) {
+	valid := []models.Transformation{
+		{Operation: "watermark", Parameters: map[string]string{"image_id": "64b7f0f0f0f0f0f0f0f0f0f0", "opacity": "0.3"}},
+		{Operation: "text", Parameters: map[string]string{"text": "© me", "color": "#fff"}},
+		{Operation: "border", Parameters: map[string]string{"size": "4"}},
+		{Operation: "pad", Parameters: map[string]string{"width": "100", "height": "100", "color": "#00000000"}},
+		{Operation: "thumbnail", Parameters: map[string]string{"width": "64", "height": "64", "focus": "auto"}},
+	}
+	if err := ValidateTransformations(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := []models.Transformation{
+		{Operation: "watermark", Parameters: map[string]string{"image_id": "nope"}},
+		{Operation: "watermark", Parameters: map[string]string{"image_id": "64b7f0f0f0f0f0f0f0f0f0f0", "scale": "0"}},
+		{Operation: "text", Parameters: map[string]string{"text": " "}},
+		{Operation: "text", Parameters: map[string]string{"text": "hi", "font": "comic"}},
+		{Operation: "border", Parameters: map[string]string{"size": "0"}},
+		{Operation: "pad", Parameters: map[string]string{"width": "100"}},
+		{Operation: "thumbnail", Parameters: map[string]string{"width": "64", "height": "64", "focus_x": "2"}},
+	}
+	for _, step := range invalid {
+		if err := ValidateTransformations([]models.Transformation{step}); err == nil {
+			t.Errorf("%s %v: expected an error", step.Operation, step.Parameters)
+		}
+	}
+}
@@ -9,27 +9,42 @@ import (
 	"time"
 
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
 	"go.uber.org/zap"
 )
 
 type FileService struct {
-	s3Service *S3Service
-	logger    *zap.Logger
+	storage       Storage
+	logger        *zap.Logger
+	maxDimension  int
+	maxMegapixels int
 }
 
-func NewFileService(s3Service *S3Service, logger *zap.Logger) *FileService {
+func NewFileService(storage Storage, logger *zap.Logger, maxDimension, maxMegapixels int) *FileService {
 	return &FileService{
-		s3Service: s3Service,
-		logger:    logger,
+		storage:       storage,
+		logger:        logger,
+		maxDimension:  maxDimension,
+		maxMegapixels: maxMegapixels,
 	}
 }
 
-// UploadFile uploads a file to S3 and returns the key
-func (s *FileService) UploadFile(ctx context.Context, file *multipart.FileHeader) (string, error) {
+// UploadedFile describes an upload as it was stored
+type UploadedFile struct {
+	Key    string
+	Size   int64
+	Width  int
+	Height int
+	// Hashes are nil when the image could not be hashed
+	Hashes *models.ImageHashes
+}
+
+// UploadFile cleans an uploaded image (see CleanImage) and uploads it to storage
+func (s *FileService) UploadFile(ctx context.Context, file *multipart.FileHeader) (*UploadedFile, error) {
 	src, err := file.Open()
 	if err != nil {
 		s.logger.Error("Failed to open uploaded file", logger.Error(err))
-		return "", fmt.Errorf("failed to open file: %w", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer src.Close()
 
@@ -37,43 +52,91 @@ func (s *FileService) UploadFile(ctx context.Context, file *multipart.FileHeader
 	data, err := io.ReadAll(src)
 	if err != nil {
 		s.logger.Error("Failed to read file data", logger.Error(err))
-		return "", fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// Reject oversized images before anything decodes them
+	if err := CheckDimensions(data, s.maxDimension, s.maxMegapixels); err != nil {
+		s.logger.Warn("Rejected uploaded image", logger.Error(err))
+		return nil, err
+	}
+
+	// Fix the orientation and strip metadata before anything is stored
+	contentType := file.Header.Get("Content-Type")
+	cleaned, err := CleanImage(data, contentType)
+	if err != nil {
+		s.logger.Warn("Failed to clean uploaded image", logger.Error(err))
+		return nil, fmt.Errorf("failed to clean image: %w", err)
 	}
 
 	// Generate unique key
 	ext := filepath.Ext(file.Filename)
 	key := fmt.Sprintf("uploads/%d%s", time.Now().UnixNano(), ext)
 
-	// Upload to S3
-	err = s.s3Service.UploadFile(ctx, key, data, file.Header.Get("Content-Type"))
+	// Upload to storage
+	err = s.storage.UploadFile(ctx, key, cleaned.Data, contentType)
+	if err != nil {
+		s.logger.Error("Failed to upload file to storage", logger.Error(err))
+		return nil, fmt.Errorf("failed to upload to storage: %w", err)
+	}
+
+	// Hashes only power duplicate detection, so the upload goes on without
+	hashes, err := HashImageData(cleaned.Data)
 	if err != nil {
-		s.logger.Error("Failed to upload file to S3", logger.Error(err))
-		return "", fmt.Errorf("failed to upload to S3: %w", err)
+		s.logger.Warn("Failed to hash uploaded image", logger.String("key", key), logger.Error(err))
 	}
 
 	s.logger.Info("File uploaded successfully", logger.String("key", key))
-	return key, nil
+	return &UploadedFile{
+		Key:    key,
+		Size:   int64(len(cleaned.Data)),
+		Width:  cleaned.Width,
+		Height: cleaned.Height,
+		Hashes: hashes,
+	}, nil
 }
 
-// GetFile retrieves a file from S3 by key
+// GetFile retrieves a file from storage by key
 func (s *FileService) GetFile(ctx context.Context, key string) ([]byte, error) {
-	output, err := s.s3Service.GetFile(ctx, key)
+	output, err := s.storage.GetFile(ctx, key)
 	if err != nil {
-		s.logger.Error("Failed to get file from S3", logger.Error(err))
-		return nil, fmt.Errorf("failed to get file from S3: %w", err)
+		s.logger.Error("Failed to get file from storage", logger.Error(err))
+		return nil, fmt.Errorf("failed to get file from storage: %w", err)
 	}
 
 	return output, nil
 }
 
-// DeleteFile deletes a file from S3 by key
+// DeleteFile deletes a file from storage by key
 func (s *FileService) DeleteFile(ctx context.Context, key string) error {
-	err := s.s3Service.DeleteFile(ctx, key)
+	err := s.storage.DeleteFile(ctx, key)
 	if err != nil {
-		s.logger.Error("Failed to delete file from S3", logger.Error(err))
-		return fmt.Errorf("failed to delete file from S3: %w", err)
+		s.logger.Error("Failed to delete file from storage", logger.Error(err))
+		return fmt.Errorf("failed to delete file from storage: %w", err)
 	}
 
 	s.logger.Info("File deleted successfully", logger.String("key", key))
 	return nil
 }
+
+// DownloadURL returns a time-limited URL that downloads the file directly
+// from storage as an attachment named filename
+func (s *FileService) DownloadURL(ctx context.Context, key, filename string, expires time.Duration) (string, error) {
+	url, err := s.storage.DownloadURL(ctx, key, filename, expires)
+	if err != nil {
+		s.logger.Error("Failed to create download URL", logger.Error(err))
+		return "", fmt.Errorf("failed to create download URL: %w", err)
+	}
+
+	return url, nil
+}
+
+// VerifyDownload checks a signed /files/ URL issued by the local backend
+func (s *FileService) VerifyDownload(key, filename string, expires int64, signature string) error {
+	local, ok := s.storage.(*LocalStorage)
+	if !ok {
+		return fmt.Errorf("local downloads are not enabled")
+	}
+
+	return local.VerifyDownload(key, filename, expires, signature, time.Now())
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/disintegration/imaging"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+
+	// Registers the WebP decoder so WebP uploads can be processed
+	_ "golang.org/x/image/webp"
+)
+
+// formatWebP extends imaging's formats; imaging has no WebP encoder, so
+// encodeImage writes it with nativewebp. There is no pure-Go AVIF encoder,
+// so AVIF is not offered as an output format.
+const formatWebP imaging.Format = -1
+
+// FormatAuto picks the output format from the request's Accept header
+const FormatAuto = "auto"
+
+// Default encoding settings, overridden by the quality parameters
+const (
+	defaultJPEGQuality = 90
+	defaultGIFColors   = 256
+)
+
+type outputFormat struct {
+	format      imaging.Format
+	contentType string
+}
+
+// outputFormats maps the "format" parameter to an encoder
+var outputFormats = map[string]outputFormat{
+	"jpeg": {imaging.JPEG, "image/jpeg"},
+	"jpg":  {imaging.JPEG, "image/jpeg"},
+	"png":  {imaging.PNG, "image/png"},
+	"gif":  {imaging.GIF, "image/gif"},
+	"bmp":  {imaging.BMP, "image/bmp"},
+	"tiff": {imaging.TIFF, "image/tiff"},
+	"tif":  {imaging.TIFF, "image/tiff"},
+	"webp": {formatWebP, "image/webp"},
+}
+
+// contentTypeFormats maps an original's content type to the format it is
+// re-encoded in when no format is requested
+var contentTypeFormats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/jpg":  "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/bmp":  "bmp",
+	"image/tiff": "tiff",
+	"image/webp": "webp",
+}
+
+// pngCompressionLevels maps the "png_compression" parameter to a zlib level
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"speed":   png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// encodingParameters are the parameters that control the output encoding
+// rather than a transformation
+var encodingParameters = []string{"format", "quality", "jpeg_quality", "png_compression", "gif_colors"}
+
+func (s *ImageService) resolveFormat(originalContentType string, parameters map[string]string) (imaging.Format, string) {
+	if formatStr, ok := parameters["format"]; ok {
+		if f, ok := outputFormats[strings.ToLower(formatStr)]; ok {
+			return f.format, f.contentType
+		}
+	}
+
+	if name, ok := contentTypeFormats[strings.ToLower(originalContentType)]; ok {
+		f := outputFormats[name]
+		return f.format, f.contentType
+	}
+	return imaging.PNG, "image/png"
+}
+
+// encodeImage encodes an image in the given format with its quality parameters:
+//
+//	jpeg  jpeg_quality, else quality (1-100, default 90)
+//	png   png_compression: default, none, speed or best
+//	gif   gif_colors (2-256, default 256)
+//	webp  always lossless, so quality does not apply
+func encodeImage(img image.Image, format imaging.Format, parameters map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if format == formatWebP {
+		if err := nativewebp.Encode(&buf, img, nil); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	encodeOpts := []imaging.EncodeOption{}
+	switch format {
+	case imaging.JPEG:
+		q := defaultJPEGQuality
+		for _, key := range []string{"quality", "jpeg_quality"} {
+			if parsed, err := strconv.Atoi(parameters[key]); err == nil && parsed >= 1 && parsed <= 100 {
+				q = parsed
+			}
+		}
+		encodeOpts = append(encodeOpts, imaging.JPEGQuality(q))
+	case imaging.PNG:
+		if level, ok := pngCompressionLevels[strings.ToLower(parameters["png_compression"])]; ok {
+			encodeOpts = append(encodeOpts, imaging.PNGCompressionLevel(level))
+		}
+	case imaging.GIF:
+		colors := defaultGIFColors
+		if parsed, err := strconv.Atoi(parameters["gif_colors"]); err == nil && parsed >= 2 && parsed <= 256 {
+			colors = parsed
+		}
+		encodeOpts = append(encodeOpts, imaging.GIFNumColors(colors))
+	}
+
+	if err := imaging.Encode(&buf, img, format, encodeOpts...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// validateEncodingParameters checks the format and quality parameters of a step
+func validateEncodingParameters(parameters map[string]string) error {
+	if v, ok := parameters["format"]; ok {
+		if _, ok := outputFormats[strings.ToLower(v)]; !ok && !strings.EqualFold(v, FormatAuto) {
+			return fmt.Errorf("unsupported format: %s", v)
+		}
+	}
+	for _, key := range []string{"quality", "jpeg_quality"} {
+		if v, ok := parameters[key]; ok {
+			if q, err := strconv.Atoi(v); err != nil || q < 1 || q > 100 {
+				return fmt.Errorf("%s must be between 1 and 100", key)
+			}
+		}
+	}
+	if v, ok := parameters["png_compression"]; ok {
+		if _, ok := pngCompressionLevels[strings.ToLower(v)]; !ok {
+			return fmt.Errorf("png_compression must be default, none, speed or best")
+		}
+	}
+	if v, ok := parameters["gif_colors"]; ok {
+		if n, err := strconv.Atoi(v); err != nil || n < 2 || n > 256 {
+			return fmt.Errorf("gif_colors must be between 2 and 256")
+		}
+	}
+	return nil
+}
+
+// NegotiateFormat picks the output format for format=auto from an Accept
+// header. WebP is preferred when the client lists it, except for JPEG
+// originals: the WebP encoder is lossless, which makes photos larger than
+// JPEG. Otherwise the original's format is kept if the client accepts it,
+// falling back to PNG or JPEG.
+func NegotiateFormat(accept, originalContentType string) string {
+	original, ok := contentTypeFormats[strings.ToLower(originalContentType)]
+	if !ok {
+		original = "png"
+	}
+	if strings.TrimSpace(accept) == "" {
+		return original
+	}
+
+	accepted := parseAccept(accept)
+	if original != "jpeg" && accepted["image/webp"] > 0 {
+		return "webp"
+	}
+	for _, name := range []string{original, "png", "jpeg"} {
+		if acceptsType(accepted, outputFormats[name].contentType) {
+			return name
+		}
+	}
+	return original
+}
+
+// acceptsType reports whether a parsed Accept header allows a media type,
+// directly or through image/* or */*
+func acceptsType(accepted map[string]float64, contentType string) bool {
+	for _, t := range []string{contentType, "image/*", "*/*"} {
+		if q, ok := accepted[t]; ok {
+			return q > 0
+		}
+	}
+	return false
+}
+
+// parseAccept returns the quality of each media type listed in an Accept header
+func parseAccept(accept string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		accepted[mediaType] = q
+	}
+	return accepted
+}
+
+// ResolveAutoFormat returns a copy of the pipeline with format=auto replaced
+// by the given format
+func ResolveAutoFormat(transformations []models.Transformation, format string) []models.Transformation {
+	resolved := make([]models.Transformation, len(transformations))
+	for i, t := range transformations {
+		resolved[i] = t
+		if strings.EqualFold(t.Parameters["format"], FormatAuto) {
+			params := make(map[string]string, len(t.Parameters))
+			for k, v := range t.Parameters {
+				params[k] = v
+			}
+			params["format"] = format
+			resolved[i].Parameters = params
+		}
+	}
+	return resolved
+}
+
+// HasAutoFormat reports whether any step asks for format=auto
+func HasAutoFormat(transformations []models.Transformation) bool {
+	for _, t := range transformations {
+		if strings.EqualFold(t.Parameters["format"], FormatAuto) {
+			return true
+		}
+	}
+	return false
+}
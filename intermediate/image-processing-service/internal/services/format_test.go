@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		want        string
+	}{
+		{"image/avif,image/webp,image/apng,image/*,*/*;q=0.8", "image/png", "webp"},
+		{"image/webp,*/*", "image/jpeg", "jpeg"},
+		{"image/webp;q=0,*/*", "image/png", "png"},
+		{"", "image/gif", "gif"},
+		{"*/*", "image/tiff", "tiff"},
+		{"image/png,image/jpeg", "image/tiff", "png"},
+		{"image/jpeg", "image/bmp", "jpeg"},
+		{"application/json", "image/gif", "gif"},
+	}
+	for _, tt := range tests {
+		if got := NegotiateFormat(tt.accept, tt.contentType); got != tt.want {
+			t.Errorf("NegotiateFormat(%q, %q) = %q, want %q", tt.accept, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestResolveAutoFormat(t *testing.T) {
+	steps := []models.Transformation{
+		{Operation: string(models.OperationResize), Parameters: map[string]string{"width": "10"}},
+		{Operation: string(models.OperationConvert), Parameters: map[string]string{"format": "auto", "quality": "80"}},
+	}
+	if !HasAutoFormat(steps) {
+		t.Fatal("expected format=auto to be detected")
+	}
+	resolved := ResolveAutoFormat(steps, "webp")
+	if resolved[1].Parameters["format"] != "webp" || resolved[1].Parameters["quality"] != "80" {
+		t.Errorf("unexpected parameters: %v", resolved[1].Parameters)
+	}
+	if steps[1].Parameters["format"] != "auto" {
+		t.Error("the original pipeline must not be modified")
+	}
+	if HasAutoFormat(resolved) {
+		t.Error("resolved pipeline still asks for format=auto")
+	}
+
+	if got := ResolveAutoSpec("w_300,f_auto,q_80", "webp"); got != "w_300,f_webp,q_80" {
+		t.Errorf("ResolveAutoSpec = %q", got)
+	}
+}
+
+func TestEncodeImageWebP(t *testing.T) {
+	svc := newTestImageService(t)
+	format, contentType := svc.resolveFormat("image/jpeg", map[string]string{"format": "webp"})
+	if format != formatWebP || contentType != "image/webp" {
+		t.Fatalf("unexpected format or contentType: got %v, %s", format, contentType)
+	}
+
+	data, err := encodeImage(newSolidImage(20, 10, color.RGBA{0, 0, 255, 255}), format, map[string]string{"quality": "50"})
+	if err != nil {
+		t.Fatalf("encodeImage returned error: %v", err)
+	}
+	img, name, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode WebP output: %v", err)
+	}
+	if name != "webp" || img.Bounds().Dx() != 20 || img.Bounds().Dy() != 10 {
+		t.Errorf("unexpected output: %s %v", name, img.Bounds())
+	}
+}
+
+func TestEncodeImageQualityPerFormat(t *testing.T) {
+	noisy := imaging.New(64, 64, color.White)
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			noisy.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), uint8((x * y) % 256), 255})
+		}
+	}
+
+	low, err := encodeImage(noisy, imaging.JPEG, map[string]string{"quality": "95", "jpeg_quality": "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := encodeImage(noisy, imaging.JPEG, map[string]string{"quality": "95"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(low) >= len(high) {
+		t.Errorf("jpeg_quality should override quality: %d >= %d bytes", len(low), len(high))
+	}
+
+	fast, err := encodeImage(noisy, imaging.PNG, map[string]string{"png_compression": "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	best, err := encodeImage(noisy, imaging.PNG, map[string]string{"png_compression": "best"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(best) >= len(fast) {
+		t.Errorf("png_compression best should be smaller than none: %d >= %d bytes", len(best), len(fast))
+	}
+}
+
+func TestValidateEncodingParameters(t *testing.T) {
+	valid := []map[string]string{
+		{"format": "webp"},
+		{"format": "AUTO"},
+		{"jpeg_quality": "75"},
+		{"png_compression": "best"},
+		{"gif_colors": "16"},
+	}
+	for _, p := range valid {
+		if err := validateEncodingParameters(p); err != nil {
+			t.Errorf("%v: unexpected error: %v", p, err)
+		}
+	}
+	invalid := []map[string]string{
+		{"format": "avif"},
+		{"jpeg_quality": "0"},
+		{"png_compression": "max"},
+		{"gif_colors": "1"},
+	}
+	for _, p := range invalid {
+		if err := validateEncodingParameters(p); err == nil {
+			t.Errorf("%v: expected an error", p)
+		}
+	}
+}
@@ -3,8 +3,11 @@ package services
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -20,70 +23,21 @@ import (
 	"go.uber.org/zap"
 )
 
-type imageProcessingJob struct {
-	ImageID    string
-	Operation  string
-	Parameters map[string]string
-}
-
 type ImageService struct {
-	db      *database.MongoDB
-	storage *S3Service
-	logger  *zap.Logger
-	jobs    chan imageProcessingJob
+	db            *database.MongoDB
+	storage       Storage
+	logger        *zap.Logger
+	maxMegapixels int
 }
 
-func NewImageService(db *database.MongoDB, storage *S3Service, logger *zap.Logger) *ImageService {
-	s := &ImageService{
-		db:      db,
-		storage: storage,
-		logger:  logger,
-		jobs:    make(chan imageProcessingJob, 100),
-	}
-	go s.startWorkers(2)
-	return s
-}
-
-func (s *ImageService) startWorkers(count int) {
-	for i := 0; i < count; i++ {
-		go s.worker()
-	}
-}
-
-func (s *ImageService) worker() {
-	for job := range s.jobs {
-		img, err := s.GetImage(job.ImageID)
-		if err != nil {
-			s.logger.Error("Failed to load image for processing", logger.Error(err))
-			continue
-		}
-		if img == nil {
-			s.logger.Warn("Image not found for processing", logger.String("image_id", job.ImageID))
-			continue
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		_, err = s.ProcessImage(ctx, img, job.Operation, job.Parameters)
-		cancel()
-		if err != nil {
-			s.logger.Error("Failed to process image asynchronously", logger.Error(err))
-		}
-	}
-}
-
-func (s *ImageService) EnqueueProcessing(imageID, operation string, parameters map[string]string) error {
-	if s.jobs == nil {
-		return fmt.Errorf("image processing queue not initialized")
-	}
-	job := imageProcessingJob{
-		ImageID:    imageID,
-		Operation:  operation,
-		Parameters: parameters,
-	}
-	select {
-	case s.jobs <- job:
-		return nil
-	default:
-		return fmt.Errorf("image processing queue is full")
+// NewImageService creates an ImageService. maxMegapixels caps every image a
+// pipeline produces, including intermediate ones; zero disables the check.
+func NewImageService(db *database.MongoDB, storage Storage, logger *zap.Logger, maxMegapixels int) *ImageService {
+	return &ImageService{
+		db:            db,
+		storage:       storage,
+		logger:        logger,
+		maxMegapixels: maxMegapixels,
 	}
 }
 
@@ -151,7 +105,7 @@ func (s *ImageService) GetUserImages(userID string, page, limit int) ([]*models.
 	opts := options.Find().
 		SetSkip(int64(skip)).
 		SetLimit(int64(limit)).
-		SetSort(bson.D{{"created_at", -1}})
+		SetSort(bson.D{{Key: "created_at", Value: -1}})
 
 	cursor, err := collection.Find(context.Background(), filter, opts)
 	if err != nil {
@@ -230,18 +184,118 @@ func (s *ImageService) AddProcessedImage(id string, processed models.ProcessedIm
 	return nil
 }
 
+// FindSimilarImages returns a user's images whose hash for the algorithm is
+// within threshold bits of hashes, closest first. The image excludeID, usually
+// the one being compared, is left out.
+func (s *ImageService) FindSimilarImages(ctx context.Context, userID primitive.ObjectID, hashes *models.ImageHashes, algorithm string, threshold int, excludeID primitive.ObjectID, limit int) ([]*models.SimilarImageResponse, error) {
+	collection := s.db.Collection("images")
+	filter := bson.M{
+		"user_id": userID,
+		"_id":     bson.M{"$ne": excludeID},
+		"hashes":  bson.M{"$exists": true},
+	}
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		s.logger.Error("Failed to find images", logger.Error(err))
+		return nil, fmt.Errorf("failed to find images: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var images []*models.Image
+	if err := cursor.All(ctx, &images); err != nil {
+		s.logger.Error("Failed to decode images", logger.Error(err))
+		return nil, fmt.Errorf("failed to decode images: %w", err)
+	}
+
+	return rankSimilar(images, hashes, algorithm, threshold, limit), nil
+}
+
+// rankSimilar keeps the images within threshold, closest first, then oldest
+// first, so the original of a run of duplicates comes before its copies
+func rankSimilar(images []*models.Image, hashes *models.ImageHashes, algorithm string, threshold, limit int) []*models.SimilarImageResponse {
+	similar := []*models.SimilarImageResponse{}
+	for _, image := range images {
+		distance, ok := HashDistance(hashes, image.Hashes, algorithm)
+		if !ok || distance > threshold {
+			continue
+		}
+		similar = append(similar, &models.SimilarImageResponse{Image: image.ToResponse(), Distance: distance})
+	}
+
+	sort.SliceStable(similar, func(i, j int) bool {
+		if similar[i].Distance != similar[j].Distance {
+			return similar[i].Distance < similar[j].Distance
+		}
+		return similar[i].Image.CreatedAt.Before(similar[j].Image.CreatedAt)
+	})
+	if limit > 0 && len(similar) > limit {
+		similar = similar[:limit]
+	}
+	return similar
+}
+
+// EnsureHashes computes and stores the hashes of an image uploaded before
+// hashing was added
+func (s *ImageService) EnsureHashes(ctx context.Context, image *models.Image) error {
+	if image.Hashes != nil {
+		return nil
+	}
+
+	data, err := s.storage.GetFile(ctx, image.OriginalKey)
+	if err != nil {
+		return fmt.Errorf("failed to get original file: %w", err)
+	}
+	hashes, err := HashImageData(data)
+	if err != nil {
+		return err
+	}
+
+	collection := s.db.Collection("images")
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": image.ID}, bson.M{"$set": bson.M{"hashes": hashes}})
+	if err != nil {
+		s.logger.Error("Failed to store image hashes", logger.Error(err))
+		return fmt.Errorf("failed to store image hashes: %w", err)
+	}
+
+	image.Hashes = hashes
+	return nil
+}
+
 // ProcessImage processes an image with the specified operation
 func (s *ImageService) ProcessImage(ctx context.Context, image *models.Image, operation string, parameters map[string]string) (*models.ProcessedImage, error) {
+	return s.ProcessPipeline(ctx, image, []models.Transformation{{Operation: operation, Parameters: parameters}}, nil)
+}
+
+// ProcessPipeline applies the transformations in order to the original image
+// and stores the result as a single processed version. progress, if not nil,
+// is called with the percentage done as the work advances.
+func (s *ImageService) ProcessPipeline(ctx context.Context, image *models.Image, transformations []models.Transformation, progress func(percent int)) (*models.ProcessedImage, error) {
+	if progress == nil {
+		progress = func(int) {}
+	}
+	if err := ValidateTransformations(transformations); err != nil {
+		return nil, err
+	}
+
 	if err := s.UpdateImageStatus(image.ID.Hex(), models.ImageStatusProcessing); err != nil {
 		return nil, fmt.Errorf("failed to update status: %w", err)
 	}
 
+	// A single step keeps its own name and parameters
+	operation := transformations[0].Operation
+	parameters := transformations[0].Parameters
+	if len(transformations) > 1 {
+		operation = string(models.OperationPipeline)
+		parameters = outputParameters(transformations)
+	}
+
 	processedKey := fmt.Sprintf("processed/%s_%s_%d", image.ID.Hex(), operation, time.Now().UnixNano())
 
 	s.logger.Info("Processing image",
 		logger.String("image_id", image.ID.Hex()),
 		logger.String("operation", operation),
-		logger.Any("parameters", parameters),
+		logger.Any("transformations", transformations),
 	)
 
 	originalData, err := s.storage.GetFile(ctx, image.OriginalKey)
@@ -249,43 +303,40 @@ func (s *ImageService) ProcessImage(ctx context.Context, image *models.Image, op
 		_ = s.UpdateImageStatus(image.ID.Hex(), models.ImageStatusFailed)
 		return nil, fmt.Errorf("failed to get original file: %w", err)
 	}
+	progress(20)
 
-	src, err := imaging.Decode(bytes.NewReader(originalData))
+	src, err := imaging.Decode(bytes.NewReader(originalData), imaging.AutoOrientation(true))
 	if err != nil {
 		_ = s.UpdateImageStatus(image.ID.Hex(), models.ImageStatusFailed)
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
+	progress(30)
 
-	dst, contentType, format, err := s.applyOperation(src, image.ContentType, operation, parameters)
+	overlays, err := s.loadOverlays(ctx, image, transformations)
 	if err != nil {
 		_ = s.UpdateImageStatus(image.ID.Hex(), models.ImageStatusFailed)
-		return nil, fmt.Errorf("failed to apply operation: %w", err)
+		return nil, err
 	}
 
-	var buf bytes.Buffer
-	encodeOpts := []imaging.EncodeOption{}
-
-	if format == imaging.JPEG {
-		q := 90
-		if v, ok := parameters["quality"]; ok {
-			if parsed, err := strconv.Atoi(v); err == nil && parsed >= 1 && parsed <= 100 {
-				q = parsed
-			}
-		}
-		encodeOpts = append(encodeOpts, imaging.JPEGQuality(q))
+	dst, contentType, format, err := s.applyPipeline(src, image.ContentType, transformations, overlays)
+	if err != nil {
+		_ = s.UpdateImageStatus(image.ID.Hex(), models.ImageStatusFailed)
+		return nil, fmt.Errorf("failed to apply operation: %w", err)
 	}
+	progress(60)
 
-	if err := imaging.Encode(&buf, dst, format, encodeOpts...); err != nil {
+	data, err := encodeImage(dst, format, outputParameters(transformations))
+	if err != nil {
 		_ = s.UpdateImageStatus(image.ID.Hex(), models.ImageStatusFailed)
 		return nil, fmt.Errorf("failed to encode processed image: %w", err)
 	}
-
-	data := buf.Bytes()
+	progress(80)
 
 	if err := s.storage.UploadFile(ctx, processedKey, data, contentType); err != nil {
 		_ = s.UpdateImageStatus(image.ID.Hex(), models.ImageStatusFailed)
 		return nil, fmt.Errorf("failed to upload processed image: %w", err)
 	}
+	progress(90)
 
 	size := int64(len(data))
 	bounds := dst.Bounds()
@@ -299,10 +350,14 @@ func (s *ImageService) ProcessImage(ctx context.Context, image *models.Image, op
 		Width:       bounds.Dx(),
 		Height:      bounds.Dy(),
 	}
+	if len(transformations) > 1 {
+		processed.Transformations = transformations
+	}
 
 	if err := s.AddProcessedImage(image.ID.Hex(), processed); err != nil {
 		return nil, fmt.Errorf("failed to add processed image: %w", err)
 	}
+	s.recomputeUsage(ctx, image.UserID)
 
 	if err := s.UpdateImageStatus(image.ID.Hex(), models.ImageStatusCompleted); err != nil {
 		s.logger.Error("Failed to update status to completed", logger.Error(err))
@@ -311,6 +366,181 @@ func (s *ImageService) ProcessImage(ctx context.Context, image *models.Image, op
 	return &processed, nil
 }
 
+// applyPipeline applies each transformation to the result of the previous one.
+// The output format comes from the last step that sets one. overlays holds
+// the watermark images by ID (see loadOverlays). A step whose result would
+// exceed the megapixel limit fails with ErrImageTooLarge before it runs.
+func (s *ImageService) applyPipeline(src image.Image, originalContentType string, transformations []models.Transformation, overlays map[string]image.Image) (image.Image, string, imaging.Format, error) {
+	dst := src
+	for i, t := range transformations {
+		size := stepSize(dst.Bounds(), t)
+		if s.maxMegapixels > 0 && int64(size.X)*int64(size.Y) > int64(s.maxMegapixels)*1000000 {
+			return nil, "", 0, fmt.Errorf("transformation %d (%s): %w: %dx%d exceeds %d megapixels",
+				i+1, t.Operation, ErrImageTooLarge, size.X, size.Y, s.maxMegapixels)
+		}
+
+		var err error
+		if t.Operation == string(models.OperationWatermark) {
+			dst, err = applyWatermark(dst, overlays[t.Parameters["image_id"]], t.Parameters)
+		} else {
+			dst, _, _, err = s.applyOperation(dst, originalContentType, t.Operation, t.Parameters)
+		}
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("transformation %d (%s): %w", i+1, t.Operation, err)
+		}
+	}
+	format, contentType := s.resolveFormat(originalContentType, outputParameters(transformations))
+	return dst, contentType, format, nil
+}
+
+// ErrOverlayNotFound is returned when a watermark names an image that does
+// not exist or belongs to another user
+var ErrOverlayNotFound = errors.New("watermark image not found")
+
+// loadOverlays loads the images used by the watermark steps of a pipeline.
+// Only images of the same owner may be used.
+func (s *ImageService) loadOverlays(ctx context.Context, base *models.Image, transformations []models.Transformation) (map[string]image.Image, error) {
+	overlays := make(map[string]image.Image)
+	for _, t := range transformations {
+		if t.Operation != string(models.OperationWatermark) {
+			continue
+		}
+		id := t.Parameters["image_id"]
+		if _, ok := overlays[id]; ok {
+			continue
+		}
+
+		overlay, err := s.GetImage(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get watermark image: %w", err)
+		}
+		if overlay == nil || overlay.UserID != base.UserID {
+			return nil, ErrOverlayNotFound
+		}
+		data, err := s.storage.GetFile(ctx, overlay.OriginalKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get watermark file: %w", err)
+		}
+		img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode watermark image: %w", err)
+		}
+		overlays[id] = img
+	}
+	return overlays, nil
+}
+
+// outputParameters collects the encoding parameters of a pipeline; later steps win
+func outputParameters(transformations []models.Transformation) map[string]string {
+	out := map[string]string{}
+	for _, t := range transformations {
+		for _, key := range encodingParameters {
+			if v, ok := t.Parameters[key]; ok && v != "" {
+				out[key] = v
+			}
+		}
+	}
+	return out
+}
+
+// MaxOutputDimension caps the width and height a transformation may produce
+const MaxOutputDimension = 8192
+
+// ValidateTransformations checks a whole pipeline before any work is done:
+// the number of steps, each operation and its parameters
+func ValidateTransformations(transformations []models.Transformation) error {
+	if len(transformations) == 0 {
+		return fmt.Errorf("at least one transformation is required")
+	}
+	if len(transformations) > models.MaxTransformations {
+		return fmt.Errorf("at most %d transformations are allowed", models.MaxTransformations)
+	}
+	for i, t := range transformations {
+		if err := validateOperation(t.Operation, t.Parameters); err != nil {
+			return fmt.Errorf("transformation %d (%s): %w", i+1, t.Operation, err)
+		}
+	}
+	return nil
+}
+
+func validateOperation(operation string, parameters map[string]string) error {
+	if !models.IsValidOperation(operation) {
+		return fmt.Errorf("unsupported operation")
+	}
+
+	if err := validateEncodingParameters(parameters); err != nil {
+		return err
+	}
+
+	switch operation {
+	case string(models.OperationResize), string(models.OperationCrop):
+		width, height, err := parseDimensions(parameters)
+		if err != nil {
+			return err
+		}
+		if width < 0 || height < 0 {
+			return fmt.Errorf("width and height must not be negative")
+		}
+		if operation == string(models.OperationResize) && width == 0 && height == 0 {
+			return fmt.Errorf("width or height must be provided for resize")
+		}
+		if operation == string(models.OperationCrop) && (width == 0 || height == 0) {
+			return fmt.Errorf("width and height must be greater than zero for crop")
+		}
+		if width > MaxOutputDimension || height > MaxOutputDimension {
+			return fmt.Errorf("width and height must be at most %d", MaxOutputDimension)
+		}
+		switch strings.ToLower(parameters["fit"]) {
+		case "", "fill", "contain":
+		case "cover":
+			if width == 0 || height == 0 {
+				return fmt.Errorf("fit cover requires width and height")
+			}
+		default:
+			return fmt.Errorf("unsupported fit: %s", parameters["fit"])
+		}
+	case string(models.OperationRotate):
+		switch parameters["angle"] {
+		case "90", "180", "270":
+		default:
+			return fmt.Errorf("unsupported rotate angle: %s", parameters["angle"])
+		}
+	case string(models.OperationFlip):
+		switch strings.ToLower(parameters["mode"]) {
+		case "", "horizontal", "vertical":
+		default:
+			return fmt.Errorf("unsupported flip mode: %s", parameters["mode"])
+		}
+	case string(models.OperationBlur), string(models.OperationSharpen):
+		if v := parameters["sigma"]; v != "" {
+			if sigma, err := strconv.ParseFloat(v, 64); err != nil || sigma <= 0 {
+				return fmt.Errorf("sigma must be a positive number")
+			}
+		}
+	case string(models.OperationBrightness), string(models.OperationContrast):
+		if v := parameters["value"]; v != "" {
+			if value, err := strconv.ParseFloat(v, 64); err != nil || value < -100 || value > 100 {
+				return fmt.Errorf("value must be a number between -100 and 100")
+			}
+		}
+	case string(models.OperationConvert):
+		if parameters["format"] == "" {
+			return fmt.Errorf("format is required for convert")
+		}
+	case string(models.OperationWatermark):
+		return validateWatermark(parameters)
+	case string(models.OperationText):
+		return validateText(parameters)
+	case string(models.OperationBorder):
+		return validateBorder(parameters)
+	case string(models.OperationPad):
+		return validatePad(parameters)
+	case string(models.OperationThumbnail):
+		return validateThumbnail(parameters)
+	}
+	return nil
+}
+
 func (s *ImageService) applyOperation(src image.Image, originalContentType string, operation string, parameters map[string]string) (image.Image, string, imaging.Format, error) {
 	targetFormat, contentType := s.resolveFormat(originalContentType, parameters)
 
@@ -323,6 +553,22 @@ func (s *ImageService) applyOperation(src image.Image, originalContentType strin
 		if width == 0 && height == 0 {
 			return nil, "", 0, fmt.Errorf("width or height must be provided for resize")
 		}
+		switch strings.ToLower(parameters["fit"]) {
+		case "cover":
+			// Crop the overflow first, so only the box itself is ever allocated
+			window := coverWindow(src.Bounds(), width, height, 0.5, 0.5)
+			dst := imaging.Resize(imaging.Crop(src, window), width, height, imaging.Lanczos)
+			return dst, contentType, targetFormat, nil
+		case "contain":
+			// Fit inside the box keeping the aspect ratio
+			if width > 0 && height > 0 {
+				return imaging.Fit(src, width, height, imaging.Lanczos), contentType, targetFormat, nil
+			}
+		}
+		// A missing side follows the aspect ratio, which can make it huge
+		if w, h := resizedSize(src.Bounds(), width, height); w > MaxOutputDimension || h > MaxOutputDimension {
+			return nil, "", 0, fmt.Errorf("resized image would be %dx%d, over %d pixels per side", w, h, MaxOutputDimension)
+		}
 		dst := imaging.Resize(src, width, height, imaging.Lanczos)
 		return dst, contentType, targetFormat, nil
 	case string(models.OperationCrop):
@@ -367,49 +613,28 @@ func (s *ImageService) applyOperation(src image.Image, originalContentType strin
 	case string(models.OperationContrast):
 		percent := parseFloat(parameters["value"], 0)
 		return imaging.AdjustContrast(src, percent), contentType, targetFormat, nil
-	case string(models.OperationCompress):
+	case string(models.OperationCompress), string(models.OperationConvert):
 		return src, contentType, targetFormat, nil
+	case string(models.OperationWatermark):
+		// The overlay is another image, loaded by applyPipeline
+		return nil, "", 0, fmt.Errorf("watermark requires its overlay image")
+	case string(models.OperationText):
+		dst, err := applyText(src, parameters)
+		return dst, contentType, targetFormat, err
+	case string(models.OperationBorder):
+		dst, err := applyBorder(src, parameters)
+		return dst, contentType, targetFormat, err
+	case string(models.OperationPad):
+		dst, err := applyPad(src, parameters)
+		return dst, contentType, targetFormat, err
+	case string(models.OperationThumbnail):
+		dst, err := applyThumbnail(src, parameters)
+		return dst, contentType, targetFormat, err
 	default:
 		return nil, "", 0, fmt.Errorf("unsupported operation: %s", operation)
 	}
 }
 
-func (s *ImageService) resolveFormat(originalContentType string, parameters map[string]string) (imaging.Format, string) {
-	if parameters == nil {
-		parameters = map[string]string{}
-	}
-
-	if formatStr, ok := parameters["format"]; ok {
-		switch strings.ToLower(formatStr) {
-		case "jpeg", "jpg":
-			return imaging.JPEG, "image/jpeg"
-		case "png":
-			return imaging.PNG, "image/png"
-		case "gif":
-			return imaging.GIF, "image/gif"
-		case "bmp":
-			return imaging.BMP, "image/bmp"
-		case "tiff", "tif":
-			return imaging.TIFF, "image/tiff"
-		}
-	}
-
-	switch strings.ToLower(originalContentType) {
-	case "image/jpeg", "image/jpg":
-		return imaging.JPEG, "image/jpeg"
-	case "image/png":
-		return imaging.PNG, "image/png"
-	case "image/gif":
-		return imaging.GIF, "image/gif"
-	case "image/bmp":
-		return imaging.BMP, "image/bmp"
-	case "image/tiff":
-		return imaging.TIFF, "image/tiff"
-	default:
-		return imaging.PNG, "image/png"
-	}
-}
-
 func parseDimensions(parameters map[string]string) (int, int, error) {
 	width := 0
 	height := 0
@@ -433,6 +658,72 @@ func parseDimensions(parameters map[string]string) (int, int, error) {
 	return width, height, nil
 }
 
+// stepSize predicts the size of the image a transformation produces from an
+// image of bounds b, without allocating it. Invalid parameters are left for
+// the operation itself to report.
+func stepSize(b image.Rectangle, t models.Transformation) image.Point {
+	width, height, _ := parseDimensions(t.Parameters)
+	switch t.Operation {
+	case string(models.OperationResize):
+		switch strings.ToLower(t.Parameters["fit"]) {
+		case "cover":
+			return image.Pt(width, height)
+		case "contain":
+			if width > 0 && height > 0 {
+				if b.Dx() <= width && b.Dy() <= height {
+					return b.Size()
+				}
+				scale := math.Min(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+				return image.Pt(int(math.Round(float64(b.Dx())*scale)), int(math.Round(float64(b.Dy())*scale)))
+			}
+		}
+		return image.Pt(resizedSize(b, width, height))
+	case string(models.OperationCrop):
+		return image.Pt(min(width, b.Dx()), min(height, b.Dy()))
+	case string(models.OperationRotate):
+		if t.Parameters["angle"] == "90" || t.Parameters["angle"] == "270" {
+			return image.Pt(b.Dy(), b.Dx())
+		}
+	case string(models.OperationPad), string(models.OperationThumbnail):
+		return image.Pt(width, height)
+	case string(models.OperationBorder):
+		size, _ := intParam(t.Parameters, "size", 10, 1, maxBorderSize)
+		return image.Pt(b.Dx()+2*size, b.Dy()+2*size)
+	}
+	return b.Size()
+}
+
+// resizedSize returns the size imaging.Resize produces from b for width x
+// height, where a zero side keeps the aspect ratio
+func resizedSize(b image.Rectangle, width, height int) (int, int) {
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return 0, 0
+	}
+	if width == 0 {
+		width = int(math.Max(1, math.Floor(float64(height)*float64(b.Dx())/float64(b.Dy())+0.5)))
+	}
+	if height == 0 {
+		height = int(math.Max(1, math.Floor(float64(width)*float64(b.Dy())/float64(b.Dx())+0.5)))
+	}
+	return width, height
+}
+
+// coverWindow returns the largest part of b with the aspect ratio of
+// width x height, placed as close to the focal point (fx, fy), given as
+// fractions of b, as the edges allow. Scaling it to width x height covers
+// the box without the overflow ever being scaled.
+func coverWindow(b image.Rectangle, width, height int, fx, fy float64) image.Rectangle {
+	cw, ch := b.Dx(), b.Dy()
+	if int64(cw)*int64(height) > int64(ch)*int64(width) {
+		cw = max(1, int(math.Round(float64(ch)*float64(width)/float64(height))))
+	} else {
+		ch = max(1, int(math.Round(float64(cw)*float64(height)/float64(width))))
+	}
+	x0 := clamp(int(math.Round(fx*float64(b.Dx())))-cw/2, 0, b.Dx()-cw)
+	y0 := clamp(int(math.Round(fy*float64(b.Dy())))-ch/2, 0, b.Dy()-ch)
+	return image.Rect(b.Min.X+x0, b.Min.Y+y0, b.Min.X+x0+cw, b.Min.Y+y0+ch)
+}
+
 func parseFloat(value string, defaultValue float64) float64 {
 	if value == "" {
 		return defaultValue
@@ -444,6 +735,82 @@ func parseFloat(value string, defaultValue float64) float64 {
 	return parsed
 }
 
+// RenderDerivative returns an on-the-fly rendering of an image, from the
+// derivative cache when it was rendered before. The cache is keyed by the
+// canonical transformation, so equivalent URLs share one entry. A new
+// rendering is only cached if store returns true, which callers use to stop
+// caching once the owner's quota is full; they record cached renderings
+// with RecordDerivative.
+func (s *ImageService) RenderDerivative(ctx context.Context, image *models.Image, transformations []models.Transformation, canonicalSpec string, store func() bool) ([]byte, string, bool, error) {
+	_, contentType := s.resolveFormat(image.ContentType, outputParameters(transformations))
+	key := DerivativeKey(image.ID.Hex(), canonicalSpec)
+
+	data, err := s.storage.GetFile(ctx, key)
+	if err == nil {
+		return data, contentType, true, nil
+	}
+	if !IsNotFound(err) {
+		s.logger.Warn("Failed to read cached derivative", logger.String("key", key), logger.Error(err))
+	}
+
+	originalData, err := s.storage.GetFile(ctx, image.OriginalKey)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to get original file: %w", err)
+	}
+	src, err := imaging.Decode(bytes.NewReader(originalData), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to decode image: %w", err)
+	}
+	dst, contentType, format, err := s.applyPipeline(src, image.ContentType, transformations, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	data, err = encodeImage(dst, format, outputParameters(transformations))
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to encode derivative: %w", err)
+	}
+
+	if !store() {
+		return data, contentType, false, nil
+	}
+
+	// A failed cache write only costs a re-render next time
+	if err := s.storage.UploadFile(ctx, key, data, contentType); err != nil {
+		s.logger.Warn("Failed to cache derivative", logger.String("key", key), logger.Error(err))
+	}
+	return data, contentType, false, nil
+}
+
+// RecordDerivative adds a newly cached derivative to its image, so that it
+// counts towards the owner's usage and is deleted with the image. Renderings
+// cached while the image was being deleted are removed again.
+func (s *ImageService) RecordDerivative(ctx context.Context, image *models.Image, canonicalSpec string, size int64) {
+	key := DerivativeKey(image.ID.Hex(), canonicalSpec)
+	collection := s.db.Collection("images")
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": image.ID, "derivatives.key": bson.M{"$ne": key}},
+		bson.M{"$push": bson.M{"derivatives": models.Derivative{Key: key, Size: size}}},
+	)
+	if err != nil {
+		s.logger.Warn("Failed to record derivative", logger.String("key", key), logger.Error(err))
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		// Either recorded by a concurrent request, or the image is gone
+		count, err := collection.CountDocuments(ctx, bson.M{"_id": image.ID})
+		if err == nil && count == 0 {
+			if err := s.storage.DeletePrefix(ctx, DerivativePrefix(image.ID.Hex())); err != nil {
+				s.logger.Warn("Failed to delete derivatives", logger.String("image_id", image.ID.Hex()), logger.Error(err))
+			}
+		}
+		return
+	}
+
+	s.recomputeUsage(ctx, image.UserID)
+}
+
 // DeleteImage deletes an image and all its processed versions
 func (s *ImageService) DeleteImage(ctx context.Context, id string) error {
 	// Get image first to get all keys for deletion
@@ -456,13 +823,16 @@ func (s *ImageService) DeleteImage(ctx context.Context, id string) error {
 		return fmt.Errorf("image not found")
 	}
 
-	// TODO: Delete files from S3 storage
-	// Delete original file
-	s.logger.Info("Would delete original file", logger.String("key", image.OriginalKey))
-
-	// Delete processed files
+	// Delete the original and processed files. A failure leaves an orphaned
+	// file behind but must not keep the image record alive.
+	keys := []string{image.OriginalKey}
 	for _, processed := range image.Processed {
-		s.logger.Info("Would delete processed file", logger.String("key", processed.Key))
+		keys = append(keys, processed.Key)
+	}
+	for _, key := range keys {
+		if err := s.storage.DeleteFile(ctx, key); err != nil {
+			s.logger.Warn("Failed to delete file from storage", logger.String("key", key), logger.Error(err))
+		}
 	}
 
 	// Delete from database
@@ -479,6 +849,25 @@ func (s *ImageService) DeleteImage(ctx context.Context, id string) error {
 		return err
 	}
 
+	// Cached derivatives go after the record, so that one cached meanwhile is
+	// either deleted here or by RecordDerivative
+	if err := s.storage.DeletePrefix(ctx, DerivativePrefix(image.ID.Hex())); err != nil {
+		s.logger.Warn("Failed to delete derivatives from storage", logger.String("image_id", id), logger.Error(err))
+	}
+
+	// An album whose cover was this image keeps no cover
+	_, err = s.db.Collection("albums").UpdateMany(ctx, bson.M{"cover_image_id": objectID}, bson.M{"$unset": bson.M{"cover_image_id": ""}})
+	if err != nil {
+		s.logger.Warn("Failed to clear album covers", logger.String("image_id", id), logger.Error(err))
+	}
+
+	// Share links of the image stop working with it
+	if _, err := s.db.Collection("share_links").DeleteMany(ctx, bson.M{"image_id": objectID}); err != nil {
+		s.logger.Warn("Failed to delete share links", logger.String("image_id", id), logger.Error(err))
+	}
+
+	s.recomputeUsage(ctx, image.UserID)
+
 	s.logger.Info("Image deleted successfully", logger.String("image_id", id))
 	return nil
 }
@@ -1,12 +1,14 @@
 package services
 
 import (
+	"errors"
 	"image"
 	"image/color"
 	"testing"
 
 	"github.com/disintegration/imaging"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"go.uber.org/zap"
 )
 
 func newSolidImage(width, height int, c color.Color) image.Image {
@@ -15,7 +17,7 @@ func newSolidImage(width, height int, c color.Color) image.Image {
 }
 
 func TestApplyOperationResize(t *testing.T) {
-	svc := &ImageService{}
+	svc := newTestImageService(t)
 	src := newSolidImage(100, 50, color.RGBA{255, 0, 0, 255})
 
 	dst, contentType, format, err := svc.applyOperation(src, "image/jpeg", string(models.OperationResize), map[string]string{
@@ -34,8 +36,55 @@ func TestApplyOperationResize(t *testing.T) {
 	}
 }
 
+func TestApplyOperationResizeRejectsOversizedResult(t *testing.T) {
+	svc := newTestImageService(t)
+	src := newSolidImage(10, 1000, color.RGBA{255, 0, 0, 255})
+
+	// Only the width is requested; the height follows the aspect ratio
+	_, _, _, err := svc.applyOperation(src, "image/png", string(models.OperationResize), map[string]string{"width": "8192"})
+	if err == nil {
+		t.Fatal("expected a resize to 8192x819200 to be rejected")
+	}
+
+	// Cover crops the thin image before scaling, so it stays within the box
+	dst, _, _, err := svc.applyOperation(src, "image/png", string(models.OperationResize), map[string]string{
+		"width": "8192", "height": "64", "fit": "cover",
+	})
+	if err != nil {
+		t.Fatalf("cover resize returned error: %v", err)
+	}
+	if b := dst.Bounds(); b.Dx() != 8192 || b.Dy() != 64 {
+		t.Fatalf("unexpected cover dimensions: got %dx%d, want 8192x64", b.Dx(), b.Dy())
+	}
+}
+
+func TestApplyPipelineEnforcesMegapixelLimit(t *testing.T) {
+	svc := NewImageService(nil, newTestStorage(t), zap.NewNop(), 1)
+	src := newSolidImage(100, 100, color.White)
+
+	// Each step is within the output cap, but the padded canvas is 4 megapixels
+	transformations := []models.Transformation{
+		{Operation: string(models.OperationCrop), Parameters: map[string]string{"width": "10", "height": "10"}},
+		{Operation: string(models.OperationPad), Parameters: map[string]string{"width": "2000", "height": "2000"}},
+		{Operation: string(models.OperationResize), Parameters: map[string]string{"width": "50"}},
+	}
+	_, _, _, err := svc.applyPipeline(src, "image/png", transformations, nil)
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("expected ErrImageTooLarge for the padded step, got %v", err)
+	}
+
+	// A step that shrinks the image back is fine on its own
+	dst, _, _, err := svc.applyPipeline(src, "image/png", []models.Transformation{transformations[2]}, nil)
+	if err != nil {
+		t.Fatalf("applyPipeline returned error: %v", err)
+	}
+	if b := dst.Bounds(); b.Dx() != 50 || b.Dy() != 50 {
+		t.Fatalf("unexpected dimensions %dx%d", b.Dx(), b.Dy())
+	}
+}
+
 func TestApplyOperationCrop(t *testing.T) {
-	svc := &ImageService{}
+	svc := newTestImageService(t)
 	src := newSolidImage(100, 100, color.RGBA{0, 255, 0, 255})
 
 	dst, _, _, err := svc.applyOperation(src, "image/png", string(models.OperationCrop), map[string]string{
@@ -52,7 +101,7 @@ func TestApplyOperationCrop(t *testing.T) {
 }
 
 func TestApplyOperationRotate(t *testing.T) {
-	svc := &ImageService{}
+	svc := newTestImageService(t)
 	src := newSolidImage(10, 20, color.RGBA{0, 0, 255, 255})
 
 	dst, _, _, err := svc.applyOperation(src, "image/png", string(models.OperationRotate), map[string]string{
@@ -68,7 +117,7 @@ func TestApplyOperationRotate(t *testing.T) {
 }
 
 func TestApplyOperationFlipHorizontal(t *testing.T) {
-	svc := &ImageService{}
+	svc := newTestImageService(t)
 	img := imaging.New(2, 1, color.RGBA{0, 0, 0, 0})
 	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
 	img.Set(1, 0, color.RGBA{0, 0, 255, 255})
@@ -87,7 +136,7 @@ func TestApplyOperationFlipHorizontal(t *testing.T) {
 }
 
 func TestApplyOperationGrayscale(t *testing.T) {
-	svc := &ImageService{}
+	svc := newTestImageService(t)
 	src := newSolidImage(5, 5, color.RGBA{200, 100, 50, 255})
 
 	dst, _, _, err := svc.applyOperation(src, "image/jpeg", string(models.OperationGrayscale), map[string]string{})
@@ -101,7 +150,7 @@ func TestApplyOperationGrayscale(t *testing.T) {
 }
 
 func TestApplyOperationCompressKeepsDimensions(t *testing.T) {
-	svc := &ImageService{}
+	svc := newTestImageService(t)
 	src := newSolidImage(30, 40, color.RGBA{10, 20, 30, 255})
 
 	dst, contentType, format, err := svc.applyOperation(src, "image/jpeg", string(models.OperationCompress), map[string]string{
@@ -118,3 +167,65 @@ func TestApplyOperationCompressKeepsDimensions(t *testing.T) {
 		t.Fatalf("compress should preserve dimensions: got %v, want %v", dst.Bounds(), src.Bounds())
 	}
 }
+
+func TestApplyPipelineAppliesInOrder(t *testing.T) {
+	svc := newTestImageService(t)
+	src := newSolidImage(200, 100, color.RGBA{255, 0, 0, 255})
+
+	dst, contentType, format, err := svc.applyPipeline(src, "image/png", []models.Transformation{
+		{Operation: string(models.OperationResize), Parameters: map[string]string{"width": "100"}},
+		{Operation: string(models.OperationRotate), Parameters: map[string]string{"angle": "90"}},
+		{Operation: string(models.OperationCrop), Parameters: map[string]string{"width": "40", "height": "30"}},
+		{Operation: string(models.OperationConvert), Parameters: map[string]string{"format": "jpeg", "quality": "80"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("applyPipeline returned error: %v", err)
+	}
+	if format != imaging.JPEG || contentType != "image/jpeg" {
+		t.Fatalf("expected the convert step to pick JPEG, got %v, %s", format, contentType)
+	}
+	// 200x100 -> 100x50 -> rotated 50x100 -> cropped 40x30
+	b := dst.Bounds()
+	if b.Dx() != 40 || b.Dy() != 30 {
+		t.Fatalf("unexpected pipeline dimensions: got %dx%d, want 40x30", b.Dx(), b.Dy())
+	}
+}
+
+func TestOutputParametersLaterStepsWin(t *testing.T) {
+	params := outputParameters([]models.Transformation{
+		{Operation: "resize", Parameters: map[string]string{"width": "10", "format": "png"}},
+		{Operation: "convert", Parameters: map[string]string{"format": "jpeg", "quality": "70"}},
+	})
+	if params["format"] != "jpeg" || params["quality"] != "70" || len(params) != 2 {
+		t.Fatalf("unexpected output parameters: %v", params)
+	}
+}
+
+func TestValidateTransformations(t *testing.T) {
+	valid := []models.Transformation{
+		{Operation: "resize", Parameters: map[string]string{"width": "300"}},
+		{Operation: "grayscale"},
+		{Operation: "convert", Parameters: map[string]string{"format": "png"}},
+	}
+	if err := ValidateTransformations(valid); err != nil {
+		t.Fatalf("expected a valid pipeline, got %v", err)
+	}
+
+	invalid := map[string][]models.Transformation{
+		"empty":           nil,
+		"unknown":         {{Operation: "explode"}},
+		"resize no size":  {{Operation: "resize"}},
+		"crop bad height": {{Operation: "crop", Parameters: map[string]string{"width": "10", "height": "x"}}},
+		"rotate angle":    {{Operation: "rotate", Parameters: map[string]string{"angle": "45"}}},
+		"format":          {{Operation: "grayscale", Parameters: map[string]string{"format": "xcf"}}},
+		"quality":         {{Operation: "compress", Parameters: map[string]string{"quality": "0"}}},
+		"convert":         {{Operation: "convert"}},
+		"later step":      {valid[0], {Operation: "blur", Parameters: map[string]string{"sigma": "-1"}}},
+		"too long":        make([]models.Transformation, models.MaxTransformations+1),
+	}
+	for name, pipeline := range invalid {
+		if err := ValidateTransformations(pipeline); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
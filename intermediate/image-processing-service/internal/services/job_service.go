@@ -0,0 +1,497 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/database"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	// jobTimeout bounds one attempt at a job
+	jobTimeout = 60 * time.Second
+	// jobLease is how long a claimed job stays with its worker. It outlasts
+	// jobTimeout, so only jobs of a crashed worker are claimed again.
+	jobLease = 2 * jobTimeout
+	// jobPollInterval is how often idle workers look for due jobs, such as
+	// retries or jobs queued by another instance
+	jobPollInterval = 2 * time.Second
+
+	// Retries wait retryBaseDelay, doubling per attempt up to retryMaxDelay
+	retryBaseDelay = 5 * time.Second
+	retryMaxDelay  = 5 * time.Minute
+
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 3
+)
+
+// JobService persists processing jobs in MongoDB and runs them on a pool of
+// workers. Jobs survive restarts: queued jobs wait in the collection, and a
+// job whose worker died is picked up again once its lease runs out.
+type JobService struct {
+	db                *database.MongoDB
+	imageService      *ImageService
+	logger            *zap.Logger
+	workers           int
+	maxAttempts       int
+	webhookKey        []byte
+	webhookClient     *http.Client
+	allowPrivateHooks bool
+	wake              chan struct{}
+	cancel            context.CancelFunc
+	wg                sync.WaitGroup
+}
+
+func NewJobService(db *database.MongoDB, imageService *ImageService, logger *zap.Logger, workers, maxAttempts int, webhookKey string, allowPrivateHooks bool) *JobService {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &JobService{
+		db:                db,
+		imageService:      imageService,
+		logger:            logger,
+		workers:           workers,
+		maxAttempts:       maxAttempts,
+		webhookKey:        []byte(webhookKey),
+		webhookClient:     newWebhookClient(allowPrivateHooks),
+		allowPrivateHooks: allowPrivateHooks,
+		wake:              make(chan struct{}, 1),
+	}
+}
+
+// Start creates the queue index and starts the workers
+func (s *JobService) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.Collection("jobs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}},
+	})
+	if err != nil {
+		s.logger.Error("Failed to create job index", logger.Error(err))
+		return fmt.Errorf("failed to create job index: %w", err)
+	}
+
+	runCtx, stop := context.WithCancel(context.Background())
+	s.cancel = stop
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.worker(runCtx)
+	}
+	s.logger.Info("Job workers started", logger.Int("workers", s.workers))
+	return nil
+}
+
+// Stop stops claiming jobs and waits for running ones to finish
+func (s *JobService) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// Enqueue persists a validated pipeline as a queued job
+func (s *JobService) Enqueue(userID, imageID primitive.ObjectID, transformations []models.Transformation, webhookURL string) (*models.Job, error) {
+	now := time.Now()
+	job := &models.Job{
+		ID:              primitive.NewObjectID(),
+		UserID:          userID,
+		ImageID:         imageID,
+		Transformations: transformations,
+		Status:          models.JobStatusQueued,
+		MaxAttempts:     s.maxAttempts,
+		WebhookURL:      webhookURL,
+		RunAt:           now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	if _, err := s.db.Collection("jobs").InsertOne(context.Background(), job); err != nil {
+		s.logger.Error("Failed to insert job", logger.Error(err))
+		return nil, fmt.Errorf("failed to insert job: %w", err)
+	}
+
+	// Wake an idle worker instead of waiting for the next poll
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	s.logger.Info("Job queued", logger.String("job_id", job.ID.Hex()), logger.String("image_id", imageID.Hex()))
+	return job, nil
+}
+
+// GetJob retrieves a job by ID
+func (s *JobService) GetJob(id string) (*models.Job, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		s.logger.Error("Invalid job ID", logger.Error(err))
+		return nil, fmt.Errorf("invalid job ID: %w", err)
+	}
+
+	var job models.Job
+	err = s.db.Collection("jobs").FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		s.logger.Error("Failed to get job", logger.Error(err))
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (s *JobService) worker(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := s.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to claim job", logger.Error(err))
+		}
+		if job != nil {
+			s.run(job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim takes the lease on the oldest due job: a queued job whose run time
+// has come, or a processing job whose worker let the lease run out
+func (s *JobService) claim(ctx context.Context) (*models.Job, error) {
+	now := time.Now()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.JobStatusQueued, "run_at": bson.M{"$lte": now}},
+		bson.M{"status": models.JobStatusProcessing, "lease_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      models.JobStatusProcessing,
+			"progress":    0,
+			"lease_until": now.Add(jobLease),
+			"updated_at":  now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.Job
+	err := s.db.Collection("jobs").FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// run makes one attempt at a job and records the outcome
+func (s *JobService) run(job *models.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	// A panic fails the job rather than killing the worker. The job is not
+	// retried: the same input would panic again.
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("Job panicked",
+				logger.String("job_id", job.ID.Hex()),
+				logger.String("stack", string(debug.Stack())),
+			)
+			s.fail(job, fmt.Sprintf("processing panicked: %v", r))
+		}
+	}()
+
+	s.logger.Info("Processing job",
+		logger.String("job_id", job.ID.Hex()),
+		logger.Int("attempt", job.Attempts),
+	)
+
+	// A job claimed again after its lease ran out may have crashed a worker
+	if job.Attempts > job.MaxAttempts {
+		s.fail(job, "no attempts left")
+		return
+	}
+
+	// Invalid pipelines and deleted images will not get better on retry
+	if err := ValidateTransformations(job.Transformations); err != nil {
+		s.fail(job, err.Error())
+		return
+	}
+	image, err := s.imageService.GetImage(job.ImageID.Hex())
+	if err != nil {
+		s.retryOrFail(job, err)
+		return
+	}
+	if image == nil {
+		s.fail(job, "image not found")
+		return
+	}
+
+	processed, err := s.imageService.ProcessPipeline(ctx, image, job.Transformations, func(percent int) {
+		s.setProgress(job.ID, percent)
+	})
+	if err != nil {
+		if errors.Is(err, ErrOverlayNotFound) || errors.Is(err, ErrImageTooLarge) {
+			s.fail(job, err.Error())
+			return
+		}
+		s.retryOrFail(job, err)
+		return
+	}
+
+	now := time.Now()
+	s.finish(job, bson.M{
+		"status":       models.JobStatusCompleted,
+		"progress":     100,
+		"result":       processed,
+		"completed_at": now,
+		"updated_at":   now,
+	}, models.JobEventCompleted)
+}
+
+func (s *JobService) setProgress(id primitive.ObjectID, percent int) {
+	update := bson.M{"$set": bson.M{"progress": percent, "updated_at": time.Now()}}
+	if _, err := s.db.Collection("jobs").UpdateOne(context.Background(), bson.M{"_id": id}, update); err != nil {
+		s.logger.Warn("Failed to update job progress", logger.String("job_id", id.Hex()), logger.Error(err))
+	}
+}
+
+// retryOrFail queues the job again after a backoff, or fails it for good
+// once it has used all its attempts
+func (s *JobService) retryOrFail(job *models.Job, cause error) {
+	if job.Attempts >= job.MaxAttempts {
+		s.fail(job, cause.Error())
+		return
+	}
+
+	delay := retryDelay(job.Attempts)
+	s.logger.Warn("Job attempt failed, retrying",
+		logger.String("job_id", job.ID.Hex()),
+		logger.Int("attempt", job.Attempts),
+		logger.String("retry_in", delay.String()),
+		logger.Error(cause),
+	)
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"status":     models.JobStatusQueued,
+			"error":      cause.Error(),
+			"run_at":     now.Add(delay),
+			"updated_at": now,
+		},
+		"$unset": bson.M{"lease_until": ""},
+	}
+	if _, err := s.db.Collection("jobs").UpdateOne(context.Background(), bson.M{"_id": job.ID}, update); err != nil {
+		s.logger.Error("Failed to reschedule job", logger.String("job_id", job.ID.Hex()), logger.Error(err))
+	}
+}
+
+func (s *JobService) fail(job *models.Job, reason string) {
+	s.logger.Error("Job failed", logger.String("job_id", job.ID.Hex()), logger.String("error", reason))
+
+	now := time.Now()
+	s.finish(job, bson.M{
+		"status":       models.JobStatusFailed,
+		"error":        reason,
+		"completed_at": now,
+		"updated_at":   now,
+	}, models.JobEventFailed)
+}
+
+// finish records the final state of a job and notifies its webhook
+func (s *JobService) finish(job *models.Job, set bson.M, event string) {
+	update := bson.M{"$set": set, "$unset": bson.M{"lease_until": ""}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var done models.Job
+	err := s.db.Collection("jobs").FindOneAndUpdate(context.Background(), bson.M{"_id": job.ID}, update, opts).Decode(&done)
+	if err != nil {
+		s.logger.Error("Failed to update job", logger.String("job_id", job.ID.Hex()), logger.Error(err))
+		return
+	}
+
+	if done.WebhookURL != "" {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.notify(&done, event)
+		}()
+	}
+}
+
+// notify posts the job to its webhook, retrying a few times, and records
+// whether it was delivered
+func (s *JobService) notify(job *models.Job, event string) {
+	body, err := json.Marshal(models.JobWebhookPayload{Event: event, Job: job.ToResponse()})
+	if err != nil {
+		s.logger.Error("Failed to encode webhook payload", logger.Error(err))
+		return
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if lastErr = s.postWebhook(job.WebhookURL, event, body); lastErr == nil {
+			break
+		}
+		s.logger.Warn("Webhook delivery failed",
+			logger.String("job_id", job.ID.Hex()),
+			logger.Int("attempt", attempt),
+			logger.Error(lastErr),
+		)
+		if attempt < webhookAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	update := bson.M{"$set": set}
+	if lastErr == nil {
+		set["webhook_sent_at"] = time.Now()
+		update["$unset"] = bson.M{"webhook_error": ""}
+	} else {
+		set["webhook_error"] = lastErr.Error()
+	}
+	if _, err := s.db.Collection("jobs").UpdateOne(context.Background(), bson.M{"_id": job.ID}, update); err != nil {
+		s.logger.Error("Failed to record webhook delivery", logger.String("job_id", job.ID.Hex()), logger.Error(err))
+	}
+}
+
+func (s *JobService) postWebhook(target, event string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "image-processing-service")
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+WebhookSignature(s.webhookKey, timestamp, body))
+
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ValidateWebhookURL checks a user-supplied webhook URL. Unless private hosts
+// are allowed, the address is checked again when connecting, since DNS can
+// change in between.
+func (s *JobService) ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook_url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return fmt.Errorf("webhook_url must not contain credentials")
+	}
+	if !s.allowPrivateHooks {
+		if ip := net.ParseIP(u.Hostname()); ip != nil && isPrivateAddress(ip) {
+			return fmt.Errorf("webhook_url must not point to a private address")
+		}
+		if u.Hostname() == "localhost" {
+			return fmt.Errorf("webhook_url must not point to a private address")
+		}
+	}
+	return nil
+}
+
+// WebhookSignature is the hex HMAC-SHA256 of "<timestamp>.<body>". Receivers
+// recompute it to check that a delivery came from this service.
+func WebhookSignature(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay is the backoff before the attempt after the given one
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
+
+// isPrivateAddress reports whether a webhook would reach the service's own
+// network rather than the internet
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast()
+}
+
+// newWebhookClient returns the HTTP client for webhooks. Unless private hosts
+// are allowed, it refuses to connect to private addresses, whatever the URL
+// resolved to.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateAddress(ip) {
+				return errors.New("webhook address is not allowed: " + host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		// Redirects could lead to a private address the URL check never saw
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
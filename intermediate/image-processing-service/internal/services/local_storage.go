@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStorage keeps files in a directory, for development and tests without
+// S3. Downloads are served by the API under /files/ with signed URLs.
+type LocalStorage struct {
+	root string
+	key  []byte
+}
+
+func NewLocalStorage(root, signingKey string) (*LocalStorage, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage directory: %w", err)
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStorage{root: abs, key: []byte(signingKey)}, nil
+}
+
+// path maps a key such as "uploads/123.jpg" to a file under the root,
+// rejecting keys that would escape it
+func (s *LocalStorage) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStorage) UploadFile(ctx context.Context, key string, data []byte, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalStorage) GetFile(ctx context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, key)
+	}
+	return data, err
+}
+
+// DeleteFile removes a file; like S3, deleting a missing key is not an error
+func (s *LocalStorage) DeleteFile(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DeletePrefix removes the directory of the prefix with everything in it
+func (s *LocalStorage) DeletePrefix(ctx context.Context, prefix string) error {
+	if !strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("invalid storage prefix: %q", prefix)
+	}
+	p, err := s.path(strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(p)
+}
+
+// DownloadURL returns a signed /files/ path for the key
+func (s *LocalStorage) DownloadURL(ctx context.Context, key, filename string, expires time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	exp := time.Now().Add(expires).Unix()
+	q := url.Values{}
+	q.Set("e", strconv.FormatInt(exp, 10))
+	q.Set("n", filename)
+	q.Set("s", s.signature(key, filename, exp))
+	return "/files/" + key + "?" + q.Encode(), nil
+}
+
+// VerifyDownload checks the signature and expiry of a /files/ URL
+func (s *LocalStorage) VerifyDownload(key, filename string, expires int64, signature string, now time.Time) error {
+	if !hmac.Equal([]byte(s.signature(key, filename, expires)), []byte(signature)) {
+		return fmt.Errorf("invalid signature")
+	}
+	if now.Unix() > expires {
+		return fmt.Errorf("URL expired")
+	}
+	return nil
+}
+
+// signature covers the key, the download filename and the expiry. The
+// "file:" prefix keeps it apart from on-the-fly image URL signatures, which
+// use the same key.
+func (s *LocalStorage) signature(key, filename string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte("file:" + key + "\n" + filename + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
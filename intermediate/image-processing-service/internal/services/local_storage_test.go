@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// newTestImageService returns an ImageService on local storage in a
+// temporary directory, without a database
+func newTestImageService(t *testing.T) *ImageService {
+	t.Helper()
+	return NewImageService(nil, newTestStorage(t), zap.NewNop(), 50)
+}
+
+func newTestStorage(t *testing.T) *LocalStorage {
+	t.Helper()
+	storage, err := NewLocalStorage(t.TempDir(), "test-key")
+	if err != nil {
+		t.Fatalf("NewLocalStorage returned error: %v", err)
+	}
+	return storage
+}
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := storage.UploadFile(ctx, "uploads/a.png", []byte("data"), "image/png"); err != nil {
+		t.Fatalf("UploadFile returned error: %v", err)
+	}
+	data, err := storage.GetFile(ctx, "uploads/a.png")
+	if err != nil || string(data) != "data" {
+		t.Fatalf("GetFile = %q, %v; want \"data\"", data, err)
+	}
+
+	if err := storage.DeleteFile(ctx, "uploads/a.png"); err != nil {
+		t.Fatalf("DeleteFile returned error: %v", err)
+	}
+	if _, err := storage.GetFile(ctx, "uploads/a.png"); !IsNotFound(err) {
+		t.Fatalf("GetFile after delete: want not found, got %v", err)
+	}
+	if err := storage.DeleteFile(ctx, "uploads/a.png"); err != nil {
+		t.Fatalf("deleting a missing file returned error: %v", err)
+	}
+}
+
+func TestLocalStorageDeletePrefix(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"derived/a/1", "derived/a/2", "derived/ab/1", "uploads/a.png"} {
+		if err := storage.UploadFile(ctx, key, []byte("data"), "image/png"); err != nil {
+			t.Fatalf("UploadFile(%s) returned error: %v", key, err)
+		}
+	}
+
+	if err := storage.DeletePrefix(ctx, "derived/a/"); err != nil {
+		t.Fatalf("DeletePrefix returned error: %v", err)
+	}
+	for key, kept := range map[string]bool{"derived/a/1": false, "derived/a/2": false, "derived/ab/1": true, "uploads/a.png": true} {
+		if _, err := storage.GetFile(ctx, key); IsNotFound(err) == kept {
+			t.Errorf("%s: kept=%v, got %v", key, kept, err)
+		}
+	}
+
+	// Deleting again, or a prefix without files, is not an error
+	if err := storage.DeletePrefix(ctx, "derived/a/"); err != nil {
+		t.Fatalf("deleting a missing prefix returned error: %v", err)
+	}
+	for _, prefix := range []string{"derived/a", "", "/", "../"} {
+		if err := storage.DeletePrefix(ctx, prefix); err == nil {
+			t.Errorf("DeletePrefix(%q) was accepted", prefix)
+		}
+	}
+}
+
+func TestLocalStorageRejectsKeysOutsideRoot(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"", "../secret", "uploads/../../secret", "/etc/passwd", "uploads\\a.png", "uploads//a.png"} {
+		if err := storage.UploadFile(ctx, key, []byte("x"), "image/png"); err == nil {
+			t.Errorf("UploadFile(%q): expected error", key)
+		}
+		if _, err := storage.GetFile(ctx, key); err == nil || IsNotFound(err) {
+			t.Errorf("GetFile(%q): expected invalid key error, got %v", key, err)
+		}
+	}
+}
+
+func TestLocalStorageDownloadURL(t *testing.T) {
+	storage := newTestStorage(t)
+
+	raw, err := storage.DownloadURL(context.Background(), "uploads/a.png", "my photo.png", time.Minute)
+	if err != nil {
+		t.Fatalf("DownloadURL returned error: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("DownloadURL returned invalid URL %q: %v", raw, err)
+	}
+	if u.Path != "/files/uploads/a.png" {
+		t.Fatalf("unexpected path %q", u.Path)
+	}
+
+	q := u.Query()
+	expires, _ := strconv.ParseInt(q.Get("e"), 10, 64)
+	key := strings.TrimPrefix(u.Path, "/files/")
+	if err := storage.VerifyDownload(key, q.Get("n"), expires, q.Get("s"), time.Now()); err != nil {
+		t.Fatalf("VerifyDownload rejected a fresh URL: %v", err)
+	}
+	if err := storage.VerifyDownload(key, "other.png", expires, q.Get("s"), time.Now()); err == nil {
+		t.Fatal("VerifyDownload accepted a changed filename")
+	}
+	if err := storage.VerifyDownload("uploads/b.png", q.Get("n"), expires, q.Get("s"), time.Now()); err == nil {
+		t.Fatal("VerifyDownload accepted a changed key")
+	}
+	if err := storage.VerifyDownload(key, q.Get("n"), expires, q.Get("s"), time.Now().Add(2*time.Minute)); err == nil {
+		t.Fatal("VerifyDownload accepted an expired URL")
+	}
+}
+
+func TestRenderDerivativeCachesInStorage(t *testing.T) {
+	svc := newTestImageService(t)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, newSolidImage(40, 20, color.RGBA{0, 0, 255, 255}), imaging.PNG); err != nil {
+		t.Fatalf("failed to encode source: %v", err)
+	}
+	img := &models.Image{ID: primitive.NewObjectID(), OriginalKey: "uploads/src.png", ContentType: "image/png"}
+	if err := svc.storage.UploadFile(ctx, img.OriginalKey, buf.Bytes(), img.ContentType); err != nil {
+		t.Fatalf("UploadFile returned error: %v", err)
+	}
+
+	transformations, canonical, err := ParseTransformSpec("w_20")
+	if err != nil {
+		t.Fatalf("ParseTransformSpec returned error: %v", err)
+	}
+
+	// Over quota, a rendering is served but not cached
+	full := func() bool { return false }
+	if _, _, hit, err := svc.RenderDerivative(ctx, img, transformations, canonical, full); err != nil || hit {
+		t.Fatalf("render over quota: hit=%v err=%v; want a miss", hit, err)
+	}
+	if _, err := svc.storage.GetFile(ctx, DerivativeKey(img.ID.Hex(), canonical)); !IsNotFound(err) {
+		t.Fatalf("render over quota was cached: %v", err)
+	}
+
+	store := func() bool { return true }
+	first, _, hit, err := svc.RenderDerivative(ctx, img, transformations, canonical, store)
+	if err != nil || hit {
+		t.Fatalf("first render: hit=%v err=%v; want a miss", hit, err)
+	}
+	second, _, hit, err := svc.RenderDerivative(ctx, img, transformations, canonical, store)
+	if err != nil || !hit {
+		t.Fatalf("second render: hit=%v err=%v; want a hit", hit, err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatal("cached derivative differs from the rendered one")
+	}
+
+	// Deleting the image's derivatives leaves the original alone
+	if err := svc.storage.DeletePrefix(ctx, DerivativePrefix(img.ID.Hex())); err != nil {
+		t.Fatalf("DeletePrefix returned error: %v", err)
+	}
+	if _, _, hit, err := svc.RenderDerivative(ctx, img, transformations, canonical, store); err != nil || hit {
+		t.Fatalf("render after DeletePrefix: hit=%v err=%v; want a miss", hit, err)
+	}
+}
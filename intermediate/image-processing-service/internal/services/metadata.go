@@ -0,0 +1,277 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// ErrInvalidImage is returned for uploads whose bytes do not match their type
+var ErrInvalidImage = errors.New("invalid image")
+
+// ErrImageTooLarge is returned for uploads over the configured dimensions
+var ErrImageTooLarge = errors.New("image too large")
+
+// orientedJPEGQuality is used when an upload is rotated upright and re-encoded
+const orientedJPEGQuality = 95
+
+// CleanedImage is an upload after orientation correction and metadata stripping
+type CleanedImage struct {
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// CleanImage prepares an upload for storage. JPEGs with an EXIF orientation
+// are rotated upright and re-encoded; otherwise metadata (EXIF, XMP, IPTC,
+// comments and text chunks) is removed from JPEG, PNG and WebP files without
+// touching the pixels. Colour profiles are kept. Other formats are stored as is.
+func CleanImage(data []byte, contentType string) (*CleanedImage, error) {
+	var cleaned []byte
+	var err error
+
+	switch strings.ToLower(contentType) {
+	case "image/jpeg", "image/jpg":
+		if jpegOrientation(data) > 1 {
+			return reorientJPEG(data)
+		}
+		cleaned, err = stripJPEGMetadata(data)
+	case "image/png":
+		cleaned, err = stripPNGMetadata(data)
+	case "image/webp":
+		cleaned, err = stripWebPMetadata(data)
+	default:
+		cleaned = data
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(cleaned))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	return &CleanedImage{Data: cleaned, Width: config.Width, Height: config.Height}, nil
+}
+
+// CheckDimensions reads the dimensions from an image header and rejects
+// images wider or taller than maxDimension or over maxMegapixels, before
+// anything decodes the pixels. A small file can declare enormous dimensions
+// and exhaust memory when decoded. Zero limits are not checked.
+func CheckDimensions(data []byte, maxDimension, maxMegapixels int) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	if maxDimension > 0 && (config.Width > maxDimension || config.Height > maxDimension) {
+		return fmt.Errorf("%w: %dx%d exceeds %d pixels per side", ErrImageTooLarge, config.Width, config.Height, maxDimension)
+	}
+	if maxMegapixels > 0 && int64(config.Width)*int64(config.Height) > int64(maxMegapixels)*1000000 {
+		return fmt.Errorf("%w: %dx%d exceeds %d megapixels", ErrImageTooLarge, config.Width, config.Height, maxMegapixels)
+	}
+	return nil
+}
+
+// reorientJPEG applies the EXIF orientation to the pixels. The re-encoded
+// file carries no metadata, so nothing is left to strip.
+func reorientJPEG(data []byte) (*CleanedImage, error) {
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(orientedJPEGQuality)); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	bounds := img.Bounds()
+	return &CleanedImage{Data: buf.Bytes(), Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// jpegSegment is one marker segment of a JPEG header
+type jpegSegment struct {
+	marker byte
+	start  int // offset of the 0xFF marker byte
+	end    int // offset just past the segment
+}
+
+// jpegSegments lists the header segments of a JPEG up to the start of scan.
+// It returns the offset of the SOS marker, after which the data is copied as is.
+func jpegSegments(data []byte) ([]jpegSegment, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, fmt.Errorf("not a JPEG file")
+	}
+	var segments []jpegSegment
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, 0, fmt.Errorf("malformed JPEG marker at offset %d", i)
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			i++ // fill byte
+			continue
+		}
+		if marker == 0xDA {
+			return segments, i, nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil, 0, fmt.Errorf("truncated JPEG segment at offset %d", i)
+		}
+		segments = append(segments, jpegSegment{marker: marker, start: i, end: i + 2 + length})
+		i += 2 + length
+	}
+	return nil, 0, fmt.Errorf("JPEG has no image data")
+}
+
+// keepJPEGSegment reports whether a header segment survives stripping: all
+// non-APP segments, JFIF (APP0), ICC profiles (APP2) and Adobe colour info (APP14)
+func keepJPEGSegment(marker byte) bool {
+	if marker == 0xFE { // comment
+		return false
+	}
+	if marker >= 0xE0 && marker <= 0xEF {
+		return marker == 0xE0 || marker == 0xE2 || marker == 0xEE
+	}
+	return true
+}
+
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	segments, sos, err := jpegSegments(data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	for _, seg := range segments {
+		if keepJPEGSegment(seg.marker) {
+			out = append(out, data[seg.start:seg.end]...)
+		}
+	}
+	return append(out, data[sos:]...), nil
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG (1-8), or 0 if it
+// has none
+func jpegOrientation(data []byte) int {
+	segments, _, err := jpegSegments(data)
+	if err != nil {
+		return 0
+	}
+	for _, seg := range segments {
+		payload := data[seg.start+4 : seg.end]
+		if seg.marker != 0xE1 || !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			continue
+		}
+		return exifOrientation(payload[6:])
+	}
+	return 0
+}
+
+// exifOrientation reads the orientation tag from IFD0 of a TIFF-structured
+// EXIF block
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// strippedPNGChunks are the ancillary chunks that carry metadata
+var strippedPNGChunks = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, fmt.Errorf("not a PNG file")
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+	for i := len(signature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, fmt.Errorf("truncated PNG chunk at offset %d", i)
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, fmt.Errorf("truncated PNG chunk at offset %d", i)
+		}
+		if !strippedPNGChunks[string(data[i+4:i+8])] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// VP8X feature flags announcing EXIF and XMP chunks
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("not a WebP file")
+	}
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, fmt.Errorf("truncated WebP chunk at offset %d", i)
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if end == len(data)+1 && size%2 == 1 {
+			end = len(data) // some writers drop the final padding byte
+		}
+		if end > len(data) || end < i {
+			return nil, fmt.Errorf("truncated WebP chunk at offset %d", i)
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			if size > 0 {
+				out[start+8] &^= webpFlagEXIF | webpFlagXMP
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
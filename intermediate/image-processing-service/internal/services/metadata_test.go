@@ -0,0 +1,169 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// exifSegment builds a big-endian APP1 EXIF segment holding only an orientation tag
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(tiff[18:], orientation)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func encodeTestImage(t *testing.T, img image.Image, format imaging.Format) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, format); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withJPEGSegments inserts header segments right after the SOI marker
+func withJPEGSegments(data []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, data[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, data[2:]...)
+}
+
+func TestCleanImageRotatesJPEG(t *testing.T) {
+	src := encodeTestImage(t, newSolidImage(40, 20, color.RGBA{255, 0, 0, 255}), imaging.JPEG)
+	data := withJPEGSegments(src, exifSegment(6))
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation = %d, want 6", got)
+	}
+
+	cleaned, err := CleanImage(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("CleanImage returned error: %v", err)
+	}
+	if cleaned.Width != 20 || cleaned.Height != 40 {
+		t.Errorf("unexpected dimensions: got %dx%d, want 20x40", cleaned.Width, cleaned.Height)
+	}
+	if bytes.Contains(cleaned.Data, []byte("Exif")) {
+		t.Error("EXIF data was not removed")
+	}
+}
+
+func TestCleanImageStripsJPEGMetadata(t *testing.T) {
+	src := encodeTestImage(t, newSolidImage(40, 20, color.RGBA{0, 255, 0, 255}), imaging.JPEG)
+	comment := append([]byte{0xFF, 0xFE, 0, 9}, "secret!"...)
+	data := withJPEGSegments(src, exifSegment(1), comment)
+
+	cleaned, err := CleanImage(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("CleanImage returned error: %v", err)
+	}
+	if !bytes.Equal(cleaned.Data, src) {
+		t.Error("expected the original file without its EXIF and comment segments")
+	}
+	if cleaned.Width != 40 || cleaned.Height != 20 {
+		t.Errorf("unexpected dimensions: got %dx%d", cleaned.Width, cleaned.Height)
+	}
+}
+
+func TestCleanImageStripsPNGText(t *testing.T) {
+	src := encodeTestImage(t, newSolidImage(8, 8, color.White), imaging.PNG)
+	text := []byte("Author\x00someone")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	// After the signature (8 bytes) and IHDR (25 bytes)
+	data := append(append(append([]byte{}, src[:33]...), chunk...), src[33:]...)
+
+	cleaned, err := CleanImage(data, "image/png")
+	if err != nil {
+		t.Fatalf("CleanImage returned error: %v", err)
+	}
+	if !bytes.Equal(cleaned.Data, src) {
+		t.Error("expected the original file without its tEXt chunk")
+	}
+}
+
+func TestCleanImageStripsWebPExif(t *testing.T) {
+	src, err := encodeImage(newSolidImage(8, 4, color.Black), formatWebP, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp8x := []byte{'V', 'P', '8', 'X', 10, 0, 0, 0, webpFlagEXIF, 0, 0, 0, 7, 0, 0, 3, 0, 0}
+	exif := append([]byte{'E', 'X', 'I', 'F', 3, 0, 0, 0}, 1, 2, 3, 0)
+	data := append(append(append(append([]byte{}, src[:12]...), vp8x...), src[12:]...), exif...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+
+	cleaned, err := CleanImage(data, "image/webp")
+	if err != nil {
+		t.Fatalf("CleanImage returned error: %v", err)
+	}
+	if bytes.Contains(cleaned.Data, []byte("EXIF")) {
+		t.Error("EXIF chunk was not removed")
+	}
+	if cleaned.Data[20]&webpFlagEXIF != 0 {
+		t.Error("VP8X still announces EXIF")
+	}
+	if got := binary.LittleEndian.Uint32(cleaned.Data[4:]); int(got) != len(cleaned.Data)-8 {
+		t.Errorf("RIFF size %d does not match the file", got)
+	}
+	if cleaned.Width != 8 || cleaned.Height != 4 {
+		t.Errorf("unexpected dimensions: got %dx%d", cleaned.Width, cleaned.Height)
+	}
+}
+
+func TestCleanImageRejectsMismatchedType(t *testing.T) {
+	src := encodeTestImage(t, newSolidImage(8, 8, color.White), imaging.PNG)
+	if _, err := CleanImage(src, "image/jpeg"); err == nil {
+		t.Error("expected a PNG labelled as JPEG to be rejected")
+	}
+}
+
+// pngHeader returns a PNG signature and IHDR chunk declaring the dimensions,
+// without any pixel data
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	ihdr[12] = 8 // bit depth
+	ihdr[13] = 2 // RGB
+
+	chunk := binary.BigEndian.AppendUint32(nil, 13)
+	chunk = append(chunk, ihdr...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(ihdr))
+	return append([]byte("\x89PNG\r\n\x1a\n"), chunk...)
+}
+
+func TestCheckDimensions(t *testing.T) {
+	small := encodeTestImage(t, newSolidImage(80, 40, color.White), imaging.PNG)
+	if err := CheckDimensions(small, 100, 1); err != nil {
+		t.Errorf("80x40 image rejected: %v", err)
+	}
+	if err := CheckDimensions(small, 50, 0); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("80x40 image with 50 pixels per side: want ErrImageTooLarge, got %v", err)
+	}
+
+	bomb := pngHeader(100000, 100000)
+	if err := CheckDimensions(bomb, 0, 50); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("10 gigapixel header: want ErrImageTooLarge, got %v", err)
+	}
+	if err := CheckDimensions(bomb, 0, 0); err != nil {
+		t.Errorf("without limits: got %v", err)
+	}
+
+	if err := CheckDimensions([]byte("not an image"), 100, 1); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("garbage: want ErrInvalidImage, got %v", err)
+	}
+}
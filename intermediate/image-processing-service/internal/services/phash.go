@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+)
+
+// Perceptual hash algorithms; each hash is 64 bits
+const (
+	HashAverage    = "ahash"
+	HashDifference = "dhash"
+	HashPerceptual = "phash"
+)
+
+// MaxHashDistance is the Hamming distance between two opposite hashes
+const MaxHashDistance = 64
+
+// IsValidHashAlgorithm checks if the hash algorithm is supported
+func IsValidHashAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case HashAverage, HashDifference, HashPerceptual:
+		return true
+	}
+	return false
+}
+
+// ComputeHashes returns the average, difference and DCT hashes of an image.
+// Similar looking images, such as resized or re-encoded copies, get hashes a
+// small Hamming distance apart.
+func ComputeHashes(img image.Image) *models.ImageHashes {
+	return &models.ImageHashes{
+		AHash: formatHash(averageHash(img)),
+		DHash: formatHash(differenceHash(img)),
+		PHash: formatHash(perceptualHash(img)),
+	}
+}
+
+// HashImageData decodes an image and computes its hashes
+func HashImageData(data []byte) (*models.ImageHashes, error) {
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return ComputeHashes(img), nil
+}
+
+// HashDistance returns the Hamming distance between the hashes of two images
+// for an algorithm. ok is false when either image has no such hash.
+func HashDistance(a, b *models.ImageHashes, algorithm string) (distance int, ok bool) {
+	x, err := parseHash(hashValue(a, algorithm))
+	if err != nil {
+		return 0, false
+	}
+	y, err := parseHash(hashValue(b, algorithm))
+	if err != nil {
+		return 0, false
+	}
+	return bits.OnesCount64(x ^ y), true
+}
+
+func hashValue(hashes *models.ImageHashes, algorithm string) string {
+	if hashes == nil {
+		return ""
+	}
+	switch algorithm {
+	case HashAverage:
+		return hashes.AHash
+	case HashDifference:
+		return hashes.DHash
+	case HashPerceptual:
+		return hashes.PHash
+	}
+	return ""
+}
+
+// Hashes are stored as 16 hex digits; MongoDB has no unsigned 64-bit integers
+func formatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func parseHash(s string) (uint64, error) {
+	if len(s) != 16 {
+		return 0, fmt.Errorf("invalid hash: %q", s)
+	}
+	return strconv.ParseUint(s, 16, 64)
+}
+
+// luminance shrinks an image to width x height and returns its brightness,
+// with transparent areas flattened onto white
+func luminance(img image.Image, width, height int) [][]float64 {
+	small := imaging.Resize(img, width, height, imaging.Box)
+	flat := imaging.Overlay(imaging.New(width, height, color.White), small, image.Pt(0, 0), 1)
+
+	values := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		values[y] = make([]float64, width)
+		for x := 0; x < width; x++ {
+			c := flat.NRGBAAt(x, y)
+			values[y][x] = 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+		}
+	}
+	return values
+}
+
+// averageHash sets a bit for each pixel of an 8x8 thumbnail brighter than
+// the mean
+func averageHash(img image.Image) uint64 {
+	values := luminance(img, 8, 8)
+
+	var mean float64
+	for _, row := range values {
+		for _, v := range row {
+			mean += v
+		}
+	}
+	mean /= 64
+
+	var hash uint64
+	for _, row := range values {
+		for _, v := range row {
+			hash <<= 1
+			if v > mean {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// differenceHash sets a bit for each pixel of a 9x8 thumbnail brighter than
+// its right neighbour, which follows gradients rather than absolute values
+func differenceHash(img image.Image) uint64 {
+	values := luminance(img, 9, 8)
+
+	var hash uint64
+	for _, row := range values {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if row[x] > row[x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// perceptualHash takes the DCT of a 32x32 thumbnail and sets a bit for each
+// of the 8x8 lowest frequencies above their median. The DC term, which only
+// carries the overall brightness, is left out of the median.
+func perceptualHash(img image.Image) uint64 {
+	const size, low = 32, 8
+	values := luminance(img, size, size)
+
+	// Separable 2D DCT-II, keeping only the low frequencies
+	var cosines [low][size]float64
+	for u := 0; u < low; u++ {
+		for x := 0; x < size; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * size))
+		}
+	}
+	var rows [size][low]float64
+	for y := 0; y < size; y++ {
+		for u := 0; u < low; u++ {
+			var sum float64
+			for x := 0; x < size; x++ {
+				sum += values[y][x] * cosines[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	var coefficients [low * low]float64
+	for v := 0; v < low; v++ {
+		for u := 0; u < low; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			coefficients[v*low+u] = sum
+		}
+	}
+
+	sorted := append([]float64(nil), coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for _, c := range coefficients {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
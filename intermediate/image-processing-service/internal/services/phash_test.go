@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newPatternImage draws shapes so that hashes have structure to work with
+func newPatternImage(width, height, seed int) *image.NRGBA {
+	img := imaging.New(width, height, color.White)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := x*64/width, y*64/height
+			v := uint8((fx*seed + fy*fy/(seed+1)) % 256)
+			if (fx/16+fy/16+seed)%2 == 0 {
+				v = 255 - v
+			}
+			img.SetNRGBA(x, y, color.NRGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestHashesMatchResizedAndReencodedCopies(t *testing.T) {
+	original := newPatternImage(256, 192, 3)
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, imaging.Resize(original, 120, 0, imaging.Lanczos), imaging.JPEG, imaging.JPEGQuality(70)); err != nil {
+		t.Fatalf("failed to encode copy: %v", err)
+	}
+	copyHashes, err := HashImageData(buf.Bytes())
+	if err != nil {
+		t.Fatalf("HashImageData returned error: %v", err)
+	}
+	originalHashes := ComputeHashes(original)
+	otherHashes := ComputeHashes(newPatternImage(256, 192, 7))
+
+	for _, algorithm := range []string{HashAverage, HashDifference, HashPerceptual} {
+		same, ok := HashDistance(originalHashes, copyHashes, algorithm)
+		if !ok {
+			t.Fatalf("%s: HashDistance not ok", algorithm)
+		}
+		if same > 6 {
+			t.Errorf("%s: distance to a resized copy = %d, want <= 6", algorithm, same)
+		}
+		different, _ := HashDistance(originalHashes, otherHashes, algorithm)
+		if different <= same {
+			t.Errorf("%s: distance to a different image = %d, want more than %d", algorithm, different, same)
+		}
+	}
+}
+
+func TestHashDistance(t *testing.T) {
+	a := &models.ImageHashes{AHash: "0000000000000000", DHash: "ffffffffffffffff", PHash: "00000000000000ff"}
+	b := &models.ImageHashes{AHash: "0000000000000000", DHash: "0000000000000000", PHash: "000000000000000f"}
+
+	if d, ok := HashDistance(a, b, HashAverage); !ok || d != 0 {
+		t.Errorf("ahash distance = %d, %v; want 0", d, ok)
+	}
+	if d, ok := HashDistance(a, b, HashDifference); !ok || d != MaxHashDistance {
+		t.Errorf("dhash distance = %d, %v; want 64", d, ok)
+	}
+	if d, ok := HashDistance(a, b, HashPerceptual); !ok || d != 4 {
+		t.Errorf("phash distance = %d, %v; want 4", d, ok)
+	}
+	if _, ok := HashDistance(a, nil, HashPerceptual); ok {
+		t.Error("HashDistance with a missing hash: want not ok")
+	}
+	if _, ok := HashDistance(a, &models.ImageHashes{PHash: "xyz"}, HashPerceptual); ok {
+		t.Error("HashDistance with an invalid hash: want not ok")
+	}
+}
+
+func TestRankSimilar(t *testing.T) {
+	base := &models.ImageHashes{PHash: "0000000000000000"}
+	image := func(phash string, day int) *models.Image {
+		return &models.Image{
+			ID:        primitive.NewObjectID(),
+			Hashes:    &models.ImageHashes{PHash: phash},
+			CreatedAt: time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC),
+		}
+	}
+	far := image("ffffffffffffffff", 1)
+	newer := image("0000000000000003", 3)
+	older := image("0000000000000003", 2)
+	exact := image("0000000000000000", 4)
+	unhashed := &models.Image{ID: primitive.NewObjectID()}
+
+	similar := rankSimilar([]*models.Image{far, newer, older, exact, unhashed}, base, HashPerceptual, 2, 0)
+	want := []primitive.ObjectID{exact.ID, older.ID, newer.ID}
+	if len(similar) != len(want) {
+		t.Fatalf("got %d similar images, want %d", len(similar), len(want))
+	}
+	for i, id := range want {
+		if similar[i].Image.ID != id.Hex() {
+			t.Errorf("similar[%d] = %s, want %s", i, similar[i].Image.ID, id.Hex())
+		}
+	}
+	if similar[1].Distance != 2 {
+		t.Errorf("distance = %d, want 2", similar[1].Distance)
+	}
+
+	if limited := rankSimilar([]*models.Image{far, newer, older, exact}, base, HashPerceptual, 2, 1); len(limited) != 1 {
+		t.Errorf("limit 1 returned %d images", len(limited))
+	}
+}
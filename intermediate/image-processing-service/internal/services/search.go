@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Tag limits
+const (
+	MaxTags      = 30
+	maxTagLength = 50
+)
+
+// DefaultImageSort lists the newest images first
+const DefaultImageSort = "-created_at"
+
+// sortFields are the fields images can be sorted by; "-field" sorts
+// descending
+var sortFields = map[string]bool{
+	"created_at": true,
+	"size":       true,
+	"width":      true,
+	"height":     true,
+	"filename":   true,
+}
+
+// ImageSearch filters a user's images. Zero values do not filter.
+type ImageSearch struct {
+	// Tags an image must all have
+	Tags        []string
+	AlbumID     *primitive.ObjectID
+	ContentType string
+	MinWidth    int
+	MaxWidth    int
+	MinHeight   int
+	MaxHeight   int
+	// From and To bound the upload time, inclusive
+	From  time.Time
+	To    time.Time
+	Sort  string
+	Page  int
+	Limit int
+}
+
+// IsValidImageSort checks if images can be sorted by the field
+func IsValidImageSort(sort string) bool {
+	return sortFields[strings.TrimPrefix(sort, "-")]
+}
+
+// NormalizeTags lowercases and trims tags and drops empty and repeated ones.
+// Tags may contain letters, digits, spaces and - _ : . characters.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" -_:.", r) {
+				return nil, fmt.Errorf("tag %q contains an invalid character %q", tag, r)
+			}
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", MaxTags)
+	}
+	return normalized, nil
+}
+
+// filter returns the MongoDB filter of a search over a user's images
+func (q *ImageSearch) filter(userID primitive.ObjectID) bson.M {
+	filter := bson.M{"user_id": userID}
+	if len(q.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": q.Tags}
+	}
+	if q.AlbumID != nil {
+		filter["album_ids"] = *q.AlbumID
+	}
+	if q.ContentType != "" {
+		filter["content_type"] = q.ContentType
+	}
+	if r := intRange(q.MinWidth, q.MaxWidth); r != nil {
+		filter["width"] = r
+	}
+	if r := intRange(q.MinHeight, q.MaxHeight); r != nil {
+		filter["height"] = r
+	}
+	if !q.From.IsZero() || !q.To.IsZero() {
+		r := bson.M{}
+		if !q.From.IsZero() {
+			r["$gte"] = q.From
+		}
+		if !q.To.IsZero() {
+			r["$lte"] = q.To
+		}
+		filter["created_at"] = r
+	}
+	return filter
+}
+
+func intRange(lo, hi int) bson.M {
+	if lo <= 0 && hi <= 0 {
+		return nil
+	}
+	r := bson.M{}
+	if lo > 0 {
+		r["$gte"] = lo
+	}
+	if hi > 0 {
+		r["$lte"] = hi
+	}
+	return r
+}
+
+// sortOrder returns the sort document of a search. _id breaks ties, so pages
+// do not overlap when many images share a value.
+func (q *ImageSearch) sortOrder() bson.D {
+	sort := q.Sort
+	if !IsValidImageSort(sort) {
+		sort = DefaultImageSort
+	}
+	direction := 1
+	if strings.HasPrefix(sort, "-") {
+		direction = -1
+		sort = sort[1:]
+	}
+	return bson.D{{Key: sort, Value: direction}, {Key: "_id", Value: direction}}
+}
+
+// SearchImages returns a page of a user's images matching the search and the
+// total number of matches
+func (s *ImageService) SearchImages(ctx context.Context, userID primitive.ObjectID, q *ImageSearch) ([]*models.Image, int64, error) {
+	collection := s.db.Collection("images")
+	filter := q.filter(userID)
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		s.logger.Error("Failed to count images", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to count images: %w", err)
+	}
+
+	opts := options.Find().
+		SetSkip(int64((q.Page - 1) * q.Limit)).
+		SetLimit(int64(q.Limit)).
+		SetSort(q.sortOrder())
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		s.logger.Error("Failed to search images", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to search images: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	images := []*models.Image{}
+	if err := cursor.All(ctx, &images); err != nil {
+		s.logger.Error("Failed to decode images", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to decode images: %w", err)
+	}
+
+	return images, total, nil
+}
+
+// SetTags replaces the tags of an image; tags must be normalized
+func (s *ImageService) SetTags(ctx context.Context, imageID primitive.ObjectID, tags []string) error {
+	update := bson.M{"$set": bson.M{"tags": tags, "updated_at": time.Now()}}
+	if len(tags) == 0 {
+		update = bson.M{"$unset": bson.M{"tags": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	}
+
+	_, err := s.db.Collection("images").UpdateOne(ctx, bson.M{"_id": imageID}, update)
+	if err != nil {
+		s.logger.Error("Failed to set image tags", logger.Error(err))
+		return fmt.Errorf("failed to set image tags: %w", err)
+	}
+
+	return nil
+}
+
+// CreateIndexes creates the indexes behind image listing and search. Every
+// query is scoped to a user, so user_id leads each index.
+func (s *ImageService) CreateIndexes(ctx context.Context) error {
+	keys := []bson.D{
+		{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		{{Key: "user_id", Value: 1}, {Key: "tags", Value: 1}, {Key: "created_at", Value: -1}},
+		{{Key: "user_id", Value: 1}, {Key: "album_ids", Value: 1}, {Key: "created_at", Value: -1}},
+		{{Key: "user_id", Value: 1}, {Key: "content_type", Value: 1}, {Key: "created_at", Value: -1}},
+		{{Key: "user_id", Value: 1}, {Key: "width", Value: 1}, {Key: "height", Value: 1}},
+		{{Key: "user_id", Value: 1}, {Key: "size", Value: 1}},
+	}
+
+	indexes := make([]mongo.IndexModel, len(keys))
+	for i, k := range keys {
+		indexes[i] = mongo.IndexModel{Keys: k}
+	}
+
+	if _, err := s.db.Collection("images").Indexes().CreateMany(ctx, indexes); err != nil {
+		s.logger.Error("Failed to create image indexes", logger.Error(err))
+		return fmt.Errorf("failed to create image indexes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := NormalizeTags([]string{" Beach ", "beach", "summer  2024", "", "city:tokyo"})
+	if err != nil {
+		t.Fatalf("NormalizeTags returned error: %v", err)
+	}
+	want := []string{"beach", "summer 2024", "city:tokyo"}
+	if !reflect.DeepEqual(tags, want) {
+		t.Fatalf("NormalizeTags = %q, want %q", tags, want)
+	}
+
+	for _, invalid := range [][]string{
+		{"a/b"},
+		{"<script>"},
+		{strings.Repeat("a", maxTagLength+1)},
+	} {
+		if _, err := NormalizeTags(invalid); err == nil {
+			t.Errorf("NormalizeTags(%q): expected error", invalid)
+		}
+	}
+
+	many := make([]string, MaxTags+1)
+	for i := range many {
+		many[i] = string(rune('a'+i%26)) + string(rune('a'+i/26))
+	}
+	if _, err := NormalizeTags(many); err == nil {
+		t.Errorf("NormalizeTags with %d tags: expected error", len(many))
+	}
+}
+
+func TestImageSearchFilter(t *testing.T) {
+	userID := primitive.NewObjectID()
+	albumID := primitive.NewObjectID()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	q := &ImageSearch{
+		Tags:        []string{"beach", "summer"},
+		AlbumID:     &albumID,
+		ContentType: "image/png",
+		MinWidth:    100,
+		MaxHeight:   500,
+		From:        from,
+	}
+	want := bson.M{
+		"user_id":      userID,
+		"tags":         bson.M{"$all": []string{"beach", "summer"}},
+		"album_ids":    albumID,
+		"content_type": "image/png",
+		"width":        bson.M{"$gte": 100},
+		"height":       bson.M{"$lte": 500},
+		"created_at":   bson.M{"$gte": from},
+	}
+	if got := q.filter(userID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("filter =\n%v\nwant\n%v", got, want)
+	}
+
+	if got := (&ImageSearch{}).filter(userID); !reflect.DeepEqual(got, bson.M{"user_id": userID}) {
+		t.Fatalf("empty search filter = %v, want only user_id", got)
+	}
+}
+
+func TestImageSearchSortOrder(t *testing.T) {
+	tests := []struct {
+		sort string
+		want bson.D
+	}{
+		{"", bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{"size", bson.D{{Key: "size", Value: 1}, {Key: "_id", Value: 1}}},
+		{"-width", bson.D{{Key: "width", Value: -1}, {Key: "_id", Value: -1}}},
+		{"password", bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+	}
+
+	for _, tt := range tests {
+		q := &ImageSearch{Sort: tt.sort}
+		if got := q.sortOrder(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sortOrder(%q) = %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+
+	if IsValidImageSort("-password") || !IsValidImageSort("-filename") {
+		t.Error("IsValidImageSort accepted an unknown field or rejected a known one")
+	}
+}
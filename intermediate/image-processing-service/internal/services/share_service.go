@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/database"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrShareUnavailable is returned for a share link that is revoked, expired
+// or out of downloads
+var ErrShareUnavailable = errors.New("share link is no longer available")
+
+// ErrSharePassword is returned for a missing or wrong share link password
+var ErrSharePassword = errors.New("invalid share link password")
+
+// shareTokenBytes is the entropy of share link tokens
+const shareTokenBytes = 32
+
+type ShareService struct {
+	db     *database.MongoDB
+	logger *zap.Logger
+}
+
+func NewShareService(db *database.MongoDB, logger *zap.Logger) *ShareService {
+	return &ShareService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateIndexes creates the indexes looking links up by token and listing a
+// user's links
+func (s *ShareService) CreateIndexes(ctx context.Context) error {
+	_, err := s.db.Collection("share_links").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		s.logger.Error("Failed to create share link indexes", logger.Error(err))
+		return fmt.Errorf("failed to create share link indexes: %w", err)
+	}
+	return nil
+}
+
+// CreateLink creates a share link with a new random token. A non-empty
+// password is stored as a bcrypt hash.
+func (s *ShareService) CreateLink(ctx context.Context, link *models.ShareLink, password string) error {
+	token, err := newShareToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate share token: %w", err)
+	}
+
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash share password: %w", err)
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	link.ID = primitive.NewObjectID()
+	link.Token = token
+	link.CreatedAt = time.Now()
+
+	if _, err := s.db.Collection("share_links").InsertOne(ctx, link); err != nil {
+		s.logger.Error("Failed to insert share link", logger.Error(err))
+		return fmt.Errorf("failed to insert share link: %w", err)
+	}
+
+	s.logger.Info("Share link created", logger.String("id", link.ID.Hex()), logger.String("image_id", link.ImageID.Hex()))
+	return nil
+}
+
+// GetLink retrieves a share link by ID
+func (s *ShareService) GetLink(ctx context.Context, id string) (*models.ShareLink, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid share link ID: %w", err)
+	}
+	return s.findOne(ctx, bson.M{"_id": objectID})
+}
+
+// GetLinkByToken retrieves a share link by its public token
+func (s *ShareService) GetLinkByToken(ctx context.Context, token string) (*models.ShareLink, error) {
+	return s.findOne(ctx, bson.M{"token": token})
+}
+
+func (s *ShareService) findOne(ctx context.Context, filter bson.M) (*models.ShareLink, error) {
+	var link models.ShareLink
+	err := s.db.Collection("share_links").FindOne(ctx, filter).Decode(&link)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		s.logger.Error("Failed to get share link", logger.Error(err))
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+// GetActiveLinks lists a user's usable links, newest first, optionally only
+// those of one image
+func (s *ShareService) GetActiveLinks(ctx context.Context, userID primitive.ObjectID, imageID *primitive.ObjectID) ([]*models.ShareLink, error) {
+	filter := activeFilter(time.Now())
+	filter["user_id"] = userID
+	if imageID != nil {
+		filter["image_id"] = *imageID
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := s.db.Collection("share_links").Find(ctx, filter, opts)
+	if err != nil {
+		s.logger.Error("Failed to find share links", logger.Error(err))
+		return nil, fmt.Errorf("failed to find share links: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	links := []*models.ShareLink{}
+	if err := cursor.All(ctx, &links); err != nil {
+		s.logger.Error("Failed to decode share links", logger.Error(err))
+		return nil, fmt.Errorf("failed to decode share links: %w", err)
+	}
+
+	return links, nil
+}
+
+// RevokeLink makes a link unusable. Revoking a revoked link is a no-op.
+func (s *ShareService) RevokeLink(ctx context.Context, link *models.ShareLink) error {
+	now := time.Now()
+	_, err := s.db.Collection("share_links").UpdateOne(ctx,
+		bson.M{"_id": link.ID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now}},
+	)
+	if err != nil {
+		s.logger.Error("Failed to revoke share link", logger.Error(err))
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+
+	s.logger.Info("Share link revoked", logger.String("id", link.ID.Hex()))
+	return nil
+}
+
+// CheckPassword returns ErrSharePassword unless the password matches the
+// link's, or the link has none
+func (s *ShareService) CheckPassword(link *models.ShareLink, password string) error {
+	if link.PasswordHash == "" {
+		return nil
+	}
+	if password == "" || bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+		return ErrSharePassword
+	}
+	return nil
+}
+
+// RecordDownload counts a download. The check and the increment are one
+// update, so concurrent downloads cannot exceed the limit; ErrShareUnavailable
+// is returned when the link is no longer active.
+func (s *ShareService) RecordDownload(ctx context.Context, link *models.ShareLink) error {
+	now := time.Now()
+	filter := activeFilter(now)
+	filter["_id"] = link.ID
+
+	result, err := s.db.Collection("share_links").UpdateOne(ctx, filter, bson.M{
+		"$inc": bson.M{"downloads": 1},
+		"$set": bson.M{"last_download_at": now},
+	})
+	if err != nil {
+		s.logger.Error("Failed to record share download", logger.Error(err))
+		return fmt.Errorf("failed to record share download: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrShareUnavailable
+	}
+
+	link.Downloads++
+	link.LastDownloadAt = &now
+	return nil
+}
+
+// activeFilter matches links that are not revoked, not expired and have
+// downloads left; it mirrors models.ShareLink.Active
+func activeFilter(now time.Time) bson.M {
+	return bson.M{
+		"revoked_at": bson.M{"$exists": false},
+		"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"expires_at": bson.M{"$exists": false}},
+				bson.M{"expires_at": bson.M{"$gt": now}},
+			}},
+			bson.M{"$or": bson.A{
+				bson.M{"max_downloads": 0},
+				bson.M{"$expr": bson.M{"$lt": bson.A{"$downloads", "$max_downloads"}}},
+			}},
+		},
+	}
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
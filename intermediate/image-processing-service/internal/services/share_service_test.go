@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestShareLinkActive(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	cases := []struct {
+		name string
+		link models.ShareLink
+		want bool
+	}{
+		{"unlimited", models.ShareLink{}, true},
+		{"revoked", models.ShareLink{RevokedAt: &past}, false},
+		{"expired", models.ShareLink{ExpiresAt: &past}, false},
+		{"expires exactly now", models.ShareLink{ExpiresAt: &now}, false},
+		{"not yet expired", models.ShareLink{ExpiresAt: &future}, true},
+		{"downloads left", models.ShareLink{MaxDownloads: 2, Downloads: 1}, true},
+		{"out of downloads", models.ShareLink{MaxDownloads: 2, Downloads: 2}, false},
+	}
+	for _, tc := range cases {
+		if got := tc.link.Active(now); got != tc.want {
+			t.Errorf("%s: Active = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckSharePassword(t *testing.T) {
+	s := &ShareService{}
+
+	if err := s.CheckPassword(&models.ShareLink{}, ""); err != nil {
+		t.Fatalf("a link without a password should not need one: %v", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := &models.ShareLink{PasswordHash: string(hash)}
+
+	if err := s.CheckPassword(link, "secret"); err != nil {
+		t.Fatalf("the right password was rejected: %v", err)
+	}
+	for _, bad := range []string{"", "Secret", "secret "} {
+		if err := s.CheckPassword(link, bad); err != ErrSharePassword {
+			t.Errorf("%q: expected ErrSharePassword, got %v", bad, err)
+		}
+	}
+}
+
+func TestNewShareToken(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		token, err := newShareToken()
+		if err != nil {
+			t.Fatalf("newShareToken returned error: %v", err)
+		}
+		if len(token) != 43 {
+			t.Fatalf("unexpected token length %d: %s", len(token), token)
+		}
+		if seen[token] {
+			t.Fatalf("duplicate token %s", token)
+		}
+		seen[token] = true
+	}
+}
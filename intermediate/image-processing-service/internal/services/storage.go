@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/config"
+)
+
+// Storage backends selected by STORAGE_BACKEND
+const (
+	StorageS3    = "s3"
+	StorageLocal = "local"
+)
+
+// ErrFileNotFound is returned by the local backend for a missing key
+var ErrFileNotFound = errors.New("file not found")
+
+// Storage keeps originals, processed versions and cached derivatives by key
+type Storage interface {
+	UploadFile(ctx context.Context, key string, data []byte, contentType string) error
+	GetFile(ctx context.Context, key string) ([]byte, error)
+	DeleteFile(ctx context.Context, key string) error
+	// DeletePrefix deletes every file under a directory-like prefix such as
+	// "derived/<image ID>/". The prefix must end with a slash.
+	DeletePrefix(ctx context.Context, prefix string) error
+	// DownloadURL returns a URL that serves the file directly for a limited
+	// time, as an attachment with the given filename. Local URLs are paths
+	// relative to the service.
+	DownloadURL(ctx context.Context, key, filename string, expires time.Duration) (string, error)
+}
+
+// NewStorage returns the backend selected in the configuration
+func NewStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case StorageS3, "":
+		return NewS3Service(cfg), nil
+	case StorageLocal:
+		return NewLocalStorage(cfg.LocalStorageDir, cfg.URLSigningKey)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
+
+// IsNotFound reports whether a storage error means the key does not exist
+func IsNotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	return errors.Is(err, ErrFileNotFound) || errors.As(err, &noSuchKey) || errors.As(err, &notFound)
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+)
+
+// MaxTransformSpecLength limits the transformation segment of an image URL
+const MaxTransformSpecLength = 200
+
+// URLSigner signs on-the-fly image URLs so only URLs issued by the API render
+type URLSigner struct {
+	key []byte
+}
+
+func NewURLSigner(key string) *URLSigner {
+	return &URLSigner{key: []byte(key)}
+}
+
+// signature is the HMAC-SHA256 of the image ID, the transformation segment and
+// the optional expiry, base64url encoded
+func (s *URLSigner) signature(imageID, spec string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(imageID + "/" + spec))
+	if expires > 0 {
+		mac.Write([]byte("?e=" + strconv.FormatInt(expires, 10)))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Path returns the signed path of an on-the-fly image. A zero expires never expires.
+func (s *URLSigner) Path(imageID, spec string, expires int64) string {
+	q := url.Values{}
+	q.Set("s", s.signature(imageID, spec, expires))
+	if expires > 0 {
+		q.Set("e", strconv.FormatInt(expires, 10))
+	}
+	return "/i/" + imageID + "/" + spec + "?" + q.Encode()
+}
+
+// Verify checks the signature and expiry of an on-the-fly image URL
+func (s *URLSigner) Verify(imageID, spec, signature string, expires int64, now time.Time) error {
+	expected := s.signature(imageID, spec, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("invalid signature")
+	}
+	if expires > 0 && now.Unix() > expires {
+		return fmt.Errorf("URL expired")
+	}
+	return nil
+}
+
+// transformKeys lists the keys of the URL grammar in canonical order, which is
+// also the order the steps are applied in
+var transformKeys = []string{"w", "h", "fit", "r", "fl", "gray", "br", "co", "bl", "sh", "f", "q"}
+
+// ParseTransformSpec parses the transformation segment of an on-the-fly URL,
+// such as "w_300,h_200,fit_cover,f_webp", into a pipeline. It also returns
+// the canonical form of the segment, which names the cached derivative.
+//
+//	w_<px>, h_<px>     resize; fit_fill (default), fit_contain or fit_cover
+//	r_<90|180|270>     rotate
+//	fl_<h|v>           flip
+//	gray               grayscale
+//	br_<n>, co_<n>     brightness and contrast, -100..100
+//	bl_<sigma>         blur
+//	sh_<sigma>         sharpen
+//	f_<format>, q_<n>  output format and quality; f_auto follows the Accept header
+func ParseTransformSpec(spec string) ([]models.Transformation, string, error) {
+	if spec == "" {
+		return nil, "", fmt.Errorf("transformation is required")
+	}
+	if len(spec) > MaxTransformSpecLength {
+		return nil, "", fmt.Errorf("transformation is too long")
+	}
+
+	values := make(map[string]string)
+	for _, token := range strings.Split(spec, ",") {
+		key, value, _ := strings.Cut(token, "_")
+		known := false
+		for _, k := range transformKeys {
+			if k == key {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, "", fmt.Errorf("unknown transformation: %s", token)
+		}
+		if _, dup := values[key]; dup {
+			return nil, "", fmt.Errorf("duplicate transformation: %s", key)
+		}
+		if (key == "gray") != (value == "") {
+			return nil, "", fmt.Errorf("invalid transformation: %s", token)
+		}
+		values[key] = value
+	}
+
+	var steps []models.Transformation
+	if values["w"] != "" || values["h"] != "" {
+		params := map[string]string{"width": values["w"], "height": values["h"]}
+		if fit := values["fit"]; fit != "" {
+			params["fit"] = fit
+		}
+		steps = append(steps, models.Transformation{Operation: string(models.OperationResize), Parameters: params})
+	} else if values["fit"] != "" {
+		return nil, "", fmt.Errorf("fit requires w or h")
+	}
+	if v, ok := values["r"]; ok {
+		steps = append(steps, models.Transformation{Operation: string(models.OperationRotate), Parameters: map[string]string{"angle": v}})
+	}
+	if v, ok := values["fl"]; ok {
+		mode := map[string]string{"h": "horizontal", "v": "vertical"}[v]
+		if mode == "" {
+			return nil, "", fmt.Errorf("invalid flip: %s", v)
+		}
+		steps = append(steps, models.Transformation{Operation: string(models.OperationFlip), Parameters: map[string]string{"mode": mode}})
+	}
+	if _, ok := values["gray"]; ok {
+		steps = append(steps, models.Transformation{Operation: string(models.OperationGrayscale)})
+	}
+	if v, ok := values["br"]; ok {
+		steps = append(steps, models.Transformation{Operation: string(models.OperationBrightness), Parameters: map[string]string{"value": v}})
+	}
+	if v, ok := values["co"]; ok {
+		steps = append(steps, models.Transformation{Operation: string(models.OperationContrast), Parameters: map[string]string{"value": v}})
+	}
+	if v, ok := values["bl"]; ok {
+		steps = append(steps, models.Transformation{Operation: string(models.OperationBlur), Parameters: map[string]string{"sigma": v}})
+	}
+	if v, ok := values["sh"]; ok {
+		steps = append(steps, models.Transformation{Operation: string(models.OperationSharpen), Parameters: map[string]string{"sigma": v}})
+	}
+	_, hasFormat := values["f"]
+	_, hasQuality := values["q"]
+	if hasFormat || hasQuality {
+		params := map[string]string{}
+		if hasFormat {
+			params["format"] = strings.ToLower(values["f"])
+		}
+		if hasQuality {
+			params["quality"] = values["q"]
+		}
+		op := models.OperationConvert
+		if !hasFormat {
+			op = models.OperationCompress
+		}
+		steps = append(steps, models.Transformation{Operation: string(op), Parameters: params})
+	}
+
+	if err := ValidateTransformations(steps); err != nil {
+		return nil, "", err
+	}
+
+	canonical := make([]string, 0, len(values))
+	for _, k := range transformKeys {
+		if v, ok := values[k]; ok {
+			if k == "gray" {
+				canonical = append(canonical, k)
+			} else {
+				canonical = append(canonical, k+"_"+strings.ToLower(v))
+			}
+		}
+	}
+	return steps, strings.Join(canonical, ","), nil
+}
+
+// ResolveAutoSpec replaces f_auto in a canonical spec with the negotiated
+// format, so it shares its cache entry with the explicit URL
+func ResolveAutoSpec(canonicalSpec, format string) string {
+	tokens := strings.Split(canonicalSpec, ",")
+	for i, token := range tokens {
+		if token == "f_"+FormatAuto {
+			tokens[i] = "f_" + format
+		}
+	}
+	return strings.Join(tokens, ",")
+}
+
+// DerivativeKey is the storage key of a cached on-the-fly derivative
+func DerivativeKey(imageID, canonicalSpec string) string {
+	sum := sha256.Sum256([]byte(canonicalSpec))
+	return DerivativePrefix(imageID) + hex.EncodeToString(sum[:16])
+}
+
+// DerivativePrefix is the storage prefix of all cached derivatives of an image
+func DerivativePrefix(imageID string) string {
+	return "derived/" + imageID + "/"
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+)
+
+func TestParseTransformSpec(t *testing.T) {
+	steps, canonical, err := ParseTransformSpec("f_PNG,h_200,w_300,fit_cover,gray")
+	if err != nil {
+		t.Fatalf("ParseTransformSpec returned error: %v", err)
+	}
+	if canonical != "w_300,h_200,fit_cover,gray,f_png" {
+		t.Fatalf("unexpected canonical form: %s", canonical)
+	}
+	ops := make([]string, len(steps))
+	for i, s := range steps {
+		ops[i] = s.Operation
+	}
+	if strings.Join(ops, ",") != "resize,grayscale,convert" {
+		t.Fatalf("unexpected pipeline: %v", ops)
+	}
+	if steps[0].Parameters["fit"] != "cover" || steps[2].Parameters["format"] != "png" {
+		t.Fatalf("unexpected parameters: %+v", steps)
+	}
+
+	// Equivalent specs share a canonical form and so a cached derivative
+	_, other, _ := ParseTransformSpec("gray,w_300,fit_cover,h_200,f_png")
+	if DerivativeKey("abc", other) != DerivativeKey("abc", canonical) {
+		t.Fatalf("equivalent specs should share a derivative key")
+	}
+
+	for _, bad := range []string{"", "w_abc", "w_300,w_200", "x_1", "gray_1", "fit_cover", "fit_cover,w_300", "r_45", "fl_d", "f_xcf", "q_101", "w_99999"} {
+		if _, _, err := ParseTransformSpec(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner("secret")
+	now := time.Unix(1700000000, 0)
+
+	path := signer.Path("img1", "w_300", 0)
+	sig := strings.TrimPrefix(path[strings.Index(path, "?"):], "?s=")
+	if !strings.HasPrefix(path, "/i/img1/w_300?s=") {
+		t.Fatalf("unexpected path: %s", path)
+	}
+	if err := signer.Verify("img1", "w_300", sig, 0, now); err != nil {
+		t.Fatalf("expected a valid signature, got %v", err)
+	}
+	if err := signer.Verify("img1", "w_3000", sig, 0, now); err == nil {
+		t.Fatal("a changed transformation should not verify")
+	}
+	if err := signer.Verify("img2", "w_300", sig, 0, now); err == nil {
+		t.Fatal("another image should not verify")
+	}
+	if err := NewURLSigner("other").Verify("img1", "w_300", sig, 0, now); err == nil {
+		t.Fatal("another key should not verify")
+	}
+
+	expires := now.Add(time.Hour).Unix()
+	expiring := signer.signature("img1", "w_300", expires)
+	if err := signer.Verify("img1", "w_300", expiring, expires, now); err != nil {
+		t.Fatalf("expected a valid expiring signature, got %v", err)
+	}
+	if err := signer.Verify("img1", "w_300", expiring, expires+3600, now); err == nil {
+		t.Fatal("an extended expiry should not verify")
+	}
+	if err := signer.Verify("img1", "w_300", expiring, expires, now.Add(2*time.Hour)); err == nil {
+		t.Fatal("an expired URL should not verify")
+	}
+}
+
+func TestApplyOperationResizeFit(t *testing.T) {
+	svc := newTestImageService(t)
+	src := newSolidImage(200, 100, color.RGBA{255, 0, 0, 255})
+
+	cases := map[string][2]int{"cover": {50, 50}, "contain": {50, 25}, "fill": {50, 50}}
+	for fit, want := range cases {
+		dst, _, _, err := svc.applyOperation(src, "image/png", string(models.OperationResize), map[string]string{
+			"width": "50", "height": "50", "fit": fit,
+		})
+		if err != nil {
+			t.Fatalf("%s: applyOperation returned error: %v", fit, err)
+		}
+		if b := dst.Bounds(); b.Dx() != want[0] || b.Dy() != want[1] {
+			t.Errorf("%s: got %dx%d, want %dx%d", fit, b.Dx(), b.Dy(), want[0], want[1])
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/config"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ErrQuotaExceeded is returned when an upload or processing request would
+// take a user over their plan
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ComputeUsage adds up the sizes of a user's originals, processed versions
+// and cached derivatives
+func (s *ImageService) ComputeUsage(ctx context.Context, userID primitive.ObjectID) (*models.Usage, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"user_id": userID}},
+		bson.M{"$group": bson.M{
+			"_id":    nil,
+			"bytes":  bson.M{"$sum": bson.M{"$add": bson.A{"$size", bson.M{"$sum": "$processed.size"}, bson.M{"$sum": "$derivatives.size"}}}},
+			"images": bson.M{"$sum": 1},
+		}},
+	}
+
+	cursor, err := s.db.Collection("images").Aggregate(ctx, pipeline)
+	if err != nil {
+		s.logger.Error("Failed to aggregate usage", logger.Error(err))
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	usage := &models.Usage{}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(usage); err != nil {
+			return nil, fmt.Errorf("failed to decode usage: %w", err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+
+	usage.UpdatedAt = time.Now()
+	return usage, nil
+}
+
+// RecomputeUsage computes a user's usage and stores it on the user. It runs
+// after every upload, processing and deletion.
+func (s *ImageService) RecomputeUsage(ctx context.Context, userID primitive.ObjectID) (*models.Usage, error) {
+	usage, err := s.ComputeUsage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Collection("users").UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"usage": usage}})
+	if err != nil {
+		s.logger.Error("Failed to store usage", logger.Error(err))
+		return nil, fmt.Errorf("failed to store usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// recomputeUsage is RecomputeUsage for callers that must not fail because of
+// it; usage is recomputed again on the next change
+func (s *ImageService) recomputeUsage(ctx context.Context, userID primitive.ObjectID) {
+	if _, err := s.RecomputeUsage(ctx, userID); err != nil {
+		s.logger.Warn("Failed to recompute usage", logger.String("user_id", userID.Hex()), logger.Error(err))
+	}
+}
+
+// GetUsage returns the stored usage of a user, computing it for users who
+// have not uploaded since usage tracking was added
+func (s *ImageService) GetUsage(ctx context.Context, userID primitive.ObjectID) (*models.Usage, error) {
+	var user models.User
+	err := s.db.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		s.logger.Error("Failed to get user", logger.Error(err))
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.Usage != nil {
+		return user.Usage, nil
+	}
+
+	return s.RecomputeUsage(ctx, userID)
+}
+
+// CheckQuota returns ErrQuotaExceeded if adding addImages images of addBytes
+// in total would take the user over the plan. Usage is computed fresh, but
+// concurrent requests can still overshoot the quota slightly.
+func (s *ImageService) CheckQuota(ctx context.Context, userID primitive.ObjectID, plan config.Plan, addBytes, addImages int64) error {
+	usage, err := s.ComputeUsage(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkQuota(usage, plan, addBytes, addImages)
+}
+
+func checkQuota(usage *models.Usage, plan config.Plan, addBytes, addImages int64) error {
+	if plan.MaxImages > 0 && addImages > 0 && usage.Images+addImages > plan.MaxImages {
+		return fmt.Errorf("%w: the plan allows %d images", ErrQuotaExceeded, plan.MaxImages)
+	}
+	if plan.MaxBytes > 0 && usage.Bytes+addBytes > plan.MaxBytes {
+		return fmt.Errorf("%w: the plan allows %d bytes, %d are used", ErrQuotaExceeded, plan.MaxBytes, usage.Bytes)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/config"
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
+)
+
+func TestCheckQuota(t *testing.T) {
+	plan := config.Plan{MaxBytes: 1000, MaxImages: 3}
+	usage := &models.Usage{Bytes: 900, Images: 2}
+
+	tests := []struct {
+		name      string
+		plan      config.Plan
+		addBytes  int64
+		addImages int64
+		exceeded  bool
+	}{
+		{"fits", plan, 100, 1, false},
+		{"too many bytes", plan, 101, 1, true},
+		{"too many images", plan, 10, 2, true},
+		{"processing under the byte quota", plan, 0, 0, false},
+		{"unlimited plan", config.Plan{}, 1 << 40, 1000, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkQuota(usage, tt.plan, tt.addBytes, tt.addImages)
+			if got := errors.Is(err, ErrQuotaExceeded); got != tt.exceeded {
+				t.Errorf("checkQuota exceeded = %v (%v), want %v", got, err, tt.exceeded)
+			}
+		})
+	}
+
+	full := &models.Usage{Bytes: 1200, Images: 3}
+	if err := checkQuota(full, plan, 0, 0); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("processing over the byte quota: want ErrQuotaExceeded, got %v", err)
+	}
+}
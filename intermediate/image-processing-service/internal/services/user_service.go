@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/config"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/database"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/logger"
 	"github.com/jaygaha/roadmap-go-projects/intermediate/image-processing-service/internal/models"
@@ -28,6 +29,9 @@ func NewUserService(db *database.MongoDB, logger *zap.Logger) *UserService {
 
 func (s *UserService) CreateUser(user *models.User) error {
 	user.ID = primitive.NewObjectID()
+	if user.Plan == "" {
+		user.Plan = config.DefaultPlan
+	}
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 